@@ -8,6 +8,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/DataDog/datadog-agent/cmd/agent/common/path"
@@ -45,6 +46,12 @@ func LoadComponents(_ secrets.Component, wmeta workloadmeta.Component, ac autodi
 		filepath.Join(path.GetDistPath(), "conf.d"),
 		"",
 	}
+	// The integration configurations managed by the installer are read ahead of the user ones.
+	// The overlay only exists on hosts where configurations were delivered remotely.
+	managedConfdPath := filepath.Join(path.DefaultConfPath, "managed", "conf.d")
+	if _, err := os.Stat(managedConfdPath); err == nil {
+		confSearchPaths = append([]string{managedConfdPath}, confSearchPaths...)
+	}
 
 	// TODO: (components) - This is a temporary fix to start the autodiscovery component in CLI mode (agent flare and diagnose in forcelocal checks)
 	// because the autodiscovery component is not started by the agent automatically. Probably we can start it inside
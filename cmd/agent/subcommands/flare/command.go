@@ -410,7 +410,12 @@ func requestArchive(flareComp flare.Component, pdata flare.ProfileData) (string,
 
 	r, err := util.DoPost(c, urlstr, "application/json", bytes.NewBuffer(p))
 	if err != nil {
-		if r != nil && string(r) != "" {
+		var problem *util.Problem
+		if errors.As(err, &problem) {
+			// the agent answered with the problem details of the error rather than its message
+			fmt.Fprintf(color.Output, "The agent ran into an error while making the flare: %s\n", color.RedString(problem.Error()))
+			err = fmt.Errorf("Error getting flare from running agent: %w", problem)
+		} else if r != nil && string(r) != "" {
 			fmt.Fprintf(color.Output, "The agent ran into an error while making the flare: %s\n", color.RedString(string(r)))
 			err = fmt.Errorf("Error getting flare from running agent: %s", r)
 		} else {
@@ -131,12 +131,14 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/python"
 	"github.com/DataDog/datadog-agent/pkg/commonchecks"
 	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
 	"github.com/DataDog/datadog-agent/pkg/config/remote/data"
 	commonsettings "github.com/DataDog/datadog-agent/pkg/config/settings"
 	"github.com/DataDog/datadog-agent/pkg/jmxfetch"
 	"github.com/DataDog/datadog-agent/pkg/serializer"
 	clusteragentStatus "github.com/DataDog/datadog-agent/pkg/status/clusteragent"
 	endpointsStatus "github.com/DataDog/datadog-agent/pkg/status/endpoints"
+	fleetStatus "github.com/DataDog/datadog-agent/pkg/status/fleet"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	httpproxyStatus "github.com/DataDog/datadog-agent/pkg/status/httpproxy"
 	jmxStatus "github.com/DataDog/datadog-agent/pkg/status/jmx"
@@ -367,6 +369,10 @@ func getSharedFxOption() fx.Option {
 		fx.Provide(func(config config.Component) status.InformationProvider {
 			return status.NewInformationProvider(httpproxyStatus.GetProvider(config))
 		}),
+		fx.Provide(func(config config.Component) status.InformationProvider {
+			return status.NewInformationProvider(fleetStatus.GetProvider(config))
+		}),
+		fx.Provide(fleetStatus.GetFlareProvider),
 		fx.Supply(
 			rcclient.Params{
 				AgentName:    "core-agent",
@@ -610,6 +616,10 @@ func startAgent(
 	// start dependent services
 	go startDependentServices()
 
+	// the configuration is loaded, only the sources updated at runtime can write to it from now on:
+	// remote config, the runtime settings and the agent itself
+	pkgconfig.Datadog().Seal(model.SourceRC, model.SourceCLI, model.SourceAgentRuntime, model.SourceRuntimeOnly)
+
 	return nil
 }
 
@@ -12,15 +12,18 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	stdLog "log"
 	"net/http"
+	"strings"
 	"time"
 
 	authenticationv1 "k8s.io/api/authentication/v1"
 
 	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/metrics"
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/apiserver/common"
 	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/certificate"
@@ -29,14 +32,24 @@ import (
 
 	admiv1 "k8s.io/api/admission/v1"
 	admiv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
-const jsonContentType = "application/json"
+const (
+	jsonContentType = "application/json"
+
+	// patchTooLargeEventReason is the reason of the events recorded when a mutation isn't applied
+	// because its patch can't fit
+	patchTooLargeEventReason = "PatchTooLarge"
+)
 
 // MutateRequest contains the information of a mutation request
 type MutateRequest struct {
@@ -61,6 +74,9 @@ type WebhookFunc func(request *MutateRequest) ([]byte, error)
 type Server struct {
 	decoder runtime.Decoder
 	mux     *http.ServeMux
+	// eventRecorder records the Kubernetes events of the mutations that weren't applied, it's set once
+	// the server runs
+	eventRecorder record.EventRecorder
 }
 
 // NewServer creates an admission webhook server.
@@ -105,6 +121,11 @@ func (s *Server) Run(mainCtx context.Context, client kubernetes.Interface) error
 		tlsMinVersion = tls.VersionTLS10
 	}
 
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	defer eventBroadcaster.Shutdown()
+	s.eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "datadog-admission-controller"})
+
 	logWriter, _ := config.NewTLSHandshakeErrorWriter(4, seelog.WarnLvl)
 	server := &http.Server{
 		Addr:     fmt.Sprintf(":%d", config.Datadog().GetInt("admission_controller.port")),
@@ -189,6 +210,7 @@ func (s *Server) mutateHandler(w http.ResponseWriter, r *http.Request, webhookNa
 			APIClient:     apiClient,
 		}
 		jsonPatch, err := mutateFunc(&mutateRequest)
+		s.recordMutationEvent(&mutateRequest, err)
 		admissionReviewResp.Response = mutationResponse(jsonPatch, err)
 		admissionReviewResp.Response.UID = admissionReviewReq.Request.UID
 		response = admissionReviewResp
@@ -208,6 +230,7 @@ func (s *Server) mutateHandler(w http.ResponseWriter, r *http.Request, webhookNa
 			APIClient:     apiClient,
 		}
 		jsonPatch, err := mutateFunc(&mutateRequest)
+		s.recordMutationEvent(&mutateRequest, err)
 		admissionReviewResp.Response = responseV1ToV1beta1(mutationResponse(jsonPatch, err))
 		admissionReviewResp.Response.UID = admissionReviewReq.Request.UID
 		response = admissionReviewResp
@@ -226,18 +249,85 @@ func (s *Server) mutateHandler(w http.ResponseWriter, r *http.Request, webhookNa
 	}
 }
 
+// recordMutationEvent records a warning event when a mutation wasn't applied because its patch can't
+// fit, for the cluster operators to notice it besides the response returned to the client. The pod
+// isn't created yet, the event is recorded on its controller if it has one.
+func (s *Server) recordMutationEvent(request *MutateRequest, err error) {
+	var tooLargeErr *mutatecommon.PatchTooLargeError
+	if s.eventRecorder == nil || !errors.As(err, &tooLargeErr) {
+		return
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(request.Raw, &pod); err != nil {
+		log.Debugf("Could not decode the pod to record the event of its mutation: %v", err)
+		return
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = request.Namespace
+	}
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  pod.Namespace,
+		Name:       pod.Name,
+		UID:        pod.UID,
+	}
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		ref = &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Namespace:  pod.Namespace,
+			Name:       owner.Name,
+			UID:        owner.UID,
+		}
+	}
+	if ref.Name == "" {
+		log.Debugf("Could not record the event of the mutation of pod %s: it has neither a name nor a controller", tooLargeErr.Pod)
+		return
+	}
+	s.eventRecorder.Event(ref, corev1.EventTypeWarning, patchTooLargeEventReason, tooLargeErr.Error())
+}
+
 // mutationResponse returns the adequate v1.AdmissionResponse based on the mutation result.
+//
+// A mutation whose patch can't fit is handled according to the failure policy of the webhook, as if
+// the webhook had failed: with Fail the pod is rejected so that it's never created without the
+// mutation, with Ignore it's admitted without any of the operations of the patch and the client is
+// warned that the pod misses the mutation.
 func mutationResponse(jsonPatch []byte, err error) *admiv1.AdmissionResponse {
 	if err != nil {
 		log.Warnf("Failed to mutate: %v", err)
 
-		return &admiv1.AdmissionResponse{
+		var tooLargeErr *mutatecommon.PatchTooLargeError
+		if errors.As(err, &tooLargeErr) && strings.EqualFold(config.Datadog().GetString("admission_controller.failure_policy"), "fail") {
+			return &admiv1.AdmissionResponse{
+				Result: &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Message: err.Error(),
+					Reason:  patchTooLargeEventReason,
+					Code:    http.StatusRequestEntityTooLarge,
+				},
+				Allowed: false,
+			}
+		}
+
+		response := &admiv1.AdmissionResponse{
 			Result: &metav1.Status{
 				Message: err.Error(),
 			},
 			Allowed: true,
 		}
+		if tooLargeErr != nil {
+			response.Warnings = []string{"Datadog admission controller: " + tooLargeErr.Error()}
+		}
+		return response
+	}
 
+	// mutations not changing the pod don't send any patch
+	if len(jsonPatch) == 0 {
+		return &admiv1.AdmissionResponse{
+			Allowed: true,
+		}
 	}
 
 	patchType := admiv1.PatchTypeJSONPatch
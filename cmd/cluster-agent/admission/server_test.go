@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2017-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admiv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func TestRecordMutationEvent(t *testing.T) {
+	controller := true
+	tooLargeErr := fmt.Errorf("failed to mutate: %w", &mutatecommon.PatchTooLargeError{MutationType: "lib_injection", Pod: "web-", Size: 2048, Limit: 1024})
+
+	tests := []struct {
+		name  string
+		pod   corev1.Pod
+		err   error
+		event string
+	}{
+		{
+			name: "patch too large, event on the controller",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				GenerateName:    "web-",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web", Controller: &controller}},
+			}},
+			err:   tooLargeErr,
+			event: "Warning PatchTooLarge the lib_injection mutation of pod web- was not applied: its JSON patch of 2048 bytes exceeds the limit of 1024 bytes",
+		},
+		{
+			name:  "patch too large, event on the pod",
+			pod:   corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+			err:   tooLargeErr,
+			event: "Warning PatchTooLarge the lib_injection mutation of pod web- was not applied: its JSON patch of 2048 bytes exceeds the limit of 1024 bytes",
+		},
+		{
+			name: "patch too large, nothing to record the event on",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "web-"}},
+			err:  tooLargeErr,
+		},
+		{
+			name: "other error",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+			err:  errors.New("failed to mutate"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			s := &Server{eventRecorder: recorder}
+			raw, err := json.Marshal(tt.pod)
+			require.NoError(t, err)
+
+			s.recordMutationEvent(&MutateRequest{Raw: raw, Namespace: "default"}, tt.err)
+
+			select {
+			case event := <-recorder.Events:
+				assert.Equal(t, tt.event, event)
+			default:
+				assert.Empty(t, tt.event, "no event recorded")
+			}
+		})
+	}
+}
+
+func TestMutateHandlerPatchTooLarge(t *testing.T) {
+	const event = "Warning PatchTooLarge the test mutation of pod default/web was not applied"
+
+	tests := []struct {
+		name          string
+		failurePolicy string
+		maxPatchSize  int
+		allowed       bool
+		patched       bool
+		warned        bool
+		event         bool
+	}{
+		{
+			name:          "patch fits",
+			failurePolicy: "Fail",
+			maxPatchSize:  1024 * 1024,
+			allowed:       true,
+			patched:       true,
+		},
+		{
+			name:          "patch too large, failure policy Ignore",
+			failurePolicy: "Ignore",
+			maxPatchSize:  64,
+			allowed:       true,
+			warned:        true,
+			event:         true,
+		},
+		{
+			name:          "patch too large, failure policy Fail",
+			failurePolicy: "Fail",
+			maxPatchSize:  64,
+			allowed:       false,
+			event:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConfig := config.Mock(t)
+			mockConfig.SetWithoutSource("admission_controller.failure_policy", tt.failurePolicy)
+			mockConfig.SetWithoutSource("admission_controller.max_patch_size", tt.maxPatchSize)
+			recorder := record.NewFakeRecorder(1)
+			s := NewServer()
+			s.eventRecorder = recorder
+			s.Register("/inject", "test", func(request *MutateRequest) ([]byte, error) {
+				return mutatecommon.Mutate(request.Raw, request.Namespace, "test", func(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool, error) {
+					return mutatecommon.InjectEnv(pod, corev1.EnvVar{Name: "DD_ENV", Value: "prod"}), nil
+				}, request.DynamicClient)
+			}, nil, nil)
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "web", Image: "web"},
+					{Name: "sidecar", Image: "sidecar"},
+				}},
+			}
+			rawPod, err := json.Marshal(pod)
+			require.NoError(t, err)
+			review := admiv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admiv1.AdmissionRequest{
+					UID:       "uid",
+					Name:      "web",
+					Namespace: "default",
+					Object:    runtime.RawExtension{Raw: rawPod},
+				},
+			}
+			body, err := json.Marshal(review)
+			require.NoError(t, err)
+			request := httptest.NewRequest(http.MethodPost, "/inject", bytes.NewReader(body))
+			request.Header.Set("Content-Type", jsonContentType)
+			recorded := httptest.NewRecorder()
+
+			s.mux.ServeHTTP(recorded, request)
+
+			require.Equal(t, http.StatusOK, recorded.Code)
+			var response admiv1.AdmissionReview
+			require.NoError(t, json.Unmarshal(recorded.Body.Bytes(), &response))
+			require.NotNil(t, response.Response)
+			assert.Equal(t, review.Request.UID, response.Response.UID)
+			assert.Equal(t, tt.allowed, response.Response.Allowed)
+			if tt.patched {
+				assert.Contains(t, string(response.Response.Patch), "DD_ENV")
+			} else {
+				assert.Empty(t, response.Response.Patch)
+			}
+			if tt.warned {
+				require.Len(t, response.Response.Warnings, 1)
+				assert.Contains(t, response.Response.Warnings[0], "the test mutation of pod default/web was not applied")
+			} else {
+				assert.Empty(t, response.Response.Warnings)
+			}
+			if !tt.allowed {
+				require.NotNil(t, response.Response.Result)
+				assert.Equal(t, metav1.StatusReason(patchTooLargeEventReason), response.Response.Result.Reason)
+				assert.Equal(t, int32(http.StatusRequestEntityTooLarge), response.Response.Result.Code)
+			}
+			select {
+			case recordedEvent := <-recorder.Events:
+				assert.True(t, tt.event, "unexpected event %s", recordedEvent)
+				assert.Contains(t, recordedEvent, event)
+			default:
+				assert.False(t, tt.event, "no event recorded")
+			}
+		})
+	}
+}
@@ -7,16 +7,23 @@
 package daemon
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
 
 	"github.com/DataDog/datadog-agent/cmd/installer/command"
 	"github.com/DataDog/datadog-agent/comp/core"
 	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
 	"github.com/DataDog/datadog-agent/comp/core/log/logimpl"
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
 	"github.com/DataDog/datadog-agent/comp/core/sysprobeconfig/sysprobeconfigimpl"
 	"github.com/DataDog/datadog-agent/comp/updater/localapiclient"
 	"github.com/DataDog/datadog-agent/comp/updater/localapiclient/localapiclientimpl"
+	fleetdaemon "github.com/DataDog/datadog-agent/pkg/fleet/daemon"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"github.com/spf13/cobra"
 	"go.uber.org/fx"
@@ -26,35 +33,40 @@ type cliParams struct {
 	command.GlobalParams
 	pkg     string
 	version string
+	channel string
+	caseID  string
+	email   string
+	send    bool
+	dryRun  bool
 }
 
 func apiCommands(global *command.GlobalParams) []*cobra.Command {
+	installParams := &cliParams{GlobalParams: *global}
 	installCmd := &cobra.Command{
 		Use:     "install package version",
 		Aliases: []string{"install"},
 		Short:   "Installs a package to the expected version",
 		Args:    cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return experimentFxWrapper(install, &cliParams{
-				GlobalParams: *global,
-				pkg:          args[0],
-				version:      args[1],
-			})
+			installParams.pkg = args[0]
+			installParams.version = args[1]
+			return experimentFxWrapper(install, installParams)
 		},
 	}
+	installCmd.Flags().BoolVar(&installParams.dryRun, "dry-run", false, "Check the package can be installed and show what would change, without changing anything")
+	startExperimentParams := &cliParams{GlobalParams: *global}
 	startExperimentCmd := &cobra.Command{
 		Use:     "start-experiment package version",
 		Aliases: []string{"start"},
 		Short:   "Starts an experiment",
 		Args:    cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return experimentFxWrapper(start, &cliParams{
-				GlobalParams: *global,
-				pkg:          args[0],
-				version:      args[1],
-			})
+			startExperimentParams.pkg = args[0]
+			startExperimentParams.version = args[1]
+			return experimentFxWrapper(start, startExperimentParams)
 		},
 	}
+	startExperimentCmd.Flags().BoolVar(&startExperimentParams.dryRun, "dry-run", false, "Check the experiment can be started and show what would change, without changing anything")
 	stopExperimentCmd := &cobra.Command{
 		Use:     "stop-experiment package",
 		Aliases: []string{"stop"},
@@ -79,7 +91,75 @@ func apiCommands(global *command.GlobalParams) []*cobra.Command {
 			})
 		},
 	}
-	return []*cobra.Command{startExperimentCmd, stopExperimentCmd, promoteExperimentCmd, installCmd}
+	garbageCollectCmd := &cobra.Command{
+		Use:     "garbage-collect",
+		Aliases: []string{"gc"},
+		Short:   "Removes the packages no longer used, without waiting for the periodic collection of the daemon",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return experimentFxWrapper(garbageCollect, &cliParams{
+				GlobalParams: *global,
+			})
+		},
+	}
+	setChannelCmd := &cobra.Command{
+		Use:   "set-channel channel",
+		Short: "Subscribes the host to a release channel of the catalog: stable, beta or nightly",
+		Long: `Subscribes the host to a release channel of the catalog. The host gets the packages of its channel and
+of the more stable ones, e.g. a host of the beta channel gets the beta and stable packages.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return experimentFxWrapper(setChannel, &cliParams{
+				GlobalParams: *global,
+				channel:      args[0],
+			})
+		},
+	}
+	catalogCmd := &cobra.Command{
+		Use:   "catalog [command]",
+		Short: "Inspects the catalog of the daemon",
+	}
+	catalogListCmd := &cobra.Command{
+		Use:   "list [package]",
+		Short: "Lists the packages of the catalog available for this host",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := &cliParams{GlobalParams: *global}
+			if len(args) > 0 {
+				params.pkg = args[0]
+			}
+			return experimentFxWrapper(catalogList, params)
+		},
+	}
+	catalogShowCmd := &cobra.Command{
+		Use:   "show package",
+		Short: "Shows the versions of a package of the catalog available for this host",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return experimentFxWrapper(catalogShow, &cliParams{
+				GlobalParams: *global,
+				pkg:          args[0],
+			})
+		},
+	}
+	catalogCmd.AddCommand(catalogListCmd, catalogShowCmd)
+	flareParams := &cliParams{GlobalParams: *global}
+	flareCmd := &cobra.Command{
+		Use:   "flare [caseID]",
+		Short: "Collects a fleet flare with the daemon logs, state and task history",
+		Long: `Collects a fleet flare with the daemon logs, the state of the package repositories, the recent task history,
+the APM injection status and the redacted environment. With --send, it is sent to the given support case, next to the agent flare.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				flareParams.caseID = args[0]
+			}
+			return experimentFxWrapper(flare, flareParams)
+		},
+	}
+	flareCmd.Flags().StringVarP(&flareParams.email, "email", "e", "", "Your email")
+	flareCmd.Flags().BoolVarP(&flareParams.send, "send", "s", false, "Send the flare to Datadog support instead of keeping it locally")
+	return []*cobra.Command{setChannelCmd, catalogCmd, startExperimentCmd, stopExperimentCmd, promoteExperimentCmd, installCmd, garbageCollectCmd, flareCmd}
 }
 
 func experimentFxWrapper(f interface{}, params *cliParams) error {
@@ -96,7 +176,104 @@ func experimentFxWrapper(f interface{}, params *cliParams) error {
 	)
 }
 
+func setChannel(params *cliParams, client localapiclient.Component) error {
+	err := client.SetChannel(params.channel)
+	if err != nil {
+		fmt.Println("Error setting channel:", err)
+		return err
+	}
+	return nil
+}
+
+// catalogPackages returns the packages of the catalog of the daemon available for this host,
+// restricted to a package if set
+func catalogPackages(client localapiclient.Component, pkg string) ([]fleetdaemon.CatalogPackage, error) {
+	packages, err := client.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	if pkg == "" {
+		return packages, nil
+	}
+	var filtered []fleetdaemon.CatalogPackage
+	for _, p := range packages {
+		if p.Name == pkg {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+func catalogPackageState(p fleetdaemon.CatalogPackage) string {
+	switch {
+	case p.Stable:
+		return "stable"
+	case p.Experiment:
+		return "experiment"
+	default:
+		return "-"
+	}
+}
+
+func catalogList(params *cliParams, client localapiclient.Component) error {
+	packages, err := catalogPackages(client, params.pkg)
+	if err != nil {
+		fmt.Println("Error getting catalog:", err)
+		return err
+	}
+	if len(packages) == 0 {
+		fmt.Printf("No package of the catalog is available for %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tSTATE")
+	for _, p := range packages {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Version, catalogPackageState(p))
+	}
+	return w.Flush()
+}
+
+func catalogShow(params *cliParams, client localapiclient.Component) error {
+	packages, err := catalogPackages(client, params.pkg)
+	if err != nil {
+		fmt.Println("Error getting catalog:", err)
+		return err
+	}
+	if len(packages) == 0 {
+		err := fmt.Errorf("no version of package %s is available for %s/%s in the catalog", params.pkg, runtime.GOOS, runtime.GOARCH)
+		fmt.Println("Error getting catalog:", err)
+		return err
+	}
+	for _, p := range packages {
+		fmt.Printf("%s %s (%s)\n", p.Name, p.Version, catalogPackageState(p))
+		fmt.Printf("  URL: %s\n", p.URL)
+		if p.SHA256 != "" {
+			fmt.Printf("  SHA256: %s\n", p.SHA256)
+		}
+		if p.Size != 0 {
+			fmt.Printf("  Size: %d\n", p.Size)
+		}
+		if p.Rollout != nil {
+			rollout, err := json.Marshal(p.Rollout)
+			if err != nil {
+				return fmt.Errorf("could not marshal rollout: %w", err)
+			}
+			fmt.Printf("  Rollout: %s\n", rollout)
+		}
+	}
+	return nil
+}
+
 func start(params *cliParams, client localapiclient.Component) error {
+	if params.dryRun {
+		report, err := client.StartExperimentDryRun(params.pkg, params.version)
+		if err != nil {
+			fmt.Println("Error checking experiment:", err)
+			return err
+		}
+		printDryRunReport(report)
+		return nil
+	}
 	err := client.StartExperiment(params.pkg, params.version)
 	if err != nil {
 		fmt.Println("Error starting experiment:", err)
@@ -124,6 +301,15 @@ func promote(params *cliParams, client localapiclient.Component) error {
 }
 
 func install(params *cliParams, client localapiclient.Component) error {
+	if params.dryRun {
+		report, err := client.InstallDryRun(params.pkg, params.version)
+		if err != nil {
+			fmt.Println("Error checking install:", err)
+			return err
+		}
+		printDryRunReport(report)
+		return nil
+	}
 	err := client.Install(params.pkg, params.version)
 	if err != nil {
 		fmt.Println("Error bootstrapping package:", err)
@@ -131,3 +317,51 @@ func install(params *cliParams, client localapiclient.Component) error {
 	}
 	return nil
 }
+
+func printDryRunReport(report *installer.DryRunReport) {
+	fmt.Printf("%s %s (%s, %s)\n", report.Package, report.Version, report.Platform, report.Digest)
+	if report.Stable != "" {
+		fmt.Printf("  Stable: %s\n", report.Stable)
+	}
+	if report.Experiment != "" {
+		fmt.Printf("  Experiment: %s\n", report.Experiment)
+	}
+	fmt.Printf("  Disk space: %d bytes required, %d bytes available\n", report.RequiredDiskSpace, report.AvailableDiskSpace)
+	if len(report.Changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	fmt.Println("Changes:")
+	for _, change := range report.Changes {
+		fmt.Printf("  - %s\n", change)
+	}
+}
+
+func garbageCollect(_ *cliParams, client localapiclient.Component) error {
+	err := client.GarbageCollect()
+	if err != nil {
+		fmt.Println("Error running garbage collection:", err)
+		return err
+	}
+	return nil
+}
+
+func flare(params *cliParams, client localapiclient.Component, config config.Component) error {
+	path, err := client.Flare()
+	if err != nil {
+		fmt.Println("Error collecting fleet flare:", err)
+		return err
+	}
+	if !params.send {
+		fmt.Println("Fleet flare written to", path)
+		return nil
+	}
+	defer os.Remove(path)
+	response, err := helpers.SendTo(config, path, params.caseID, params.email, config.GetString("api_key"), helpers.GetFlareEndpoint(config), helpers.NewLocalFlareSource())
+	if err != nil {
+		fmt.Println("Error sending fleet flare:", err)
+		return err
+	}
+	fmt.Println(response)
+	return nil
+}
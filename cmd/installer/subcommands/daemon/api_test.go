@@ -8,10 +8,42 @@ package daemon
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/DataDog/datadog-agent/cmd/installer/command"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
 
+func TestSetChannelCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"set-channel", "beta"},
+		setChannel,
+		func(params *cliParams) {
+			require.Equal(t, "beta", params.channel)
+		})
+}
+
+func TestCatalogListCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"catalog", "list", "test"},
+		catalogList,
+		func(params *cliParams) {
+			require.Equal(t, "test", params.pkg)
+		})
+}
+
+func TestCatalogShowCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"catalog", "show", "test"},
+		catalogShow,
+		func(params *cliParams) {
+			require.Equal(t, "test", params.pkg)
+		})
+}
+
 func TestInstallCommand(t *testing.T) {
 	fxutil.TestOneShotSubcommand(t,
 		apiCommands(&command.GlobalParams{}),
@@ -20,6 +52,18 @@ func TestInstallCommand(t *testing.T) {
 		func() {})
 }
 
+func TestInstallDryRunCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"install", "--dry-run", "test", "v1"},
+		install,
+		func(params *cliParams) {
+			require.Equal(t, "test", params.pkg)
+			require.Equal(t, "v1", params.version)
+			require.True(t, params.dryRun)
+		})
+}
+
 func TestStartExperimentCommand(t *testing.T) {
 	fxutil.TestOneShotSubcommand(t,
 		apiCommands(&command.GlobalParams{}),
@@ -28,6 +72,18 @@ func TestStartExperimentCommand(t *testing.T) {
 		func() {})
 }
 
+func TestStartExperimentDryRunCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"start-experiment", "--dry-run", "test", "v1"},
+		start,
+		func(params *cliParams) {
+			require.Equal(t, "test", params.pkg)
+			require.Equal(t, "v1", params.version)
+			require.True(t, params.dryRun)
+		})
+}
+
 func TestStopExperimentCommand(t *testing.T) {
 	fxutil.TestOneShotSubcommand(t,
 		apiCommands(&command.GlobalParams{}),
@@ -43,3 +99,22 @@ func TestPromoteExperimentCommand(t *testing.T) {
 		promote,
 		func() {})
 }
+
+func TestGarbageCollectCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"garbage-collect"},
+		garbageCollect,
+		func() {})
+}
+
+func TestFlareCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		apiCommands(&command.GlobalParams{}),
+		[]string{"flare", "12345", "--send"},
+		flare,
+		func(params *cliParams) {
+			require.Equal(t, "12345", params.caseID)
+			require.True(t, params.send)
+		})
+}
@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/cmd/installer/command"
+	"github.com/DataDog/datadog-agent/comp/core"
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/log/logimpl"
+	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	"github.com/DataDog/datadog-agent/comp/core/sysprobeconfig/sysprobeconfigimpl"
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/helper"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// helperStopTimeout is the time left to the running commands to be interrupted when the helper stops
+const helperStopTimeout = time.Minute
+
+type helperParams struct {
+	command.GlobalParams
+	socket          string
+	allowedUser     string
+	allowedCommands []string
+}
+
+// HelperCommands returns the privileged helper command, it must run as root
+func HelperCommands(global *command.GlobalParams) []*cobra.Command {
+	params := &helperParams{}
+	helperCmd := &cobra.Command{
+		Use:     "privileged-helper",
+		Short:   "Runs the installer commands as root on behalf of a daemon running as non-root",
+		GroupID: "daemon",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			params.GlobalParams = *global
+			return helperFxWrapper(params)
+		},
+	}
+	helperCmd.Flags().StringVar(&params.socket, "socket", helper.DefaultSocketPath, "path of the socket the daemon connects to")
+	helperCmd.Flags().StringVar(&params.allowedUser, "allowed-user", helper.DefaultAllowedUser, "user allowed to run commands, besides root")
+	helperCmd.Flags().StringSliceVar(&params.allowedCommands, "allow", helper.DefaultAllowedCommands, "installer commands the daemon is allowed to run")
+	return []*cobra.Command{helperCmd}
+}
+
+func helperFxWrapper(params *helperParams) error {
+	return fxutil.OneShot(runHelper,
+		fx.Supply(core.BundleParams{
+			ConfigParams:         config.NewAgentParams(params.ConfFilePath),
+			SecretParams:         secrets.NewEnabledParams(),
+			SysprobeConfigParams: sysprobeconfigimpl.NewParams(),
+			LogParams:            logimpl.ForOneShot("INSTALLER", "info", true),
+		}),
+		core.Bundle(),
+		fx.Supply(params),
+	)
+}
+
+func runHelper(params *helperParams, config config.Component) error {
+	// the helper only runs the stable installer with its own configuration, never a binary, a package
+	// or a configuration chosen by the daemon
+	allowedCommands, err := helper.AllowedCommands(params.allowedCommands, config.GetStringSlice("installer.privileged_helper.opt_in_commands"))
+	if err != nil {
+		return fmt.Errorf("could not start privileged helper: %w", err)
+	}
+	server, err := helper.NewServer(params.socket, helper.DefaultInstallerPath, env.FromConfig(config), allowedCommands, params.allowedUser)
+	if err != nil {
+		return fmt.Errorf("could not start privileged helper: %w", err)
+	}
+	server.Start()
+	log.Infof("Helper: listening on %s for user %s, allowed commands: %v", params.socket, params.allowedUser, allowedCommands)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Infof("Helper: stopping")
+	stopCtx, cancel := context.WithTimeout(context.Background(), helperStopTimeout)
+	defer cancel()
+	return server.Stop(stopCtx)
+}
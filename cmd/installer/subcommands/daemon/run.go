@@ -22,6 +22,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/core/pid/pidimpl"
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
 	"github.com/DataDog/datadog-agent/comp/core/sysprobeconfig/sysprobeconfigimpl"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/statsd"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice/rcserviceimpl"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rctelemetryreporter/rctelemetryreporterimpl"
@@ -70,6 +71,7 @@ func runFxWrapper(global *command.GlobalParams) error {
 		}),
 		rctelemetryreporterimpl.Module(),
 		rcserviceimpl.Module(),
+		statsd.Module(),
 		updaterimpl.Module(),
 		localapiimpl.Module(),
 		telemetryimpl.Module(),
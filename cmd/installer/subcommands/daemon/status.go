@@ -7,6 +7,7 @@ package daemon
 
 import (
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"os"
@@ -25,28 +26,38 @@ import (
 	"go.uber.org/fx"
 )
 
+type statusParams struct {
+	command.GlobalParams
+	// json prints the raw status for host automation
+	json bool
+}
+
 func statusCommand(global *command.GlobalParams) *cobra.Command {
+	params := &statusParams{}
 	statusCmd := &cobra.Command{
 		Use:     "status",
 		Short:   "Print the installer status",
 		GroupID: "daemon",
 		Long:    ``,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return statusFxWrapper(global)
+			params.GlobalParams = *global
+			return statusFxWrapper(params)
 		},
 	}
+	statusCmd.Flags().BoolVarP(&params.json, "json", "j", false, "Print the status as JSON")
 	return statusCmd
 }
 
-func statusFxWrapper(global *command.GlobalParams) error {
+func statusFxWrapper(params *statusParams) error {
 	return fxutil.OneShot(status,
 		fx.Supply(core.BundleParams{
-			ConfigParams:         config.NewAgentParams(global.ConfFilePath),
+			ConfigParams:         config.NewAgentParams(params.ConfFilePath),
 			SecretParams:         secrets.NewEnabledParams(),
 			SysprobeConfigParams: sysprobeconfigimpl.NewParams(),
 			LogParams:            logimpl.ForOneShot("INSTALLER", "off", true),
 		}),
 		core.Bundle(),
+		fx.Supply(params),
 		localapiclientimpl.Module(),
 	)
 }
@@ -65,7 +76,16 @@ var functions = template.FuncMap{
 	},
 }
 
-func status(client localapiclient.Component) error {
+func status(params *statusParams, client localapiclient.Component) error {
+	if params.json {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("error getting status: %w", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
 	tmpl, err := template.New("status").Funcs(functions).Parse(string(statusTmpl))
 	if err != nil {
 		return fmt.Errorf("error parsing status template: %w", err)
@@ -11,6 +11,7 @@ import (
 	"github.com/DataDog/datadog-agent/cmd/installer/command"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStatusCommand(t *testing.T) {
@@ -22,3 +23,15 @@ func TestStatusCommand(t *testing.T) {
 		status,
 		func() {})
 }
+
+func TestStatusCommandJSON(t *testing.T) {
+	cmd := statusCommand(&command.GlobalParams{})
+	cmd.GroupID = ""
+	fxutil.TestOneShotSubcommand(t,
+		[]*cobra.Command{cmd},
+		[]string{"status", "--json"},
+		status,
+		func(params *statusParams) {
+			require.True(t, params.json)
+		})
+}
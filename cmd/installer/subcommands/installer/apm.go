@@ -6,6 +6,10 @@
 package installer
 
 import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
 	"github.com/spf13/cobra"
 )
 
@@ -20,11 +24,15 @@ func apmCommands() *cobra.Command {
 }
 
 func apmInstrumentCommand() *cobra.Command {
+	var dryRun bool
 	cmd := &cobra.Command{
 		Use:   "instrument [all|host|docker]",
 		Short: "Instrument APM auto-injection for a host or docker. Defaults to both.",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(_ *cobra.Command, args []string) (err error) {
+			if dryRun {
+				return apmDockerDryRun("apm_instrument_dry_run", args, false)
+			}
 			i, err := newInstallerCmd("apm_instrument")
 			if err != nil {
 				return err
@@ -37,15 +45,20 @@ func apmInstrumentCommand() *cobra.Command {
 			return i.InstrumentAPMInjector(i.ctx, args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the modification of the docker daemon configuration without applying it")
 	return cmd
 }
 
 func apmUninstrumentCommand() *cobra.Command {
+	var dryRun bool
 	cmd := &cobra.Command{
 		Use:   "uninstrument [all|host|docker]",
 		Short: "Uninstrument APM auto-injection for a host or docker. Defaults to both.",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(_ *cobra.Command, args []string) (err error) {
+			if dryRun {
+				return apmDockerDryRun("apm_uninstrument_dry_run", args, true)
+			}
 			i, err := newInstallerCmd("apm_uninstrument")
 			if err != nil {
 				return err
@@ -58,5 +71,26 @@ func apmUninstrumentCommand() *cobra.Command {
 			return i.UninstrumentAPMInjector(i.ctx, args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the modification of the docker daemon configuration without applying it")
 	return cmd
 }
+
+// apmDockerDryRun prints the docker daemon configuration before and after the
+// (un)instrumentation so it can be reviewed before being applied.
+func apmDockerDryRun(operation string, args []string, uninstrument bool) (err error) {
+	if len(args) > 0 && args[0] != "docker" {
+		return fmt.Errorf("dry run is only supported for docker")
+	}
+	c := newCmd(operation)
+	defer func() { c.Stop(err) }()
+	diff, err := service.DiffAPMInjectorDocker(c.ctx, uninstrument)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
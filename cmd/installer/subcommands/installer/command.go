@@ -8,7 +8,9 @@ package installer
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -17,6 +19,8 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/fleet/bootstraper"
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
 	"github.com/DataDog/datadog-agent/pkg/fleet/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/version"
 	"github.com/spf13/cobra"
@@ -53,10 +57,18 @@ func Commands(_ *command.GlobalParams) []*cobra.Command {
 		installExperimentCommand(),
 		removeExperimentCommand(),
 		promoteExperimentCommand(),
+		rollbackCommand(),
+		exportCommand(),
+		importCommand(),
 		garbageCollectCommand(),
+		checkPermissionsCommand(),
 		purgeCommand(),
 		isInstalledCommand(),
 		apmCommands(),
+		rotateAPIKeyCommand(),
+		rebootCommand(),
+		setIntegrationConfigsCommand(),
+		rollbackIntegrationConfigsCommand(),
 	}
 }
 
@@ -86,6 +98,10 @@ func newCmd(operation string) *cmd {
 
 func (c *cmd) Stop(err error) {
 	c.span.Finish(tracer.WithError(err))
+	if err != nil {
+		// the daemon running the command reads the error and its code back from stderr
+		fmt.Fprintln(os.Stderr, installerErrors.ToJSON(err))
+	}
 	if c.t != nil {
 		err := c.t.Stop(context.Background())
 		if err != nil {
@@ -214,6 +230,7 @@ func bootstrapCommand() *cobra.Command {
 
 func installCommand() *cobra.Command {
 	var installArgs []string
+	var dryRun bool
 	cmd := &cobra.Command{
 		Use:     "install <url>",
 		Short:   "Install a package",
@@ -226,13 +243,26 @@ func installCommand() *cobra.Command {
 			}
 			defer func() { i.Stop(err) }()
 			i.span.SetTag("params.url", args[0])
+			i.span.SetTag("params.dry_run", dryRun)
+			if dryRun {
+				return printDryRunReport(i.InstallDryRun(i.ctx, args[0]))
+			}
 			return i.Install(i.ctx, args[0], installArgs)
 		},
 	}
 	cmd.Flags().StringArrayVarP(&installArgs, "install_args", "A", nil, "Arguments to pass to the package")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check the package can be installed and print what would change, without changing anything")
 	return cmd
 }
 
+// printDryRunReport prints the report of a dry run on stdout, where the daemon reads it back
+func printDryRunReport(report *installer.DryRunReport, err error) error {
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
+
 func removeCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "remove <package>",
@@ -272,6 +302,8 @@ func purgeCommand() *cobra.Command {
 }
 
 func installExperimentCommand() *cobra.Command {
+	var dryRun bool
+	var deltaFrom string
 	cmd := &cobra.Command{
 		Use:     "install-experiment <url>",
 		Short:   "Install an experiment",
@@ -284,9 +316,20 @@ func installExperimentCommand() *cobra.Command {
 			}
 			defer func() { i.Stop(err) }()
 			i.span.SetTag("params.url", args[0])
-			return i.InstallExperiment(i.ctx, args[0])
+			i.span.SetTag("params.dry_run", dryRun)
+			i.span.SetTag("params.delta_from", deltaFrom)
+			if dryRun {
+				return printDryRunReport(i.InstallExperimentDryRun(i.ctx, args[0]))
+			}
+			ctx := i.ctx
+			if deltaFrom != "" {
+				ctx = installer.WithDeltaBase(ctx, deltaFrom)
+			}
+			return i.InstallExperiment(ctx, args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Check the experiment can be installed and print what would change, without changing anything")
+	cmd.Flags().StringVar(&deltaFrom, "delta-from", "", "URL of the installed package the experiment updates from, to reuse its cached layers")
 	return cmd
 }
 
@@ -328,6 +371,67 @@ func promoteExperimentCommand() *cobra.Command {
 	return cmd
 }
 
+func rollbackCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rollback <package>",
+		Short:   "Rollback a package to the version that was stable before the last promotion",
+		GroupID: "installer",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			i, err := newInstallerCmd("rollback")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			i.span.SetTag("params.package", args[0])
+			return i.Rollback(i.ctx, args[0])
+		},
+	}
+	return cmd
+}
+
+func exportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "export <package> <version> <path>",
+		Short:   "Export an installed package to an archive, to install it on hosts without access to the registry",
+		GroupID: "installer",
+		Args:    cobra.ExactArgs(3),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			i, err := newInstallerCmd("export")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			i.span.SetTag("params.package", args[0])
+			i.span.SetTag("params.version", args[1])
+			i.span.SetTag("params.path", args[2])
+			return i.Export(i.ctx, args[0], args[1], args[2])
+		},
+	}
+	return cmd
+}
+
+func importCommand() *cobra.Command {
+	var installArgs []string
+	cmd := &cobra.Command{
+		Use:     "import <path>",
+		Short:   "Install a package from an archive created by the export command",
+		GroupID: "installer",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			i, err := newInstallerCmd("import")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			i.span.SetTag("params.path", args[0])
+			return i.ImportArchive(i.ctx, args[0], installArgs)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&installArgs, "install_args", "A", nil, "Arguments to pass to the package")
+	return cmd
+}
+
 func garbageCollectCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "garbage-collect",
@@ -346,6 +450,119 @@ func garbageCollectCommand() *cobra.Command {
 	return cmd
 }
 
+func checkPermissionsCommand() *cobra.Command {
+	var repair bool
+	cmd := &cobra.Command{
+		Use:     "check-permissions",
+		Short:   "Check the owners and modes of the files of the packages and print the issues found",
+		GroupID: "installer",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			i, err := newInstallerCmd("check_permissions")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			i.span.SetTag("params.repair", repair)
+			if repair {
+				return printPermissionIssues(i.RepairPermissions(i.ctx))
+			}
+			return printPermissionIssues(i.CheckPermissions(i.ctx))
+		},
+	}
+	cmd.Flags().BoolVar(&repair, "repair", false, "Restore the expected owners and modes of the files")
+	return cmd
+}
+
+func printPermissionIssues(issues []repository.PermissionIssue, err error) error {
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(issues)
+}
+
+func rotateAPIKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rotate-api-key",
+		Short:   "Replace the agent API key with the one read from stdin and restart the agent",
+		GroupID: "installer",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			i, err := newInstallerCmd("rotate_api_key")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			apiKey, err := io.ReadAll(io.LimitReader(os.Stdin, 1024))
+			if err != nil {
+				return fmt.Errorf("could not read API key from stdin: %w", err)
+			}
+			return i.RotateAPIKey(i.ctx, strings.TrimSpace(string(apiKey)))
+		},
+	}
+	return cmd
+}
+
+func setIntegrationConfigsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "set-integration-configs",
+		Short:   "Set the integration configurations read from stdin as a new version of the conf.d overlay and restart the agent",
+		GroupID: "installer",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			i, err := newInstallerCmd("set_integration_configs")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			var configs installer.IntegrationConfigs
+			err = json.NewDecoder(os.Stdin).Decode(&configs)
+			if err != nil {
+				return fmt.Errorf("could not read integration configurations from stdin: %w", err)
+			}
+			i.span.SetTag("params.version", configs.Version)
+			return i.SetIntegrationConfigs(i.ctx, configs.Version, configs.Files)
+		},
+	}
+	return cmd
+}
+
+func rollbackIntegrationConfigsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rollback-integration-configs",
+		Short:   "Restore the previous version of the conf.d overlay and restart the agent",
+		GroupID: "installer",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			i, err := newInstallerCmd("rollback_integration_configs")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			return i.RollbackIntegrationConfigs(i.ctx)
+		},
+	}
+	return cmd
+}
+
+func rebootCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "reboot",
+		Short:   "Reboot the host so that the packages requiring it are fully applied",
+		GroupID: "installer",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			i, err := newInstallerCmd("reboot")
+			if err != nil {
+				return err
+			}
+			defer func() { i.Stop(err) }()
+			return i.Reboot(i.ctx)
+		},
+	}
+	return cmd
+}
+
 const (
 	// ReturnCodeIsInstalledFalse is the return code when a package is not installed
 	ReturnCodeIsInstalledFalse = 10
@@ -21,6 +21,7 @@ import (
 func InstallerSubcommands() []command.SubcommandFactory {
 	return []command.SubcommandFactory{
 		withDatadogAgent(daemon.Commands),
+		withRoot(daemon.HelperCommands),
 		withRoot(installer.Commands),
 		installer.UnprivilegedCommands,
 	}
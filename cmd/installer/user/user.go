@@ -11,6 +11,7 @@ package user
 import (
 	"fmt"
 	"os/user"
+	"runtime"
 	"strconv"
 	"syscall"
 )
@@ -23,7 +24,12 @@ func IsRoot() bool {
 // RootToDatadogAgent changes the user of the process to the Datadog Agent user from root.
 // Note that we actually only set dd-agent as the effective user, not the real user, in oder to
 // escalate privileges back when needed.
+//
+// On macOS the agent runs as root as there is no dd-agent user, so this is a noop.
 func RootToDatadogAgent() error {
+	if runtime.GOOS == "darwin" {
+		return nil
+	}
 	datadogAgentGroup, err := user.LookupGroup("dd-agent")
 	if err != nil {
 		return fmt.Errorf("failed to lookup dd-agent group: %s", err)
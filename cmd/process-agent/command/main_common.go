@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 
 	"go.uber.org/fx"
 
@@ -51,6 +52,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/process/types"
 	remoteconfig "github.com/DataDog/datadog-agent/comp/remote-config"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rcclient"
+	apiutil "github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/collector/python"
 	ddconfig "github.com/DataDog/datadog-agent/pkg/config"
 	commonsettings "github.com/DataDog/datadog-agent/pkg/config/settings"
@@ -326,6 +328,10 @@ func initMisc(deps miscDeps) error {
 	appCtx, stopApp := context.WithCancel(context.Background())
 	deps.Lc.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
+			// the requests to the core agent API are authenticated with a client certificate of the process-agent
+			if ipcAddress, err := ddconfig.GetIPCAddress(); err == nil {
+				apiutil.StartClientCertificateRotation(appCtx, deps.Config, net.JoinHostPort(ipcAddress, ddconfig.GetIPCPort()), "process-agent")
+			}
 
 			if collector.Enabled(deps.Config) {
 				err := processCollectionServer.Start(appCtx, deps.WorkloadMeta)
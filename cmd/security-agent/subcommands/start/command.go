@@ -11,6 +11,7 @@ import (
 	"errors"
 	_ "expvar" // Blank import used because this isn't directly used in this file
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof" // Blank import used because this isn't directly used in this file
 	"os"
@@ -54,6 +55,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/dogstatsd"
 	"github.com/DataDog/datadog-agent/comp/dogstatsd/statsd"
 	"github.com/DataDog/datadog-agent/comp/metadata/host/hostimpl"
+	apiutil "github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/collector/python"
 	pkgCompliance "github.com/DataDog/datadog-agent/pkg/compliance"
 	pkgconfig "github.com/DataDog/datadog-agent/pkg/config"
@@ -252,6 +254,8 @@ var (
 	stopper      startstop.Stopper
 	srv          *api.Server
 	expvarServer *http.Server
+	// stopClientCertificateRotation stops the renewal of the client certificate of the security-agent
+	stopClientCertificateRotation context.CancelFunc
 )
 
 var errAllComponentsDisabled = errors.New("all security-agent component are disabled")
@@ -314,6 +318,13 @@ func RunAgent(log log.Component, config config.Component, telemetry telemetry.Co
 		return log.Errorf("Error while setuping internal profiling, exiting: %v", err)
 	}
 
+	// the requests to the core agent API are authenticated with a client certificate of the security-agent
+	if ipcAddress, err := pkgconfig.GetIPCAddress(); err == nil {
+		var ctx context.Context
+		ctx, stopClientCertificateRotation = context.WithCancel(context.Background())
+		apiutil.StartClientCertificateRotation(ctx, config, net.JoinHostPort(ipcAddress, pkgconfig.GetIPCPort()), "security-agent")
+	}
+
 	log.Infof("Datadog Security Agent is now running.")
 
 	return
@@ -330,6 +341,10 @@ func StopAgent(log log.Component) {
 		log.Warnf("Some components were unhealthy: %v", healthStatus.Unhealthy)
 	}
 
+	if stopClientCertificateRotation != nil {
+		stopClientCertificateRotation()
+	}
+
 	// stop metaScheduler and statsd if they are instantiated
 	if stopper != nil {
 		stopper.Stop()
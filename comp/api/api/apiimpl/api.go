@@ -51,7 +51,7 @@ type apiServer struct {
 	wmeta             workloadmeta.Component
 	collector         optional.Option[collector.Component]
 	senderManager     diagnosesendermanager.Component
-	endpointProviders []api.EndpointProvider
+	endpointProviders *endpointRegistry
 }
 
 type dependencies struct {
@@ -92,7 +92,7 @@ func newAPIServer(deps dependencies) api.Component {
 		wmeta:             deps.WorkloadMeta,
 		collector:         deps.Collector,
 		senderManager:     deps.DiagnoseSenderManager,
-		endpointProviders: fxutil.GetAndFilterGroup(deps.EndpointProviders),
+		endpointProviders: newEndpointRegistry(fxutil.GetAndFilterGroup(deps.EndpointProviders)),
 	}
 }
 
@@ -126,3 +126,19 @@ func (server *apiServer) StopServer() {
 func (server *apiServer) ServerAddress() *net.TCPAddr {
 	return ServerAddress()
 }
+
+// RegisterEndpointProvider mounts a new endpoint on the agent router.
+// It can be called before or after the server is started.
+func (server *apiServer) RegisterEndpointProvider(provider api.EndpointProvider) error {
+	return server.endpointProviders.register(provider)
+}
+
+// UnregisterEndpointProvider unmounts an endpoint from the agent router.
+func (server *apiServer) UnregisterEndpointProvider(provider api.EndpointProvider) error {
+	return server.endpointProviders.unregister(provider)
+}
+
+// EndpointProviders returns the endpoint providers currently mounted on the agent router.
+func (server *apiServer) EndpointProviders() []api.EndpointProvider {
+	return server.endpointProviders.list()
+}
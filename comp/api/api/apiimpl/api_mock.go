@@ -45,3 +45,18 @@ func (mock *mockAPIServer) StopServer() {
 func (mock *mockAPIServer) ServerAddress() *net.TCPAddr {
 	return nil
 }
+
+// RegisterEndpointProvider noop
+func (mock *mockAPIServer) RegisterEndpointProvider(_ api.EndpointProvider) error {
+	return nil
+}
+
+// UnregisterEndpointProvider noop
+func (mock *mockAPIServer) UnregisterEndpointProvider(_ api.EndpointProvider) error {
+	return nil
+}
+
+// EndpointProviders returns nil
+func (mock *mockAPIServer) EndpointProviders() []api.EndpointProvider {
+	return nil
+}
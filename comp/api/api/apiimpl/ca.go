@@ -0,0 +1,182 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// certificateRoute is the route of the /agent router issuing client certificates, see util.ClientCertificateRoute
+	certificateRoute = "/certificate"
+
+	clientCertificateTTL = time.Hour
+	maxCSRSize           = 16 << 10
+)
+
+// satelliteIdentities are the identities client certificates can be issued for
+var satelliteIdentities = []string{"trace-agent", "process-agent", "security-agent"}
+
+// clientCA is an in-memory certificate authority issuing short-lived client certificates
+// to the satellite agent processes. A process bootstraps with the auth token to get a
+// certificate, which then identifies it to the API server.
+//
+// The authority lives as long as the API server: certificates issued by a previous run
+// of the agent aren't trusted anymore and must be requested again.
+type clientCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newClientCA() (*clientCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate CA key: %v", err)
+	}
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().Add(-time.Minute)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		// the serial number tells the authorities of the runs of the agent apart, for the clients
+		// to present a certificate only if it's issued by the current one
+		Subject:               pkix.Name{Organization: []string{"Datadog, Inc."}, CommonName: "Datadog Agent client CA", SerialNumber: serialNumber.String()},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &clientCA{
+		cert: cert,
+		key:  key,
+		pool: pool,
+	}, nil
+}
+
+// parseCSR parses and checks the PEM encoded certificate request of a client
+func parseCSR(csrPEM []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("invalid certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate request signature: %v", err)
+	}
+	identity := csr.Subject.CommonName
+	if !slices.Contains(satelliteIdentities, identity) {
+		return nil, fmt.Errorf("unknown identity %q, expected one of %s", identity, strings.Join(satelliteIdentities, ", "))
+	}
+	return csr, nil
+}
+
+// issue signs the certificate request and returns the PEM encoded client certificate.
+// The identity of the client is the common name of the request.
+func (ca *clientCA) issue(csr *x509.CertificateRequest) ([]byte, error) {
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().Add(-time.Minute)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{Organization: []string{"Datadog, Inc."}, CommonName: csr.Subject.CommonName},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(clientCertificateTTL),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), nil
+}
+
+// issueCertificate is the handler of the certificate route. Processes already holding
+// a certificate can renew it, but only for their own identity.
+func (ca *clientCA) issueCertificate(w http.ResponseWriter, r *http.Request) {
+	csrPEM, err := io.ReadAll(io.LimitReader(r.Body, maxCSRSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		log.Warnf("refused to issue client certificate: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if identity, ok := clientIdentity(r); ok && identity != csr.Subject.CommonName {
+		log.Warnf("refused to issue client certificate: %s requested a certificate for %s", identity, csr.Subject.CommonName)
+		http.Error(w, fmt.Sprintf("%s can't request a certificate for another identity", identity), http.StatusForbidden)
+		return
+	}
+	certPEM, err := ca.issue(csr)
+	if err != nil {
+		log.Errorf("unable to issue client certificate: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("issued a client certificate for %s", csr.Subject.CommonName)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(util.ClientCertificate{
+		Certificate: string(certPEM),
+		CA:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})),
+	})
+}
+
+// clientIdentity returns the identity of the client if it presented a certificate issued
+// by the client CA. The TLS handshake only verifies client certificates against the client CA.
+func clientIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serialNumber, nil
+}
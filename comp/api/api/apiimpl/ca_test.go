@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func newTestCAServer(t *testing.T) *httptest.Server {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("auth_token_file_path", filepath.Join(t.TempDir(), "auth_token"))
+	require.NoError(t, util.CreateAndSetAuthToken(mockConfig))
+
+	ca, err := newClientCA()
+	require.NoError(t, err)
+	agentMux := gorilla.NewRouter()
+	agentMux.Use(validateToken)
+	agentMux.HandleFunc(certificateRoute, ca.issueCertificate).Methods("POST")
+	agentMux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux := http.NewServeMux()
+	mux.Handle("/agent/", http.StripPrefix("/agent", agentMux))
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  ca.pool,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// clientWithCertificate returns a client trusting the test server and presenting the given certificate
+func clientWithCertificate(srv *httptest.Server, cert *tls.Certificate) *http.Client {
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	return &http.Client{Transport: transport}
+}
+
+func TestClientCertificate(t *testing.T) {
+	srv := newTestCAServer(t)
+	addr := srv.Listener.Addr().String()
+
+	cert, err := util.RequestClientCertificate(context.Background(), srv.Client(), addr, "trace-agent")
+	require.NoError(t, err)
+	assert.Equal(t, "trace-agent", cert.Leaf.Subject.CommonName)
+	assert.WithinDuration(t, time.Now().Add(clientCertificateTTL), cert.Leaf.NotAfter, 2*time.Minute)
+
+	// Requests without the certificate or the token are rejected
+	resp, err := srv.Client().Get(srv.URL + "/agent/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The certificate authenticates the process in place of the token
+	client := clientWithCertificate(srv, cert)
+	resp, err = client.Get(srv.URL + "/agent/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The certificate can be renewed for the same identity only
+	renewed, err := util.RequestClientCertificate(context.Background(), client, addr, "trace-agent")
+	require.NoError(t, err)
+	assert.Equal(t, "trace-agent", renewed.Leaf.Subject.CommonName)
+	_, err = util.RequestClientCertificate(context.Background(), client, addr, "security-agent")
+	assert.ErrorContains(t, err, "another identity")
+}
+
+func TestClientCertificateUnknownIdentity(t *testing.T) {
+	srv := newTestCAServer(t)
+
+	_, err := util.RequestClientCertificate(context.Background(), srv.Client(), srv.Listener.Addr().String(), "agent")
+	assert.ErrorContains(t, err, "unknown identity")
+}
+
+func TestClientCertificateOtherCA(t *testing.T) {
+	srv := newTestCAServer(t)
+	otherSrv := newTestCAServer(t)
+
+	// A certificate issued by the authority of another API server isn't trusted, so the client doesn't
+	// present it and the request isn't authenticated
+	cert, err := util.RequestClientCertificate(context.Background(), otherSrv.Client(), otherSrv.Listener.Addr().String(), "trace-agent")
+	require.NoError(t, err)
+	resp, err := clientWithCertificate(srv, cert).Get(srv.URL + "/agent/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestClientCertificateRotation(t *testing.T) {
+	srv := newTestCAServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	util.StartClientCertificateRotation(ctx, config.Mock(t), srv.Listener.Addr().String(), "trace-agent")
+	client := util.GetClient(false)
+	// the requests are authenticated by the certificate once issued, without the token
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := client.Get(srv.URL + "/agent/ping")
+		if assert.NoError(c, err) {
+			resp.Body.Close()
+			assert.Equal(c, http.StatusOK, resp.StatusCode)
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// the certificate isn't presented to a server that doesn't trust its authority, e.g. once the
+	// agent restarted, the request then falls back to the token
+	otherSrv := newTestCAServer(t)
+	resp, err := client.Get(otherSrv.URL + "/agent/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
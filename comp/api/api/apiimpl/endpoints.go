@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	api "github.com/DataDog/datadog-agent/comp/api/api/def"
+)
+
+// endpointsRoute is the route listing the endpoint providers currently mounted on the /agent router.
+const endpointsRoute = "/endpoints"
+
+// endpointRegistry holds the endpoint providers mounted on the /agent router.
+//
+// gorilla/mux routers can't be modified while they are serving requests, so every
+// registration builds a new router and atomically swaps it with the current one.
+// In-flight requests keep being served by the router they were dispatched to.
+type endpointRegistry struct {
+	m         sync.Mutex
+	providers map[string]api.EndpointProvider
+
+	// build returns the handler serving the given providers. It is nil until the server is started,
+	// registrations made before that are only recorded.
+	build   func(providers []api.EndpointProvider) http.Handler
+	handler atomic.Pointer[http.Handler]
+}
+
+// endpointInfo describes a mounted endpoint.
+type endpointInfo struct {
+	Route   string   `json:"route"`
+	Methods []string `json:"methods"`
+}
+
+func newEndpointRegistry(providers []api.EndpointProvider) *endpointRegistry {
+	r := &endpointRegistry{
+		providers: make(map[string]api.EndpointProvider, len(providers)),
+	}
+	for _, p := range providers {
+		r.providers[endpointKey(p)] = p
+	}
+	return r
+}
+
+// endpointKey identifies an endpoint by its route and methods, so that different
+// providers can serve different methods on the same route.
+func endpointKey(p api.EndpointProvider) string {
+	methods := slices.Clone(p.Methods())
+	sort.Strings(methods)
+	return p.Route() + " " + strings.Join(methods, ",")
+}
+
+// start sets the router builder and mounts the registered providers.
+func (r *endpointRegistry) start(build func(providers []api.EndpointProvider) http.Handler) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.build = build
+	r.rebuild()
+}
+
+// register mounts the given provider, the server doesn't need to be restarted.
+func (r *endpointRegistry) register(p api.EndpointProvider) error {
+	if p == nil {
+		return fmt.Errorf("endpoint provider is nil")
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	key := endpointKey(p)
+	if _, ok := r.providers[key]; ok {
+		return fmt.Errorf("endpoint %s is already registered", key)
+	}
+	r.providers[key] = p
+	r.rebuild()
+	return nil
+}
+
+// unregister unmounts the given provider.
+func (r *endpointRegistry) unregister(p api.EndpointProvider) error {
+	if p == nil {
+		return fmt.Errorf("endpoint provider is nil")
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	key := endpointKey(p)
+	if _, ok := r.providers[key]; !ok {
+		return fmt.Errorf("endpoint %s is not registered", key)
+	}
+	delete(r.providers, key)
+	r.rebuild()
+	return nil
+}
+
+// list returns the providers sorted by route.
+func (r *endpointRegistry) list() []api.EndpointProvider {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.sortedProviders()
+}
+
+func (r *endpointRegistry) sortedProviders() []api.EndpointProvider {
+	providers := make([]api.EndpointProvider, 0, len(r.providers))
+	for _, p := range r.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool { return endpointKey(providers[i]) < endpointKey(providers[j]) })
+	return providers
+}
+
+// rebuild must be called with the lock held.
+func (r *endpointRegistry) rebuild() {
+	if r.build == nil {
+		return
+	}
+	handler := r.build(r.sortedProviders())
+	r.handler.Store(&handler)
+}
+
+// ServeHTTP dispatches the request to the current router.
+func (r *endpointRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	handler := r.handler.Load()
+	if handler == nil {
+		http.Error(w, "server is not started", http.StatusServiceUnavailable)
+		return
+	}
+	(*handler).ServeHTTP(w, req)
+}
+
+// listEndpoints is the handler of the endpoints route.
+func (r *endpointRegistry) listEndpoints(w http.ResponseWriter, _ *http.Request) {
+	endpoints := []endpointInfo{}
+	for _, p := range r.list() {
+		endpoints = append(endpoints, endpointInfo{Route: p.Route(), Methods: p.Methods()})
+	}
+	body, err := json.Marshal(endpoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
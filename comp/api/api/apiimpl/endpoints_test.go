@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/DataDog/datadog-agent/comp/api/api/def"
+)
+
+func testEndpoint(route string, methods ...string) api.EndpointProvider {
+	return api.NewAgentEndpointProvider(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(route))
+	}, route, methods...).Provider
+}
+
+func startTestRegistry(providers ...api.EndpointProvider) *endpointRegistry {
+	registry := newEndpointRegistry(providers)
+	registry.start(func(providers []api.EndpointProvider) http.Handler {
+		r := mux.NewRouter()
+		r.HandleFunc(endpointsRoute, registry.listEndpoints).Methods("GET")
+		for _, p := range providers {
+			r.HandleFunc(p.Route(), p.HandlerFunc()).Methods(p.Methods()...)
+		}
+		return r
+	})
+	return registry
+}
+
+func serve(h http.Handler, method string, route string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, route, nil))
+	return rec
+}
+
+func TestEndpointRegistryNotStarted(t *testing.T) {
+	registry := newEndpointRegistry([]api.EndpointProvider{testEndpoint("/foo", "GET")})
+	assert.Equal(t, http.StatusServiceUnavailable, serve(registry, "GET", "/foo").Code)
+}
+
+func TestEndpointRegistryHotRegistration(t *testing.T) {
+	foo := testEndpoint("/foo", "GET")
+	registry := startTestRegistry(foo)
+
+	assert.Equal(t, http.StatusOK, serve(registry, "GET", "/foo").Code)
+	assert.Equal(t, http.StatusNotFound, serve(registry, "GET", "/bar").Code)
+
+	bar := testEndpoint("/bar", "GET")
+	require.NoError(t, registry.register(bar))
+	assert.Equal(t, "/bar", serve(registry, "GET", "/bar").Body.String())
+	assert.Error(t, registry.register(testEndpoint("/bar", "GET")))
+
+	// Same route with a different method is a different endpoint
+	require.NoError(t, registry.register(testEndpoint("/bar", "POST")))
+
+	require.NoError(t, registry.unregister(foo))
+	assert.Equal(t, http.StatusNotFound, serve(registry, "GET", "/foo").Code)
+	assert.Error(t, registry.unregister(foo))
+}
+
+func TestEndpointRegistryList(t *testing.T) {
+	registry := startTestRegistry(testEndpoint("/foo", "GET"), testEndpoint("/bar", "POST", "GET"))
+
+	rec := serve(registry, "GET", endpointsRoute)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var endpoints []endpointInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &endpoints))
+	assert.Equal(t, []endpointInfo{
+		{Route: "/bar", Methods: []string{"POST", "GET"}},
+		{Route: "/foo", Methods: []string{"GET"}},
+	}, endpoints)
+}
@@ -16,6 +16,8 @@ import (
 
 	gorilla "github.com/gorilla/mux"
 
+	apiutils "github.com/DataDog/datadog-agent/comp/api/api/apiimpl/utils"
+	"github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -39,6 +41,7 @@ func buildAuthorizedSet(paths ...string) authorizedSet {
 type configEndpoint struct {
 	cfg                   config.Reader
 	authorizedConfigPaths authorizedSet
+	watchers              *watchers
 
 	// runtime metrics about the config endpoint usage
 	expvars            *expvar.Map
@@ -53,33 +56,17 @@ func (c *configEndpoint) getConfigValueHandler(w http.ResponseWriter, r *http.Re
 	// all valid config paths won't contain such characters so for a valid request this is a no-op
 	path := html.EscapeString(vars["path"])
 
-	authorized := false
-	if _, ok := c.authorizedConfigPaths[path]; ok {
-		authorized = true
-	} else {
-		// check to see if the requested path matches any of the authorized paths by trying to treat
-		// the authorized path as a prefix: if the requested path is `foo.bar` and we have an
-		// authorized path of `foo`, then `foo.bar` would be allowed, or if we had a requested path
-		// of `foo.bar.quux`, and an authorized path of `foo.bar`, it would also be allowed
-		for authorizedPath := range c.authorizedConfigPaths {
-			if strings.HasPrefix(path, authorizedPath+prefixPathSuffix) {
-				authorized = true
-				break
-			}
-		}
-	}
-
-	if !authorized {
+	if !c.isAuthorized(path) {
 		c.unauthorizedExpvar.Add(path, 1)
 		log.Warnf("config endpoint received a request from '%s' for config '%s' which is not allowed", r.RemoteAddr, path)
-		http.Error(w, fmt.Sprintf("querying config value '%s' is not allowed", path), http.StatusForbidden)
+		apiutils.WriteProblem(w, http.StatusForbidden, util.ErrorCodeConfigNotAllowed, fmt.Sprintf("querying config value '%s' is not allowed", path))
 		return
 	}
 
 	if !c.cfg.IsKnown(path) {
 		c.errorsExpvar.Add(path, 1)
 		log.Warnf("config endpoint received a request from '%s' for config '%s' which does not exist", r.RemoteAddr, path)
-		http.Error(w, fmt.Sprintf("config value '%s' does not exist", path), http.StatusNotFound)
+		apiutils.WriteProblem(w, http.StatusNotFound, util.ErrorCodeConfigNotFound, fmt.Sprintf("config value '%s' does not exist", path))
 		return
 	}
 
@@ -88,6 +75,22 @@ func (c *configEndpoint) getConfigValueHandler(w http.ResponseWriter, r *http.Re
 	c.marshalAndSendResponse(w, path, value)
 }
 
+func (c *configEndpoint) isAuthorized(path string) bool {
+	if _, ok := c.authorizedConfigPaths[path]; ok {
+		return true
+	}
+	// check to see if the requested path matches any of the authorized paths by trying to treat
+	// the authorized path as a prefix: if the requested path is `foo.bar` and we have an
+	// authorized path of `foo`, then `foo.bar` would be allowed, or if we had a requested path
+	// of `foo.bar.quux`, and an authorized path of `foo.bar`, it would also be allowed
+	for authorizedPath := range c.authorizedConfigPaths {
+		if strings.HasPrefix(path, authorizedPath+prefixPathSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *configEndpoint) getAllConfigValuesHandler(w http.ResponseWriter, r *http.Request) {
 	log.Debug("config endpoint received a request from '%s' for all authorized config values", r.RemoteAddr)
 	allValues := make(map[string]interface{}, len(c.authorizedConfigPaths))
@@ -117,7 +120,9 @@ func getConfigEndpoint(cfg config.Reader, authorizedConfigPaths authorizedSet, e
 		cfg:                   cfg,
 		authorizedConfigPaths: authorizedConfigPaths,
 		expvars:               expvar.NewMap(expvarNamespace + "_config_endpoint"),
+		watchers:              newWatchers(),
 	}
+	cfg.OnUpdate(configEndpoint.watchers.notify)
 
 	for name, expv := range map[string]*expvar.Map{
 		"success":      &configEndpoint.successExpvar,
@@ -129,6 +134,8 @@ func getConfigEndpoint(cfg config.Reader, authorizedConfigPaths authorizedSet, e
 
 	configEndpointMux := gorilla.NewRouter()
 	configEndpointMux.HandleFunc("/", http.HandlerFunc(configEndpoint.getAllConfigValuesHandler)).Methods("GET")
+	// registered before /{path} so that it isn't handled as a config path
+	configEndpointMux.HandleFunc("/watch", http.HandlerFunc(configEndpoint.watchConfigValuesHandler)).Methods("GET")
 	configEndpointMux.HandleFunc("/{path}", http.HandlerFunc(configEndpoint.getConfigValueHandler)).Methods("GET")
 
 	return configEndpointMux, configEndpoint
@@ -138,7 +145,7 @@ func (c *configEndpoint) marshalAndSendResponse(w http.ResponseWriter, path stri
 	body, err := json.Marshal(value)
 	if err != nil {
 		c.errorsExpvar.Add(path, 1)
-		http.Error(w, fmt.Sprintf("could not marshal config value of '%s': %v", path, err), http.StatusInternalServerError)
+		apiutils.WriteProblem(w, http.StatusInternalServerError, util.ErrorCodeInternal, fmt.Sprintf("could not marshal config value of '%s': %v", path, err))
 		return
 	}
 
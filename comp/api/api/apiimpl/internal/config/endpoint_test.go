@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/config"
 )
 
@@ -55,6 +56,13 @@ func testConfigValue(t *testing.T, configEndpoint *configEndpoint, server *httpt
 	require.Equal(t, expectedStatus, resp.StatusCode, string(body))
 
 	if resp.StatusCode != http.StatusOK {
+		// errors are reported as problem details
+		require.Equal(t, util.ProblemContentType, resp.Header.Get("Content-Type"))
+		var problem util.Problem
+		require.NoError(t, json.Unmarshal(body, &problem))
+		require.Equal(t, expectedStatus, problem.Status)
+		require.NotEmpty(t, problem.Code)
+		require.Contains(t, problem.Detail, configName)
 		return
 	}
 
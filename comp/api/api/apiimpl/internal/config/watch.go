@@ -0,0 +1,197 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apiutils "github.com/DataDog/datadog-agent/comp/api/api/apiimpl/utils"
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	watchPath           = "/watch"
+	defaultWatchTimeout = 20 * time.Second
+)
+
+// watchResponse is the response of the watch endpoint
+type watchResponse struct {
+	// Revision is the revision of the config when the response was sent, it must be passed as the
+	// revision parameter of the next request so that no change is missed between two requests
+	Revision uint64 `json:"revision"`
+	// Changed are the watched keys changed since the requested revision, it's empty if the request timed out
+	Changed []string `json:"changed"`
+	// Values are the current values of the watched keys
+	Values map[string]interface{} `json:"values"`
+}
+
+// watchers tracks the changes of the config, numbered by revision, and wakes up the requests
+// waiting for them
+type watchers struct {
+	sync.Mutex
+	revision uint64
+	// revisions is the revision of the last change of each setting
+	revisions map[string]uint64
+	// changed is closed and replaced on each change
+	changed chan struct{}
+}
+
+func newWatchers() *watchers {
+	return &watchers{
+		revisions: make(map[string]uint64),
+		changed:   make(chan struct{}),
+	}
+}
+
+// notify is registered as a config update receiver, it must not block
+func (w *watchers) notify(setting string, _, _ any) {
+	w.Lock()
+	defer w.Unlock()
+	w.revision++
+	w.revisions[setting] = w.revision
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+func (w *watchers) currentRevision() uint64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.revision
+}
+
+// wait blocks until one of the keys changes after the given revision, or until the timeout or the
+// cancellation of the context. It returns the changed keys and the current revision.
+func (w *watchers) wait(ctx context.Context, keys []string, since uint64, timeout time.Duration) ([]string, uint64) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		w.Lock()
+		changed := w.changedSince(keys, since)
+		revision, ch := w.revision, w.changed
+		w.Unlock()
+		if len(changed) > 0 {
+			return changed, revision
+		}
+		select {
+		case <-ch:
+		case <-timer.C:
+			return []string{}, revision
+		case <-ctx.Done():
+			return []string{}, revision
+		}
+	}
+}
+
+// changedSince returns the keys changed after the given revision.
+// changedSince must be called while holding the lock.
+func (w *watchers) changedSince(keys []string, since uint64) []string {
+	if since > w.revision {
+		// the revision comes from a previous run of the agent, every key may have changed
+		return keys
+	}
+	var changed []string
+	for _, key := range keys {
+		for setting, revision := range w.revisions {
+			if revision > since && settingMatches(key, setting) {
+				changed = append(changed, key)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// settingMatches returns whether a change of the setting changes the value of the key, the setting
+// being the key itself, one of its children or one of its parents
+func settingMatches(key string, setting string) bool {
+	return setting == key ||
+		strings.HasPrefix(setting, key+prefixPathSuffix) ||
+		strings.HasPrefix(key, setting+prefixPathSuffix)
+}
+
+// watchConfigValuesHandler long-polls for changes of the config keys given in the `keys` parameter,
+// separated by commas. It responds as soon as one of them changes after the `revision` parameter,
+// or after the `timeout` parameter, so that the clients don't have to poll the values to react to
+// runtime changes. Without revision, only the changes happening during the request are reported.
+func (c *configEndpoint) watchConfigValuesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var keys []string
+	for _, key := range strings.Split(query.Get("keys"), ",") {
+		// escape in case it contains html special characters that would be unsafe to include as is in a response
+		if key = html.EscapeString(strings.TrimSpace(key)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		c.errorsExpvar.Add(watchPath, 1)
+		apiutils.WriteProblem(w, http.StatusBadRequest, util.ErrorCodeInvalidRequest, "no config key to watch, the 'keys' parameter is required")
+		return
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !c.isAuthorized(key) {
+			c.unauthorizedExpvar.Add(key, 1)
+			log.Warnf("config endpoint received a request from '%s' to watch config '%s' which is not allowed", r.RemoteAddr, key)
+			apiutils.WriteProblem(w, http.StatusForbidden, util.ErrorCodeConfigNotAllowed, fmt.Sprintf("watching config value '%s' is not allowed", key))
+			return
+		}
+		if !c.cfg.IsKnown(key) {
+			c.errorsExpvar.Add(key, 1)
+			log.Warnf("config endpoint received a request from '%s' to watch config '%s' which does not exist", r.RemoteAddr, key)
+			apiutils.WriteProblem(w, http.StatusNotFound, util.ErrorCodeConfigNotFound, fmt.Sprintf("config value '%s' does not exist", key))
+			return
+		}
+	}
+
+	since := c.watchers.currentRevision()
+	if value := query.Get("revision"); value != "" {
+		revision, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.errorsExpvar.Add(watchPath, 1)
+			apiutils.WriteProblem(w, http.StatusBadRequest, util.ErrorCodeInvalidRequest, fmt.Sprintf("invalid revision '%s': %v", html.EscapeString(value), err))
+			return
+		}
+		since = revision
+	}
+
+	timeout := defaultWatchTimeout
+	if value := query.Get("timeout"); value != "" {
+		var err error
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			c.errorsExpvar.Add(watchPath, 1)
+			apiutils.WriteProblem(w, http.StatusBadRequest, util.ErrorCodeInvalidRequest, fmt.Sprintf("invalid timeout '%s'", html.EscapeString(value)))
+			return
+		}
+	}
+	// respond before the server times the request out, leaving a margin to write the response
+	if maxTimeout := time.Duration(c.cfg.GetInt64("server_timeout"))*time.Second - time.Second; maxTimeout > 0 && timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+
+	log.Debugf("config endpoint received a request from '%s' to watch configs %v since revision %d", r.RemoteAddr, keys, since)
+	changed, revision := c.watchers.wait(r.Context(), keys, since, timeout)
+
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		values[key] = c.cfg.Get(key)
+	}
+	c.marshalAndSendResponse(w, watchPath, watchResponse{
+		Revision: revision,
+		Changed:  changed,
+		Values:   values,
+	})
+}
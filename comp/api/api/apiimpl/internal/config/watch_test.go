@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func watchConfigValues(t *testing.T, server *httptest.Server, query string) (int, watchResponse) {
+	t.Helper()
+
+	resp, err := server.Client().Get(server.URL + "/watch?" + query)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var response watchResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	}
+	return resp.StatusCode, response
+}
+
+func TestWatchConfigEndpoint(t *testing.T) {
+	cfg, server, _ := getConfigServer(t, authorizedSet{"my.config": {}})
+	cfg.SetWithoutSource("my.config.value", "some_value")
+	cfg.SetKnown("my.config.value")
+	cfg.SetWithoutSource("my.config.other", "other_value")
+	cfg.SetKnown("my.config.other")
+
+	// the changes done before the first request are reported from revision 0
+	status, response := watchConfigValues(t, server, "keys=my.config.value&revision=0")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"my.config.value"}, response.Changed)
+	assert.Equal(t, map[string]interface{}{"my.config.value": "some_value"}, response.Values)
+	revision := response.Revision
+
+	// nothing changed since then
+	status, response = watchConfigValues(t, server, fmt.Sprintf("keys=my.config.value&revision=%d&timeout=10ms", revision))
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, response.Changed)
+	assert.Equal(t, revision, response.Revision)
+
+	// the request waits for the change
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cfg.SetWithoutSource("my.config.other", "new_value")
+		cfg.SetWithoutSource("my.config.value", "new_value")
+	}()
+	status, response = watchConfigValues(t, server, fmt.Sprintf("keys=my.config.value&revision=%d&timeout=10s", revision))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"my.config.value"}, response.Changed)
+	assert.Equal(t, map[string]interface{}{"my.config.value": "new_value"}, response.Values)
+	assert.Greater(t, response.Revision, revision)
+}
+
+func TestWatchConfigEndpointParentKey(t *testing.T) {
+	cfg, server, configEndpoint := getConfigServer(t, authorizedSet{"my.config": {}})
+	cfg.SetWithoutSource("my.config.value", "some_value")
+	cfg.SetKnown("my.config")
+	revision := configEndpoint.watchers.currentRevision()
+
+	cfg.SetWithoutSource("my.config.value", "new_value")
+	status, response := watchConfigValues(t, server, fmt.Sprintf("keys=my.config&revision=%d&timeout=10ms", revision))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"my.config"}, response.Changed)
+}
+
+func TestWatchConfigEndpointRevisionFromPreviousRun(t *testing.T) {
+	cfg, server, _ := getConfigServer(t, authorizedSet{"my.config.value": {}})
+	cfg.SetKnown("my.config.value")
+
+	status, response := watchConfigValues(t, server, "keys=my.config.value&revision=1000&timeout=10ms")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"my.config.value"}, response.Changed)
+}
+
+func TestWatchConfigEndpointErrors(t *testing.T) {
+	cfg, server, _ := getConfigServer(t, authorizedSet{"my.config.value": {}, "my.missing.value": {}})
+	cfg.SetWithoutSource("my.config.value", "some_value")
+	cfg.SetKnown("my.config.value")
+	cfg.SetWithoutSource("my.secret.value", "some_value")
+	cfg.SetKnown("my.secret.value")
+
+	for _, testCase := range []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{"no_keys", "timeout=10ms", http.StatusBadRequest},
+		{"unauthorized", "keys=my.config.value,my.secret.value&timeout=10ms", http.StatusForbidden},
+		{"missing", "keys=my.missing.value&timeout=10ms", http.StatusNotFound},
+		{"invalid_revision", "keys=my.config.value&revision=abc&timeout=10ms", http.StatusBadRequest},
+		{"invalid_timeout", "keys=my.config.value&timeout=-1s", http.StatusBadRequest},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			status, _ := watchConfigValues(t, server, testCase.query)
+			assert.Equal(t, testCase.expectedStatus, status)
+		})
+	}
+}
@@ -39,3 +39,27 @@ func getIPCServerAddressPort() (string, string, bool) {
 
 	return ipcServerHost, ipcServerHostPort, true
 }
+
+// returns whether the read-only status server is enabled, and if so its host:port. The server
+// doesn't require the auth token so it must only listen on a loopback address.
+func getStatusServerAddressPort() (string, bool, error) {
+	statusServerPort := config.Datadog().GetInt("status_server.port")
+	if statusServerPort == 0 {
+		return "", false, nil
+	}
+
+	statusServerHost := config.Datadog().GetString("status_server.host")
+	if !isLoopback(statusServerHost) {
+		return "", false, fmt.Errorf("status_server.host must be a loopback address, got %q", statusServerHost)
+	}
+
+	return net.JoinHostPort(statusServerHost, strconv.Itoa(statusServerPort)), true, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
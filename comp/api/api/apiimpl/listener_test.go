@@ -38,3 +38,37 @@ func TestGetIPCServerAddressPort(t *testing.T) {
 		require.False(t, enabled)
 	})
 }
+
+func TestGetStatusServerAddressPort(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		config.Mock(t)
+		_, enabled, err := getStatusServerAddressPort()
+		require.NoError(t, err)
+		require.False(t, enabled)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		cfg := config.Mock(t)
+		cfg.SetWithoutSource("status_server.port", 1234)
+
+		hostPort, enabled, err := getStatusServerAddressPort()
+		require.NoError(t, err)
+		require.Equal(t, "localhost:1234", hostPort)
+		require.True(t, enabled)
+
+		cfg.SetWithoutSource("status_server.host", "::1")
+		hostPort, _, err = getStatusServerAddressPort()
+		require.NoError(t, err)
+		require.Equal(t, "[::1]:1234", hostPort)
+	})
+
+	t.Run("not loopback", func(t *testing.T) {
+		cfg := config.Mock(t)
+		cfg.SetWithoutSource("status_server.port", 1234)
+		cfg.SetWithoutSource("status_server.host", "0.0.0.0")
+
+		_, enabled, err := getStatusServerAddressPort()
+		require.Error(t, err)
+		require.False(t, enabled)
+	})
+}
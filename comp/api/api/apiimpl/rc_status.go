@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DataDog/datadog-agent/comp/api/api/utils"
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservicemrf"
+	"github.com/DataDog/datadog-agent/pkg/config/remote/service"
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/util/optional"
+)
+
+// remoteConfigStatusRoute is the route of the /agent router reporting the status of the remote configurations
+const remoteConfigStatusRoute = "/remote-config/status"
+
+// configStateGetter returns the state of the local store of a remote config service
+type configStateGetter interface {
+	ConfigGetState() (*pb.GetStateConfigResponse, error)
+}
+
+// remoteConfigServiceStatus is the status of the configurations of a remote config service, per product
+type remoteConfigServiceStatus struct {
+	Products []service.ProductStatus `json:"products"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// remoteConfigStatus is the status of the remote config services running in the agent, a service that
+// isn't running is omitted
+type remoteConfigStatus struct {
+	Service *remoteConfigServiceStatus `json:"service,omitempty"`
+	MRF     *remoteConfigServiceStatus `json:"mrf,omitempty"`
+}
+
+// getRemoteConfigStatus reports which configurations of each product are applied, pending or failed, so
+// that users can check locally why a remote configuration change didn't take effect.
+func getRemoteConfigStatus(configService optional.Option[rcservice.Component], configServiceMRF optional.Option[rcservicemrf.Component]) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		var status remoteConfigStatus
+		if rcService, isSet := configService.Get(); isSet && rcService != nil {
+			status.Service = getRemoteConfigServiceStatus(rcService)
+		}
+		if rcServiceMRF, isSet := configServiceMRF.Get(); isSet && rcServiceMRF != nil {
+			status.MRF = getRemoteConfigServiceStatus(rcServiceMRF)
+		}
+		body, err := json.Marshal(status)
+		if err != nil {
+			utils.SetJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+func getRemoteConfigServiceStatus(s configStateGetter) *remoteConfigServiceStatus {
+	state, err := s.ConfigGetState()
+	if err != nil {
+		return &remoteConfigServiceStatus{Products: []service.ProductStatus{}, Error: err.Error()}
+	}
+	return &remoteConfigServiceStatus{Products: service.ProductsStatus(state)}
+}
@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservicemrf"
+	"github.com/DataDog/datadog-agent/pkg/config/remote/service"
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	rcstate "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/DataDog/datadog-agent/pkg/util/optional"
+)
+
+type fakeRCService struct {
+	state *pb.GetStateConfigResponse
+	err   error
+}
+
+func (s fakeRCService) ClientGetConfigs(context.Context, *pb.ClientGetConfigsRequest) (*pb.ClientGetConfigsResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s fakeRCService) ConfigGetState() (*pb.GetStateConfigResponse, error) {
+	return s.state, s.err
+}
+
+func TestGetRemoteConfigStatus(t *testing.T) {
+	rcService := fakeRCService{state: &pb.GetStateConfigResponse{
+		TargetFilenames: map[string]string{"datadog/2/APM_TRACING/config-1/config": "hash"},
+		ActiveClients: []*pb.Client{{
+			Id: "client-1",
+			State: &pb.ClientState{ConfigStates: []*pb.ConfigState{
+				{Id: "config-1", Version: 4, Product: "APM_TRACING", ApplyState: uint64(rcstate.ApplyStateError), ApplyError: "invalid config"},
+			}},
+		}},
+	}}
+	rcServiceMRF := fakeRCService{err: errors.New("remote config service not started")}
+
+	w := httptest.NewRecorder()
+	getRemoteConfigStatus(
+		optional.NewOption[rcservice.Component](rcService),
+		optional.NewOption[rcservicemrf.Component](rcServiceMRF),
+	)(w, httptest.NewRequest(http.MethodGet, remoteConfigStatusRoute, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status remoteConfigStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.Service)
+	assert.Equal(t, []service.ProductStatus{{
+		Product: "APM_TRACING",
+		Failed:  1,
+		Configs: []service.ConfigStatus{{
+			ID:      "config-1",
+			Path:    "datadog/2/APM_TRACING/config-1/config",
+			Version: 4,
+			State:   service.ConfigFailed,
+			Errors:  []string{"client-1: invalid config"},
+			Clients: []string{"client-1"},
+		}},
+	}}, status.Service.Products)
+	require.NotNil(t, status.MRF)
+	assert.Equal(t, "remote config service not started", status.MRF.Error)
+
+	// the services that aren't running are omitted
+	w = httptest.NewRecorder()
+	getRemoteConfigStatus(optional.NewNoneOption[rcservice.Component](), optional.NewNoneOption[rcservicemrf.Component]())(w, httptest.NewRequest(http.MethodGet, remoteConfigStatusRoute, nil))
+	assert.JSONEq(t, `{}`, w.Body.String())
+}
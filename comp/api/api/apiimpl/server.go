@@ -14,7 +14,6 @@ import (
 
 	"github.com/cihub/seelog"
 
-	api "github.com/DataDog/datadog-agent/comp/api/api/def"
 	"github.com/DataDog/datadog-agent/comp/collector/collector"
 	"github.com/DataDog/datadog-agent/comp/core/autodiscovery"
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
@@ -71,7 +70,7 @@ func StartServers(
 	statusComponent status.Component,
 	collector optional.Option[collector.Component],
 	ac autodiscovery.Component,
-	providers []api.EndpointProvider,
+	endpoints *endpointRegistry,
 ) error {
 	apiAddr, err := getIPCAddressPort()
 	if err != nil {
@@ -81,6 +80,11 @@ func StartServers(
 	additionalHostIdentities := []string{apiAddr}
 
 	ipcServerHost, ipcServerHostPort, ipcServerEnabled := getIPCServerAddressPort()
+
+	statusServerHostPort, statusServerEnabled, err := getStatusServerAddressPort()
+	if err != nil {
+		return fmt.Errorf("unable to get status server address and port: %v", err)
+	}
 	if ipcServerEnabled {
 		additionalHostIdentities = append(additionalHostIdentities, ipcServerHost)
 	}
@@ -90,10 +94,18 @@ func StartServers(
 		return fmt.Errorf("unable to initialize TLS: %v", err)
 	}
 
+	clientAuthority, err := newClientCA()
+	if err != nil {
+		return fmt.Errorf("unable to initialize client CA: %v", err)
+	}
+
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{*tlsKeyPair},
 		NextProtos:   []string{"h2"},
 		MinVersion:   tls.VersionTLS12,
+		// Satellite processes may authenticate with a certificate issued by the client CA
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientAuthority.pool,
 	}
 
 	// start the CMD server
@@ -101,6 +113,7 @@ func StartServers(
 		apiAddr,
 		tlsConfig,
 		tlsCertPool,
+		clientAuthority,
 		configService,
 		configServiceMRF,
 		dogstatsdServer,
@@ -114,7 +127,7 @@ func StartServers(
 		statusComponent,
 		collector,
 		ac,
-		providers,
+		endpoints,
 	); err != nil {
 		return fmt.Errorf("unable to start CMD API server: %v", err)
 	}
@@ -128,6 +141,15 @@ func StartServers(
 		}
 	}
 
+	// start the read-only status server
+	if statusServerEnabled {
+		if err := startStatusServer(statusServerHostPort); err != nil {
+			// if we fail to start the status server, we should stop the other servers
+			StopServers()
+			return fmt.Errorf("unable to start status API server: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -135,4 +157,5 @@ func StartServers(
 func StopServers() {
 	stopCMDServer()
 	stopIPCServer()
+	stopStatusServer()
 }
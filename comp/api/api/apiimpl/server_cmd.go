@@ -53,6 +53,7 @@ func startCMDServer(
 	cmdAddr string,
 	tlsConfig *tls.Config,
 	tlsCertPool *x509.CertPool,
+	clientAuthority *clientCA,
 	configService optional.Option[rcservice.Component],
 	configServiceMRF optional.Option[rcservicemrf.Component],
 	dogstatsdServer dogstatsdServer.Component,
@@ -66,7 +67,7 @@ func startCMDServer(
 	statusComponent status.Component,
 	collector optional.Option[collector.Component],
 	ac autodiscovery.Component,
-	providers []api.EndpointProvider,
+	endpoints *endpointRegistry,
 ) (err error) {
 	// get the transport we're going to use under HTTP
 	cmdListener, err = getListener(cmdAddr)
@@ -120,28 +121,31 @@ func startCMDServer(
 
 	// Setup multiplexer
 	// create the REST HTTP router
-	agentMux := gorilla.NewRouter()
+	// The agent router is rebuilt every time an endpoint provider is registered or unregistered
+	endpoints.start(func(providers []api.EndpointProvider) http.Handler {
+		agentMux := gorilla.NewRouter()
+		// Validate token for every request
+		agentMux.Use(validateToken)
+		agentMux.HandleFunc(endpointsRoute, endpoints.listEndpoints).Methods("GET")
+		agentMux.HandleFunc(certificateRoute, clientAuthority.issueCertificate).Methods("POST")
+		agentMux.HandleFunc(remoteConfigStatusRoute, getRemoteConfigStatus(configService, configServiceMRF)).Methods("GET")
+		return agent.SetupHandlers(
+			agentMux,
+			wmeta,
+			logsAgent,
+			senderManager,
+			secretResolver,
+			statusComponent,
+			collector,
+			ac,
+			providers,
+		)
+	})
 	checkMux := gorilla.NewRouter()
-
-	// Validate token for every request
-	agentMux.Use(validateToken)
 	checkMux.Use(validateToken)
 
 	cmdMux := http.NewServeMux()
-	cmdMux.Handle(
-		"/agent/",
-		http.StripPrefix("/agent",
-			agent.SetupHandlers(
-				agentMux,
-				wmeta,
-				logsAgent,
-				senderManager,
-				secretResolver,
-				statusComponent,
-				collector,
-				ac,
-				providers,
-			)))
+	cmdMux.Handle("/agent/", http.StripPrefix("/agent", endpoints))
 	cmdMux.Handle("/check/", http.StripPrefix("/check", check.SetupHandlers(checkMux)))
 	cmdMux.Handle("/", gwmux)
 
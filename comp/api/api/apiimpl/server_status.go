@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"encoding/json"
+	"fmt"
+	stdLog "log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cihub/seelog"
+
+	apiutils "github.com/DataDog/datadog-agent/comp/api/api/apiimpl/utils"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	"github.com/DataDog/datadog-agent/pkg/status/health"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+const statusServerName string = "Status API Server"
+
+var statusListener net.Listener
+
+// minimalStatus is the status exposed by the read-only status server, it must not expose anything
+// sensitive as the server doesn't require the auth token
+type minimalStatus struct {
+	Version   string    `json:"version"`
+	PID       int       `json:"pid"`
+	StartTime time.Time `json:"start_time"`
+	Healthy   bool      `json:"healthy"`
+}
+
+// startStatusServer starts the read-only status server, exposing the health and a minimal status of
+// the Agent over plain HTTP for local tools that can't read the auth token
+func startStatusServer(statusServerAddr string) (err error) {
+	statusListener, err = getListener(statusServerAddr)
+	if err != nil {
+		return err
+	}
+
+	statusServer := &http.Server{
+		Addr:    statusServerAddr,
+		Handler: http.TimeoutHandler(statusServerHandler(), time.Duration(config.Datadog().GetInt64("server_timeout"))*time.Second, "timeout"),
+	}
+
+	// Use a stack depth of 4 on top of the default one to get a relevant filename in the stdlib
+	logWriter, _ := config.NewLogWriter(5, seelog.ErrorLvl)
+	statusServer.ErrorLog = stdLog.New(logWriter, fmt.Sprintf("Error from the Agent HTTP server '%s': ", statusServerName), 0)
+
+	go statusServer.Serve(statusListener) //nolint:errcheck
+
+	log.Infof("Started HTTP server '%s' on %s", statusServerName, statusListener.Addr().String())
+	return nil
+}
+
+func stopStatusServer() {
+	stopServer(statusListener, statusServerName)
+}
+
+func statusServerHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", readOnly(getStatusServerHealth))
+	mux.HandleFunc("/status", readOnly(getMinimalStatus))
+	return apiutils.LogResponseHandler(statusServerName)(mux)
+}
+
+// readOnly rejects the requests that aren't GET or HEAD
+func readOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// getStatusServerHealth returns the health of the Agent, with a 500 status code when it is unhealthy
+// so that healthchecks can rely on the status code only
+func getStatusServerHealth(w http.ResponseWriter, _ *http.Request) {
+	h := health.GetReady()
+	statusCode := http.StatusOK
+	if len(h.Unhealthy) > 0 {
+		log.Debugf("Healthcheck failed on: %v", h.Unhealthy)
+		statusCode = http.StatusInternalServerError
+	}
+	writeStatusServerJSON(w, h, statusCode)
+}
+
+func getMinimalStatus(w http.ResponseWriter, _ *http.Request) {
+	writeStatusServerJSON(w, minimalStatus{
+		Version:   version.AgentVersion,
+		PID:       os.Getpid(),
+		StartTime: pkgconfigsetup.StartTime,
+		Healthy:   len(health.GetReady().Unhealthy) == 0,
+	}, http.StatusOK)
+}
+
+func writeStatusServerJSON(w http.ResponseWriter, v interface{}, statusCode int) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package apiimpl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/status/health"
+)
+
+func TestStatusServerHandler(t *testing.T) {
+	handler := statusServerHandler()
+
+	get := func(method string, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// a component that hasn't reported yet is unhealthy
+	handle := health.RegisterReadiness("status-server-test")
+	t.Cleanup(func() { handle.Deregister() })
+
+	rec := get(http.MethodGet, "/health")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var h health.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &h))
+	assert.Contains(t, h.Unhealthy, "status-server-test")
+
+	rec = get(http.MethodGet, "/status")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var status minimalStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, os.Getpid(), status.PID)
+	assert.False(t, status.Healthy)
+
+	// the server is read-only
+	assert.Equal(t, http.StatusMethodNotAllowed, get(http.MethodPost, "/health").Code)
+	// and doesn't expose the authenticated endpoints
+	assert.Equal(t, http.StatusNotFound, get(http.MethodGet, "/agent/status").Code)
+	assert.Equal(t, http.StatusNotFound, get(http.MethodGet, "/config/v1/").Code)
+}
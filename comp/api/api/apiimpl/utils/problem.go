@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+)
+
+// WriteProblem writes an error response as problem details (RFC 7807), with the machine-readable
+// code of the error. Clients of pkg/api/util get the problem back as the error of the request.
+func WriteProblem(w http.ResponseWriter, status int, code util.ErrorCode, detail string) {
+	body, _ := json.Marshal(util.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+	w.Header().Set("Content-Type", util.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -25,6 +25,13 @@ type Component interface {
 	StartServer() error
 	StopServer()
 	ServerAddress() *net.TCPAddr
+
+	// RegisterEndpointProvider mounts an endpoint at runtime, e.g. for features enabled through remote config.
+	RegisterEndpointProvider(provider EndpointProvider) error
+	// UnregisterEndpointProvider unmounts an endpoint previously registered.
+	UnregisterEndpointProvider(provider EndpointProvider) error
+	// EndpointProviders returns the endpoints currently mounted.
+	EndpointProviders() []EndpointProvider
 }
 
 // EndpointProvider is an interface to register api endpoints
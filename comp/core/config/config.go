@@ -110,6 +110,8 @@ func newConfig(deps dependencies) (*cfg, error) {
 		}
 	}
 
+	setTelemetry(config)
+
 	return &cfg{Config: config, warnings: warnings}, nil
 }
 
@@ -36,10 +36,12 @@ require (
 	github.com/DataDog/datadog-agent/pkg/config/env v0.55.0-rc.3
 	github.com/DataDog/datadog-agent/pkg/config/model v0.55.0-rc.3
 	github.com/DataDog/datadog-agent/pkg/config/setup v0.55.0-rc.3
+	github.com/DataDog/datadog-agent/pkg/telemetry v0.55.0-rc.3
 	github.com/DataDog/datadog-agent/pkg/util/fxutil v0.55.0-rc.3
 	github.com/DataDog/datadog-agent/pkg/util/optional v0.55.0-rc.3
 	github.com/DataDog/datadog-agent/pkg/util/winutil v0.55.0-rc.3
 	github.com/DataDog/viper v1.13.5
+	github.com/prometheus/client_golang v1.17.0
 	github.com/stretchr/testify v1.9.0
 	go.uber.org/fx v1.18.2
 )
@@ -78,7 +80,6 @@ require (
 	github.com/pelletier/go-toml v1.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/power-devops/perfstat v0.0.0-20210106213030-5aafc221ea8c // indirect
-	github.com/prometheus/client_golang v1.17.0 // indirect
 	github.com/prometheus/client_model v0.5.0 // indirect
 	github.com/prometheus/common v0.44.0 // indirect
 	github.com/prometheus/procfs v0.11.1 // indirect
@@ -93,6 +94,7 @@ require (
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.3 // indirect
 	go.opentelemetry.io/otel v1.27.0 // indirect
+	go.opentelemetry.io/otel/exporters/prometheus v0.42.0 // indirect
 	go.opentelemetry.io/otel/metric v1.27.0 // indirect
 	go.opentelemetry.io/otel/sdk v1.27.0 // indirect
 	go.opentelemetry.io/otel/sdk/metric v1.27.0 // indirect
@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	pkgconfigmodel "github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+const telemetrySubsystem = "config"
+
+// durationBuckets are the buckets, in seconds, of the durations of the configuration hot paths
+var durationBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+var (
+	tlmSetCalls = telemetry.NewCounterWithOpts(
+		telemetrySubsystem,
+		"set_calls",
+		[]string{"source", "changed"},
+		"Number of calls to Set by source and whether they changed the value.",
+		telemetry.Options{NoDoubleUnderscoreSep: true},
+	)
+	tlmNotificationLatency = telemetry.NewHistogramWithOpts(
+		telemetrySubsystem,
+		"notification_latency",
+		[]string{},
+		"Time in seconds it took to notify all the receivers of a change.",
+		durationBuckets,
+		telemetry.Options{NoDoubleUnderscoreSep: true},
+	)
+	tlmNotificationReceivers = telemetry.NewGaugeWithOpts(
+		telemetrySubsystem,
+		"notification_receivers",
+		[]string{},
+		"Number of receivers notified of the last change.",
+		telemetry.Options{NoDoubleUnderscoreSep: true},
+	)
+	tlmSnapshotBuilds = telemetry.NewCounterWithOpts(
+		telemetrySubsystem,
+		"snapshot_builds",
+		[]string{},
+		"Number of snapshots of the settings built.",
+		telemetry.Options{NoDoubleUnderscoreSep: true},
+	)
+	tlmSnapshotDuration = telemetry.NewHistogramWithOpts(
+		telemetrySubsystem,
+		"snapshot_duration",
+		[]string{},
+		"Time in seconds it took to build a snapshot of the settings.",
+		durationBuckets,
+		telemetry.Options{NoDoubleUnderscoreSep: true},
+	)
+)
+
+// configTelemetry exports the measurements of the configuration as agent telemetry.
+type configTelemetry struct{}
+
+var _ pkgconfigmodel.Telemetry = configTelemetry{}
+
+func (configTelemetry) SetCalled(source pkgconfigmodel.Source, changed bool) {
+	tlmSetCalls.Inc(source.String(), strconv.FormatBool(changed))
+}
+
+func (configTelemetry) ReceiversNotified(receivers int, duration time.Duration) {
+	tlmNotificationReceivers.Set(float64(receivers))
+	tlmNotificationLatency.Observe(duration.Seconds())
+}
+
+func (configTelemetry) SnapshotBuilt(_ int, duration time.Duration) {
+	tlmSnapshotBuilds.Inc()
+	tlmSnapshotDuration.Observe(duration.Seconds())
+}
+
+// sourceKeysCollector reports the number of keys set by each source when the telemetry is scraped,
+// walking the keys of the sources is too costly to be done on each write.
+type sourceKeysCollector struct {
+	config pkgconfigmodel.Instrumented
+	desc   *prometheus.Desc
+}
+
+func newSourceKeysCollector(config pkgconfigmodel.Instrumented) *sourceKeysCollector {
+	return &sourceKeysCollector{
+		config: config,
+		desc:   prometheus.NewDesc(telemetrySubsystem+"_source_keys", "Number of keys set by each source.", []string{"source"}, nil),
+	}
+}
+
+func (c *sourceKeysCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *sourceKeysCollector) Collect(ch chan<- prometheus.Metric) {
+	for source, keys := range c.config.SourceKeys() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(keys), source.String())
+	}
+}
+
+// registerSourceKeysOnce registers the collector of the source keys once, the configuration is global
+var registerSourceKeysOnce sync.Once
+
+// setTelemetry registers the telemetry of the configuration, if it's instrumented.
+func setTelemetry(config pkgconfigmodel.Config) {
+	instrumented, ok := config.(pkgconfigmodel.Instrumented)
+	if !ok {
+		return
+	}
+	instrumented.SetTelemetry(configTelemetry{})
+	registerSourceKeysOnce.Do(func() {
+		telemetry.GetCompatComponent().RegisterCollector(newSourceKeysCollector(instrumented))
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/DataDog/datadog-agent/comp/aggregator/diagnosesendermanager"
+	apiimplutils "github.com/DataDog/datadog-agent/comp/api/api/apiimpl/utils"
 	api "github.com/DataDog/datadog-agent/comp/api/api/def"
 	apiutils "github.com/DataDog/datadog-agent/comp/api/api/utils"
 	"github.com/DataDog/datadog-agent/comp/collector/collector"
@@ -29,6 +30,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
 	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
 	rcclienttypes "github.com/DataDog/datadog-agent/comp/remote-config/rcclient/types"
+	apiutil "github.com/DataDog/datadog-agent/pkg/api/util"
 	"github.com/DataDog/datadog-agent/pkg/config/utils"
 	"github.com/DataDog/datadog-agent/pkg/diagnose"
 	pkgFlare "github.com/DataDog/datadog-agent/pkg/flare"
@@ -116,12 +118,12 @@ func (f *flare) createAndReturnFlarePath(w http.ResponseWriter, r *http.Request)
 	if r.Body != http.NoBody {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, f.log.Errorf("Error while reading HTTP request body: %s", err).Error(), 500)
+			apiimplutils.WriteProblem(w, http.StatusBadRequest, apiutil.ErrorCodeInvalidRequest, f.log.Errorf("Error while reading HTTP request body: %s", err).Error())
 			return
 		}
 
 		if err := json.Unmarshal(body, &profile); err != nil {
-			http.Error(w, f.log.Errorf("Error while unmarshaling JSON from request body: %s", err).Error(), 500)
+			apiimplutils.WriteProblem(w, http.StatusBadRequest, apiutil.ErrorCodeInvalidRequest, f.log.Errorf("Error while unmarshaling JSON from request body: %s", err).Error())
 			return
 		}
 	}
@@ -140,8 +142,10 @@ func (f *flare) createAndReturnFlarePath(w http.ResponseWriter, r *http.Request)
 			f.log.Errorf("The flare failed to be created: %s", err)
 		} else {
 			f.log.Warnf("The flare failed to be created")
+			err = fmt.Errorf("the flare failed to be created")
 		}
-		http.Error(w, err.Error(), 500)
+		apiimplutils.WriteProblem(w, http.StatusInternalServerError, apiutil.ErrorCodeFlareFailed, err.Error())
+		return
 	}
 	w.Write([]byte(filePath))
 }
@@ -13,7 +13,10 @@ import (
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/status"
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
+	"github.com/DataDog/datadog-agent/pkg/config/remote/service"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/optional"
 	"go.uber.org/fx"
 )
 
@@ -24,6 +27,8 @@ type dependencies struct {
 	fx.In
 
 	Config config.Component
+	// RcService is only provided by the processes running the remote config service
+	RcService optional.Option[rcservice.Component] `optional:"true"`
 }
 
 type provides struct {
@@ -39,13 +44,15 @@ func Module() fxutil.Module {
 }
 
 type statusProvider struct {
-	Config config.Component
+	Config    config.Component
+	RcService optional.Option[rcservice.Component]
 }
 
 func newStatus(deps dependencies) provides {
 	return provides{
 		StatusProvider: status.NewInformationProvider(statusProvider{
-			Config: deps.Config,
+			Config:    deps.Config,
+			RcService: deps.RcService,
 		}),
 	}
 }
@@ -91,6 +98,7 @@ func (rc statusProvider) populateStatus(stats map[string]interface{}) {
 	if isRemoteConfigEnabled(rc.Config) && expvar.Get("remoteConfigStatus") != nil {
 		remoteConfigStatusJSON := expvar.Get("remoteConfigStatus").String()
 		json.Unmarshal([]byte(remoteConfigStatusJSON), &status) //nolint:errcheck
+		rc.populateProducts(status)
 	} else {
 		if !rc.Config.GetBool("remote_configuration.enabled") {
 			status["disabledReason"] = "it is explicitly disabled in the agent configuration. (`remote_configuration.enabled: false`)"
@@ -104,6 +112,37 @@ func (rc statusProvider) populateStatus(stats map[string]interface{}) {
 	stats["remoteConfiguration"] = status
 }
 
+// populateProducts adds the applied, pending and failed configurations of each product, so that users
+// can check why a configuration change didn't take effect
+func (rc statusProvider) populateProducts(status map[string]interface{}) {
+	rcService, isSet := rc.RcService.Get()
+	if !isSet || rcService == nil {
+		return
+	}
+	state, err := rcService.ConfigGetState()
+	if err != nil {
+		status["productsError"] = err.Error()
+		return
+	}
+	var products []map[string]interface{}
+	for _, product := range service.ProductsStatus(state) {
+		var failed []map[string]interface{}
+		for _, config := range product.Configs {
+			if config.State == service.ConfigFailed {
+				failed = append(failed, map[string]interface{}{"id": config.ID, "version": config.Version, "errors": config.Errors})
+			}
+		}
+		products = append(products, map[string]interface{}{
+			"product":       product.Product,
+			"applied":       product.Applied,
+			"pending":       product.Pending,
+			"failed":        product.Failed,
+			"failedConfigs": failed,
+		})
+	}
+	status["products"] = products
+}
+
 func isRemoteConfigEnabled(conf config.Component) bool {
 	// Disable Remote Config for GovCloud
 	if conf.GetBool("fips.enabled") || conf.GetString("site") == "ddog-gov.com" {
@@ -7,11 +7,17 @@ package rcstatusimpl
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	rcstate "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/optional"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 )
 
@@ -58,3 +64,40 @@ func TestStatusOuput(t *testing.T) {
 		})
 	}
 }
+
+type fakeRCService struct {
+	state *pbgo.GetStateConfigResponse
+}
+
+func (s fakeRCService) ClientGetConfigs(context.Context, *pbgo.ClientGetConfigsRequest) (*pbgo.ClientGetConfigsResponse, error) {
+	return nil, nil
+}
+
+func (s fakeRCService) ConfigGetState() (*pbgo.GetStateConfigResponse, error) {
+	return s.state, nil
+}
+
+func TestStatusProducts(t *testing.T) {
+	deps := fxutil.Test[dependencies](t, fx.Options(
+		config.MockModule(),
+		fx.Replace(config.MockParams{Overrides: map[string]interface{}{"remote_configuration.enabled": true}}),
+	))
+	rcService := fakeRCService{state: &pbgo.GetStateConfigResponse{
+		TargetFilenames: map[string]string{
+			"datadog/2/APM_TRACING/config-1/config": "hash1",
+			"datadog/2/APM_TRACING/config-2/config": "hash2",
+		},
+		ActiveClients: []*pbgo.Client{{
+			Id: "client-1",
+			State: &pbgo.ClientState{ConfigStates: []*pbgo.ConfigState{
+				{Id: "config-1", Version: 4, Product: "APM_TRACING", ApplyState: uint64(rcstate.ApplyStateError), ApplyError: "invalid config"},
+			}},
+		}},
+	}}
+	statusProvider := statusProvider{Config: deps.Config, RcService: optional.NewOption[rcservice.Component](rcService)}
+
+	b := new(bytes.Buffer)
+	require.NoError(t, statusProvider.Text(false, b))
+	assert.Contains(t, b.String(), "APM_TRACING: 0 applied, 1 pending, 1 failed")
+	assert.Contains(t, b.String(), "config-1 (version 4) failed: client-1: invalid config")
+}
@@ -64,6 +64,7 @@ type component struct{}
 type agent struct {
 	*pkgagent.Agent
 
+	ctx                context.Context
 	cancel             context.CancelFunc
 	config             config.Component
 	params             *Params
@@ -86,6 +87,7 @@ func NewAgent(deps dependencies) (traceagent.Component, error) {
 	}
 	ctx, cancel := context.WithCancel(deps.Context) // Several related non-components require a shared context to gracefully stop.
 	ag := &agent{
+		ctx:                ctx,
 		cancel:             cancel,
 		config:             deps.Config,
 		params:             deps.Params,
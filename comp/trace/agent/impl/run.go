@@ -8,6 +8,7 @@ package agent
 import (
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -73,6 +74,10 @@ func runAgentSidekicks(ag *agent) error {
 		log.Errorf("could not set auth token: %s", err)
 	} else {
 		ag.Agent.DebugServer.AddRoute("/config", ag.config.GetConfigHandler())
+		// the requests to the core agent API are authenticated with a client certificate of the trace-agent
+		if ipcAddress, err := coreconfig.GetIPCAddress(); err == nil {
+			apiutil.StartClientCertificateRotation(ag.ctx, coreconfig.Datadog(), net.JoinHostPort(ipcAddress, coreconfig.GetIPCPort()), "trace-agent")
+		}
 	}
 
 	api.AttachEndpoint(api.Endpoint{
@@ -14,8 +14,10 @@ import (
 
 	"github.com/DataDog/datadog-agent/comp/core/config"
 	"github.com/DataDog/datadog-agent/comp/core/log"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/statsd"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
 	updatercomp "github.com/DataDog/datadog-agent/comp/updater/updater"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/fleet/daemon"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"github.com/DataDog/datadog-agent/pkg/util/optional"
@@ -39,6 +41,7 @@ type dependencies struct {
 	Log          log.Component
 	Config       config.Component
 	RemoteConfig optional.Option[rcservice.Component]
+	Statsd       statsd.Component
 }
 
 func newUpdaterComponent(lc fx.Lifecycle, dependencies dependencies) (updatercomp.Component, error) {
@@ -46,7 +49,12 @@ func newUpdaterComponent(lc fx.Lifecycle, dependencies dependencies) (updatercom
 	if !ok {
 		return nil, errRemoteConfigRequired
 	}
-	daemon, err := daemon.NewDaemon(remoteConfig, dependencies.Config)
+	// the metrics of the daemon are sent to the dogstatsd server of the local agent
+	statsdClient, err := dependencies.Statsd.CreateForHostPort(pkgconfigsetup.GetBindHost(dependencies.Config), dependencies.Config.GetInt("dogstatsd_port"))
+	if err != nil {
+		return nil, fmt.Errorf("could not create statsd client: %w", err)
+	}
+	daemon, err := daemon.NewDaemon(remoteConfig, dependencies.Config, statsdClient)
 	if err != nil {
 		return nil, fmt.Errorf("could not create updater: %w", err)
 	}
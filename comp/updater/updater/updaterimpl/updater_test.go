@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/DataDog/datadog-agent/comp/core"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/statsd"
 	"github.com/DataDog/datadog-agent/comp/remote-config/rcservice"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 	"github.com/DataDog/datadog-agent/pkg/util/optional"
@@ -33,6 +34,7 @@ func TestUpdaterWithoutRemoteConfig(t *testing.T) {
 		core.MockBundle(),
 		fx.Supply(core.BundleParams{}),
 		fx.Supply(optional.NewNoneOption[rcservice.Component]()),
+		statsd.MockModule(),
 		Module(),
 	))
 	_, err := newUpdaterComponent(&mockLifecycle{}, deps.Dependencies)
@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agentrpc
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T) *Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("pong"))
+	})
+	server, err := NewServer("127.0.0.1:0", "server-token", handler, "127.0.0.1")
+	require.NoError(t, err)
+	server.Start()
+	t.Cleanup(func() { server.Stop() })
+	return server
+}
+
+func TestAgentRPC(t *testing.T) {
+	server := startTestServer(t)
+	url := "https://" + server.Addr().String() + "/ping"
+
+	t.Run("valid token and fingerprint", func(t *testing.T) {
+		client, err := NewClient("server-token", server.Fingerprint())
+		require.NoError(t, err)
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "pong", string(body))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		client, err := NewClient("other-token", server.Fingerprint())
+		require.NoError(t, err)
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		resp, err := (&http.Client{Transport: mustTransport(t, server.Fingerprint())}).Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("fingerprint mismatch", func(t *testing.T) {
+		other := startTestServer(t)
+		client, err := NewClient("server-token", other.Fingerprint())
+		require.NoError(t, err)
+		_, err = client.Get(url)
+		assert.ErrorContains(t, err, "doesn't match the pinned fingerprint")
+	})
+}
+
+func TestNewClientRequiresPinning(t *testing.T) {
+	_, err := NewClient("token", "")
+	assert.Error(t, err)
+	_, err = NewClient("", "fingerprint")
+	assert.Error(t, err)
+}
+
+// mustTransport returns the pinned transport of a client, without the auth token
+func mustTransport(t *testing.T, fingerprint string) http.RoundTripper {
+	client, err := NewClient("unused", fingerprint)
+	require.NoError(t, err)
+	return client.Transport.(*authTransport).next
+}
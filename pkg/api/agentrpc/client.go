@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agentrpc
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/api/security"
+)
+
+// NewClient returns an http client authenticating with the given token to a server whose
+// certificate matches the pinned fingerprint, see Server.Fingerprint.
+func NewClient(token string, fingerprint string) (*http.Client, error) {
+	if token == "" {
+		return nil, errors.New("an auth token is required")
+	}
+	fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	if fingerprint == "" {
+		return nil, errors.New("a certificate fingerprint is required")
+	}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			// The server certificate is self-signed: the chain can't be verified against a
+			// certificate authority, the certificate is checked against the pinned fingerprint instead.
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: verifyFingerprint(fingerprint),
+			MinVersion:            tls.VersionTLS12,
+		},
+	}
+	return &http.Client{
+		Transport: &authTransport{token: token, next: transport},
+	}, nil
+}
+
+func verifyFingerprint(fingerprint string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no server certificate presented")
+		}
+		actual := security.CertificateFingerprint(rawCerts[0])
+		if subtle.ConstantTimeCompare([]byte(actual), []byte(fingerprint)) != 1 {
+			return fmt.Errorf("server certificate fingerprint %s doesn't match the pinned fingerprint", actual)
+		}
+		return nil
+	}
+}
+
+// authTransport sets the auth token on the requests
+type authTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,13 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package agentrpc implements an authenticated transport between agent processes,
+// e.g. the cluster agent querying the node agents.
+//
+// The server serves its handler over TLS with a self-signed certificate and only
+// accepts requests carrying the shared bearer token. The client doesn't rely on a
+// certificate authority: it pins the fingerprint of the server certificate, which is
+// exchanged out of band along with the token.
+package agentrpc
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agentrpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/api/security"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Server serves a handler to other agent processes
+type Server struct {
+	listener    net.Listener
+	server      *http.Server
+	fingerprint string
+}
+
+// NewServer listens on addr and returns a server authenticating requests with the given
+// token. The self-signed certificate of the server is valid for the given hosts, its
+// fingerprint must be shared with the clients.
+func NewServer(addr string, token string, handler http.Handler, hosts ...string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("an auth token is required")
+	}
+	cert, _, err := security.GenerateSelfSignedTLS(hosts)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen to address %s: %v", addr, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return &Server{
+		listener: tls.NewListener(listener, tlsConfig),
+		server: &http.Server{
+			Handler:           validateToken(token, handler),
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+		fingerprint: security.CertificateFingerprint(cert.Certificate[0]),
+	}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Fingerprint returns the fingerprint of the server certificate, to be pinned by the clients
+func (s *Server) Fingerprint() string {
+	return s.fingerprint
+}
+
+// Start serves the requests in the background
+func (s *Server) Start() {
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("agent RPC server on %s stopped: %v", s.listener.Addr(), err)
+		}
+	}()
+}
+
+// Stop gracefully stops the server
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func validateToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, tok, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || scheme != "Bearer" {
+			log.Warnf("missing auth token for %s request to %s", r.Method, r.RequestURI)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Datadog Agent"`)
+			http.Error(w, "no session token provided", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(token)) != 1 {
+			log.Warnf("invalid auth token for %s request to %s", r.Method, r.RequestURI)
+			http.Error(w, "invalid session token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package security
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// GenerateSelfSignedTLS generates a self-signed TLS certificate valid for the given hosts
+// and a pool trusting it
+func GenerateSelfSignedTLS(hosts []string) (*tls.Certificate, *x509.CertPool, error) {
+	_, rootCertPEM, rootKey, err := GenerateRootCert(hosts, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate certificate: %v", err)
+	}
+
+	// PEM encode the private key
+	rootKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rootKey),
+	})
+
+	pair, err := tls.X509KeyPair(rootCertPEM, rootKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate TLS key pair: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(rootCertPEM); !ok {
+		return nil, nil, fmt.Errorf("unable to add new certificate to pool")
+	}
+
+	return &pair, certPool, nil
+}
+
+// CertificateFingerprint returns the hex encoded SHA-256 digest of a DER encoded certificate,
+// used to pin the certificate of a peer
+func CertificateFingerprint(certDER []byte) string {
+	sum := sha256.Sum256(certDER)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// ClientCertificateRoute is the route of the agent API issuing client certificates
+const ClientCertificateRoute = "/agent/certificate"
+
+// clientCertificateRetryDelay is the delay before requesting a client certificate again after a failure,
+// e.g. while the agent is starting
+const clientCertificateRetryDelay = 30 * time.Second
+
+var (
+	clientCertLock sync.RWMutex
+	clientCert     *tls.Certificate
+	// renewClientCert wakes the rotation up when the certificate isn't trusted anymore, e.g. once the
+	// agent restarted with a new authority
+	renewClientCert = make(chan struct{}, 1)
+)
+
+// ClientCertificate is the response of the agent API to a client certificate request
+type ClientCertificate struct {
+	// Certificate is the PEM encoded client certificate
+	Certificate string `json:"certificate"`
+	// CA is the PEM encoded certificate of the authority that issued it
+	CA string `json:"ca"`
+}
+
+// RequestClientCertificate generates a key pair and requests the agent API listening on
+// addr to certify it for the given identity, e.g. trace-agent.
+// The request is authenticated with the auth token, the returned certificate then identifies
+// the process to the agent API in place of the token until it expires, see Leaf.NotAfter.
+func RequestClientCertificate(ctx context.Context, c *http.Client, addr string, identity string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate key: %v", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: identity},
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create certificate request: %v", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s%s", addr, ClientCertificateRoute), bytes.NewReader(csrPEM))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-pem-file")
+	req.Header.Set("Authorization", "Bearer "+GetAuthToken())
+	r, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode >= 400 {
+		return nil, fmt.Errorf("unable to get a client certificate: %s", body)
+	}
+
+	var resp ClientCertificate
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unable to parse client certificate response: %v", err)
+	}
+	block, _ := pem.Decode([]byte(resp.Certificate))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid client certificate in response")
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client certificate: %v", err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{block.Bytes},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// GetClientCertificate returns the client certificate of the process, see tls.Config.GetClientCertificate.
+// No certificate is presented if the process doesn't hold a valid one issued by an authority the server
+// accepts, its requests are then authenticated with the auth token only.
+func GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	clientCertLock.RLock()
+	defer clientCertLock.RUnlock()
+	if clientCert == nil || time.Now().After(clientCert.Leaf.NotAfter) {
+		return &tls.Certificate{}, nil
+	}
+	if err := info.SupportsCertificate(clientCert); err != nil {
+		select {
+		case renewClientCert <- struct{}{}:
+		default:
+		}
+		return &tls.Certificate{}, nil
+	}
+	return clientCert, nil
+}
+
+func setClientCertificate(cert *tls.Certificate) {
+	clientCertLock.Lock()
+	defer clientCertLock.Unlock()
+	clientCert = cert
+}
+
+// StartClientCertificateRotation requests a client certificate for the given identity from the agent
+// API listening on addr, and renews it ahead of its expiry until the context is cancelled. The
+// certificate is presented by the clients of GetClient(false) and NewIPCEndpoint.
+func StartClientCertificateRotation(ctx context.Context, config model.Reader, addr string, identity string) {
+	go func() {
+		// the renewals are authenticated with the current certificate
+		client := GetClient(false)
+		for {
+			delay := clientCertificateRetryDelay
+			cert, err := requestClientCertificate(ctx, config, client, addr, identity)
+			if err != nil {
+				log.Debugf("unable to get a client certificate for %s, retrying in %s: %v", identity, delay, err)
+			} else {
+				setClientCertificate(cert)
+				delay = clientCertificateRenewalDelay(cert.Leaf)
+				log.Debugf("got a client certificate for %s valid until %s", identity, cert.Leaf.NotAfter)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			case <-renewClientCert:
+			}
+		}
+	}()
+}
+
+// requestClientCertificate requests a client certificate, the auth token is read first if it isn't yet
+// as the agent may create it after the process starts
+func requestClientCertificate(ctx context.Context, config model.Reader, c *http.Client, addr string, identity string) (*tls.Certificate, error) {
+	if err := SetAuthToken(config); err != nil {
+		return nil, err
+	}
+	return RequestClientCertificate(ctx, c, addr, identity)
+}
+
+// clientCertificateRenewalDelay returns the delay before renewing a certificate, once two thirds of its
+// lifetime elapsed
+func clientCertificateRenewalDelay(leaf *x509.Certificate) time.Duration {
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return time.Until(leaf.NotBefore.Add(lifetime * 2 / 3))
+}
@@ -8,7 +8,6 @@ package util
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"io"
 	"net/http"
 )
@@ -40,7 +39,10 @@ func GetClient(verify bool) *http.Client {
 	}
 
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify:   true,
+			GetClientCertificate: GetClientCertificate,
+		},
 	}
 
 	return &http.Client{Transport: tr}
@@ -81,7 +83,7 @@ func DoGetWithOptions(c *http.Client, url string, options *ReqOptions) (body []b
 		return body, e
 	}
 	if r.StatusCode >= 400 {
-		return body, fmt.Errorf("%s", body)
+		return body, errorResponse(r, body)
 	}
 	return body, nil
 }
@@ -105,7 +107,7 @@ func DoPost(c *http.Client, url string, contentType string, body io.Reader) (res
 		return resp, e
 	}
 	if r.StatusCode >= 400 {
-		return resp, fmt.Errorf("%s", resp)
+		return resp, errorResponse(r, resp)
 	}
 	return resp, nil
 }
@@ -44,6 +44,21 @@ func TestDoGet(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("problem response", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ProblemContentType)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"config value 'foo' does not exist","code":"config_not_found"}`))
+		}
+		server := makeTestServer(t, http.HandlerFunc(handler))
+		_, err := DoGetWithOptions(server.Client(), server.URL, &ReqOptions{})
+		require.EqualError(t, err, "config value 'foo' does not exist")
+		var problem *Problem
+		require.ErrorAs(t, err, &problem)
+		require.Equal(t, ErrorCodeConfigNotFound, problem.Code)
+		require.Equal(t, http.StatusNotFound, problem.Status)
+	})
+
 	t.Run("url error", func(t *testing.T) {
 		_, err := DoGetWithOptions(http.DefaultClient, " http://localhost", &ReqOptions{})
 		require.Error(t, err)
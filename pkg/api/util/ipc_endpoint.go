@@ -124,7 +124,10 @@ func NewIPCEndpoint(config config.Component, endpointPath string, options ...End
 		return nil, fmt.Errorf("%s: %s", cmdHostKey, err)
 	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify:   true,
+			GetClientCertificate: GetClientCertificate,
+		},
 	}
 	client := &http.Client{Transport: tr}
 
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ProblemContentType is the content type of the problem details (RFC 7807) error responses
+const ProblemContentType = "application/problem+json"
+
+// ErrorCode is the machine-readable code of an error response of the agent APIs, so that the CLI
+// and automation don't have to parse the error messages
+type ErrorCode string
+
+const (
+	// ErrorCodeInvalidRequest is the code of a malformed request
+	ErrorCodeInvalidRequest ErrorCode = "invalid_request"
+	// ErrorCodeInternal is the code of an unexpected failure of the server
+	ErrorCodeInternal ErrorCode = "internal_error"
+	// ErrorCodeConfigNotAllowed is the code of a request for a config value that isn't exposed
+	ErrorCodeConfigNotAllowed ErrorCode = "config_not_allowed"
+	// ErrorCodeConfigNotFound is the code of a request for a config value that doesn't exist
+	ErrorCodeConfigNotFound ErrorCode = "config_not_found"
+	// ErrorCodeFlareFailed is the code of a flare that couldn't be created
+	ErrorCodeFlareFailed ErrorCode = "flare_failed"
+)
+
+// Problem is a problem details (RFC 7807) error response, extended with the code of the error.
+// It's returned as the error of the requests answered with one.
+type Problem struct {
+	Type   string    `json:"type"`
+	Title  string    `json:"title"`
+	Status int       `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+	Code   ErrorCode `json:"code"`
+}
+
+// Error returns the detail of the problem, the error messages are the ones of the plain text responses
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// errorResponse returns the error of a response with an error status, decoded from the problem
+// details if the server answered with them
+func errorResponse(r *http.Response, body []byte) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == ProblemContentType {
+		var problem Problem
+		if err := json.Unmarshal(body, &problem); err == nil {
+			return &problem
+		}
+	}
+	return fmt.Errorf("%s", body)
+}
@@ -12,12 +12,28 @@ const (
 	// EnabledLabelKey pod label to disable/enable mutations at the pod level.
 	EnabledLabelKey = "admission.datadoghq.com/enabled"
 
+	// AllEnabledAnnotationKey pod annotation to opt a workload out of every mutation: it takes
+	// precedence over EnabledLabelKey and the configuration of each webhook.
+	AllEnabledAnnotationKey = "admission.datadoghq.com/all.enabled"
+
 	// InjectionModeLabelKey pod label to chose the config injection at the pod level.
 	InjectionModeLabelKey = "admission.datadoghq.com/config.mode"
 
 	// LibVersionAnnotKeyFormat is the format of the library version annotation
 	LibVersionAnnotKeyFormat = "admission.datadoghq.com/%s-lib.version"
 
+	// InjectionHashAnnotKey pod annotation recording a hash of the libraries injected by the auto
+	// instrumentation webhook, used to detect pods injected with an outdated configuration.
+	InjectionHashAnnotKey = "admission.datadoghq.com/injection-hash"
+
+	// LanguageDetectionDeferredAnnotKey pod annotation set by the auto instrumentation webhook when it
+	// defers the injection to its reinvocation as the languages of the pod aren't detected yet.
+	LanguageDetectionDeferredAnnotKey = "admission.datadoghq.com/language-detection-deferred"
+
+	// InjectionRestartAnnotKey pod template annotation recording the injection hash a workload was
+	// restarted for by the injection drift controller.
+	InjectionRestartAnnotKey = "admission.datadoghq.com/injection-restarted-for"
+
 	// LibConfigV1AnnotKeyFormat is the format of the library config annotation
 	LibConfigV1AnnotKeyFormat = "admission.datadoghq.com/%s-lib.config.v1"
 )
@@ -25,6 +25,7 @@ const (
 	InvalidInput         = "invalid_input"
 	InternalError        = "internal_error"
 	ConfigInjectionError = "config_injection_error"
+	PatchTooLarge        = "patch_too_large"
 )
 
 // Status tags
@@ -47,6 +48,10 @@ var (
 	MutationAttempts = telemetry.NewGaugeWithOpts("admission_webhooks", "mutation_attempts",
 		[]string{"mutation_type", "status", "injected", "error"}, "Number of pod mutation attempts by mutation type",
 		telemetry.Options{NoDoubleUnderscoreSep: true})
+	PatchSize = telemetry.NewHistogramWithOpts("admission_webhooks", "patch_size",
+		[]string{"mutation_type"}, "Size distribution of the JSON patches of the pod mutations (in bytes).",
+		prometheus.ExponentialBuckets(256, 4, 8), // from 256B to 4MiB
+		telemetry.Options{NoDoubleUnderscoreSep: true})
 	WebhooksReceived = telemetry.NewCounterWithOpts("admission_webhooks", "webhooks_received",
 		[]string{"mutation_type"}, "Number of mutation webhook requests received.",
 		telemetry.Options{NoDoubleUnderscoreSep: true})
@@ -99,4 +104,10 @@ var (
 	PatchErrors = telemetry.NewCounterWithOpts("admission_webhooks", "patcher_errors",
 		[]string{}, "Number of patch errors.",
 		telemetry.Options{NoDoubleUnderscoreSep: true})
+	InjectionDrifts = telemetry.NewGaugeWithOpts("admission_webhooks", "injection_drifts",
+		[]string{}, "Number of pods injected with an outdated auto instrumentation configuration.",
+		telemetry.Options{NoDoubleUnderscoreSep: true})
+	InjectionRestarts = telemetry.NewCounterWithOpts("admission_webhooks", "injection_restarts",
+		[]string{"kind", "status"}, "Number of rolling restarts triggered to inject the current auto instrumentation configuration.",
+		telemetry.Options{NoDoubleUnderscoreSep: true})
 )
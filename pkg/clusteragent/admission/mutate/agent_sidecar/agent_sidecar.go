@@ -48,14 +48,15 @@ type Webhook struct {
 	operations        []admiv1.OperationType
 	namespaceSelector *metav1.LabelSelector
 	objectSelector    *metav1.LabelSelector
-	containerRegistry string
+	registries        *common.ImageRegistries
+	jobHandling       *jobHandling
 }
 
 // NewWebhook returns a new Webhook
 func NewWebhook() *Webhook {
 	nsSelector, objSelector := labelSelectors()
 
-	containerRegistry := common.ContainerRegistry("admission_controller.agent_sidecar.container_registry")
+	registries := common.NewImageRegistries("admission_controller.agent_sidecar.container_registry")
 
 	return &Webhook{
 		name:              webhookName,
@@ -65,7 +66,8 @@ func NewWebhook() *Webhook {
 		operations:        []admiv1.OperationType{admiv1.Create},
 		namespaceSelector: nsSelector,
 		objectSelector:    objSelector,
-		containerRegistry: containerRegistry,
+		registries:        registries,
+		jobHandling:       newJobHandling(),
 	}
 }
 
@@ -112,20 +114,30 @@ func (w *Webhook) mutate(request *admission.MutateRequest) ([]byte, error) {
 	return common.Mutate(request.Raw, request.Namespace, w.Name(), w.injectAgentSidecar, request.DynamicClient)
 }
 
-func (w *Webhook) injectAgentSidecar(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool, error) {
+func (w *Webhook) injectAgentSidecar(pod *corev1.Pod, ns string, _ dynamic.Interface) (bool, error) {
 	if pod == nil {
 		return false, errors.New(metrics.InvalidInput)
 	}
 
-	agentSidecarExists := slices.ContainsFunc(pod.Spec.Containers, func(cont corev1.Container) bool {
+	isAgentSidecar := func(cont corev1.Container) bool {
 		return cont.Name == agentSidecarContainerName
-	})
+	}
+	// The agent sidecar is an init container when injected as a native sidecar
+	if slices.ContainsFunc(pod.Spec.InitContainers, isAgentSidecar) {
+		return false, nil
+	}
+	agentSidecarExists := slices.ContainsFunc(pod.Spec.Containers, isAgentSidecar)
 
 	podUpdated := false
 
 	if !agentSidecarExists {
-		agentSidecarContainer := getDefaultSidecarTemplate(w.containerRegistry)
+		registry := w.registries.ForNamespace(pod.Namespace)
+		agentSidecarContainer := getDefaultSidecarTemplate(registry.Registry)
+		if registry.PullPolicy != "" {
+			agentSidecarContainer.ImagePullPolicy = registry.PullPolicy
+		}
 		pod.Spec.Containers = append(pod.Spec.Containers, *agentSidecarContainer)
+		_ = common.InjectImagePullSecrets(pod, registry.PullSecrets)
 		podUpdated = true
 	}
 
@@ -150,6 +162,14 @@ func (w *Webhook) injectAgentSidecar(pod *corev1.Pod, _ string, _ dynamic.Interf
 		}
 	}
 
+	// The pods of Jobs are handled last as the agent sidecar may be moved to
+	// the init containers
+	if pod.Namespace != "" {
+		ns = pod.Namespace
+	}
+	updated = w.jobHandling.apply(pod, ns)
+	podUpdated = podUpdated || updated
+
 	return podUpdated, nil
 }
 
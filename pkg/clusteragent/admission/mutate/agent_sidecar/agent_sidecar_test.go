@@ -414,6 +414,40 @@ func TestDefaultSidecarTemplateAgentImage(t *testing.T) {
 	}
 }
 
+func TestInjectAgentSidecarNamespaceRegistry(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{"internal": {"registry": "registry.internal", "image_pull_secrets": ["regcred"], "image_pull_policy": "Always"}}`)
+
+	webhook := NewWebhook()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-name",
+			Namespace: "internal",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "container-name"},
+			},
+		},
+	}
+	injected, err := webhook.injectAgentSidecar(pod, "", nil)
+	assert.NoError(t, err)
+	assert.True(t, injected)
+	assert.Equal(t, "registry.internal/agent:latest", pod.Spec.Containers[1].Image)
+	assert.Equal(t, corev1.PullAlways, pod.Spec.Containers[1].ImagePullPolicy)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "regcred"}}, pod.Spec.ImagePullSecrets)
+
+	pod.Namespace = "default"
+	pod.Spec.Containers = pod.Spec.Containers[:1]
+	pod.Spec.ImagePullSecrets = nil
+	_, err = webhook.injectAgentSidecar(pod, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%s/agent:latest", commonRegistry), pod.Spec.Containers[1].Image)
+	assert.Equal(t, corev1.PullIfNotPresent, pod.Spec.Containers[1].ImagePullPolicy)
+	assert.Empty(t, pod.Spec.ImagePullSecrets)
+}
+
 func TestDefaultSidecarTemplateClusterAgentEnvVars(t *testing.T) {
 
 	tests := []struct {
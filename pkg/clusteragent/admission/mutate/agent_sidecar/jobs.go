@@ -0,0 +1,261 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package agentsidecar
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/apiserver"
+	apiCommon "github.com/DataDog/datadog-agent/pkg/util/kubernetes/apiserver/common"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/pointer"
+)
+
+////////////////////////////////
+//                            //
+//        Job Handling        //
+//                            //
+////////////////////////////////
+
+// The agent sidecar never exits on its own, which prevents the pods of Jobs
+// from completing once their containers are done. Two ways of terminating it
+// are supported:
+//   - native: the agent sidecar is injected as a native sidecar container, an
+//     init container with the "Always" restart policy, which Kubernetes stops
+//     once the other containers have exited. It requires Kubernetes 1.28+.
+//   - shim: the containers of the pod are wrapped so that they leave a marker
+//     in a shared volume when they exit, and the agent sidecar is wrapped so
+//     that it stops once all the markers are there. It requires the
+//     containers to define their command and their images to ship /bin/sh.
+const (
+	jobHandlingModeAuto   = "auto"
+	jobHandlingModeNative = "native"
+	jobHandlingModeShim   = "shim"
+)
+
+// nativeSidecarMinorVersion is the first minor version of Kubernetes 1.x
+// supporting native sidecar containers
+const nativeSidecarMinorVersion = 28
+
+const jobTerminationDir = "/var/run/datadog-job"
+
+// jobTerminationVolumeName is the name of the volume shared between the agent
+// sidecar and the containers of the pod in shim mode
+const jobTerminationVolumeName = "datadog-job-termination"
+
+// jobHandling terminates the agent sidecar in the pods of Jobs
+type jobHandling struct {
+	enabled    bool
+	mode       string
+	namespaces []string
+	// serverVersion returns the version of the Kubernetes server, it is only
+	// called in auto mode
+	serverVersion func() (*version.Info, error)
+}
+
+func newJobHandling() *jobHandling {
+	mode := config.Datadog().GetString("admission_controller.agent_sidecar.job_handling.mode")
+	switch mode {
+	case jobHandlingModeAuto, jobHandlingModeNative, jobHandlingModeShim:
+	default:
+		log.Errorf("unknown agent sidecar job handling mode %q, using %q", mode, jobHandlingModeAuto)
+		mode = jobHandlingModeAuto
+	}
+
+	return &jobHandling{
+		enabled:       config.Datadog().GetBool("admission_controller.agent_sidecar.job_handling.enabled"),
+		mode:          mode,
+		namespaces:    config.Datadog().GetStringSlice("admission_controller.agent_sidecar.job_handling.namespaces"),
+		serverVersion: kubeServerVersion,
+	}
+}
+
+func kubeServerVersion() (*version.Info, error) {
+	apiCl, err := apiserver.GetAPIClient()
+	if err != nil {
+		return nil, err
+	}
+	return apiCommon.KubeServerVersion(apiCl.Cl.Discovery(), 5*time.Second)
+}
+
+// enabledForNamespace returns whether the pods of Jobs are handled in the
+// given namespace. All namespaces are handled when none is configured.
+func (j *jobHandling) enabledForNamespace(ns string) bool {
+	if !j.enabled {
+		return false
+	}
+	return len(j.namespaces) == 0 || slices.Contains(j.namespaces, ns)
+}
+
+// apply makes the agent sidecar of the pod terminate once the other containers
+// have exited, if the pod belongs to a Job. It returns a boolean that indicates
+// if the pod was mutated.
+func (j *jobHandling) apply(pod *corev1.Pod, ns string) bool {
+	if !isJobPod(pod) || !j.enabledForNamespace(ns) {
+		return false
+	}
+
+	if j.useNativeSidecar() {
+		return injectAsNativeSidecar(pod)
+	}
+
+	mutated, err := injectTerminationShim(pod)
+	if err != nil {
+		log.Warnf("Cannot handle the termination of the agent sidecar of job pod %s: %v", common.PodString(pod), err)
+	}
+	return mutated
+}
+
+func (j *jobHandling) useNativeSidecar() bool {
+	switch j.mode {
+	case jobHandlingModeNative:
+		return true
+	case jobHandlingModeShim:
+		return false
+	}
+
+	serverVersion, err := j.serverVersion()
+	if err != nil {
+		log.Warnf("Cannot get Kubernetes version, falling back to the agent sidecar termination shim: %v", err)
+		return false
+	}
+	supported, err := supportsNativeSidecars(serverVersion)
+	if err != nil {
+		log.Warnf("Falling back to the agent sidecar termination shim: %v", err)
+		return false
+	}
+	return supported
+}
+
+// supportsNativeSidecars returns whether the Kubernetes version supports
+// native sidecar containers
+func supportsNativeSidecars(v *version.Info) (bool, error) {
+	major, err := strconv.Atoi(v.Major)
+	if err != nil {
+		return false, fmt.Errorf("cannot parse server major version %q: %w", v.Major, err)
+	}
+	// Minor versions can have a suffix, e.g. "28+" on EKS
+	minor, err := strconv.Atoi(strings.TrimRight(v.Minor, "+"))
+	if err != nil {
+		return false, fmt.Errorf("cannot parse server minor version %q: %w", v.Minor, err)
+	}
+	return major > 1 || (major == 1 && minor >= nativeSidecarMinorVersion), nil
+}
+
+// isJobPod returns whether the pod is owned by a Job
+func isJobPod(pod *corev1.Pod) bool {
+	return slices.ContainsFunc(pod.GetOwnerReferences(), func(owner metav1.OwnerReference) bool {
+		return owner.Kind == "Job" && strings.HasPrefix(owner.APIVersion, "batch/")
+	})
+}
+
+// injectAsNativeSidecar moves the agent sidecar to the init containers of the
+// pod with the "Always" restart policy. It returns a boolean that indicates if
+// the pod was mutated.
+func injectAsNativeSidecar(pod *corev1.Pod) bool {
+	i := slices.IndexFunc(pod.Spec.Containers, func(cont corev1.Container) bool {
+		return cont.Name == agentSidecarContainerName
+	})
+	if i < 0 {
+		return false
+	}
+
+	agentContainer := pod.Spec.Containers[i]
+	agentContainer.RestartPolicy = pointer.Ptr(corev1.ContainerRestartPolicyAlways)
+	pod.Spec.Containers = slices.Delete(pod.Spec.Containers, i, i+1)
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, agentContainer)
+	return true
+}
+
+// injectTerminationShim wraps the commands of the containers of the pod so
+// that they leave a marker in a shared volume when they exit, and the command
+// of the agent sidecar so that it stops once all the markers are there. When
+// the pod is restarted on failure, markers are only left by the containers
+// that succeeded. It returns a boolean that indicates if the pod was mutated.
+func injectTerminationShim(pod *corev1.Pod) (bool, error) {
+	agentIndex := -1
+	var appContainers []string
+	for i, cont := range pod.Spec.Containers {
+		if cont.Name == agentSidecarContainerName {
+			agentIndex = i
+			continue
+		}
+		if len(cont.Command) == 0 {
+			return false, fmt.Errorf("container %s doesn't define its command", cont.Name)
+		}
+		appContainers = append(appContainers, cont.Name)
+	}
+	if agentIndex < 0 || len(appContainers) == 0 {
+		return false, nil
+	}
+	if slices.ContainsFunc(pod.Spec.Volumes, func(vol corev1.Volume) bool {
+		return vol.Name == jobTerminationVolumeName
+	}) {
+		// The shim was already injected
+		return false, nil
+	}
+
+	volume := corev1.Volume{
+		Name: jobTerminationVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+	volumeMount := corev1.VolumeMount{
+		Name:      jobTerminationVolumeName,
+		MountPath: jobTerminationDir,
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+
+	onlyOnSuccess := pod.Spec.RestartPolicy == corev1.RestartPolicyOnFailure
+	for i := range pod.Spec.Containers {
+		cont := &pod.Spec.Containers[i]
+		cont.VolumeMounts = append(cont.VolumeMounts, volumeMount)
+		if i == agentIndex {
+			cont.Command = []string{"/bin/sh", "-c", agentShimScript(len(appContainers))}
+			cont.Args = nil
+			continue
+		}
+		cont.Command = append([]string{"/bin/sh", "-c", appShimScript(cont.Name, onlyOnSuccess), "datadog-job-shim"}, cont.Command...)
+	}
+
+	return true, nil
+}
+
+// appShimScript runs the command of the container, passed as arguments, and
+// leaves a marker once it exits
+func appShimScript(containerName string, onlyOnSuccess bool) string {
+	marker := fmt.Sprintf("%s/%s.done", jobTerminationDir, containerName)
+	if onlyOnSuccess {
+		return fmt.Sprintf(`"$@"; rc=$?; if [ $rc -eq 0 ]; then touch %s; fi; exit $rc`, marker)
+	}
+	return fmt.Sprintf(`"$@"; rc=$?; touch %s; exit $rc`, marker)
+}
+
+// agentShimScript runs the agent and stops it once the given number of markers
+// have been left by the other containers
+func agentShimScript(containers int) string {
+	return fmt.Sprintf(`/bin/entrypoint.sh & agent=$!
+while [ "$(ls %[1]s | grep -c '\.done$')" -lt %[2]d ]; do
+  if ! kill -0 $agent 2>/dev/null; then wait $agent; exit $?; fi
+  sleep 1
+done
+kill -TERM $agent
+wait $agent
+exit 0`, jobTerminationDir, containers)
+}
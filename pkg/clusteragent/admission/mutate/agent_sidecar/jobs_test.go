@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package agentsidecar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func jobPod(namespace string, containers ...corev1.Container) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "job-pod",
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "Job", Name: "job"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    containers,
+		},
+	}
+}
+
+func TestInjectAgentSidecarJobNative(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.enabled", true)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.mode", "native")
+
+	webhook := NewWebhook()
+	pod := jobPod("default", corev1.Container{Name: "job"})
+
+	injected, err := webhook.injectAgentSidecar(pod, "default", nil)
+	require.NoError(t, err)
+	assert.True(t, injected)
+	require.Len(t, pod.Spec.Containers, 1)
+	assert.Equal(t, "job", pod.Spec.Containers[0].Name)
+	require.Len(t, pod.Spec.InitContainers, 1)
+	assert.Equal(t, agentSidecarContainerName, pod.Spec.InitContainers[0].Name)
+	require.NotNil(t, pod.Spec.InitContainers[0].RestartPolicy)
+	assert.Equal(t, corev1.ContainerRestartPolicyAlways, *pod.Spec.InitContainers[0].RestartPolicy)
+
+	// the agent sidecar isn't injected twice
+	injected, err = webhook.injectAgentSidecar(pod, "default", nil)
+	require.NoError(t, err)
+	assert.False(t, injected)
+	assert.Len(t, pod.Spec.Containers, 1)
+	assert.Len(t, pod.Spec.InitContainers, 1)
+}
+
+func TestInjectAgentSidecarJobShim(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.enabled", true)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.mode", "shim")
+
+	webhook := NewWebhook()
+	pod := jobPod("default", corev1.Container{Name: "job", Command: []string{"/job"}, Args: []string{"--run"}})
+
+	injected, err := webhook.injectAgentSidecar(pod, "default", nil)
+	require.NoError(t, err)
+	assert.True(t, injected)
+	assert.Empty(t, pod.Spec.InitContainers)
+	require.Len(t, pod.Spec.Containers, 2)
+
+	job := pod.Spec.Containers[0]
+	assert.Equal(t, []string{"/bin/sh", "-c", appShimScript("job", false), "datadog-job-shim", "/job"}, job.Command)
+	assert.Equal(t, []string{"--run"}, job.Args)
+	assert.Contains(t, job.VolumeMounts, corev1.VolumeMount{Name: jobTerminationVolumeName, MountPath: jobTerminationDir})
+
+	agent := pod.Spec.Containers[1]
+	assert.Equal(t, agentSidecarContainerName, agent.Name)
+	assert.Equal(t, []string{"/bin/sh", "-c", agentShimScript(1)}, agent.Command)
+	assert.Contains(t, agent.VolumeMounts, corev1.VolumeMount{Name: jobTerminationVolumeName, MountPath: jobTerminationDir})
+
+	// the shim isn't injected twice
+	injected, err = webhook.injectAgentSidecar(pod, "default", nil)
+	require.NoError(t, err)
+	assert.False(t, injected)
+	assert.Equal(t, []string{"/bin/sh", "-c", appShimScript("job", false), "datadog-job-shim", "/job"}, pod.Spec.Containers[0].Command)
+}
+
+func TestInjectAgentSidecarJobShimWithoutCommand(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.enabled", true)
+	mockConfig.SetWithoutSource("admission_controller.agent_sidecar.job_handling.mode", "shim")
+
+	webhook := NewWebhook()
+	pod := jobPod("default", corev1.Container{Name: "job"})
+
+	injected, err := webhook.injectAgentSidecar(pod, "default", nil)
+	require.NoError(t, err)
+	assert.True(t, injected)
+	assert.Empty(t, pod.Spec.Volumes)
+	assert.Empty(t, pod.Spec.Containers[0].Command)
+	assert.Empty(t, pod.Spec.Containers[1].Command)
+}
+
+func TestJobHandlingApply(t *testing.T) {
+	agentContainer := func() corev1.Container { return corev1.Container{Name: agentSidecarContainerName} }
+
+	tests := []struct {
+		name          string
+		handling      jobHandling
+		pod           *corev1.Pod
+		ns            string
+		expectNative  bool
+		expectMutated bool
+	}{
+		{
+			name:     "disabled",
+			handling: jobHandling{mode: jobHandlingModeNative},
+			pod:      jobPod("default", agentContainer()),
+			ns:       "default",
+		},
+		{
+			name:     "not a job",
+			handling: jobHandling{enabled: true, mode: jobHandlingModeNative},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"}},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{agentContainer()}},
+			},
+			ns: "default",
+		},
+		{
+			name:     "namespace not handled",
+			handling: jobHandling{enabled: true, mode: jobHandlingModeNative, namespaces: []string{"batch"}},
+			pod:      jobPod("default", agentContainer()),
+			ns:       "default",
+		},
+		{
+			name:          "namespace handled",
+			handling:      jobHandling{enabled: true, mode: jobHandlingModeNative, namespaces: []string{"batch"}},
+			pod:           jobPod("batch", agentContainer()),
+			ns:            "batch",
+			expectNative:  true,
+			expectMutated: true,
+		},
+		{
+			name: "auto on 1.28",
+			handling: jobHandling{enabled: true, mode: jobHandlingModeAuto, serverVersion: func() (*version.Info, error) {
+				return &version.Info{Major: "1", Minor: "28+"}, nil
+			}},
+			pod:           jobPod("default", agentContainer()),
+			ns:            "default",
+			expectNative:  true,
+			expectMutated: true,
+		},
+		{
+			name: "auto on 1.27",
+			handling: jobHandling{enabled: true, mode: jobHandlingModeAuto, serverVersion: func() (*version.Info, error) {
+				return &version.Info{Major: "1", Minor: "27"}, nil
+			}},
+			pod:           jobPod("default", agentContainer(), corev1.Container{Name: "job", Command: []string{"/job"}}),
+			ns:            "default",
+			expectMutated: true,
+		},
+		{
+			name: "auto without server version",
+			handling: jobHandling{enabled: true, mode: jobHandlingModeAuto, serverVersion: func() (*version.Info, error) {
+				return nil, errors.New("unreachable")
+			}},
+			pod:           jobPod("default", agentContainer(), corev1.Container{Name: "job", Command: []string{"/job"}}),
+			ns:            "default",
+			expectMutated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mutated := test.handling.apply(test.pod, test.ns)
+			assert.Equal(t, test.expectMutated, mutated)
+			if test.expectNative {
+				assert.Len(t, test.pod.Spec.InitContainers, 1)
+			} else {
+				assert.Empty(t, test.pod.Spec.InitContainers)
+			}
+		})
+	}
+}
+
+func TestAppShimScript(t *testing.T) {
+	assert.Equal(t, `"$@"; rc=$?; touch /var/run/datadog-job/job.done; exit $rc`, appShimScript("job", false))
+	assert.Equal(t, `"$@"; rc=$?; if [ $rc -eq 0 ]; then touch /var/run/datadog-job/job.done; fi; exit $rc`, appShimScript("job", true))
+}
+
+func TestSupportsNativeSidecars(t *testing.T) {
+	tests := []struct {
+		version   version.Info
+		supported bool
+		wantErr   bool
+	}{
+		{version: version.Info{Major: "1", Minor: "27"}, supported: false},
+		{version: version.Info{Major: "1", Minor: "28"}, supported: true},
+		{version: version.Info{Major: "1", Minor: "30+"}, supported: true},
+		{version: version.Info{Major: "2", Minor: "0"}, supported: true},
+		{version: version.Info{Major: "1", Minor: "x"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		supported, err := supportsNativeSidecars(&test.version)
+		if test.wantErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.supported, supported, "%s.%s", test.version.Major, test.version.Minor)
+	}
+}
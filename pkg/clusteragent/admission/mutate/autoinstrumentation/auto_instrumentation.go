@@ -142,9 +142,18 @@ type Webhook struct {
 	resources         []string
 	operations        []admiv1.OperationType
 	filter            *containers.Filter
-	containerRegistry string
-	pinnedLibraries   []libInfo
+	registries        *mutatecommon.ImageRegistries
+	propagation       *propagationConfigs
+	pinnedLibVersions map[language]string
 	wmeta             workloadmeta.Component
+
+	// pinDigests is whether the injected images are pinned to their digests, unless overridden
+	// for the namespace
+	pinDigests bool
+	digests    *digestResolver
+
+	// languageDetectionFallback is what is injected in the pods whose languages aren't detected
+	languageDetectionFallback languageDetectionFallback
 }
 
 // NewWebhook returns a new Webhook
@@ -154,7 +163,17 @@ func NewWebhook(wmeta workloadmeta.Component) (*Webhook, error) {
 		return nil, err
 	}
 
-	containerRegistry := mutatecommon.ContainerRegistry("admission_controller.auto_instrumentation.container_registry")
+	registries := mutatecommon.NewImageRegistries("admission_controller.auto_instrumentation.container_registry")
+	propagation := newPropagationConfigs()
+
+	fallback, err := parseLanguageDetectionFallback(config.Datadog().GetString("admission_controller.auto_instrumentation.language_detection_fallback"))
+	if err != nil {
+		log.Errorf("Invalid admission_controller.auto_instrumentation.language_detection_fallback, using %s: %v", fallbackInjectAll, err)
+		fallback = fallbackInjectAll
+	}
+	if fallback == fallbackRetry && strings.EqualFold(config.Datadog().GetString("admission_controller.reinvocation_policy"), "never") {
+		log.Warnf("The %s language detection fallback requires the IfNeeded reinvocation policy, the pods whose languages aren't detected won't be injected", fallbackRetry)
+	}
 
 	return &Webhook{
 		name:              webhookName,
@@ -163,9 +182,14 @@ func NewWebhook(wmeta workloadmeta.Component) (*Webhook, error) {
 		resources:         []string{"pods"},
 		operations:        []admiv1.OperationType{admiv1.Create},
 		filter:            filter,
-		containerRegistry: containerRegistry,
-		pinnedLibraries:   getPinnedLibraries(containerRegistry),
+		registries:        registries,
+		propagation:       propagation,
+		pinnedLibVersions: getPinnedLibVersions(),
 		wmeta:             wmeta,
+		pinDigests:        config.Datadog().GetBool("admission_controller.auto_instrumentation.pin_image_digests.enabled"),
+		digests:           newDigestResolver(config.Datadog().GetDuration("admission_controller.auto_instrumentation.pin_image_digests.cache_ttl")),
+
+		languageDetectionFallback: fallback,
 	}, nil
 }
 
@@ -294,16 +318,13 @@ func (w *Webhook) inject(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool,
 	if pod == nil {
 		return false, errors.New(metrics.InvalidInput)
 	}
+	if mutatecommon.IsOptedOut(pod) {
+		log.Debugf("Skipping auto instrumentation of pod %q due to annotation", mutatecommon.PodString(pod))
+		return false, nil
+	}
 	injectApmTelemetryConfig(pod)
 
-	if w.isEnabledInNamespace(pod.Namespace) {
-		// if Single Step Instrumentation is enabled, pods can still opt out using the label
-		if pod.GetLabels()[common.EnabledLabelKey] == "false" {
-			log.Debugf("Skipping single step instrumentation of pod %q due to label", mutatecommon.PodString(pod))
-			return false, nil
-		}
-	} else if !mutatecommon.ShouldMutatePod(pod) {
-		log.Debugf("Skipping auto instrumentation of pod %q because pod mutation is not allowed", mutatecommon.PodString(pod))
+	if !w.isPodAllowed(pod) {
 		return false, nil
 	}
 	for _, lang := range supportedLanguages {
@@ -320,26 +341,78 @@ func (w *Webhook) inject(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool,
 		return false, nil
 	}
 	injectSecurityClientLibraryConfig(pod)
+	injectPropagationConfig(pod, w.propagation.forNamespace(pod.Namespace))
 	// Inject env variables used for Onboarding KPIs propagation
-	var injectionType string
-	if w.isEnabledInNamespace(pod.Namespace) {
+	injectionType := w.injectionType(pod.Namespace)
+	if injectionType == singleStepInstrumentationInstallType {
 		// if Single Step Instrumentation is enabled, inject DD_INSTRUMENTATION_INSTALL_TYPE:k8s_single_step
 		_ = mutatecommon.InjectEnv(pod, singleStepInstrumentationInstallTypeEnvVar)
-		injectionType = singleStepInstrumentationInstallType
 	} else {
 		// if local library injection is enabled, inject DD_INSTRUMENTATION_INSTALL_TYPE:k8s_lib_injection
 		_ = mutatecommon.InjectEnv(pod, localLibraryInstrumentationInstallTypeEnvVar)
-		injectionType = localLibraryInstrumentationInstallType
 	}
 
 	if err := w.injectAutoInstruConfig(pod, libsToInject, autoDetected, injectionType); err != nil {
 		log.Errorf("failed to inject auto instrumentation configurations: %v", err)
 		return false, errors.New(metrics.ConfigInjectionError)
 	}
+	registry := w.registries.ForNamespace(pod.Namespace)
+	applyImageRegistry(pod, registry)
+	w.pinImageDigests(pod, registry)
+
+	// Record what was injected so that pods injected with an outdated policy can be detected
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[common.InjectionHashAnnotKey] = injectionHash(libsToInject, injectionType)
 
 	return true, nil
 }
 
+// isPodAllowed returns whether the webhook is allowed to inject libraries in the pod, according to
+// its labels, its owner and the namespaces where Single Step Instrumentation is enabled
+func (w *Webhook) isPodAllowed(pod *corev1.Pod) bool {
+	if w.isEnabledInNamespace(pod.Namespace) {
+		// if Single Step Instrumentation is enabled, pods can still opt out using the label
+		if pod.GetLabels()[common.EnabledLabelKey] == "false" {
+			log.Debugf("Skipping single step instrumentation of pod %q due to label", mutatecommon.PodString(pod))
+			return false
+		}
+		// as well as through the kind of their owner, unless explicitly enabled
+		if mutate, matched := mutatecommon.OwnerKindDecision(pod); matched && !mutate && pod.GetLabels()[common.EnabledLabelKey] != "true" {
+			log.Debugf("Skipping single step instrumentation of pod %q due to its owner", mutatecommon.PodString(pod))
+			return false
+		}
+	} else if !mutatecommon.ShouldMutatePod(pod) {
+		log.Debugf("Skipping auto instrumentation of pod %q because pod mutation is not allowed", mutatecommon.PodString(pod))
+		return false
+	}
+	return true
+}
+
+// injectionType returns the value of DD_INSTRUMENTATION_INSTALL_TYPE for the pods of the namespace
+func (w *Webhook) injectionType(ns string) string {
+	if w.isEnabledInNamespace(ns) {
+		return singleStepInstrumentationInstallType
+	}
+	return localLibraryInstrumentationInstallType
+}
+
+// applyImageRegistry sets the pull policy of the injected init containers and
+// attaches the image pull secrets needed to pull them
+func applyImageRegistry(pod *corev1.Pod, registry mutatecommon.ImageRegistry) {
+	if registry.PullPolicy != "" {
+		for i, ctr := range pod.Spec.InitContainers {
+			for _, lang := range supportedLanguages {
+				if ctr.Name == initContainerName(lang) {
+					pod.Spec.InitContainers[i].ImagePullPolicy = registry.PullPolicy
+				}
+			}
+		}
+	}
+	_ = mutatecommon.InjectImagePullSecrets(pod, registry.PullSecrets)
+}
+
 // The config for the security products has three states: <unset> | true | false.
 // This is because the products themselves have treat these cases differently:
 // * <unset> - product disactivated but can be activated remotely
@@ -384,34 +457,46 @@ func injectApmTelemetryConfig(pod *corev1.Pod) {
 // getLibrariesToInjectForApmInstrumentation returns the list of tracing libraries to inject, when APM Instrumentation is enabled
 // - if apm_config.instrumentation.lib_versions set, returns only tracing libraries from apm_config.instrumentation.lib_versions
 // - if language detection is on and can detect the apps' languages, returns only auto-detected languages
+// - if language detection is on but the languages aren't known yet, returns the libraries of the configured fallback
 // - otherwise returns all tracing libraries supported by APM Instrumentation
-func (w *Webhook) getLibrariesToInjectForApmInstrumentation(pod *corev1.Pod) ([]libInfo, bool) {
+func (w *Webhook) getLibrariesToInjectForApmInstrumentation(pod *corev1.Pod, registry string) ([]libInfo, bool) {
 	autoDetected := false
 
 	// Pinned tracing libraries in APM Instrumentation configuration
-	libsToInject := w.pinnedLibraries
+	libsToInject := w.getPinnedLibraries(registry)
 	if len(libsToInject) > 0 {
 		return libsToInject, autoDetected
 	}
 
 	// Tracing libraries for language detection
-	libsToInject = w.getLibrariesLanguageDetection(pod)
+	libsToInject = w.getLibrariesLanguageDetection(pod, registry)
 	if len(libsToInject) > 0 {
 		autoDetected = true
 		return libsToInject, autoDetected
 	}
+	if config.Datadog().GetBool("admission_controller.auto_instrumentation.inject_auto_detected_libraries") {
+		return w.getFallbackLibraries(pod, registry), autoDetected
+	}
 
 	// Latest tracing libraries for all supported languages (java, js, dotnet, python, ruby)
-	libsToInject = w.getAllLatestLibraries()
+	libsToInject = getAllLatestLibraries(registry)
 
 	return libsToInject, autoDetected
 }
 
 // getPinnedLibraries returns tracing libraries to inject as configured by apm_config.instrumentation.lib_versions
-func getPinnedLibraries(registry string) []libInfo {
+func (w *Webhook) getPinnedLibraries(registry string) []libInfo {
 	var res []libInfo
+	for lang, version := range w.pinnedLibVersions {
+		res = append(res, libInfo{lang: lang, image: libImageName(registry, lang, version)})
+	}
+	return res
+}
+
+// getPinnedLibVersions returns the tracing library versions configured by apm_config.instrumentation.lib_versions
+func getPinnedLibVersions() map[language]string {
+	res := map[language]string{}
 
-	var libVersion string
 	singleStepLibraryVersions := config.Datadog().GetStringMapString("apm_config.instrumentation.lib_versions")
 
 	// If APM Instrumentation is enabled and configuration apm_config.instrumentation.lib_versions specified, inject only the libraries from the configuration
@@ -421,8 +506,7 @@ func getPinnedLibraries(registry string) []libInfo {
 			continue
 		}
 		log.Infof("Library version %s is specified for language %s", version, lang)
-		libVersion = version
-		res = append(res, libInfo{lang: language(lang), image: libImageName(registry, language(lang), libVersion)})
+		res[language(lang)] = version
 	}
 
 	return res
@@ -430,21 +514,21 @@ func getPinnedLibraries(registry string) []libInfo {
 
 // getLibrariesLanguageDetection runs process language auto-detection and returns languages to inject for APM Instrumentation.
 // The langages information is available in workloadmeta-store and attached on the pod's owner.
-func (w *Webhook) getLibrariesLanguageDetection(pod *corev1.Pod) []libInfo {
+func (w *Webhook) getLibrariesLanguageDetection(pod *corev1.Pod, registry string) []libInfo {
 	if config.Datadog().GetBool("admission_controller.auto_instrumentation.inject_auto_detected_libraries") {
 		// Use libraries returned by language detection for APM Instrumentation
-		return w.getAutoDetectedLibraries(pod)
+		return w.getAutoDetectedLibraries(pod, registry)
 	}
 
 	return []libInfo{}
 }
 
 // getAllLatestLibraries returns all supported by APM Instrumentation tracing libraries
-func (w *Webhook) getAllLatestLibraries() []libInfo {
+func getAllLatestLibraries(registry string) []libInfo {
 	libsToInject := []libInfo{}
 
 	for _, lang := range supportedLanguages {
-		libsToInject = append(libsToInject, libInfo{lang: language(lang), image: libImageName(w.containerRegistry, lang, "latest")})
+		libsToInject = append(libsToInject, libInfo{lang: language(lang), image: libImageName(registry, lang, "latest")})
 	}
 
 	return libsToInject
@@ -461,10 +545,11 @@ type libInfo struct {
 func (w *Webhook) extractLibInfo(pod *corev1.Pod) ([]libInfo, bool) {
 	var libInfoList []libInfo
 	var autoDetected = false
+	registry := w.registries.ForNamespace(pod.Namespace).Registry
 
 	// The library version specified via annotation on the Pod takes precedence over libraries injected with Single Step Instrumentation
 	if ShouldInject(pod, w.wmeta) {
-		libInfoList = w.extractLibrariesFromAnnotations(pod)
+		libInfoList = w.extractLibrariesFromAnnotations(pod, registry)
 		if len(libInfoList) > 0 {
 			return libInfoList, autoDetected
 		}
@@ -472,7 +557,7 @@ func (w *Webhook) extractLibInfo(pod *corev1.Pod) ([]libInfo, bool) {
 
 	// Get libraries to inject for APM Instrumentation
 	if w.isEnabledInNamespace(pod.Namespace) {
-		libInfoList, autoDetected = w.getLibrariesToInjectForApmInstrumentation(pod)
+		libInfoList, autoDetected = w.getLibrariesToInjectForApmInstrumentation(pod, registry)
 		if len(libInfoList) > 0 {
 			return libInfoList, autoDetected
 		}
@@ -488,7 +573,7 @@ func (w *Webhook) extractLibInfo(pod *corev1.Pod) ([]libInfo, bool) {
 		if version != "latest" {
 			log.Warnf("Ignoring version %q. To inject all libs, the only supported version is latest for now", version)
 		}
-		libInfoList = w.getAllLatestLibraries()
+		libInfoList = getAllLatestLibraries(registry)
 	}
 
 	return libInfoList, autoDetected
@@ -496,7 +581,7 @@ func (w *Webhook) extractLibInfo(pod *corev1.Pod) ([]libInfo, bool) {
 
 // getAutoDetectedLibraries constructs the libraries to be injected if the languages
 // were stored in workloadmeta store based on owner annotations (for example: Deployment, Daemonset, Statefulset).
-func (w *Webhook) getAutoDetectedLibraries(pod *corev1.Pod) []libInfo {
+func (w *Webhook) getAutoDetectedLibraries(pod *corev1.Pod, registry string) []libInfo {
 	libList := []libInfo{}
 
 	ownerName, ownerKind, found := getOwnerNameAndKind(pod)
@@ -512,7 +597,7 @@ func (w *Webhook) getAutoDetectedLibraries(pod *corev1.Pod) []libInfo {
 	// Currently we only support deployments
 	switch ownerKind {
 	case "Deployment":
-		libList = getLibListFromDeploymentAnnotations(store, ownerName, pod.Namespace, w.containerRegistry)
+		libList = getLibListFromDeploymentAnnotations(store, ownerName, pod.Namespace, registry)
 	default:
 		log.Debugf("This ownerKind:%s is not yet supported by the process language auto-detection feature", ownerKind)
 	}
@@ -520,7 +605,7 @@ func (w *Webhook) getAutoDetectedLibraries(pod *corev1.Pod) []libInfo {
 	return libList
 }
 
-func (w *Webhook) extractLibrariesFromAnnotations(pod *corev1.Pod) []libInfo {
+func (w *Webhook) extractLibrariesFromAnnotations(pod *corev1.Pod, registry string) []libInfo {
 	annotations := pod.Annotations
 	libList := []libInfo{}
 	for _, lang := range supportedLanguages {
@@ -535,7 +620,7 @@ func (w *Webhook) extractLibrariesFromAnnotations(pod *corev1.Pod) []libInfo {
 
 		libVersionAnnotation := strings.ToLower(fmt.Sprintf(libVersionAnnotationKeyFormat, lang))
 		if version, found := annotations[libVersionAnnotation]; found {
-			image := fmt.Sprintf("%s/dd-lib-%s-init:%s", w.containerRegistry, lang, version)
+			image := fmt.Sprintf("%s/dd-lib-%s-init:%s", registry, lang, version)
 			log.Debugf(
 				"Found %s library annotation for version %s, will overwrite %s injected with Single Step Instrumentation",
 				string(lang), version, image,
@@ -555,7 +640,7 @@ func (w *Webhook) extractLibrariesFromAnnotations(pod *corev1.Pod) []libInfo {
 
 			libVersionAnnotation := strings.ToLower(fmt.Sprintf(libVersionAnnotationKeyCtrFormat, ctr.Name, lang))
 			if version, found := annotations[libVersionAnnotation]; found {
-				image := libImageName(w.containerRegistry, lang, version)
+				image := libImageName(registry, lang, version)
 				log.Debugf(
 					"Found version library annotation for %s, will inject %s to container %s",
 					string(lang), image, ctr.Name,
@@ -570,6 +655,11 @@ func (w *Webhook) extractLibrariesFromAnnotations(pod *corev1.Pod) []libInfo {
 
 // ShouldInject returns true if Admission Controller should inject standard tags, APM configs and APM libraries
 func ShouldInject(pod *corev1.Pod, wmeta workloadmeta.Component) bool {
+	// The opt-out annotation applies to every webhook, whatever the labels
+	if mutatecommon.IsOptedOut(pod) {
+		return false
+	}
+
 	// If a pod explicitly sets the label admission.datadoghq.com/enabled, make a decision based on its value
 	if val, found := pod.GetLabels()[common.EnabledLabelKey]; found {
 		switch val {
@@ -582,18 +672,22 @@ func ShouldInject(pod *corev1.Pod, wmeta workloadmeta.Component) bool {
 		}
 	}
 
+	if mutate, matched := mutatecommon.OwnerKindDecision(pod); matched {
+		return mutate
+	}
+
 	apmWebhook, err := GetWebhook(wmeta)
 	if err != nil {
-		return config.Datadog().GetBool("admission_controller.mutate_unlabelled")
+		return config.Datadog().Features().Enabled(config.FeatureMutateUnlabelled)
 	}
 
-	return apmWebhook.isEnabledInNamespace(pod.Namespace) || config.Datadog().GetBool("admission_controller.mutate_unlabelled")
+	return apmWebhook.isEnabledInNamespace(pod.Namespace) || config.Datadog().Features().Enabled(config.FeatureMutateUnlabelled)
 }
 
 // isEnabledInNamespace indicates if Single Step Instrumentation is enabled for
 // the namespace in the cluster
 func (w *Webhook) isEnabledInNamespace(namespace string) bool {
-	apmInstrumentationEnabled := config.Datadog().GetBool("apm_config.instrumentation.enabled")
+	apmInstrumentationEnabled := config.Datadog().Features().Enabled(config.FeatureAPMInstrumentation)
 
 	if !apmInstrumentationEnabled {
 		log.Debugf("APM Instrumentation is disabled")
@@ -586,6 +586,44 @@ func TestExtractLibInfo(t *testing.T) {
 				mockConfig.SetWithoutSource("admission_controller.mutate_unlabelled", true)
 			},
 		},
+		{
+			name: "java from namespace registry",
+			pod: func() *corev1.Pod {
+				pod := common.FakePodWithAnnotation("admission.datadoghq.com/java-lib.version", "v1")
+				pod.Namespace = "internal"
+				return pod
+			}(),
+			containerRegistry: "registry",
+			expectedLibsToInject: []libInfo{
+				{
+					lang:  "java",
+					image: "registry.internal/dd-lib-java-init:v1",
+				},
+			},
+			setupConfig: func() {
+				mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{"internal": {"registry": "registry.internal"}}`)
+			},
+		},
+		{
+			name: "single step instrumentation with pinned java version from namespace registry",
+			pod: func() *corev1.Pod {
+				pod := common.FakePod("java-pod")
+				pod.Namespace = "internal"
+				return pod
+			}(),
+			containerRegistry: "registry",
+			expectedLibsToInject: []libInfo{
+				{
+					lang:  "java",
+					image: "registry.internal/dd-lib-java-init:v1.20.0",
+				},
+			},
+			setupConfig: func() {
+				mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true)
+				mockConfig.SetWithoutSource("apm_config.instrumentation.lib_versions", map[string]string{"java": "v1.20.0"})
+				mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{"internal": {"registry": "registry.internal"}}`)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1446,6 +1484,21 @@ func TestInjectAutoInstrumentation(t *testing.T) {
 			wantErr:                   false,
 			setupConfig:               func() { mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true) },
 		},
+		{
+			name: "Single Step Instrumentation: opt out with annotation",
+			pod: common.FakePodWithParent(
+				"ns",
+				map[string]string{
+					"admission.datadoghq.com/all.enabled":      "false",
+					"admission.datadoghq.com/java-lib.version": "latest",
+				}, map[string]string{
+					"admission.datadoghq.com/enabled": "true",
+				}, []corev1.EnvVar{}, "replicaset", "test-deployment-123"),
+			expectedEnvs:              nil,
+			expectedInjectedLibraries: map[string]string{},
+			wantErr:                   false,
+			setupConfig:               func() { mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true) },
+		},
 		{
 			name: "Single Step Instrumentation: default service name for ReplicaSet",
 			pod: common.FakePodWithParent(
@@ -2121,6 +2174,24 @@ func TestShouldInject(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "instrumentation on, label enabled, opted out with annotation",
+			pod: common.WithAnnotations(
+				common.FakePodWithNamespaceAndLabel("ns", "admission.datadoghq.com/enabled", "true"),
+				map[string]string{"admission.datadoghq.com/all.enabled": "false"},
+			),
+			setupConfig: func() { mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true) },
+			want:        false,
+		},
+		{
+			name: "instrumentation on, invalid opt out annotation",
+			pod: common.WithAnnotations(
+				common.FakePodWithNamespaceAndLabel("ns", "", ""),
+				map[string]string{"admission.datadoghq.com/all.enabled": "no"},
+			),
+			setupConfig: func() { mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true) },
+			want:        true,
+		},
 		{
 			name:        "instrumentation off, label enabled",
 			pod:         common.FakePodWithNamespaceAndLabel("ns", "admission.datadoghq.com/enabled", "true"),
@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/sync/singleflight"
+	corev1 "k8s.io/api/core/v1"
+
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// digestResolutionTimeout bounds the resolution of an image digest, as it delays the admission of the pod
+	digestResolutionTimeout = 2 * time.Second
+	// digestFailureTTL is how long a failed resolution is cached, so that an unreachable registry
+	// doesn't delay the admission of every pod
+	digestFailureTTL = time.Minute
+)
+
+// digestResolver resolves the digests of the images injected in the pods, so that the injected
+// images are pinned and immune to tag mutations. Resolutions are cached by the cluster agent.
+type digestResolver struct {
+	m     sync.Mutex
+	ttl   time.Duration
+	cache map[string]digestResolution
+	// resolutions deduplicates the concurrent resolutions of an image
+	resolutions singleflight.Group
+
+	// resolve is overridden in tests
+	resolve func(ctx context.Context, image string) (string, error)
+}
+
+type digestResolution struct {
+	digest  string
+	err     error
+	expires time.Time
+}
+
+func newDigestResolver(ttl time.Duration) *digestResolver {
+	return &digestResolver{
+		ttl:     ttl,
+		cache:   make(map[string]digestResolution),
+		resolve: resolveRegistryDigest,
+	}
+}
+
+// resolveRegistryDigest returns the digest of an image from its registry. The registries are
+// reached with the credentials of the cluster agent, not the image pull secrets of the pods.
+func resolveRegistryDigest(ctx context.Context, image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("could not parse image %s: %w", image, err)
+	}
+	desc, err := remote.Head(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", fmt.Errorf("could not get the digest of image %s: %w", image, err)
+	}
+	return desc.Digest.String(), nil
+}
+
+// pinnedImage returns the image pinned to its digest, the image is returned as is if it's already
+// pinned or if its digest can't be resolved
+func (r *digestResolver) pinnedImage(image string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	digest, err := r.digest(image)
+	if err != nil {
+		log.Warnf("Injecting image %s without pinning its digest: %v", image, err)
+		return image
+	}
+	return image + "@" + digest
+}
+
+// digest returns the digest of an image. The concurrent resolutions of an image are deduplicated so
+// that the admissions of pods injected with the same image don't all resolve it, while the images
+// of other pods are resolved independently.
+func (r *digestResolver) digest(image string) (string, error) {
+	if resolution, ok := r.cachedResolution(image); ok {
+		return resolution.digest, resolution.err
+	}
+	resolution, _, _ := r.resolutions.Do(image, func() (interface{}, error) {
+		// the image may have been resolved while waiting for a previous resolution to complete
+		if resolution, ok := r.cachedResolution(image); ok {
+			return resolution, nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), digestResolutionTimeout)
+		defer cancel()
+		digest, err := r.resolve(ctx, image)
+		now := time.Now()
+		resolution := digestResolution{digest: digest, err: err, expires: now.Add(r.ttl)}
+		if err != nil {
+			resolution.expires = now.Add(digestFailureTTL)
+		}
+		r.m.Lock()
+		r.cache[image] = resolution
+		r.m.Unlock()
+		return resolution, nil
+	})
+	return resolution.(digestResolution).digest, resolution.(digestResolution).err
+}
+
+// cachedResolution returns the resolution of an image if it's cached and not expired
+func (r *digestResolver) cachedResolution(image string) (digestResolution, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	resolution, ok := r.cache[image]
+	if !ok || !time.Now().Before(resolution.expires) {
+		return digestResolution{}, false
+	}
+	return resolution, true
+}
+
+// pinImageDigests pins the images of the injected init containers to their digests, if enabled
+// for the namespace of the pod
+func (w *Webhook) pinImageDigests(pod *corev1.Pod, registry mutatecommon.ImageRegistry) {
+	enabled := w.pinDigests
+	if registry.PinDigests != nil {
+		enabled = *registry.PinDigests
+	}
+	if !enabled {
+		return
+	}
+	for i, ctr := range pod.Spec.InitContainers {
+		for _, lang := range supportedLanguages {
+			if ctr.Name == initContainerName(lang) {
+				pod.Spec.InitContainers[i].Image = w.digests.pinnedImage(ctr.Image)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core"
+	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
+	workloadmetafxmock "github.com/DataDog/datadog-agent/comp/core/workloadmeta/fx-mock"
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+const testDigest = "sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+
+func TestResolveRegistryDigest(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	image := strings.TrimPrefix(s.URL, "http://") + "/dd-lib-java-init:v1"
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	expectedDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	digest, err := resolveRegistryDigest(context.Background(), image)
+	require.NoError(t, err)
+	assert.Equal(t, expectedDigest.String(), digest)
+
+	_, err = resolveRegistryDigest(context.Background(), strings.TrimPrefix(s.URL, "http://")+"/dd-lib-java-init:missing")
+	assert.Error(t, err)
+}
+
+func TestDigestResolver(t *testing.T) {
+	resolutions := 0
+	r := newDigestResolver(time.Hour)
+	r.resolve = func(_ context.Context, image string) (string, error) {
+		resolutions++
+		if strings.HasSuffix(image, ":missing") {
+			return "", errors.New("manifest unknown")
+		}
+		return testDigest, nil
+	}
+
+	image := libImageName(commonRegistry, java, "v1")
+	assert.Equal(t, image+"@"+testDigest, r.pinnedImage(image))
+	// resolutions are cached
+	assert.Equal(t, image+"@"+testDigest, r.pinnedImage(image))
+	assert.Equal(t, 1, resolutions)
+
+	// images whose digest can't be resolved are injected unpinned
+	missing := libImageName(commonRegistry, java, "missing")
+	assert.Equal(t, missing, r.pinnedImage(missing))
+	assert.Equal(t, missing, r.pinnedImage(missing))
+	assert.Equal(t, 2, resolutions)
+
+	// pinned images are left untouched
+	pinned := "registry.internal/dd-lib-java-init@" + testDigest
+	assert.Equal(t, pinned, r.pinnedImage(pinned))
+	assert.Equal(t, 2, resolutions)
+
+	// resolutions expire
+	r.ttl = 0
+	r.cache = map[string]digestResolution{}
+	r.pinnedImage(image)
+	r.pinnedImage(image)
+	assert.Equal(t, 4, resolutions)
+}
+
+func TestDigestResolverConcurrentResolutions(t *testing.T) {
+	var resolutions atomic.Int32
+	slowImage := libImageName(commonRegistry, java, "slow")
+	unblock := make(chan struct{})
+	r := newDigestResolver(time.Hour)
+	r.resolve = func(_ context.Context, image string) (string, error) {
+		resolutions.Add(1)
+		if image == slowImage {
+			<-unblock
+		}
+		return testDigest, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, slowImage+"@"+testDigest, r.pinnedImage(slowImage))
+		}()
+	}
+	// a slow registry doesn't delay the resolution of the other images
+	assert.Eventually(t, func() bool { return resolutions.Load() > 0 }, time.Second, time.Millisecond)
+	image := libImageName(commonRegistry, python, "v1")
+	assert.Equal(t, image+"@"+testDigest, r.pinnedImage(image))
+
+	// the concurrent resolutions of an image are deduplicated
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, int32(2), resolutions.Load())
+}
+
+func TestInjectPinsImageDigests(t *testing.T) {
+	wmeta := fxutil.Test[workloadmeta.Component](t, core.MockBundle(), workloadmetafxmock.MockModule(), fx.Supply(workloadmeta.NewParams()))
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true)
+	mockConfig.SetWithoutSource("apm_config.instrumentation.lib_versions", map[string]string{"java": "v1"})
+	mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.pin_image_digests.enabled", true)
+	mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{"unpinned": {"pin_digests": false}}`)
+	webhook, err := NewWebhook(wmeta)
+	require.NoError(t, err)
+	webhook.digests.resolve = func(context.Context, string) (string, error) {
+		return testDigest, nil
+	}
+
+	pod := common.FakePod("app")
+	pod.Namespace = "apps"
+	injected, err := webhook.inject(pod, "apps", nil)
+	require.NoError(t, err)
+	require.True(t, injected)
+	require.Len(t, pod.Spec.InitContainers, 1)
+	assert.Equal(t, libImageName(commonRegistry, java, "v1")+"@"+testDigest, pod.Spec.InitContainers[0].Image)
+
+	// the namespace opts out of the pinning
+	pod = common.FakePod("app")
+	pod.Namespace = "unpinned"
+	injected, err = webhook.inject(pod, "unpinned", nil)
+	require.NoError(t, err)
+	require.True(t, injected)
+	require.Len(t, pod.Spec.InitContainers, 1)
+	assert.Equal(t, libImageName(commonRegistry, java, "v1"), pod.Spec.InitContainers[0].Image)
+}
@@ -0,0 +1,241 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/common"
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/metrics"
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const restartedAtAnnotKey = "kubectl.kubernetes.io/restartedAt"
+
+// injectionHash returns a hash of the libraries injected in a pod, which changes when the
+// configuration of the webhook would inject different ones, for instance after a library upgrade
+func injectionHash(libs []libInfo, injectionType string) string {
+	entries := make([]string, 0, len(libs))
+	for _, lib := range libs {
+		entries = append(entries, fmt.Sprintf("%s/%s=%s", lib.ctrName, lib.lang, lib.image))
+	}
+	sort.Strings(entries)
+	hash := sha256.Sum256([]byte(injectionType + "\n" + strings.Join(entries, "\n")))
+	return hex.EncodeToString(hash[:8])
+}
+
+// expectedInjectionHash returns the injection hash of the pod with the current configuration of the
+// webhook, empty if no library would be injected
+func (w *Webhook) expectedInjectionHash(pod *corev1.Pod) string {
+	if mutatecommon.IsOptedOut(pod) || !w.isPodAllowed(pod) {
+		return ""
+	}
+	libs, _ := w.extractLibInfo(pod)
+	if len(libs) == 0 {
+		return ""
+	}
+	return injectionHash(libs, w.injectionType(pod.Namespace))
+}
+
+// workloadRef references the workload owning a pod
+type workloadRef struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (r workloadRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.kind, r.namespace, r.name)
+}
+
+// DriftController periodically looks for the pods injected by the auto instrumentation webhook
+// whose injected libraries no longer match the current configuration, for instance after a
+// library upgrade. It can trigger a rolling restart of the workloads owning them so that their
+// pods are injected again.
+type DriftController struct {
+	webhook        *Webhook
+	client         kubernetes.Interface
+	podLister      corelisters.PodLister
+	podsSynced     cache.InformerSynced
+	isLeaderFunc   func() bool
+	interval       time.Duration
+	rollingRestart bool
+}
+
+// NewDriftController returns a new DriftController
+func NewDriftController(webhook *Webhook, client kubernetes.Interface, podInformer coreinformers.PodInformer, isLeaderFunc func() bool, interval time.Duration, rollingRestart bool) *DriftController {
+	return &DriftController{
+		webhook:        webhook,
+		client:         client,
+		podLister:      podInformer.Lister(),
+		podsSynced:     podInformer.Informer().HasSynced,
+		isLeaderFunc:   isLeaderFunc,
+		interval:       interval,
+		rollingRestart: rollingRestart,
+	}
+}
+
+// Run starts the controller, it blocks until stopCh is closed
+func (c *DriftController) Run(stopCh <-chan struct{}) {
+	log.Infof("Starting injection drift controller (interval: %s, rolling restart: %t)", c.interval, c.rollingRestart)
+	defer log.Info("Stopping injection drift controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.podsSynced) {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !c.isLeaderFunc() {
+				continue
+			}
+			if err := c.check(context.Background()); err != nil {
+				log.Warnf("Error checking injection drifts: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// check looks for the drifted pods, restarting the workloads owning them if enabled
+func (c *DriftController) check(ctx context.Context) error {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	restarted := make(map[workloadRef]struct{})
+	for _, pod := range pods {
+		hash, found := pod.Annotations[common.InjectionHashAnnotKey]
+		if !found || pod.DeletionTimestamp != nil {
+			continue
+		}
+		expected := c.webhook.expectedInjectionHash(pod)
+		if expected == hash {
+			continue
+		}
+		drifted++
+		log.Debugf("Pod %s was injected with hash %q, the current configuration gives %q", mutatecommon.PodString(pod), hash, expected)
+
+		if !c.rollingRestart {
+			continue
+		}
+		workload, err := c.owningWorkload(ctx, pod)
+		if err != nil {
+			log.Debugf("Cannot restart pod %s: %v", mutatecommon.PodString(pod), err)
+			continue
+		}
+		if _, done := restarted[workload]; done {
+			continue
+		}
+		restarted[workload] = struct{}{}
+		if err := c.restart(ctx, workload, expected); err != nil {
+			metrics.InjectionRestarts.Inc(workload.kind, metrics.StatusError)
+			log.Warnf("Cannot restart %s: %v", workload, err)
+		}
+	}
+
+	metrics.InjectionDrifts.Set(float64(drifted))
+	if drifted > 0 {
+		log.Infof("Found %d pods injected with an outdated auto instrumentation configuration", drifted)
+	}
+	return nil
+}
+
+// owningWorkload returns the workload owning the pod, following the replica set of deployments
+func (c *DriftController) owningWorkload(ctx context.Context, pod *corev1.Pod) (workloadRef, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return workloadRef{}, fmt.Errorf("pod has no controller")
+	}
+	switch owner.Kind {
+	case "StatefulSet", "DaemonSet":
+		return workloadRef{kind: owner.Kind, namespace: pod.Namespace, name: owner.Name}, nil
+	case "ReplicaSet":
+		rs, err := c.client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return workloadRef{}, err
+		}
+		if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == "Deployment" {
+			return workloadRef{kind: rsOwner.Kind, namespace: pod.Namespace, name: rsOwner.Name}, nil
+		}
+	}
+	return workloadRef{}, fmt.Errorf("owner %s %s can't be restarted", owner.Kind, owner.Name)
+}
+
+// restart triggers a rolling restart of the workload, the way kubectl rollout restart does. The
+// hash it is restarted for is recorded on the pod template so that it's restarted only once while
+// the rollout is in progress.
+func (c *DriftController) restart(ctx context.Context, workload workloadRef, expected string) error {
+	var templateAnnotations map[string]string
+	switch workload.kind {
+	case "Deployment":
+		obj, err := c.client.AppsV1().Deployments(workload.namespace).Get(ctx, workload.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		templateAnnotations = obj.Spec.Template.Annotations
+	case "StatefulSet":
+		obj, err := c.client.AppsV1().StatefulSets(workload.namespace).Get(ctx, workload.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		templateAnnotations = obj.Spec.Template.Annotations
+	case "DaemonSet":
+		obj, err := c.client.AppsV1().DaemonSets(workload.namespace).Get(ctx, workload.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		templateAnnotations = obj.Spec.Template.Annotations
+	default:
+		return fmt.Errorf("unsupported kind %s", workload.kind)
+	}
+	if templateAnnotations[common.InjectionRestartAnnotKey] == expected {
+		log.Debugf("%s was already restarted for injection hash %q", workload, expected)
+		return nil
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q,%q:%q}}}}}`,
+		restartedAtAnnotKey, time.Now().Format(time.RFC3339), common.InjectionRestartAnnotKey, expected))
+	var err error
+	switch workload.kind {
+	case "Deployment":
+		_, err = c.client.AppsV1().Deployments(workload.namespace).Patch(ctx, workload.name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = c.client.AppsV1().StatefulSets(workload.namespace).Patch(ctx, workload.name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = c.client.AppsV1().DaemonSets(workload.namespace).Patch(ctx, workload.name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	metrics.InjectionRestarts.Inc(workload.kind, metrics.StatusSuccess)
+	log.Infof("Triggered a rolling restart of %s to inject the current auto instrumentation configuration", workload)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/DataDog/datadog-agent/comp/core"
+	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
+	workloadmetafxmock "github.com/DataDog/datadog-agent/comp/core/workloadmeta/fx-mock"
+	admcommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/common"
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/pointer"
+)
+
+func TestInjectionHash(t *testing.T) {
+	javaLib := libInfo{lang: java, image: libImageName(commonRegistry, java, "v1.20.0")}
+	pythonLib := libInfo{lang: python, image: libImageName(commonRegistry, python, "v2.5.1")}
+
+	hash := injectionHash([]libInfo{javaLib, pythonLib}, singleStepInstrumentationInstallType)
+	assert.Equal(t, hash, injectionHash([]libInfo{pythonLib, javaLib}, singleStepInstrumentationInstallType))
+	assert.NotEqual(t, hash, injectionHash([]libInfo{javaLib, pythonLib}, localLibraryInstrumentationInstallType))
+
+	upgradedLib := libInfo{lang: java, image: libImageName(commonRegistry, java, "v1.21.0")}
+	assert.NotEqual(t, hash, injectionHash([]libInfo{upgradedLib, pythonLib}, singleStepInstrumentationInstallType))
+}
+
+// newDriftTestWebhooks returns webhooks injecting the given versions of the java library
+func newDriftTestWebhooks(t *testing.T, javaVersions ...string) []*Webhook {
+	wmeta := fxutil.Test[workloadmeta.Component](t, core.MockBundle(), workloadmetafxmock.MockModule(), fx.Supply(workloadmeta.NewParams()))
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true)
+	var webhooks []*Webhook
+	for _, version := range javaVersions {
+		mockConfig.SetWithoutSource("apm_config.instrumentation.lib_versions", map[string]string{"java": version})
+		webhook, err := NewWebhook(wmeta)
+		require.NoError(t, err)
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+func TestInjectRecordsInjectionHash(t *testing.T) {
+	webhooks := newDriftTestWebhooks(t, "v1.20.0", "v1.21.0")
+	webhook, upgraded := webhooks[0], webhooks[1]
+
+	pod := common.FakePod("app")
+	pod.Namespace = "apps"
+	injected, err := webhook.inject(pod, "apps", nil)
+	require.NoError(t, err)
+	require.True(t, injected)
+	assert.NotEmpty(t, pod.Annotations[admcommon.InjectionHashAnnotKey])
+	assert.Equal(t, webhook.expectedInjectionHash(pod), pod.Annotations[admcommon.InjectionHashAnnotKey])
+
+	// the library is upgraded
+	assert.NotEqual(t, upgraded.expectedInjectionHash(pod), pod.Annotations[admcommon.InjectionHashAnnotKey])
+}
+
+func TestDriftControllerCheck(t *testing.T) {
+	webhooks := newDriftTestWebhooks(t, "v1.20.0", "v1.21.0")
+	oldWebhook, newWebhook := webhooks[0], webhooks[1]
+
+	newPod := func(name string, owner metav1.OwnerReference, webhook *Webhook) *corev1.Pod {
+		pod := common.FakePod(name)
+		pod.Namespace = "apps"
+		pod.OwnerReferences = []metav1.OwnerReference{owner}
+		if webhook != nil {
+			_, err := webhook.inject(pod, "apps", nil)
+			require.NoError(t, err)
+		}
+		return pod
+	}
+	rsOwner := metav1.OwnerReference{Kind: "ReplicaSet", Name: "app-5d8f7", Controller: pointer.Ptr(true)}
+	jobOwner := metav1.OwnerReference{Kind: "Job", Name: "batch", Controller: pointer.Ptr(true)}
+
+	client := k8sfake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name:            "app-5d8f7",
+			Namespace:       "apps",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "app", Controller: pointer.Ptr(true)}},
+		}},
+		newPod("app-5d8f7-a", rsOwner, oldWebhook),
+		newPod("app-5d8f7-b", rsOwner, oldWebhook),
+		newPod("app-5d8f7-c", rsOwner, newWebhook),
+		newPod("batch-a", jobOwner, oldWebhook),
+		newPod("not-injected", rsOwner, nil),
+	)
+	podInformers := informers.NewSharedInformerFactory(client, 0)
+	podInformer := podInformers.Core().V1().Pods()
+	podInformer.Informer()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	podInformers.Start(stopCh)
+	require.True(t, cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced))
+
+	patches := func() int {
+		count := 0
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "patch" {
+				count++
+			}
+		}
+		return count
+	}
+
+	// detection only
+	controller := NewDriftController(newWebhook, client, podInformer, func() bool { return true }, 0, false)
+	require.NoError(t, controller.check(context.Background()))
+	assert.Equal(t, 0, patches())
+
+	// the deployment is restarted once, the job can't be restarted
+	controller.rollingRestart = true
+	require.NoError(t, controller.check(context.Background()))
+	assert.Equal(t, 1, patches())
+	deploy, err := client.AppsV1().Deployments("apps").Get(context.Background(), "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	expected := newWebhook.expectedInjectionHash(newPod("app-5d8f7-d", rsOwner, nil))
+	assert.Equal(t, expected, deploy.Spec.Template.Annotations[admcommon.InjectionRestartAnnotKey])
+	assert.NotEmpty(t, deploy.Spec.Template.Annotations[restartedAtAnnotKey])
+
+	// not restarted again while the rollout is in progress
+	require.NoError(t, controller.check(context.Background()))
+	assert.Equal(t, 1, patches())
+}
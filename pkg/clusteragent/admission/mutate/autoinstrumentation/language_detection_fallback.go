@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/common"
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// languageDetectionFallback is what the webhook injects in a pod when language detection is enabled but
+// the languages of the pod aren't known at admission time, e.g. while the cluster agent restarts and
+// workloadmeta isn't populated yet
+type languageDetectionFallback string
+
+const (
+	// fallbackInjectAll injects the libraries of all the supported languages
+	fallbackInjectAll languageDetectionFallback = "inject_all"
+	// fallbackInjectNone doesn't inject any library
+	fallbackInjectNone languageDetectionFallback = "inject_none"
+	// fallbackRetry defers the injection to the reinvocation of the webhook, nothing is injected if the
+	// languages are still unknown then
+	fallbackRetry languageDetectionFallback = "retry"
+)
+
+var languageDetectionFallbacks = []languageDetectionFallback{fallbackInjectAll, fallbackInjectNone, fallbackRetry}
+
+func parseLanguageDetectionFallback(s string) (languageDetectionFallback, error) {
+	for _, f := range languageDetectionFallbacks {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid language detection fallback %q, supported values are %v", s, languageDetectionFallbacks)
+}
+
+// getFallbackLibraries returns the libraries to inject in a pod whose languages aren't detected, according
+// to the configured fallback. Retried pods are annotated so that the reinvocation can tell them apart.
+func (w *Webhook) getFallbackLibraries(pod *corev1.Pod, registry string) []libInfo {
+	switch w.languageDetectionFallback {
+	case fallbackInjectNone:
+		log.Debugf("Languages of pod %q are unknown, no library is injected", mutatecommon.PodString(pod))
+		return nil
+	case fallbackRetry:
+		if _, retried := pod.Annotations[common.LanguageDetectionDeferredAnnotKey]; retried {
+			log.Infof("Languages of pod %q are still unknown on reinvocation, no library is injected", mutatecommon.PodString(pod))
+			return nil
+		}
+		log.Debugf("Languages of pod %q are unknown, deferring the injection to the reinvocation", mutatecommon.PodString(pod))
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[common.LanguageDetectionDeferredAnnotKey] = "true"
+		return nil
+	default:
+		return getAllLatestLibraries(registry)
+	}
+}
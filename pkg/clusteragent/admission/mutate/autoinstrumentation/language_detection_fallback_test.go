@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/comp/core"
+	configComp "github.com/DataDog/datadog-agent/comp/core/config"
+	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
+	workloadmetafxmock "github.com/DataDog/datadog-agent/comp/core/workloadmeta/fx-mock"
+	admcommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/common"
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestParseLanguageDetectionFallback(t *testing.T) {
+	fallback, err := parseLanguageDetectionFallback("Inject_None")
+	require.NoError(t, err)
+	assert.Equal(t, fallbackInjectNone, fallback)
+
+	_, err = parseLanguageDetectionFallback("inject_some")
+	assert.Error(t, err)
+}
+
+func TestLanguageDetectionFallback(t *testing.T) {
+	tests := []struct {
+		name             string
+		fallback         string
+		deferred         bool
+		expectedLibs     []libInfo
+		expectedDeferred bool
+	}{
+		{
+			name:         "inject all",
+			fallback:     "inject_all",
+			expectedLibs: getAllLatestLibraries(commonRegistry),
+		},
+		{
+			name:     "inject none",
+			fallback: "inject_none",
+		},
+		{
+			name:         "invalid fallback injects all",
+			fallback:     "inject_some",
+			expectedLibs: getAllLatestLibraries(commonRegistry),
+		},
+		{
+			name:             "retry defers the injection",
+			fallback:         "retry",
+			expectedDeferred: true,
+		},
+		{
+			name:             "retry doesn't inject on reinvocation",
+			fallback:         "retry",
+			deferred:         true,
+			expectedDeferred: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides := map[string]interface{}{
+				"admission_controller.mutate_unlabelled":  true,
+				"admission_controller.container_registry": commonRegistry,
+			}
+			wmeta := fxutil.Test[workloadmeta.Component](t,
+				core.MockBundle(),
+				fx.Replace(configComp.MockParams{Overrides: overrides}),
+				workloadmetafxmock.MockModule(),
+				fx.Supply(workloadmeta.NewParams()),
+			)
+			mockConfig := config.Mock(t)
+			for k, v := range overrides {
+				mockConfig.SetWithoutSource(k, v)
+			}
+			mockConfig.SetWithoutSource("apm_config.instrumentation.enabled", true)
+			mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.inject_auto_detected_libraries", true)
+			mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.language_detection_fallback", tt.fallback)
+
+			webhook, err := NewWebhook(wmeta)
+			require.NoError(t, err)
+
+			// the languages of the deployment aren't in workloadmeta
+			pod := common.FakePodWithParent("ns", map[string]string{}, map[string]string{}, nil, "replicaset", "test-app-689695b6cc")
+			if tt.deferred {
+				pod.Annotations[admcommon.LanguageDetectionDeferredAnnotKey] = "true"
+			}
+			libs, autoDetected := webhook.extractLibInfo(pod)
+			assert.False(t, autoDetected)
+			assert.ElementsMatch(t, tt.expectedLibs, libs)
+			_, deferred := pod.Annotations[admcommon.LanguageDetectionDeferredAnnotKey]
+			assert.Equal(t, tt.expectedDeferred, deferred)
+		})
+	}
+}
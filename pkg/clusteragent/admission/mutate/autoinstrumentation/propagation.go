@@ -0,0 +1,131 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	propagationStyleEnvVarName               = "DD_TRACE_PROPAGATION_STYLE"
+	traceID128BitGenerationEnabledEnvVarName = "DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED"
+)
+
+// supportedPropagationStyles are the propagation styles understood by all the tracers
+var supportedPropagationStyles = []string{"datadog", "tracecontext", "b3multi", "b3", "b3 single header", "none"}
+
+// propagationConfig is the trace context propagation configuration injected in a pod
+type propagationConfig struct {
+	Style                          string `json:"style,omitempty"`
+	TraceID128BitGenerationEnabled *bool  `json:"trace_id_128_bit_generation_enabled,omitempty"`
+}
+
+// propagationConfigs holds the default propagation configuration and its per
+// namespace overrides
+type propagationConfigs struct {
+	defaultConfig propagationConfig
+	namespaces    map[string]propagationConfig
+}
+
+// newPropagationConfigs returns the propagation configuration read from
+// admission_controller.auto_instrumentation.propagation. Invalid settings are logged
+// and ignored, the namespaces whose override is invalid use the default configuration.
+func newPropagationConfigs() *propagationConfigs {
+	configs := &propagationConfigs{
+		defaultConfig: propagationConfig{
+			Style: config.Datadog().GetString("admission_controller.auto_instrumentation.propagation.style"),
+		},
+		namespaces: map[string]propagationConfig{},
+	}
+	if config.Datadog().IsSet("admission_controller.auto_instrumentation.propagation.trace_id_128_bit_generation_enabled") {
+		enabled := config.Datadog().GetBool("admission_controller.auto_instrumentation.propagation.trace_id_128_bit_generation_enabled")
+		configs.defaultConfig.TraceID128BitGenerationEnabled = &enabled
+	}
+	if err := validatePropagationStyle(configs.defaultConfig.Style); err != nil {
+		log.Errorf("Invalid admission_controller.auto_instrumentation.propagation.style, the propagation style isn't injected: %v", err)
+		configs.defaultConfig.Style = ""
+	}
+
+	var namespaces map[string]propagationConfig
+	if err := json.Unmarshal([]byte(config.Datadog().GetString("admission_controller.auto_instrumentation.propagation.namespaces")), &namespaces); err != nil {
+		log.Errorf("Failed to parse admission_controller.auto_instrumentation.propagation.namespaces, using the default propagation configuration for all namespaces: %v", err)
+		return configs
+	}
+	for ns, nsConfig := range namespaces {
+		if err := validatePropagationStyle(nsConfig.Style); err != nil {
+			log.Errorf("Invalid propagation configuration for namespace %s, using the default one: %v", ns, err)
+			continue
+		}
+		configs.namespaces[ns] = nsConfig
+	}
+	return configs
+}
+
+func validatePropagationStyle(style string) error {
+	if style == "" {
+		return nil
+	}
+	for _, s := range strings.Split(style, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		valid := false
+		for _, supported := range supportedPropagationStyles {
+			if s == supported {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown propagation style %q", s)
+		}
+	}
+	return nil
+}
+
+// forNamespace returns the propagation configuration of the given namespace,
+// unset fields of the namespace override fall back to the default ones
+func (p *propagationConfigs) forNamespace(ns string) propagationConfig {
+	propagation := p.defaultConfig
+	override, found := p.namespaces[ns]
+	if !found {
+		return propagation
+	}
+	if override.Style != "" {
+		propagation.Style = override.Style
+	}
+	if override.TraceID128BitGenerationEnabled != nil {
+		propagation.TraceID128BitGenerationEnabled = override.TraceID128BitGenerationEnabled
+	}
+	return propagation
+}
+
+// injectPropagationConfig injects the propagation settings of the pod namespace so that
+// all the tracers of the cluster agree on how trace context is propagated.
+// Env vars already set on a container take precedence.
+func injectPropagationConfig(pod *corev1.Pod, propagation propagationConfig) {
+	if propagation.Style != "" {
+		_ = mutatecommon.InjectEnv(pod, corev1.EnvVar{
+			Name:  propagationStyleEnvVarName,
+			Value: propagation.Style,
+		})
+	}
+	if propagation.TraceID128BitGenerationEnabled != nil {
+		_ = mutatecommon.InjectEnv(pod, corev1.EnvVar{
+			Name:  traceID128BitGenerationEnabledEnvVarName,
+			Value: strconv.FormatBool(*propagation.TraceID128BitGenerationEnabled),
+		})
+	}
+}
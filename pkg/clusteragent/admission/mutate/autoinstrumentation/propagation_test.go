@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package autoinstrumentation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/pointer"
+)
+
+func TestPropagationConfigs(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.style", "datadog,tracecontext")
+	mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.trace_id_128_bit_generation_enabled", true)
+	mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.namespaces", `{
+		"legacy": {"style": "b3multi", "trace_id_128_bit_generation_enabled": false},
+		"style-only": {"style": "tracecontext"}
+	}`)
+
+	configs := newPropagationConfigs()
+
+	assert.Equal(t, propagationConfig{Style: "datadog,tracecontext", TraceID128BitGenerationEnabled: pointer.Ptr(true)}, configs.forNamespace("default"))
+	assert.Equal(t, propagationConfig{Style: "b3multi", TraceID128BitGenerationEnabled: pointer.Ptr(false)}, configs.forNamespace("legacy"))
+	assert.Equal(t, propagationConfig{Style: "tracecontext", TraceID128BitGenerationEnabled: pointer.Ptr(true)}, configs.forNamespace("style-only"))
+}
+
+func TestPropagationConfigsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		style      string
+		namespaces string
+		expected   propagationConfig
+	}{
+		{
+			name:       "invalid json",
+			style:      "datadog",
+			namespaces: `{"legacy": [}`,
+			expected:   propagationConfig{Style: "datadog"},
+		},
+		{
+			name:       "invalid namespace style",
+			style:      "datadog",
+			namespaces: `{"legacy": {"style": "zipkin"}, "valid": {"style": "b3multi"}}`,
+			expected:   propagationConfig{Style: "datadog"},
+		},
+		{
+			name:       "invalid default style",
+			style:      "datadog,zipkin",
+			namespaces: `{"legacy": {"style": "b3multi"}}`,
+			expected:   propagationConfig{Style: "b3multi"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConfig := config.Mock(t)
+			mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.style", tt.style)
+			mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.namespaces", tt.namespaces)
+
+			// The invalid settings are skipped, the other ones are still used
+			configs := newPropagationConfigs()
+			assert.Equal(t, tt.expected, configs.forNamespace("legacy"))
+		})
+	}
+
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.auto_instrumentation.propagation.namespaces", `{"legacy": {"style": "zipkin"}, "valid": {"style": "b3multi"}}`)
+	assert.Equal(t, propagationConfig{Style: "b3multi"}, newPropagationConfigs().forNamespace("valid"))
+}
+
+func TestInjectPropagationConfig(t *testing.T) {
+	pod := common.FakePod("java-pod")
+	injectPropagationConfig(pod, propagationConfig{})
+	assert.Empty(t, pod.Spec.Containers[0].Env)
+
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: propagationStyleEnvVarName, Value: "b3"}}
+	injectPropagationConfig(pod, propagationConfig{Style: "datadog,tracecontext", TraceID128BitGenerationEnabled: pointer.Ptr(true)})
+	assert.ElementsMatch(t, []corev1.EnvVar{
+		{Name: propagationStyleEnvVarName, Value: "b3"},
+		{Name: traceID128BitGenerationEnabledEnvVarName, Value: "true"},
+	}, pod.Spec.Containers[0].Env)
+}
@@ -10,6 +10,7 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -40,19 +41,77 @@ func Mutate(rawPod []byte, ns string, mutationType string, m MutationFunc, dc dy
 		return nil, fmt.Errorf("failed to mutate pod: %v", err)
 	}
 
-	metrics.MutationAttempts.Inc(mutationType, metrics.StatusSuccess, strconv.FormatBool(injected), "")
-
 	bytes, err := json.Marshal(pod)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode the mutated Pod object: %v", err)
 	}
 
-	patch, err := jsondiff.CompareJSON(rawPod, bytes) // TODO: Try to generate the patch at the MutationFunc
+	jsonPatch, err := preparePatch(rawPod, bytes, config.Datadog().GetInt("admission_controller.max_patch_size"))
+	var tooLargeErr *PatchTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		tooLargeErr.MutationType = mutationType
+		tooLargeErr.Pod = PodString(&pod)
+		metrics.MutationAttempts.Inc(mutationType, metrics.StatusError, strconv.FormatBool(false), metrics.PatchTooLarge)
+		return nil, tooLargeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.MutationAttempts.Inc(mutationType, metrics.StatusSuccess, strconv.FormatBool(injected), "")
+	metrics.PatchSize.Observe(float64(len(jsonPatch)), mutationType)
+	return jsonPatch, nil
+}
+
+// PatchTooLargeError is returned when the JSON patch of a mutation can't fit under the size limit,
+// none of the operations of the patch are applied. The webhook server then rejects or admits the pod
+// according to the failure policy of the webhook.
+type PatchTooLargeError struct {
+	MutationType string
+	Pod          string
+	Size         int
+	Limit        int
+}
+
+// Error returns the error message.
+func (e *PatchTooLargeError) Error() string {
+	return fmt.Sprintf("the %s mutation of pod %s was not applied: its JSON patch of %d bytes exceeds the limit of %d bytes", e.MutationType, e.Pod, e.Size, e.Limit)
+}
+
+// preparePatch returns the JSON patch turning rawPod into mutatedPod, nil if they are equivalent.
+// Prepending items to the lists of large pods, e.g. the env vars of dozens of containers, produces
+// patches replacing every item shifted: if the patch exceeds maxSize, it's pruned to the items
+// actually inserted or removed, before failing with a PatchTooLargeError.
+func preparePatch(rawPod []byte, mutatedPod []byte, maxSize int) ([]byte, error) {
+	patch, err := jsondiff.CompareJSON(rawPod, mutatedPod) // TODO: Try to generate the patch at the MutationFunc
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare the JSON patch: %v", err)
 	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	jsonPatch, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the JSON patch: %v", err)
+	}
+	if maxSize <= 0 || len(jsonPatch) <= maxSize {
+		return jsonPatch, nil
+	}
 
-	return json.Marshal(patch)
+	size := len(jsonPatch)
+	patch, err = jsondiff.CompareJSON(rawPod, mutatedPod, jsondiff.LCS())
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare the pruned JSON patch: %v", err)
+	}
+	jsonPatch, err = json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the pruned JSON patch: %v", err)
+	}
+	if len(jsonPatch) > maxSize {
+		return nil, &PatchTooLargeError{Size: len(jsonPatch), Limit: maxSize}
+	}
+	log.Debugf("Pruned JSON patch from %d to %d bytes to fit under the limit of %d bytes", size, len(jsonPatch), maxSize)
+	return jsonPatch, nil
 }
 
 // contains returns whether EnvVar slice contains an env var with a given name
@@ -164,9 +223,31 @@ func containsVolumeMount(volumeMounts []corev1.VolumeMount, element corev1.Volum
 	return false
 }
 
+// IsOptedOut returns true if the pod opted out of all the mutations of the Admission Controller
+// with the annotation admission.datadoghq.com/all.enabled=false. Webhooks must check it before
+// any other label or configuration.
+func IsOptedOut(pod *corev1.Pod) bool {
+	val, found := pod.GetAnnotations()[admCommon.AllEnabledAnnotationKey]
+	if !found {
+		return false
+	}
+	switch val {
+	case "false":
+		return true
+	case "true":
+	default:
+		log.Warnf("Invalid annotation value '%s=%s' on pod %s should be either 'true' or 'false', ignoring it", admCommon.AllEnabledAnnotationKey, val, PodString(pod))
+	}
+	return false
+}
+
 // ShouldMutatePod returns true if Admission Controller is allowed to mutate the pod
 // via pod label or mutateUnlabelled configuration
 func ShouldMutatePod(pod *corev1.Pod) bool {
+	if IsOptedOut(pod) {
+		return false
+	}
+
 	// If a pod explicitly sets the label admission.datadoghq.com/enabled, make a decision based on its value
 	if val, found := pod.GetLabels()[admCommon.EnabledLabelKey]; found {
 		switch val {
@@ -179,7 +260,12 @@ func ShouldMutatePod(pod *corev1.Pod) bool {
 		}
 	}
 
-	return config.Datadog().GetBool("admission_controller.mutate_unlabelled")
+	// Pods created by operators often have no label, they can be targeted by the kind of their owner
+	if mutate, matched := OwnerKindDecision(pod); matched {
+		return mutate
+	}
+
+	return config.Datadog().Features().Enabled(config.FeatureMutateUnlabelled)
 }
 
 // ContainerRegistry gets the container registry config using the specified
@@ -8,11 +8,17 @@
 package common
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
 )
 
 func Test_contains(t *testing.T) {
@@ -218,3 +224,140 @@ func Test_injectVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldMutatePodOptOut(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.mutate_unlabelled", true)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		want        bool
+	}{
+		{
+			name: "no annotation",
+			want: true,
+		},
+		{
+			name:        "opted out",
+			annotations: map[string]string{"admission.datadoghq.com/all.enabled": "false"},
+			want:        false,
+		},
+		{
+			name:        "opted out takes precedence over the label",
+			annotations: map[string]string{"admission.datadoghq.com/all.enabled": "false"},
+			labels:      map[string]string{"admission.datadoghq.com/enabled": "true"},
+			want:        false,
+		},
+		{
+			name:        "annotation enabled doesn't override the label",
+			annotations: map[string]string{"admission.datadoghq.com/all.enabled": "true"},
+			labels:      map[string]string{"admission.datadoghq.com/enabled": "false"},
+			want:        false,
+		},
+		{
+			name:        "invalid annotation is ignored",
+			annotations: map[string]string{"admission.datadoghq.com/all.enabled": "no"},
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := WithAnnotations(WithLabels(FakePod("pod"), tt.labels), tt.annotations)
+			assert.Equal(t, tt.want, ShouldMutatePod(pod))
+			assert.Equal(t, !tt.want && tt.annotations["admission.datadoghq.com/all.enabled"] == "false", IsOptedOut(pod))
+		})
+	}
+}
+
+func TestMutatePatchSize(t *testing.T) {
+	pod := FakeWorstCasePod("pod", 40, 50)
+	rawPod, err := json.Marshal(pod)
+	require.NoError(t, err)
+	injectEnv := func(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool, error) {
+		injected := InjectEnv(pod, FakeEnvWithValue("DD_AGENT_HOST", "localhost"))
+		injected = InjectVolume(pod, corev1.Volume{Name: "datadog"}, corev1.VolumeMount{Name: "datadog", MountPath: "/var/run/datadog"}) || injected
+		return injected, nil
+	}
+
+	var mutatedPod corev1.Pod
+	require.NoError(t, json.Unmarshal(rawPod, &mutatedPod))
+	_, _ = injectEnv(&mutatedPod, "", nil)
+	expected, err := json.Marshal(mutatedPod)
+	require.NoError(t, err)
+	fullPatch, err := preparePatch(rawPod, expected, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		maxPatchSize  int
+		mutation      MutationFunc
+		wantTooLarge  bool
+		wantNoPatch   bool
+		wantFullPatch bool
+	}{
+		{
+			name:          "no limit",
+			mutation:      injectEnv,
+			wantFullPatch: true,
+		},
+		{
+			name:          "under the limit",
+			maxPatchSize:  len(fullPatch),
+			mutation:      injectEnv,
+			wantFullPatch: true,
+		},
+		{
+			name:         "pruned under the limit",
+			maxPatchSize: len(fullPatch) / 10,
+			mutation:     injectEnv,
+		},
+		{
+			name:         "too large",
+			maxPatchSize: 1024,
+			mutation:     injectEnv,
+			wantTooLarge: true,
+		},
+		{
+			name:         "no-op",
+			maxPatchSize: 1024,
+			mutation:     func(*corev1.Pod, string, dynamic.Interface) (bool, error) { return false, nil },
+			wantNoPatch:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConfig := config.Mock(t)
+			mockConfig.SetWithoutSource("admission_controller.max_patch_size", tt.maxPatchSize)
+
+			jsonPatch, err := Mutate(rawPod, "default", "test", tt.mutation, nil)
+			if tt.wantTooLarge {
+				var tooLargeErr *PatchTooLargeError
+				require.ErrorAs(t, err, &tooLargeErr)
+				assert.Equal(t, "test", tooLargeErr.MutationType)
+				assert.Equal(t, tt.maxPatchSize, tooLargeErr.Limit)
+				assert.Greater(t, tooLargeErr.Size, tt.maxPatchSize)
+				assert.Nil(t, jsonPatch)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNoPatch {
+				assert.Nil(t, jsonPatch)
+				return
+			}
+			if tt.wantFullPatch {
+				assert.Equal(t, fullPatch, jsonPatch)
+			} else {
+				assert.LessOrEqual(t, len(jsonPatch), tt.maxPatchSize)
+			}
+
+			// the patch applied by the API server results in the mutated pod
+			patch, err := jsonpatch.DecodePatch(jsonPatch)
+			require.NoError(t, err)
+			patched, err := patch.Apply(rawPod)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(patched))
+		})
+	}
+}
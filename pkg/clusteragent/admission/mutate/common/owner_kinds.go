@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// OwnerKind matches the owners of a pod through its ownerReferences. It is used to
+// target pods created by operators, which often don't carry any standard label.
+type OwnerKind struct {
+	Kind string `json:"kind"`
+	// APIVersion is either a full API version like sparkoperator.k8s.io/v1beta2 or a
+	// group like sparkoperator.k8s.io matching all its versions. Empty matches any API version.
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+func (o OwnerKind) matches(ref metav1.OwnerReference) bool {
+	if o.Kind != ref.Kind {
+		return false
+	}
+	if o.APIVersion == "" || o.APIVersion == ref.APIVersion {
+		return true
+	}
+	group, _, _ := strings.Cut(ref.APIVersion, "/")
+	return !strings.Contains(o.APIVersion, "/") && o.APIVersion == group
+}
+
+// ownerKindsCache avoids parsing the owner kinds for every pod, the raw
+// values are kept to notice configuration changes
+var ownerKindsCache struct {
+	sync.Mutex
+	rawIncluded string
+	rawExcluded string
+	included    []OwnerKind
+	excluded    []OwnerKind
+}
+
+// ownerKinds returns the included and excluded owner kinds read from
+// admission_controller.included_owner_kinds and admission_controller.excluded_owner_kinds
+func ownerKinds() (included []OwnerKind, excluded []OwnerKind) {
+	rawIncluded := config.Datadog().GetString("admission_controller.included_owner_kinds")
+	rawExcluded := config.Datadog().GetString("admission_controller.excluded_owner_kinds")
+
+	ownerKindsCache.Lock()
+	defer ownerKindsCache.Unlock()
+	if rawIncluded != ownerKindsCache.rawIncluded {
+		ownerKindsCache.rawIncluded = rawIncluded
+		ownerKindsCache.included = parseOwnerKinds("admission_controller.included_owner_kinds", rawIncluded)
+	}
+	if rawExcluded != ownerKindsCache.rawExcluded {
+		ownerKindsCache.rawExcluded = rawExcluded
+		ownerKindsCache.excluded = parseOwnerKinds("admission_controller.excluded_owner_kinds", rawExcluded)
+	}
+	return ownerKindsCache.included, ownerKindsCache.excluded
+}
+
+func parseOwnerKinds(configKey string, raw string) []OwnerKind {
+	if raw == "" {
+		return nil
+	}
+	var kinds []OwnerKind
+	if err := json.Unmarshal([]byte(raw), &kinds); err != nil {
+		log.Errorf("failed to parse %s, ignoring it: %v", configKey, err)
+		return nil
+	}
+	for _, kind := range kinds {
+		if kind.Kind == "" {
+			log.Errorf("failed to parse %s, ignoring it: %v", configKey, fmt.Errorf("owner kind without kind: %+v", kind))
+			return nil
+		}
+	}
+	return kinds
+}
+
+// HasIncludedOwnerKinds returns true if pods are targeted based on their owners, in which case
+// the webhooks must be called for unlabelled pods too.
+func HasIncludedOwnerKinds() bool {
+	included, _ := ownerKinds()
+	return len(included) > 0
+}
+
+// OwnerKindDecision returns whether the pod should be mutated based on the kinds of its owners.
+// The second value is false when the owners of the pod match neither the included nor the
+// excluded owner kinds. Exclusion takes precedence over inclusion.
+func OwnerKindDecision(pod *corev1.Pod) (mutate bool, matched bool) {
+	included, excluded := ownerKinds()
+	if len(included) == 0 && len(excluded) == 0 {
+		return false, false
+	}
+	for _, ref := range pod.GetOwnerReferences() {
+		for _, kind := range excluded {
+			if kind.matches(ref) {
+				return false, true
+			}
+		}
+	}
+	for _, ref := range pod.GetOwnerReferences() {
+		for _, kind := range included {
+			if kind.matches(ref) {
+				return true, true
+			}
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func podOwnedBy(apiVersion, kind string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: "owner"}},
+		},
+	}
+}
+
+func TestOwnerKindDecision(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.included_owner_kinds", `[
+		{"kind": "SparkApplication", "api_version": "sparkoperator.k8s.io"},
+		{"kind": "FlinkDeployment", "api_version": "flink.apache.org/v1beta1"},
+		{"kind": "StrimziPodSet"}
+	]`)
+	mockConfig.SetWithoutSource("admission_controller.excluded_owner_kinds", `[{"kind": "Job", "api_version": "batch/v1"}]`)
+
+	tests := []struct {
+		name            string
+		pod             *corev1.Pod
+		expectedMutate  bool
+		expectedMatched bool
+	}{
+		{
+			name:            "group matches all versions",
+			pod:             podOwnedBy("sparkoperator.k8s.io/v1beta2", "SparkApplication"),
+			expectedMutate:  true,
+			expectedMatched: true,
+		},
+		{
+			name:            "full api version",
+			pod:             podOwnedBy("flink.apache.org/v1beta1", "FlinkDeployment"),
+			expectedMutate:  true,
+			expectedMatched: true,
+		},
+		{
+			name: "other api version",
+			pod:  podOwnedBy("flink.apache.org/v1alpha1", "FlinkDeployment"),
+		},
+		{
+			name:            "any api version",
+			pod:             podOwnedBy("core.strimzi.io/v1beta2", "StrimziPodSet"),
+			expectedMutate:  true,
+			expectedMatched: true,
+		},
+		{
+			name:            "excluded",
+			pod:             podOwnedBy("batch/v1", "Job"),
+			expectedMatched: true,
+		},
+		{
+			name: "not matched",
+			pod:  podOwnedBy("apps/v1", "ReplicaSet"),
+		},
+		{
+			name: "no owner",
+			pod:  &corev1.Pod{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutate, matched := OwnerKindDecision(tt.pod)
+			assert.Equal(t, tt.expectedMutate, mutate)
+			assert.Equal(t, tt.expectedMatched, matched)
+		})
+	}
+}
+
+func TestOwnerKindDecisionExclusionFirst(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.included_owner_kinds", `[{"kind": "SparkApplication"}]`)
+	mockConfig.SetWithoutSource("admission_controller.excluded_owner_kinds", `[{"kind": "Job"}]`)
+
+	pod := podOwnedBy("sparkoperator.k8s.io/v1beta2", "SparkApplication")
+	pod.OwnerReferences = append(pod.OwnerReferences, metav1.OwnerReference{APIVersion: "batch/v1", Kind: "Job"})
+	mutate, matched := OwnerKindDecision(pod)
+	assert.False(t, mutate)
+	assert.True(t, matched)
+}
+
+func TestShouldMutatePodOwnerKinds(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.mutate_unlabelled", false)
+	assert.False(t, HasIncludedOwnerKinds())
+	assert.False(t, ShouldMutatePod(podOwnedBy("sparkoperator.k8s.io/v1beta2", "SparkApplication")))
+
+	mockConfig.SetWithoutSource("admission_controller.included_owner_kinds", `[{"kind": "SparkApplication"}]`)
+	assert.True(t, HasIncludedOwnerKinds())
+	assert.True(t, ShouldMutatePod(podOwnedBy("sparkoperator.k8s.io/v1beta2", "SparkApplication")))
+	assert.False(t, ShouldMutatePod(podOwnedBy("apps/v1", "ReplicaSet")))
+
+	// The label takes precedence
+	pod := podOwnedBy("sparkoperator.k8s.io/v1beta2", "SparkApplication")
+	pod.Labels = map[string]string{"admission.datadoghq.com/enabled": "false"}
+	assert.False(t, ShouldMutatePod(pod))
+
+	// Invalid configurations are ignored
+	mockConfig.SetWithoutSource("admission_controller.included_owner_kinds", `[{"api_version": "batch/v1"}]`)
+	assert.False(t, HasIncludedOwnerKinds())
+}
@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// ImageRegistry is the registry configuration used for the images injected in a pod
+type ImageRegistry struct {
+	Registry    string            `json:"registry,omitempty"`
+	PullSecrets []string          `json:"image_pull_secrets,omitempty"`
+	PullPolicy  corev1.PullPolicy `json:"image_pull_policy,omitempty"`
+	// PinDigests overrides whether the injected images are pinned to their digests, for the
+	// webhooks supporting it
+	PinDigests *bool `json:"pin_digests,omitempty"`
+}
+
+// ImageRegistries holds the default registry configuration of a webhook and
+// its per namespace overrides
+type ImageRegistries struct {
+	defaultRegistry ImageRegistry
+	namespaces      map[string]ImageRegistry
+}
+
+// NewImageRegistries returns the registry configuration of a webhook. The default
+// registry is read from the specified config option, see ContainerRegistry, and the
+// per namespace overrides from admission_controller.namespace_registries.
+// Invalid settings are logged and ignored, the namespaces whose override is invalid
+// use the default registry.
+func NewImageRegistries(specificConfigOpt string) *ImageRegistries {
+	registries := &ImageRegistries{
+		defaultRegistry: ImageRegistry{
+			Registry:    ContainerRegistry(specificConfigOpt),
+			PullSecrets: config.Datadog().GetStringSlice("admission_controller.image_pull_secrets"),
+			PullPolicy:  corev1.PullPolicy(config.Datadog().GetString("admission_controller.image_pull_policy")),
+		},
+		namespaces: map[string]ImageRegistry{},
+	}
+	if err := validatePullPolicy(registries.defaultRegistry.PullPolicy); err != nil {
+		log.Errorf("Invalid admission_controller.image_pull_policy, using the default pull policy: %v", err)
+		registries.defaultRegistry.PullPolicy = ""
+	}
+
+	var namespaces map[string]ImageRegistry
+	if err := json.Unmarshal([]byte(config.Datadog().GetString("admission_controller.namespace_registries")), &namespaces); err != nil {
+		log.Errorf("Failed to parse admission_controller.namespace_registries, using the default registry for all namespaces: %v", err)
+		return registries
+	}
+	for ns, registry := range namespaces {
+		if err := validatePullPolicy(registry.PullPolicy); err != nil {
+			log.Errorf("Invalid registry configuration for namespace %s, using the default registry: %v", ns, err)
+			continue
+		}
+		registries.namespaces[ns] = registry
+	}
+	return registries
+}
+
+func validatePullPolicy(policy corev1.PullPolicy) error {
+	switch policy {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return nil
+	default:
+		return fmt.Errorf("unknown image pull policy %q", policy)
+	}
+}
+
+// ForNamespace returns the registry configuration of the given namespace,
+// unset fields of the namespace override fall back to the default ones
+func (r *ImageRegistries) ForNamespace(ns string) ImageRegistry {
+	registry := r.defaultRegistry
+	override, found := r.namespaces[ns]
+	if !found {
+		return registry
+	}
+	if override.Registry != "" {
+		registry.Registry = override.Registry
+	}
+	if len(override.PullSecrets) > 0 {
+		registry.PullSecrets = override.PullSecrets
+	}
+	if override.PullPolicy != "" {
+		registry.PullPolicy = override.PullPolicy
+	}
+	if override.PinDigests != nil {
+		registry.PinDigests = override.PinDigests
+	}
+	return registry
+}
+
+// InjectImagePullSecrets adds the given secrets to the image pull secrets of the pod.
+// The secrets must exist in the namespace of the pod.
+func InjectImagePullSecrets(pod *corev1.Pod, secrets []string) bool {
+	injected := false
+	for _, secret := range secrets {
+		exists := slices.ContainsFunc(pod.Spec.ImagePullSecrets, func(ref corev1.LocalObjectReference) bool {
+			return ref.Name == secret
+		})
+		if exists {
+			continue
+		}
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+		injected = true
+	}
+	return injected
+}
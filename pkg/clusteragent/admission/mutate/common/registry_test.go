@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/pointer"
+)
+
+func TestImageRegistries(t *testing.T) {
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.container_registry", "gcr.io/datadoghq")
+	mockConfig.SetWithoutSource("admission_controller.image_pull_secrets", []string{"default-secret"})
+	mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{
+		"internal": {"registry": "registry.internal/datadog", "image_pull_secrets": ["internal-secret"], "image_pull_policy": "Always"},
+		"policy-only": {"image_pull_policy": "Never"},
+		"pinned": {"pin_digests": true}
+	}`)
+
+	registries := NewImageRegistries("admission_controller.agent_sidecar.container_registry")
+
+	assert.Equal(t, ImageRegistry{
+		Registry:    "gcr.io/datadoghq",
+		PullSecrets: []string{"default-secret"},
+	}, registries.ForNamespace("default"))
+	assert.Equal(t, ImageRegistry{
+		Registry:    "registry.internal/datadog",
+		PullSecrets: []string{"internal-secret"},
+		PullPolicy:  corev1.PullAlways,
+	}, registries.ForNamespace("internal"))
+	assert.Equal(t, ImageRegistry{
+		Registry:    "gcr.io/datadoghq",
+		PullSecrets: []string{"default-secret"},
+		PullPolicy:  corev1.PullNever,
+	}, registries.ForNamespace("policy-only"))
+	assert.Equal(t, ImageRegistry{
+		Registry:    "gcr.io/datadoghq",
+		PullSecrets: []string{"default-secret"},
+		PinDigests:  pointer.Ptr(true),
+	}, registries.ForNamespace("pinned"))
+}
+
+func TestImageRegistriesInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		pullPolicy string
+		namespaces string
+		expected   ImageRegistry
+	}{
+		{
+			name:       "invalid json",
+			namespaces: `{"internal": [}`,
+			expected:   ImageRegistry{Registry: "gcr.io/datadoghq", PullSecrets: []string{}},
+		},
+		{
+			name:       "invalid namespace pull policy",
+			namespaces: `{"internal": {"image_pull_policy": "Sometimes"}, "valid": {"registry": "registry.internal/datadog"}}`,
+			expected:   ImageRegistry{Registry: "gcr.io/datadoghq", PullSecrets: []string{}},
+		},
+		{
+			name:       "invalid default pull policy",
+			pullPolicy: "Sometimes",
+			namespaces: `{"internal": {"image_pull_policy": "Always"}}`,
+			expected:   ImageRegistry{Registry: "gcr.io/datadoghq", PullSecrets: []string{}, PullPolicy: corev1.PullAlways},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConfig := config.Mock(t)
+			mockConfig.SetWithoutSource("admission_controller.container_registry", "gcr.io/datadoghq")
+			mockConfig.SetWithoutSource("admission_controller.image_pull_policy", tt.pullPolicy)
+			mockConfig.SetWithoutSource("admission_controller.namespace_registries", tt.namespaces)
+
+			// The invalid settings are skipped, the other ones are still used
+			registries := NewImageRegistries("admission_controller.agent_sidecar.container_registry")
+			assert.Equal(t, tt.expected, registries.ForNamespace("internal"))
+			assert.Equal(t, ImageRegistry{Registry: "gcr.io/datadoghq", PullSecrets: []string{}}, registries.ForNamespace("default"))
+		})
+	}
+
+	mockConfig := config.Mock(t)
+	mockConfig.SetWithoutSource("admission_controller.container_registry", "gcr.io/datadoghq")
+	mockConfig.SetWithoutSource("admission_controller.namespace_registries", `{"internal": {"image_pull_policy": "Sometimes"}, "valid": {"registry": "registry.internal/datadog"}}`)
+	registries := NewImageRegistries("admission_controller.agent_sidecar.container_registry")
+	assert.Equal(t, "registry.internal/datadog", registries.ForNamespace("valid").Registry)
+}
+
+func TestInjectImagePullSecrets(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "existing"}}
+
+	assert.True(t, InjectImagePullSecrets(pod, []string{"existing", "new"}))
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "existing"}, {Name: "new"}}, pod.Spec.ImagePullSecrets)
+	assert.False(t, InjectImagePullSecrets(pod, []string{"new"}))
+	assert.False(t, InjectImagePullSecrets(pod, nil))
+}
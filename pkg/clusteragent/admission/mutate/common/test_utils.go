@@ -10,6 +10,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"go.uber.org/fx"
@@ -66,6 +67,25 @@ func FakeContainer(name string) corev1.Container {
 	}
 }
 
+// FakeWorstCasePod returns a pod with the given number of containers, each with the given number of
+// env vars and volume mounts: the worst case for the patches prepending items to the lists of every
+// container, as every item shifted is replaced.
+func FakeWorstCasePod(name string, containers int, items int) *corev1.Pod {
+	pod := FakePodWithContainer(name)
+	for i := 0; i < containers; i++ {
+		container := corev1.Container{Name: fmt.Sprintf("%s-container-%d", name, i)}
+		for j := 0; j < items; j++ {
+			container.Env = append(container.Env, FakeEnvWithValue(fmt.Sprintf("%s_ENV_%d", strings.ToUpper(name), j), strings.Repeat("v", 64)))
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      fmt.Sprintf("%s-volume-%d", name, j),
+				MountPath: fmt.Sprintf("/var/lib/%s/volume-%d", name, j),
+			})
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, container)
+	}
+	return pod
+}
+
 // FakePodWithContainer returns a pod with the given name and containers
 func FakePodWithContainer(name string, containers ...corev1.Container) *corev1.Pod {
 	return &corev1.Pod{
@@ -95,6 +115,12 @@ func WithLabels(pod *corev1.Pod, labels map[string]string) *corev1.Pod {
 	return pod
 }
 
+// WithAnnotations sets the annotations of the given pod
+func WithAnnotations(pod *corev1.Pod, annotations map[string]string) *corev1.Pod {
+	pod.Annotations = annotations
+	return pod
+}
+
 // FakePodWithLabel returns a pod with the given label set to the given value
 func FakePodWithLabel(k, v string) *corev1.Pod {
 	return &corev1.Pod{
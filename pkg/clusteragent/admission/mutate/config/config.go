@@ -6,7 +6,8 @@
 //go:build kubeapiserver
 
 // Package config implements the webhook that injects DD_AGENT_HOST and
-// DD_ENTITY_ID into a pod template as needed
+// DD_ENTITY_ID into a pod template as needed, along with the APM ingestion
+// settings configured for its namespace and workload
 package config
 
 import (
@@ -90,6 +91,7 @@ var (
 // conf is the configuration for the webhook
 type conf struct {
 	injectContName bool
+	ingestionRules []ingestionRule
 }
 
 // Webhook is the webhook that injects DD_AGENT_HOST and DD_ENTITY_ID into a pod
@@ -106,10 +108,15 @@ type Webhook struct {
 
 // NewWebhook returns a new Webhook
 func NewWebhook(wmeta workloadmeta.Component) *Webhook {
+	ingestionRules, err := parseIngestionRules(config.Datadog().GetString("admission_controller.inject_config.apm_ingestion_rules"))
+	if err != nil {
+		log.Errorf("APM ingestion settings won't be injected: %v", err)
+	}
 	return &Webhook{
 		name: webhookName,
 		config: conf{
 			injectContName: config.Datadog().GetBool("admission_controller.inject_config.inject_container_name"),
+			ingestionRules: ingestionRules,
 		},
 		isEnabled:  config.Datadog().GetBool("admission_controller.inject_config.enabled"),
 		endpoint:   config.Datadog().GetString("admission_controller.inject_config.endpoint"),
@@ -163,9 +170,10 @@ func (w *Webhook) mutate(request *admission.MutateRequest) ([]byte, error) {
 	return common.Mutate(request.Raw, request.Namespace, w.Name(), w.inject, request.DynamicClient)
 }
 
-// inject injects DD_AGENT_HOST and DD_ENTITY_ID into a pod template if needed
-func (w *Webhook) inject(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool, error) {
-	var injectedConfig, injectedEntity bool
+// inject injects DD_AGENT_HOST and DD_ENTITY_ID into a pod template if needed,
+// along with the APM ingestion settings of the rules matching the pod
+func (w *Webhook) inject(pod *corev1.Pod, ns string, _ dynamic.Interface) (bool, error) {
+	var injectedConfig, injectedEntity, injectedIngestion bool
 
 	if pod == nil {
 		return false, errors.New(metrics.InvalidInput)
@@ -198,7 +206,9 @@ func (w *Webhook) inject(pod *corev1.Pod, _ string, _ dynamic.Interface) (bool,
 		injectedEntity = common.InjectEnv(pod, defaultDdEntityIDEnvVar)
 	}
 
-	return injectedConfig || injectedEntity, nil
+	injectedIngestion = injectIngestionSettings(w.config.ingestionRules, pod, ns)
+
+	return injectedConfig || injectedEntity || injectedIngestion, nil
 }
 
 // injectionMode returns the injection mode based on the global mode and pod labels
@@ -132,6 +132,26 @@ func TestInjectEntityID(t *testing.T) {
 	}
 }
 
+func TestInjectIngestionSettings(t *testing.T) {
+	pod := mutatecommon.FakePodWithContainer("foo-pod", corev1.Container{})
+	pod = mutatecommon.WithLabels(pod, map[string]string{"admission.datadoghq.com/enabled": "true", "app": "checkout"})
+	wmeta := fxutil.Test[workloadmeta.Component](
+		t,
+		core.MockBundle(),
+		workloadmetafxmock.MockModule(),
+		fx.Supply(workloadmeta.NewParams()),
+		fx.Replace(config.MockParams{Overrides: map[string]interface{}{
+			"admission_controller.inject_config.apm_ingestion_rules": `[{"namespaces": ["payments"], "pod_selector": {"app": "checkout"}, "env": "prod", "tags": {"team": "checkout"}}]`,
+		}}),
+	)
+	webhook := NewWebhook(wmeta)
+	injected, err := webhook.inject(pod, "payments", nil)
+	assert.Nil(t, err)
+	assert.True(t, injected)
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "DD_ENV", Value: "prod"})
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "DD_TAGS", Value: "team:checkout"})
+}
+
 func TestInjectIdentity(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+)
+
+const (
+	traceSampleRateEnvVarName = "DD_TRACE_SAMPLE_RATE"
+	envEnvVarName             = "DD_ENV"
+	tagsEnvVarName            = "DD_TAGS"
+)
+
+// ingestionRule maps the pods of some namespaces and workloads to the APM ingestion settings
+// injected in their containers
+type ingestionRule struct {
+	// Namespaces restricts the rule to the pods of the given namespaces, empty matches all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// PodSelector restricts the rule to the pods with the given labels, usually set by their workload
+	PodSelector map[string]string `json:"pod_selector,omitempty"`
+
+	Env        string            `json:"env,omitempty"`
+	SampleRate *float64          `json:"sample_rate,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+func (r ingestionRule) matches(pod *corev1.Pod, ns string) bool {
+	if len(r.Namespaces) > 0 && !slices.Contains(r.Namespaces, ns) {
+		return false
+	}
+	return labels.SelectorFromSet(r.PodSelector).Matches(labels.Set(pod.GetLabels()))
+}
+
+// parseIngestionRules parses the rules of admission_controller.inject_config.apm_ingestion_rules
+func parseIngestionRules(raw string) ([]ingestionRule, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var rules []ingestionRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse admission_controller.inject_config.apm_ingestion_rules: %v", err)
+	}
+	for i, rule := range rules {
+		if rule.SampleRate != nil && (*rule.SampleRate < 0 || *rule.SampleRate > 1) {
+			return nil, fmt.Errorf("invalid sample rate %v in APM ingestion rule %d, must be between 0 and 1", *rule.SampleRate, i)
+		}
+	}
+	return rules, nil
+}
+
+// ingestionEnvVars returns the env vars of the settings of the rules matching the pod. The settings
+// of all the matching rules are merged, the first rules taking precedence.
+func ingestionEnvVars(rules []ingestionRule, pod *corev1.Pod, ns string) []corev1.EnvVar {
+	var env string
+	var sampleRate *float64
+	tags := map[string]string{}
+	for _, rule := range rules {
+		if !rule.matches(pod, ns) {
+			continue
+		}
+		if env == "" {
+			env = rule.Env
+		}
+		if sampleRate == nil {
+			sampleRate = rule.SampleRate
+		}
+		for k, v := range rule.Tags {
+			if _, found := tags[k]; !found {
+				tags[k] = v
+			}
+		}
+	}
+
+	var envVars []corev1.EnvVar
+	if env != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: envEnvVarName, Value: env})
+	}
+	if sampleRate != nil {
+		envVars = append(envVars, corev1.EnvVar{Name: traceSampleRateEnvVarName, Value: strconv.FormatFloat(*sampleRate, 'f', -1, 64)})
+	}
+	if len(tags) > 0 {
+		pairs := make([]string, 0, len(tags))
+		for k, v := range tags {
+			pairs = append(pairs, k+":"+v)
+		}
+		sort.Strings(pairs)
+		envVars = append(envVars, corev1.EnvVar{Name: tagsEnvVarName, Value: strings.Join(pairs, ",")})
+	}
+	return envVars
+}
+
+// injectIngestionSettings injects the APM ingestion settings of the rules matching the pod,
+// the env vars already set on its containers are left untouched
+func injectIngestionSettings(rules []ingestionRule, pod *corev1.Pod, ns string) bool {
+	injected := false
+	for _, envVar := range ingestionEnvVars(rules, pod, ns) {
+		injected = common.InjectEnv(pod, envVar) || injected
+	}
+	return injected
+}
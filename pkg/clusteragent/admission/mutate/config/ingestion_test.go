@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build kubeapiserver
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	mutatecommon "github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/common"
+)
+
+func TestParseIngestionRules(t *testing.T) {
+	rules, err := parseIngestionRules(`[{"namespaces": ["payments"], "pod_selector": {"team": "checkout"}, "env": "prod", "sample_rate": 0.5, "tags": {"team": "checkout"}}]`)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, []string{"payments"}, rules[0].Namespaces)
+	assert.Equal(t, map[string]string{"team": "checkout"}, rules[0].PodSelector)
+	assert.Equal(t, "prod", rules[0].Env)
+	assert.Equal(t, 0.5, *rules[0].SampleRate)
+
+	rules, err = parseIngestionRules("[]")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	_, err = parseIngestionRules(`[{"sample_rate": 1.5}]`)
+	assert.Error(t, err)
+
+	_, err = parseIngestionRules(`{"env": "prod"}`)
+	assert.Error(t, err)
+}
+
+func TestIngestionEnvVars(t *testing.T) {
+	rules, err := parseIngestionRules(`[
+		{"namespaces": ["payments"], "pod_selector": {"app": "checkout"}, "sample_rate": 1, "tags": {"team": "checkout"}},
+		{"namespaces": ["payments"], "env": "prod", "sample_rate": 0.25, "tags": {"team": "payments", "cost-center": "42"}},
+		{"env": "staging"}
+	]`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		ns       string
+		expected []corev1.EnvVar
+	}{
+		{
+			name: "workload rule takes precedence over namespace rule",
+			pod:  mutatecommon.FakePodWithLabel("app", "checkout"),
+			ns:   "payments",
+			expected: []corev1.EnvVar{
+				{Name: "DD_ENV", Value: "prod"},
+				{Name: "DD_TRACE_SAMPLE_RATE", Value: "1"},
+				{Name: "DD_TAGS", Value: "cost-center:42,team:checkout"},
+			},
+		},
+		{
+			name: "namespace rule",
+			pod:  mutatecommon.FakePodWithLabel("app", "refunds"),
+			ns:   "payments",
+			expected: []corev1.EnvVar{
+				{Name: "DD_ENV", Value: "prod"},
+				{Name: "DD_TRACE_SAMPLE_RATE", Value: "0.25"},
+				{Name: "DD_TAGS", Value: "cost-center:42,team:payments"},
+			},
+		},
+		{
+			name: "catch-all rule",
+			pod:  mutatecommon.FakePod("foo"),
+			ns:   "default",
+			expected: []corev1.EnvVar{
+				{Name: "DD_ENV", Value: "staging"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ingestionEnvVars(rules, tt.pod, tt.ns))
+		})
+	}
+
+	assert.Empty(t, ingestionEnvVars(nil, mutatecommon.FakePod("foo"), "default"))
+}
+
+func TestInjectIngestionSettingsKeepsExistingEnv(t *testing.T) {
+	rules, err := parseIngestionRules(`[{"env": "prod", "sample_rate": 0.5}]`)
+	require.NoError(t, err)
+
+	pod := mutatecommon.FakePodWithEnvValue("foo", "DD_ENV", "dev")
+	assert.True(t, injectIngestionSettings(rules, pod, "default"))
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "DD_ENV", Value: "dev"})
+	assert.NotContains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "DD_ENV", Value: "prod"})
+	assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "DD_TRACE_SAMPLE_RATE", Value: "0.5"})
+
+	assert.False(t, injectIngestionSettings(rules, pod, "default"))
+}
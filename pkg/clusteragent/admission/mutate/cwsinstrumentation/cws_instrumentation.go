@@ -735,8 +735,8 @@ func injectCWSInitContainer(pod *corev1.Pod, resources *corev1.ResourceRequireme
 func labelSelectors(useNamespaceSelector bool) (namespaceSelector, objectSelector *metav1.LabelSelector) {
 	var labelSelector metav1.LabelSelector
 
-	if config.Datadog().GetBool("admission_controller.cws_instrumentation.mutate_unlabelled") ||
-		config.Datadog().GetBool("admission_controller.mutate_unlabelled") {
+	if config.Datadog().Features().Enabled(config.FeatureCWSInstrumentationMutateUnlabelled) ||
+		config.Datadog().Features().Enabled(config.FeatureMutateUnlabelled) {
 		// Accept all, ignore pods if they're explicitly filtered-out
 		labelSelector = metav1.LabelSelector{
 			MatchExpressions: []metav1.LabelSelectorRequirement{
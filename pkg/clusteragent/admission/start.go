@@ -14,6 +14,7 @@ import (
 	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
 	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/controllers/secret"
 	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/controllers/webhook"
+	"github.com/DataDog/datadog-agent/pkg/clusteragent/admission/mutate/autoinstrumentation"
 	"github.com/DataDog/datadog-agent/pkg/clusteragent/autoscaling/workload"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/kubernetes/apiserver"
@@ -86,6 +87,11 @@ func StartControllers(ctx ControllerContext, wmeta workloadmeta.Component, pa wo
 	ctx.SecretInformers.Start(ctx.StopCh)
 	ctx.WebhookInformers.Start(ctx.StopCh)
 
+	if config.Datadog().GetBool("admission_controller.auto_instrumentation.enabled") &&
+		config.Datadog().GetBool("admission_controller.auto_instrumentation.drift_detection.enabled") {
+		startDriftController(ctx, wmeta)
+	}
+
 	informers := map[apiserver.InformerName]cache.SharedInformer{
 		apiserver.SecretsInformer: ctx.SecretInformers.Core().V1().Secrets().Informer(),
 	}
@@ -100,3 +106,24 @@ func StartControllers(ctx ControllerContext, wmeta workloadmeta.Component, pa wo
 
 	return webhookController.EnabledWebhooks(), apiserver.SyncInformers(informers, 0)
 }
+
+// startDriftController starts the controller detecting the pods injected with an outdated auto
+// instrumentation configuration
+func startDriftController(ctx ControllerContext, wmeta workloadmeta.Component) {
+	apmWebhook, err := autoinstrumentation.GetWebhook(wmeta)
+	if err != nil {
+		log.Errorf("Cannot start the injection drift controller: %v", err)
+		return
+	}
+	podInformers := informers.NewSharedInformerFactory(ctx.Client, 0)
+	driftController := autoinstrumentation.NewDriftController(
+		apmWebhook,
+		ctx.Client,
+		podInformers.Core().V1().Pods(),
+		ctx.IsLeaderFunc,
+		config.Datadog().GetDuration("admission_controller.auto_instrumentation.drift_detection.interval"),
+		config.Datadog().GetBool("admission_controller.auto_instrumentation.drift_detection.rolling_restart"),
+	)
+	go driftController.Run(ctx.StopCh)
+	podInformers.Start(ctx.StopCh)
+}
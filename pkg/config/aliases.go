@@ -182,7 +182,17 @@ const (
 	DefaultProcessEventsEndpoint             = pkgconfigsetup.DefaultProcessEventsEndpoint
 )
 
+// Aliases for feature flags
+const (
+	FeatureMutateUnlabelled                   = pkgconfigsetup.FeatureMutateUnlabelled
+	FeatureCWSInstrumentationMutateUnlabelled = pkgconfigsetup.FeatureCWSInstrumentationMutateUnlabelled
+	FeatureAPMInstrumentation                 = pkgconfigsetup.FeatureAPMInstrumentation
+	FeatureExcludePauseContainer              = pkgconfigsetup.FeatureExcludePauseContainer
+)
+
 type (
+	// FeatureFlag Alias
+	FeatureFlag = model.FeatureFlag
 	// ConfigurationProviders Alias
 	ConfigurationProviders = pkgconfigsetup.ConfigurationProviders
 	// Listeners Alias
@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CheckBindings returns a description of each conflict found between the env var bindings and
+// the aliases of the configuration:
+//   - an env var bound to several keys with defaults of different types, a value set through it
+//     can't be valid for all of them
+//   - an alias resolving to itself, or registered again for another key, which Viper ignores
+//
+// Conflicts are returned sorted so that the output is stable across runs.
+func (c *safeConfig) CheckBindings() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	var conflicts []string
+	defaults := c.configSources[SourceDefault]
+	for envVar, keys := range c.envBindings {
+		if len(keys) < 2 {
+			continue
+		}
+		types := map[reflect.Type][]string{}
+		for _, key := range keys {
+			if value := defaults.Get(key); value != nil {
+				types[reflect.TypeOf(value)] = append(types[reflect.TypeOf(value)], key)
+			}
+		}
+		if len(types) < 2 {
+			continue
+		}
+		var descriptions []string
+		for t, keys := range types {
+			for _, key := range keys {
+				descriptions = append(descriptions, fmt.Sprintf("%s (%s)", key, t))
+			}
+		}
+		sort.Strings(descriptions)
+		conflicts = append(conflicts, fmt.Sprintf("env var %s is bound to keys of different types: %s", envVar, strings.Join(descriptions, ", ")))
+	}
+
+	for alias := range c.aliases {
+		if cycle := c.aliasCycle(alias); cycle != nil {
+			conflicts = append(conflicts, fmt.Sprintf("alias %q is part of a cycle: %s", alias, strings.Join(cycle, " -> ")))
+		}
+	}
+	conflicts = append(conflicts, c.aliasConflicts...)
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// aliasCycle returns the chain of aliases leading back to the given alias, or nil if the alias
+// resolves to a key.
+// aliasCycle must be called while holding the config lock (read or write).
+func (c *safeConfig) aliasCycle(alias string) []string {
+	chain := []string{alias}
+	visited := map[string]struct{}{alias: {}}
+	key := alias
+	for {
+		next, ok := c.aliases[key]
+		if !ok {
+			return nil
+		}
+		chain = append(chain, next)
+		if next == alias {
+			return chain
+		}
+		if _, ok := visited[next]; ok {
+			// the chain loops without going through this alias, the cycle is reported from its members
+			return nil
+		}
+		visited[next] = struct{}{}
+		key = next
+	}
+}
@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBindingsEnvVarConflicts(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("first_key", "value", "DD_SHARED")
+	config.BindEnvAndSetDefault("second_key", 10, "DD_SHARED")
+	// keys of the same type can share an env var
+	config.BindEnvAndSetDefault("third_key", "value", "DD_OTHER")
+	config.BindEnvAndSetDefault("fourth_key", "other value", "DD_OTHER")
+	// keys without default don't have a type
+	config.BindEnv("fifth_key", "DD_OTHER")
+
+	assert.Equal(t, []string{
+		"env var DD_SHARED is bound to keys of different types: first_key (string), second_key (int)",
+	}, config.CheckBindings())
+}
+
+func TestCheckBindingsAliasCycles(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("key", "value")
+	config.RegisterAlias("old_key", "key")
+	assert.Empty(t, config.CheckBindings())
+
+	config.RegisterAlias("a", "b")
+	config.RegisterAlias("b", "a")
+	config.RegisterAlias("old_key", "other_key")
+
+	assert.Equal(t, []string{
+		`alias "a" is part of a cycle: a -> b -> a`,
+		`alias "b" is part of a cycle: b -> a -> b`,
+		`alias "old_key" registered for "other_key" is already an alias of "key"`,
+	}, config.CheckBindings())
+}
+
+func TestRegisterAlias(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("key", "value")
+	config.RegisterAlias("old_key", "key")
+
+	assert.Equal(t, "value", config.GetString("old_key"))
+	assert.True(t, config.IsKnown("old_key"))
+	config.Set("old_key", "new value", SourceAgentRuntime)
+	assert.Equal(t, "new value", config.GetString("key"))
+	assert.Equal(t, SourceAgentRuntime, config.GetSource("key"))
+}
@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"math"
+	"runtime"
+)
+
+// HostResources describes the resources of the host the configuration is loaded on.
+type HostResources struct {
+	// NumCPU is the number of logical CPUs usable by the process.
+	NumCPU int
+	// TotalMemory is the total amount of physical memory in bytes, 0 if unknown.
+	TotalMemory uint64
+}
+
+// ComputedDefault computes the default value of a setting from the host resources.
+type ComputedDefault func(host HostResources) interface{}
+
+// getHostResources returns the resources of the current host, it is replaced in tests.
+var getHostResources = func() HostResources {
+	return HostResources{
+		NumCPU:      runtime.NumCPU(),
+		TotalMemory: totalMemory(),
+	}
+}
+
+// PercentOfTotalMemory returns a ComputedDefault resolving to the given percentage of the
+// host total memory, in bytes. fallback is used when the total memory can't be retrieved.
+func PercentOfTotalMemory(percent float64, fallback uint64) ComputedDefault {
+	return func(host HostResources) interface{} {
+		if host.TotalMemory == 0 {
+			return fallback
+		}
+		return uint64(float64(host.TotalMemory) * percent / 100)
+	}
+}
+
+// FractionOfCPUs returns a ComputedDefault resolving to the given fraction of the host CPUs,
+// rounded down and never lower than min.
+func FractionOfCPUs(fraction float64, min int) ComputedDefault {
+	return func(host HostResources) interface{} {
+		n := int(math.Floor(float64(host.NumCPU) * fraction))
+		if n < min {
+			return min
+		}
+		return n
+	}
+}
+
+// SetComputedDefault sets the default value of a setting to the value computed from the host
+// resources. The resolved value is reported by AllSettings like any other default, with the
+// SourceComputedDefault source.
+func (c *safeConfig) SetComputedDefault(key string, compute ComputedDefault) {
+	value := compute(getHostResources())
+	c.Lock()
+	defer c.Unlock()
+	if err := c.checkWritable(key, SourceComputedDefault); err != nil {
+		reportSealedWrite(err)
+		return
+	}
+	c.written()
+	c.configSources[SourceComputedDefault].Set(key, value)
+	c.Viper.SetDefault(key, value)
+}
+
+// BindEnvAndSetComputedDefault implements the Config interface
+func (c *safeConfig) BindEnvAndSetComputedDefault(key string, compute ComputedDefault, env ...string) {
+	c.SetComputedDefault(key, compute)
+	c.BindEnv(append([]string{key}, env...)...) //nolint:errcheck
+}
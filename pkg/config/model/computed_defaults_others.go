@@ -0,0 +1,13 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !linux
+
+package model
+
+// totalMemory is only implemented on Linux, computed defaults fall back to their static value elsewhere.
+func totalMemory() uint64 {
+	return 0
+}
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHostResources(t *testing.T, host HostResources) {
+	previous := getHostResources
+	getHostResources = func() HostResources { return host }
+	t.Cleanup(func() { getHostResources = previous })
+}
+
+func TestComputedDefault(t *testing.T) {
+	mockHostResources(t, HostResources{NumCPU: 8, TotalMemory: 16 * 1024 * 1024 * 1024})
+
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetComputedDefault("cache.max_memory", PercentOfTotalMemory(5, 1024))
+	config.BindEnvAndSetComputedDefault("workers", FractionOfCPUs(0.5, 1))
+
+	assert.Equal(t, uint64(858993459), config.Get("cache.max_memory"))
+	assert.Equal(t, 4, config.GetInt("workers"))
+	assert.Equal(t, SourceComputedDefault, config.GetSource("workers"))
+	assert.Equal(t, map[string]interface{}{
+		"cache":   map[string]interface{}{"max_memory": uint64(858993459)},
+		"workers": 4,
+	}, config.AllSettings())
+	assert.Empty(t, config.AllSettingsWithoutDefault())
+
+	t.Setenv("DD_WORKERS", "12")
+	assert.Equal(t, 12, config.GetInt("workers"))
+
+	config.Set("cache.max_memory", 2048, SourceFile)
+	assert.Equal(t, 2048, config.GetInt("cache.max_memory"))
+	assert.Equal(t, SourceFile, config.GetSource("cache.max_memory"))
+}
+
+func TestComputedDefaultFallbacks(t *testing.T) {
+	mockHostResources(t, HostResources{NumCPU: 1})
+
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetComputedDefault("cache.max_memory", PercentOfTotalMemory(5, 1024))
+	config.SetComputedDefault("workers", FractionOfCPUs(0.5, 1))
+
+	assert.Equal(t, uint64(1024), config.Get("cache.max_memory"))
+	assert.Equal(t, 1, config.GetInt("workers"))
+}
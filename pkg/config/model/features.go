@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// FeatureFlag is a boolean setting turning a feature of the agent on or off, identified by its key
+type FeatureFlag string
+
+// FeatureFlagReceiver is called with the new state of a feature flag each time it changes. It is called
+// once the configuration is unlocked and shouldn't block.
+type FeatureFlagReceiver func(flag FeatureFlag, enabled bool)
+
+// FeatureFlags is the set of the feature flags of a configuration. Their values are resolved like any
+// other setting, from the defaults, files, environment variables, remote config, etc., but they are
+// cached so that they can be looked up in constant time on hot paths. The cache is invalidated by
+// any write to the configuration.
+type FeatureFlags struct {
+	config *safeConfig
+
+	// m serializes the updates of the state, lookups don't take it once the state is up to date
+	m         sync.Mutex
+	state     atomic.Pointer[featureFlagsState]
+	receivers map[FeatureFlag][]FeatureFlagReceiver
+}
+
+// featureFlagsState is an immutable set of the values of the flags, at a given number of writes to
+// the configuration
+type featureFlagsState struct {
+	writes  uint64
+	enabled map[FeatureFlag]bool
+}
+
+func newFeatureFlags(config *safeConfig) *FeatureFlags {
+	f := &FeatureFlags{
+		config:    config,
+		receivers: map[FeatureFlag][]FeatureFlagReceiver{},
+	}
+	f.state.Store(&featureFlagsState{enabled: map[FeatureFlag]bool{}})
+	return f
+}
+
+// Enabled returns whether the feature flag is on
+func (f *FeatureFlags) Enabled(flag FeatureFlag) bool {
+	state := f.state.Load()
+	if state.writes == f.config.writes.Load() {
+		if enabled, found := state.enabled[flag]; found {
+			return enabled
+		}
+	}
+	return f.refresh(flag)[flag]
+}
+
+// OnChange adds a receiver called each time the given feature flag is turned on or off
+func (f *FeatureFlags) OnChange(flag FeatureFlag, receiver FeatureFlagReceiver) {
+	f.m.Lock()
+	f.receivers[flag] = append(f.receivers[flag], receiver)
+	f.m.Unlock()
+	// track the flag so that its changes are noticed
+	f.refresh(flag)
+}
+
+// refresh resolves the tracked flags and the given ones again if the configuration was written to
+// since they were cached, notifies the receivers of the flags that changed and returns their values.
+func (f *FeatureFlags) refresh(flags ...FeatureFlag) map[FeatureFlag]bool {
+	type change struct {
+		flag      FeatureFlag
+		enabled   bool
+		receivers []FeatureFlagReceiver
+	}
+	var changes []change
+
+	f.m.Lock()
+	previous := f.state.Load()
+	writes := f.config.writes.Load()
+	missing := false
+	for _, flag := range flags {
+		if _, found := previous.enabled[flag]; !found {
+			missing = true
+		}
+	}
+	if previous.writes == writes && !missing {
+		f.m.Unlock()
+		return previous.enabled
+	}
+
+	state := &featureFlagsState{
+		writes:  writes,
+		enabled: make(map[FeatureFlag]bool, len(previous.enabled)+len(flags)),
+	}
+	for flag := range previous.enabled {
+		state.enabled[flag] = f.config.GetBool(string(flag))
+	}
+	for _, flag := range flags {
+		state.enabled[flag] = f.config.GetBool(string(flag))
+	}
+	for flag, enabled := range state.enabled {
+		if wasEnabled, found := previous.enabled[flag]; found && wasEnabled != enabled && len(f.receivers[flag]) > 0 {
+			changes = append(changes, change{flag: flag, enabled: enabled, receivers: f.receivers[flag]})
+		}
+	}
+	f.state.Store(state)
+	f.m.Unlock()
+
+	for _, c := range changes {
+		for _, receiver := range c.receivers {
+			receiver(c.flag, c.enabled)
+		}
+	}
+	return state.enabled
+}
@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	t.Setenv("DD_FEATURE_ENV", "true")
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetConfigType("yaml")
+	config.BindEnvAndSetDefault("feature.default", true)
+	config.BindEnvAndSetDefault("feature.file", false)
+	config.BindEnvAndSetDefault("feature.env", false)
+	config.BindEnvAndSetDefault("feature.rc", false)
+
+	features := config.Features()
+	assert.True(t, features.Enabled("feature.default"))
+	assert.False(t, features.Enabled("feature.file"))
+	assert.True(t, features.Enabled("feature.env"))
+	assert.False(t, features.Enabled("feature.rc"))
+	assert.False(t, features.Enabled("feature.unknown"))
+
+	// the cached values are invalidated by writes that aren't notified
+	err := config.ReadConfig(bytes.NewBufferString("feature:\n  file: true\n"))
+	assert.NoError(t, err)
+	assert.True(t, features.Enabled("feature.file"))
+
+	config.Set("feature.rc", true, SourceRC)
+	assert.True(t, features.Enabled("feature.rc"))
+	config.UnsetForSource("feature.rc", SourceRC)
+	assert.False(t, features.Enabled("feature.rc"))
+}
+
+func TestFeatureFlagsOnChange(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("feature.rc", false)
+	config.BindEnvAndSetDefault("feature.other", false)
+
+	var changes []bool
+	config.Features().OnChange("feature.rc", func(flag FeatureFlag, enabled bool) {
+		assert.Equal(t, FeatureFlag("feature.rc"), flag)
+		changes = append(changes, enabled)
+	})
+
+	config.Set("feature.other", true, SourceRC)
+	assert.Empty(t, changes)
+
+	config.Set("feature.rc", true, SourceRC)
+	config.Set("feature.rc", true, SourceCLI)
+	config.UnsetForSource("feature.rc", SourceCLI)
+	config.UnsetForSource("feature.rc", SourceRC)
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestFeatureFlagsConcurrency(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("feature", false)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for n := 0; n <= 1000; n++ {
+			config.Features().Enabled("feature")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n <= 1000; n++ {
+			config.Set("feature", n%2 == 0, SourceRC)
+		}
+	}()
+	wg.Wait()
+	assert.True(t, config.Features().Enabled("feature"))
+}
+
+func BenchmarkFeatureFlagsEnabled(b *testing.B) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("feature", true)
+
+	b.Run("GetBool", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			config.GetBool("feature")
+		}
+	})
+	b.Run("Features", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			config.Features().Enabled("feature")
+		}
+	})
+}
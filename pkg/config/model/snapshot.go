@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mohae/deepcopy"
+)
+
+// ErrStaleGeneration is returned when reading the configuration at a generation it has moved past
+var ErrStaleGeneration = errors.New("configuration generation is stale")
+
+// Snapshot is a consistent copy of the settings of the configuration at a given generation, it
+// isn't affected by the changes made to the configuration afterwards
+type Snapshot struct {
+	generation uint64
+	settings   map[string]interface{}
+}
+
+// Generation returns the generation of the configuration the snapshot was taken at
+func (s *Snapshot) Generation() uint64 {
+	return s.generation
+}
+
+// Get returns a copy of the value of the key in the snapshot, or nil if it isn't set
+func (s *Snapshot) Get(key string) interface{} {
+	return deepcopy.Copy(s.settings[strings.ToLower(key)])
+}
+
+// IsSet returns whether the key has a value in the snapshot
+func (s *Snapshot) IsSet(key string) bool {
+	_, found := s.settings[strings.ToLower(key)]
+	return found
+}
+
+// Keys returns the keys set in the snapshot, lowercased
+func (s *Snapshot) Keys() []string {
+	keys := make([]string, 0, len(s.settings))
+	for key := range s.settings {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"time"
+)
+
+// Telemetry receives measurements of the hot paths of the configuration, so that their regressions are
+// visible in the agent telemetry. This package doesn't depend on the telemetry of the agent, the
+// implementation is registered with SetTelemetry by the config component, see Instrumented.
+//
+// The methods are called synchronously, on the path of the measured operations, and must be cheap.
+type Telemetry interface {
+	// SetCalled is called for each call to Set with the source of the write and whether it changed the value
+	SetCalled(source Source, changed bool)
+	// ReceiversNotified is called after the receivers registered with OnUpdate were notified of a change,
+	// with the number of receivers and the time it took to notify all of them
+	ReceiversNotified(receivers int, duration time.Duration)
+	// SnapshotBuilt is called each time a snapshot of the settings is built by ReadAtGeneration
+	SnapshotBuilt(keys int, duration time.Duration)
+}
+
+// Instrumented is implemented by the configurations whose hot paths can be measured. It isn't part of
+// the Config interface: only the config component registers the telemetry, by asserting it.
+type Instrumented interface {
+	// SetTelemetry registers the Telemetry receiving the measurements of the configuration
+	SetTelemetry(telemetry Telemetry)
+	// SourceKeys returns the number of keys set by each source. It walks all the keys of the sources and
+	// is meant to be called when the telemetry is reported, not on the hot paths.
+	SourceKeys() map[Source]int
+}
+
+var _ Instrumented = (*safeConfig)(nil)
+
+// SetTelemetry registers the Telemetry receiving the measurements of the configuration.
+func (c *safeConfig) SetTelemetry(telemetry Telemetry) {
+	c.Lock()
+	defer c.Unlock()
+	c.telemetry = telemetry
+}
+
+// SourceKeys returns the number of keys set by each source.
+func (c *safeConfig) SourceKeys() map[Source]int {
+	c.RLock()
+	defer c.RUnlock()
+	keys := make(map[Source]int, len(sources))
+	for _, source := range sources {
+		keys[source] = len(c.configSources[source].AllKeys())
+	}
+	return keys
+}
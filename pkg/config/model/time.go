@@ -0,0 +1,82 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayouts are the layouts tried by GetTimeE when none is given
+var defaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+type timeOptions struct {
+	layouts  []string
+	location *time.Location
+}
+
+// TimeOption is an option of GetTimeE
+type TimeOption func(*timeOptions)
+
+// WithTimeLayouts sets the layouts tried in order to parse the setting, as accepted by time.Parse.
+// RFC 3339 and dates with an optional time are accepted by default.
+func WithTimeLayouts(layouts ...string) TimeOption {
+	return func(o *timeOptions) {
+		o.layouts = layouts
+	}
+}
+
+// WithTimeLocation sets the location of the times whose layout has no zone, UTC by default. The
+// times with a zone are converted to it.
+func WithTimeLocation(location *time.Location) TimeOption {
+	return func(o *timeOptions) {
+		o.location = location
+	}
+}
+
+// parseTime parses a setting value as a time
+func parseTime(value interface{}, opts ...TimeOption) (time.Time, error) {
+	o := timeOptions{
+		layouts:  defaultTimeLayouts,
+		location: time.UTC,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.In(o.location), nil
+	case string:
+		v = strings.TrimSpace(v)
+		for _, layout := range o.layouts {
+			t, err := time.ParseInLocation(layout, v, o.location)
+			if err == nil {
+				return t.In(o.location), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q doesn't match any of the layouts %q", v, o.layouts)
+	default:
+		return time.Time{}, fmt.Errorf("%v is not a time", value)
+	}
+}
+
+// parseDuration parses a duration as accepted by time.ParseDuration, a number without unit is a
+// number of nanoseconds like for GetDuration
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return time.ParseDuration(s)
+}
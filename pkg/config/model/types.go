@@ -27,6 +27,13 @@ type Proxy struct {
 // 'NotificationReceiver' should not be blocking.
 type NotificationReceiver func(setting string, oldValue, newValue any)
 
+// GenerationNotificationReceiver is the NotificationReceiver registered through 'OnUpdateWithGeneration', it also
+// receives the generation of the configuration right after the change. As notifications are sent once the
+// configuration is unlocked, the configuration may have changed again when the receiver is called: receivers
+// processing changes in batches can compare it to GetGeneration, or read the settings with ReadAtGeneration, to
+// detect and skip stale notifications.
+type GenerationNotificationReceiver func(setting string, oldValue, newValue any, generation uint64)
+
 // Reader is a subset of Config that only allows reading of configuration
 type Reader interface {
 	Get(key string) interface{}
@@ -37,9 +44,13 @@ type Reader interface {
 	GetInt64(key string) int64
 	GetFloat64(key string) float64
 	GetTime(key string) time.Time
+	// GetTimeE returns a setting as a time, strings are parsed with the layouts and in the location
+	// given as options
+	GetTimeE(key string, opts ...TimeOption) (time.Time, error)
 	GetDuration(key string) time.Duration
 	GetStringSlice(key string) []string
 	GetFloat64SliceE(key string) ([]float64, error)
+	GetDurationSliceE(key string) ([]time.Duration, error)
 	GetStringMap(key string) map[string]interface{}
 	GetStringMapString(key string) map[string]string
 	GetStringMapStringSlice(key string) map[string][]string
@@ -64,6 +75,9 @@ type Reader interface {
 
 	// UnmarshalKey Unmarshal a configuration key into a struct
 	UnmarshalKey(key string, rawVal interface{}, opts ...viper.DecoderConfigOption) error
+	// UnmarshalKeyFromSources Unmarshal a configuration key into a struct using only the values set by the given
+	// sources, and returns the source of each setting used
+	UnmarshalKeyFromSources(key string, rawVal interface{}, sources []Source, opts ...viper.DecoderConfigOption) (map[string]Source, error)
 
 	// IsKnown returns whether this key is known
 	IsKnown(key string) bool
@@ -90,6 +104,17 @@ type Reader interface {
 	// OnUpdate adds a callback to the list receivers to be called each time a value is change in the configuration
 	// by a call to the 'Set' method. The configuration will sequentially call each receiver.
 	OnUpdate(callback NotificationReceiver)
+	// OnUpdateWithGeneration is the OnUpdate counterpart for the callbacks receiving the generation of the configuration
+	OnUpdateWithGeneration(callback GenerationNotificationReceiver)
+
+	// GetGeneration returns the generation of the configuration, increased by each write that may change its values
+	GetGeneration() uint64
+	// ReadAtGeneration returns a snapshot of the settings of the configuration at the given generation. It
+	// returns ErrStaleGeneration if the configuration was changed since then.
+	ReadAtGeneration(generation uint64) (*Snapshot, error)
+
+	// Features returns the feature flags of the configuration, cached for constant-time lookups
+	Features() *FeatureFlags
 }
 
 // Writer is a subset of Config that only allows writing the configuration
@@ -138,6 +163,9 @@ type Loader interface {
 	// SetKnown adds a key to the set of known valid config keys
 	SetKnown(key string)
 
+	// RegisterAlias makes alias another name of key
+	RegisterAlias(alias string, key string)
+
 	// API not implemented by viper.Viper and that have proven useful for our config usage
 
 	// BindEnvAndSetDefault sets the default value for a config parameter and adds an env binding
@@ -146,6 +174,24 @@ type Loader interface {
 	// If env is provided, it will override the name of the environment variable used for this
 	// config key
 	BindEnvAndSetDefault(key string, val interface{}, env ...string)
+
+	// SetComputedDefault sets the default value of a config parameter to the value computed
+	// from the host resources, e.g. a percentage of the total memory.
+	SetComputedDefault(key string, compute ComputedDefault)
+
+	// BindEnvAndSetComputedDefault is the BindEnvAndSetDefault counterpart of SetComputedDefault.
+	BindEnvAndSetComputedDefault(key string, compute ComputedDefault, env ...string)
+
+	// Seal freezes the configuration, only the given sources can write to it afterwards
+	Seal(allowedSources ...Source)
+
+	// RegisterRuntimeOnlyKey registers a key only set at runtime with SourceRuntimeOnly, never read from
+	// files or env vars and excluded from the dumps of the settings
+	RegisterRuntimeOnlyKey(key string, defaultValue interface{})
+
+	// CheckBindings returns a description of each env var bound to keys of different types
+	// and of each alias that can't be resolved, which are silently ignored otherwise.
+	CheckBindings() []string
 }
 
 // Config represents an object that can load and store configuration parameters
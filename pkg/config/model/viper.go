@@ -12,9 +12,11 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"path/filepath"
@@ -35,6 +37,8 @@ type Source string
 const (
 	// SourceDefault are the values from defaults.
 	SourceDefault Source = "default"
+	// SourceComputedDefault are the default values computed from the host resources (memory, CPUs, ...).
+	SourceComputedDefault Source = "computed-default"
 	// SourceUnknown are the values from unknown source. This should only be used in tests when calling
 	// SetWithoutSource.
 	SourceUnknown Source = "unknown"
@@ -53,6 +57,9 @@ const (
 	SourceRC Source = "remote-config"
 	// SourceCLI are the values set by the user at runtime through the CLI.
 	SourceCLI Source = "cli"
+	// SourceRuntimeOnly are the values of the runtime-only keys, registered with RegisterRuntimeOnlyKey. They are
+	// only set by the components at runtime, e.g. negotiated ports, and are never read from files or env vars.
+	SourceRuntimeOnly Source = "runtime-only"
 	// SourceProvided are all values set by any source but default.
 	SourceProvided Source = "provided" // everything but defaults
 )
@@ -60,6 +67,7 @@ const (
 // sources list the known sources, following the order of hierarchy between them
 var sources = []Source{
 	SourceDefault,
+	SourceComputedDefault,
 	SourceUnknown,
 	SourceFile,
 	SourceEnvVar,
@@ -67,6 +75,7 @@ var sources = []Source{
 	SourceLocalConfigProcess,
 	SourceRC,
 	SourceCLI,
+	SourceRuntimeOnly,
 }
 
 // ValueWithSource is a tuple for a source and a value, not necessarily the applied value in the main config
@@ -94,7 +103,13 @@ type safeConfig struct {
 	envPrefix      string
 	envKeyReplacer *strings.Replacer
 
-	notificationReceivers []NotificationReceiver
+	notificationReceivers []GenerationNotificationReceiver
+	// generation is increased by any write that may change the values of the configuration, see written
+	generation uint64
+	// writes is increased by any write that may change the values of the configuration, including the ones
+	// that aren't notified such as loading a file, it invalidates the feature flags cache
+	writes   atomic.Uint64
+	features *FeatureFlags
 
 	// Proxy settings
 	proxies *Proxy
@@ -103,23 +118,156 @@ type safeConfig struct {
 	// configuration values.
 	configEnvVars map[string]struct{}
 
+	// envBindings are the keys bound to each env var, and aliases the keys registered
+	// as alias of another one. Both are used to detect conflicts, see CheckBindings.
+	envBindings    map[string][]string
+	aliases        map[string]string
+	aliasConflicts []string
+
 	// keys that have been used but are unknown
 	// used to warn (a single time) on use
 	unknownKeys map[string]struct{}
 
 	// extraConfigFilePaths represents additional configuration file paths that will be merged into the main configuration when ReadInConfig() is called.
 	extraConfigFilePaths []string
+
+	// sealed is set once the configuration is sealed, only the sealAllowedSources can write to it from then on
+	sealed             bool
+	sealAllowedSources map[Source]struct{}
+
+	// telemetry receives the measurements of the configuration, nil if none is registered
+	telemetry Telemetry
+
+	// runtimeOnlyKeys are the keys only SourceRuntimeOnly can write to, lowercased
+	runtimeOnlyKeys map[string]struct{}
+}
+
+// ErrConfigSealed is returned when writing to a sealed configuration from a source that is not allowed
+var ErrConfigSealed = errors.New("configuration is sealed")
+
+// ErrRuntimeOnlyKey is returned when writing to a runtime-only key from another source than SourceRuntimeOnly,
+// or writing to another key from SourceRuntimeOnly
+var ErrRuntimeOnlyKey = errors.New("runtime-only key")
+
+// Seal freezes the configuration once it is loaded. From then on only the given sources, typically
+// the ones updated at runtime like SourceRC or SourceCLI, can write to the configuration. Writes from
+// other sources are rejected and logged as errors with the stack of the caller, to catch components
+// mutating the configuration after startup.
+func (c *safeConfig) Seal(allowedSources ...Source) {
+	c.Lock()
+	defer c.Unlock()
+	c.sealed = true
+	c.sealAllowedSources = make(map[Source]struct{}, len(allowedSources))
+	for _, source := range allowedSources {
+		c.sealAllowedSources[source] = struct{}{}
+	}
+}
+
+// checkWritable returns an error if the configuration is sealed and the source isn't allowed to write to it,
+// or if only one of the source and the key is runtime-only. The runtime-only keys can be written even once
+// the configuration is sealed.
+//
+// Must be called with the lock locked.
+func (c *safeConfig) checkWritable(key string, source Source) error {
+	if c.isRuntimeOnly(key) {
+		if source == SourceRuntimeOnly {
+			return nil
+		}
+		return fmt.Errorf("%w, source %s can't write %s", ErrRuntimeOnlyKey, source, key)
+	}
+	if source == SourceRuntimeOnly {
+		return fmt.Errorf("%w, %s isn't registered as runtime-only", ErrRuntimeOnlyKey, key)
+	}
+	if !c.sealed {
+		return nil
+	}
+	if _, ok := c.sealAllowedSources[source]; ok {
+		return nil
+	}
+	if key == "" {
+		return fmt.Errorf("%w, source %s can't write to it", ErrConfigSealed, source)
+	}
+	return fmt.Errorf("%w, source %s can't write %s", ErrConfigSealed, source, key)
+}
+
+// RegisterRuntimeOnlyKey registers a key only set by the components at runtime with SourceRuntimeOnly, e.g.
+// an internal coordination value or a negotiated port. The values of the files and env vars are never read
+// for it, the other sources can't write to it and it is excluded from the dumps of the settings. Its changes
+// are notified like the ones of the other keys. The default value must not be nil.
+func (c *safeConfig) RegisterRuntimeOnlyKey(key string, defaultValue interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	c.written()
+	key = strings.ToLower(key)
+	c.runtimeOnlyKeys[key] = struct{}{}
+	c.configSources[SourceDefault].Set(key, defaultValue)
+	c.Viper.SetDefault(key, defaultValue)
+	// the value is always overridden, so that the values of the files and env vars are never read
+	c.mergeViperInstances(key)
+}
+
+// isRuntimeOnly returns whether a key was registered with RegisterRuntimeOnlyKey
+//
+// Must be called with the lock locked.
+func (c *safeConfig) isRuntimeOnly(key string) bool {
+	_, ok := c.runtimeOnlyKeys[strings.ToLower(key)]
+	return ok
+}
+
+// removeRuntimeOnlyKeys removes the runtime-only keys from settings nested by section
+//
+// Must be called with the lock locked.
+func (c *safeConfig) removeRuntimeOnlyKeys(settings map[string]interface{}) map[string]interface{} {
+	for key := range c.runtimeOnlyKeys {
+		path := strings.Split(key, ".")
+		section := settings
+		for _, name := range path[:len(path)-1] {
+			next, ok := section[name].(map[string]interface{})
+			if !ok {
+				section = nil
+				break
+			}
+			section = next
+		}
+		if section != nil {
+			delete(section, path[len(path)-1])
+		}
+	}
+	return settings
+}
+
+// reportSealedWrite logs a rejected write with the stack of the caller, as the write methods
+// don't return errors
+func reportSealedWrite(err error) {
+	log.Errorf("%v, the write is ignored:\n%s", err, debug.Stack())
 }
 
 // OnUpdate adds a callback to the list receivers to be called each time a value is changed in the configuration
 // by a call to the 'Set' method.
 // Callbacks are only called if the value is effectively changed.
 func (c *safeConfig) OnUpdate(callback NotificationReceiver) {
+	c.OnUpdateWithGeneration(func(setting string, oldValue, newValue any, _ uint64) {
+		callback(setting, oldValue, newValue)
+	})
+}
+
+// OnUpdateWithGeneration is the OnUpdate counterpart for the callbacks receiving the generation of the
+// configuration right after the change.
+func (c *safeConfig) OnUpdateWithGeneration(callback GenerationNotificationReceiver) {
 	c.Lock()
 	defer c.Unlock()
 	c.notificationReceivers = append(c.notificationReceivers, callback)
 }
 
+// written records a write that may have changed the values of the configuration: it increases the
+// generation and invalidates the feature flags cache.
+//
+// Must be called with the lock locked.
+func (c *safeConfig) written() {
+	c.generation++
+	c.writes.Add(1)
+}
+
 // Set wraps Viper for concurrent access
 func (c *safeConfig) Set(key string, newValue interface{}, source Source) {
 	if source == SourceDefault {
@@ -128,20 +276,39 @@ func (c *safeConfig) Set(key string, newValue interface{}, source Source) {
 	}
 
 	// modify the config then release the lock to avoid deadlocks while notifying
-	var receivers []NotificationReceiver
+	var receivers []GenerationNotificationReceiver
 	c.Lock()
+	if err := c.checkWritable(key, source); err != nil {
+		c.Unlock()
+		reportSealedWrite(err)
+		return
+	}
 	previousValue := c.Viper.Get(key)
 	c.configSources[source].Set(key, newValue)
 	c.mergeViperInstances(key)
-	if !reflect.DeepEqual(previousValue, newValue) {
+	changed := !reflect.DeepEqual(previousValue, newValue)
+	if changed {
 		// if the value has not changed, do not duplicate the slice so that no callback is called
 		receivers = slices.Clone(c.notificationReceivers)
+		c.written()
+	}
+	generation := c.generation
+	telemetry := c.telemetry
+	if telemetry != nil {
+		telemetry.SetCalled(source, changed)
 	}
 	c.Unlock()
 
 	// notifying all receiver about the updated setting
+	start := time.Now()
 	for _, receiver := range receivers {
-		receiver(key, previousValue, newValue)
+		receiver(key, previousValue, newValue, generation)
+	}
+	if telemetry != nil && len(receivers) > 0 {
+		telemetry.ReceiversNotified(len(receivers), time.Since(start))
+	}
+	if changed {
+		c.features.refresh()
 	}
 }
 
@@ -154,6 +321,11 @@ func (c *safeConfig) SetWithoutSource(key string, value interface{}) {
 func (c *safeConfig) SetDefault(key string, value interface{}) {
 	c.Lock()
 	defer c.Unlock()
+	if err := c.checkWritable(key, SourceDefault); err != nil {
+		reportSealedWrite(err)
+		return
+	}
+	c.written()
 	c.configSources[SourceDefault].Set(key, value)
 	c.Viper.SetDefault(key, value)
 }
@@ -161,9 +333,59 @@ func (c *safeConfig) SetDefault(key string, value interface{}) {
 // UnsetForSource wraps Viper for concurrent access
 func (c *safeConfig) UnsetForSource(key string, source Source) {
 	c.Lock()
-	defer c.Unlock()
+	if err := c.checkWritable(key, source); err != nil {
+		c.Unlock()
+		reportSealedWrite(err)
+		return
+	}
+	previousValue := c.Viper.Get(key)
 	c.configSources[source].Set(key, nil)
 	c.mergeViperInstances(key)
+	changed := !reflect.DeepEqual(previousValue, c.Viper.Get(key))
+	if changed {
+		c.written()
+	}
+	c.Unlock()
+
+	if changed {
+		c.features.refresh()
+	}
+}
+
+// Features returns the feature flags of the configuration
+func (c *safeConfig) Features() *FeatureFlags {
+	return c.features
+}
+
+// GetGeneration returns the generation of the configuration, increased by each write that may change its values
+func (c *safeConfig) GetGeneration() uint64 {
+	c.RLock()
+	defer c.RUnlock()
+	return c.generation
+}
+
+// ReadAtGeneration returns a snapshot of the settings at the given generation, or ErrStaleGeneration
+// if the configuration was changed since then
+func (c *safeConfig) ReadAtGeneration(generation uint64) (*Snapshot, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.generation != generation {
+		return nil, fmt.Errorf("%w: requested generation %d, current generation %d", ErrStaleGeneration, generation, c.generation)
+	}
+	start := time.Now()
+	snapshot := &Snapshot{
+		generation: generation,
+		settings:   make(map[string]interface{}),
+	}
+	for _, key := range c.Viper.AllKeys() {
+		if val := c.Viper.Get(key); val != nil {
+			snapshot.settings[key] = deepcopy.Copy(val)
+		}
+	}
+	if c.telemetry != nil {
+		c.telemetry.SnapshotBuilt(len(snapshot.settings), time.Since(start))
+	}
+	return snapshot, nil
 }
 
 // mergeViperInstances is called after a change in an instance of Viper
@@ -171,7 +393,11 @@ func (c *safeConfig) UnsetForSource(key string, source Source) {
 // (it must be used with a lock to prevent concurrent access to Viper)
 func (c *safeConfig) mergeViperInstances(key string) {
 	var val interface{}
+	runtimeOnly := c.isRuntimeOnly(key)
 	for _, source := range sources {
+		if runtimeOnly && source != SourceDefault && source != SourceRuntimeOnly {
+			continue
+		}
 		if currVal := c.configSources[source].Get(key); currVal != nil {
 			val = currVal
 		}
@@ -186,6 +412,27 @@ func (c *safeConfig) SetKnown(key string) {
 	c.Viper.SetKnown(key)
 }
 
+// RegisterAlias wraps Viper for concurrent access, and keeps track of the aliases to detect
+// the ones Viper ignores: cycles and aliases registered again for another key.
+func (c *safeConfig) RegisterAlias(alias string, key string) {
+	c.Lock()
+	defer c.Unlock()
+	c.written()
+	alias = strings.ToLower(alias)
+	key = strings.ToLower(key)
+	if previous, ok := c.aliases[alias]; ok {
+		if previous != key {
+			c.aliasConflicts = append(c.aliasConflicts, fmt.Sprintf("alias %q registered for %q is already an alias of %q", alias, key, previous))
+		}
+		return
+	}
+	c.aliases[alias] = key
+	for _, source := range sources {
+		c.configSources[source].RegisterAlias(alias, key)
+	}
+	c.Viper.RegisterAlias(alias, key)
+}
+
 // IsKnown returns whether a key is known
 func (c *safeConfig) IsKnown(key string) bool {
 	c.RLock()
@@ -238,6 +485,7 @@ func (c *safeConfig) GetKnownKeysLowercased() map[string]interface{} {
 func (c *safeConfig) SetEnvKeyTransformer(key string, fn func(string) interface{}) {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 	c.Viper.SetEnvKeyTransformer(key, fn)
 }
 
@@ -403,6 +651,20 @@ func (c *safeConfig) GetTime(key string) time.Time {
 	return val
 }
 
+// GetTimeE loads a key as a time.Time, parsing strings with the given layouts. Times without zone
+// are in the given location, and all times are returned in it.
+func (c *safeConfig) GetTimeE(key string, opts ...TimeOption) (time.Time, error) {
+	c.RLock()
+	defer c.RUnlock()
+	c.checkKnownKey(key)
+
+	t, err := parseTime(c.Viper.Get(key), opts...)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value from '%v' is not a time: %w", key, err)
+	}
+	return t, nil
+}
+
 // GetDuration wraps Viper for concurrent access
 func (c *safeConfig) GetDuration(key string) time.Duration {
 	c.RLock()
@@ -450,6 +712,29 @@ func (c *safeConfig) GetFloat64SliceE(key string) ([]float64, error) {
 	return res, nil
 }
 
+// GetDurationSliceE loads a key as a []time.Duration
+func (c *safeConfig) GetDurationSliceE(key string) ([]time.Duration, error) {
+	c.RLock()
+	defer c.RUnlock()
+	c.checkKnownKey(key)
+
+	// We're using GetStringSlice because viper can only parse list of string from env variables
+	list, err := c.Viper.GetStringSliceE(key)
+	if err != nil {
+		return nil, fmt.Errorf("'%v' is not a list", key)
+	}
+
+	res := []time.Duration{}
+	for _, item := range list {
+		d, err := parseDuration(item)
+		if err != nil {
+			return nil, fmt.Errorf("value '%v' from '%v' is not a duration", item, key)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
 // GetStringMap wraps Viper for concurrent access
 func (c *safeConfig) GetStringMap(key string) map[string]interface{} {
 	c.RLock()
@@ -517,6 +802,7 @@ func (c *safeConfig) GetSource(key string) Source {
 func (c *safeConfig) SetEnvPrefix(in string) {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 	c.configSources[SourceEnvVar].SetEnvPrefix(in)
 	c.Viper.SetEnvPrefix(in)
 	c.envPrefix = in
@@ -532,6 +818,7 @@ func (c *safeConfig) mergeWithEnvPrefix(key string) string {
 func (c *safeConfig) BindEnv(input ...string) {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 	var envKeys []string
 
 	// If one input is given, viper derives an env key from it; otherwise, all inputs after
@@ -548,6 +835,10 @@ func (c *safeConfig) BindEnv(input ...string) {
 			key = c.envKeyReplacer.Replace(key)
 		}
 		c.configEnvVars[key] = struct{}{}
+		configKey := strings.ToLower(input[0])
+		if !slices.Contains(c.envBindings[key], configKey) {
+			c.envBindings[key] = append(c.envBindings[key], configKey)
+		}
 	}
 
 	_ = c.configSources[SourceEnvVar].BindEnv(input...)
@@ -558,6 +849,7 @@ func (c *safeConfig) BindEnv(input ...string) {
 func (c *safeConfig) SetEnvKeyReplacer(r *strings.Replacer) {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 	c.configSources[SourceEnvVar].SetEnvKeyReplacer(r)
 	c.Viper.SetEnvKeyReplacer(r)
 	c.envKeyReplacer = r
@@ -571,6 +863,48 @@ func (c *safeConfig) UnmarshalKey(key string, rawVal interface{}, opts ...viper.
 	return c.Viper.UnmarshalKey(key, rawVal, opts...)
 }
 
+// UnmarshalKeyFromSources unmarshals a configuration key into a struct using only the values set by the
+// given sources, all sources are used if none is given. The priority between the sources is unchanged.
+// It returns the source of each setting used, indexed by its path relative to the key.
+func (c *safeConfig) UnmarshalKeyFromSources(key string, rawVal interface{}, fromSources []Source, opts ...viper.DecoderConfigOption) (map[string]Source, error) {
+	c.RLock()
+	defer c.RUnlock()
+	c.checkKnownKey(key)
+
+	key = strings.ToLower(key)
+	values := map[string]interface{}{}
+	settingSources := map[string]Source{}
+	for _, source := range sources {
+		if len(fromSources) > 0 && !slices.Contains(fromSources, source) {
+			continue
+		}
+		for _, setting := range c.configSources[source].AllKeys() {
+			if setting != key && !strings.HasPrefix(setting, key+".") {
+				continue
+			}
+			value := c.configSources[source].Get(setting)
+			if value == nil {
+				continue
+			}
+			// sources are ordered by priority, the last one setting a value wins
+			path := strings.TrimPrefix(strings.TrimPrefix(setting, key), ".")
+			values[setting] = value
+			settingSources[path] = source
+		}
+	}
+
+	// build the value of the key from the selected settings and let viper decode it
+	// the same way UnmarshalKey does
+	filtered := viper.New()
+	for setting, value := range values {
+		filtered.Set(setting, value)
+	}
+	if err := filtered.UnmarshalKey(key, rawVal, opts...); err != nil {
+		return nil, err
+	}
+	return settingSources, nil
+}
+
 // Unmarshal wraps Viper for concurrent access
 func (c *safeConfig) Unmarshal(rawVal interface{}) error {
 	c.RLock()
@@ -589,11 +923,16 @@ func (c *safeConfig) UnmarshalExact(rawVal interface{}) error {
 func (c *safeConfig) ReadInConfig() error {
 	c.Lock()
 	defer c.Unlock()
+	if err := c.checkWritable("", SourceFile); err != nil {
+		return err
+	}
 	// ReadInConfig reset configuration with the main config file
 	err := errors.Join(c.Viper.ReadInConfig(), c.configSources[SourceFile].ReadInConfig())
 	if err != nil {
 		return err
 	}
+	// the main file replaced the settings, whether the extra files can be merged or not
+	c.written()
 
 	type extraConf struct {
 		path    string
@@ -625,6 +964,9 @@ func (c *safeConfig) ReadInConfig() error {
 func (c *safeConfig) ReadConfig(in io.Reader) error {
 	c.Lock()
 	defer c.Unlock()
+	if err := c.checkWritable("", SourceFile); err != nil {
+		return err
+	}
 	b, err := io.ReadAll(in)
 	if err != nil {
 		return err
@@ -633,6 +975,7 @@ func (c *safeConfig) ReadConfig(in io.Reader) error {
 	if err != nil {
 		return err
 	}
+	c.written()
 	return c.configSources[SourceFile].ReadConfig(bytes.NewReader(b))
 }
 
@@ -640,7 +983,14 @@ func (c *safeConfig) ReadConfig(in io.Reader) error {
 func (c *safeConfig) MergeConfig(in io.Reader) error {
 	c.Lock()
 	defer c.Unlock()
-	return c.Viper.MergeConfig(in)
+	if err := c.checkWritable("", SourceFile); err != nil {
+		return err
+	}
+	if err := c.Viper.MergeConfig(in); err != nil {
+		return err
+	}
+	c.written()
+	return nil
 }
 
 // MergeConfigMap merges the configuration from the map given with an existing config.
@@ -648,7 +998,14 @@ func (c *safeConfig) MergeConfig(in io.Reader) error {
 func (c *safeConfig) MergeConfigMap(cfg map[string]any) error {
 	c.Lock()
 	defer c.Unlock()
-	return c.Viper.MergeConfigMap(cfg)
+	if err := c.checkWritable("", SourceFile); err != nil {
+		return err
+	}
+	if err := c.Viper.MergeConfigMap(cfg); err != nil {
+		return err
+	}
+	c.written()
+	return nil
 }
 
 // AllSettings wraps Viper for concurrent access
@@ -658,7 +1015,7 @@ func (c *safeConfig) AllSettings() map[string]interface{} {
 
 	// AllSettings returns a fresh map, so the caller may do with it
 	// as they please without holding the lock.
-	return c.Viper.AllSettings()
+	return c.removeRuntimeOnlyKeys(c.Viper.AllSettings())
 }
 
 // AllSettingsWithoutDefault returns a copy of the all the settings in the configuration without defaults
@@ -668,7 +1025,7 @@ func (c *safeConfig) AllSettingsWithoutDefault() map[string]interface{} {
 
 	// AllSettingsWithoutDefault returns a fresh map, so the caller may do with it
 	// as they please without holding the lock.
-	return c.Viper.AllSettingsWithoutDefault()
+	return c.removeRuntimeOnlyKeys(c.Viper.AllSettingsWithoutDefault())
 }
 
 // AllSettingsBySource returns the settings from each source (file, env vars, ...)
@@ -678,6 +1035,7 @@ func (c *safeConfig) AllSettingsBySource() map[Source]interface{} {
 
 	sources := []Source{
 		SourceDefault,
+		SourceComputedDefault,
 		SourceUnknown,
 		SourceFile,
 		SourceEnvVar,
@@ -688,9 +1046,9 @@ func (c *safeConfig) AllSettingsBySource() map[Source]interface{} {
 	}
 	res := map[Source]interface{}{}
 	for _, source := range sources {
-		res[source] = c.configSources[source].AllSettingsWithoutDefault()
+		res[source] = c.removeRuntimeOnlyKeys(c.configSources[source].AllSettingsWithoutDefault())
 	}
-	res[SourceProvided] = c.AllSettingsWithoutDefault()
+	res[SourceProvided] = c.removeRuntimeOnlyKeys(c.Viper.AllSettingsWithoutDefault())
 	return res
 }
 
@@ -775,6 +1133,7 @@ func (c *safeConfig) SetTypeByDefaultValue(in bool) {
 func (c *safeConfig) BindPFlag(key string, flag *pflag.Flag) error {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 	return c.Viper.BindPFlag(key, flag)
 }
 
@@ -809,8 +1168,13 @@ func NewConfig(name string, envPrefix string, envKeyReplacer *strings.Replacer)
 		Viper:         viper.New(),
 		configSources: map[Source]*viper.Viper{},
 		configEnvVars: map[string]struct{}{},
+		envBindings:   map[string][]string{},
+		aliases:       map[string]string{},
 		unknownKeys:   map[string]struct{}{},
+
+		runtimeOnlyKeys: map[string]struct{}{},
 	}
+	config.features = newFeatureFlags(&config)
 
 	// load one Viper instance per source of setting change
 	for _, source := range sources {
@@ -821,6 +1185,8 @@ func NewConfig(name string, envPrefix string, envKeyReplacer *strings.Replacer)
 	config.SetConfigName(name)
 	config.SetEnvPrefix(envPrefix)
 	config.SetEnvKeyReplacer(envKeyReplacer)
+	// a new configuration starts at generation 0, whatever the setup above wrote
+	config.generation = 0
 
 	return &config
 }
@@ -830,6 +1196,7 @@ func NewConfig(name string, envPrefix string, envKeyReplacer *strings.Replacer)
 func (c *safeConfig) CopyConfig(cfg Config) {
 	c.Lock()
 	defer c.Unlock()
+	c.written()
 
 	if cfg, ok := cfg.(*safeConfig); ok {
 		c.Viper = cfg.Viper
@@ -838,8 +1205,16 @@ func (c *safeConfig) CopyConfig(cfg Config) {
 		c.envKeyReplacer = cfg.envKeyReplacer
 		c.proxies = cfg.proxies
 		c.configEnvVars = cfg.configEnvVars
+		c.envBindings = cfg.envBindings
+		c.aliases = cfg.aliases
+		c.aliasConflicts = cfg.aliasConflicts
 		c.unknownKeys = cfg.unknownKeys
 		c.notificationReceivers = cfg.notificationReceivers
+		// the generation keeps increasing so that the snapshots of both configurations are stale
+		c.generation = max(c.generation, cfg.generation) + 1
+		c.sealed = cfg.sealed
+		c.sealAllowedSources = cfg.sealAllowedSources
+		c.runtimeOnlyKeys = cfg.runtimeOnlyKeys
 		return
 	}
 	panic("Replacement config must be an instance of safeConfig")
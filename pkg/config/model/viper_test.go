@@ -13,8 +13,10 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConcurrencySetGet(t *testing.T) {
@@ -162,6 +164,88 @@ float_list:
 	assert.Equal(t, []float64{1.1, 2.2, 3.3}, list)
 }
 
+func TestGetDurationSliceE(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+
+	config.BindEnv("duration_list")
+	config.SetConfigType("yaml")
+	yamlExample := []byte(`---
+duration_list:
+  - 30s
+  - "1h30m"
+  - 100
+`)
+	config.ReadConfig(bytes.NewBuffer(yamlExample))
+
+	list, err := config.GetDurationSliceE("duration_list")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{30 * time.Second, 90 * time.Minute, 100}, list)
+
+	t.Setenv("DD_DURATION_LIST", "5m 2h")
+	list, err = config.GetDurationSliceE("duration_list")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{5 * time.Minute, 2 * time.Hour}, list)
+
+	t.Setenv("DD_DURATION_LIST", "5m tomorrow")
+	list, err = config.GetDurationSliceE("duration_list")
+	assert.NotNil(t, err)
+	assert.Equal(t, "value 'tomorrow' from 'duration_list' is not a duration", err.Error())
+	assert.Nil(t, list)
+}
+
+func TestGetTimeE(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnv("start")
+
+	t.Setenv("DD_START", "2024-03-01T10:00:00Z")
+	start, err := config.GetTimeE("start")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), start)
+
+	// times with a zone are converted to the location
+	start, err = config.GetTimeE("start", WithTimeLocation(paris))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 11, 0, 0, 0, paris), start)
+	assert.Equal(t, paris, start.Location())
+
+	// times without zone are in the location
+	t.Setenv("DD_START", "2024-03-01 10:00:00")
+	start, err = config.GetTimeE("start")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), start)
+	start, err = config.GetTimeE("start", WithTimeLocation(paris))
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC).Equal(start))
+
+	t.Setenv("DD_START", "01/03/2024 10:00")
+	_, err = config.GetTimeE("start")
+	assert.Error(t, err)
+	start, err = config.GetTimeE("start", WithTimeLayouts("02/01/2006 15:04"), WithTimeLocation(paris))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, paris), start)
+
+}
+
+func TestGetTimeESet(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetKnown("start")
+
+	config.SetWithoutSource("start", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	start, err := config.GetTimeE("start", WithTimeLocation(paris))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 11, 0, 0, 0, paris), start)
+
+	config.SetWithoutSource("start", 42)
+	_, err = config.GetTimeE("start")
+	assert.Error(t, err)
+}
+
 func TestIsSectionSet(t *testing.T) {
 	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
 
@@ -219,6 +303,86 @@ func TestGetSource(t *testing.T) {
 	assert.Equal(t, SourceEnvVar, config.GetSource("foo"))
 }
 
+func TestUnmarshalKeyFromSources(t *testing.T) {
+	type endpoint struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
+	}
+	type settings struct {
+		Enabled   bool     `mapstructure:"enabled"`
+		Endpoint  endpoint `mapstructure:"endpoint"`
+		Hostnames []string `mapstructure:"hostnames"`
+	}
+
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("section.enabled", false)
+	config.SetDefault("section.endpoint.port", 80)
+	config.SetDefault("section.hostnames", []string{"localhost"})
+	config.Set("section.endpoint.host", "file.example", SourceFile)
+	config.Set("section.endpoint.port", 8080, SourceFile)
+	config.Set("section.enabled", true, SourceRC)
+	config.Set("section.endpoint.host", "rc.example", SourceRC)
+	config.Set("other.enabled", true, SourceFile)
+
+	var all settings
+	allSources, err := config.UnmarshalKeyFromSources("section", &all, nil)
+	require.NoError(t, err)
+	assert.Equal(t, settings{Enabled: true, Endpoint: endpoint{Host: "rc.example", Port: 8080}, Hostnames: []string{"localhost"}}, all)
+	assert.Equal(t, map[string]Source{
+		"enabled":       SourceRC,
+		"endpoint.host": SourceRC,
+		"endpoint.port": SourceFile,
+		"hostnames":     SourceDefault,
+	}, allSources)
+
+	// ignore remote config
+	var local settings
+	localSources, err := config.UnmarshalKeyFromSources("section", &local, []Source{SourceDefault, SourceFile, SourceEnvVar})
+	require.NoError(t, err)
+	assert.Equal(t, settings{Enabled: false, Endpoint: endpoint{Host: "file.example", Port: 8080}, Hostnames: []string{"localhost"}}, local)
+	assert.Equal(t, map[string]Source{
+		"enabled":       SourceDefault,
+		"endpoint.host": SourceFile,
+		"endpoint.port": SourceFile,
+		"hostnames":     SourceDefault,
+	}, localSources)
+
+	// only the operator provided values
+	var file settings
+	fileSources, err := config.UnmarshalKeyFromSources("section", &file, []Source{SourceFile})
+	require.NoError(t, err)
+	assert.Equal(t, settings{Endpoint: endpoint{Host: "file.example", Port: 8080}}, file)
+	assert.Equal(t, map[string]Source{
+		"endpoint.host": SourceFile,
+		"endpoint.port": SourceFile,
+	}, fileSources)
+
+	var port int
+	portSources, err := config.UnmarshalKeyFromSources("section.endpoint.port", &port, []Source{SourceDefault})
+	require.NoError(t, err)
+	assert.Equal(t, 80, port)
+	assert.Equal(t, map[string]Source{"": SourceDefault}, portSources)
+}
+
+func TestUnmarshalKeyFromSourcesEnvVar(t *testing.T) {
+	t.Setenv("DD_SECTION_ENDPOINT_HOST", "env.example")
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.BindEnvAndSetDefault("section.endpoint.host", "default.example")
+
+	var endpoint struct {
+		Host string `mapstructure:"host"`
+	}
+	sources, err := config.UnmarshalKeyFromSources("section.endpoint", &endpoint, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "env.example", endpoint.Host)
+	assert.Equal(t, map[string]Source{"host": SourceEnvVar}, sources)
+
+	sources, err = config.UnmarshalKeyFromSources("section.endpoint", &endpoint, []Source{SourceDefault})
+	require.NoError(t, err)
+	assert.Equal(t, "default.example", endpoint.Host)
+	assert.Equal(t, map[string]Source{"host": SourceDefault}, sources)
+}
+
 func TestIsSet(t *testing.T) {
 	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
 	assert.False(t, config.IsSetForSource("foo", SourceFile))
@@ -350,6 +514,74 @@ func TestNotificationNoChange(t *testing.T) {
 	assert.Equal(t, []string{"foo"}, updatedKeyCB1)
 }
 
+func TestGeneration(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	assert.Equal(t, uint64(0), config.GetGeneration())
+	config.SetDefault("foo", "default")
+	assert.Equal(t, uint64(1), config.GetGeneration())
+
+	generations := []uint64{}
+	config.OnUpdateWithGeneration(func(_ string, _, _ any, generation uint64) { generations = append(generations, generation) })
+
+	config.Set("foo", "bar", SourceFile)
+	config.Set("foo", "bar", SourceFile)
+	config.Set("foo", "baz", SourceAgentRuntime)
+	assert.Equal(t, []uint64{2, 3}, generations)
+	assert.Equal(t, uint64(3), config.GetGeneration())
+
+	// unsetting a source only changes the generation if the value changes
+	config.UnsetForSource("foo", SourceEnvVar)
+	assert.Equal(t, uint64(3), config.GetGeneration())
+	config.UnsetForSource("foo", SourceAgentRuntime)
+	assert.Equal(t, uint64(4), config.GetGeneration())
+
+	// the writes that aren't notified change the generation too
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(strings.NewReader("foo: read")))
+	assert.Equal(t, uint64(5), config.GetGeneration())
+	require.NoError(t, config.MergeConfig(strings.NewReader("foo: merged")))
+	assert.Equal(t, uint64(6), config.GetGeneration())
+	require.NoError(t, config.MergeConfigMap(map[string]any{"foo": "merged map"}))
+	assert.Equal(t, uint64(7), config.GetGeneration())
+	config.BindEnv("foo")
+	assert.Equal(t, uint64(8), config.GetGeneration())
+	assert.Equal(t, []uint64{2, 3}, generations)
+}
+
+func TestReadAtGeneration(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("foo", "default")
+	config.SetDefault("nested.list", []string{"a"})
+	config.Set("bar", "file", SourceFile)
+
+	snapshot, err := config.ReadAtGeneration(config.GetGeneration())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), snapshot.Generation())
+	assert.Equal(t, "default", snapshot.Get("foo"))
+	assert.Equal(t, "file", snapshot.Get("BAR"))
+	assert.True(t, snapshot.IsSet("nested.list"))
+	assert.False(t, snapshot.IsSet("unknown"))
+	assert.ElementsMatch(t, []string{"foo", "bar", "nested.list"}, snapshot.Keys())
+
+	// the snapshot isn't affected by later changes
+	snapshot.Get("nested.list").([]string)[0] = "b"
+	config.Set("foo", "changed", SourceFile)
+	assert.Equal(t, "default", snapshot.Get("foo"))
+	assert.Equal(t, []string{"a"}, snapshot.Get("nested.list"))
+
+	_, err = config.ReadAtGeneration(3)
+	assert.ErrorIs(t, err, ErrStaleGeneration)
+
+	// notifications can be checked against the current generation
+	var notified *Snapshot
+	config.OnUpdateWithGeneration(func(_ string, _, _ any, generation uint64) {
+		notified, err = config.ReadAtGeneration(generation)
+	})
+	config.Set("foo", "notified", SourceFile)
+	require.NoError(t, err)
+	assert.Equal(t, "notified", notified.Get("foo"))
+}
+
 func TestCheckKnownKey(t *testing.T) {
 	config := NewConfig("test", "DD", strings.NewReplacer(".", "_")).(*safeConfig)
 
@@ -365,6 +597,160 @@ func TestCheckKnownKey(t *testing.T) {
 	assert.Contains(t, config.unknownKeys, "foobar")
 }
 
+func TestSeal(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("foo", "default")
+	config.Set("foo", "file", SourceFile)
+
+	updatedKeys := []string{}
+	config.OnUpdate(func(key string, _, _ any) { updatedKeys = append(updatedKeys, key) })
+
+	config.Seal(SourceRC, SourceCLI)
+
+	// Writes from sources that aren't allowed are ignored
+	config.Set("foo", "env", SourceEnvVar)
+	config.SetDefault("foo", "new-default")
+	config.UnsetForSource("foo", SourceFile)
+	assert.Equal(t, "file", config.Get("foo"))
+	assert.Equal(t, SourceFile, config.GetSource("foo"))
+	assert.Empty(t, updatedKeys)
+	assert.ErrorIs(t, config.ReadConfig(strings.NewReader("foo: read")), ErrConfigSealed)
+	assert.ErrorIs(t, config.MergeConfig(strings.NewReader("foo: merged")), ErrConfigSealed)
+	assert.Equal(t, "file", config.Get("foo"))
+
+	// Allowed sources can still write at runtime
+	config.Set("foo", "rc", SourceRC)
+	assert.Equal(t, "rc", config.Get("foo"))
+	config.UnsetForSource("foo", SourceRC)
+	config.Set("bar", "cli", SourceCLI)
+	assert.Equal(t, "file", config.Get("foo"))
+	assert.Equal(t, "cli", config.Get("bar"))
+	assert.Equal(t, []string{"foo", "bar"}, updatedKeys)
+}
+
+func TestSealedWriteKeepsGeneration(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetConfigType("yaml")
+	config.SetDefault("foo", "default")
+	config.Seal(SourceRC)
+	generation := config.GetGeneration()
+
+	// the rejected writes don't make the snapshots stale
+	config.Set("foo", "file", SourceFile)
+	config.SetDefault("foo", "new-default")
+	config.UnsetForSource("foo", SourceDefault)
+	assert.ErrorIs(t, config.ReadInConfig(), ErrConfigSealed)
+	assert.ErrorIs(t, config.ReadConfig(strings.NewReader("foo: read")), ErrConfigSealed)
+	assert.ErrorIs(t, config.MergeConfig(strings.NewReader("foo: merged")), ErrConfigSealed)
+	assert.ErrorIs(t, config.MergeConfigMap(map[string]any{"foo": "merged map"}), ErrConfigSealed)
+	assert.Equal(t, generation, config.GetGeneration())
+	_, err := config.ReadAtGeneration(generation)
+	assert.NoError(t, err)
+}
+
+func TestRuntimeOnlyKey(t *testing.T) {
+	t.Setenv("DD_IPC_NEGOTIATED_PORT", "1234")
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetConfigType("yaml")
+	config.BindEnv("ipc.negotiated_port")
+	config.BindEnvAndSetDefault("ipc.address", "localhost")
+	config.RegisterRuntimeOnlyKey("ipc.negotiated_port", 0)
+	require.NoError(t, config.ReadConfig(strings.NewReader("ipc:\n  negotiated_port: 5678\n  address: 127.0.0.1\n")))
+
+	// the values of the files and env vars are never read
+	assert.Equal(t, 0, config.GetInt("ipc.negotiated_port"))
+	assert.Equal(t, "127.0.0.1", config.GetString("ipc.address"))
+
+	updatedKeys := []string{}
+	config.OnUpdate(func(key string, _, _ any) { updatedKeys = append(updatedKeys, key) })
+	config.Seal(SourceRC)
+
+	// the other sources can't write to it
+	config.Set("ipc.negotiated_port", 4321, SourceRC)
+	config.Set("ipc.negotiated_port", 4321, SourceCLI)
+	assert.Equal(t, 0, config.GetInt("ipc.negotiated_port"))
+	// and the runtime-only source can't write to the other keys
+	config.Set("ipc.address", "0.0.0.0", SourceRuntimeOnly)
+	assert.Equal(t, "127.0.0.1", config.GetString("ipc.address"))
+	assert.Empty(t, updatedKeys)
+
+	// the runtime-only source writes to it even once sealed, and its changes are notified
+	config.Set("ipc.negotiated_port", 4321, SourceRuntimeOnly)
+	assert.Equal(t, 4321, config.GetInt("ipc.negotiated_port"))
+	assert.Equal(t, SourceRuntimeOnly, config.GetSource("ipc.negotiated_port"))
+	assert.Equal(t, []string{"ipc.negotiated_port"}, updatedKeys)
+
+	// it is excluded from the dumps
+	assert.Equal(t, map[string]interface{}{"address": "127.0.0.1"}, config.AllSettings()["ipc"])
+	assert.Equal(t, map[string]interface{}{"address": "127.0.0.1"}, config.AllSettingsWithoutDefault()["ipc"])
+	for source, settings := range config.AllSettingsBySource() {
+		if ipc, ok := settings.(map[string]interface{})["ipc"].(map[string]interface{}); ok {
+			assert.NotContains(t, ipc, "negotiated_port", source)
+		}
+	}
+
+	// it is reset to its default once unset
+	config.UnsetForSource("ipc.negotiated_port", SourceRuntimeOnly)
+	assert.Equal(t, 0, config.GetInt("ipc.negotiated_port"))
+}
+
+type testTelemetry struct {
+	sets      map[Source]int
+	changes   int
+	notified  []int
+	snapshots []int
+}
+
+func (t *testTelemetry) SetCalled(source Source, changed bool) {
+	t.sets[source]++
+	if changed {
+		t.changes++
+	}
+}
+
+func (t *testTelemetry) ReceiversNotified(receivers int, _ time.Duration) {
+	t.notified = append(t.notified, receivers)
+}
+
+func (t *testTelemetry) SnapshotBuilt(keys int, _ time.Duration) {
+	t.snapshots = append(t.snapshots, keys)
+}
+
+func TestTelemetry(t *testing.T) {
+	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
+	config.SetDefault("foo", "default")
+	config.Set("foo", "file", SourceFile)
+	config.Set("bar", "file", SourceFile)
+
+	telemetry := &testTelemetry{sets: map[Source]int{}}
+	config.(Instrumented).SetTelemetry(telemetry)
+	sourceKeys := config.(Instrumented).SourceKeys()
+	assert.Equal(t, 2, sourceKeys[SourceFile])
+	assert.Equal(t, 1, sourceKeys[SourceDefault])
+	assert.Equal(t, 0, sourceKeys[SourceRC])
+
+	config.OnUpdate(func(string, any, any) {})
+	config.OnUpdate(func(string, any, any) {})
+	config.Set("foo", "rc", SourceRC)
+	config.Set("foo", "rc", SourceRC)
+	config.Set("bar", "cli", SourceCLI)
+	assert.Equal(t, map[Source]int{SourceRC: 2, SourceCLI: 1}, telemetry.sets)
+	assert.Equal(t, 2, telemetry.changes)
+	// receivers are only notified of effective changes
+	assert.Equal(t, []int{2, 2}, telemetry.notified)
+	sourceKeys = config.(Instrumented).SourceKeys()
+	assert.Equal(t, 1, sourceKeys[SourceRC])
+	assert.Equal(t, 1, sourceKeys[SourceCLI])
+
+	_, err := config.ReadAtGeneration(config.GetGeneration())
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, telemetry.snapshots)
+
+	config.(Instrumented).SetTelemetry(nil)
+	config.Set("foo", "cli", SourceCLI)
+	assert.Equal(t, 1, telemetry.sets[SourceCLI])
+}
+
 func TestCopyConfig(t *testing.T) {
 	config := NewConfig("test", "DD", strings.NewReplacer(".", "_"))
 	config.SetDefault("baz", "qux")
@@ -9,4 +9,6 @@ package model
 type Warnings struct {
 	TraceMallocEnabledWithPy2 bool
 	Err                       error
+	// BindingConflicts are the conflicting env var bindings and aliases of the config, see Loader.CheckBindings
+	BindingConflicts []string
 }
@@ -8,6 +8,7 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -60,6 +61,8 @@ type HTTPClient struct {
 	baseURL string
 	client  *http.Client
 	header  http.Header
+	// compress is set if the configuration requests are compressed with gzip
+	compress bool
 }
 
 // NewHTTPClient returns a new HTTP configuration client
@@ -85,9 +88,10 @@ func NewHTTPClient(auth Auth, cfg model.Reader, baseURL *url.URL) (*HTTPClient,
 		return nil, fmt.Errorf("remote Configuration does not allow skipping TLS validation by default (currently skipped because `skip_ssl_validation` is set to true). While it is not advised, the `remote_configuration.no_tls_validation` config option can be set to `true` to disable this protection")
 	}
 	return &HTTPClient{
-		client:  httpClient,
-		header:  header,
-		baseURL: baseURL.String(),
+		client:   httpClient,
+		header:   header,
+		baseURL:  baseURL.String(),
+		compress: cfg.GetBool("remote_configuration.compress_requests"),
 	}, nil
 }
 
@@ -97,6 +101,15 @@ func (c *HTTPClient) Fetch(ctx context.Context, request *pbgo.LatestConfigsReque
 	if err != nil {
 		return nil, err
 	}
+	header := c.header
+	if c.compress {
+		body, err = gzipBody(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		header = c.header.Clone()
+		header.Set("Content-Encoding", "gzip")
+	}
 
 	url := c.baseURL + pollEndpoint
 	log.Debugf("fetching configurations at %s", url)
@@ -104,7 +117,7 @@ func (c *HTTPClient) Fetch(ctx context.Context, request *pbgo.LatestConfigsReque
 	if err != nil {
 		return nil, fmt.Errorf("failed to create org data request: %w", err)
 	}
-	req.Header = c.header
+	req.Header = header
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -235,3 +248,18 @@ func checkStatusCode(resp *http.Response) error {
 
 	return nil
 }
+
+// gzipBody compresses a request body with gzip
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(body)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
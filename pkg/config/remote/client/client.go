@@ -75,6 +75,8 @@ type Client struct {
 	// They are atomics so that they don't have to share the top-level mutex
 	// when in use
 	updaterPackagesState *atomic.Value // []*pbgo.PackageState
+	updaterTags          *atomic.Value // []string
+	updaterHealth        *atomic.Value // UpdaterHealth
 	cwsWorkloads         *atomic.Value // []string
 }
 
@@ -279,6 +281,12 @@ func newClient(cf ConfigFetcher, opts ...func(opts *Options)) (*Client, error) {
 	updaterPackagesState := &atomic.Value{}
 	updaterPackagesState.Store([]*pbgo.PackageState{})
 
+	updaterTags := &atomic.Value{}
+	updaterTags.Store(options.updaterTags)
+
+	updaterHealth := &atomic.Value{}
+	updaterHealth.Store(UpdaterHealth{})
+
 	return &Client{
 		Options:              options,
 		ID:                   generateID(),
@@ -287,6 +295,8 @@ func newClient(cf ConfigFetcher, opts ...func(opts *Options)) (*Client, error) {
 		closeFn:              cloneFn,
 		cwsWorkloads:         cwsWorkloads,
 		updaterPackagesState: updaterPackagesState,
+		updaterTags:          updaterTags,
+		updaterHealth:        updaterHealth,
 		state:                repository,
 		backoffPolicy:        backoffPolicy,
 		listeners:            make(map[string][]func(update map[string]state.RawConfig, applyStateCallback func(string, state.ApplyStatus))),
@@ -361,6 +371,25 @@ func (c *Client) SetUpdaterPackagesState(packages []*pbgo.PackageState) {
 	c.updaterPackagesState.Store(packages)
 }
 
+// SetUpdaterTags sets the updater tags, replacing the ones set with WithUpdater
+func (c *Client) SetUpdaterTags(tags []string) {
+	c.updaterTags.Store(tags)
+}
+
+// UpdaterHealth is the health of the updater, reported along with its packages
+type UpdaterHealth struct {
+	// Heartbeat is the time of the last heartbeat, it stops moving when the updater is stuck
+	Heartbeat  time.Time
+	Uptime     time.Duration
+	LastGC     time.Time
+	QueueDepth int
+}
+
+// SetUpdaterHealth sets the health of the updater
+func (c *Client) SetUpdaterHealth(health UpdaterHealth) {
+	c.updaterHealth.Store(health)
+}
+
 func (c *Client) startFn() {
 	go c.pollLoop()
 }
@@ -564,11 +593,27 @@ func (c *Client) newUpdateRequest() (*pbgo.ClientGetConfigsRequest, error) {
 		if !ok {
 			return nil, errors.New("could not load updaterPackagesState")
 		}
+		updaterTags, ok := c.updaterTags.Load().([]string)
+		if !ok {
+			return nil, errors.New("could not load updaterTags")
+		}
+		updaterHealth, ok := c.updaterHealth.Load().(UpdaterHealth)
+		if !ok {
+			return nil, errors.New("could not load updaterHealth")
+		}
 
 		req.Client.IsUpdater = true
 		req.Client.ClientUpdater = &pbgo.ClientUpdater{
-			Tags:     c.Options.updaterTags,
-			Packages: updaterPackagesState,
+			Tags:          updaterTags,
+			Packages:      updaterPackagesState,
+			UptimeSeconds: uint64(updaterHealth.Uptime.Seconds()),
+			QueueDepth:    uint64(updaterHealth.QueueDepth),
+		}
+		if !updaterHealth.Heartbeat.IsZero() {
+			req.Client.ClientUpdater.HeartbeatTimestamp = updaterHealth.Heartbeat.Unix()
+		}
+		if !updaterHealth.LastGC.IsZero() {
+			req.Client.ClientUpdater.LastGcTimestamp = updaterHealth.LastGC.Unix()
 		}
 	case false:
 		cwsWorkloads, ok := c.cwsWorkloads.Load().([]string)
@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package service
+
+import (
+	"sort"
+
+	rdata "github.com/DataDog/datadog-agent/pkg/config/remote/data"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	rcstate "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+// Apply states of the configurations, aggregated over the clients they're dispatched to
+const (
+	// ConfigApplied is a configuration applied by all the clients reporting it
+	ConfigApplied = "applied"
+	// ConfigPending is a configuration received by the agent that no client acknowledged yet
+	ConfigPending = "pending"
+	// ConfigFailed is a configuration at least one client failed to apply
+	ConfigFailed = "failed"
+)
+
+// ConfigStatus is the status of a configuration of a product
+type ConfigStatus struct {
+	ID      string `json:"id"`
+	Path    string `json:"path,omitempty"`
+	Version uint64 `json:"version,omitempty"`
+	State   string `json:"state"`
+	// Errors are the errors of the clients that failed to apply the configuration
+	Errors []string `json:"errors,omitempty"`
+	// Clients are the clients that reported the configuration
+	Clients []string `json:"clients,omitempty"`
+}
+
+// ProductStatus is the status of the configurations of a product
+type ProductStatus struct {
+	Product string         `json:"product"`
+	Applied int            `json:"applied"`
+	Pending int            `json:"pending"`
+	Failed  int            `json:"failed"`
+	Configs []ConfigStatus `json:"configs"`
+}
+
+// ProductsStatus aggregates the configurations of the local store and the apply states reported by the
+// active clients into the status of the configurations of each product, sorted by product and ID.
+func ProductsStatus(state *pbgo.GetStateConfigResponse) []ProductStatus {
+	type configKey struct{ product, id string }
+	configs := make(map[configKey]*ConfigStatus)
+	// unacknowledged are the configurations a client is still applying
+	unacknowledged := make(map[configKey]bool)
+	getConfig := func(key configKey) *ConfigStatus {
+		config, ok := configs[key]
+		if !ok {
+			config = &ConfigStatus{ID: key.id}
+			configs[key] = config
+		}
+		return config
+	}
+
+	for path := range state.GetTargetFilenames() {
+		configPath, err := rdata.ParseConfigPath(path)
+		if err != nil {
+			continue
+		}
+		getConfig(configKey{product: configPath.Product, id: configPath.ConfigID}).Path = path
+	}
+	for _, client := range state.GetActiveClients() {
+		for _, configState := range client.GetState().GetConfigStates() {
+			key := configKey{product: configState.GetProduct(), id: configState.GetId()}
+			config := getConfig(key)
+			config.Version = max(config.Version, configState.GetVersion())
+			config.Clients = append(config.Clients, clientName(client))
+			switch rcstate.ApplyState(configState.GetApplyState()) {
+			case rcstate.ApplyStateError:
+				config.Errors = append(config.Errors, clientName(client)+": "+configState.GetApplyError())
+			case rcstate.ApplyStateUnacknowledged:
+				unacknowledged[key] = true
+			}
+		}
+	}
+	for key, config := range configs {
+		switch {
+		case len(config.Errors) > 0:
+			config.State = ConfigFailed
+		case len(config.Clients) == 0 || unacknowledged[key]:
+			config.State = ConfigPending
+		default:
+			// the clients not supporting the apply states only report the configurations they received
+			config.State = ConfigApplied
+		}
+	}
+
+	products := make(map[string]*ProductStatus)
+	for key, config := range configs {
+		product, ok := products[key.product]
+		if !ok {
+			product = &ProductStatus{Product: key.product}
+			products[key.product] = product
+		}
+		switch config.State {
+		case ConfigApplied:
+			product.Applied++
+		case ConfigPending:
+			product.Pending++
+		case ConfigFailed:
+			product.Failed++
+		}
+		sort.Strings(config.Clients)
+		sort.Strings(config.Errors)
+		product.Configs = append(product.Configs, *config)
+	}
+	status := make([]ProductStatus, 0, len(products))
+	for _, product := range products {
+		sort.Slice(product.Configs, func(i, j int) bool { return product.Configs[i].ID < product.Configs[j].ID })
+		status = append(status, *product)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Product < status[j].Product })
+	return status
+}
+
+// clientName identifies a client in the status of the configurations
+func clientName(client *pbgo.Client) string {
+	switch {
+	case client.GetIsTracer():
+		return "tracer " + client.GetClientTracer().GetService() + " (" + client.GetId() + ")"
+	case client.GetIsAgent():
+		return "agent " + client.GetClientAgent().GetName() + " (" + client.GetId() + ")"
+	case client.GetIsUpdater():
+		return "updater (" + client.GetId() + ")"
+	default:
+		return client.GetId()
+	}
+}
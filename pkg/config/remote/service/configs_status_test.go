@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	rcstate "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+func TestProductsStatus(t *testing.T) {
+	state := &pbgo.GetStateConfigResponse{
+		TargetFilenames: map[string]string{
+			"datadog/2/APM_TRACING/applied/config":        "hash1",
+			"datadog/2/APM_TRACING/failed/config":         "hash2",
+			"datadog/2/APM_TRACING/not-dispatched/config": "hash3",
+			"datadog/2/AGENT_CONFIG/applying/config":      "hash4",
+			"invalid":                                     "hash5",
+		},
+		ActiveClients: []*pbgo.Client{
+			{
+				Id:           "tracer-1",
+				IsTracer:     true,
+				ClientTracer: &pbgo.ClientTracer{Service: "web"},
+				State: &pbgo.ClientState{ConfigStates: []*pbgo.ConfigState{
+					{Id: "applied", Version: 2, Product: "APM_TRACING", ApplyState: uint64(rcstate.ApplyStateAcknowledged)},
+					{Id: "failed", Version: 3, Product: "APM_TRACING", ApplyState: uint64(rcstate.ApplyStateAcknowledged)},
+				}},
+			},
+			{
+				Id:           "tracer-2",
+				IsTracer:     true,
+				ClientTracer: &pbgo.ClientTracer{Service: "db"},
+				State: &pbgo.ClientState{ConfigStates: []*pbgo.ConfigState{
+					{Id: "applied", Version: 2, Product: "APM_TRACING"},
+					{Id: "failed", Version: 3, Product: "APM_TRACING", ApplyState: uint64(rcstate.ApplyStateError), ApplyError: "invalid sampling rate"},
+				}},
+			},
+			{
+				Id:          "agent-1",
+				IsAgent:     true,
+				ClientAgent: &pbgo.ClientAgent{Name: "core-agent"},
+				State: &pbgo.ClientState{ConfigStates: []*pbgo.ConfigState{
+					{Id: "applying", Version: 1, Product: "AGENT_CONFIG", ApplyState: uint64(rcstate.ApplyStateUnacknowledged)},
+				}},
+			},
+		},
+	}
+
+	assert.Equal(t, []ProductStatus{
+		{
+			Product: "AGENT_CONFIG",
+			Pending: 1,
+			Configs: []ConfigStatus{
+				{ID: "applying", Path: "datadog/2/AGENT_CONFIG/applying/config", Version: 1, State: ConfigPending, Clients: []string{"agent core-agent (agent-1)"}},
+			},
+		},
+		{
+			Product: "APM_TRACING",
+			Applied: 1,
+			Pending: 1,
+			Failed:  1,
+			Configs: []ConfigStatus{
+				{ID: "applied", Path: "datadog/2/APM_TRACING/applied/config", Version: 2, State: ConfigApplied, Clients: []string{"tracer db (tracer-2)", "tracer web (tracer-1)"}},
+				{
+					ID:      "failed",
+					Path:    "datadog/2/APM_TRACING/failed/config",
+					Version: 3,
+					State:   ConfigFailed,
+					Errors:  []string{"tracer db (tracer-2): invalid sampling rate"},
+					Clients: []string{"tracer db (tracer-2)", "tracer web (tracer-1)"},
+				},
+				{ID: "not-dispatched", Path: "datadog/2/APM_TRACING/not-dispatched/config", State: ConfigPending},
+			},
+		},
+	}, ProductsStatus(state))
+}
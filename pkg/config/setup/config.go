@@ -271,6 +271,9 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("check_sampler_context_metrics", false)
 	config.BindEnvAndSetDefault("host_aliases", []string{})
 
+	// Fail to start when conflicting env var bindings or aliases are defined, see Loader.CheckBindings
+	config.BindEnvAndSetDefault("strict_config_bindings", false)
+
 	// overridden in IoT Agent main
 	config.BindEnvAndSetDefault("iot_host", false)
 	// overridden in Heroku buildpack
@@ -659,6 +662,13 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("admission_controller.mutate_unlabelled", false)
 	config.BindEnvAndSetDefault("admission_controller.port", 8000)
 	config.BindEnvAndSetDefault("admission_controller.container_registry", "gcr.io/datadoghq")
+	config.BindEnvAndSetDefault("admission_controller.image_pull_secrets", []string{})
+	config.BindEnvAndSetDefault("admission_controller.image_pull_policy", "")
+	// Should be able to parse it to a map of namespaces to registry, image_pull_secrets and image_pull_policy overrides
+	config.BindEnvAndSetDefault("admission_controller.namespace_registries", "{}")
+	// Should be able to parse them to lists of owner kinds, e.g. [{"kind": "SparkApplication", "api_version": "sparkoperator.k8s.io"}]
+	config.BindEnvAndSetDefault("admission_controller.included_owner_kinds", "[]")
+	config.BindEnvAndSetDefault("admission_controller.excluded_owner_kinds", "[]")
 	config.BindEnvAndSetDefault("admission_controller.timeout_seconds", 10) // in seconds (see kubernetes/kubernetes#71508)
 	config.BindEnvAndSetDefault("admission_controller.service_name", "datadog-admission-controller")
 	config.BindEnvAndSetDefault("admission_controller.certificate.validity_bound", 365*24)             // validity bound of the certificate created by the controller (in hours, default 1 year)
@@ -673,6 +683,8 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("admission_controller.inject_config.socket_path", "/var/run/datadog")
 	config.BindEnvAndSetDefault("admission_controller.inject_config.trace_agent_socket", "unix:///var/run/datadog/apm.socket")
 	config.BindEnvAndSetDefault("admission_controller.inject_config.dogstatsd_socket", "unix:///var/run/datadog/dsd.socket")
+	// Should be able to parse it to a list of rules, e.g. [{"namespaces": ["payments"], "pod_selector": {"team": "checkout"}, "env": "prod", "sample_rate": 0.5, "tags": {"team": "checkout"}}]
+	config.BindEnvAndSetDefault("admission_controller.inject_config.apm_ingestion_rules", "[]")
 	config.BindEnvAndSetDefault("admission_controller.inject_tags.enabled", true)
 	config.BindEnvAndSetDefault("admission_controller.inject_tags.endpoint", "/injecttags")
 	config.BindEnvAndSetDefault("admission_controller.inject_tags.pod_owners_cache_validity", 10) // in minutes
@@ -680,6 +692,10 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("admission_controller.namespace_selector_fallback", false)
 	config.BindEnvAndSetDefault("admission_controller.failure_policy", "Ignore")
 	config.BindEnvAndSetDefault("admission_controller.reinvocation_policy", "IfNeeded")
+	// size limit of the JSON patches of the mutations, in bytes, under the 1.5MiB limit of the objects stored in
+	// etcd and the 3MiB limit of the API server requests. Mutations whose patch can't fit aren't applied, the pod
+	// is then rejected with the Fail failure policy and admitted without the mutation with Ignore.
+	config.BindEnvAndSetDefault("admission_controller.max_patch_size", 1024*1024)
 	config.BindEnvAndSetDefault("admission_controller.add_aks_selectors", false) // adds in the webhook some selectors that are required in AKS
 	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.enabled", true)
 	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.endpoint", "/injectlib")
@@ -688,11 +704,29 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.patcher.fallback_to_file_provider", false)                                // to be enabled only in e2e tests
 	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.patcher.file_provider_path", "/etc/datadog-agent/patch/auto-instru.json") // to be used only in e2e tests
 	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.inject_auto_detected_libraries", false)                                   // allows injecting libraries for languages detected by automatic language detection feature
+	// what is injected when the languages of a pod aren't detected yet at admission time, e.g. while the cluster agent
+	// restarts: inject_all the libraries of all the supported languages, inject_none, or retry to defer the injection to
+	// the reinvocation of the webhook. Pods are only reinvoked with the IfNeeded reinvocation_policy, when another webhook
+	// mutates them afterwards, nothing is injected if their languages are still unknown then.
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.language_detection_fallback", "inject_all")
 	config.BindEnv("admission_controller.auto_instrumentation.init_resources.cpu")
 	config.BindEnv("admission_controller.auto_instrumentation.init_resources.memory")
 	config.BindEnv("admission_controller.auto_instrumentation.asm.enabled", "DD_ADMISSION_CONTROLLER_AUTO_INSTRUMENTATION_APPSEC_ENABLED")         // config for ASM which is implemented in the client libraries
 	config.BindEnv("admission_controller.auto_instrumentation.iast.enabled", "DD_ADMISSION_CONTROLLER_AUTO_INSTRUMENTATION_IAST_ENABLED")          // config for IAST which is implemented in the client libraries
 	config.BindEnv("admission_controller.auto_instrumentation.asm_sca.enabled", "DD_ADMISSION_CONTROLLER_AUTO_INSTRUMENTATION_APPSEC_SCA_ENABLED") // config for SCA
+	config.BindEnv("admission_controller.auto_instrumentation.propagation.style")                                                                  // injected as DD_TRACE_PROPAGATION_STYLE
+	config.BindEnv("admission_controller.auto_instrumentation.propagation.trace_id_128_bit_generation_enabled")                                    // injected as DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED
+	// Should be able to parse it to a map of namespaces to style and trace_id_128_bit_generation_enabled overrides
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.propagation.namespaces", "{}")
+	// Detection of the pods injected with an outdated configuration, which can be restarted
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.drift_detection.enabled", false)
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.drift_detection.interval", 10*time.Minute)
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.drift_detection.rolling_restart", false)
+	// pin the injected lib-init images to the digests of their tags at injection time, so that pods are immune to
+	// tag mutations and admitted by image digest policies. Namespaces override it with `pin_digests` in
+	// admission_controller.namespace_registries. Resolved digests are cached for cache_ttl.
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.pin_image_digests.enabled", false)
+	config.BindEnvAndSetDefault("admission_controller.auto_instrumentation.pin_image_digests.cache_ttl", time.Hour)
 	config.BindEnvAndSetDefault("admission_controller.cws_instrumentation.enabled", false)
 	config.BindEnvAndSetDefault("admission_controller.cws_instrumentation.pod_endpoint", "/inject-pod-cws")
 	config.BindEnvAndSetDefault("admission_controller.cws_instrumentation.command_endpoint", "/inject-command-cws")
@@ -717,6 +751,9 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.image_name", "agent")
 	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.image_tag", "latest")
 	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.cluster_agent.enabled", "true")
+	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.job_handling.enabled", false)
+	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.job_handling.mode", "auto") // auto, native or shim
+	config.BindEnvAndSetDefault("admission_controller.agent_sidecar.job_handling.namespaces", []string{})
 
 	// Declare other keys that don't have a default/env var.
 	// Mostly, keys we use IsSet() on, because IsSet always returns true if a key has a default.
@@ -916,6 +953,152 @@ func InitConfig(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("remote_updates", false)
 	config.BindEnvAndSetDefault("installer.registry.url", "")
 	config.BindEnvAndSetDefault("installer.registry.auth", "")
+	// credentials of the private registries mirroring the packages: the username and password of the "password"
+	// authentication, e.g. an Artifactory token, only sent to the host of installer.registry.url, and the docker
+	// credential helper of the "helper" authentication, the docker-credential-<helper> program being run to get
+	// the credentials of each registry
+	config.BindEnvAndSetDefault("installer.registry.username", "")
+	config.BindEnvAndSetDefault("installer.registry.password", "")
+	config.BindEnvAndSetDefault("installer.registry.credential_helper", "")
+	// bound of the random delay applied by each host before executing a remote request, so that
+	// fleet-wide requests don't hit the registry and restart the agents everywhere at once
+	config.BindEnvAndSetDefault("installer.remote_request_jitter", "0s")
+	// number of remote requests executed concurrently by the daemon. Requests for the same package are
+	// always executed one after the other, so that e.g. an agent experiment doesn't hold an APM injector install.
+	config.BindEnvAndSetDefault("installer.remote_request_workers", 2)
+	// repair the systemd units and symlinks of the packages when the garbage collection finds them drifting
+	config.BindEnvAndSetDefault("installer.repair_units", false)
+	// repair the owners and modes of the files of /opt/datadog-packages when the garbage collection finds
+	// them differing from the ones set at install, e.g. after a restore from a backup
+	config.BindEnvAndSetDefault("installer.repair_permissions", false)
+	// resource limits of the installer subprocesses run by the daemon, applied through a transient systemd
+	// scope so that package extractions don't compete with the workloads of the host. 0 or empty leaves
+	// the resource unlimited. Weights range from 1 to 10000, bandwidths are in bytes per second with an
+	// optional K, M, G or T suffix.
+	config.BindEnvAndSetDefault("installer.subprocess_limits.cpu_weight", 0)
+	config.BindEnvAndSetDefault("installer.subprocess_limits.io_weight", 0)
+	config.BindEnvAndSetDefault("installer.subprocess_limits.io_read_bandwidth_max", "")
+	config.BindEnvAndSetDefault("installer.subprocess_limits.io_write_bandwidth_max", "")
+	// windows during which the daemon executes remote requests, requests received outside of them are
+	// reported as pending until the next one opens. Windows are formatted as `[days] HH:MM-HH:MM [timezone]`,
+	// e.g. `Sat 02:00-06:00 UTC` or `Mon-Fri 22:00-01:00 Europe/Paris`. Remote requests are executed at
+	// any time if empty. Windows are separated by semicolons in DD_FLEET_MAINTENANCE_WINDOWS.
+	config.BindEnvAndSetDefault("fleet.maintenance_windows", []string{})
+	config.SetEnvKeyTransformer("fleet.maintenance_windows", func(in string) interface{} {
+		var windows []string
+		for _, window := range strings.Split(in, ";") {
+			if window = strings.TrimSpace(window); window != "" {
+				windows = append(windows, window)
+			}
+		}
+		return windows
+	})
+	// period during which the daemon doesn't execute remote requests, even in a maintenance window, e.g. a
+	// change freeze. Requests received meanwhile are reported as pending until it ends. The start and end are
+	// RFC 3339 times or `YYYY-MM-DD[ HH:MM]` in the timezone, an IANA timezone name. The blackout starts
+	// immediately if only its end is set.
+	config.BindEnvAndSetDefault("fleet.maintenance_blackout.start", "")
+	config.BindEnvAndSetDefault("fleet.maintenance_blackout.end", "")
+	config.BindEnvAndSetDefault("fleet.maintenance_blackout.timezone", "UTC")
+	// ring and groups of the host, matched against the rollout of the packages of the catalog so that
+	// experiments are only started on the hosts targeted by the current stage of a rollout
+	config.BindEnvAndSetDefault("installer.rollout.ring", "")
+	config.BindEnvAndSetDefault("installer.rollout.groups", []string{})
+	// packages the daemon never manages on remote requests, whatever the targeting of the backend, e.g.
+	// datadog-apm-inject on sensitive hosts. Requests for them fail, they can still be managed locally.
+	config.BindEnvAndSetDefault("installer.blocked_packages", []string{})
+	// installer commands the privileged helper runs for a daemon running as non-root on top of the ones managing
+	// the packages: rotate-api-key, reboot, set-integration-configs and rollback-integration-configs.
+	config.BindEnvAndSetDefault("installer.privileged_helper.opt_in_commands", []string{})
+	// interval at which the daemon reports its state and health (uptime, last garbage collection, queued
+	// requests) even when nothing changes, so that hosts whose daemon died can be marked as stale. 0 disables it.
+	config.BindEnvAndSetDefault("installer.heartbeat_interval", "5m")
+	// interval at which the daemon garbage collects the packages and objects no longer used. 0 disables the
+	// periodic collection, which can still be triggered with `datadog-installer daemon garbage-collect`.
+	config.BindEnvAndSetDefault("installer.gc_interval", "1h")
+	// health probes run by the daemon after starting an experiment, the experiment is stopped automatically
+	// if one of them keeps failing. Probes are formatted as `<package>:<probe>`, the probes being
+	// `systemd:<unit>` for an active systemd unit, `http:<url>` for an endpoint answering with a 2xx status
+	// (e.g. the health endpoint of the agent) or `package` for the `health-check` executable shipped at the
+	// root of the experiment. Probes are separated by spaces in DD_INSTALLER_EXPERIMENT_HEALTH_CHECK_PROBES.
+	config.BindEnvAndSetDefault("installer.experiment_health_check.probes", []string{})
+	// time during which the probes are run after starting an experiment, 0 disables the health check. The probes
+	// run in the background, a check interrupted by a restart of the daemon is run again by the next one.
+	config.BindEnvAndSetDefault("installer.experiment_health_check.duration", "0s")
+	config.BindEnvAndSetDefault("installer.experiment_health_check.interval", "30s")
+	// hooks run by the daemon before and after the installs and the experiments of the packages restart their
+	// services, so that cluster tooling can handle the monitoring gap, e.g. by cordoning or annotating the Kubernetes
+	// node when the agent also runs as a daemonset, or so that the services depending on the agent can be quiesced
+	// around its upgrades. The command is run with `sh -c` and the DD_EXPERIMENT_HOOK_* environment variables
+	// describing the operation, the URL receives them as a JSON POST; the end of their output is recorded in the
+	// audit log. A failing hook fails the operation if on_failure is abort, and is only logged if it is warn. The
+	// after hooks are run even if the operation failed. Only the packages installed from the catalog are hooked on
+	// install, as the other ones are only known once downloaded.
+	config.BindEnvAndSetDefault("installer.experiment_hooks.packages", []string{"datadog-agent"})
+	config.BindEnvAndSetDefault("installer.experiment_hooks.command", "")
+	config.BindEnvAndSetDefault("installer.experiment_hooks.url", "")
+	config.BindEnvAndSetDefault("installer.experiment_hooks.timeout", "2m")
+	config.BindEnvAndSetDefault("installer.experiment_hooks.on_failure", "abort")
+	// rewrite rules of the URLs of the packages, applied by the daemon before installing them so that the catalog
+	// received from remote config can be downloaded from an approved internal mirror. Rules are formatted as
+	// `<prefix>=<replacement>`, e.g. `oci://install.datadoghq.com/=oci://registry.internal/datadog/`, the first
+	// rule matching a URL is applied. Prefixes have a scheme and a host, matched exactly. The digests of the packages
+	// are kept, the mirror must serve the same packages. A URL rewritten to a registry overridden by
+	// installer.registry.url isn't installed, as the override would replace the mirror.
+	config.BindEnvAndSetDefault("installer.url_rewrites", []string{})
+	// socket of the privileged helper (`installer privileged-helper`), running the installer commands as root on
+	// behalf of a daemon started with --no-root. The helper only accepts the connections of its allowed user and
+	// only runs the commands of its allowlist, set on its command line. Empty runs the commands from the daemon.
+	config.BindEnvAndSetDefault("installer.privileged_helper.socket", "")
+	// notifications of the lifecycle events of the packages (install started, succeeded or failed, experiment
+	// started, promoted, stopped or failed), so that external orchestration can react to the changes of the fleet
+	// without polling the daemon. The events are posted as JSON to the URL, e.g. a local webhook, and sent as
+	// Datadog events through the dogstatsd server of the local agent if events is set. Notifications are
+	// delivered in the background, a failed delivery is logged and doesn't fail the operation.
+	config.BindEnvAndSetDefault("installer.notifications.url", "")
+	config.BindEnvAndSetDefault("installer.notifications.events", false)
+	config.BindEnvAndSetDefault("installer.notifications.timeout", "10s")
+	// delays before retrying a failed delivery, e.g. `[5s, 30s, 2m]`, failed deliveries aren't retried if empty
+	config.BindEnvAndSetDefault("installer.notifications.retry_backoff", []string{})
+	// reporting of the state of the packages through remote config. If delta is set, only the packages that
+	// changed since the last full report are reported, the full state being reported every full_sync_interval,
+	// so that hosts managing many packages don't send them all on every remote config poll. It requires a
+	// backend supporting the delta reports, which are flagged with the installer_state_report:delta tag.
+	config.BindEnvAndSetDefault("installer.state_reporting.delta", false)
+	config.BindEnvAndSetDefault("installer.state_reporting.full_sync_interval", "10m")
+	// preflight checks of the host run by the daemon before the installs and experiments, so that they fail
+	// fast with a dedicated error code instead of leaving a partial install: the disk space available for the
+	// package of the catalog (as checked by the installer once the package is downloaded), the memory
+	// available, the glibc version and whether systemd is running. Empty sizes and versions skip the
+	// corresponding check, the memory isn't checked by default as small hosts run with little available.
+	config.BindEnvAndSetDefault("installer.preflight.enabled", true)
+	config.BindEnvAndSetDefault("installer.preflight.min_memory", "")
+	config.BindEnvAndSetDefault("installer.preflight.min_glibc_version", "2.17")
+	config.BindEnvAndSetDefault("installer.preflight.require_systemd", false)
+	// path of a catalog file, or of a directory of catalog files, mirrored on disk for hosts without remote
+	// config connectivity. Its packages can be installed in addition to the ones of the remote catalog.
+	config.BindEnvAndSetDefault("fleet.catalog_path", "")
+	// ed25519 keys, PEM encoded, of the package archives written by `datadog-installer export`: the private
+	// key (PKCS #8) signs the exported archives, and `datadog-installer import` only installs the archives
+	// signed by one of the trusted public keys (PKIX). Trusted keys are separated by spaces in
+	// DD_INSTALLER_ARCHIVE_TRUSTED_KEYS.
+	config.BindEnvAndSetDefault("installer.archive.signing_key", "")
+	config.BindEnvAndSetDefault("installer.archive.trusted_keys", []string{})
+	// bandwidth limit of the package downloads run for the daemon, in bytes per second, so that upgrades
+	// across many hosts don't saturate the uplinks of a site. 0 leaves it unlimited.
+	config.BindEnvAndSetDefault("fleet.max_download_bytes_per_sec", 0)
+	// keep the compressed layers of the installed packages on disk, so that the experiments started by the daemon
+	// only download the layers of the new version that differ from the installed one. It costs the disk space of
+	// the layers of the installed versions.
+	config.BindEnvAndSetDefault("fleet.delta_updates", false)
+	// release channel this host is subscribed to: stable, beta or nightly. The daemon only uses the catalog
+	// packages of this channel and of the more stable ones, the packages without a channel are stable.
+	config.BindEnvAndSetDefault("fleet.channel", "")
+	// versions the packages are pinned to on this host, e.g. `{datadog-agent: 7.55.1}`. The catalog versions of a
+	// pinned package other than the pinned one are ignored and the remote requests starting an experiment with
+	// them are reported as invalid. A pin matches all the releases of a version, e.g. 7.55.1 matches 7.55.1-1.
+	// Pins are set as a JSON object in DD_FLEET_PINNED_PACKAGES.
+	config.BindEnvAndSetDefault("fleet.pinned_packages", map[string]string{})
 
 	// Data Jobs Monitoring config
 	config.BindEnvAndSetDefault("djm_config.enabled", false)
@@ -970,6 +1153,8 @@ func agent(config pkgconfigmodel.Config) {
 	config.BindEnvAndSetDefault("agent_ipc.host", "localhost")
 	config.BindEnvAndSetDefault("agent_ipc.port", 0)
 	config.BindEnvAndSetDefault("agent_ipc.config_refresh_interval", 0)
+	config.BindEnvAndSetDefault("status_server.host", "localhost")
+	config.BindEnvAndSetDefault("status_server.port", 0)
 	config.BindEnvAndSetDefault("default_integration_http_timeout", 9)
 	config.BindEnvAndSetDefault("integration_tracing", false)
 	config.BindEnvAndSetDefault("integration_tracing_exhaustive", false)
@@ -1022,6 +1207,8 @@ func remoteconfig(config pkgconfigmodel.Config) {
 	config.BindEnv("remote_configuration.rc_dd_url")
 	config.BindEnvAndSetDefault("remote_configuration.no_tls", false)
 	config.BindEnvAndSetDefault("remote_configuration.no_tls_validation", false)
+	// compress the requests to the remote config backend with gzip, it requires a backend accepting them
+	config.BindEnvAndSetDefault("remote_configuration.compress_requests", false)
 	config.BindEnvAndSetDefault("remote_configuration.config_root", "")
 	config.BindEnvAndSetDefault("remote_configuration.director_root", "")
 	config.BindEnv("remote_configuration.refresh_interval")
@@ -1787,6 +1974,22 @@ func checkConflictingOptions(config pkgconfigmodel.Config) error {
 	return nil
 }
 
+// checkBindings reports the conflicting env var bindings and aliases of the config as warnings,
+// or as an error when strict_config_bindings is enabled.
+func checkBindings(config pkgconfigmodel.Config, warnings *pkgconfigmodel.Warnings) error {
+	warnings.BindingConflicts = config.CheckBindings()
+	if len(warnings.BindingConflicts) == 0 {
+		return nil
+	}
+	for _, conflict := range warnings.BindingConflicts {
+		log.Warnf("Conflicting configuration binding: %s", conflict)
+	}
+	if config.IsKnown("strict_config_bindings") && config.GetBool("strict_config_bindings") {
+		return fmt.Errorf("%d conflicting configuration bindings found and strict_config_bindings is enabled: %s", len(warnings.BindingConflicts), strings.Join(warnings.BindingConflicts, "; "))
+	}
+	return nil
+}
+
 // LoadDatadogCustom loads the datadog config in the given config
 func LoadDatadogCustom(config pkgconfigmodel.Config, origin string, secretResolver optional.Option[secrets.Component], additionalKnownEnvVars []string) (*pkgconfigmodel.Warnings, error) {
 	// Feature detection running in a defer func as it always  need to run (whether config load has been successful or not)
@@ -1860,6 +2063,10 @@ func LoadCustom(config pkgconfigmodel.Config, origin string, secretResolver opti
 		log.Warnf(warningMsg)
 	}
 
+	if err := checkBindings(config, &warnings); err != nil {
+		return &warnings, err
+	}
+
 	// We resolve proxy setting before secrets. This allows setting secrets through DD_PROXY_* env variables
 	LoadProxyFromEnv(config)
 
@@ -1499,3 +1499,19 @@ func TestAgentConfigInit(t *testing.T) {
 	assert.True(t, conf.IsKnown("sbom.enabled"))
 	assert.True(t, conf.IsKnown("inventories_enabled"))
 }
+
+func TestCheckBindings(t *testing.T) {
+	conf := Conf()
+	warnings := &pkgconfigmodel.Warnings{}
+	assert.NoError(t, checkBindings(conf, warnings))
+	assert.Empty(t, warnings.BindingConflicts)
+
+	conf.BindEnvAndSetDefault("test_conflicting_key", 10, "DD_HOSTNAME")
+	assert.NoError(t, checkBindings(conf, warnings))
+	assert.Equal(t, []string{
+		"env var DD_HOSTNAME is bound to keys of different types: hostname (string), test_conflicting_key (int)",
+	}, warnings.BindingConflicts)
+
+	conf.SetWithoutSource("strict_config_bindings", true)
+	assert.ErrorContains(t, checkBindings(conf, warnings), "1 conflicting configuration bindings found")
+}
@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package setup
+
+import (
+	pkgconfigmodel "github.com/DataDog/datadog-agent/pkg/config/model"
+)
+
+// Feature flags looked up on hot paths through Datadog().Features()
+const (
+	// FeatureMutateUnlabelled makes the admission controller mutate the pods without the admission label
+	FeatureMutateUnlabelled pkgconfigmodel.FeatureFlag = "admission_controller.mutate_unlabelled"
+	// FeatureCWSInstrumentationMutateUnlabelled makes the CWS instrumentation webhook mutate the pods without the admission label
+	FeatureCWSInstrumentationMutateUnlabelled pkgconfigmodel.FeatureFlag = "admission_controller.cws_instrumentation.mutate_unlabelled"
+	// FeatureAPMInstrumentation enables the single step APM instrumentation of the cluster
+	FeatureAPMInstrumentation pkgconfigmodel.FeatureFlag = "apm_config.instrumentation.enabled"
+	// FeatureExcludePauseContainer excludes the pause containers from the container filters
+	FeatureExcludePauseContainer pkgconfigmodel.FeatureFlag = "exclude_pause_container"
+)
@@ -27,6 +27,10 @@ func initCWSSystemProbeConfig(cfg pkgconfigmodel.Config) {
 	cfg.BindEnvAndSetDefault("runtime_security_config.event_server.burst", 40)
 	cfg.BindEnvAndSetDefault("runtime_security_config.event_server.retention", "6s")
 	cfg.BindEnvAndSetDefault("runtime_security_config.event_server.rate", 10)
+	cfg.BindEnvAndSetDefault("runtime_security_config.container_rate_limiter.rate", 0)
+	cfg.BindEnvAndSetDefault("runtime_security_config.container_rate_limiter.burst", 100)
+	cfg.BindEnvAndSetDefault("runtime_security_config.container_rate_limiter.per_container_metrics", false)
+	cfg.BindEnvAndSetDefault("runtime_security_config.ignore_sandbox_containers", false)
 	cfg.BindEnvAndSetDefault("runtime_security_config.cookie_cache_size", 100)
 	cfg.BindEnvAndSetDefault("runtime_security_config.internal_monitoring.enabled", false)
 	cfg.BindEnvAndSetDefault("runtime_security_config.log_patterns", []string{})
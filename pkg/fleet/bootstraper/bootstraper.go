@@ -28,12 +28,17 @@ func Bootstrap(ctx context.Context, env *env.Env) error {
 		version = env.DefaultPackagesVersionOverride[installerPackage]
 	}
 	installerURL := oci.PackageURL(env, installerPackage, version)
+	cmd := exec.NewInstallerExec(env, exec.StableInstallerPath)
 	err := bootstrap.Install(ctx, env, installerURL)
 	if err != nil {
-		return fmt.Errorf("failed to bootstrap the installer: %w", err)
+		// fall back to the OS package, which is verified against the pinned Datadog keys
+		fmt.Fprintf(os.Stderr, "failed to bootstrap the installer from the registry, falling back to the OS package: %v\n", err)
+		cmd, err = bootstrap.InstallOSPackage(ctx, env, env.DefaultPackagesVersionOverride[installerPackage])
+		if err != nil {
+			return fmt.Errorf("failed to bootstrap the installer: %w", err)
+		}
 	}
 
-	cmd := exec.NewInstallerExec(env, exec.StableInstallerPath)
 	defaultPackages, err := cmd.DefaultPackages(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get default packages: %w", err)
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiKeyValidationTimeout bounds the validation of a new API key against the intake
+const apiKeyValidationTimeout = 30 * time.Second
+
+// validateAPIKey checks the API key is valid for the site before the agent is restarted with it,
+// overridden in tests
+var validateAPIKey = func(ctx context.Context, site string, apiKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, apiKeyValidationTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://api.%s/api/v1/validate", strings.TrimSpace(site)), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("DD-API-KEY", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API key rejected by %s: %s", site, resp.Status)
+	}
+	return nil
+}
+
+// decryptAPIKey decrypts the new API key sent with a rotate_api_key request. The key never travels in
+// plaintext, it's sealed with AES-256-GCM keyed by the SHA-256 of the current API key of the host and
+// base64 encoded along with its nonce.
+func decryptAPIKey(encrypted string, currentAPIKey string) (string, error) {
+	if currentAPIKey == "" {
+		return "", fmt.Errorf("no current API key to decrypt the new one with")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("could not decode encrypted API key: %w", err)
+	}
+	gcm, err := apiKeyCipher(currentAPIKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted API key is too short")
+	}
+	apiKey, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt API key: %w", err)
+	}
+	return string(apiKey), nil
+}
+
+func apiKeyCipher(currentAPIKey string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(currentAPIKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("could not create API key cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+// encryptAPIKey encrypts the new API key the way the backend does before sending it to the host
+func encryptAPIKey(t *testing.T, apiKey string, currentAPIKey string) string {
+	gcm, err := apiKeyCipher(currentAPIKey)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(apiKey), nil))
+}
+
+// rotateAPIKeyParamsJSON returns the params of a rotate_api_key request sent to a host using currentAPIKey
+func rotateAPIKeyParamsJSON(t *testing.T, apiKey string, currentAPIKey string) []byte {
+	params, err := json.Marshal(rotateAPIKeyParams{EncryptedAPIKey: encryptAPIKey(t, apiKey, currentAPIKey)})
+	require.NoError(t, err)
+	return params
+}
+
+// stubAPIKeyValidation accepts the API keys without reaching the intake, except the rejected ones
+func stubAPIKeyValidation(t *testing.T, rejected ...string) {
+	oldValidateAPIKey := validateAPIKey
+	t.Cleanup(func() { validateAPIKey = oldValidateAPIKey })
+	validateAPIKey = func(_ context.Context, site string, apiKey string) error {
+		for _, r := range rejected {
+			if apiKey == r {
+				return fmt.Errorf("API key rejected by %s: 403 Forbidden", site)
+			}
+		}
+		return nil
+	}
+}
+
+func TestDecryptAPIKey(t *testing.T) {
+	encrypted := encryptAPIKey(t, "newkey", "oldkey")
+	assert.NotContains(t, encrypted, "newkey")
+
+	apiKey, err := decryptAPIKey(encrypted, "oldkey")
+	require.NoError(t, err)
+	assert.Equal(t, "newkey", apiKey)
+
+	_, err = decryptAPIKey(encrypted, "otherkey")
+	assert.ErrorContains(t, err, "could not decrypt API key")
+	_, err = decryptAPIKey(encrypted, "")
+	assert.ErrorContains(t, err, "no current API key")
+	_, err = decryptAPIKey("newkey", "oldkey")
+	assert.Error(t, err)
+}
+
+func TestRemoteRotateAPIKeyInvalid(t *testing.T) {
+	stubAPIKeyValidation(t, "revokedkey")
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, APIKey: "oldkey", Site: "datadoghq.com"})
+	defer i.Stop()
+
+	// the agent isn't restarted with a key the intake rejects
+	i.pm.On("State", "datadog-agent").Return(repository.State{Stable: "7.55.0"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRotateAPIKey,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        rotateAPIKeyParamsJSON(t, "revokedkey", "oldkey"),
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertNotCalled(t, "RotateAPIKey", mock.Anything, mock.Anything)
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task.Error)
+	assert.Contains(t, i.rcc.packagesState[0].Task.Error.Message, "could not validate the new API key")
+	assert.Equal(t, "oldkey", i.env.APIKey)
+}
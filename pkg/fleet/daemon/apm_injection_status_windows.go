@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	osexec "os/exec"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	// datadogProfilerCLSID is the CLSID of the Datadog .NET profiler, set as the profiler of the .NET
+	// Framework (COR_PROFILER) and .NET Core (CORECLR_PROFILER) runtimes by the injection
+	datadogProfilerCLSID = "{846F5F1C-F9AE-4B07-969E-05C26BC060D8}"
+
+	systemEnvironmentKey = `SYSTEM\CurrentControlSet\Control\Session Manager\Environment`
+	// iisServicesKey is the key of the services, W3SVC and WAS host the IIS worker processes
+	iisServicesKey = `SYSTEM\CurrentControlSet\Services`
+)
+
+var iisServices = []string{"W3SVC", "WAS"}
+
+// apmInjectionStatus returns the status of the .NET profiler based injection on Windows: the host is
+// instrumented if the profiler is enabled in the system environment, IIS if it's enabled in the
+// environment of all its services.
+func apmInjectionStatus() (status APMInjectionStatus, err error) {
+	systemEnv, err := registryEnvironment(systemEnvironmentKey)
+	if err != nil {
+		return status, fmt.Errorf("could not read the system environment: %w", err)
+	}
+	status.HostInstrumented = dotnetProfilerEnabled(systemEnv)
+
+	iisInstrumented := true
+	for _, service := range iisServices {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, iisServicesKey+`\`+service, registry.QUERY_VALUE)
+		if errors.Is(err, registry.ErrNotExist) {
+			iisInstrumented = false
+			continue
+		} else if err != nil {
+			return status, fmt.Errorf("could not open the registry key of service %s: %w", service, err)
+		}
+		// the environment of a service is a multi-string value of KEY=VALUE
+		env, _, err := key.GetStringsValue("Environment")
+		key.Close()
+		if err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return status, fmt.Errorf("could not read the environment of service %s: %w", service, err)
+		}
+		if service == "W3SVC" {
+			status.IISInstalled = true
+		}
+		iisInstrumented = iisInstrumented && dotnetProfilerEnabled(env)
+	}
+	status.IISInstrumented = status.IISInstalled && iisInstrumented
+
+	// Docker is installed if the docker binary is in the PATH, the injection doesn't instrument
+	// Windows containers
+	_, err = osexec.LookPath("docker")
+	if err != nil && errors.Is(err, osexec.ErrNotFound) {
+		return status, nil
+	} else if err != nil {
+		return status, fmt.Errorf("could not check if docker is installed: %w", err)
+	}
+	status.DockerInstalled = true
+	return status, nil
+}
+
+// registryEnvironment returns the variables of an environment key of the registry as KEY=VALUE
+func registryEnvironment(path string) ([]string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+	names, err := key.ReadValueNames(0)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, name := range names {
+		value, _, err := key.GetStringValue(name)
+		if err != nil {
+			// values of other types, e.g. binary ones, aren't environment variables
+			continue
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
+
+// dotnetProfilerEnabled returns whether the Datadog profiler is enabled for the .NET Framework or .NET
+// Core runtimes in the given environment
+func dotnetProfilerEnabled(env []string) bool {
+	vars := make(map[string]string, len(env))
+	for _, v := range env {
+		name, value, ok := strings.Cut(v, "=")
+		if ok {
+			vars[strings.ToUpper(name)] = value
+		}
+	}
+	framework := vars["COR_ENABLE_PROFILING"] == "1" && strings.EqualFold(vars["COR_PROFILER"], datadogProfilerCLSID)
+	core := vars["CORECLR_ENABLE_PROFILING"] == "1" && strings.EqualFold(vars["CORECLR_PROFILER"], datadogProfilerCLSID)
+	return framework || core
+}
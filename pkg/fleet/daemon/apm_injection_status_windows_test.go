@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDotnetProfilerEnabled(t *testing.T) {
+	assert.True(t, dotnetProfilerEnabled([]string{"COR_ENABLE_PROFILING=1", "COR_PROFILER=" + datadogProfilerCLSID}))
+	assert.True(t, dotnetProfilerEnabled([]string{"CORECLR_ENABLE_PROFILING=1", "coreclr_profiler={846f5f1c-f9ae-4b07-969e-05c26bc060d8}"}))
+	assert.False(t, dotnetProfilerEnabled([]string{"COR_ENABLE_PROFILING=0", "COR_PROFILER=" + datadogProfilerCLSID}))
+	assert.False(t, dotnetProfilerEnabled([]string{"COR_ENABLE_PROFILING=1", "COR_PROFILER={00000000-0000-0000-0000-000000000000}"}))
+	assert.False(t, dotnetProfilerEnabled(nil))
+}
@@ -0,0 +1,238 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// auditLogFile is the file, in the packages directory, the operations on the packages are recorded to
+	auditLogFile = "audit.log"
+	// auditLogMaxSize is the size after which the audit log is rotated
+	auditLogMaxSize = 10 * 1024 * 1024
+	// auditLogBackups is the number of rotated audit logs kept next to the current one
+	auditLogBackups = 3
+)
+
+// Requesters of the operations recorded in the audit log
+const (
+	requesterRemoteConfig = "remote_config"
+	requesterLocalAPI     = "local_api"
+	requesterDaemon       = "daemon"
+)
+
+// Operations recorded in the audit log, on top of the remote requests
+const (
+	auditInstall           = "install"
+	auditStartExperiment   = "start_experiment"
+	auditStopExperiment    = "stop_experiment"
+	auditPromoteExperiment = "promote_experiment"
+	auditRollback          = "rollback"
+	auditUninstall         = "uninstall"
+	auditGarbageCollect    = "garbage_collect"
+	auditRemoteRequest     = "remote_request"
+	auditExperimentHook    = "experiment_hook"
+)
+
+// AuditEntry is an operation on the packages recorded in the audit log.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Requester string    `json:"requester"`
+	RequestID string    `json:"request_id,omitempty"`
+	// Method is the method of the remote requests, or the operation an experiment hook is run for
+	Method  string `json:"method,omitempty"`
+	Package string `json:"package,omitempty"`
+	// Version is the version requested, URL the package the operation was done with
+	Version string `json:"version,omitempty"`
+	URL     string `json:"url,omitempty"`
+	// Stable and Experiment are the versions the remote requests expected to be installed
+	Stable     string `json:"stable,omitempty"`
+	Experiment string `json:"experiment,omitempty"`
+	// Hook is the experiment hook run and Output the end of its output, for the experiment hooks
+	Hook            string  `json:"hook,omitempty"`
+	Output          string  `json:"output,omitempty"`
+	Outcome         string  `json:"outcome"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// auditLog records the operations on the packages to an append-only JSON lines file, so that what
+// changed on a host and when can be reconstructed. The file is rotated once it reaches its maximum
+// size, nothing is recorded if there is no path.
+type auditLog struct {
+	m       sync.Mutex
+	path    string
+	maxSize int64
+}
+
+func newAuditLog(path string) *auditLog {
+	return &auditLog{path: path, maxSize: auditLogMaxSize}
+}
+
+// record appends an entry to the audit log. Failing to record it doesn't fail the operation.
+func (a *auditLog) record(entry AuditEntry) {
+	if a.path == "" {
+		return
+	}
+	a.m.Lock()
+	defer a.m.Unlock()
+	err := a.write(entry)
+	if err != nil {
+		log.Warnf("Daemon: could not record %s operation to the audit log: %v", entry.Operation, err)
+	}
+}
+
+func (a *auditLog) write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+	if info, err := os.Stat(a.path); err == nil && info.Size()+int64(len(line)) > a.maxSize {
+		err = a.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("could not open audit log: %w", err)
+	}
+	_, err = f.Write(line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write audit log: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the audit log to audit.log.1, audit.log.1 to audit.log.2 and so on, dropping the oldest one
+func (a *auditLog) rotate() error {
+	for i := auditLogBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate audit log: %w", err)
+		}
+	}
+	err := os.Rename(a.path, a.path+".1")
+	if err != nil {
+		return fmt.Errorf("could not rotate audit log: %w", err)
+	}
+	return nil
+}
+
+type auditKey int
+
+var auditRequesterKey auditKey
+
+// withAuditRequester returns a context recording the operations done with it as requested by requester
+func withAuditRequester(ctx context.Context, requester string) context.Context {
+	return context.WithValue(ctx, auditRequesterKey, requester)
+}
+
+// auditRequester returns who requested the operations done with the context: the remote requests
+// are requested through remote config and the operations without requester by the daemon itself.
+func auditRequester(ctx context.Context) (requester string, requestID string) {
+	if state, ok := ctx.Value(requestStateKey).(*requestState); ok {
+		requestID = state.ID
+		requester = requesterRemoteConfig
+	}
+	if r, ok := ctx.Value(auditRequesterKey).(string); ok {
+		return r, requestID
+	}
+	if requester == "" {
+		requester = requesterDaemon
+	}
+	return requester, requestID
+}
+
+// audit records an operation on a package once it's done.
+func (d *daemonImpl) audit(ctx context.Context, operation string, pkg string, url string, start time.Time, err error) {
+	requester, requestID := auditRequester(ctx)
+	entry := AuditEntry{
+		Time:            start,
+		Operation:       operation,
+		Requester:       requester,
+		RequestID:       requestID,
+		Package:         pkg,
+		URL:             url,
+		Outcome:         "success",
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = err.Error()
+	}
+	d.auditLog.record(entry)
+}
+
+// auditRemoteAPIRequest records a handled remote request with the state it ended in.
+func (d *daemonImpl) auditRemoteAPIRequest(ctx context.Context, request remoteAPIRequest, start time.Time, err error) {
+	entry := AuditEntry{
+		Time:            start,
+		Operation:       auditRemoteRequest,
+		Requester:       requesterRemoteConfig,
+		RequestID:       request.ID,
+		Method:          request.Method,
+		Package:         request.Package,
+		Stable:          request.ExpectedState.Stable,
+		Experiment:      request.ExpectedState.Experiment,
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	var params taskWithVersionParams
+	if json.Unmarshal(request.Params, &params) == nil {
+		entry.Version = params.Version
+	}
+	if state, ok := ctx.Value(requestStateKey).(*requestState); ok {
+		entry.Outcome = strings.ToLower(state.State.String())
+		if state.Err != nil {
+			entry.Error = state.Err.Error()
+		}
+	}
+	if err != nil && entry.Error == "" {
+		entry.Error = err.Error()
+	}
+	d.auditLog.record(entry)
+}
+
+// auditExperimentHook records an experiment hook once it's run, the failures of the hooks are recorded as
+// warnings if they don't fail the operation.
+func (d *daemonImpl) auditExperimentHook(ctx context.Context, hook experimentHook, event experimentHookEvent, output string, start time.Time, err error, warn bool) {
+	requester, requestID := auditRequester(ctx)
+	entry := AuditEntry{
+		Time:            start,
+		Operation:       auditExperimentHook,
+		Requester:       requester,
+		RequestID:       requestID,
+		Method:          event.Operation,
+		Package:         event.Package,
+		Version:         event.Version,
+		Hook:            event.Phase + ":" + hook.String(),
+		Output:          output,
+		Outcome:         "success",
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		if warn {
+			entry.Outcome = "warning"
+		}
+		entry.Error = err.Error()
+	}
+	d.auditLog.record(entry)
+}
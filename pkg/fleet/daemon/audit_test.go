@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+func readAuditLog(t *testing.T, path string) []AuditEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), auditLogFile)
+	a := newAuditLog(path)
+	a.maxSize = 300
+	for i := 0; i < 20; i++ {
+		a.record(AuditEntry{Time: time.Now(), Operation: auditGarbageCollect, Requester: requesterDaemon, Outcome: "success"})
+	}
+
+	// the log is rotated once it reaches its maximum size, the oldest ones are dropped
+	for _, p := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), a.maxSize)
+		assert.NotEmpty(t, readAuditLog(t, p))
+	}
+	_, err := os.Stat(path + ".4")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAuditRequester(t *testing.T) {
+	requester, requestID := auditRequester(context.Background())
+	assert.Equal(t, requesterDaemon, requester)
+	assert.Empty(t, requestID)
+
+	requester, _ = auditRequester(withAuditRequester(context.Background(), requesterLocalAPI))
+	assert.Equal(t, requesterLocalAPI, requester)
+
+	remoteCtx := context.WithValue(context.Background(), requestStateKey, &requestState{ID: "test-request-1"})
+	requester, requestID = auditRequester(remoteCtx)
+	assert.Equal(t, requesterRemoteConfig, requester)
+	assert.Equal(t, "test-request-1", requestID)
+
+	// the operations done by the daemon on behalf of a request, e.g. the expiry of an experiment
+	requester, requestID = auditRequester(withAuditRequester(remoteCtx, requesterDaemon))
+	assert.Equal(t, requesterDaemon, requester)
+	assert.Equal(t, "test-request-1", requestID)
+}
+
+func TestAuditRemoteRequest(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true})
+	defer i.Stop()
+	path := filepath.Join(t.TempDir(), auditLogFile)
+	i.auditLog = newAuditLog(path)
+
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.On("GarbageCollect", mock.Anything).Return(nil).Once()
+	require.NoError(t, i.GarbageCollect(context.Background()))
+	i.pm.AssertExpectations(t)
+
+	entries := readAuditLog(t, path)
+	require.Len(t, entries, 3)
+	assert.Equal(t, auditStartExperiment, entries[0].Operation)
+	assert.Equal(t, requesterRemoteConfig, entries[0].Requester)
+	assert.Equal(t, "test-request-1", entries[0].RequestID)
+	assert.Equal(t, testExperimentPackage.URL, entries[0].URL)
+	assert.Equal(t, "success", entries[0].Outcome)
+
+	assert.Equal(t, auditRemoteRequest, entries[1].Operation)
+	assert.Equal(t, "test-request-1", entries[1].RequestID)
+	assert.Equal(t, methodStartExperiment, entries[1].Method)
+	assert.Equal(t, testExperimentPackage.Name, entries[1].Package)
+	assert.Equal(t, testExperimentPackage.Version, entries[1].Version)
+	assert.Equal(t, "0.0.1", entries[1].Stable)
+	assert.Equal(t, "done", entries[1].Outcome)
+
+	assert.Equal(t, auditGarbageCollect, entries[2].Operation)
+	assert.Equal(t, requesterDaemon, entries[2].Requester)
+}
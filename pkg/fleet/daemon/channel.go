@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	channelStable  = "stable"
+	channelBeta    = "beta"
+	channelNightly = "nightly"
+)
+
+// channelFile is the file, in the packages directory, the channel the host was switched to is persisted
+// to. It takes precedence over the configured channel as long as the configuration isn't changed.
+const channelFile = "channel"
+
+// persistedChannel is the channel the host was switched to, along with the channel configured when it was
+type persistedChannel struct {
+	Channel           string `json:"channel"`
+	ConfiguredChannel string `json:"configured_channel"`
+}
+
+// channels are the release channels of the catalog packages, from the most to the least stable. A host
+// subscribed to a channel gets the packages of this channel and of the more stable ones.
+var channels = []string{channelStable, channelBeta, channelNightly}
+
+// parseChannel returns the channel a host subscribes to, stable if unset
+func parseChannel(channel string) (string, error) {
+	if channel == "" {
+		return channelStable, nil
+	}
+	if !slices.Contains(channels, channel) {
+		return "", fmt.Errorf("unknown channel %q, expected one of %v", channel, channels)
+	}
+	return channel, nil
+}
+
+// availableIn returns whether the package is available to the hosts subscribed to the channel. Packages
+// without a channel are stable, packages of an unknown channel aren't available to any host.
+func (p Package) availableIn(channel string) bool {
+	packageChannel := p.Channel
+	if packageChannel == "" {
+		packageChannel = channelStable
+	}
+	rank := slices.Index(channels, packageChannel)
+	return rank != -1 && rank <= slices.Index(channels, channel)
+}
+
+// GetChannel returns the release channel the host is subscribed to.
+func (d *daemonImpl) GetChannel() string {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.channel
+}
+
+// SetChannel switches the host to another release channel, e.g. to let early adopters track the
+// pre-release builds. The channel is kept across the restarts of the daemon.
+func (d *daemonImpl) SetChannel(ctx context.Context, channel string) error {
+	unlock := d.packages.lockAll()
+	defer unlock()
+	return d.setChannel(ctx, channel)
+}
+
+func (d *daemonImpl) setChannel(ctx context.Context, channel string) (err error) {
+	span, _ := tracer.StartSpanFromContext(ctx, "set_channel")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("channel", channel)
+
+	channel, err = parseChannel(channel)
+	if err != nil {
+		return fmt.Errorf("could not set channel: %w", err)
+	}
+	err = d.persistChannel(channel)
+	if err != nil {
+		return fmt.Errorf("could not persist channel: %w", err)
+	}
+	d.applyChannel(channel)
+	log.Infof("Daemon: Subscribed to the %s channel", channel)
+	d.refreshState(ctx)
+	return nil
+}
+
+// persistChannel keeps the channel the host is switched to across the restarts of the daemon. Nothing
+// is kept when the host is switched back to the configured channel.
+func (d *daemonImpl) persistChannel(channel string) error {
+	if d.channelPath == "" {
+		return nil
+	}
+	if channel == d.configuredChannel {
+		err := os.Remove(d.channelPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	rawChannel, err := json.Marshal(persistedChannel{Channel: channel, ConfiguredChannel: d.configuredChannel})
+	if err != nil {
+		return fmt.Errorf("could not marshal channel: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(d.channelPath), filepath.Base(d.channelPath)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(rawChannel)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write channel: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), d.channelPath)
+	if err != nil {
+		return fmt.Errorf("could not move channel: %w", err)
+	}
+	return nil
+}
+
+// loadChannel subscribes the host to the channel it was switched to before the daemon restarted, if any.
+// The switch is dropped if the configured channel changed since, the new configuration wins.
+func (d *daemonImpl) loadChannel() error {
+	if d.channelPath == "" {
+		return nil
+	}
+	unlock := d.packages.lockAll()
+	defer unlock()
+	rawChannel, err := os.ReadFile(d.channelPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read channel: %w", err)
+	}
+	var persisted persistedChannel
+	err = json.Unmarshal(rawChannel, &persisted)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal channel: %w", err)
+	}
+	channel, err := parseChannel(persisted.Channel)
+	if err != nil {
+		return fmt.Errorf("invalid persisted channel: %w", err)
+	}
+	if persisted.ConfiguredChannel != d.configuredChannel {
+		log.Infof("Daemon: The configured channel changed from %s to %s since the host was switched to the %s channel, subscribing to the %s channel", persisted.ConfiguredChannel, d.configuredChannel, channel, d.configuredChannel)
+		err = os.Remove(d.channelPath)
+		if err != nil {
+			return fmt.Errorf("could not remove channel: %w", err)
+		}
+		return nil
+	}
+	log.Infof("Daemon: Subscribed to the %s channel the host was switched to, instead of the configured %s channel", channel, d.configuredChannel)
+	d.applyChannel(channel)
+	return nil
+}
+
+// applyChannel filters the catalog by the channel and reports it in the updater tags. The env is read by
+// the installer subprocesses without the daemon lock, it must only be changed while all the package
+// locks are held.
+func (d *daemonImpl) applyChannel(channel string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.channel = channel
+	d.env.Channel = channel
+	d.rc.SetChannel(channel)
+}
@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChannel(t *testing.T) {
+	channel, err := parseChannel("")
+	require.NoError(t, err)
+	assert.Equal(t, channelStable, channel)
+
+	channel, err = parseChannel(channelNightly)
+	require.NoError(t, err)
+	assert.Equal(t, channelNightly, channel)
+
+	_, err = parseChannel("alpha")
+	assert.Error(t, err)
+}
+
+func TestPackageAvailableIn(t *testing.T) {
+	tests := []struct {
+		packageChannel string
+		hostChannel    string
+		available      bool
+	}{
+		{"", channelStable, true},
+		{channelStable, channelStable, true},
+		{channelBeta, channelStable, false},
+		{channelBeta, channelBeta, true},
+		{channelNightly, channelBeta, false},
+		{"", channelNightly, true},
+		{channelBeta, channelNightly, true},
+		{"alpha", channelNightly, false},
+	}
+	for _, tt := range tests {
+		p := Package{Name: "datadog-agent", Channel: tt.packageChannel}
+		assert.Equal(t, tt.available, p.availableIn(tt.hostChannel), "package channel %q, host channel %q", tt.packageChannel, tt.hostChannel)
+	}
+}
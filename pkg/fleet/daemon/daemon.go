@@ -7,23 +7,27 @@
 package daemon
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"math/rand"
 	"os"
-	osexec "os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
+	"github.com/DataDog/datadog-go/v5/statsd"
+
 	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
 	"github.com/DataDog/datadog-agent/pkg/config/remote/client"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
 	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
@@ -35,10 +39,9 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/version"
 )
 
-const (
-	// gcInterval is the interval at which the GC will run
-	gcInterval = 1 * time.Hour
-)
+// installInstallerExperiment installs the installer experiment, which restarts the daemon, it's
+// overridden in tests
+var installInstallerExperiment = bootstrap.InstallExperiment
 
 // Daemon is the fleet daemon in charge of remote install, updates and configuration.
 type Daemon interface {
@@ -46,33 +49,119 @@ type Daemon interface {
 	Stop(ctx context.Context) error
 
 	Install(ctx context.Context, url string, args []string) error
+	InstallDryRun(ctx context.Context, url string) (*installer.DryRunReport, error)
 	StartExperiment(ctx context.Context, url string) error
+	StartExperimentDryRun(ctx context.Context, url string) (*installer.DryRunReport, error)
 	StopExperiment(ctx context.Context, pkg string) error
 	PromoteExperiment(ctx context.Context, pkg string) error
+	Rollback(ctx context.Context, pkg string) error
+	Uninstall(ctx context.Context, pkg string) error
+	RotateAPIKey(ctx context.Context, apiKey string) error
+	SetChannel(ctx context.Context, channel string) error
+	GarbageCollect(ctx context.Context) error
 
+	GetCatalog() []Package
 	GetPackage(pkg string, version string) (Package, error)
+	GetChannel() string
 	GetState() (map[string]repository.State, error)
+	GetRedactedEnv() []string
 	GetAPMInjectionStatus() (APMInjectionStatus, error)
+	GetDaemonStatus() DaemonStatus
+	Subscribe() (<-chan StateEvent, func())
+	CollectFleetFlare(ctx context.Context) (string, error)
 }
 
 type daemonImpl struct {
+	// m protects the state shared by the operations on the packages: the catalog, the env,
+	// the reboot timer, the reported state and the health of the daemon
 	m        sync.Mutex
 	stopChan chan struct{}
 
+	// packages serializes the operations on each package, operations on different packages
+	// run concurrently
+	packages *packageLocks
+
 	env        *env.Env
 	installer  installer.Installer
 	rc         *remoteConfig
 	catalog    catalog
-	requests   chan remoteAPIRequest
+	requests   *requestQueue
 	requestsWG sync.WaitGroup
+	claims     *requestClaims
+
+	// parkedRequests are the timers queuing the requests waiting for the next maintenance window again, by request ID
+	parkedRequests map[string]*time.Timer
+
+	// requestStore persists the remote requests until they're handled, to resume them after a restart
+	requestStore *requestStore
+
+	// versions are the highest versions installed for each package, to refuse remote downgrades
+	versions *versionHistory
+
+	// runningTasks are the last states of the remote requests being handled, by package, they
+	// are reported along with the state of the packages whatever the request refreshing it
+	runningTasks map[string]requestState
+
+	// localCatalog is the catalog mirrored on disk, its packages are available in addition to the
+	// ones of the catalog received from remote config
+	localCatalog catalog
+
+	rebootTimer *time.Timer
+
+	// startTime and lastGC are reported in the heartbeats
+	startTime time.Time
+	lastGC    time.Time
+	// catalogUpdatedAt is the last time a catalog was received, reported in the status
+	catalogUpdatedAt time.Time
+
+	maintenanceWindows []maintenanceWindow
+	rolloutHost        rolloutHost
+	// channel is the release channel the host is subscribed to, the catalog packages of the less
+	// stable channels are ignored
+	channel string
+	// configuredChannel is the release channel of the configuration, the host may be switched to another one
+	configuredChannel string
+	// channelPath is the file the channel the host is switched to is persisted to
+	channelPath string
+
+	// healthProbes are the probes checking the experiments started by remote requests, by package
+	healthProbes map[string][]healthProbe
+
+	// experimentHooks are run before and after the installs and experiments of the hooked packages, e.g. to drain the node
+	experimentHooks []experimentHook
+	// urlRewrites redirect the URLs of the packages, e.g. to an internal mirror, before they're installed
+	urlRewrites []urlRewrite
+
+	// statsd sends the operational metrics of the daemon to the local agent, experimentStarts are
+	// the start times of the experiments started by remote requests, to report their duration
+	statsd           statsd.ClientInterface
+	experimentStarts map[string]time.Time
+
+	// expiries stops the experiments started with a TTL once they expire
+	expiries *experimentExpiries
+	// healthChecks are the health checks of the experiments started by remote requests being run
+	healthChecks *healthChecks
+	// auditLog records the operations on the packages
+	auditLog *auditLog
+	// notifier notifies the lifecycle events of the packages to the external orchestration
+	notifier *notifier
+	// stateReporter reduces the state of the packages reported through remote config to the changes
+	stateReporter *stateReporter
+
+	subscribers *subscribers
+	tasks       taskHistory
+
+	// config and logFile are only set when running as a service, they are used to send fleet flares
+	config  config.Reader
+	logFile string
 }
 
 func newInstaller(env *env.Env, installerBin string) installer.Installer {
 	return exec.NewInstallerExec(env, installerBin)
 }
 
-// NewDaemon returns a new daemon.
-func NewDaemon(rcFetcher client.ConfigFetcher, config config.Reader) (Daemon, error) {
+// NewDaemon returns a new daemon, sending its metrics with the given statsd client.
+func NewDaemon(rcFetcher client.ConfigFetcher, config config.Reader, statsdClient statsd.ClientInterface) (Daemon, error) {
 	installerBin, err := os.Executable()
 	if err != nil {
 		return nil, fmt.Errorf("could not get installer executable path: %w", err)
@@ -81,24 +170,114 @@ func NewDaemon(rcFetcher client.ConfigFetcher, config config.Reader) (Daemon, er
 	if err != nil {
 		return nil, fmt.Errorf("could not get resolve installer executable path: %w", err)
 	}
-	rc, err := newRemoteConfig(rcFetcher)
+	env := env.FromConfig(config)
+	rc, err := newRemoteConfig(rcFetcher, env)
 	if err != nil {
 		return nil, fmt.Errorf("could not create remote config client: %w", err)
 	}
-	env := env.FromConfig(config)
+	requestStorePath := filepath.Join(installer.PackagesPath, requestStoreFile)
+	versionHistoryPath := filepath.Join(installer.PackagesPath, versionHistoryFile)
+	experimentExpiriesPath := filepath.Join(installer.PackagesPath, experimentExpiriesFile)
+	experimentHealthChecksPath := filepath.Join(installer.PackagesPath, experimentHealthChecksFile)
+	channelPath := filepath.Join(installer.PackagesPath, channelFile)
+	auditLogPath := filepath.Join(installer.PackagesPath, auditLogFile)
 	installer := newInstaller(env, installerBin)
-	return newDaemon(rc, installer, env), nil
+	d := newDaemon(rc, installer, env)
+	d.requestStore = newRequestStore(requestStorePath)
+	d.versions = newVersionHistory(versionHistoryPath)
+	d.expiries = newExperimentExpiries(experimentExpiriesPath)
+	d.healthChecks = newHealthChecks(experimentHealthChecksPath)
+	d.channelPath = channelPath
+	d.auditLog = newAuditLog(auditLogPath)
+	d.statsd = statsdClient
+	d.config = config
+	d.logFile = config.GetString("installer.log_file")
+	if d.logFile == "" {
+		d.logFile = pkgconfigsetup.DefaultUpdaterLogFile
+	}
+	return d, nil
 }
 
 func newDaemon(rc *remoteConfig, installer installer.Installer, env *env.Env) *daemonImpl {
 	i := &daemonImpl{
-		env:       env,
-		rc:        rc,
-		installer: installer,
-		requests:  make(chan remoteAPIRequest, 32),
-		catalog:   catalog{},
-		stopChan:  make(chan struct{}),
+		env:              env,
+		rc:               rc,
+		installer:        installer,
+		packages:         newPackageLocks(),
+		requests:         newRequestQueue(),
+		claims:           newRequestClaims(),
+		parkedRequests:   make(map[string]*time.Timer),
+		requestStore:     newRequestStore(""),
+		versions:         newVersionHistory(""),
+		expiries:         newExperimentExpiries(""),
+		healthChecks:     newHealthChecks(""),
+		auditLog:         newAuditLog(""),
+		stateReporter:    newStateReporter(env.StateReporting.Delta, env.StateReporting.FullSyncInterval),
+		runningTasks:     make(map[string]requestState),
+		healthProbes:     make(map[string][]healthProbe),
+		statsd:           &statsd.NoOpClient{},
+		experimentStarts: make(map[string]time.Time),
+		catalog:          catalog{},
+		stopChan:         make(chan struct{}),
+		subscribers:      newSubscribers(),
+		startTime:        time.Now(),
+		rolloutHost: rolloutHost{
+			ring:   env.RolloutRing,
+			groups: env.RolloutGroups,
+		},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		i.rolloutHost.hostname = hostname
+	} else {
+		log.Warnf("Daemon: could not get the hostname for the rollouts: %v", err)
+	}
+	channel, err := parseChannel(env.Channel)
+	if err != nil {
+		log.Errorf("Daemon: subscribing to the %s channel: %v", channelStable, err)
+		channel = channelStable
+	}
+	i.channel = channel
+	i.configuredChannel = channel
+	rc.SetChannel(channel)
+	if env.CatalogPath != "" {
+		localCatalog, err := loadLocalCatalog(env.CatalogPath)
+		if err != nil {
+			log.Errorf("Daemon: could not load local catalog: %v", err)
+		} else {
+			log.Infof("Daemon: Loaded %d packages from the local catalog %s", len(localCatalog.Packages), env.CatalogPath)
+			i.localCatalog = localCatalog
+		}
+	}
+	for _, w := range env.MaintenanceWindows {
+		window, err := parseMaintenanceWindow(w)
+		if err != nil {
+			log.Errorf("Daemon: ignoring maintenance window: %v", err)
+			continue
+		}
+		i.maintenanceWindows = append(i.maintenanceWindows, window)
+	}
+	for _, p := range env.ExperimentHealthProbes {
+		pkg, probe, err := parseHealthProbe(p)
+		if err != nil {
+			log.Errorf("Daemon: ignoring health probe: %v", err)
+			continue
+		}
+		i.healthProbes[pkg] = append(i.healthProbes[pkg], probe)
 	}
+	hooks, err := newExperimentHooks(env.ExperimentHooks.Command, env.ExperimentHooks.URL)
+	if err != nil {
+		log.Errorf("Daemon: ignoring experiment hooks: %v", err)
+	}
+	i.experimentHooks = hooks
+	if onFailure := env.ExperimentHooks.OnFailure; onFailure != "" && onFailure != hookOnFailureAbort && onFailure != hookOnFailureWarn {
+		log.Errorf("Daemon: unknown experiment hooks failure semantics %q, failing hooks abort the operations", onFailure)
+	}
+	i.urlRewrites = parseURLRewrites(env.URLRewrites)
+	notifier, err := newNotifier(env.Notifications.URL, env.Notifications.Events, env.Notifications.Timeout, env.Notifications.RetryBackoff)
+	if err != nil {
+		log.Errorf("Daemon: ignoring notification URL: %v", err)
+	}
+	i.notifier = notifier
 	i.refreshState(context.Background())
 	return i
 }
@@ -111,79 +290,85 @@ func (d *daemonImpl) GetState() (map[string]repository.State, error) {
 	return d.installer.States()
 }
 
-// GetAPMInjectionStatus returns the APM injection status. This is not done in the service
-// to avoid cross-contamination between the daemon and the installer.
-func (d *daemonImpl) GetAPMInjectionStatus() (status APMInjectionStatus, err error) {
+// GetRedactedEnv returns the environment passed to the installer subprocesses, with its secrets redacted.
+func (d *daemonImpl) GetRedactedEnv() []string {
 	d.m.Lock()
 	defer d.m.Unlock()
 
-	// Host is instrumented if the ld.so.preload file contains the apm injector
-	ldPreloadContent, err := os.ReadFile("/etc/ld.so.preload")
-	if err != nil {
-		return status, fmt.Errorf("could not read /etc/ld.so.preload: %w", err)
-	}
-	if bytes.Contains(ldPreloadContent, []byte("/opt/datadog-packages/datadog-apm-inject/stable/inject")) {
-		status.HostInstrumented = true
-	}
-
-	// Docker is installed if the docker binary is in the PATH
-	_, err = osexec.LookPath("docker")
-	if err != nil && errors.Is(err, osexec.ErrNotFound) {
-		return status, nil
-	} else if err != nil {
-		return status, fmt.Errorf("could not check if docker is installed: %w", err)
-	}
-	status.DockerInstalled = true
+	return d.env.ToRedactedEnv()
+}
 
-	// Docker is instrumented if there is the injector runtime in its configuration
-	// We're not retrieving the default runtime from the docker daemon as we are not
-	// root
-	dockerConfigContent, err := os.ReadFile("/etc/docker/daemon.json")
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return status, fmt.Errorf("could not read /etc/docker/daemon.json: %w", err)
-	} else if errors.Is(err, os.ErrNotExist) {
-		return status, nil
-	}
-	if bytes.Contains(dockerConfigContent, []byte("/opt/datadog-packages/datadog-apm-inject/stable/inject")) {
-		status.DockerInstrumented = true
-	}
+// GetAPMInjectionStatus returns the APM injection status. This is not done in the service
+// to avoid cross-contamination between the daemon and the installer.
+func (d *daemonImpl) GetAPMInjectionStatus() (APMInjectionStatus, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
 
-	return status, nil
+	return apmInjectionStatus()
 }
 
 // GetPackage returns the package with the given name and version.
 func (d *daemonImpl) GetPackage(pkg string, version string) (Package, error) {
-	d.m.Lock()
-	defer d.m.Unlock()
-
-	catalogPackage, ok := d.catalog.getPackage(pkg, version, runtime.GOARCH, runtime.GOOS)
+	catalogPackage, ok := d.getCatalogPackage(pkg, version, runtime.GOARCH, runtime.GOOS)
 	if !ok {
 		return Package{}, fmt.Errorf("could not get package %s, %s for %s, %s", pkg, version, runtime.GOARCH, runtime.GOOS)
 	}
 	return catalogPackage, nil
 }
 
-// Start starts remote config and the garbage collector.
+// Start starts remote config, the garbage collector, the heartbeat and the workers executing
+// the remote requests.
 func (d *daemonImpl) Start(_ context.Context) error {
 	d.m.Lock()
-	defer d.m.Unlock()
+	d.rc.SetHealth(d.health())
+	d.m.Unlock()
+	if err := d.versions.load(); err != nil {
+		log.Errorf("Daemon: could not load the version history: %v", err)
+	}
+	if err := d.expiries.load(); err != nil {
+		log.Errorf("Daemon: could not load the experiment expiries: %v", err)
+	}
+	// the channel is loaded before anything runs the installer subprocesses it's passed to
+	if err := d.loadChannel(); err != nil {
+		log.Errorf("Daemon: could not load the channel: %v", err)
+	}
+	d.expiries.start(d.expireExperiment)
+	d.resumeExperimentHealthChecks()
+	d.notifier.start(d.statsd)
+	d.resumeRemoteAPIRequests()
+	for i := 0; i < max(d.env.RemoteRequestWorkers, 1); i++ {
+		go d.remoteAPIRequestWorker()
+	}
 	go func() {
+		// the heartbeat is disabled if its interval isn't set
+		var heartbeat <-chan time.Time
+		if d.env.HeartbeatInterval > 0 {
+			heartbeatTicker := time.NewTicker(d.env.HeartbeatInterval)
+			defer heartbeatTicker.Stop()
+			heartbeat = heartbeatTicker.C
+		}
+		// the periodic garbage collection is disabled if its interval isn't set
+		var gc <-chan time.Time
+		if d.env.GCInterval > 0 {
+			gcTicker := time.NewTicker(d.env.GCInterval)
+			defer gcTicker.Stop()
+			gc = gcTicker.C
+		}
 		for {
 			select {
-			case <-time.After(gcInterval):
-				d.m.Lock()
-				err := d.installer.GarbageCollect(context.Background())
-				d.m.Unlock()
+			case <-gc:
+				err := d.GarbageCollect(withAuditRequester(context.Background(), requesterDaemon))
 				if err != nil {
 					log.Errorf("Daemon: could not run GC: %v", err)
 				}
+			case <-heartbeat:
+				d.refreshState(context.Background())
+				d.m.Lock()
+				d.rc.SetHealth(d.health())
+				d.m.Unlock()
 			case <-d.stopChan:
+				d.dropRemoteAPIRequests()
 				return
-			case request := <-d.requests:
-				err := d.handleRemoteAPIRequest(request)
-				if err != nil {
-					log.Errorf("Daemon: could not handle remote request: %v", err)
-				}
 			}
 		}
 	}()
@@ -195,70 +380,247 @@ func (d *daemonImpl) Start(_ context.Context) error {
 	return nil
 }
 
-// Stop stops the garbage collector.
+// remoteAPIRequestWorker executes the queued remote requests until the daemon is stopped
+func (d *daemonImpl) remoteAPIRequestWorker() {
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-d.requests.ready:
+			// requests queued while one is executed are picked by priority, skipping the ones for
+			// packages handled by other workers
+			for {
+				request, ok := d.requests.popFunc(d.claimRequest)
+				if !ok {
+					break
+				}
+				select {
+				case <-d.stopChan:
+					d.requestsWG.Done()
+					return
+				default:
+				}
+				err := d.handleRemoteAPIRequest(request)
+				if err != nil {
+					log.Errorf("Daemon: could not handle remote request: %v", err)
+				}
+				d.releaseRequest(request)
+			}
+		}
+	}
+}
+
+// claimRequest claims the package of the request for the worker handling it, flares are handled
+// whatever the requests being handled
+func (d *daemonImpl) claimRequest(request remoteAPIRequest) bool {
+	if request.Method == methodFlare {
+		return true
+	}
+	return d.claims.claim(requestPackage(request))
+}
+
+// releaseRequest releases the package of a handled request and wakes the workers up to pick the
+// requests queued for it
+func (d *daemonImpl) releaseRequest(request remoteAPIRequest) {
+	if request.Method == methodFlare {
+		return
+	}
+	d.claims.release(requestPackage(request))
+	d.requests.notify()
+}
+
+// Stop stops the garbage collector and the workers executing the remote requests.
 func (d *daemonImpl) Stop(_ context.Context) error {
-	d.m.Lock()
-	defer d.m.Unlock()
 	d.rc.Close()
 	close(d.stopChan)
+	d.expiries.stop()
+	d.healthChecks.stop()
+	d.m.Lock()
+	if d.rebootTimer != nil {
+		d.rebootTimer.Stop()
+	}
+	d.m.Unlock()
 	d.requestsWG.Wait()
+	d.notifier.stop()
+	d.subscribers.close()
 	return nil
 }
 
+// Subscribe returns a channel receiving an event whenever the state of the packages
+// or of a remote task changes, and a function to unsubscribe. Events are dropped
+// if the channel isn't drained fast enough.
+func (d *daemonImpl) Subscribe() (<-chan StateEvent, func()) {
+	return d.subscribers.subscribe()
+}
+
 // Install installs the package from the given URL.
 func (d *daemonImpl) Install(ctx context.Context, url string, args []string) error {
-	d.m.Lock()
-	defer d.m.Unlock()
+	// the package isn't known until it's downloaded
+	unlock := d.packages.lockAll()
+	defer unlock()
 	return d.install(ctx, url, args)
 }
 
 func (d *daemonImpl) install(ctx context.Context, url string, args []string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "install")
 	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditInstall, "", url, start, err) }()
+	d.notify(ctx, eventInstallStarted, auditInstall, "", url, nil)
+	defer func() {
+		if err != nil {
+			d.notify(ctx, eventInstallFailed, auditInstall, "", url, err)
+		} else {
+			d.notify(ctx, eventInstallSucceeded, auditInstall, "", url, nil)
+		}
+	}()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
-	log.Infof("Daemon: Installing package from %s", url)
-	err = d.installer.Install(ctx, url, args)
+	err = d.preflight(ctx, auditInstall, url)
 	if err != nil {
-		return fmt.Errorf("could not install: %w", err)
+		return err
 	}
-	log.Infof("Daemon: Successfully installed package from %s", url)
-	return nil
+	// the packages installed from a URL are only known once downloaded, only the ones of the catalog
+	// are hooked
+	pkg, _ := d.catalogPackageOfURL(url)
+	downloadURL, err := d.rewriteURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	return d.withExperimentHooks(ctx, auditInstall, pkg.Name, pkg.Version, func() error {
+		log.Infof("Daemon: Installing package from %s", url)
+		err := d.installer.Install(ctx, downloadURL, args)
+		if err != nil {
+			return fmt.Errorf("could not install: %w", err)
+		}
+		log.Infof("Daemon: Successfully installed package from %s", url)
+		return nil
+	})
+}
+
+// InstallDryRun reports what installing the package from the given URL would change, without
+// changing anything. Dry runs don't change the packages, they don't wait for the operations in progress.
+func (d *daemonImpl) InstallDryRun(ctx context.Context, url string) (_ *installer.DryRunReport, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "install_dry_run")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	log.Infof("Daemon: Checking install of package from %s", url)
+	downloadURL, err := d.rewriteURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	report, err := d.installer.InstallDryRun(ctx, downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not check install: %w", err)
+	}
+	if rawReport, err := json.Marshal(report); err == nil {
+		span.SetTag("dry_run_report", string(rawReport))
+	}
+	log.Infof("Daemon: Install of package from %s would make changes: %v", url, report.Changes)
+	return report, nil
 }
 
 // StartExperiment starts an experiment with the given package.
 func (d *daemonImpl) StartExperiment(ctx context.Context, url string) error {
-	d.m.Lock()
-	defer d.m.Unlock()
+	// the package isn't known until it's downloaded
+	unlock := d.packages.lockAll()
+	defer unlock()
 	return d.startExperiment(ctx, url)
 }
 
 func (d *daemonImpl) startExperiment(ctx context.Context, url string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "start_experiment")
 	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditStartExperiment, "", url, start, err) }()
+	defer func() { d.notifyExperiment(ctx, eventExperimentStarted, auditStartExperiment, "", url, err) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
-	log.Infof("Daemon: Starting experiment for package from %s", url)
-	err = d.installer.InstallExperiment(ctx, url)
+	err = d.preflight(ctx, auditStartExperiment, url)
 	if err != nil {
-		return fmt.Errorf("could not install experiment: %w", err)
+		return err
 	}
-	log.Infof("Daemon: Successfully started experiment for package from %s", url)
-	return nil
+	pkg, _ := d.catalogPackageOfURL(url)
+	downloadURL, err := d.rewriteURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	return d.withExperimentHooks(ctx, auditStartExperiment, pkg.Name, pkg.Version, func() error {
+		log.Infof("Daemon: Starting experiment for package from %s", url)
+		err := d.installer.InstallExperiment(ctx, downloadURL)
+		if err != nil {
+			return fmt.Errorf("could not install experiment: %w", err)
+		}
+		log.Infof("Daemon: Successfully started experiment for package from %s", url)
+		return nil
+	})
+}
+
+// StartExperimentDryRun reports what starting an experiment with the given package would change,
+// without changing anything.
+func (d *daemonImpl) StartExperimentDryRun(ctx context.Context, url string) (*installer.DryRunReport, error) {
+	return d.startExperimentDryRun(ctx, url)
+}
+
+func (d *daemonImpl) startExperimentDryRun(ctx context.Context, url string) (_ *installer.DryRunReport, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "start_experiment_dry_run")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	log.Infof("Daemon: Checking experiment for package from %s", url)
+	downloadURL, err := d.rewriteURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	report, err := d.installer.InstallExperimentDryRun(ctx, downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not check experiment: %w", err)
+	}
+	if rawReport, err := json.Marshal(report); err == nil {
+		span.SetTag("dry_run_report", string(rawReport))
+	}
+	log.Infof("Daemon: Experiment for package from %s would make changes: %v", url, report.Changes)
+	return report, nil
 }
 
 func (d *daemonImpl) startInstallerExperiment(ctx context.Context, url string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "start_installer_experiment")
 	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditStartExperiment, "datadog-installer", url, start, err) }()
+	defer func() {
+		d.notifyExperiment(ctx, eventExperimentStarted, auditStartExperiment, "datadog-installer", url, err)
+	}()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
+	err = d.preflight(ctx, auditStartExperiment, url)
+	if err != nil {
+		return err
+	}
+	// the installer experiment restarts the daemon: the after hooks are run by the restarted daemon once
+	// it completes the request, or here if the experiment couldn't be started
+	downloadURL, err := d.rewriteURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	pkg, _ := d.catalogPackageOfURL(url)
+	event, hooked := d.experimentHookEvent(ctx, auditStartExperiment, "datadog-installer", pkg.Version)
+	if hooked {
+		err = d.runExperimentHooks(ctx, hookPhaseBefore, event)
+		if err != nil {
+			return d.runExperimentHooksAfter(ctx, event, fmt.Errorf("could not run experiment hooks: %w", err))
+		}
+	}
 	log.Infof("Daemon: Starting installer experiment for package from %s", url)
-	err = bootstrap.InstallExperiment(ctx, d.env, url)
+	err = installInstallerExperiment(ctx, d.env, downloadURL)
 	if err != nil {
-		return fmt.Errorf("could not install installer experiment: %w", err)
+		err = fmt.Errorf("could not install installer experiment: %w", err)
+		if hooked {
+			return d.runExperimentHooksAfter(ctx, event, err)
+		}
+		return err
 	}
 	log.Infof("Daemon: Successfully started installer experiment for package from %s", url)
 	return nil
@@ -266,189 +628,1198 @@ func (d *daemonImpl) startInstallerExperiment(ctx context.Context, url string) (
 
 // PromoteExperiment promotes the experiment to stable.
 func (d *daemonImpl) PromoteExperiment(ctx context.Context, pkg string) error {
-	d.m.Lock()
-	defer d.m.Unlock()
+	unlock := d.packages.lock(pkg)
+	defer unlock()
 	return d.promoteExperiment(ctx, pkg)
 }
 
 func (d *daemonImpl) promoteExperiment(ctx context.Context, pkg string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "promote_experiment")
 	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditPromoteExperiment, pkg, "", start, err) }()
+	defer func() { d.notifyExperiment(ctx, eventExperimentPromoted, auditPromoteExperiment, pkg, "", err) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
-	log.Infof("Daemon: Promoting experiment for package %s", pkg)
-	err = d.installer.PromoteExperiment(ctx, pkg)
+	err = d.withExperimentHooks(ctx, auditPromoteExperiment, pkg, "", func() error {
+		log.Infof("Daemon: Promoting experiment for package %s", pkg)
+		err := d.installer.PromoteExperiment(ctx, pkg)
+		if err != nil {
+			return fmt.Errorf("could not promote experiment: %w", err)
+		}
+		log.Infof("Daemon: Successfully promoted experiment for package %s", pkg)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("could not promote experiment: %w", err)
+		return err
 	}
-	log.Infof("Daemon: Successfully promoted experiment for package %s", pkg)
+	d.expiries.cancel(pkg)
+	d.healthChecks.cancel(pkg)
+	d.reportExperimentEnd(pkg, "promoted")
 	return nil
 }
 
 // StopExperiment stops the experiment.
 func (d *daemonImpl) StopExperiment(ctx context.Context, pkg string) error {
-	d.m.Lock()
-	defer d.m.Unlock()
+	unlock := d.packages.lock(pkg)
+	defer unlock()
 	return d.stopExperiment(ctx, pkg)
 }
 
 func (d *daemonImpl) stopExperiment(ctx context.Context, pkg string) (err error) {
 	span, ctx := tracer.StartSpanFromContext(ctx, "stop_experiment")
 	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditStopExperiment, pkg, "", start, err) }()
+	defer func() { d.notifyExperiment(ctx, eventExperimentStopped, auditStopExperiment, pkg, "", err) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
-	log.Infof("Daemon: Stopping experiment for package %s", pkg)
-	err = d.installer.RemoveExperiment(ctx, pkg)
+	err = d.withExperimentHooks(ctx, auditStopExperiment, pkg, "", func() error {
+		log.Infof("Daemon: Stopping experiment for package %s", pkg)
+		err := d.installer.RemoveExperiment(ctx, pkg)
+		if err != nil {
+			return fmt.Errorf("could not stop experiment: %w", err)
+		}
+		log.Infof("Daemon: Successfully stopped experiment for package %s", pkg)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("could not stop experiment: %w", err)
+		return err
 	}
-	log.Infof("Daemon: Successfully stopped experiment for package %s", pkg)
+	d.expiries.cancel(pkg)
+	d.healthChecks.cancel(pkg)
+	d.reportExperimentEnd(pkg, "stopped")
 	return nil
 }
 
-func (d *daemonImpl) handleCatalogUpdate(c catalog) error {
-	d.m.Lock()
-	defer d.m.Unlock()
-	log.Infof("Installer: Received catalog update")
-	d.catalog = c
-	return nil
+// Rollback sets the version of the package that was stable before the last promotion as stable again.
+func (d *daemonImpl) Rollback(ctx context.Context, pkg string) error {
+	unlock := d.packages.lock(pkg)
+	defer unlock()
+	return d.rollback(ctx, pkg)
 }
 
-func (d *daemonImpl) scheduleRemoteAPIRequest(request remoteAPIRequest) error {
-	d.requestsWG.Add(1)
-	d.requests <- request
+func (d *daemonImpl) rollback(ctx context.Context, pkg string) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "rollback")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditRollback, pkg, "", start, err) }()
+	d.refreshState(ctx)
+	defer d.refreshState(ctx)
+
+	log.Infof("Daemon: Rolling back package %s", pkg)
+	err = d.installer.Rollback(ctx, pkg)
+	if err != nil {
+		return fmt.Errorf("could not rollback: %w", err)
+	}
+	log.Infof("Daemon: Successfully rolled back package %s", pkg)
 	return nil
 }
 
-func (d *daemonImpl) handleRemoteAPIRequest(request remoteAPIRequest) (err error) {
-	d.m.Lock()
-	defer d.m.Unlock()
-	defer d.requestsWG.Done()
-	parentSpan, ctx := newRequestContext(request)
-	defer parentSpan.Finish(tracer.WithError(err))
+// Uninstall removes the package from the host.
+func (d *daemonImpl) Uninstall(ctx context.Context, pkg string) error {
+	unlock := d.packages.lock(pkg)
+	defer unlock()
+	return d.uninstall(ctx, pkg)
+}
+
+func (d *daemonImpl) uninstall(ctx context.Context, pkg string) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "uninstall")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditUninstall, pkg, "", start, err) }()
 	d.refreshState(ctx)
 	defer d.refreshState(ctx)
 
-	s, err := d.installer.State(request.Package)
-	if err != nil {
-		return fmt.Errorf("could not get installer state: %w", err)
-	}
-	versionEqual := request.ExpectedState.InstallerVersion == "" || version.AgentVersion == request.ExpectedState.InstallerVersion
-	if versionEqual && s.Stable != request.ExpectedState.Stable || s.Experiment != request.ExpectedState.Experiment {
-		log.Infof("remote request %s not executed as state does not match: expected %v, got %v", request.ID, request.ExpectedState, s)
-		setRequestInvalid(ctx)
-		d.refreshState(ctx)
-		return nil
+	if pkg == "datadog-installer" {
+		// the daemon would remove itself and couldn't report the result
+		return fmt.Errorf("could not uninstall: the installer can't be uninstalled by the daemon")
 	}
-	defer func() { setRequestDone(ctx, err) }()
-
-	switch request.Method {
-	case methodStartExperiment:
-		var params taskWithVersionParams
-		err = json.Unmarshal(request.Params, &params)
-		if err != nil {
-			return fmt.Errorf("could not unmarshal start experiment params: %w", err)
-		}
-		experimentPackage, ok := d.catalog.getPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
-		if !ok {
-			return fmt.Errorf("could not get package %s, %s for %s, %s", request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
-		}
-		log.Infof("Installer: Received remote request %s to start experiment for package %s version %s", request.ID, request.Package, request.Params)
-		if request.Package == "datadog-installer" {
-			// Special case for the installer package as we want the experiment installer to start the experiment itself
-			return d.startInstallerExperiment(ctx, experimentPackage.URL)
-		}
-		return d.startExperiment(ctx, experimentPackage.URL)
-	case methodStopExperiment:
-		log.Infof("Installer: Received remote request %s to stop experiment for package %s", request.ID, request.Package)
-		return d.stopExperiment(ctx, request.Package)
-	case methodPromoteExperiment:
-		log.Infof("Installer: Received remote request %s to promote experiment for package %s", request.ID, request.Package)
-		return d.promoteExperiment(ctx, request.Package)
-	default:
-		return fmt.Errorf("unknown method: %s", request.Method)
+	log.Infof("Daemon: Uninstalling package %s", pkg)
+	err = d.installer.Remove(ctx, pkg)
+	if err != nil {
+		return fmt.Errorf("could not uninstall: %w", err)
 	}
+	log.Infof("Daemon: Successfully uninstalled package %s", pkg)
+	return nil
 }
 
-type requestKey int
+// RotateAPIKey replaces the API key used by the agent and the installer.
+func (d *daemonImpl) RotateAPIKey(ctx context.Context, apiKey string) error {
+	unlock := d.packages.lockAll()
+	defer unlock()
+	return d.rotateAPIKey(ctx, apiKey)
+}
 
-var requestStateKey requestKey
+func (d *daemonImpl) rotateAPIKey(ctx context.Context, apiKey string) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "rotate_api_key")
+	defer func() { span.Finish(tracer.WithError(err)) }()
 
-// requestState represents the state of a task.
-type requestState struct {
-	Package string
-	ID      string
-	State   pbgo.TaskState
-	Err     *installerErrors.InstallerError
+	log.Infof("Daemon: Rotating API key")
+	d.m.Lock()
+	site := d.env.Site
+	d.m.Unlock()
+	// the agent units are restarted with the new key, it must be valid before they are
+	err = validateAPIKey(ctx, site, apiKey)
+	if err != nil {
+		return fmt.Errorf("could not validate the new API key: %w", err)
+	}
+	err = d.installer.RotateAPIKey(ctx, apiKey)
+	if err != nil {
+		return fmt.Errorf("could not rotate API key: %w", err)
+	}
+	// The installer subprocesses get the new key, the remote config client of the daemon
+	// keeps using the key it started with until the daemon is restarted.
+	d.m.Lock()
+	d.env.APIKey = apiKey
+	d.m.Unlock()
+	log.Infof("Daemon: Successfully rotated API key")
+	return nil
 }
 
-func newRequestContext(request remoteAPIRequest) (ddtrace.Span, context.Context) {
-	ctx := context.WithValue(context.Background(), requestStateKey, &requestState{
-		Package: request.Package,
-		ID:      request.ID,
-		State:   pbgo.TaskState_RUNNING,
-	})
+// setIntegrationConfigs writes the integration configurations delivered remotely to the conf.d overlay
+// read by the agent ahead of the user configurations.
+func (d *daemonImpl) setIntegrationConfigs(ctx context.Context, params setIntegrationConfigsParams) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "set_integration_configs")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("version", params.Version)
 
-	ctxCarrier := tracer.TextMapCarrier{
-		tracer.DefaultTraceIDHeader:  request.TraceID,
-		tracer.DefaultParentIDHeader: request.ParentSpanID,
-		tracer.DefaultPriorityHeader: "2",
+	files := make(map[string][]byte, len(params.Files))
+	for name, content := range params.Files {
+		files[name] = []byte(content)
 	}
-	spanCtx, err := tracer.Extract(ctxCarrier)
+	log.Infof("Daemon: Setting integration configurations to version %s", params.Version)
+	err = d.installer.SetIntegrationConfigs(ctx, params.Version, files)
 	if err != nil {
-		log.Debugf("failed to extract span context from install script params: %v", err)
-		return tracer.StartSpan("remote_request"), ctx
+		return fmt.Errorf("could not set integration configurations: %w", err)
 	}
-
-	return tracer.StartSpanFromContext(ctx, "remote_request", tracer.ChildOf(spanCtx))
+	log.Infof("Daemon: Successfully set integration configurations to version %s", params.Version)
+	return nil
 }
 
-func setRequestInvalid(ctx context.Context) {
-	state := ctx.Value(requestStateKey).(*requestState)
-	state.State = pbgo.TaskState_INVALID_STATE
-}
+// rollbackIntegrationConfigs restores the previous integration configurations delivered remotely.
+func (d *daemonImpl) rollbackIntegrationConfigs(ctx context.Context) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "rollback_integration_configs")
+	defer func() { span.Finish(tracer.WithError(err)) }()
 
-func setRequestDone(ctx context.Context, err error) {
-	state := ctx.Value(requestStateKey).(*requestState)
-	state.State = pbgo.TaskState_DONE
+	log.Infof("Daemon: Rolling back integration configurations")
+	err = d.installer.RollbackIntegrationConfigs(ctx)
 	if err != nil {
-		state.State = pbgo.TaskState_ERROR
-		state.Err = installerErrors.From(err)
+		return fmt.Errorf("could not rollback integration configurations: %w", err)
 	}
+	log.Infof("Daemon: Successfully rolled back integration configurations")
+	return nil
 }
 
-func (d *daemonImpl) refreshState(ctx context.Context) {
-	state, err := d.installer.States()
+// maxReportedPermissionIssues bounds the files reported with a check_permissions task, a recursive
+// chown of the host changes the owner of every file of the packages
+const maxReportedPermissionIssues = 10
+
+// checkPermissions checks the owners and modes of the files of the packages, restoring them if asked
+// to. The issues left are returned with the ErrInvalidPermissions code so that they are reported with
+// the task.
+func (d *daemonImpl) checkPermissions(ctx context.Context, repair bool) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "check_permissions")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("repair", repair)
+
+	log.Infof("Daemon: Checking permissions of the packages")
+	var issues []repository.PermissionIssue
+	if repair {
+		issues, err = d.installer.RepairPermissions(ctx)
+	} else {
+		issues, err = d.installer.CheckPermissions(ctx)
+	}
 	if err != nil {
-		// TODO: we should report this error through RC in some way
+		return fmt.Errorf("could not check permissions of the packages: %w", err)
+	}
+	var invalid []string
+	for _, issue := range issues {
+		if issue.Repaired {
+			log.Infof("Daemon: Repaired permissions of %s: %s", issue.Path, issue.Reason)
+			continue
+		}
+		invalid = append(invalid, issue.Path+" "+issue.Reason)
+	}
+	span.SetTag("issues", len(issues))
+	span.SetTag("invalid", len(invalid))
+	if len(invalid) == 0 {
+		log.Infof("Daemon: Successfully checked permissions of the packages")
+		return nil
+	}
+	reported := invalid
+	if len(reported) > maxReportedPermissionIssues {
+		reported = append(reported[:maxReportedPermissionIssues:maxReportedPermissionIssues], fmt.Sprintf("and %d more", len(invalid)-maxReportedPermissionIssues))
+	}
+	return installerErrors.Wrap(
+		installerErrors.ErrInvalidPermissions,
+		fmt.Errorf("%d files of the packages have unexpected permissions: %s", len(invalid), strings.Join(reported, ", ")),
+	)
+}
+
+// diagnose reports with the task the modification (un)instrumenting docker would make to the docker
+// daemon configuration, so that it can be reviewed before being applied.
+func (d *daemonImpl) diagnose(ctx context.Context, uninstrument bool) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "diagnose")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("uninstrument", uninstrument)
+
+	diff, err := d.installer.DiffAPMInjectorDocker(ctx, uninstrument)
+	if err != nil {
+		return fmt.Errorf("could not diagnose the docker daemon configuration: %w", err)
+	}
+	span.SetTag("has_changes", diff.HasChanges())
+	log.Infof("Daemon: Diagnosed the docker daemon configuration %s (changes: %t)", diff.Path, diff.HasChanges())
+	return setRequestReport(ctx, diff)
+}
+
+// GarbageCollect removes the packages and objects no operation uses anymore.
+func (d *daemonImpl) GarbageCollect(ctx context.Context) error {
+	unlock := d.packages.lockAll()
+	defer unlock()
+	return d.garbageCollect(ctx)
+}
+
+func (d *daemonImpl) garbageCollect(ctx context.Context) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "garbage_collect")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	start := time.Now()
+	defer func() { d.audit(ctx, auditGarbageCollect, "", "", start, err) }()
+
+	log.Infof("Daemon: Running garbage collection")
+	availableBefore, diskErr := packagesDiskAvailable()
+	err = d.installer.GarbageCollect(ctx)
+	if err != nil {
+		return fmt.Errorf("could not garbage collect: %w", err)
+	}
+	if diskErr == nil {
+		d.reportReclaimedBytes(availableBefore)
+	}
+	d.m.Lock()
+	d.lastGC = time.Now()
+	d.m.Unlock()
+	log.Infof("Daemon: Successfully ran garbage collection")
+	return nil
+}
+
+// getCatalogPackage returns a package of the catalog received from remote config, or of the local catalog,
+// available in the channel of the host
+func (d *daemonImpl) getCatalogPackage(pkg string, version string, arch string, platform string) (Package, bool) {
+	p, ok := d.findCatalogPackage(pkg, version, arch, platform)
+	if !ok || !p.availableIn(d.GetChannel()) {
+		return Package{}, false
+	}
+	return p, true
+}
+
+// findCatalogPackage returns a package of the catalog received from remote config, or of the local catalog,
+// whatever its channel
+func (d *daemonImpl) findCatalogPackage(pkg string, version string, arch string, platform string) (Package, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if p, ok := d.catalog.getPackage(pkg, version, arch, platform); ok {
+		return p, true
+	}
+	return d.localCatalog.getPackage(pkg, version, arch, platform)
+}
+
+// GetCatalog returns the packages of the catalog received from remote config and of the local
+// catalog that are available for the platform and architecture of the host and whose rollout
+// targets it.
+func (d *daemonImpl) GetCatalog() []Package {
+	d.m.Lock()
+	defer d.m.Unlock()
+	var packages []Package
+	for _, c := range []catalog{d.catalog, d.localCatalog} {
+		for _, p := range c.Packages {
+			if (p.Arch != "" && p.Arch != runtime.GOARCH) || (p.Platform != "" && p.Platform != runtime.GOOS) || !p.availableIn(d.channel) {
+				continue
+			}
+			if !p.Rollout.includes(p.Name, d.rolloutHost) {
+				continue
+			}
+			// packages of the remote config catalog shadow the ones of the local catalog
+			if slices.ContainsFunc(packages, func(other Package) bool { return other.Name == p.Name && other.Version == p.Version }) {
+				continue
+			}
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+func (d *daemonImpl) handleCatalogUpdate(c catalog) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	log.Infof("Installer: Received catalog update")
+	// the versions of the pinned packages other than the pinned ones are never made available
+	packages := make([]Package, 0, len(c.Packages))
+	for _, p := range c.Packages {
+		if err := d.checkPinnedVersion(p.Name, p.Version); err != nil {
+			log.Infof("Installer: Ignoring version %s of package %s from the catalog: %v", p.Version, p.Name, err)
+			continue
+		}
+		packages = append(packages, p)
+	}
+	c.Packages = packages
+	d.catalog = c
+	d.catalogUpdatedAt = time.Now()
+	return nil
+}
+
+func (d *daemonImpl) scheduleRemoteAPIRequest(request remoteAPIRequest) error {
+	// remote config sends the requests again after a restart, the ones resumed from the store are skipped
+	if !d.requestStore.add(request) {
+		log.Infof("Installer: Ignoring remote request %s as it's already queued", request.ID)
+		return nil
+	}
+	d.requestsWG.Add(1)
+	d.requests.push(request)
+	return nil
+}
+
+// parkRemoteAPIRequest queues the request again once the next maintenance window opens, reporting it
+// as pending meanwhile. The windows are evaluated again periodically as the wall clock can jump, e.g.
+// on NTP syncs or when a VM is restored from a snapshot, while timers follow the monotonic clock.
+func (d *daemonImpl) parkRemoteAPIRequest(request remoteAPIRequest, wait time.Duration) {
+	d.m.Lock()
+	select {
+	case <-d.stopChan:
+		// the request stays in the request store for the next daemon
+		d.m.Unlock()
+		return
+	default:
+	}
+	task, ok := d.runningTasks[request.Package]
+	reported := ok && task.ID == request.ID && task.State == pbgo.TaskState_PENDING
+	d.requestsWG.Add(1)
+	d.parkedRequests[request.ID] = time.AfterFunc(min(wait, maintenanceWindowRecheckInterval), func() {
+		d.m.Lock()
+		defer d.m.Unlock()
+		// the request was dropped if the daemon was stopped meanwhile
+		if _, ok := d.parkedRequests[request.ID]; !ok {
+			return
+		}
+		delete(d.parkedRequests, request.ID)
+		d.requests.push(request)
+	})
+	d.m.Unlock()
+	if reported {
+		return
+	}
+	log.Infof("Installer: Queuing remote request %s until the next maintenance window in %s", request.ID, wait)
+	ctx := newRequestStateContext(request)
+	setRequestPending(ctx, wait)
+	d.refreshState(ctx)
+}
+
+// dropRemoteAPIRequests drops the requests still queued when the daemon is stopped, they're kept in
+// the request store to be resumed by the next daemon
+func (d *daemonImpl) dropRemoteAPIRequests() {
+	d.m.Lock()
+	for id, timer := range d.parkedRequests {
+		log.Infof("Installer: Dropping remote request %s waiting for the next maintenance window as the daemon is stopping", id)
+		timer.Stop()
+		delete(d.parkedRequests, id)
+		d.requestsWG.Done()
+	}
+	d.m.Unlock()
+	for request, ok := d.requests.pop(); ok; request, ok = d.requests.pop() {
+		log.Infof("Installer: Dropping remote request %s as the daemon is stopping", request.ID)
+		d.requestsWG.Done()
+	}
+}
+
+// resumeRemoteAPIRequests queues the requests persisted by the previous daemon again, reports the
+// ones it was executing as aborted as they may have been interrupted half-way, and the outcome of the
+// ones it handed off to this daemon
+func (d *daemonImpl) resumeRemoteAPIRequests() {
+	queued, aborted, handedOff, err := d.requestStore.load()
+	if err != nil {
+		log.Errorf("Daemon: could not load the persisted remote requests: %v", err)
+		return
+	}
+	for _, request := range handedOff {
+		d.completeHandedOffRequest(request)
+	}
+	for _, request := range aborted {
+		log.Warnf("Installer: Remote request %s was aborted by a restart of the daemon", request.ID)
+		d.abortRemoteAPIRequest(request)
+	}
+	for _, request := range queued {
+		log.Infof("Installer: Resuming remote request %s queued before the restart of the daemon", request.ID)
+		d.requestsWG.Add(1)
+		d.requests.push(request)
+	}
+}
+
+// abortRemoteAPIRequest reports a request as failed without executing it. The task stays reported
+// until another request for the package is handled.
+func (d *daemonImpl) abortRemoteAPIRequest(request remoteAPIRequest) {
+	span, ctx := newRequestContext(request)
+	err := fmt.Errorf("remote request %s was aborted by a restart of the daemon", request.ID)
+	defer span.Finish(tracer.WithError(err))
+	setRequestDone(ctx, err)
+	d.refreshState(ctx)
+	d.requestStore.remove(request.ID)
+}
+
+// completeHandedOffRequest reports the outcome of a request that restarted the previous daemon: the
+// installer experiment it started is done if it's the one running.
+func (d *daemonImpl) completeHandedOffRequest(request remoteAPIRequest) {
+	span, ctx := newRequestContext(request)
+	var err error
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	var params taskWithVersionParams
+	err = json.Unmarshal(request.Params, &params)
+	if err != nil {
+		err = fmt.Errorf("could not unmarshal start experiment params: %w", err)
+	} else if s, stateErr := d.installer.State(request.Package); stateErr != nil {
+		err = fmt.Errorf("could not get installer state: %w", stateErr)
+	} else if s.Experiment != params.Version {
+		err = fmt.Errorf("experiment for package %s version %s isn't running after the restart of the daemon", request.Package, params.Version)
+	}
+	// the hooks run before the installer experiment are completed by the restarted daemon
+	if event, ok := d.experimentHookEvent(ctx, auditStartExperiment, request.Package, params.Version); ok {
+		err = d.runExperimentHooksAfter(ctx, event, err)
+	}
+	if err != nil {
+		log.Warnf("Installer: Remote request %s handed off to the restarted daemon failed: %v", request.ID, err)
+	} else {
+		log.Infof("Installer: Remote request %s handed off to the restarted daemon is done", request.ID)
+	}
+	setRequestDone(ctx, err)
+	d.refreshState(ctx)
+	d.requestStore.remove(request.ID)
+}
+
+func (d *daemonImpl) handleRemoteAPIRequest(request remoteAPIRequest) (err error) {
+	defer d.requestsWG.Done()
+	// requests waiting for the next maintenance window don't hold a worker. Flares don't change the
+	// packages, they are sent outside of the windows, and blocked packages are refused without waiting.
+	if request.Method != methodFlare && !d.packageBlocked(request) {
+		if wait := maintenanceDelay(timeNow(), d.env.MaintenanceBlackout, d.maintenanceWindows); wait > 0 {
+			d.parkRemoteAPIRequest(request, wait)
+			return nil
+		}
+	}
+	parentSpan, ctx := newRequestContext(request)
+	defer parentSpan.Finish(tracer.WithError(err))
+	start := time.Now()
+	// the duration metric only measures the execution of the request, not its delay or the wait for the lock
+	opStart := start
+	defer func() { d.reportRemoteAPIRequest(request, time.Since(opStart), err) }()
+	defer func() { d.auditRemoteAPIRequest(ctx, request, start, err) }()
+	defer d.untrackRequest(ctx)
+	parentSpan.SetTag("priority", request.Priority)
+
+	if d.packageBlocked(request) {
+		log.Warnf("Installer: Refusing remote request %s as package %s is blocked on this host", request.ID, request.Package)
+		err = installerErrors.Wrap(
+			installerErrors.ErrPackageBlocked,
+			fmt.Errorf("package %s is blocked from remote management on this host", request.Package),
+		)
+		setRequestDone(ctx, err)
+		d.refreshState(ctx)
+		d.requestStore.remove(request.ID)
+		return err
+	}
+
+	delay := d.remoteRequestDelay()
+	parentSpan.SetTag("delay", delay.String())
+	if delay > 0 {
+		setRequestDelay(ctx, delay)
+		d.refreshState(ctx)
+		log.Infof("Installer: Delaying remote request %s by %s", request.ID, delay)
+		select {
+		case <-time.After(delay):
+		case <-d.stopChan:
+			return fmt.Errorf("daemon stopped before executing remote request %s", request.ID)
+		}
+	}
+
+	// flares don't change the packages, they are sent while the packages are being updated
+	if request.Method != methodFlare {
+		unlock := d.packages.lock(requestPackage(request))
+		defer unlock()
+	}
+	opStart = time.Now()
+	// the request is reported as aborted if the daemon restarts before it's done
+	d.requestStore.setRunning(request.ID)
+	// the requests handed off to the next daemon are removed by it once it reported their outcome
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			d.requestStore.remove(request.ID)
+		}
+	}()
+	d.refreshState(ctx)
+	defer d.refreshState(ctx)
+
+	// flares don't change the packages, they are sent whatever their state
+	if request.Method == methodFlare {
+		defer func() { setRequestDone(ctx, err) }()
+		var params flareParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal flare params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to send a fleet flare", request.ID)
+		return d.sendFleetFlare(ctx, params, helpers.NewRemoteConfigFlareSource(request.ID))
+	}
+
+	s, err := d.installer.State(request.Package)
+	if err != nil {
+		return fmt.Errorf("could not get installer state: %w", err)
+	}
+	versionEqual := request.ExpectedState.InstallerVersion == "" || version.AgentVersion == request.ExpectedState.InstallerVersion
+	if versionEqual && s.Stable != request.ExpectedState.Stable || s.Experiment != request.ExpectedState.Experiment {
+		log.Infof("remote request %s not executed as state does not match: expected %v, got %v", request.ID, request.ExpectedState, s)
+		setRequestInvalid(ctx, nil)
+		d.refreshState(ctx)
+		return nil
+	}
+	if !d.targetedByRollout(request) {
+		log.Infof("remote request %s not executed as the host isn't targeted by the rollout of package %s", request.ID, request.Package)
+		setRequestInvalid(ctx, nil)
+		d.refreshState(ctx)
+		return nil
+	}
+	if channelErr := d.checkChannelRequest(request); channelErr != nil {
+		log.Warnf("Installer: remote request %s not executed: %v", request.ID, channelErr)
+		setRequestInvalid(ctx, channelErr)
+		d.refreshState(ctx)
+		return nil
+	}
+	if pinErr := d.checkPinnedRequest(request, s); pinErr != nil {
+		log.Warnf("Installer: remote request %s not executed: %v", request.ID, pinErr)
+		setRequestInvalid(ctx, pinErr)
+		d.refreshState(ctx)
+		return nil
+	}
+	if downgradeErr := d.checkDowngradeRequest(ctx, request); downgradeErr != nil {
+		log.Warnf("Installer: remote request %s not executed: %v", request.ID, downgradeErr)
+		setRequestInvalid(ctx, downgradeErr)
+		d.refreshState(ctx)
+		return nil
+	}
+	defer func() { setRequestDone(ctx, err) }()
+
+	switch request.Method {
+	case methodStartExperiment:
+		var params taskWithVersionParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal start experiment params: %w", err)
+		}
+		experimentPackage, ok := d.getCatalogPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+		if !ok {
+			return fmt.Errorf("could not get package %s, %s for %s, %s", request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+		}
+		var ttl time.Duration
+		if params.TTL != "" {
+			ttl, err = time.ParseDuration(params.TTL)
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("invalid experiment ttl %q", params.TTL)
+			}
+		}
+		if params.DryRun {
+			log.Infof("Installer: Received remote request %s to check experiment for package %s version %s", request.ID, request.Package, params.Version)
+			report, err := d.startExperimentDryRun(ctx, experimentPackage.URL)
+			if err != nil {
+				return err
+			}
+			return setRequestReport(ctx, report)
+		}
+		log.Infof("Installer: Received remote request %s to start experiment for package %s version %s", request.ID, request.Package, request.Params)
+		// the expiry is set beforehand as the installer experiment restarts the daemon
+		if ttl > 0 {
+			d.expiries.schedule(request.Package, experimentExpiry{RequestID: request.ID, Version: params.Version, ExpiresAt: time.Now().Add(ttl)})
+			defer func() {
+				if err != nil {
+					d.expiries.cancel(request.Package)
+				}
+			}()
+		}
+		if request.Package == "datadog-installer" {
+			// Special case for the installer package as we want the experiment installer to start the experiment itself.
+			// It restarts the daemon, the next one reports the outcome of the request.
+			d.requestStore.setHandedOff(request.ID)
+			err = d.startInstallerExperiment(ctx, experimentPackage.URL)
+			handedOff = err == nil
+			return err
+		}
+		err = d.startExperiment(d.withDeltaBase(ctx, request.Package, s.Stable), experimentPackage.URL)
+		if err != nil {
+			return err
+		}
+		d.reportDownload(request.Package, params.Version)
+		d.trackExperiment(request.Package)
+		// the task is reported as failed if the experiment is rolled back by its health checks
+		d.watchExperimentHealth(request.Package, pendingHealthCheck{RequestID: request.ID, Version: params.Version})
+		return nil
+	case methodStopExperiment:
+		log.Infof("Installer: Received remote request %s to stop experiment for package %s", request.ID, request.Package)
+		return d.stopExperiment(ctx, request.Package)
+	case methodPromoteExperiment:
+		log.Infof("Installer: Received remote request %s to promote experiment for package %s", request.ID, request.Package)
+		return d.promoteExperiment(ctx, request.Package)
+	case methodRollback:
+		log.Infof("Installer: Received remote request %s to rollback package %s", request.ID, request.Package)
+		return d.rollback(ctx, request.Package)
+	case methodUninstall:
+		log.Infof("Installer: Received remote request %s to uninstall package %s", request.ID, request.Package)
+		return d.uninstall(ctx, request.Package)
+	case methodRotateAPIKey:
+		var params rotateAPIKeyParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal rotate API key params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to rotate the API key", request.ID)
+		d.m.Lock()
+		currentAPIKey := d.env.APIKey
+		d.m.Unlock()
+		apiKey, err := decryptAPIKey(params.EncryptedAPIKey, currentAPIKey)
+		if err != nil {
+			return fmt.Errorf("could not rotate API key: %w", err)
+		}
+		return d.rotateAPIKey(ctx, apiKey)
+	case methodReboot:
+		var params rebootParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal reboot params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to reboot the host for package %s", request.ID, request.Package)
+		return d.scheduleReboot(ctx, request.Package, params)
+	case methodSetChannel:
+		var params setChannelParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal set channel params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to switch to the %s channel", request.ID, params.Channel)
+		return d.setChannel(ctx, params.Channel)
+	case methodCheckPermissions:
+		var params checkPermissionsParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal check permissions params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to check the permissions of the packages (repair: %t)", request.ID, params.Repair)
+		return d.checkPermissions(ctx, params.Repair)
+	case methodDiagnose:
+		var params diagnoseParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal diagnose params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to diagnose the docker daemon configuration (uninstrument: %t)", request.ID, params.Uninstrument)
+		return d.diagnose(ctx, params.Uninstrument)
+	case methodSetIntegrationConfigs:
+		var params setIntegrationConfigsParams
+		err = json.Unmarshal(request.Params, &params)
+		if err != nil {
+			return fmt.Errorf("could not unmarshal set integration configs params: %w", err)
+		}
+		log.Infof("Installer: Received remote request %s to set the integration configurations to version %s", request.ID, params.Version)
+		return d.setIntegrationConfigs(ctx, params)
+	case methodRollbackIntegrationConfigs:
+		log.Infof("Installer: Received remote request %s to rollback the integration configurations", request.ID)
+		return d.rollbackIntegrationConfigs(ctx)
+	default:
+		return fmt.Errorf("unknown method: %s", request.Method)
+	}
+}
+
+// withDeltaBase returns a context updating the package as a delta from its stable version, when delta
+// updates are enabled and the stable version is known in the catalog
+func (d *daemonImpl) withDeltaBase(ctx context.Context, pkg string, stable string) context.Context {
+	if !d.env.DeltaUpdates || stable == "" {
+		return ctx
+	}
+	base, ok := d.getCatalogPackage(pkg, stable, runtime.GOARCH, runtime.GOOS)
+	if !ok {
+		log.Infof("Installer: Stable version %s of package %s isn't in the catalog, the experiment is fully downloaded", stable, pkg)
+		return ctx
+	}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag("delta_base", base.Version)
+	}
+	// the installed version was downloaded from the rewritten URL
+	baseURL, err := d.rewriteURL(ctx, base.URL)
+	if err != nil {
+		log.Warnf("Installer: Stable version %s of package %s can't be used as a delta base, the experiment is fully downloaded: %v", stable, pkg, err)
+		return ctx
+	}
+	return installer.WithDeltaBase(ctx, baseURL)
+}
+
+// rollbackUnhealthyExperiment stops the experiment of a package that failed its health checks, and
+// reports the request that started it as failed with the reason of the rollback. Experiments that were
+// promoted, stopped or replaced since aren't stopped.
+func (d *daemonImpl) rollbackUnhealthyExperiment(checkCtx context.Context, pkg string, check pendingHealthCheck, healthErr error) {
+	unlock := d.packages.lock(pkg)
+	defer unlock()
+	if checkCtx.Err() != nil || !d.healthChecks.current(pkg, check) {
+		return
+	}
+	defer d.healthChecks.done(pkg, check)
+	s, err := d.installer.State(pkg)
+	if err != nil {
+		log.Errorf("Daemon: could not get the state of package %s to roll back its unhealthy experiment: %v", pkg, err)
+		return
+	}
+	if s.Experiment != check.Version {
+		log.Infof("Daemon: Experiment of package %s version %s is no longer running, ignoring its health check", pkg, check.Version)
+		return
+	}
+
+	log.Errorf("Daemon: Experiment for package %s is unhealthy, rolling it back: %v", pkg, healthErr)
+	span, ctx := tracer.StartSpanFromContext(context.WithValue(context.Background(), requestStateKey, &requestState{
+		Package: pkg,
+		ID:      check.RequestID,
+		State:   pbgo.TaskState_RUNNING,
+	}), "rollback_unhealthy_experiment")
+	span.SetTag("package", pkg)
+	ctx = withAuditRequester(ctx, requesterDaemon)
+	rollbackErr := installerErrors.Wrap(
+		installerErrors.ErrExperimentUnhealthy,
+		fmt.Errorf("experiment rolled back automatically: %w", healthErr),
+	)
+	if err := d.stopExperiment(ctx, pkg); err != nil {
+		rollbackErr = installerErrors.Wrap(
+			installerErrors.ErrExperimentUnhealthy,
+			fmt.Errorf("experiment is unhealthy (%w) and could not be rolled back: %w", healthErr, err),
+		)
+	}
+	span.Finish(tracer.WithError(rollbackErr))
+	setRequestDone(ctx, rollbackErr)
+	d.refreshState(ctx)
+}
+
+// requestPackage returns the package the request applies to, empty if it applies to all of them
+// like the rotation of the API key or the switch of channel.
+func requestPackage(request remoteAPIRequest) string {
+	if request.Method == methodRotateAPIKey || request.Method == methodSetChannel || request.Method == methodCheckPermissions {
+		return ""
+	}
+	return request.Package
+}
+
+// packageBlocked returns whether the request targets a package the host owner blocked from remote
+// management. Flares and the rotation of the API key don't manage a package and are never blocked.
+func (d *daemonImpl) packageBlocked(request remoteAPIRequest) bool {
+	if request.Method == methodFlare {
+		return false
+	}
+	pkg := requestPackage(request)
+	return pkg != "" && slices.Contains(d.env.BlockedPackages, pkg)
+}
+
+// checkPinnedRequest returns an error if the request would move a package away from the version it's
+// pinned to on the host, or remove it. The version a request moves the package to is the one it starts
+// as an experiment, the experiment it promotes, the stable it stops the experiment for or the previous
+// stable it rolls back to.
+func (d *daemonImpl) checkPinnedRequest(request remoteAPIRequest, state repository.State) error {
+	var version string
+	switch request.Method {
+	case methodStartExperiment:
+		var params taskWithVersionParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			// invalid params are reported when the request is executed
+			return nil
+		}
+		version = params.Version
+	case methodPromoteExperiment:
+		version = state.Experiment
+	case methodStopExperiment:
+		version = state.Stable
+	case methodRollback:
+		version = state.Previous
+	case methodUninstall:
+		if pin, ok := d.env.PinnedPackages[request.Package]; ok {
+			return installerErrors.Wrap(
+				installerErrors.ErrPackagePinned,
+				fmt.Errorf("package %s is pinned to version %s on this host, refusing to uninstall it", request.Package, pin),
+			)
+		}
+		return nil
+	default:
+		return nil
+	}
+	if version == "" {
+		// there is nothing to move the package to, the request fails when it's executed
+		return nil
+	}
+	return d.checkPinnedVersion(request.Package, version)
+}
+
+// checkChannelRequest returns an error if the request starts an experiment with a catalog package of a
+// channel the host isn't subscribed to.
+func (d *daemonImpl) checkChannelRequest(request remoteAPIRequest) error {
+	if request.Method != methodStartExperiment {
+		return nil
+	}
+	var params taskWithVersionParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		// invalid params are reported when the request is executed
+		return nil
+	}
+	pkg, ok := d.findCatalogPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+	channel := d.GetChannel()
+	if !ok || pkg.availableIn(channel) {
+		return nil
+	}
+	return installerErrors.Wrap(
+		installerErrors.ErrPackageChannel,
+		fmt.Errorf("version %s of package %s is in the %s channel, this host is subscribed to the %s channel", params.Version, request.Package, pkg.Channel, channel),
+	)
+}
+
+// checkPinnedVersion returns an error if the package is pinned to another version on the host. A pin
+// matches all the releases of the version, e.g. 7.55.1 matches 7.55.1-1.
+func (d *daemonImpl) checkPinnedVersion(pkg string, version string) error {
+	pin, ok := d.env.PinnedPackages[pkg]
+	if !ok || version == pin || strings.HasPrefix(version, pin+"-") {
+		return nil
+	}
+	return installerErrors.Wrap(
+		installerErrors.ErrPackagePinned,
+		fmt.Errorf("package %s is pinned to version %s on this host, refusing version %s", pkg, pin, version),
+	)
+}
+
+// checkDowngradeRequest returns an error if the request would install a version of the package lower
+// than the highest one installed on the host, unless its params explicitly allow it. Allowed downgrades
+// are audited.
+func (d *daemonImpl) checkDowngradeRequest(ctx context.Context, request remoteAPIRequest) error {
+	if request.Method != methodStartExperiment {
+		return nil
+	}
+	var params taskWithVersionParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		// invalid params are reported when the request is executed
+		return nil
+	}
+	highest, downgrade := d.versions.downgrade(request.Package, params.Version)
+	if !downgrade {
+		return nil
+	}
+	if !params.AllowDowngrade {
+		return installerErrors.Wrap(
+			installerErrors.ErrPackageDowngrade,
+			fmt.Errorf("version %s of package %s is lower than version %s installed on this host, the downgrade must be explicitly allowed", params.Version, request.Package, highest),
+		)
+	}
+	log.Warnf("Installer: Remote request %s downgrades package %s from version %s to %s as explicitly allowed", request.ID, request.Package, highest, params.Version)
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag("downgrade_from", highest)
+	}
+	d.reportDowngrade(request, highest, params.Version)
+	return nil
+}
+
+// targetedByRollout returns whether the host is targeted by the rollout of the package of an experiment
+// request. Requests for other methods or for packages missing from the catalog are left to be handled.
+func (d *daemonImpl) targetedByRollout(request remoteAPIRequest) bool {
+	if request.Method != methodStartExperiment {
+		return true
+	}
+	var params taskWithVersionParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return true
+	}
+	pkg, ok := d.getCatalogPackage(request.Package, params.Version, runtime.GOARCH, runtime.GOOS)
+	if !ok {
+		return true
+	}
+	return pkg.Rollout.includes(request.Package, d.rolloutHost)
+}
+
+// scheduleReboot schedules a reboot of the host at a random time of the maintenance window so
+// that the hosts of a fleet don't all reboot at once. A reboot scheduled earlier is replaced, and
+// nothing is scheduled if the package doesn't require a reboot.
+func (d *daemonImpl) scheduleReboot(ctx context.Context, pkg string, params rebootParams) (err error) {
+	span, _ := tracer.StartSpanFromContext(ctx, "schedule_reboot")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	s, err := d.installer.State(pkg)
+	if err != nil {
+		return fmt.Errorf("could not get installer state: %w", err)
+	}
+	if s.RebootRequired == nil {
+		log.Infof("Daemon: Package %s doesn't require a reboot, skipping", pkg)
+		return nil
+	}
+	delay, err := rebootDelay(time.Now(), params.WindowStart, params.WindowEnd)
+	if err != nil {
+		return fmt.Errorf("could not schedule reboot: %w", err)
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.rebootTimer != nil {
+		d.rebootTimer.Stop()
+	}
+	span.SetTag("delay", delay.String())
+	log.Infof("Daemon: Scheduling a reboot of the host in %s for package %s: %s", delay, pkg, s.RebootRequired.Reason)
+	d.rebootTimer = time.AfterFunc(delay, func() {
+		err := d.reboot(pkg)
+		if err != nil {
+			log.Errorf("Daemon: could not reboot the host: %v", err)
+		}
+	})
+	return nil
+}
+
+// rebootDelay returns the delay until a random time between now, or the start of the window if it
+// hasn't started yet, and the end of the window.
+func rebootDelay(now time.Time, windowStart time.Time, windowEnd time.Time) (time.Duration, error) {
+	if !windowEnd.After(windowStart) {
+		return 0, fmt.Errorf("invalid maintenance window from %s to %s", windowStart, windowEnd)
+	}
+	if !now.Before(windowEnd) {
+		return 0, fmt.Errorf("maintenance window ended at %s", windowEnd)
+	}
+	if now.After(windowStart) {
+		windowStart = now
+	}
+	return windowStart.Sub(now) + time.Duration(rand.Int63n(int64(windowEnd.Sub(windowStart)))), nil
+}
+
+func (d *daemonImpl) reboot(pkg string) (err error) {
+	// the host isn't rebooted while packages are being updated
+	unlock := d.packages.lockAll()
+	defer unlock()
+	select {
+	case <-d.stopChan:
+		// the timer fired while the daemon was stopping
+		return nil
+	default:
+	}
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "reboot")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("package", pkg)
+
+	// The package may have been removed or the host rebooted by other means since the reboot was scheduled
+	s, err := d.installer.State(pkg)
+	if err != nil {
+		return fmt.Errorf("could not get installer state: %w", err)
+	}
+	if s.RebootRequired == nil {
+		log.Infof("Daemon: Package %s doesn't require a reboot anymore, skipping", pkg)
+		return nil
+	}
+	log.Infof("Daemon: Rebooting the host for package %s", pkg)
+	return d.installer.Reboot(ctx)
+}
+
+// remoteRequestDelay returns a random delay bounded by the configured jitter. Each host applies it
+// before executing a remote request so that the hosts targeted by a fleet-wide request don't
+// download packages and restart services all at the same time.
+func (d *daemonImpl) remoteRequestDelay() time.Duration {
+	if d.env.RemoteRequestJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d.env.RemoteRequestJitter)))
+}
+
+type requestKey int
+
+var requestStateKey requestKey
+
+// requestState represents the state of a task.
+type requestState struct {
+	Package  string
+	ID       string
+	Priority int
+	State    pbgo.TaskState
+	Err      *installerErrors.InstallerError
+	Delay    time.Duration
+	// DryRunReport is the JSON report of a dry run or of a diagnosis, reported with the task once it's done
+	DryRunReport string
+}
+
+func (r *requestState) toTask() *pbgo.PackageStateTask {
+	var taskErr *pbgo.TaskError
+	if r.Err != nil {
+		taskErr = &pbgo.TaskError{
+			Code:    uint64(r.Err.Code()),
+			Message: r.Err.Error(),
+		}
+	}
+	return &pbgo.PackageStateTask{
+		Id:           r.ID,
+		State:        r.State,
+		Error:        taskErr,
+		DryRunReport: r.DryRunReport,
+	}
+}
+
+func newRequestContext(request remoteAPIRequest) (ddtrace.Span, context.Context) {
+	ctx := newRequestStateContext(request)
+
+	ctxCarrier := tracer.TextMapCarrier{
+		tracer.DefaultTraceIDHeader:  request.TraceID,
+		tracer.DefaultParentIDHeader: request.ParentSpanID,
+		tracer.DefaultPriorityHeader: "2",
+	}
+	spanCtx, err := tracer.Extract(ctxCarrier)
+	if err != nil {
+		log.Debugf("failed to extract span context from install script params: %v", err)
+		return tracer.StartSpan("remote_request"), ctx
+	}
+
+	return tracer.StartSpanFromContext(ctx, "remote_request", tracer.ChildOf(spanCtx))
+}
+
+// newRequestStateContext returns a context holding the state of the request, reported with its task
+func newRequestStateContext(request remoteAPIRequest) context.Context {
+	return context.WithValue(context.Background(), requestStateKey, &requestState{
+		Package:  request.Package,
+		ID:       request.ID,
+		Priority: request.Priority,
+		State:    pbgo.TaskState_RUNNING,
+	})
+}
+
+// setRequestInvalid marks the request as not executed, reason is reported with the task if not nil
+func setRequestInvalid(ctx context.Context, reason error) {
+	state := ctx.Value(requestStateKey).(*requestState)
+	state.State = pbgo.TaskState_INVALID_STATE
+	if reason != nil {
+		state.Err = installerErrors.From(reason)
+	}
+}
+
+// setRequestPending marks the request as waiting for the next maintenance window, opening after the given delay
+func setRequestPending(ctx context.Context, delay time.Duration) {
+	state := ctx.Value(requestStateKey).(*requestState)
+	state.State = pbgo.TaskState_PENDING
+	state.Delay = delay
+}
+
+func setRequestDelay(ctx context.Context, delay time.Duration) {
+	state := ctx.Value(requestStateKey).(*requestState)
+	state.Delay = delay
+}
+
+// setRequestReport sets the report of a dry run or of a diagnosis, reported with the task
+func setRequestReport(ctx context.Context, report any) error {
+	rawReport, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("could not marshal report: %w", err)
+	}
+	state := ctx.Value(requestStateKey).(*requestState)
+	state.DryRunReport = string(rawReport)
+	return nil
+}
+
+func setRequestDone(ctx context.Context, err error) {
+	state := ctx.Value(requestStateKey).(*requestState)
+	state.State = pbgo.TaskState_DONE
+	if err != nil {
+		state.State = pbgo.TaskState_ERROR
+		state.Err = installerErrors.From(err)
+	}
+}
+
+func (d *daemonImpl) refreshState(ctx context.Context) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	state, err := d.installer.States()
+	if err != nil {
+		// TODO: we should report this error through RC in some way
 		log.Errorf("could not get installer state: %v", err)
 		return
 	}
 	requestState, ok := ctx.Value(requestStateKey).(*requestState)
+	d.publishState(state, requestState)
+	if ok && requestState.Package != "" {
+		d.runningTasks[requestState.Package] = *requestState
+	}
 	var packages []*pbgo.PackageState
 	for pkg, s := range state {
+		d.versions.record(pkg, s.Stable)
 		p := &pbgo.PackageState{
 			Package:           pkg,
 			StableVersion:     s.Stable,
 			ExperimentVersion: s.Experiment,
 		}
-		if ok && pkg == requestState.Package {
-			var taskErr *pbgo.TaskError
-			if requestState.Err != nil {
-				taskErr = &pbgo.TaskError{
-					Code:    uint64(requestState.Err.Code()),
-					Message: requestState.Err.Error(),
-				}
-			}
-			p.Task = &pbgo.PackageStateTask{
-				Id:    requestState.ID,
-				State: requestState.State,
-				Error: taskErr,
-			}
+		if task, ok := d.runningTasks[pkg]; ok {
+			p.Task = task.toTask()
 		}
 		packages = append(packages, p)
 	}
-	d.rc.SetState(packages)
+	// a package removed by a request is reported without versions so that the removal is acknowledged
+	for pkg, task := range d.runningTasks {
+		if _, found := state[pkg]; !found {
+			packages = append(packages, &pbgo.PackageState{
+				Package: pkg,
+				Task:    task.toTask(),
+			})
+		}
+	}
+	d.rc.SetState(d.stateReporter.report(packages, time.Now()))
+}
+
+// untrackRequest stops reporting the task of the request once it's handled
+func (d *daemonImpl) untrackRequest(ctx context.Context) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	requestState := ctx.Value(requestStateKey).(*requestState)
+	if task, ok := d.runningTasks[requestState.Package]; ok && task.ID == requestState.ID {
+		delete(d.runningTasks, requestState.Package)
+	}
+}
+
+// GetDaemonStatus returns the state of the daemon shown by the agent status.
+func (d *daemonImpl) GetDaemonStatus() DaemonStatus {
+	d.m.Lock()
+	status := DaemonStatus{
+		StartTime:        d.startTime,
+		LastGC:           d.lastGC,
+		CatalogUpdatedAt: d.catalogUpdatedAt,
+		CatalogPackages:  len(d.catalog.Packages),
+	}
+	d.m.Unlock()
+	status.LastRemoteRequest = d.tasks.lastDone()
+	return status
+}
+
+// health returns the health of the daemon reported in the heartbeats, so that the backend can tell
+// a host whose daemon died from a host without any change.
+func (d *daemonImpl) health() client.UpdaterHealth {
+	return client.UpdaterHealth{
+		Heartbeat:  time.Now(),
+		Uptime:     time.Since(d.startTime),
+		LastGC:     d.lastGC,
+		QueueDepth: d.requests.len(),
+	}
+}
+
+func (d *daemonImpl) publishState(state map[string]repository.State, request *requestState) {
+	event := StateEvent{
+		Packages: state,
+	}
+	if request != nil {
+		event.Task = &TaskState{
+			ID:       request.ID,
+			Package:  request.Package,
+			Priority: request.Priority,
+			State:    request.State.String(),
+		}
+		if request.Err != nil {
+			event.Task.Error = request.Err.Error()
+		}
+		if request.Delay > 0 {
+			event.Task.Delay = request.Delay.String()
+		}
+		event.Task.DryRunReport = request.DryRunReport
+		d.tasks.add(*event.Task)
+	}
+	d.subscribers.publish(event)
 }
@@ -11,15 +11,24 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/config/remote/client"
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
 	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
 	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
 	"github.com/DataDog/datadog-agent/pkg/version"
@@ -49,6 +58,11 @@ func (m *testPackageManager) Install(ctx context.Context, url string, installArg
 	return args.Error(0)
 }
 
+func (m *testPackageManager) InstallDryRun(ctx context.Context, url string) (*installer.DryRunReport, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(*installer.DryRunReport), args.Error(1)
+}
+
 func (m *testPackageManager) Remove(ctx context.Context, pkg string) error {
 	args := m.Called(ctx, pkg)
 	return args.Error(0)
@@ -63,6 +77,11 @@ func (m *testPackageManager) InstallExperiment(ctx context.Context, url string)
 	return args.Error(0)
 }
 
+func (m *testPackageManager) InstallExperimentDryRun(ctx context.Context, url string) (*installer.DryRunReport, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(*installer.DryRunReport), args.Error(1)
+}
+
 func (m *testPackageManager) RemoveExperiment(ctx context.Context, pkg string) error {
 	args := m.Called(ctx, pkg)
 	return args.Error(0)
@@ -73,6 +92,11 @@ func (m *testPackageManager) PromoteExperiment(ctx context.Context, pkg string)
 	return args.Error(0)
 }
 
+func (m *testPackageManager) Rollback(ctx context.Context, pkg string) error {
+	args := m.Called(ctx, pkg)
+	return args.Error(0)
+}
+
 func (m *testPackageManager) GarbageCollect(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -83,13 +107,61 @@ func (m *testPackageManager) InstrumentAPMInjector(ctx context.Context, method s
 	return args.Error(0)
 }
 
+func (m *testPackageManager) Export(ctx context.Context, pkg string, version string, path string) error {
+	args := m.Called(ctx, pkg, version, path)
+	return args.Error(0)
+}
+
+func (m *testPackageManager) ImportArchive(ctx context.Context, path string, installArgs []string) error {
+	args := m.Called(ctx, path, installArgs)
+	return args.Error(0)
+}
+
 func (m *testPackageManager) UninstrumentAPMInjector(ctx context.Context, method string) error {
 	args := m.Called(ctx, method)
 	return args.Error(0)
 }
 
+func (m *testPackageManager) DiffAPMInjectorDocker(ctx context.Context, uninstrument bool) (service.FileDiff, error) {
+	args := m.Called(ctx, uninstrument)
+	return args.Get(0).(service.FileDiff), args.Error(1)
+}
+
+func (m *testPackageManager) RotateAPIKey(ctx context.Context, apiKey string) error {
+	args := m.Called(ctx, apiKey)
+	return args.Error(0)
+}
+
+func (m *testPackageManager) SetIntegrationConfigs(ctx context.Context, version string, files map[string][]byte) error {
+	args := m.Called(ctx, version, files)
+	return args.Error(0)
+}
+
+func (m *testPackageManager) RollbackIntegrationConfigs(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *testPackageManager) Reboot(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *testPackageManager) CheckPermissions(ctx context.Context) ([]repository.PermissionIssue, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.PermissionIssue), args.Error(1)
+}
+
+func (m *testPackageManager) RepairPermissions(ctx context.Context) ([]repository.PermissionIssue, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.PermissionIssue), args.Error(1)
+}
+
 type testRemoteConfigClient struct {
-	listeners map[string][]client.Handler
+	listeners     map[string][]client.Handler
+	packagesState []*pbgo.PackageState
+	tags          []string
+	health        client.UpdaterHealth
 }
 
 func newTestRemoteConfigClient() *testRemoteConfigClient {
@@ -108,7 +180,16 @@ func (c *testRemoteConfigClient) Subscribe(product string, fn func(update map[st
 	c.listeners[product] = append(c.listeners[product], client.Handler(fn))
 }
 
-func (c *testRemoteConfigClient) SetUpdaterPackagesState(_ []*pbgo.PackageState) {
+func (c *testRemoteConfigClient) SetUpdaterPackagesState(packages []*pbgo.PackageState) {
+	c.packagesState = packages
+}
+
+func (c *testRemoteConfigClient) SetUpdaterTags(tags []string) {
+	c.tags = tags
+}
+
+func (c *testRemoteConfigClient) SetUpdaterHealth(health client.UpdaterHealth) {
+	c.health = health
 }
 
 func (c *testRemoteConfigClient) SubmitCatalog(catalog catalog) {
@@ -146,12 +227,16 @@ type testInstaller struct {
 }
 
 func newTestInstaller() *testInstaller {
+	return newTestInstallerWithEnv(&env.Env{RemoteUpdates: true})
+}
+
+func newTestInstallerWithEnv(env *env.Env) *testInstaller {
 	pm := &testPackageManager{}
 	pm.On("States").Return(map[string]repository.State{}, nil)
 	rcc := newTestRemoteConfigClient()
 	rc := &remoteConfig{client: rcc}
 	i := &testInstaller{
-		daemonImpl: newDaemon(rc, pm, &env.Env{RemoteUpdates: true}),
+		daemonImpl: newDaemon(rc, pm, env),
 		rcc:        rcc,
 		pm:         pm,
 	}
@@ -223,6 +308,611 @@ func TestPromoteExperiment(t *testing.T) {
 	i.pm.AssertExpectations(t)
 }
 
+func TestRemoteRollback(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRollback,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.56.0"},
+	}
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.56.0", Previous: "7.55.0"}, nil).Once()
+	i.pm.On("Rollback", mock.Anything, testStablePackage).Return(nil).Once()
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteUninstall(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodUninstall,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.56.0"},
+	}
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.56.0"}, nil).Once()
+	i.pm.On("Remove", mock.Anything, testStablePackage).Return(nil).Once()
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	// The package is gone from the states of the installer, its removal is reported with the task
+	require.Len(t, i.rcc.packagesState, 1)
+	assert.Equal(t, testStablePackage, i.rcc.packagesState[0].Package)
+	assert.Empty(t, i.rcc.packagesState[0].StableVersion)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, "test-request-1", i.rcc.packagesState[0].Task.Id)
+	assert.Equal(t, pbgo.TaskState_DONE, i.rcc.packagesState[0].Task.State)
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestPriority(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	// The first request blocks the daemon while the others are queued
+	running := make(chan struct{})
+	release := make(chan struct{})
+	var executed []string
+	i.pm.On("State", mock.Anything).Return(repository.State{}, nil)
+	i.pm.On("PromoteExperiment", mock.Anything, "blocking").Return(nil).Run(func(mock.Arguments) {
+		close(running)
+		<-release
+	}).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		executed = append(executed, args.String(1))
+	})
+
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-1", Method: methodPromoteExperiment, Package: "blocking"})
+	<-running
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-2", Method: methodPromoteExperiment, Package: "routine"})
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-3", Method: methodPromoteExperiment, Package: "security-patch", Priority: 100})
+	close(release)
+	i.requestsWG.Wait()
+
+	// The security patch jumps the queue
+	assert.Equal(t, []string{"security-patch", "routine"}, executed)
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestConcurrentPackages(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, RemoteRequestWorkers: 2})
+	defer i.Stop()
+
+	// The agent experiment blocks while requests for the agent and the APM injector are queued
+	running := make(chan struct{})
+	release := make(chan struct{})
+	injectorPromoted := make(chan struct{})
+	var agentPromotions atomic.Int32
+	i.pm.On("State", mock.Anything).Return(repository.State{}, nil)
+	i.pm.On("PromoteExperiment", mock.Anything, "datadog-agent").Return(nil).Run(func(mock.Arguments) {
+		if agentPromotions.Add(1) == 1 {
+			close(running)
+			<-release
+		}
+	}).Twice()
+	i.pm.On("PromoteExperiment", mock.Anything, "datadog-apm-inject").Return(nil).Run(func(mock.Arguments) {
+		close(injectorPromoted)
+	}).Once()
+
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-1", Method: methodPromoteExperiment, Package: "datadog-agent"})
+	<-running
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-2", Method: methodPromoteExperiment, Package: "datadog-agent", Priority: 100})
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-3", Method: methodPromoteExperiment, Package: "datadog-apm-inject"})
+
+	// The APM injector isn't blocked by the agent experiment, the second agent request is
+	select {
+	case <-injectorPromoted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the APM injector request was blocked by the agent request")
+	}
+	assert.EqualValues(t, 1, agentPromotions.Load())
+	close(release)
+	i.requestsWG.Wait()
+
+	assert.EqualValues(t, 2, agentPromotions.Load())
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteUninstallInstaller(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodUninstall,
+		Package:       "datadog-installer",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.56.0"},
+	}
+	i.pm.On("State", "datadog-installer").Return(repository.State{Stable: "7.56.0"}, nil).Once()
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, pbgo.TaskState_ERROR, i.rcc.packagesState[0].Task.State)
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRotateAPIKey(t *testing.T) {
+	stubAPIKeyValidation(t)
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, APIKey: "oldkey"})
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	paramsJSON := rotateAPIKeyParamsJSON(t, "newkey", "oldkey")
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRotateAPIKey,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	}
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil).Once()
+	i.pm.On("RotateAPIKey", mock.Anything, "newkey").Return(nil).Once()
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	assert.Equal(t, "newkey", i.env.APIKey)
+}
+
+func TestRemoteSetIntegrationConfigs(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	paramsJSON, _ := json.Marshal(setIntegrationConfigsParams{
+		Version: "v2",
+		Files:   map[string]string{"nginx.d/conf.yaml": "instances: [{}]"},
+	})
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil)
+	i.pm.On("SetIntegrationConfigs", mock.Anything, "v2", map[string][]byte{"nginx.d/conf.yaml": []byte("instances: [{}]")}).Return(nil).Once()
+	i.pm.On("RollbackIntegrationConfigs", mock.Anything).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodSetIntegrationConfigs,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodRollbackIntegrationConfigs,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, pbgo.TaskState_DONE, i.rcc.packagesState[0].Task.State)
+}
+
+func TestRemoteCheckPermissions(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	paramsJSON, _ := json.Marshal(checkPermissionsParams{})
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil)
+	i.pm.On("CheckPermissions", mock.Anything).Return([]repository.PermissionIssue{
+		{Path: "/opt/datadog-packages/datadog-agent/7.55.0", Reason: "owned by 0:0 instead of 998:998"},
+	}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodCheckPermissions,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrInvalidPermissions), task.Error.Code)
+	assert.Contains(t, task.Error.Message, "/opt/datadog-packages/datadog-agent/7.55.0 owned by 0:0 instead of 998:998")
+
+	// the repaired issues don't fail the task
+	paramsJSON, _ = json.Marshal(checkPermissionsParams{Repair: true})
+	i.pm.On("RepairPermissions", mock.Anything).Return([]repository.PermissionIssue{
+		{Path: "/opt/datadog-packages/datadog-agent/7.55.0", Reason: "owned by 0:0 instead of 998:998", Repaired: true},
+	}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodCheckPermissions,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	require.Len(t, i.rcc.packagesState, 1)
+	task = i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+}
+
+func TestRemoteDiagnose(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-apm-inject"
+	paramsJSON, _ := json.Marshal(diagnoseParams{})
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "0.20.0"}, nil)
+	i.pm.On("DiffAPMInjectorDocker", mock.Anything, false).Return(service.FileDiff{
+		Path:   "/etc/docker/daemon.json",
+		Before: "{}",
+		After:  `{"default-runtime":"dd-shim"}`,
+	}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodDiagnose,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.20.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "InstrumentAPMInjector", mock.Anything, mock.Anything)
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	var diff service.FileDiff
+	require.NoError(t, json.Unmarshal([]byte(task.DryRunReport), &diff))
+	assert.Equal(t, "/etc/docker/daemon.json", diff.Path)
+	assert.True(t, diff.HasChanges())
+}
+
+func TestRemoteRebootNotRequired(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	now := time.Now()
+	paramsJSON, _ := json.Marshal(rebootParams{WindowStart: now, WindowEnd: now.Add(time.Millisecond)})
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodReboot,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	}
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil)
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	i.m.Lock()
+	assert.Nil(t, i.rebootTimer)
+	i.m.Unlock()
+	i.pm.AssertNotCalled(t, "Reboot", mock.Anything)
+}
+
+func TestRemoteReboot(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := "datadog-agent"
+	now := time.Now()
+	paramsJSON, _ := json.Marshal(rebootParams{WindowStart: now.Add(-time.Hour), WindowEnd: now.Add(10 * time.Millisecond)})
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodReboot,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	}
+	rebooted := make(chan struct{})
+	i.pm.On("State", testStablePackage).Return(repository.State{
+		Stable:         "7.55.0",
+		RebootRequired: &repository.RebootRequired{Version: "7.55.0", Reason: "kernel module update"},
+	}, nil)
+	i.pm.On("Reboot", mock.Anything).Return(nil).Once().Run(func(mock.Arguments) { close(rebooted) })
+	i.rcc.SubmitRequest(testRequest)
+	i.requestsWG.Wait()
+
+	select {
+	case <-rebooted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("host was not rebooted within the maintenance window")
+	}
+	i.pm.AssertExpectations(t)
+}
+
+func TestRebootDelay(t *testing.T) {
+	now := time.Now()
+
+	delay, err := rebootDelay(now, now.Add(time.Hour), now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, delay, time.Hour)
+	assert.Less(t, delay, 2*time.Hour)
+
+	delay, err = rebootDelay(now, now.Add(-time.Hour), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, delay, time.Duration(0))
+	assert.Less(t, delay, time.Hour)
+
+	_, err = rebootDelay(now, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	assert.Error(t, err)
+
+	_, err = rebootDelay(now, now.Add(time.Hour), now.Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestSubscribe(t *testing.T) {
+	stubAPIKeyValidation(t)
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, APIKey: "oldkey"})
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	testURL := "oci://example.com/test-package:1.0.0"
+	i.pm.On("Install", mock.Anything, testURL, []string(nil)).Return(nil).Once()
+	err := i.Install(context.Background(), testURL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, StateEvent{Packages: map[string]repository.State{}}, <-events)
+
+	testStablePackage := "datadog-agent"
+	paramsJSON := rotateAPIKeyParamsJSON(t, "newkey", "oldkey")
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil).Once()
+	i.pm.On("RotateAPIKey", mock.Anything, "newkey").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRotateAPIKey,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+	assert.Equal(t, &TaskState{ID: "test-request-1", Package: testStablePackage, State: "RUNNING"}, (<-events).Task)
+	assert.Equal(t, &TaskState{ID: "test-request-1", Package: testStablePackage, State: "DONE"}, (<-events).Task)
+
+	// Subscriptions are closed when the daemon stops
+	i.Stop()
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestRemoteRequestJitter(t *testing.T) {
+	jitter := 50 * time.Millisecond
+	stubAPIKeyValidation(t)
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, APIKey: "oldkey", RemoteRequestJitter: jitter})
+	defer i.Stop()
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	testStablePackage := "datadog-agent"
+	paramsJSON := rotateAPIKeyParamsJSON(t, "newkey", "oldkey")
+	i.pm.On("State", testStablePackage).Return(repository.State{Stable: "7.55.0"}, nil).Once()
+	i.pm.On("RotateAPIKey", mock.Anything, "newkey").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRotateAPIKey,
+		Package:       testStablePackage,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	var tasks []*TaskState
+	for len(events) > 0 {
+		tasks = append(tasks, (<-events).Task)
+	}
+	require.NotEmpty(t, tasks)
+	last := tasks[len(tasks)-1]
+	assert.Equal(t, "DONE", last.State)
+	// the delay may be 0, in which case it isn't reported
+	if last.Delay != "" {
+		delay, err := time.ParseDuration(last.Delay)
+		require.NoError(t, err)
+		assert.Less(t, delay, jitter)
+		assert.Equal(t, &TaskState{ID: "test-request-1", Package: testStablePackage, State: "RUNNING", Delay: last.Delay}, tasks[0])
+	}
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestJitterStop(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, RemoteRequestJitter: time.Hour})
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:      "test-request-1",
+		Method:  methodPromoteExperiment,
+		Package: "datadog-agent",
+	})
+	// The request is reported as delayed before waiting
+	task := (<-events).Task
+	require.NotNil(t, task)
+	assert.NotEmpty(t, task.Delay)
+
+	// Stopping the daemon doesn't wait for the delayed request, which is never executed
+	stopped := make(chan struct{})
+	go func() {
+		i.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon didn't stop")
+	}
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestMaintenanceWindow(t *testing.T) {
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s UTC", now.Add(2*time.Hour).Format("15:04"), now.Add(3*time.Hour).Format("15:04"))
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, MaintenanceWindows: []string{window}})
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:      "test-request-1",
+		Method:  methodPromoteExperiment,
+		Package: "datadog-agent",
+	})
+	// The request is reported as pending until the window opens
+	task := (<-events).Task
+	require.NotNil(t, task)
+	assert.Equal(t, "PENDING", task.State)
+	delay, err := time.ParseDuration(task.Delay)
+	require.NoError(t, err)
+	assert.InDelta(t, 2*time.Hour, delay, float64(time.Minute))
+
+	// Stopping the daemon doesn't wait for the pending request, which is never executed
+	stopped := make(chan struct{})
+	go func() {
+		i.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon didn't stop")
+	}
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestMaintenanceWindowFreesWorker(t *testing.T) {
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s UTC", now.Add(2*time.Hour).Format("15:04"), now.Add(3*time.Hour).Format("15:04"))
+	i := newTestInstallerWithEnv(&env.Env{
+		RemoteUpdates:        true,
+		RemoteRequestWorkers: 1,
+		MaintenanceWindows:   []string{window},
+		BlockedPackages:      []string{"datadog-apm-inject"},
+	})
+	defer i.Stop()
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-1", Method: methodPromoteExperiment, Package: "datadog-agent"})
+	task := (<-events).Task
+	require.NotNil(t, task)
+	assert.Equal(t, "PENDING", task.State)
+
+	// the pending request doesn't hold the only worker, which handles the next request
+	i.rcc.SubmitRequest(remoteAPIRequest{ID: "test-request-2", Method: methodPromoteExperiment, Package: "datadog-apm-inject"})
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Task != nil && event.Task.ID == "test-request-2" && event.Task.State == "ERROR" {
+				i.pm.AssertExpectations(t)
+				return
+			}
+		case <-timeout:
+			t.Fatal("the worker was held by the pending request")
+		}
+	}
+}
+
+func TestRemoteRequestMaintenanceWindowClockJump(t *testing.T) {
+	// the wall clock jumps forward while the request waits for the window, e.g. on a NTP sync
+	var offset atomic.Int64
+	previousTimeNow, previousRecheckInterval := timeNow, maintenanceWindowRecheckInterval
+	timeNow = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+	maintenanceWindowRecheckInterval = 10 * time.Millisecond
+	defer func() { timeNow, maintenanceWindowRecheckInterval = previousTimeNow, previousRecheckInterval }()
+
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s UTC", now.Add(2*time.Hour).Format("15:04"), now.Add(3*time.Hour).Format("15:04"))
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, MaintenanceWindows: []string{window}})
+	defer i.Stop()
+	events, unsubscribe := i.Subscribe()
+	defer unsubscribe()
+
+	i.pm.On("State", "datadog-agent").Return(repository.State{}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "datadog-agent").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:      "test-request-1",
+		Method:  methodPromoteExperiment,
+		Package: "datadog-agent",
+	})
+	task := (<-events).Task
+	require.NotNil(t, task)
+	assert.Equal(t, "PENDING", task.State)
+
+	// the request is executed once the window opens in wall clock time, without waiting for the
+	// delay computed before the jump
+	offset.Store(int64(2*time.Hour + 30*time.Minute))
+	done := make(chan struct{})
+	go func() {
+		i.requestsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not executed after the clock jumped into the window")
+	}
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestMaintenanceWindowClockSkew(t *testing.T) {
+	// the wall clock jumps backwards and past the window while the request waits for it, e.g. when
+	// a VM is restored from a snapshot
+	var offset atomic.Int64
+	previousTimeNow, previousRecheckInterval := timeNow, maintenanceWindowRecheckInterval
+	timeNow = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+	maintenanceWindowRecheckInterval = 10 * time.Millisecond
+	defer func() { timeNow, maintenanceWindowRecheckInterval = previousTimeNow, previousRecheckInterval }()
+
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s UTC", now.Add(2*time.Hour).Format("15:04"), now.Add(3*time.Hour).Format("15:04"))
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, MaintenanceWindows: []string{window}})
+	defer i.Stop()
+
+	i.pm.On("State", "datadog-agent").Return(repository.State{}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "datadog-agent").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:      "test-request-1",
+		Method:  methodPromoteExperiment,
+		Package: "datadog-agent",
+	})
+	done := make(chan struct{})
+	go func() {
+		i.requestsWG.Wait()
+		close(done)
+	}()
+
+	// the request keeps waiting, and the window keeps being evaluated, while the clock is before
+	// the window or after its end
+	for _, skew := range []time.Duration{-24 * time.Hour, -7 * 24 * time.Hour, 4 * time.Hour} {
+		offset.Store(int64(skew))
+		select {
+		case <-done:
+			t.Fatalf("request executed outside of the window after the clock jumped by %s", skew)
+		case <-time.After(10 * maintenanceWindowRecheckInterval):
+		}
+		i.pm.AssertNotCalled(t, "PromoteExperiment", mock.Anything, "datadog-agent")
+	}
+
+	// and it's executed once the clock is in the window of the next day
+	offset.Store(int64(24*time.Hour + 2*time.Hour + 30*time.Minute))
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not executed after the clock jumped into the window")
+	}
+	i.pm.AssertExpectations(t)
+}
+
 func TestUpdateCatalog(t *testing.T) {
 	i := newTestInstaller()
 	defer i.Stop()
@@ -246,42 +936,572 @@ func TestUpdateCatalog(t *testing.T) {
 	i.pm.AssertExpectations(t)
 }
 
-func TestRemoteRequest(t *testing.T) {
+func TestGetCatalog(t *testing.T) {
 	i := newTestInstaller()
 	defer i.Stop()
 
-	testStablePackage := Package{
+	testPackage := Package{
 		Name:     "test-package",
-		Version:  "0.0.1",
+		Version:  "1.0.0",
 		URL:      "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
 		Platform: runtime.GOOS,
 		Arch:     runtime.GOARCH,
 	}
+	anyPlatformPackage := Package{
+		Name:    "test-package",
+		Version: "1.1.0",
+		URL:     "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+	}
+	otherArchPackage := testPackage
+	otherArchPackage.Version = "1.2.0"
+	otherArchPackage.Arch = "banana"
+	otherRingPackage := testPackage
+	otherRingPackage.Version = "1.3.0"
+	otherRingPackage.Rollout = &Rollout{Rings: []string{"canary"}}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testPackage, otherArchPackage, anyPlatformPackage, otherRingPackage}})
+
+	// only the packages available for the host and rolled out to it are listed
+	assert.Equal(t, []Package{testPackage, anyPlatformPackage}, i.daemonImpl.GetCatalog())
+}
+
+func TestRemoteRequestRollout(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, RolloutRing: "stable"})
+	defer i.Stop()
+
 	testExperimentPackage := Package{
 		Name:     "test-package",
 		Version:  "1.0.0",
 		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
 		Platform: runtime.GOOS,
 		Arch:     runtime.GOARCH,
+		Rollout:  &Rollout{Rings: []string{"canary"}},
 	}
-	c := catalog{
-		Packages: []Package{testExperimentPackage},
-	}
-	versionParams := taskWithVersionParams{
-		Version: testExperimentPackage.Version,
-	}
-	versionParamsJSON, _ := json.Marshal(versionParams)
-	i.rcc.SubmitCatalog(c)
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
 
-	testRequest := remoteAPIRequest{
+	// The host isn't in the ring targeted by the rollout, the experiment isn't started
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
 		ID:            "test-request-1",
 		Method:        methodStartExperiment,
 		Package:       testExperimentPackage.Name,
-		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: testStablePackage.Version},
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
 		Params:        versionParamsJSON,
-	}
-	i.pm.On("State", testStablePackage.Name).Return(repository.State{Stable: testStablePackage.Version}, nil).Once()
-	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	})
+	i.requestsWG.Wait()
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, pbgo.TaskState_INVALID_STATE, i.rcc.packagesState[0].Task.State)
+	i.pm.AssertExpectations(t)
+
+	// The next stage of the rollout targets the ring of the host
+	testExperimentPackage.Rollout.Rings = append(testExperimentPackage.Rollout.Rings, "stable")
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestSetChannel(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true})
+	defer i.Stop()
+	i.channelPath = filepath.Join(t.TempDir(), channelFile)
+
+	betaPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.56.0-rc.1-1",
+		URL:      "oci://example.com/datadog-agent@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Channel:  channelBeta,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{betaPackage}})
+	assert.Empty(t, i.GetCatalog())
+
+	paramsJSON, _ := json.Marshal(setChannelParams{Channel: channelBeta})
+	i.pm.On("State", "datadog-agent").Return(repository.State{Stable: "7.55.1-1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodSetChannel,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.1-1"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+
+	// the packages of the new channel are available and the channel is reported
+	assert.Equal(t, channelBeta, i.GetChannel())
+	assert.Equal(t, []Package{betaPackage}, i.GetCatalog())
+	i.m.Lock()
+	assert.Contains(t, i.rcc.tags, channelTagPrefix+channelBeta)
+	assert.NotContains(t, i.rcc.tags, channelTagPrefix+channelStable)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, pbgo.TaskState_DONE, i.rcc.packagesState[0].Task.State)
+	i.m.Unlock()
+
+	// an unknown channel fails the request without changing the channel
+	paramsJSON, _ = json.Marshal(setChannelParams{Channel: "alpha"})
+	i.pm.On("State", "datadog-agent").Return(repository.State{Stable: "7.55.1-1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodSetChannel,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.1-1"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+	assert.Equal(t, channelBeta, i.GetChannel())
+	i.m.Lock()
+	assert.Equal(t, pbgo.TaskState_ERROR, i.rcc.packagesState[0].Task.State)
+	i.m.Unlock()
+
+	// the channel is kept when the daemon restarts
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	rcc := newTestRemoteConfigClient()
+	restarted := newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{})
+	restarted.channelPath = i.channelPath
+	restarted.Start(context.Background())
+	defer restarted.Stop(context.Background())
+	assert.Equal(t, channelBeta, restarted.GetChannel())
+	restarted.m.Lock()
+	assert.Contains(t, rcc.tags, channelTagPrefix+channelBeta)
+	restarted.m.Unlock()
+
+	// the channel configured since the switch takes precedence over it
+	reconfigured := newDaemon(&remoteConfig{client: newTestRemoteConfigClient()}, pm, &env.Env{Channel: channelNightly})
+	reconfigured.channelPath = i.channelPath
+	reconfigured.Start(context.Background())
+	defer reconfigured.Stop(context.Background())
+	assert.Equal(t, channelNightly, reconfigured.GetChannel())
+	assert.NoFileExists(t, i.channelPath)
+
+	// nothing is persisted when switching back to the configured channel
+	assert.NoError(t, i.SetChannel(context.Background(), channelBeta))
+	assert.FileExists(t, i.channelPath)
+	assert.NoError(t, i.SetChannel(context.Background(), channelStable))
+	assert.NoFileExists(t, i.channelPath)
+}
+
+func TestRemoteRequestExperimentTTL(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true})
+	defer i.Stop()
+
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+
+	// an invalid TTL fails the request before starting the experiment
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version, TTL: "tomorrow"})
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+	_, ok := i.expiries.get(testExperimentPackage.Name)
+	assert.False(t, ok)
+
+	// the experiment is stopped once neither promoted nor stopped within its TTL
+	versionParamsJSON, _ = json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version, TTL: "50ms"})
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1", Experiment: testExperimentPackage.Version}, nil).Once()
+	i.pm.On("RemoveExperiment", mock.Anything, testExperimentPackage.Name).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	assert.Eventually(t, func() bool {
+		i.m.Lock()
+		defer i.m.Unlock()
+		if len(i.rcc.packagesState) != 1 || i.rcc.packagesState[0].Task == nil {
+			return false
+		}
+		task := i.rcc.packagesState[0].Task
+		return task.Id == "test-request-2" && task.State == pbgo.TaskState_ERROR &&
+			task.Error != nil && task.Error.Code == uint64(installerErrors.ErrExperimentExpired)
+	}, time.Second, 10*time.Millisecond)
+	i.pm.AssertExpectations(t)
+	_, ok = i.expiries.get(testExperimentPackage.Name)
+	assert.False(t, ok)
+}
+
+func TestRemoteRequestBlockedPackage(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, BlockedPackages: []string{"datadog-apm-inject"}})
+	defer i.Stop()
+
+	testExperimentPackage := Package{
+		Name:     "datadog-apm-inject",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/datadog-apm-inject@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
+
+	// The request is refused without checking the state of the package
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrPackageBlocked), task.Error.Code)
+	assert.Equal(t, "package datadog-apm-inject is blocked from remote management on this host", task.Error.Message)
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+}
+
+func TestRemoteRequestDryRun(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	paramsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version, DryRun: true})
+
+	// the experiment is checked but not installed
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperimentDryRun", mock.Anything, testExperimentPackage.URL).Return(&installer.DryRunReport{
+		Package: testExperimentPackage.Name,
+		Version: testExperimentPackage.Version,
+		Stable:  "0.0.1",
+		Changes: []string{"start experiment test-package 1.0.0 on top of stable 0.0.1"},
+	}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	var report installer.DryRunReport
+	require.NoError(t, json.Unmarshal([]byte(task.DryRunReport), &report))
+	assert.Equal(t, "0.0.1", report.Stable)
+	assert.Equal(t, []string{"start experiment test-package 1.0.0 on top of stable 0.0.1"}, report.Changes)
+}
+
+func TestRemoteRequestPinnedPackage(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, PinnedPackages: map[string]string{"datadog-agent": "7.55.1"}})
+	defer i.Stop()
+
+	pinnedPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.55.1-1",
+		URL:      "oci://example.com/datadog-agent@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	newerPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.56.0-1",
+		URL:      "oci://example.com/datadog-agent@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{pinnedPackage, newerPackage}})
+
+	// the versions other than the pinned one are dropped from the catalog
+	assert.Equal(t, []Package{pinnedPackage}, i.GetCatalog())
+
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: newerPackage.Version})
+	i.pm.On("State", newerPackage.Name).Return(repository.State{Stable: "7.55.1-1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       newerPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.1-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_INVALID_STATE, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrPackagePinned), task.Error.Code)
+	assert.Equal(t, "package datadog-agent is pinned to version 7.55.1 on this host, refusing version 7.56.0-1", task.Error.Message)
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+
+	// the pinned version can still be started
+	versionParamsJSON, _ = json.Marshal(taskWithVersionParams{Version: pinnedPackage.Version})
+	i.pm.On("State", pinnedPackage.Name).Return(repository.State{Stable: "7.55.0-1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, pinnedPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStartExperiment,
+		Package:       pinnedPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestPinnedPackageMethods(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, PinnedPackages: map[string]string{"datadog-agent": "7.55.1"}})
+	defer i.Stop()
+
+	tests := []struct {
+		method  string
+		state   repository.State
+		message string
+	}{
+		{methodPromoteExperiment, repository.State{Stable: "7.55.1-1", Experiment: "7.56.0-1"}, "refusing version 7.56.0-1"},
+		{methodStopExperiment, repository.State{Stable: "7.54.0-1", Experiment: "7.55.1-1"}, "refusing version 7.54.0-1"},
+		{methodRollback, repository.State{Stable: "7.55.1-1", Previous: "7.54.0-1"}, "refusing version 7.54.0-1"},
+		{methodUninstall, repository.State{Stable: "7.55.1-1"}, "refusing to uninstall it"},
+	}
+	for n, tt := range tests {
+		i.pm.On("State", "datadog-agent").Return(tt.state, nil).Once()
+		i.rcc.SubmitRequest(remoteAPIRequest{
+			ID:            fmt.Sprintf("test-request-%d", n),
+			Method:        tt.method,
+			Package:       "datadog-agent",
+			ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: tt.state.Stable, Experiment: tt.state.Experiment},
+			Params:        json.RawMessage("{}"),
+		})
+		i.requestsWG.Wait()
+
+		require.Len(t, i.rcc.packagesState, 1, tt.method)
+		task := i.rcc.packagesState[0].Task
+		require.NotNil(t, task, tt.method)
+		assert.Equal(t, pbgo.TaskState_INVALID_STATE, task.State, tt.method)
+		require.NotNil(t, task.Error, tt.method)
+		assert.Equal(t, uint64(installerErrors.ErrPackagePinned), task.Error.Code, tt.method)
+		assert.Contains(t, task.Error.Message, tt.message, tt.method)
+	}
+	i.pm.AssertExpectations(t)
+	for _, method := range []string{"PromoteExperiment", "RemoveExperiment", "Rollback", "Remove"} {
+		i.pm.AssertNotCalled(t, method, mock.Anything, mock.Anything)
+	}
+
+	// promoting the pinned version is allowed
+	i.pm.On("State", "datadog-agent").Return(repository.State{Stable: "7.54.0-1", Experiment: "7.55.1-1"}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "datadog-agent").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-promote",
+		Method:        methodPromoteExperiment,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.54.0-1", Experiment: "7.55.1-1"},
+		Params:        json.RawMessage("{}"),
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestChannel(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, Channel: channelBeta})
+	defer i.Stop()
+
+	// the channel is reported to remote config
+	assert.Contains(t, i.rcc.tags, channelTagPrefix+channelBeta)
+
+	stablePackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.55.1-1",
+		URL:      "oci://example.com/datadog-agent@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	betaPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.56.0-rc.1-1",
+		URL:      "oci://example.com/datadog-agent@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Channel:  channelBeta,
+	}
+	nightlyPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.57.0-devel-1",
+		URL:      "oci://example.com/datadog-agent@sha256:486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Channel:  channelNightly,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{stablePackage, betaPackage, nightlyPackage}})
+
+	// the packages of the less stable channels are filtered out
+	assert.Equal(t, []Package{stablePackage, betaPackage}, i.GetCatalog())
+	_, err := i.GetPackage(nightlyPackage.Name, nightlyPackage.Version)
+	assert.Error(t, err)
+
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: nightlyPackage.Version})
+	i.pm.On("State", nightlyPackage.Name).Return(repository.State{Stable: "7.55.1-1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       nightlyPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.1-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_INVALID_STATE, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrPackageChannel), task.Error.Code)
+	assert.Equal(t, "version 7.57.0-devel-1 of package datadog-agent is in the nightly channel, this host is subscribed to the beta channel", task.Error.Message)
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+
+	// the packages of the subscribed channel can be started
+	versionParamsJSON, _ = json.Marshal(taskWithVersionParams{Version: betaPackage.Version})
+	i.pm.On("State", betaPackage.Name).Return(repository.State{Stable: "7.55.1-1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, betaPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStartExperiment,
+		Package:       betaPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.1-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+}
+
+func TestHeartbeat(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, HeartbeatInterval: 10 * time.Millisecond})
+	defer i.Stop()
+
+	// the health is reported from the start, in the state rather than the tags
+	i.m.Lock()
+	assert.False(t, i.rcc.health.Heartbeat.IsZero())
+	assert.Zero(t, i.rcc.health.QueueDepth)
+	for _, tag := range i.rcc.tags {
+		assert.NotContains(t, tag, "heartbeat")
+	}
+	i.rcc.health = client.UpdaterHealth{}
+	// the state is reported along with the health
+	i.pm.ExpectedCalls = nil
+	i.pm.On("States").Return(map[string]repository.State{"test-package": {Stable: "1.0.0"}}, nil)
+	i.m.Unlock()
+
+	assert.Eventually(t, func() bool {
+		i.m.Lock()
+		defer i.m.Unlock()
+		return !i.rcc.health.Heartbeat.IsZero() && len(i.rcc.packagesState) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGarbageCollect(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	i.pm.On("GarbageCollect", mock.Anything).Return(nil).Once()
+	assert.NoError(t, i.GarbageCollect(context.Background()))
+	i.m.Lock()
+	assert.False(t, i.lastGC.IsZero())
+	i.m.Unlock()
+
+	i.pm.On("GarbageCollect", mock.Anything).Return(errors.New("disk full")).Once()
+	assert.ErrorContains(t, i.GarbageCollect(context.Background()), "disk full")
+	i.pm.AssertExpectations(t)
+}
+
+func TestPeriodicGarbageCollect(t *testing.T) {
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	pm.On("GarbageCollect", mock.Anything).Return(nil)
+	i := newDaemon(&remoteConfig{client: newTestRemoteConfigClient()}, pm, &env.Env{GCInterval: 10 * time.Millisecond})
+	i.Start(context.Background())
+	defer i.Stop(context.Background())
+
+	assert.Eventually(t, func() bool {
+		i.m.Lock()
+		defer i.m.Unlock()
+		return !i.lastGC.IsZero()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteRequest(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	testStablePackage := Package{
+		Name:     "test-package",
+		Version:  "0.0.1",
+		URL:      "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	c := catalog{
+		Packages: []Package{testExperimentPackage},
+	}
+	versionParams := taskWithVersionParams{
+		Version: testExperimentPackage.Version,
+	}
+	versionParamsJSON, _ := json.Marshal(versionParams)
+	i.rcc.SubmitCatalog(c)
+
+	testRequest := remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: testStablePackage.Version},
+		Params:        versionParamsJSON,
+	}
+	i.pm.On("State", testStablePackage.Name).Return(repository.State{Stable: testStablePackage.Version}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
 	i.rcc.SubmitRequest(testRequest)
 	i.requestsWG.Wait()
 
@@ -309,3 +1529,226 @@ func TestRemoteRequest(t *testing.T) {
 
 	i.pm.AssertExpectations(t)
 }
+
+func TestRemoteRequestResumedAfterRestart(t *testing.T) {
+	// The previous daemon was stopped while promoting the agent, with an uninstall queued
+	store := newRequestStore(filepath.Join(t.TempDir(), requestStoreFile))
+	store.add(remoteAPIRequest{ID: "test-request-1", Method: methodPromoteExperiment, Package: "datadog-agent"})
+	store.add(remoteAPIRequest{ID: "test-request-2", Method: methodUninstall, Package: "datadog-apm-inject"})
+	store.setRunning("test-request-1")
+
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	pm.On("State", "datadog-apm-inject").Return(repository.State{}, nil).Once()
+	pm.On("Remove", mock.Anything, "datadog-apm-inject").Return(nil).Once()
+	rcc := newTestRemoteConfigClient()
+	i := &testInstaller{
+		daemonImpl: newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{RemoteUpdates: true}),
+		rcc:        rcc,
+		pm:         pm,
+	}
+	i.requestStore = newRequestStore(store.path)
+	i.Start(context.Background())
+	defer i.Stop()
+	i.requestsWG.Wait()
+
+	// The interrupted request is reported as aborted and the queued one is resumed
+	tasks := map[string]*pbgo.PackageStateTask{}
+	for _, p := range i.rcc.packagesState {
+		tasks[p.Package] = p.Task
+	}
+	require.NotNil(t, tasks["datadog-agent"])
+	assert.Equal(t, "test-request-1", tasks["datadog-agent"].Id)
+	assert.Equal(t, pbgo.TaskState_ERROR, tasks["datadog-agent"].State)
+	assert.Equal(t, "remote request test-request-1 was aborted by a restart of the daemon", tasks["datadog-agent"].Error.Message)
+	pm.AssertNotCalled(t, "PromoteExperiment", mock.Anything, "datadog-agent")
+	pm.AssertExpectations(t)
+
+	// The handled requests are removed from the store
+	queued, aborted, handedOff, err := newRequestStore(store.path).load()
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+	assert.Empty(t, aborted)
+	assert.Empty(t, handedOff)
+}
+
+func TestRemoteRequestHandOff(t *testing.T) {
+	// the installer experiment restarts the daemon asynchronously, after the request is handled
+	previousInstallInstallerExperiment := installInstallerExperiment
+	installInstallerExperiment = func(_ context.Context, _ *env.Env, _ string) error { return nil }
+	defer func() { installInstallerExperiment = previousInstallInstallerExperiment }()
+
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	pm.On("State", "datadog-installer").Return(repository.State{Stable: "7.55.0-1"}, nil).Once()
+	rcc := newTestRemoteConfigClient()
+	i := &testInstaller{
+		daemonImpl: newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{RemoteUpdates: true}),
+		rcc:        rcc,
+		pm:         pm,
+	}
+	i.requestStore = newRequestStore(filepath.Join(t.TempDir(), requestStoreFile))
+	i.Start(context.Background())
+	defer i.Stop()
+
+	installerPackage := Package{
+		Name:     "datadog-installer",
+		Version:  "7.56.0-1",
+		URL:      "oci://example.com/datadog-installer@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{installerPackage}})
+	paramsJSON, _ := json.Marshal(taskWithVersionParams{Version: installerPackage.Version})
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       installerPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0-1"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+	pm.AssertExpectations(t)
+
+	// the request is kept for the restarted daemon to report its outcome
+	_, _, handedOff, err := newRequestStore(i.requestStore.path).load()
+	assert.NoError(t, err)
+	require.Len(t, handedOff, 1)
+	assert.Equal(t, "test-request-1", handedOff[0].ID)
+}
+
+func TestRemoteRequestHandedOffAfterRestart(t *testing.T) {
+	// The previous daemon was restarted by the installer experiment it was starting
+	paramsJSON, _ := json.Marshal(taskWithVersionParams{Version: "7.56.0-1"})
+	store := newRequestStore(filepath.Join(t.TempDir(), requestStoreFile))
+	store.add(remoteAPIRequest{ID: "test-request-1", Method: methodStartExperiment, Package: "datadog-installer", Params: paramsJSON})
+	store.setRunning("test-request-1")
+	store.setHandedOff("test-request-1")
+
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	pm.On("State", "datadog-installer").Return(repository.State{Stable: "7.55.0-1", Experiment: "7.56.0-1"}, nil).Once()
+	rcc := newTestRemoteConfigClient()
+	i := &testInstaller{
+		daemonImpl: newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{RemoteUpdates: true}),
+		rcc:        rcc,
+		pm:         pm,
+	}
+	i.requestStore = newRequestStore(store.path)
+	i.Start(context.Background())
+	defer i.Stop()
+	i.requestsWG.Wait()
+
+	// The request is reported as done rather than aborted by the restart
+	var task *pbgo.PackageStateTask
+	for _, p := range i.rcc.packagesState {
+		if p.Package == "datadog-installer" {
+			task = p.Task
+		}
+	}
+	require.NotNil(t, task)
+	assert.Equal(t, "test-request-1", task.Id)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	pm.AssertExpectations(t)
+
+	_, _, handedOff, err := newRequestStore(store.path).load()
+	assert.NoError(t, err)
+	assert.Empty(t, handedOff)
+}
+
+func TestRemoteRequestDowngrade(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+	metrics := &testStatsd{}
+	i.statsd = metrics
+	i.versions.record("datadog-agent", "7.56.0-1")
+
+	olderPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.55.1-1",
+		URL:      "oci://example.com/datadog-agent@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{olderPackage}})
+
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: olderPackage.Version})
+	i.pm.On("State", olderPackage.Name).Return(repository.State{Stable: "7.55.0-1"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       olderPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_INVALID_STATE, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrPackageDowngrade), task.Error.Code)
+	assert.Equal(t, "version 7.55.1-1 of package datadog-agent is lower than version 7.56.0-1 installed on this host, the downgrade must be explicitly allowed", task.Error.Message)
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+
+	// the downgrade is executed and audited once explicitly allowed
+	versionParamsJSON, _ = json.Marshal(taskWithVersionParams{Version: olderPackage.Version, AllowDowngrade: true})
+	i.pm.On("State", olderPackage.Name).Return(repository.State{Stable: "7.55.0-1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, olderPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStartExperiment,
+		Package:       olderPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0-1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	task = i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	downgrades := metrics.get(metricDowngrades)
+	require.Len(t, downgrades, 1)
+	assert.Equal(t, []string{"package:datadog-agent", "from_version:7.56.0-1", "to_version:7.55.1-1"}, downgrades[0].tags)
+}
+
+func TestRemoteRequestDeltaUpdate(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, DeltaUpdates: true})
+	defer i.Stop()
+
+	stablePackage := Package{
+		Name:     "test-package",
+		Version:  "0.0.1",
+		URL:      "oci://example.com/test-package@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	experimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{stablePackage, experimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: experimentPackage.Version})
+
+	// the experiment is downloaded as a delta from the stable version
+	i.pm.On("State", experimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.MatchedBy(func(ctx context.Context) bool {
+		return installer.DeltaBase(ctx) == stablePackage.URL
+	}), experimentPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       experimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+	require.Len(t, i.rcc.packagesState, 1)
+	assert.Equal(t, pbgo.TaskState_DONE, i.rcc.packagesState[0].Task.State)
+}
@@ -0,0 +1,160 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// subscriberBufferSize is the number of events buffered for a subscriber before
+	// new events are dropped for it
+	subscriberBufferSize = 16
+	// taskHistorySize is the number of task state changes kept in the history of the daemon
+	taskHistorySize = 50
+)
+
+// StateEvent is sent to the subscribers of the daemon whenever the state of the
+// packages or of a remote task changes.
+type StateEvent struct {
+	Packages map[string]repository.State `json:"packages"`
+	Task     *TaskState                  `json:"task,omitempty"`
+}
+
+// TaskState is the state of a remote task.
+type TaskState struct {
+	ID       string `json:"id"`
+	Package  string `json:"package"`
+	Priority int    `json:"priority,omitempty"`
+	State    string `json:"state"`
+	Error    string `json:"error,omitempty"`
+	// Delay is the time waited before executing the task, if any, until the next maintenance
+	// window when it's pending or because of the random jitter
+	Delay string `json:"delay,omitempty"`
+	// DryRunReport is the JSON report of what the task would change, for dry runs
+	DryRunReport string `json:"dry_run_report,omitempty"`
+}
+
+// TaskRecord is a state change of a remote task kept in the task history of the daemon.
+type TaskRecord struct {
+	TaskState
+	Time time.Time `json:"time"`
+}
+
+// taskHistory keeps the last state changes of the remote tasks, to troubleshoot them
+// once they're done.
+type taskHistory struct {
+	m       sync.Mutex
+	records []TaskRecord
+}
+
+func (h *taskHistory) add(task TaskState) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	// the state is published again on refreshes, only changes are recorded. Tasks for
+	// different packages run concurrently, their records are interleaved.
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].ID == task.ID {
+			if h.records[i].TaskState == task {
+				return
+			}
+			break
+		}
+	}
+	h.records = append(h.records, TaskRecord{TaskState: task, Time: time.Now()})
+	if len(h.records) > taskHistorySize {
+		h.records = append([]TaskRecord(nil), h.records[len(h.records)-taskHistorySize:]...)
+	}
+}
+
+// lastDone returns the last record of a task that is done, whatever its outcome, nil if there is none
+func (h *taskHistory) lastDone() *TaskRecord {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for i := len(h.records) - 1; i >= 0; i-- {
+		switch h.records[i].State {
+		case pbgo.TaskState_DONE.String(), pbgo.TaskState_ERROR.String(), pbgo.TaskState_INVALID_STATE.String():
+			record := h.records[i]
+			return &record
+		}
+	}
+	return nil
+}
+
+func (h *taskHistory) list() []TaskRecord {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return append([]TaskRecord(nil), h.records...)
+}
+
+// subscribers fans out the state events of the daemon. Publishing never blocks
+// the daemon: events are dropped for subscribers that don't keep up. An event
+// equal to the last one sent to a subscriber isn't sent again, as the state is
+// refreshed more often than it changes.
+type subscribers struct {
+	m      sync.Mutex
+	chans  map[chan StateEvent]*StateEvent
+	closed bool
+}
+
+func newSubscribers() *subscribers {
+	return &subscribers{
+		chans: make(map[chan StateEvent]*StateEvent),
+	}
+}
+
+// subscribe returns a channel receiving the state events and a function to
+// unsubscribe. The channel is closed once unsubscribed or when the daemon stops.
+func (s *subscribers) subscribe() (<-chan StateEvent, func()) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	c := make(chan StateEvent, subscriberBufferSize)
+	if s.closed {
+		close(c)
+		return c, func() {}
+	}
+	s.chans[c] = nil
+	return c, func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+		if _, ok := s.chans[c]; ok {
+			delete(s.chans, c)
+			close(c)
+		}
+	}
+}
+
+func (s *subscribers) publish(event StateEvent) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for c, last := range s.chans {
+		if last != nil && reflect.DeepEqual(*last, event) {
+			continue
+		}
+		select {
+		case c <- event:
+			s.chans[c] = &event
+		default:
+			log.Debugf("Daemon: dropping state event for slow subscriber")
+		}
+	}
+}
+
+func (s *subscribers) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for c := range s.chans {
+		delete(s.chans, c)
+		close(c)
+	}
+	s.closed = true
+}
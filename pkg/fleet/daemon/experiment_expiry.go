@@ -0,0 +1,212 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// experimentExpiriesFile is the file, in the packages directory, the expiries of the experiments are persisted to
+const experimentExpiriesFile = "experiment_expiries.json"
+
+// experimentExpiry is the expiry of an experiment started by a remote request with a TTL
+type experimentExpiry struct {
+	RequestID string    `json:"request_id"`
+	Version   string    `json:"version"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// experimentExpiries stops the experiments that are neither promoted nor stopped before their expiry, so
+// that hosts aren't left running an experiment forgotten by the fleet operator. The expiries are persisted
+// so that they survive the restarts of the daemon, they're only kept in memory if there is no path.
+type experimentExpiries struct {
+	m        sync.Mutex
+	path     string
+	expiries map[string]experimentExpiry
+	timers   map[string]*time.Timer
+	// onExpire is called once an experiment expires, nil until the expiries are started
+	onExpire func(pkg string, expiry experimentExpiry)
+}
+
+func newExperimentExpiries(path string) *experimentExpiries {
+	return &experimentExpiries{
+		path:     path,
+		expiries: make(map[string]experimentExpiry),
+		timers:   make(map[string]*time.Timer),
+	}
+}
+
+// load reads the expiries persisted by the previous daemons
+func (e *experimentExpiries) load() error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if e.path == "" {
+		return nil
+	}
+	rawExpiries, err := os.ReadFile(e.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read experiment expiries: %w", err)
+	}
+	var expiries map[string]experimentExpiry
+	err = json.Unmarshal(rawExpiries, &expiries)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal experiment expiries: %w", err)
+	}
+	for pkg, expiry := range expiries {
+		e.expiries[pkg] = expiry
+	}
+	return nil
+}
+
+// start arms the timers of the expiries, the experiments that expired while the daemon was stopped
+// expire right away
+func (e *experimentExpiries) start(onExpire func(pkg string, expiry experimentExpiry)) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.onExpire = onExpire
+	for pkg, expiry := range e.expiries {
+		e.armLocked(pkg, expiry)
+	}
+}
+
+// stop disarms the timers, the expiries are kept for the next daemon
+func (e *experimentExpiries) stop() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.onExpire = nil
+	for pkg, timer := range e.timers {
+		timer.Stop()
+		delete(e.timers, pkg)
+	}
+}
+
+// schedule sets the expiry of the experiment of a package, replacing the previous one
+func (e *experimentExpiries) schedule(pkg string, expiry experimentExpiry) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.expiries[pkg] = expiry
+	e.armLocked(pkg, expiry)
+	e.persist()
+}
+
+// cancel removes the expiry of the experiment of a package, once it's promoted or stopped
+func (e *experimentExpiries) cancel(pkg string) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if timer, ok := e.timers[pkg]; ok {
+		timer.Stop()
+		delete(e.timers, pkg)
+	}
+	if _, ok := e.expiries[pkg]; !ok {
+		return
+	}
+	delete(e.expiries, pkg)
+	e.persist()
+}
+
+// get returns the expiry of the experiment of a package
+func (e *experimentExpiries) get(pkg string) (experimentExpiry, bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	expiry, ok := e.expiries[pkg]
+	return expiry, ok
+}
+
+func (e *experimentExpiries) armLocked(pkg string, expiry experimentExpiry) {
+	if timer, ok := e.timers[pkg]; ok {
+		timer.Stop()
+	}
+	onExpire := e.onExpire
+	if onExpire == nil {
+		return
+	}
+	e.timers[pkg] = time.AfterFunc(time.Until(expiry.ExpiresAt), func() {
+		onExpire(pkg, expiry)
+	})
+}
+
+// persist writes the expiries to disk. Failing to persist them only loses the expiries of the experiments
+// running when the daemon restarts.
+func (e *experimentExpiries) persist() {
+	if e.path == "" {
+		return
+	}
+	err := e.write()
+	if err != nil {
+		log.Warnf("Daemon: could not persist the experiment expiries: %v", err)
+	}
+}
+
+func (e *experimentExpiries) write() error {
+	rawExpiries, err := json.Marshal(e.expiries)
+	if err != nil {
+		return fmt.Errorf("could not marshal experiment expiries: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(e.path), filepath.Base(e.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(rawExpiries)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write experiment expiries: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), e.path)
+	if err != nil {
+		return fmt.Errorf("could not move experiment expiries: %w", err)
+	}
+	return nil
+}
+
+// expireExperiment stops the experiment of a package that reached its expiry, and reports the request that
+// started it as failed. Experiments that were replaced since aren't stopped.
+func (d *daemonImpl) expireExperiment(pkg string, expiry experimentExpiry) {
+	unlock := d.packages.lock(pkg)
+	defer unlock()
+	if current, ok := d.expiries.get(pkg); !ok || current != expiry {
+		return
+	}
+	s, err := d.installer.State(pkg)
+	if err != nil {
+		log.Errorf("Daemon: could not get the state of package %s to expire its experiment: %v", pkg, err)
+		return
+	}
+	if s.Experiment != expiry.Version {
+		log.Infof("Daemon: Experiment of package %s version %s is no longer running, ignoring its expiry", pkg, expiry.Version)
+		d.expiries.cancel(pkg)
+		return
+	}
+
+	log.Warnf("Daemon: Experiment of package %s version %s expired without being promoted or stopped, stopping it", pkg, expiry.Version)
+	ctx := context.WithValue(context.Background(), requestStateKey, &requestState{
+		Package: pkg,
+		ID:      expiry.RequestID,
+		State:   pbgo.TaskState_RUNNING,
+	})
+	ctx = withAuditRequester(ctx, requesterDaemon)
+	expiredErr := fmt.Errorf("experiment of version %s expired at %s and was stopped", expiry.Version, expiry.ExpiresAt.UTC().Format(time.RFC3339))
+	if err := d.stopExperiment(ctx, pkg); err != nil {
+		expiredErr = fmt.Errorf("experiment of version %s expired at %s and could not be stopped: %w", expiry.Version, expiry.ExpiresAt.UTC().Format(time.RFC3339), err)
+	}
+	setRequestDone(ctx, installerErrors.Wrap(installerErrors.ErrExperimentExpired, expiredErr))
+	d.refreshState(ctx)
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperimentExpiries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), experimentExpiriesFile)
+	e := newExperimentExpiries(path)
+	e.schedule("datadog-agent", experimentExpiry{RequestID: "test-request-1", Version: "7.56.0-1", ExpiresAt: time.Now().Add(-time.Minute)})
+	e.schedule("datadog-apm-inject", experimentExpiry{RequestID: "test-request-2", Version: "0.10.0-1", ExpiresAt: time.Now().Add(time.Hour)})
+	e.schedule("datadog-apm-java", experimentExpiry{RequestID: "test-request-3", Version: "1.0.0", ExpiresAt: time.Now().Add(-time.Minute)})
+	e.cancel("datadog-apm-java")
+
+	// the experiments that expired while the daemon was stopped expire as soon as it starts
+	expired := make(chan string, 3)
+	restored := newExperimentExpiries(path)
+	require.NoError(t, restored.load())
+	restored.start(func(pkg string, expiry experimentExpiry) {
+		assert.Equal(t, "test-request-1", expiry.RequestID)
+		expired <- pkg
+	})
+	defer restored.stop()
+	select {
+	case pkg := <-expired:
+		assert.Equal(t, "datadog-agent", pkg)
+	case <-time.After(time.Second):
+		t.Fatal("the experiment didn't expire")
+	}
+	_, ok := restored.get("datadog-apm-inject")
+	assert.True(t, ok)
+	_, ok = restored.get("datadog-apm-java")
+	assert.False(t, ok)
+	assert.Empty(t, expired)
+}
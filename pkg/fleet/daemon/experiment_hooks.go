@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	osexec "os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// defaultExperimentHookTimeout bounds each run of a hook if no timeout is set
+	defaultExperimentHookTimeout = 2 * time.Minute
+	// experimentHookOutputMaxSize is the size of the end of the output of a hook recorded in the audit log
+	experimentHookOutputMaxSize = 4096
+
+	hookPhaseBefore = "before"
+	hookPhaseAfter  = "after"
+
+	// a failing hook fails the operation if its failure semantics is abort, and is only logged and
+	// recorded in the audit log if it is warn
+	hookOnFailureAbort = "abort"
+	hookOnFailureWarn  = "warn"
+)
+
+// experimentHookEvent describes the operation on a package a hook is run for: an install, or the start,
+// stop or promotion of an experiment.
+type experimentHookEvent struct {
+	// Phase is either before or after the operation
+	Phase     string `json:"phase"`
+	Operation string `json:"operation"`
+	Package   string `json:"package"`
+	// Version is the version being installed, if known
+	Version   string `json:"version,omitempty"`
+	RequestID string `json:"request_id"`
+	Hostname  string `json:"hostname"`
+	// Error is the error of the operation, only set after it
+	Error string `json:"error,omitempty"`
+}
+
+// experimentHook is run around the operations restarting the services of a package, e.g. to cordon or
+// annotate the Kubernetes node while the agent is restarted, or to quiesce the services depending on it.
+// It returns the end of its output, recorded in the audit log.
+type experimentHook interface {
+	fmt.Stringer
+	run(ctx context.Context, event experimentHookEvent) (string, error)
+}
+
+// newExperimentHooks returns the hooks configured with a command and a URL
+func newExperimentHooks(command string, hookURL string) ([]experimentHook, error) {
+	var hooks []experimentHook
+	if command != "" {
+		hooks = append(hooks, &commandExperimentHook{command: command})
+	}
+	if hookURL != "" {
+		u, err := url.Parse(hookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid experiment hook URL %q", hookURL)
+		}
+		hooks = append(hooks, &httpExperimentHook{url: hookURL, client: &http.Client{}})
+	}
+	return hooks, nil
+}
+
+// commandExperimentHook runs a command with `sh -c`, the event is passed in DD_EXPERIMENT_HOOK_*
+// environment variables.
+type commandExperimentHook struct {
+	command string
+}
+
+func (h *commandExperimentHook) String() string {
+	return "command"
+}
+
+func (h *commandExperimentHook) run(ctx context.Context, event experimentHookEvent) (string, error) {
+	cmd := osexec.CommandContext(ctx, "sh", "-c", h.command)
+	cmd.Env = append(os.Environ(),
+		"DD_EXPERIMENT_HOOK_PHASE="+event.Phase,
+		"DD_EXPERIMENT_HOOK_OPERATION="+event.Operation,
+		"DD_EXPERIMENT_HOOK_PACKAGE="+event.Package,
+		"DD_EXPERIMENT_HOOK_VERSION="+event.Version,
+		"DD_EXPERIMENT_HOOK_REQUEST_ID="+event.RequestID,
+		"DD_EXPERIMENT_HOOK_HOSTNAME="+event.Hostname,
+		"DD_EXPERIMENT_HOOK_ERROR="+event.Error,
+	)
+	rawOutput, err := cmd.CombinedOutput()
+	output := lastBytes(string(rawOutput), experimentHookOutputMaxSize)
+	if ctx.Err() != nil {
+		return output, fmt.Errorf("hook command timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		return output, fmt.Errorf("hook command failed: %w: %s", err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
+
+// lastBytes returns the last max bytes of s
+func lastBytes(s string, max int) string {
+	if len(s) > max {
+		return s[len(s)-max:]
+	}
+	return s
+}
+
+// httpExperimentHook posts the event as JSON to a URL, which must answer with a 2xx status.
+type httpExperimentHook struct {
+	url    string
+	client *http.Client
+}
+
+func (h *httpExperimentHook) String() string {
+	return "http:" + h.url
+}
+
+func (h *httpExperimentHook) run(ctx context.Context, event experimentHookEvent) (string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal hook event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not reach %s: %w", h.url, err)
+	}
+	defer resp.Body.Close()
+	rawOutput, _ := io.ReadAll(io.LimitReader(resp.Body, experimentHookOutputMaxSize))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return string(rawOutput), fmt.Errorf("%s answered with status %d", h.url, resp.StatusCode)
+	}
+	return string(rawOutput), nil
+}
+
+// experimentHookEvent returns the event of the hooks run around an operation on a package, if the hooks
+// are configured for the package.
+func (d *daemonImpl) experimentHookEvent(ctx context.Context, operation string, pkg string, version string) (experimentHookEvent, bool) {
+	if pkg == "" || len(d.experimentHooks) == 0 || !slices.Contains(d.env.ExperimentHooks.Packages, pkg) {
+		return experimentHookEvent{}, false
+	}
+	_, requestID := auditRequester(ctx)
+	return experimentHookEvent{
+		Operation: operation,
+		Package:   pkg,
+		Version:   version,
+		RequestID: requestID,
+		Hostname:  d.rolloutHost.hostname,
+	}, true
+}
+
+// withExperimentHooks runs the operation on the package between the hooks configured for it. The after
+// hooks are run even if the operation or the before hooks failed, with the error.
+func (d *daemonImpl) withExperimentHooks(ctx context.Context, operation string, pkg string, version string, op func() error) error {
+	event, ok := d.experimentHookEvent(ctx, operation, pkg, version)
+	if !ok {
+		return op()
+	}
+	err := d.runExperimentHooks(ctx, hookPhaseBefore, event)
+	if err != nil {
+		err = fmt.Errorf("could not run experiment hooks: %w", err)
+	} else {
+		err = op()
+	}
+	return d.runExperimentHooksAfter(ctx, event, err)
+}
+
+// runExperimentHooksAfter runs the after hooks of an operation that returned opErr, it returns the error
+// of the operation or of the hooks if the operation succeeded.
+func (d *daemonImpl) runExperimentHooksAfter(ctx context.Context, event experimentHookEvent, opErr error) error {
+	if opErr != nil {
+		event.Error = opErr.Error()
+	}
+	hookErr := d.runExperimentHooks(ctx, hookPhaseAfter, event)
+	if opErr != nil {
+		if hookErr != nil {
+			log.Errorf("Daemon: could not run experiment hooks after %s of package %s: %v", event.Operation, event.Package, hookErr)
+		}
+		return opErr
+	}
+	if hookErr != nil {
+		return fmt.Errorf("could not run experiment hooks: %w", hookErr)
+	}
+	return nil
+}
+
+// runExperimentHooks runs the hooks for a phase of an operation. It stops at the first one failing,
+// unless their failure semantics is warn.
+func (d *daemonImpl) runExperimentHooks(ctx context.Context, phase string, event experimentHookEvent) error {
+	timeout := d.env.ExperimentHooks.Timeout
+	if timeout <= 0 {
+		timeout = defaultExperimentHookTimeout
+	}
+	warn := d.env.ExperimentHooks.OnFailure == hookOnFailureWarn
+	event.Phase = phase
+	for _, hook := range d.experimentHooks {
+		log.Infof("Daemon: Running experiment hook %s %s %s of package %s", hook, phase, event.Operation, event.Package)
+		start := time.Now()
+		hookCtx, cancel := context.WithTimeout(ctx, timeout)
+		output, err := hook.run(hookCtx, event)
+		cancel()
+		d.auditExperimentHook(ctx, hook, event, output, start, err, warn)
+		if err == nil {
+			continue
+		}
+		if !warn {
+			return fmt.Errorf("experiment hook %s failed: %w", hook, err)
+		}
+		log.Warnf("Daemon: experiment hook %s %s %s of package %s failed, ignoring it: %v", hook, phase, event.Operation, event.Package, err)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+type testExperimentHook struct {
+	m      sync.Mutex
+	err    error
+	events []experimentHookEvent
+}
+
+func (h *testExperimentHook) String() string {
+	return "test"
+}
+
+func (h *testExperimentHook) run(_ context.Context, event experimentHookEvent) (string, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.events = append(h.events, event)
+	return event.Phase + " output", h.err
+}
+
+func newHookedTestInstaller(hook experimentHook) *testInstaller {
+	i := newTestInstallerWithEnv(&env.Env{
+		RemoteUpdates:   true,
+		ExperimentHooks: env.ExperimentHooks{Packages: []string{"test-package"}},
+	})
+	i.experimentHooks = []experimentHook{hook}
+	return i
+}
+
+func TestNewExperimentHooks(t *testing.T) {
+	hooks, err := newExperimentHooks("kubectl cordon node", "https://drain.example.com/hook")
+	require.NoError(t, err)
+	require.Len(t, hooks, 2)
+	assert.Equal(t, "command", hooks[0].String())
+	assert.Equal(t, "http:https://drain.example.com/hook", hooks[1].String())
+
+	hooks, err = newExperimentHooks("", "")
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+
+	_, err = newExperimentHooks("", "drain.example.com")
+	assert.Error(t, err)
+}
+
+func TestCommandExperimentHook(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hook")
+	hook := &commandExperimentHook{command: fmt.Sprintf(`echo "$DD_EXPERIMENT_HOOK_PHASE $DD_EXPERIMENT_HOOK_OPERATION $DD_EXPERIMENT_HOOK_PACKAGE $DD_EXPERIMENT_HOOK_VERSION" > %s`, output)}
+
+	_, err := hook.run(context.Background(), experimentHookEvent{Phase: hookPhaseBefore, Operation: methodStartExperiment, Package: "datadog-agent", Version: "7.56.0-1"})
+	require.NoError(t, err)
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "before start_experiment datadog-agent 7.56.0-1\n", string(content))
+
+	hook = &commandExperimentHook{command: "echo node is not drainable && exit 1"}
+	hookOutput, err := hook.run(context.Background(), experimentHookEvent{})
+	assert.ErrorContains(t, err, "node is not drainable")
+	assert.Equal(t, "node is not drainable\n", hookOutput)
+}
+
+func TestCommandExperimentHookOutput(t *testing.T) {
+	// only the end of the output is kept
+	hook := &commandExperimentHook{command: `head -c 5000 /dev/zero | tr '\0' 'a'; echo -n "$DD_EXPERIMENT_HOOK_PHASE"`}
+	output, err := hook.run(context.Background(), experimentHookEvent{Phase: hookPhaseBefore})
+	require.NoError(t, err)
+	assert.Len(t, output, experimentHookOutputMaxSize)
+	assert.True(t, strings.HasSuffix(output, hookPhaseBefore))
+
+	hook = &commandExperimentHook{command: "sleep 10"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = hook.run(ctx, experimentHookEvent{})
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestHTTPExperimentHook(t *testing.T) {
+	var received experimentHookEvent
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+		w.Write([]byte("node annotated"))
+	}))
+	defer s.Close()
+	hook := &httpExperimentHook{url: s.URL, client: s.Client()}
+
+	event := experimentHookEvent{Phase: hookPhaseAfter, Operation: methodPromoteExperiment, Package: "datadog-agent", RequestID: "1", Hostname: "node-1"}
+	output, err := hook.run(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, "node annotated", output)
+	assert.Equal(t, event, received)
+	status = http.StatusConflict
+	_, err = hook.run(context.Background(), event)
+	assert.ErrorContains(t, err, "answered with status 409")
+}
+
+func TestRemoteRequestExperimentHooks(t *testing.T) {
+	hook := &testExperimentHook{}
+	i := newHookedTestInstaller(hook)
+	defer i.Stop()
+
+	startExperiment(i)
+	task := reportedTask(t, i)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	i.pm.AssertExpectations(t)
+
+	require.Len(t, hook.events, 2)
+	assert.Equal(t, hookPhaseBefore, hook.events[0].Phase)
+	assert.Equal(t, hookPhaseAfter, hook.events[1].Phase)
+	for _, event := range hook.events {
+		assert.Equal(t, methodStartExperiment, event.Operation)
+		assert.Equal(t, "test-package", event.Package)
+		assert.Equal(t, "1.0.0", event.Version)
+		assert.Equal(t, "test-request-1", event.RequestID)
+		assert.Empty(t, event.Error)
+	}
+}
+
+func TestRemoteRequestExperimentHookRefused(t *testing.T) {
+	hook := &testExperimentHook{err: errors.New("node is not drainable")}
+	i := newHookedTestInstaller(hook)
+	defer i.Stop()
+
+	i.pm.On("State", "test-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodPromoteExperiment,
+		Package:       "test-package",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1", Experiment: "1.0.0"},
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	i.pm.AssertNotCalled(t, "PromoteExperiment", mock.Anything, mock.Anything)
+	// the after hooks are still run, with the error
+	require.Len(t, hook.events, 2)
+	assert.Equal(t, hookPhaseBefore, hook.events[0].Phase)
+	assert.Equal(t, hookPhaseAfter, hook.events[1].Phase)
+	assert.Contains(t, hook.events[1].Error, "node is not drainable")
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, "could not run experiment hooks: experiment hook test failed: node is not drainable", task.Error.Message)
+}
+
+func TestRemoteRequestExperimentHooksOtherPackage(t *testing.T) {
+	hook := &testExperimentHook{}
+	i := newHookedTestInstaller(hook)
+	defer i.Stop()
+
+	i.pm.On("State", "other-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "other-package").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodPromoteExperiment,
+		Package:       "other-package",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1", Experiment: "1.0.0"},
+	})
+	i.requestsWG.Wait()
+
+	i.pm.AssertExpectations(t)
+	assert.Empty(t, hook.events)
+}
+
+func TestExperimentHooksInstall(t *testing.T) {
+	hook := &testExperimentHook{err: errors.New("could not quiesce my-app")}
+	i := newTestInstallerWithEnv(&env.Env{ExperimentHooks: env.ExperimentHooks{
+		Packages:  []string{"test-package"},
+		OnFailure: hookOnFailureWarn,
+	}})
+	defer i.Stop()
+	i.experimentHooks = []experimentHook{hook}
+	auditPath := filepath.Join(t.TempDir(), auditLogFile)
+	i.auditLog = newAuditLog(auditPath)
+
+	testURL := "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	require.NoError(t, i.handleCatalogUpdate(catalog{Packages: []Package{{Name: "test-package", Version: "1.0.0", URL: testURL}}}))
+	i.pm.On("Install", mock.Anything, testURL, []string(nil)).Return(nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "test-package").Return(nil).Once()
+
+	// the failing hooks only warn, the operations are still done
+	require.NoError(t, i.Install(context.Background(), testURL, nil))
+	require.NoError(t, i.PromoteExperiment(context.Background(), "test-package"))
+	i.pm.AssertExpectations(t)
+
+	var runs []string
+	for _, event := range hook.events {
+		runs = append(runs, event.Phase+" "+event.Operation+" "+event.Version)
+	}
+	assert.Equal(t, []string{
+		"before install 1.0.0",
+		"after install 1.0.0",
+		"before promote_experiment ",
+		"after promote_experiment ",
+	}, runs)
+
+	var hookEntries []string
+	for _, entry := range readAuditLog(t, auditPath) {
+		if entry.Operation == auditExperimentHook {
+			hookEntries = append(hookEntries, entry.Hook+" "+entry.Method+" "+entry.Outcome+" "+entry.Output)
+		}
+	}
+	assert.Equal(t, []string{
+		"before:test install warning before output",
+		"after:test install warning after output",
+		"before:test promote_experiment warning before output",
+		"after:test promote_experiment warning after output",
+	}, hookEntries)
+}
+
+func TestExperimentHooksAbortInstall(t *testing.T) {
+	hook := &testExperimentHook{err: errors.New("could not quiesce my-app")}
+	i := newTestInstallerWithEnv(&env.Env{ExperimentHooks: env.ExperimentHooks{Packages: []string{"test-package"}}})
+	defer i.Stop()
+	i.experimentHooks = []experimentHook{hook}
+
+	testURL := "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	require.NoError(t, i.handleCatalogUpdate(catalog{Packages: []Package{{Name: "test-package", Version: "1.0.0", URL: testURL}}}))
+
+	err := i.Install(context.Background(), testURL, nil)
+	assert.ErrorContains(t, err, "could not quiesce my-app")
+	// the package isn't installed, the after hooks are still run with the error
+	i.pm.AssertNotCalled(t, "Install", mock.Anything, testURL, mock.Anything)
+	require.Len(t, hook.events, 2)
+	assert.Equal(t, hookPhaseAfter, hook.events[1].Phase)
+	assert.Contains(t, hook.events[1].Error, "could not quiesce my-app")
+}
+
+func TestExperimentHooksInstallerExperimentHandedOff(t *testing.T) {
+	// the previous daemon ran the before hooks and was restarted by the installer experiment
+	paramsJSON, _ := json.Marshal(taskWithVersionParams{Version: "7.56.0-1"})
+	store := newRequestStore(filepath.Join(t.TempDir(), requestStoreFile))
+	store.add(remoteAPIRequest{ID: "test-request-1", Method: methodStartExperiment, Package: "datadog-installer", Params: paramsJSON})
+	store.setRunning("test-request-1")
+	store.setHandedOff("test-request-1")
+
+	pm := &testPackageManager{}
+	pm.On("States").Return(map[string]repository.State{}, nil)
+	pm.On("State", "datadog-installer").Return(repository.State{Stable: "7.55.0-1", Experiment: "7.56.0-1"}, nil).Once()
+	rcc := newTestRemoteConfigClient()
+	hook := &testExperimentHook{}
+	i := &testInstaller{
+		daemonImpl: newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{
+			RemoteUpdates:   true,
+			ExperimentHooks: env.ExperimentHooks{Packages: []string{"datadog-installer"}},
+		}),
+		rcc: rcc,
+		pm:  pm,
+	}
+	i.experimentHooks = []experimentHook{hook}
+	i.requestStore = newRequestStore(store.path)
+	i.Start(context.Background())
+	defer i.Stop()
+	i.requestsWG.Wait()
+
+	// the restarted daemon runs the after hooks once it completes the request
+	require.Len(t, hook.events, 1)
+	assert.Equal(t, experimentHookEvent{
+		Phase:     hookPhaseAfter,
+		Operation: methodStartExperiment,
+		Package:   "datadog-installer",
+		Version:   "7.56.0-1",
+		RequestID: "test-request-1",
+		Hostname:  i.rolloutHost.hostname,
+	}, hook.events[0])
+	pm.AssertExpectations(t)
+}
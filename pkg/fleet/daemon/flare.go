@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/scrubber"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+const (
+	// fleetFlareDir is the directory of the files in the fleet flare tarball, so that it can be
+	// extracted next to an agent flare
+	fleetFlareDir = "fleet"
+)
+
+// fleetFlareStatus is the status of the daemon included in fleet flares.
+type fleetFlareStatus struct {
+	Version  string                      `json:"version"`
+	Packages map[string]repository.State `json:"packages"`
+	Error    string                      `json:"error,omitempty"`
+}
+
+// CollectFleetFlare writes a tarball with what is needed to troubleshoot fleet issues: the daemon
+// logs, the state of the package repositories, the recent task history, the APM injection status
+// and the redacted environment. It returns the path of the tarball, to be removed by the caller.
+func (d *daemonImpl) CollectFleetFlare(ctx context.Context) (string, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	return d.collectFleetFlare(ctx)
+}
+
+func (d *daemonImpl) collectFleetFlare(ctx context.Context) (_ string, err error) {
+	span, _ := tracer.StartSpanFromContext(ctx, "collect_fleet_flare")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	f, err := os.CreateTemp("", "datadog-fleet-flare-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("could not create fleet flare: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	status := fleetFlareStatus{Version: version.AgentVersion}
+	status.Packages, err = d.installer.States()
+	if err != nil {
+		status.Error = err.Error()
+	}
+	err = addFlareJSON(tw, "status.json", status)
+	if err != nil {
+		return "", err
+	}
+	err = addFlareJSON(tw, "tasks.json", d.tasks.list())
+	if err != nil {
+		return "", err
+	}
+	var apmReport interface{}
+	apmReport, apmErr := apmInjectionStatus()
+	if apmErr != nil {
+		apmReport = APIError{Message: apmErr.Error()}
+	}
+	err = addFlareJSON(tw, "apm_injection.json", apmReport)
+	if err != nil {
+		return "", err
+	}
+	err = addFlareFile(tw, "env.log", []byte(strings.Join(d.env.ToRedactedEnv(), "\n")+"\n"))
+	if err != nil {
+		return "", err
+	}
+	if d.logFile != "" {
+		// the current log file and the last rolled one
+		for _, logFile := range []string{d.logFile, d.logFile + ".1"} {
+			content, err := os.ReadFile(logFile)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				content = []byte(fmt.Sprintf("could not read %s: %v\n", logFile, err))
+			}
+			err = addFlareFile(tw, path.Join("logs", filepath.Base(logFile)), content)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("could not write fleet flare: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return "", fmt.Errorf("could not write fleet flare: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// sendFleetFlare collects a fleet flare and sends it to the given support case, next to the agent flares
+func (d *daemonImpl) sendFleetFlare(ctx context.Context, params flareParams, source helpers.FlareSource) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "send_fleet_flare")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("case_id", params.CaseID)
+
+	if d.config == nil {
+		return fmt.Errorf("fleet flares can only be sent by the daemon service")
+	}
+	d.m.Lock()
+	flarePath, err := d.collectFleetFlare(ctx)
+	apiKey := d.env.APIKey
+	d.m.Unlock()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(flarePath)
+	response, err := helpers.SendTo(d.config, flarePath, params.CaseID, params.Email, apiKey, helpers.GetFlareEndpoint(d.config), source)
+	if err != nil {
+		return fmt.Errorf("could not send fleet flare: %w", err)
+	}
+	log.Infof("Installer: Fleet flare sent: %s", response)
+	return nil
+}
+
+func addFlareJSON(tw *tar.Writer, name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal %s: %w", name, err)
+	}
+	return addFlareFile(tw, name, content)
+}
+
+// addFlareFile adds a file to the fleet flare, scrubbing the credentials its content may hold as the
+// daemon logs and the task params aren't scrubbed when they're written
+func addFlareFile(tw *tar.Writer, name string, content []byte) error {
+	content, err := scrubber.ScrubBytes(content)
+	if err != nil {
+		return fmt.Errorf("could not scrub %s: %w", name, err)
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join(fleetFlareDir, name),
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not write %s to fleet flare: %w", name, err)
+	}
+	_, err = tw.Write(content)
+	if err != nil {
+		return fmt.Errorf("could not write %s to fleet flare: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+func readFlare(t *testing.T, flarePath string) map[string][]byte {
+	f, err := os.Open(flarePath)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
+
+func TestCollectFleetFlare(t *testing.T) {
+	stubAPIKeyValidation(t)
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, APIKey: "0123456789abcdef"})
+	defer i.Stop()
+	i.logFile = filepath.Join(t.TempDir(), "updater.log")
+	require.NoError(t, os.WriteFile(i.logFile, []byte("daemon logs\nDD_API_KEY=0123456789abcdef0123456789abcdef\n"), 0644))
+
+	paramsJSON := rotateAPIKeyParamsJSON(t, "fedcba9876543210", "0123456789abcdef")
+	i.pm.On("State", "datadog-agent").Return(repository.State{Stable: "7.55.0"}, nil).Once()
+	i.pm.On("RotateAPIKey", mock.Anything, "fedcba9876543210").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodRotateAPIKey,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.55.0"},
+		Params:        paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	flarePath, err := i.CollectFleetFlare(context.Background())
+	require.NoError(t, err)
+	defer os.Remove(flarePath)
+	files := readFlare(t, flarePath)
+
+	assert.Contains(t, files, "fleet/status.json")
+	assert.Contains(t, files, "fleet/apm_injection.json")
+	assert.Contains(t, string(files["fleet/logs/updater.log"]), "daemon logs\n")
+	assert.NotContains(t, string(files["fleet/logs/updater.log"]), "0123456789abcdef0123456789abcdef")
+	assert.NotContains(t, string(files["fleet/env.log"]), "fedcba9876543210")
+	assert.Contains(t, string(files["fleet/env.log"]), "DD_API_KEY=")
+
+	var tasks []TaskRecord
+	require.NoError(t, json.Unmarshal(files["fleet/tasks.json"], &tasks))
+	require.NotEmpty(t, tasks)
+	last := tasks[len(tasks)-1]
+	assert.Equal(t, "test-request-1", last.ID)
+	assert.Equal(t, "DONE", last.State)
+}
+
+func TestRemoteFlareWithoutConfig(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+
+	paramsJSON, _ := json.Marshal(flareParams{CaseID: "12345"})
+	// flares are sent whatever the state of the packages
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:      "test-request-1",
+		Method:  methodFlare,
+		Package: "datadog-installer",
+		Params:  paramsJSON,
+	})
+	i.requestsWG.Wait()
+
+	tasks := i.tasks.list()
+	require.NotEmpty(t, tasks)
+	assert.Equal(t, "ERROR", tasks[len(tasks)-1].State)
+	i.pm.AssertNotCalled(t, "State", mock.Anything)
+}
+
+func TestTaskHistory(t *testing.T) {
+	var h taskHistory
+	h.add(TaskState{ID: "1", State: "RUNNING"})
+	h.add(TaskState{ID: "1", State: "RUNNING"})
+	h.add(TaskState{ID: "1", State: "DONE"})
+	records := h.list()
+	require.Len(t, records, 2)
+	assert.Equal(t, "RUNNING", records[0].State)
+	assert.Equal(t, "DONE", records[1].State)
+
+	for n := 0; n < 2*taskHistorySize; n++ {
+		h.add(TaskState{ID: strconv.Itoa(n), State: "DONE"})
+	}
+	assert.Len(t, h.list(), taskHistorySize)
+}
@@ -0,0 +1,378 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// healthCheckTimeout bounds each run of a health probe
+	healthCheckTimeout = 30 * time.Second
+	// healthCheckMaxFailures is the number of consecutive failures of a probe after which the
+	// experiment is considered unhealthy, so that a single slow check doesn't roll it back
+	healthCheckMaxFailures = 3
+	// defaultHealthCheckInterval is the interval between the runs of the probes if none is set
+	defaultHealthCheckInterval = 30 * time.Second
+	// healthCheckFile is the executable packages can ship at their root to check their health
+	healthCheckFile = "health-check"
+	// experimentHealthChecksFile is the file, in the packages directory, the pending health checks of
+	// the experiments are persisted to
+	experimentHealthChecksFile = "experiment_health_checks.json"
+)
+
+// healthProbe checks the health of the experiment of a package.
+type healthProbe interface {
+	fmt.Stringer
+	check(ctx context.Context) error
+}
+
+// parseHealthProbe parses a health probe formatted as `<package>:<probe>` and returns the package
+// it applies to. The probes are `systemd:<unit>`, `http:<url>` and `package`.
+func parseHealthProbe(s string) (string, healthProbe, error) {
+	pkg, probe, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || pkg == "" {
+		return "", nil, fmt.Errorf("invalid health probe %q, expected <package>:<probe>", s)
+	}
+	probeType, arg, _ := strings.Cut(probe, ":")
+	switch probeType {
+	case "systemd":
+		if arg == "" {
+			return "", nil, fmt.Errorf("invalid health probe %q, expected %s:systemd:<unit>", s, pkg)
+		}
+		return pkg, &systemdHealthProbe{unit: arg}, nil
+	case "http":
+		u, err := url.Parse(arg)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", nil, fmt.Errorf("invalid health probe %q, expected %s:http:<url>", s, pkg)
+		}
+		return pkg, &httpHealthProbe{url: arg, client: &http.Client{}}, nil
+	case "package":
+		if arg != "" {
+			return "", nil, fmt.Errorf("invalid health probe %q, expected %s:package", s, pkg)
+		}
+		repositories := repository.NewRepositories(installer.PackagesPath, installer.LocksPack)
+		return pkg, &packageHealthProbe{experimentPath: repositories.Get(pkg).ExperimentPath()}, nil
+	default:
+		return "", nil, fmt.Errorf("invalid health probe %q, unknown probe %q", s, probeType)
+	}
+}
+
+// systemdHealthProbe checks that a systemd unit is active.
+type systemdHealthProbe struct {
+	unit string
+}
+
+func (p *systemdHealthProbe) String() string {
+	return "systemd:" + p.unit
+}
+
+func (p *systemdHealthProbe) check(ctx context.Context) error {
+	err := osexec.CommandContext(ctx, "systemctl", "is-active", "--quiet", p.unit).Run()
+	if err != nil {
+		return fmt.Errorf("unit %s is not active: %w", p.unit, err)
+	}
+	return nil
+}
+
+// httpHealthProbe checks that an endpoint, such as the health endpoint of the agent, answers
+// with a 2xx status.
+type httpHealthProbe struct {
+	url    string
+	client *http.Client
+}
+
+func (p *httpHealthProbe) String() string {
+	return "http:" + p.url
+}
+
+func (p *httpHealthProbe) check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered with status %d", p.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// packageHealthProbe runs the health check executable shipped at the root of the experiment.
+type packageHealthProbe struct {
+	experimentPath string
+}
+
+func (p *packageHealthProbe) String() string {
+	return "package"
+}
+
+func (p *packageHealthProbe) check(ctx context.Context) error {
+	cmd := osexec.CommandContext(ctx, filepath.Join(p.experimentPath, healthCheckFile))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", healthCheckFile, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// pendingHealthCheck is the health check of the experiment started by a remote request
+type pendingHealthCheck struct {
+	RequestID string `json:"request_id"`
+	Version   string `json:"version"`
+}
+
+// healthChecks tracks the health checks of the experiments, run in the background so that the packages
+// aren't locked while they're checked. The checks are persisted: a check interrupted by a stop of the
+// daemon doesn't count as healthy, the next daemon runs it again. They're only kept in memory if there
+// is no path.
+type healthChecks struct {
+	m       sync.Mutex
+	path    string
+	pending map[string]pendingHealthCheck
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func newHealthChecks(path string) *healthChecks {
+	return &healthChecks{
+		path:    path,
+		pending: make(map[string]pendingHealthCheck),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// load reads the checks the previous daemon didn't complete
+func (h *healthChecks) load() (map[string]pendingHealthCheck, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.path == "" {
+		return nil, nil
+	}
+	rawChecks, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read experiment health checks: %w", err)
+	}
+	var checks map[string]pendingHealthCheck
+	err = json.Unmarshal(rawChecks, &checks)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal experiment health checks: %w", err)
+	}
+	return checks, nil
+}
+
+// start records the check of the experiment of a package, replacing the previous one, and returns the
+// context of the check, done once it's cancelled or the daemon stops
+func (h *healthChecks) start(pkg string, check pendingHealthCheck) context.Context {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if cancel, ok := h.cancels[pkg]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.pending[pkg] = check
+	h.cancels[pkg] = cancel
+	h.wg.Add(1)
+	h.persist()
+	return ctx
+}
+
+// current returns whether the check is still the one of the experiment of the package
+func (h *healthChecks) current(pkg string, check pendingHealthCheck) bool {
+	h.m.Lock()
+	defer h.m.Unlock()
+	current, ok := h.pending[pkg]
+	return ok && current == check
+}
+
+// done removes the check of the experiment of a package once it's over
+func (h *healthChecks) done(pkg string, check pendingHealthCheck) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if current, ok := h.pending[pkg]; !ok || current != check {
+		return
+	}
+	h.cancels[pkg]()
+	delete(h.cancels, pkg)
+	delete(h.pending, pkg)
+	h.persist()
+}
+
+// cancel stops the check of the experiment of a package, once it's promoted or stopped
+func (h *healthChecks) cancel(pkg string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if _, ok := h.pending[pkg]; !ok {
+		return
+	}
+	h.cancels[pkg]()
+	delete(h.cancels, pkg)
+	delete(h.pending, pkg)
+	h.persist()
+}
+
+// prune persists the checks being run, dropping the ones loaded from the previous daemon that weren't
+// started again
+func (h *healthChecks) prune() {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.persist()
+}
+
+// stop interrupts the checks and waits for them, they're kept for the next daemon
+func (h *healthChecks) stop() {
+	h.m.Lock()
+	for _, cancel := range h.cancels {
+		cancel()
+	}
+	h.m.Unlock()
+	h.wg.Wait()
+}
+
+// persist writes the checks to disk. Failing to persist them only loses the checks of the experiments
+// being checked when the daemon restarts.
+func (h *healthChecks) persist() {
+	if h.path == "" {
+		return
+	}
+	err := h.write()
+	if err != nil {
+		log.Warnf("Daemon: could not persist the experiment health checks: %v", err)
+	}
+}
+
+func (h *healthChecks) write() error {
+	rawChecks, err := json.Marshal(h.pending)
+	if err != nil {
+		return fmt.Errorf("could not marshal experiment health checks: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(rawChecks)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write experiment health checks: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), h.path)
+	if err != nil {
+		return fmt.Errorf("could not move experiment health checks: %w", err)
+	}
+	return nil
+}
+
+// resumeExperimentHealthChecks runs again the checks the previous daemon didn't complete, for the
+// experiments that are still running
+func (d *daemonImpl) resumeExperimentHealthChecks() {
+	checks, err := d.healthChecks.load()
+	if err != nil {
+		log.Errorf("Daemon: could not load the experiment health checks: %v", err)
+		return
+	}
+	for pkg, check := range checks {
+		s, err := d.installer.State(pkg)
+		if err != nil || s.Experiment != check.Version {
+			log.Infof("Daemon: Experiment of package %s version %s is no longer running, dropping its health check", pkg, check.Version)
+			continue
+		}
+		log.Infof("Daemon: Resuming the health check of the experiment of package %s version %s interrupted by the restart of the daemon", pkg, check.Version)
+		d.watchExperimentHealth(pkg, check)
+	}
+	// the checks that aren't resumed are dropped
+	d.healthChecks.prune()
+}
+
+// watchExperimentHealth checks the health of the experiment that was just started in the background,
+// and rolls it back if it's unhealthy
+func (d *daemonImpl) watchExperimentHealth(pkg string, check pendingHealthCheck) {
+	if d.env.ExperimentHealthCheckDuration <= 0 || len(d.healthProbes[pkg]) == 0 {
+		return
+	}
+	ctx := d.healthChecks.start(pkg, check)
+	go func() {
+		defer d.healthChecks.wg.Done()
+		healthErr := d.checkExperimentHealth(ctx, pkg)
+		if ctx.Err() != nil {
+			// cancelled by another operation on the package, or interrupted by a stop of the daemon
+			return
+		}
+		if healthErr == nil {
+			d.healthChecks.done(pkg, check)
+			return
+		}
+		d.rollbackUnhealthyExperiment(ctx, pkg, check, healthErr)
+	}()
+}
+
+// checkExperimentHealth runs the health probes of a package every interval until the health check
+// duration is over. It returns an error as soon as a probe fails healthCheckMaxFailures times in a
+// row, or if the context is done before the end of the checks.
+func (d *daemonImpl) checkExperimentHealth(ctx context.Context, pkg string) error {
+	probes := d.healthProbes[pkg]
+	duration := d.env.ExperimentHealthCheckDuration
+	interval := d.env.ExperimentHealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	log.Infof("Daemon: Checking the health of the experiment for package %s for %s", pkg, duration)
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := make([]int, len(probes))
+	for {
+		select {
+		case <-deadline.C:
+			log.Infof("Daemon: Experiment for package %s passed its health checks", pkg)
+			return nil
+		case <-ctx.Done():
+			log.Warnf("Daemon: Health checks of the experiment for package %s interrupted before their end", pkg)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		for i, probe := range probes {
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			err := probe.check(checkCtx)
+			cancel()
+			if err == nil {
+				failures[i] = 0
+				continue
+			}
+			failures[i]++
+			log.Warnf("Daemon: Health probe %s of the experiment for package %s failed (%d/%d): %v", probe, pkg, failures[i], healthCheckMaxFailures, err)
+			if failures[i] >= healthCheckMaxFailures {
+				return fmt.Errorf("health probe %s failed: %w", probe, err)
+			}
+		}
+	}
+}
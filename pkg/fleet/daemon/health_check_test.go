@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+type testHealthProbe struct {
+	err    error
+	checks atomic.Int32
+}
+
+func (p *testHealthProbe) String() string {
+	return "test"
+}
+
+func (p *testHealthProbe) check(_ context.Context) error {
+	p.checks.Add(1)
+	return p.err
+}
+
+func TestParseHealthProbe(t *testing.T) {
+	pkg, probe, err := parseHealthProbe("datadog-agent:systemd:datadog-agent-exp.service")
+	require.NoError(t, err)
+	assert.Equal(t, "datadog-agent", pkg)
+	assert.Equal(t, &systemdHealthProbe{unit: "datadog-agent-exp.service"}, probe)
+
+	pkg, probe, err = parseHealthProbe("datadog-agent:http:http://localhost:5555/live")
+	require.NoError(t, err)
+	assert.Equal(t, "datadog-agent", pkg)
+	assert.Equal(t, "http:http://localhost:5555/live", probe.String())
+
+	pkg, probe, err = parseHealthProbe(" datadog-apm-inject:package ")
+	require.NoError(t, err)
+	assert.Equal(t, "datadog-apm-inject", pkg)
+	assert.Equal(t, &packageHealthProbe{experimentPath: "/opt/datadog-packages/datadog-apm-inject/experiment"}, probe)
+
+	for _, s := range []string{
+		"",
+		"datadog-agent",
+		":systemd:datadog-agent.service",
+		"datadog-agent:systemd",
+		"datadog-agent:http:localhost:5555",
+		"datadog-agent:package:health-check",
+		"datadog-agent:tcp:localhost:5555",
+	} {
+		_, _, err := parseHealthProbe(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestHTTPHealthProbe(t *testing.T) {
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+	probe := &httpHealthProbe{url: s.URL, client: s.Client()}
+
+	assert.NoError(t, probe.check(context.Background()))
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, probe.check(context.Background()), "answered with status 503")
+}
+
+// startExperiment submits a request starting an experiment of the test package and waits for it
+func startExperiment(i *testInstaller) {
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
+
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+}
+
+// reportedTask returns the task reported for the test package once its health checks are over
+func reportedTask(t *testing.T, i *testInstaller) *pbgo.PackageStateTask {
+	i.healthChecks.wg.Wait()
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	return i.rcc.packagesState[0].Task
+}
+
+func TestRemoteRequestHealthyExperiment(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{
+		RemoteUpdates:                 true,
+		ExperimentHealthCheckDuration: 100 * time.Millisecond,
+		ExperimentHealthCheckInterval: 10 * time.Millisecond,
+	})
+	defer i.Stop()
+	probe := &testHealthProbe{}
+	i.healthProbes["test-package"] = []healthProbe{probe}
+
+	startExperiment(i)
+	task := reportedTask(t, i)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	assert.Nil(t, task.Error)
+	assert.Greater(t, probe.checks.Load(), int32(0))
+	assert.False(t, i.healthChecks.current("test-package", pendingHealthCheck{RequestID: "test-request-1", Version: "1.0.0"}))
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestUnhealthyExperiment(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{
+		RemoteUpdates:                 true,
+		ExperimentHealthCheckDuration: time.Hour,
+		ExperimentHealthCheckInterval: 10 * time.Millisecond,
+	})
+	defer i.Stop()
+	probe := &testHealthProbe{err: errors.New("agent is not running")}
+	i.healthProbes["test-package"] = []healthProbe{probe}
+
+	startExperiment(i)
+	i.pm.On("State", "test-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.pm.On("RemoveExperiment", mock.Anything, "test-package").Return(nil).Once()
+	task := reportedTask(t, i)
+	assert.Equal(t, "test-request-1", task.Id)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrExperimentUnhealthy), task.Error.Code)
+	assert.Equal(t, "experiment rolled back automatically: health probe test failed: agent is not running", task.Error.Message)
+	assert.Equal(t, int32(healthCheckMaxFailures), probe.checks.Load())
+	i.pm.AssertExpectations(t)
+}
+
+func TestRemoteRequestExperimentCheckedWithoutLock(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{
+		RemoteUpdates:                 true,
+		ExperimentHealthCheckDuration: time.Hour,
+		ExperimentHealthCheckInterval: 10 * time.Millisecond,
+	})
+	defer i.Stop()
+	i.healthProbes["test-package"] = []healthProbe{&testHealthProbe{}}
+	startExperiment(i)
+
+	// the experiment is stopped while it's being checked, which cancels the check
+	i.pm.On("State", "test-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.pm.On("RemoveExperiment", mock.Anything, "test-package").Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodStopExperiment,
+		Package:       "test-package",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1", Experiment: "1.0.0"},
+	})
+	i.requestsWG.Wait()
+	task := reportedTask(t, i)
+	assert.Equal(t, "test-request-2", task.Id)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	i.pm.AssertExpectations(t)
+}
+
+func TestExperimentHealthCheckInterruptedByStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), experimentHealthChecksFile)
+	newInstaller := func(probe healthProbe) *testInstaller {
+		pm := &testPackageManager{}
+		pm.On("States").Return(map[string]repository.State{}, nil)
+		rcc := newTestRemoteConfigClient()
+		i := &testInstaller{
+			daemonImpl: newDaemon(&remoteConfig{client: rcc}, pm, &env.Env{
+				RemoteUpdates:                 true,
+				ExperimentHealthCheckDuration: time.Hour,
+				ExperimentHealthCheckInterval: 10 * time.Millisecond,
+			}),
+			rcc: rcc,
+			pm:  pm,
+		}
+		i.healthChecks = newHealthChecks(path)
+		i.healthProbes["test-package"] = []healthProbe{probe}
+		return i
+	}
+
+	// the daemon stops before the end of the checks, the experiment isn't considered healthy
+	i := newInstaller(&testHealthProbe{})
+	i.Start(context.Background())
+	startExperiment(i)
+	i.Stop()
+	checks, err := newHealthChecks(path).load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]pendingHealthCheck{"test-package": {RequestID: "test-request-1", Version: "1.0.0"}}, checks)
+
+	// the next daemon checks the experiment again and rolls it back
+	restarted := newInstaller(&testHealthProbe{err: errors.New("agent is not running")})
+	restarted.pm.On("State", "test-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil)
+	restarted.pm.On("RemoveExperiment", mock.Anything, "test-package").Return(nil).Once()
+	restarted.Start(context.Background())
+	defer restarted.Stop()
+	task := reportedTask(t, restarted)
+	assert.Equal(t, "test-request-1", task.Id)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	restarted.pm.AssertExpectations(t)
+	checks, err = newHealthChecks(path).load()
+	require.NoError(t, err)
+	assert.Empty(t, checks)
+}
+
+func TestRemoteRequestExperimentHealthCheckDisabled(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+	probe := &testHealthProbe{err: errors.New("agent is not running")}
+	i.healthProbes["test-package"] = []healthProbe{probe}
+
+	startExperiment(i)
+	task := reportedTask(t, i)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	assert.Equal(t, int32(0), probe.checks.Load())
+	i.pm.AssertExpectations(t)
+}
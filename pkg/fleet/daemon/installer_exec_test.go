@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/exec"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fakeinstaller"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+func TestMain(m *testing.M) {
+	fakeinstaller.Main()
+	os.Exit(m.Run())
+}
+
+// fakeInstallerExec runs the fake installer binary for the operations on the packages, and reads
+// the state of the packages from memory instead of the packages directory
+type fakeInstallerExec struct {
+	*exec.InstallerExec
+	states map[string]repository.State
+}
+
+func (i *fakeInstallerExec) State(pkg string) (repository.State, error) {
+	return i.states[pkg], nil
+}
+
+func (i *fakeInstallerExec) States() (map[string]repository.State, error) {
+	return i.states, nil
+}
+
+func newTestDaemonWithFakeInstaller(t *testing.T) *testInstaller {
+	env := &env.Env{RemoteUpdates: true}
+	installer := &fakeInstallerExec{
+		InstallerExec: exec.NewInstallerExec(env, fakeinstaller.Path(t)),
+		states: map[string]repository.State{
+			"datadog-agent": {Stable: "7.56.0", Experiment: "7.57.0"},
+		},
+	}
+	rcc := newTestRemoteConfigClient()
+	rc := &remoteConfig{client: rcc}
+	i := &testInstaller{
+		daemonImpl: newDaemon(rc, installer, env),
+		rcc:        rcc,
+	}
+	i.Start(context.Background())
+	t.Cleanup(i.Stop)
+	return i
+}
+
+// remoteRequestTask submits a request promoting the agent experiment and returns the reported task
+func remoteRequestTask(t *testing.T, i *testInstaller) *pbgo.PackageStateTask {
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodPromoteExperiment,
+		Package:       "datadog-agent",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "7.56.0", Experiment: "7.57.0"},
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	require.NotNil(t, i.rcc.packagesState[0].Task)
+	assert.Equal(t, "test-request-1", i.rcc.packagesState[0].Task.Id)
+	return i.rcc.packagesState[0].Task
+}
+
+func TestInstallerExecContractSuccess(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Stdout: "promoting experiment\n"})
+	i := newTestDaemonWithFakeInstaller(t)
+
+	task := remoteRequestTask(t, i)
+	assert.Equal(t, pbgo.TaskState_DONE, task.State)
+	assert.Nil(t, task.Error)
+}
+
+func TestInstallerExecContractErrorCode(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{
+		Error:    &fakeinstaller.Error{Message: "experiment failed its smoke tests", Code: installerErrors.ErrExperimentUnhealthy},
+		ExitCode: 255,
+	})
+	i := newTestDaemonWithFakeInstaller(t)
+
+	task := remoteRequestTask(t, i)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrExperimentUnhealthy), task.Error.Code)
+	assert.Equal(t, "could not promote experiment: experiment failed its smoke tests", task.Error.Message)
+}
+
+func TestInstallerExecContractCrash(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Stderr: "promoting experiment\n", Crash: true})
+	i := newTestDaemonWithFakeInstaller(t)
+
+	task := remoteRequestTask(t, i)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(0), task.Error.Code)
+	assert.Contains(t, task.Error.Message, "exit status 2")
+	assert.Contains(t, task.Error.Message, "promoting experiment")
+}
+
+func TestInstallerExecContractExitCodeWithoutError(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Stderr: "promoting experiment", ExitCode: 1})
+	i := newTestDaemonWithFakeInstaller(t)
+
+	task := remoteRequestTask(t, i)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(0), task.Error.Code)
+	assert.Contains(t, task.Error.Message, "exit status 1")
+}
+
+func TestInstallerExecContractTimeout(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Hang: true})
+	i := newTestDaemonWithFakeInstaller(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := i.PromoteExperiment(ctx, "datadog-agent")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the daemon isn't left blocked by the interrupted installer
+	fakeinstaller.Set(t, fakeinstaller.Behavior{})
+	err = i.PromoteExperiment(context.Background(), "datadog-agent")
+	assert.NoError(t, err)
+}
@@ -6,15 +6,24 @@
 package daemon
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/version"
@@ -23,6 +32,16 @@ import (
 
 const (
 	socketName = "installer.sock"
+	// statusSocketName is the socket serving the status of the daemon to the agent, which doesn't
+	// run as root: it's read-only and doesn't expose the operations of the daemon socket.
+	statusSocketName = "installer-status.sock"
+	// statusSocketGroup is the group allowed to read the status of the daemon
+	statusSocketGroup = "dd-agent"
+	// statusClientTimeout bounds the status requests so that a stuck daemon doesn't hang the agent status
+	statusClientTimeout = 5 * time.Second
+	// flareClientTimeout bounds the fleet flares the agent attaches to its own flares, they are collected
+	// once the daemon is done with the operation it may be running
+	flareClientTimeout = 30 * time.Second
 )
 
 // StatusResponse is the response to the status endpoint.
@@ -31,6 +50,39 @@ type StatusResponse struct {
 	Version            string                      `json:"version"`
 	Packages           map[string]repository.State `json:"packages"`
 	ApmInjectionStatus APMInjectionStatus          `json:"apm_injection_status"`
+	Goroutines         int                         `json:"goroutines"`
+	Env                []string                    `json:"env"`
+	Channel            string                      `json:"channel"`
+	Daemon             DaemonStatus                `json:"daemon"`
+}
+
+// DaemonStatus is the state of the daemon itself, shown by the agent status.
+type DaemonStatus struct {
+	StartTime time.Time `json:"start_time"`
+	LastGC    time.Time `json:"last_gc"`
+	// CatalogUpdatedAt is the last time a catalog was received, zero if none was received yet
+	CatalogUpdatedAt time.Time `json:"catalog_updated_at"`
+	CatalogPackages  int       `json:"catalog_packages"`
+	// LastRemoteRequest is the last remote request the daemon is done with, nil if none was handled yet
+	LastRemoteRequest *TaskRecord `json:"last_remote_request,omitempty"`
+}
+
+// SetChannelRequest is the request to switch the host to another release channel.
+type SetChannelRequest struct {
+	Channel string `json:"channel"`
+}
+
+// PackageStateResponse is the response to the package state endpoint.
+type PackageStateResponse struct {
+	APIResponse
+	Package string           `json:"package"`
+	State   repository.State `json:"state"`
+}
+
+// APMInjectionStatusResponse is the response to the APM injection status endpoint.
+type APMInjectionStatusResponse struct {
+	APIResponse
+	APMInjectionStatus
 }
 
 // APMInjectionStatus contains the instrumentation status of the APM injection.
@@ -38,6 +90,38 @@ type APMInjectionStatus struct {
 	HostInstrumented   bool `json:"host_instrumented"`
 	DockerInstalled    bool `json:"docker_installed"`
 	DockerInstrumented bool `json:"docker_instrumented"`
+	// IISInstalled and IISInstrumented are only reported on Windows, where the .NET applications hosted by
+	// IIS are instrumented through the environment of its services
+	IISInstalled    bool `json:"iis_installed,omitempty"`
+	IISInstrumented bool `json:"iis_instrumented,omitempty"`
+}
+
+// CatalogResponse is the response to the catalog endpoint.
+type CatalogResponse struct {
+	APIResponse
+	Packages []CatalogPackage `json:"packages"`
+}
+
+// CatalogPackage is a package of the catalog available for the host.
+type CatalogPackage struct {
+	Package
+	// Stable and Experiment are set if the version is the installed stable or experiment of the package
+	Stable     bool `json:"stable"`
+	Experiment bool `json:"experiment"`
+}
+
+// DryRunResponse is the response to the install and experiment start endpoints.
+type DryRunResponse struct {
+	APIResponse
+	// Report is set for dry runs
+	Report *installer.DryRunReport `json:"report,omitempty"`
+}
+
+// FlareResponse is the response to the flare endpoint.
+type FlareResponse struct {
+	APIResponse
+	// Path is the path of the fleet flare tarball
+	Path string `json:"path"`
 }
 
 // APIResponse is the response to an API request.
@@ -61,49 +145,135 @@ type localAPIImpl struct {
 	daemon   Daemon
 	listener net.Listener
 	server   *http.Server
+	done     chan struct{}
+
+	// statusListener and statusServer serve the read-only status socket, if any
+	statusListener net.Listener
+	statusServer   *http.Server
 }
 
 // NewLocalAPI returns a new LocalAPI.
 func NewLocalAPI(daemon Daemon, runPath string) (LocalAPI, error) {
 	socketPath := filepath.Join(runPath, socketName)
-	err := os.RemoveAll(socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("could not remove socket: %w", err)
-	}
-	listener, err := net.Listen("unix", socketPath)
+	listener, err := listenUnix(socketPath)
 	if err != nil {
 		return nil, err
 	}
 	if err := os.Chmod(socketPath, 0700); err != nil {
+		listener.Close()
 		return nil, fmt.Errorf("error setting socket permissions: %v", err)
 	}
+	statusSocketPath := filepath.Join(runPath, statusSocketName)
+	statusListener, err := listenUnix(statusSocketPath)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := setStatusSocketPermissions(statusSocketPath); err != nil {
+		listener.Close()
+		statusListener.Close()
+		return nil, err
+	}
 	return &localAPIImpl{
-		server:   &http.Server{},
-		listener: listener,
-		daemon:   daemon,
+		server:         &http.Server{},
+		listener:       listener,
+		statusServer:   &http.Server{ReadHeaderTimeout: statusClientTimeout},
+		statusListener: statusListener,
+		daemon:         daemon,
 	}, nil
 }
 
+// listenUnix listens on the unix socket at the given path, replacing the socket left by a previous daemon
+func listenUnix(socketPath string) (net.Listener, error) {
+	err := os.RemoveAll(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not remove socket: %w", err)
+	}
+	return net.Listen("unix", socketPath)
+}
+
+// setStatusSocketPermissions lets the agent read the status of the daemon: the status socket is owned by
+// the dd-agent group. It's only accessible to root if the group doesn't exist.
+func setStatusSocketPermissions(socketPath string) error {
+	group, err := user.LookupGroup(statusSocketGroup)
+	if err != nil {
+		log.Infof("Daemon: status socket only accessible to root, could not find group %s: %v", statusSocketGroup, err)
+		if err := os.Chmod(socketPath, 0700); err != nil {
+			return fmt.Errorf("error setting status socket permissions: %v", err)
+		}
+		return nil
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %s for group %s: %w", group.Gid, statusSocketGroup, err)
+	}
+	if err := os.Chown(socketPath, 0, gid); err != nil {
+		return fmt.Errorf("error setting status socket owner: %v", err)
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		return fmt.Errorf("error setting status socket permissions: %v", err)
+	}
+	return nil
+}
+
 // Start starts the LocalAPI.
 func (l *localAPIImpl) Start(_ context.Context) error {
 	l.server.Handler = l.handler()
+	// Event streams are long lived, they are ended on shutdown so that it doesn't wait for them
+	l.done = make(chan struct{})
+	l.server.RegisterOnShutdown(func() { close(l.done) })
 	go func() {
 		err := l.server.Serve(l.listener)
 		if err != nil {
 			log.Infof("Local API server stopped: %v", err)
 		}
 	}()
+	if l.statusServer != nil {
+		l.statusServer.Handler = l.statusHandler()
+		go func() {
+			err := l.statusServer.Serve(l.statusListener)
+			if err != nil {
+				log.Infof("Local API status server stopped: %v", err)
+			}
+		}()
+	}
 	return nil
 }
 
 // Stop stops the LocalAPI.
 func (l *localAPIImpl) Stop(ctx context.Context) error {
+	if l.statusServer != nil {
+		if err := l.statusServer.Shutdown(ctx); err != nil {
+			log.Warnf("could not stop the local API status server: %v", err)
+		}
+	}
 	return l.server.Shutdown(ctx)
 }
 
+// statusHandler only serves the status of the daemon, on the socket readable by the agent.
+func (l *localAPIImpl) statusHandler() http.Handler {
+	r := mux.NewRouter().Headers("Content-Type", "application/json").Subrouter()
+	r.HandleFunc("/status", l.status).Methods(http.MethodGet)
+	r.HandleFunc("/flare", l.flareArchive).Methods(http.MethodGet)
+	return r
+}
+
 func (l *localAPIImpl) handler() http.Handler {
 	r := mux.NewRouter().Headers("Content-Type", "application/json").Subrouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the operations requested locally are recorded as such in the audit log
+			next.ServeHTTP(w, r.WithContext(withAuditRequester(r.Context(), requesterLocalAPI)))
+		})
+	})
 	r.HandleFunc("/status", l.status).Methods(http.MethodGet)
+	r.HandleFunc("/events", l.events).Methods(http.MethodGet)
+	r.HandleFunc("/catalog", l.catalog).Methods(http.MethodGet)
+	r.HandleFunc("/flare", l.flare).Methods(http.MethodPost)
+	r.HandleFunc("/garbage_collect", l.garbageCollect).Methods(http.MethodPost)
+	r.HandleFunc("/channel", l.setChannel).Methods(http.MethodPost)
+	r.HandleFunc("/apm_injection/status", l.apmInjectionStatus).Methods(http.MethodGet)
+	r.HandleFunc("/{package}/state", l.packageState).Methods(http.MethodGet)
 	r.HandleFunc("/{package}/experiment/start", l.startExperiment).Methods(http.MethodPost)
 	r.HandleFunc("/{package}/experiment/stop", l.stopExperiment).Methods(http.MethodPost)
 	r.HandleFunc("/{package}/experiment/promote", l.promoteExperiment).Methods(http.MethodPost)
@@ -133,15 +303,201 @@ func (l *localAPIImpl) status(w http.ResponseWriter, _ *http.Request) {
 		Version:            version.AgentVersion,
 		Packages:           packages,
 		ApmInjectionStatus: apmStatus,
+		Goroutines:         runtime.NumGoroutine(),
+		Env:                l.daemon.GetRedactedEnv(),
+		Channel:            l.daemon.GetChannel(),
+		Daemon:             l.daemon.GetDaemonStatus(),
+	}
+}
+
+// packageState returns the state of an installed package, for host automation.
+// example: curl --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/datadog-agent/state
+func (l *localAPIImpl) packageState(w http.ResponseWriter, r *http.Request) {
+	pkg := mux.Vars(r)["package"]
+	w.Header().Set("Content-Type", "application/json")
+	response := PackageStateResponse{Package: pkg}
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	packages, err := l.daemon.GetState()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+	state, ok := packages[pkg]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		response.Error = &APIError{Message: fmt.Sprintf("package %s is not installed", pkg)}
+		return
+	}
+	response.State = state
+}
+
+// apmInjectionStatus returns the instrumentation status of the APM injection.
+// example: curl --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/apm_injection/status
+func (l *localAPIImpl) apmInjectionStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var response APMInjectionStatusResponse
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	status, err := l.daemon.GetAPMInjectionStatus()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+	response.APMInjectionStatus = status
+}
+
+// events streams the state events of the daemon as server-sent events.
+// example: curl -N --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/events
+func (l *localAPIImpl) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(APIResponse{Error: &APIError{Message: "streaming is not supported"}})
+		return
+	}
+	events, unsubscribe := l.daemon.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-l.done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Warnf("could not marshal state event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: state\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// catalog returns the packages of the catalog available for the host, along with the versions installed.
+// example: curl --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/catalog
+func (l *localAPIImpl) catalog(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var response CatalogResponse
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	packages, err := l.daemon.GetState()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+	response.Packages = []CatalogPackage{}
+	for _, p := range l.daemon.GetCatalog() {
+		state := packages[p.Name]
+		response.Packages = append(response.Packages, CatalogPackage{
+			Package:    p,
+			Stable:     state.Stable == p.Version,
+			Experiment: state.Experiment == p.Version,
+		})
+	}
+}
+
+// flare collects a fleet flare, whose path is returned.
+// example: curl -X POST --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/flare
+func (l *localAPIImpl) flare(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var response FlareResponse
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	log.Infof("Received local request to collect a fleet flare")
+	path, err := l.daemon.CollectFleetFlare(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+	response.Path = path
+}
+
+// flareArchive streams a fleet flare, for the agent to attach it to its own flares.
+// example: curl --unix-socket /opt/datadog-packages/run/installer-status.sock -H 'Content-Type: application/json' http://installer/flare
+func (l *localAPIImpl) flareArchive(w http.ResponseWriter, r *http.Request) {
+	log.Infof("Received local request to stream a fleet flare")
+	path, err := l.daemon.CollectFleetFlare(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(APIResponse{Error: &APIError{Message: err.Error()}})
+		return
+	}
+	defer os.Remove(path)
+	w.Header().Set("Content-Type", "application/gzip")
+	http.ServeFile(w, r, path)
+}
+
+// garbageCollect removes the packages and objects no operation uses anymore, without waiting for the periodic collection.
+// example: curl -X POST --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/garbage_collect
+func (l *localAPIImpl) garbageCollect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var response APIResponse
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	log.Infof("Received local request to garbage collect")
+	err := l.daemon.GarbageCollect(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+}
+
+// setChannel switches the host to another release channel of the catalog.
+// example: curl -X POST --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/channel -d '{"channel":"beta"}'
+func (l *localAPIImpl) setChannel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var request SetChannelRequest
+	var response APIResponse
+	defer func() {
+		_ = json.NewEncoder(w).Encode(response)
+	}()
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Error = &APIError{Message: err.Error()}
+		return
+	}
+	log.Infof("Received local request to switch to the %s channel", request.Channel)
+	err = l.daemon.SetChannel(r.Context(), request.Channel)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Error = &APIError{Message: err.Error()}
+		return
 	}
 }
 
 // example: curl -X POST --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/datadog-agent/experiment/start -d '{"version":"1.21.5"}'
+// add "dry_run":true to the body to get the report of what would change without changing anything
 func (l *localAPIImpl) startExperiment(w http.ResponseWriter, r *http.Request) {
 	pkg := mux.Vars(r)["package"]
 	w.Header().Set("Content-Type", "application/json")
 	var request taskWithVersionParams
-	var response APIResponse
+	var response DryRunResponse
 	defer func() {
 		_ = json.NewEncoder(w).Encode(response)
 	}()
@@ -158,6 +514,14 @@ func (l *localAPIImpl) startExperiment(w http.ResponseWriter, r *http.Request) {
 		response.Error = &APIError{Message: err.Error()}
 		return
 	}
+	if request.DryRun {
+		response.Report, err = l.daemon.StartExperimentDryRun(r.Context(), catalogPkg.URL)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			response.Error = &APIError{Message: err.Error()}
+		}
+		return
+	}
 	err = l.daemon.StartExperiment(r.Context(), catalogPkg.URL)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -201,11 +565,12 @@ func (l *localAPIImpl) promoteExperiment(w http.ResponseWriter, r *http.Request)
 }
 
 // example: curl -X POST --unix-socket /opt/datadog-packages/installer.sock -H 'Content-Type: application/json' http://installer/datadog-agent/install -d '{"version":"1.21.5"}'
+// add "dry_run":true to the body to get the report of what would change without changing anything
 func (l *localAPIImpl) install(w http.ResponseWriter, r *http.Request) {
 	pkg := mux.Vars(r)["package"]
 	w.Header().Set("Content-Type", "application/json")
 	var request taskWithVersionParams
-	var response APIResponse
+	var response DryRunResponse
 	defer func() {
 		_ = json.NewEncoder(w).Encode(response)
 	}()
@@ -226,6 +591,15 @@ func (l *localAPIImpl) install(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if request.DryRun {
+		log.Infof("Received local request to check install of package %s version %s", pkg, request.Version)
+		response.Report, err = l.daemon.InstallDryRun(r.Context(), catalogPkg.URL)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			response.Error = &APIError{Message: err.Error()}
+		}
+		return
+	}
 	log.Infof("Received local request to install package %s version %s", pkg, request.Version)
 	err = l.daemon.Install(r.Context(), catalogPkg.URL, request.InstallArgs)
 	if err != nil {
@@ -238,9 +612,18 @@ func (l *localAPIImpl) install(w http.ResponseWriter, r *http.Request) {
 // LocalAPIClient is a client to interact with the locally exposed daemon API.
 type LocalAPIClient interface {
 	Status() (StatusResponse, error)
+	PackageState(pkg string) (repository.State, error)
+	APMInjectionStatus() (APMInjectionStatus, error)
+	Subscribe(ctx context.Context) (<-chan StateEvent, error)
+	Flare() (string, error)
+	Catalog() ([]CatalogPackage, error)
+	GarbageCollect() error
 
+	SetChannel(channel string) error
 	Install(pkg, version string) error
+	InstallDryRun(pkg, version string) (*installer.DryRunReport, error)
 	StartExperiment(pkg, version string) error
+	StartExperimentDryRun(pkg, version string) (*installer.DryRunReport, error)
 	StopExperiment(pkg string) error
 	PromoteExperiment(pkg string) error
 }
@@ -265,6 +648,27 @@ func NewLocalAPIClient(runPath string) LocalAPIClient {
 	}
 }
 
+// StatusClient is a client reading the status of the daemon from its read-only status socket.
+type StatusClient interface {
+	Status() (StatusResponse, error)
+	FlareArchive() ([]byte, error)
+}
+
+// NewStatusClient returns a new StatusClient, which doesn't need to run as root.
+func NewStatusClient(runPath string) StatusClient {
+	return &localAPIClientImpl{
+		addr: "daemon", // this has no meaning when using a unix socket
+		client: &http.Client{
+			Timeout: statusClientTimeout,
+			Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.Dial("unix", filepath.Join(runPath, statusSocketName))
+				},
+			},
+		},
+	}
+}
+
 // Status returns the status of the daemon.
 func (c *localAPIClientImpl) Status() (StatusResponse, error) {
 	var response StatusResponse
@@ -289,6 +693,227 @@ func (c *localAPIClientImpl) Status() (StatusResponse, error) {
 	return response, nil
 }
 
+// PackageState returns the state of an installed package.
+func (c *localAPIClientImpl) PackageState(pkg string) (repository.State, error) {
+	var response PackageStateResponse
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/%s/state", c.addr, pkg), nil)
+	if err != nil {
+		return response.State, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return response.State, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return response.State, err
+	}
+	if response.Error != nil {
+		return response.State, fmt.Errorf("error getting package state: %s", response.Error.Message)
+	}
+	return response.State, nil
+}
+
+// APMInjectionStatus returns the instrumentation status of the APM injection.
+func (c *localAPIClientImpl) APMInjectionStatus() (APMInjectionStatus, error) {
+	var response APMInjectionStatusResponse
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/apm_injection/status", c.addr), nil)
+	if err != nil {
+		return response.APMInjectionStatus, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return response.APMInjectionStatus, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return response.APMInjectionStatus, err
+	}
+	if response.Error != nil {
+		return response.APMInjectionStatus, fmt.Errorf("error getting APM injection status: %s", response.Error.Message)
+	}
+	return response.APMInjectionStatus, nil
+}
+
+// Subscribe returns a channel receiving the state events of the daemon. The channel
+// is closed when the context is cancelled or when the daemon closes the stream.
+func (c *localAPIClientImpl) Subscribe(ctx context.Context) (<-chan StateEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/events", c.addr), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var response APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err == nil && response.Error != nil {
+			return nil, fmt.Errorf("error subscribing to events: %s", response.Error.Message)
+		}
+		return nil, fmt.Errorf("error subscribing to events: unexpected status code %d", resp.StatusCode)
+	}
+	events := make(chan StateEvent, subscriberBufferSize)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var event StateEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				log.Warnf("could not unmarshal state event: %v", err)
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Flare collects a fleet flare and returns its path.
+func (c *localAPIClientImpl) Flare() (string, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/flare", c.addr), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var response FlareResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("error collecting fleet flare: %s", response.Error.Message)
+	}
+	return response.Path, nil
+}
+
+// FlareArchive returns the content of a fleet flare tarball.
+func (c *localAPIClientImpl) FlareArchive() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/flare", c.addr), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the flare may wait for the operation the daemon is running
+	client := *c.client
+	client.Timeout = flareClientTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var response APIResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil || response.Error == nil {
+			return nil, fmt.Errorf("error collecting fleet flare: unexpected status code %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("error collecting fleet flare: %s", response.Error.Message)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Catalog returns the packages of the catalog of the daemon available for the host.
+func (c *localAPIClientImpl) Catalog() ([]CatalogPackage, error) {
+	var response CatalogResponse
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/catalog", c.addr), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("error getting catalog: %s", response.Error.Message)
+	}
+	return response.Packages, nil
+}
+
+// GarbageCollect triggers a garbage collection of the daemon.
+func (c *localAPIClientImpl) GarbageCollect() error {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/garbage_collect", c.addr), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var response APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("error running garbage collection: %s", response.Error.Message)
+	}
+	return nil
+}
+
+// SetChannel switches the host to another release channel.
+func (c *localAPIClientImpl) SetChannel(channel string) error {
+	body, err := json.Marshal(SetChannelRequest{Channel: channel})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/channel", c.addr), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var response APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("error setting channel: %s", response.Error.Message)
+	}
+	return nil
+}
+
 // StartExperiment starts an experiment for a package.
 func (c *localAPIClientImpl) StartExperiment(pkg, version string) error {
 	params := taskWithVersionParams{
@@ -398,3 +1023,55 @@ func (c *localAPIClientImpl) Install(pkg, version string) error {
 	}
 	return nil
 }
+
+// InstallDryRun reports what installing a package with a specific version would change, without changing anything.
+func (c *localAPIClientImpl) InstallDryRun(pkg, version string) (*installer.DryRunReport, error) {
+	report, err := c.dryRun(fmt.Sprintf("http://%s/%s/install", c.addr, pkg), version)
+	if err != nil {
+		return nil, fmt.Errorf("error checking install: %w", err)
+	}
+	return report, nil
+}
+
+// StartExperimentDryRun reports what starting an experiment for a package would change, without changing anything.
+func (c *localAPIClientImpl) StartExperimentDryRun(pkg, version string) (*installer.DryRunReport, error) {
+	report, err := c.dryRun(fmt.Sprintf("http://%s/%s/experiment/start", c.addr, pkg), version)
+	if err != nil {
+		return nil, fmt.Errorf("error checking experiment: %w", err)
+	}
+	return report, nil
+}
+
+func (c *localAPIClientImpl) dryRun(url string, version string) (*installer.DryRunReport, error) {
+	params := taskWithVersionParams{
+		Version: version,
+		DryRun:  true,
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var response DryRunResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, errors.New(response.Error.Message)
+	}
+	if response.Report == nil {
+		return nil, errors.New("no dry run report in the response")
+	}
+	return response.Report, nil
+}
@@ -10,10 +10,16 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
 	"github.com/DataDog/datadog-agent/pkg/version"
 	"github.com/stretchr/testify/assert"
@@ -40,11 +46,21 @@ func (m *testDaemon) Install(ctx context.Context, url string, installArgs []stri
 	return args.Error(0)
 }
 
+func (m *testDaemon) InstallDryRun(ctx context.Context, url string) (*installer.DryRunReport, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(*installer.DryRunReport), args.Error(1)
+}
+
 func (m *testDaemon) StartExperiment(ctx context.Context, url string) error {
 	args := m.Called(ctx, url)
 	return args.Error(0)
 }
 
+func (m *testDaemon) StartExperimentDryRun(ctx context.Context, url string) (*installer.DryRunReport, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(*installer.DryRunReport), args.Error(1)
+}
+
 func (m *testDaemon) StopExperiment(ctx context.Context, pkg string) error {
 	args := m.Called(ctx, pkg)
 	return args.Error(0)
@@ -55,6 +71,41 @@ func (m *testDaemon) PromoteExperiment(ctx context.Context, pkg string) error {
 	return args.Error(0)
 }
 
+func (m *testDaemon) Rollback(ctx context.Context, pkg string) error {
+	args := m.Called(ctx, pkg)
+	return args.Error(0)
+}
+
+func (m *testDaemon) Uninstall(ctx context.Context, pkg string) error {
+	args := m.Called(ctx, pkg)
+	return args.Error(0)
+}
+
+func (m *testDaemon) RotateAPIKey(ctx context.Context, apiKey string) error {
+	args := m.Called(ctx, apiKey)
+	return args.Error(0)
+}
+
+func (m *testDaemon) SetChannel(ctx context.Context, channel string) error {
+	args := m.Called(ctx, channel)
+	return args.Error(0)
+}
+
+func (m *testDaemon) GetChannel() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *testDaemon) GarbageCollect(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *testDaemon) GetCatalog() []Package {
+	args := m.Called()
+	return args.Get(0).([]Package)
+}
+
 func (m *testDaemon) GetPackage(pkg string, version string) (Package, error) {
 	args := m.Called(pkg, version)
 	return args.Get(0).(Package), args.Error(1)
@@ -65,11 +116,31 @@ func (m *testDaemon) GetState() (map[string]repository.State, error) {
 	return args.Get(0).(map[string]repository.State), args.Error(1)
 }
 
+func (m *testDaemon) GetRedactedEnv() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
 func (m *testDaemon) GetAPMInjectionStatus() (APMInjectionStatus, error) {
 	args := m.Called()
 	return args.Get(0).(APMInjectionStatus), args.Error(1)
 }
 
+func (m *testDaemon) GetDaemonStatus() DaemonStatus {
+	args := m.Called()
+	return args.Get(0).(DaemonStatus)
+}
+
+func (m *testDaemon) Subscribe() (<-chan StateEvent, func()) {
+	args := m.Called()
+	return args.Get(0).(<-chan StateEvent), args.Get(1).(func())
+}
+
+func (m *testDaemon) CollectFleetFlare(ctx context.Context) (string, error) {
+	args := m.Called(ctx)
+	return args.String(0), args.Error(1)
+}
+
 type testLocalAPI struct {
 	i *testDaemon
 	s *localAPIImpl
@@ -109,6 +180,19 @@ func TestAPIStatus(t *testing.T) {
 	}
 	api.i.On("GetState").Return(installerState, nil)
 	api.i.On("GetAPMInjectionStatus").Return(APMInjectionStatus{}, nil)
+	api.i.On("GetRedactedEnv").Return([]string{"DD_SITE=datadoghq.com"})
+	api.i.On("GetChannel").Return(channelBeta)
+	daemonStatus := DaemonStatus{
+		StartTime:        time.Now().Add(-time.Hour).UTC(),
+		LastGC:           time.Now().UTC(),
+		CatalogUpdatedAt: time.Now().UTC(),
+		CatalogPackages:  3,
+		LastRemoteRequest: &TaskRecord{
+			TaskState: TaskState{ID: "request-1", Package: "pkg1", State: "DONE"},
+			Time:      time.Now().UTC(),
+		},
+	}
+	api.i.On("GetDaemonStatus").Return(daemonStatus)
 
 	resp, err := api.c.Status()
 
@@ -116,6 +200,115 @@ func TestAPIStatus(t *testing.T) {
 	assert.Nil(t, resp.Error)
 	assert.Equal(t, version.AgentVersion, resp.Version)
 	assert.Equal(t, installerState, resp.Packages)
+	assert.Equal(t, []string{"DD_SITE=datadoghq.com"}, resp.Env)
+	assert.Equal(t, channelBeta, resp.Channel)
+	assert.Equal(t, daemonStatus, resp.Daemon)
+}
+
+func TestAPIStatusSocket(t *testing.T) {
+	d := &testDaemon{}
+	runPath, err := os.MkdirTemp("", "installer")
+	require.NoError(t, err)
+	defer os.RemoveAll(runPath)
+	api, err := NewLocalAPI(d, runPath)
+	require.NoError(t, err)
+	require.NoError(t, api.Start(context.Background()))
+	defer api.Stop(context.Background())
+
+	// the status socket is only writable by root and the dd-agent group
+	info, err := os.Stat(filepath.Join(runPath, statusSocketName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0007)
+
+	d.On("GetState").Return(map[string]repository.State{"pkg1": {Stable: "1.0.0"}}, nil)
+	d.On("GetAPMInjectionStatus").Return(APMInjectionStatus{}, nil)
+	d.On("GetRedactedEnv").Return([]string{})
+	d.On("GetChannel").Return(channelStable)
+	d.On("GetDaemonStatus").Return(DaemonStatus{})
+	resp, err := NewStatusClient(runPath).Status()
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", resp.Packages["pkg1"].Stable)
+
+	// the fleet flares are streamed to the agent
+	flarePath := filepath.Join(t.TempDir(), "flare.tar.gz")
+	require.NoError(t, os.WriteFile(flarePath, []byte("flare content"), 0600))
+	d.On("CollectFleetFlare", mock.Anything).Return(flarePath, nil).Once()
+	archive, err := NewStatusClient(runPath).FlareArchive()
+	require.NoError(t, err)
+	assert.Equal(t, "flare content", string(archive))
+	assert.NoFileExists(t, flarePath)
+
+	// the operations of the daemon aren't served on the status socket
+	statusClient := NewStatusClient(runPath).(*localAPIClientImpl)
+	req, err := http.NewRequest(http.MethodPost, "http://daemon/channel", strings.NewReader(`{"channel":"beta"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	httpResp, err := statusClient.client.Do(req)
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+	d.AssertNotCalled(t, "SetChannel", mock.Anything, mock.Anything)
+}
+
+func TestAPISetChannel(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	api.i.On("SetChannel", mock.Anything, channelBeta).Return(nil).Once()
+	assert.NoError(t, api.c.SetChannel(channelBeta))
+
+	api.i.On("SetChannel", mock.Anything, "alpha").Return(errors.New(`unknown channel "alpha"`)).Once()
+	assert.EqualError(t, api.c.SetChannel("alpha"), `error setting channel: unknown channel "alpha"`)
+	api.i.AssertExpectations(t)
+}
+
+func TestAPIPackageState(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	api.i.On("GetState").Return(map[string]repository.State{
+		"pkg1": {Stable: "1.0.0", Experiment: "2.0.0"},
+	}, nil)
+
+	state, err := api.c.PackageState("pkg1")
+	assert.NoError(t, err)
+	assert.Equal(t, repository.State{Stable: "1.0.0", Experiment: "2.0.0"}, state)
+
+	_, err = api.c.PackageState("pkg2")
+	assert.EqualError(t, err, "error getting package state: package pkg2 is not installed")
+}
+
+func TestAPIAPMInjectionStatus(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	apmStatus := APMInjectionStatus{HostInstrumented: true, DockerInstalled: true}
+	api.i.On("GetAPMInjectionStatus").Return(apmStatus, nil)
+
+	status, err := api.c.APMInjectionStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, apmStatus, status)
+}
+
+func TestAPICatalog(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	stable := Package{Name: "test-package", Version: "1.0.0", URL: "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"}
+	experiment := Package{Name: "test-package", Version: "2.0.0", URL: "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"}
+	available := Package{Name: "other-package", Version: "1.0.0", URL: "oci://example.com/other-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"}
+	api.i.On("GetState").Return(map[string]repository.State{
+		"test-package": {Stable: "1.0.0", Experiment: "2.0.0"},
+	}, nil)
+	api.i.On("GetCatalog").Return([]Package{stable, experiment, available})
+
+	packages, err := api.c.Catalog()
+	assert.NoError(t, err)
+	assert.Equal(t, []CatalogPackage{
+		{Package: stable, Stable: true},
+		{Package: experiment, Experiment: true},
+		{Package: available},
+	}, packages)
 }
 
 func TestAPIInstall(t *testing.T) {
@@ -152,6 +345,34 @@ func TestAPIStartExperiment(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAPIDryRun(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	testPackage := Package{
+		Name:    "test-package",
+		Version: "1.0.0",
+		URL:     "oci://example.com/test-package@5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+	}
+	report := &installer.DryRunReport{
+		Package: testPackage.Name,
+		Version: testPackage.Version,
+		Changes: []string{"install test-package 1.0.0"},
+	}
+	api.i.On("GetPackage", testPackage.Name, testPackage.Version).Return(testPackage, nil)
+	api.i.On("InstallDryRun", mock.Anything, testPackage.URL).Return(report, nil).Once()
+	api.i.On("StartExperimentDryRun", mock.Anything, testPackage.URL).Return((*installer.DryRunReport)(nil), errors.New("no stable version")).Once()
+
+	installReport, err := api.c.InstallDryRun(testPackage.Name, testPackage.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, report, installReport)
+
+	_, err = api.c.StartExperimentDryRun(testPackage.Name, testPackage.Version)
+	assert.EqualError(t, err, "error checking experiment: no stable version")
+	api.i.AssertNotCalled(t, "Install", mock.Anything, mock.Anything, mock.Anything)
+	api.i.AssertNotCalled(t, "StartExperiment", mock.Anything, mock.Anything)
+}
+
 func TestAPIStopExperiment(t *testing.T) {
 	api := newTestLocalAPI(t)
 	defer api.Stop()
@@ -175,3 +396,57 @@ func TestAPIPromoteExperiment(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestAPIEvents(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	events := make(chan StateEvent, 1)
+	unsubscribed := make(chan struct{})
+	api.i.On("Subscribe").Return((<-chan StateEvent)(events), func() { close(unsubscribed) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received, err := api.c.Subscribe(ctx)
+	require.NoError(t, err)
+
+	event := StateEvent{
+		Packages: map[string]repository.State{"pkg1": {Stable: "1.0.0", Experiment: "2.0.0"}},
+		Task:     &TaskState{ID: "test-request-1", Package: "pkg1", State: "DONE"},
+	}
+	events <- event
+	assert.Equal(t, event, <-received)
+
+	cancel()
+	<-unsubscribed
+	_, ok := <-received
+	assert.False(t, ok)
+}
+
+func TestAPIFlare(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	api.i.On("CollectFleetFlare", mock.Anything).Return("/tmp/datadog-fleet-flare-1.tar.gz", nil)
+
+	path, err := api.c.Flare()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/datadog-fleet-flare-1.tar.gz", path)
+}
+
+func TestAPIGarbageCollect(t *testing.T) {
+	api := newTestLocalAPI(t)
+	defer api.Stop()
+
+	// the local requests are recorded as such in the audit log
+	requestedLocally := mock.MatchedBy(func(ctx context.Context) bool {
+		requester, _ := auditRequester(ctx)
+		return requester == requesterLocalAPI
+	})
+	api.i.On("GarbageCollect", requestedLocally).Return(nil).Once()
+	assert.NoError(t, api.c.GarbageCollect())
+
+	api.i.On("GarbageCollect", mock.Anything).Return(errors.New("disk full")).Once()
+	assert.EqualError(t, api.c.GarbageCollect(), "error running garbage collection: disk full")
+	api.i.AssertExpectations(t)
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// loadLocalCatalog reads the catalog mirrored on disk at the given path, for hosts without remote
+// config connectivity. The path is either a catalog file or a directory whose JSON files are merged.
+// Catalogs have the same format as the ones received from remote config and are validated the same way.
+func loadLocalCatalog(path string) (catalog, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return catalog{}, fmt.Errorf("could not read local catalog: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return catalog{}, fmt.Errorf("could not list local catalog files: %w", err)
+		}
+		sort.Strings(files)
+	}
+
+	var mergedCatalog catalog
+	for _, file := range files {
+		rawCatalog, err := os.ReadFile(file)
+		if err != nil {
+			return catalog{}, fmt.Errorf("could not read local catalog: %w", err)
+		}
+		var c catalog
+		err = json.Unmarshal(rawCatalog, &c)
+		if err != nil {
+			return catalog{}, fmt.Errorf("could not unmarshal local catalog %s: %w", file, err)
+		}
+		for _, p := range c.Packages {
+			err := validatePackage(p)
+			if err != nil {
+				return catalog{}, fmt.Errorf("invalid package in local catalog %s: %w", file, err)
+			}
+		}
+		mergedCatalog.Packages = append(mergedCatalog.Packages, c.Packages...)
+	}
+	return mergedCatalog, nil
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+func writeTestCatalog(t *testing.T, path string, c catalog) {
+	rawCatalog, err := json.Marshal(c)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, rawCatalog, 0644))
+}
+
+func TestLoadLocalCatalog(t *testing.T) {
+	agent := Package{Name: "datadog-agent", Version: "7.56.0", URL: "file:///mirror/datadog-agent/7.56.0"}
+	injector := Package{Name: "datadog-apm-inject", Version: "0.20.0", URL: "file:///mirror/datadog-apm-inject/0.20.0"}
+
+	dir := t.TempDir()
+	writeTestCatalog(t, filepath.Join(dir, "agent.json"), catalog{Packages: []Package{agent}})
+	writeTestCatalog(t, filepath.Join(dir, "injector.json"), catalog{Packages: []Package{injector}})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a catalog"), 0644))
+
+	// a single file
+	c, err := loadLocalCatalog(filepath.Join(dir, "agent.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []Package{agent}, c.Packages)
+
+	// the JSON files of a directory are merged
+	c, err = loadLocalCatalog(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []Package{agent, injector}, c.Packages)
+
+	_, err = loadLocalCatalog(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	writeTestCatalog(t, filepath.Join(dir, "invalid.json"), catalog{Packages: []Package{{Name: "datadog-agent", Version: "7.57.0"}}})
+	_, err = loadLocalCatalog(dir)
+	assert.Error(t, err)
+}
+
+func TestLocalCatalog(t *testing.T) {
+	localPackage := Package{
+		Name:     "datadog-agent",
+		Version:  "7.56.0",
+		URL:      "file:///mirror/datadog-agent/7.56.0",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	catalogPath := filepath.Join(t.TempDir(), "catalog.json")
+	writeTestCatalog(t, catalogPath, catalog{Packages: []Package{localPackage}})
+
+	i := newTestInstallerWithEnv(&env.Env{CatalogPath: catalogPath})
+	defer i.Stop()
+
+	p, err := i.GetPackage("datadog-agent", "7.56.0")
+	require.NoError(t, err)
+	assert.Equal(t, localPackage, p)
+
+	// The packages of the remote catalog are available in addition to the local ones
+	remotePackage := localPackage
+	remotePackage.Version = "7.57.0"
+	remotePackage.URL = "oci://example.com/datadog-agent@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	require.NoError(t, i.handleCatalogUpdate(catalog{Packages: []Package{remotePackage}}))
+	_, err = i.GetPackage("datadog-agent", "7.56.0")
+	assert.NoError(t, err)
+	_, err = i.GetPackage("datadog-agent", "7.57.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []Package{remotePackage, localPackage}, i.GetCatalog())
+
+	// The packages of the remote catalog shadow the local ones
+	shadowingPackage := localPackage
+	shadowingPackage.URL = "oci://example.com/datadog-agent@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+	require.NoError(t, i.handleCatalogUpdate(catalog{Packages: []Package{shadowingPackage}}))
+	assert.Equal(t, []Package{shadowingPackage}, i.GetCatalog())
+}
@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+var (
+	// maintenanceWindowRecheckInterval is the interval at which the windows are evaluated again
+	// while a request waits for the next one
+	maintenanceWindowRecheckInterval = time.Minute
+	// timeNow returns the wall clock time the windows are evaluated at, it's overridden in tests
+	timeNow = time.Now
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maintenanceWindow is a recurring window during which remote requests are executed.
+type maintenanceWindow struct {
+	// days are the days the window starts on
+	days [7]bool
+	// start and end are offsets from midnight, the window ends the next day if end isn't after start
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// parseMaintenanceWindow parses a maintenance window formatted as `[days] HH:MM-HH:MM [timezone]`,
+// for instance `Sat 02:00-06:00 UTC` or `Mon-Fri 22:00-01:00 Europe/Paris`. Days are a comma
+// separated list of days or day ranges, the window applies every day if they are omitted. The
+// timezone is an IANA timezone name and defaults to UTC.
+func parseMaintenanceWindow(s string) (maintenanceWindow, error) {
+	w := maintenanceWindow{location: time.UTC}
+	fields := strings.Fields(s)
+	hours := -1
+	for i, field := range fields {
+		if strings.Contains(field, ":") {
+			hours = i
+			break
+		}
+	}
+	if hours < 0 || hours > 1 || len(fields)-hours > 2 {
+		return w, fmt.Errorf("invalid maintenance window %q, expected [days] HH:MM-HH:MM [timezone]", s)
+	}
+
+	if hours == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return w, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+		}
+		w.days = days
+	}
+
+	start, end, found := strings.Cut(fields[hours], "-")
+	if !found {
+		return w, fmt.Errorf("invalid maintenance window %q: invalid hours %q", s, fields[hours])
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(start); err != nil {
+		return w, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+	}
+	if w.end, err = parseTimeOfDay(end); err != nil {
+		return w, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+	}
+
+	if len(fields) > hours+1 {
+		w.location, err = time.LoadLocation(fields[hours+1])
+		if err != nil {
+			return w, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+		}
+	}
+	return w, nil
+}
+
+// parseWeekdays parses a comma separated list of days or day ranges, such as `Mon-Fri,Sun`
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := weekdays[strings.ToLower(first)]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[strings.ToLower(last)]; !ok {
+				return days, fmt.Errorf("invalid day %q", last)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses a HH:MM time and returns its offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// delay returns the delay until the next opening of the window, 0 if it's open at the given time.
+func (w maintenanceWindow) delay(now time.Time) time.Duration {
+	now = now.In(w.location)
+	next := time.Duration(-1)
+	// the window may have started the day before, and starts at least once a week
+	for offset := -1; offset <= 7; offset++ {
+		start := w.at(now, offset, w.start)
+		if !w.days[start.Weekday()] {
+			continue
+		}
+		end := w.at(now, offset, w.end)
+		if w.end <= w.start {
+			end = w.at(now, offset+1, w.end)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return 0
+		}
+		if start.After(now) && (next < 0 || start.Sub(now) < next) {
+			next = start.Sub(now)
+		}
+	}
+	return next
+}
+
+// at returns the time of the day shifted by the given number of days from now, in wall clock time
+func (w maintenanceWindow) at(now time.Time, days int, timeOfDay time.Duration) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()+days, 0, 0, int(timeOfDay/time.Second), 0, w.location)
+}
+
+// maintenanceWindowsDelay returns the delay until the next opening of any of the windows, 0 if
+// one of them is open at the given time or if there are no windows.
+func maintenanceWindowsDelay(now time.Time, windows []maintenanceWindow) time.Duration {
+	var next time.Duration
+	for i, w := range windows {
+		delay := w.delay(now)
+		if i == 0 || delay < next {
+			next = delay
+		}
+	}
+	return next
+}
+
+// maintenanceDelay returns the delay until remote requests can be executed: the end of the blackout if
+// it's ongoing at the given time, else the delay until the next opening of any of the windows.
+func maintenanceDelay(now time.Time, blackout env.MaintenanceBlackout, windows []maintenanceWindow) time.Duration {
+	if !blackout.End.IsZero() && !now.Before(blackout.Start) && now.Before(blackout.End) {
+		return blackout.End.Sub(now)
+	}
+	return maintenanceWindowsDelay(now, windows)
+}
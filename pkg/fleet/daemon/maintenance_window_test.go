@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	w, err := parseMaintenanceWindow("Sat 02:00-06:00 UTC")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{time.Saturday: true}, w.days)
+	assert.Equal(t, 2*time.Hour, w.start)
+	assert.Equal(t, 6*time.Hour, w.end)
+	assert.Equal(t, time.UTC, w.location)
+
+	w, err = parseMaintenanceWindow("fri-mon,wed 22:30-01:00 Europe/Paris")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, true, false, true, true}, w.days)
+	assert.Equal(t, 22*time.Hour+30*time.Minute, w.start)
+	assert.Equal(t, time.Hour, w.end)
+	assert.Equal(t, "Europe/Paris", w.location.String())
+
+	w, err = parseMaintenanceWindow("02:00-06:00")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, true, true, true, true, true}, w.days)
+	assert.Equal(t, time.UTC, w.location)
+
+	for _, invalid := range []string{
+		"",
+		"Sat",
+		"Sat 02:00",
+		"Sat 02:00-25:00",
+		"Sat-Foo 02:00-06:00",
+		"Sat 02:00-06:00 Mars/Olympus",
+		"Sat Sun 02:00-06:00",
+		"Sat 02:00-06:00 UTC extra",
+	} {
+		_, err := parseMaintenanceWindow(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMaintenanceWindowDelay(t *testing.T) {
+	saturday, err := parseMaintenanceWindow("Sat 02:00-06:00 UTC")
+	require.NoError(t, err)
+	overnight, err := parseMaintenanceWindow("Sun 22:00-01:00 UTC")
+	require.NoError(t, err)
+
+	// 2024-06-15 is a Saturday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2024, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	assert.Equal(t, time.Duration(0), saturday.delay(at(15, 2, 0)))
+	assert.Equal(t, time.Duration(0), saturday.delay(at(15, 5, 59)))
+	assert.Equal(t, 30*time.Minute, saturday.delay(at(15, 1, 30)))
+	assert.Equal(t, 7*24*time.Hour-4*time.Hour, saturday.delay(at(15, 6, 0)))
+	assert.Equal(t, 24*time.Hour, saturday.delay(at(14, 2, 0)))
+
+	assert.Equal(t, time.Duration(0), overnight.delay(at(16, 23, 0)))
+	assert.Equal(t, time.Duration(0), overnight.delay(at(17, 0, 30)))
+	assert.Equal(t, 7*24*time.Hour-3*time.Hour, overnight.delay(at(17, 1, 0)))
+
+	windows := []maintenanceWindow{saturday, overnight}
+	assert.Equal(t, time.Duration(0), maintenanceWindowsDelay(at(16, 22, 0), windows))
+	assert.Equal(t, 16*time.Hour, maintenanceWindowsDelay(at(16, 6, 0), windows))
+	assert.Equal(t, time.Duration(0), maintenanceWindowsDelay(at(16, 6, 0), nil))
+}
+
+func TestMaintenanceWindowDelayTimezone(t *testing.T) {
+	w, err := parseMaintenanceWindow("Sat 02:00-06:00 America/New_York")
+	require.NoError(t, err)
+
+	// 02:00 in New York is 06:00 UTC during daylight saving time
+	assert.Equal(t, time.Duration(0), w.delay(time.Date(2024, time.June, 15, 6, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Hour, w.delay(time.Date(2024, time.June, 15, 5, 0, 0, 0, time.UTC)))
+}
+
+func TestMaintenanceWindowDelayClockSkew(t *testing.T) {
+	w, err := parseMaintenanceWindow("Sat 02:00-06:00 UTC")
+	require.NoError(t, err)
+
+	// the delay only depends on the wall clock it's evaluated at, whichever way the clock jumped
+	// since the previous evaluation. 2024-06-15 is a Saturday.
+	now := time.Date(2024, time.June, 15, 1, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		skew  time.Duration
+		delay time.Duration
+	}{
+		{0, time.Hour},
+		{-time.Hour, 2 * time.Hour},
+		{-24 * time.Hour, 25 * time.Hour},
+		{-7 * 24 * time.Hour, time.Hour},
+		{3 * time.Hour, 0},
+		{5 * time.Hour, 7*24*time.Hour - 4*time.Hour},
+		{7 * 24 * time.Hour, time.Hour},
+	} {
+		assert.Equal(t, tt.delay, w.delay(now.Add(tt.skew)), "skew %s", tt.skew)
+	}
+}
+
+func TestMaintenanceDelayBlackout(t *testing.T) {
+	saturday, err := parseMaintenanceWindow("Sat 02:00-06:00 UTC")
+	require.NoError(t, err)
+	windows := []maintenanceWindow{saturday}
+
+	// 2024-06-15 is a Saturday, the blackout covers its window
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, time.June, day, hour, 0, 0, 0, time.UTC)
+	}
+	blackout := env.MaintenanceBlackout{Start: at(14, 0), End: at(15, 4)}
+	assert.Equal(t, 2*time.Hour, maintenanceDelay(at(15, 2), blackout, windows))
+	assert.Equal(t, 5*time.Hour, maintenanceDelay(at(14, 23), blackout, windows))
+	// the windows apply again once it ended
+	assert.Equal(t, time.Duration(0), maintenanceDelay(at(15, 4), blackout, windows))
+	assert.Equal(t, 24*time.Hour, maintenanceDelay(at(14, 2), env.MaintenanceBlackout{}, windows))
+
+	// without windows, the requests are only held during the blackout
+	assert.Equal(t, time.Hour, maintenanceDelay(at(15, 3), blackout, nil))
+	assert.Equal(t, time.Duration(0), maintenanceDelay(at(13, 23), blackout, nil))
+
+	// a blackout without start starts immediately
+	assert.Equal(t, time.Hour, maintenanceDelay(at(15, 3), env.MaintenanceBlackout{End: at(15, 4)}, nil))
+}
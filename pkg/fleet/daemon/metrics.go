@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Metrics sent by the daemon to the dogstatsd server of the local agent, so that the health of the
+// upgrades can be followed across a fleet
+const (
+	metricRequestDuration    = "datadog.installer.daemon.request.duration"
+	metricRequestErrors      = "datadog.installer.daemon.request.errors"
+	metricDownloadBytes      = "datadog.installer.daemon.download.bytes"
+	metricExperimentDuration = "datadog.installer.daemon.experiment.duration"
+	metricGCReclaimedBytes   = "datadog.installer.daemon.gc.reclaimed_bytes"
+	metricDowngrades         = "datadog.installer.daemon.downgrades"
+)
+
+// packagesDiskAvailable returns the disk space available for the packages, it's overridden in tests
+var packagesDiskAvailable = func() (uint64, error) {
+	usage, err := filesystem.NewDisk().GetUsage(installer.PackagesPath)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Available, nil
+}
+
+// reportRemoteAPIRequest sends the duration and the error code of a handled remote request
+func (d *daemonImpl) reportRemoteAPIRequest(request remoteAPIRequest, duration time.Duration, err error) {
+	tags := []string{"package:" + request.Package, "method:" + request.Method, "status:success"}
+	if err != nil {
+		tags[2] = "status:error"
+		code := installerErrors.From(err).Code()
+		d.sendMetric(d.statsd.Count(metricRequestErrors, 1, append(tags, fmt.Sprintf("error_code:%d", code)), 1))
+	}
+	d.sendMetric(d.statsd.Distribution(metricRequestDuration, duration.Seconds(), tags, 1))
+}
+
+// reportDownload sends the bytes actually downloaded to install an experiment, as recorded in the
+// usage of the package. Layers served from the cache or the local filesystem aren't counted.
+func (d *daemonImpl) reportDownload(pkg string, version string) {
+	states, err := d.installer.States()
+	if err != nil {
+		return
+	}
+	state, ok := states[pkg]
+	if !ok || state.Usage == nil {
+		return
+	}
+	history := state.Usage.History
+	for i := len(history) - 1; i >= 0; i-- {
+		op := history[i]
+		if op.Operation != "install_experiment" || op.Version != version {
+			continue
+		}
+		if op.BytesDownloaded > 0 {
+			tags := []string{"package:" + pkg, "version:" + version}
+			d.sendMetric(d.statsd.Distribution(metricDownloadBytes, float64(op.BytesDownloaded), tags, 1))
+		}
+		return
+	}
+}
+
+// reportDowngrade sends the downgrades allowed by remote requests, to audit them across a fleet. The
+// requests themselves are recorded in the audit log, their IDs would be unbounded tags.
+func (d *daemonImpl) reportDowngrade(request remoteAPIRequest, from string, to string) {
+	tags := []string{"package:" + request.Package, "from_version:" + from, "to_version:" + to}
+	d.sendMetric(d.statsd.Count(metricDowngrades, 1, tags, 1))
+}
+
+// trackExperiment records the start of an experiment, to report its duration once it's promoted or stopped
+func (d *daemonImpl) trackExperiment(pkg string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.experimentStarts[pkg] = time.Now()
+}
+
+// reportExperimentEnd sends the duration of an experiment. Experiments started before a restart of
+// the daemon aren't tracked.
+func (d *daemonImpl) reportExperimentEnd(pkg string, outcome string) {
+	d.m.Lock()
+	start, ok := d.experimentStarts[pkg]
+	delete(d.experimentStarts, pkg)
+	d.m.Unlock()
+	if !ok {
+		return
+	}
+	tags := []string{"package:" + pkg, "outcome:" + outcome}
+	d.sendMetric(d.statsd.Distribution(metricExperimentDuration, time.Since(start).Seconds(), tags, 1))
+}
+
+// reportReclaimedBytes sends the disk space reclaimed by a garbage collection, measured as the
+// space made available on the disk of the packages since it started
+func (d *daemonImpl) reportReclaimedBytes(availableBefore uint64) {
+	availableAfter, err := packagesDiskAvailable()
+	if err != nil || availableAfter <= availableBefore {
+		return
+	}
+	d.sendMetric(d.statsd.Count(metricGCReclaimedBytes, int64(availableAfter-availableBefore), nil, 1))
+}
+
+func (d *daemonImpl) sendMetric(err error) {
+	if err != nil {
+		log.Debugf("Daemon: could not send metric: %v", err)
+	}
+}
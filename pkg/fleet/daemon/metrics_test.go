@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+type testMetric struct {
+	name  string
+	value float64
+	tags  []string
+}
+
+// testStatsd records the metrics sent by the daemon
+type testStatsd struct {
+	statsd.NoOpClient
+	m       sync.Mutex
+	metrics []testMetric
+}
+
+func (s *testStatsd) Count(name string, value int64, tags []string, _ float64) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.metrics = append(s.metrics, testMetric{name: name, value: float64(value), tags: tags})
+	return nil
+}
+
+func (s *testStatsd) Distribution(name string, value float64, tags []string, _ float64) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.metrics = append(s.metrics, testMetric{name: name, value: value, tags: tags})
+	return nil
+}
+
+func (s *testStatsd) get(name string) []testMetric {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var metrics []testMetric
+	for _, m := range s.metrics {
+		if m.name == name {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+func TestRemoteRequestMetrics(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+	metrics := &testStatsd{}
+	i.statsd = metrics
+
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Size:     1024,
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
+
+	// the downloaded bytes are read from the usage recorded by the installer, not the catalog
+	i.m.Lock()
+	i.pm.ExpectedCalls = nil
+	i.pm.On("States").Return(map[string]repository.State{
+		testExperimentPackage.Name: {
+			Stable: "0.0.1",
+			Usage: &repository.PackageUsage{History: []repository.OperationUsage{
+				{Operation: "install_experiment", Version: "1.0.0", BytesDownloaded: 512},
+			}},
+		},
+	}, nil)
+	i.m.Unlock()
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1"}, nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, testExperimentPackage.URL).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1"},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	i.pm.On("State", testExperimentPackage.Name).Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, testExperimentPackage.Name).Return(nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-2",
+		Method:        methodPromoteExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1", Experiment: "1.0.0"},
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+
+	durations := metrics.get(metricRequestDuration)
+	require.Len(t, durations, 2)
+	assert.Equal(t, []string{"package:test-package", "method:start_experiment", "status:success"}, durations[0].tags)
+	assert.Equal(t, []string{"package:test-package", "method:promote_experiment", "status:success"}, durations[1].tags)
+	assert.Empty(t, metrics.get(metricRequestErrors))
+
+	downloads := metrics.get(metricDownloadBytes)
+	require.Len(t, downloads, 1)
+	assert.Equal(t, float64(512), downloads[0].value)
+	assert.Equal(t, []string{"package:test-package", "version:1.0.0"}, downloads[0].tags)
+
+	experiments := metrics.get(metricExperimentDuration)
+	require.Len(t, experiments, 1)
+	assert.Equal(t, []string{"package:test-package", "outcome:promoted"}, experiments[0].tags)
+}
+
+func TestRemoteRequestErrorMetrics(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+	metrics := &testStatsd{}
+	i.statsd = metrics
+
+	i.pm.On("State", "test-package").Return(repository.State{Stable: "0.0.1", Experiment: "1.0.0"}, nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "test-package").Return(installerErrors.Wrap(installerErrors.ErrInvalidState, errors.New("no experiment"))).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodPromoteExperiment,
+		Package:       "test-package",
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: "0.0.1", Experiment: "1.0.0"},
+	})
+	i.requestsWG.Wait()
+	i.pm.AssertExpectations(t)
+
+	errs := metrics.get(metricRequestErrors)
+	require.Len(t, errs, 1)
+	assert.Equal(t, []string{"package:test-package", "method:promote_experiment", "status:error", "error_code:4"}, errs[0].tags)
+	durations := metrics.get(metricRequestDuration)
+	require.Len(t, durations, 1)
+	assert.Equal(t, []string{"package:test-package", "method:promote_experiment", "status:error"}, durations[0].tags)
+	// the experiment wasn't started by the daemon, its duration is unknown
+	assert.Empty(t, metrics.get(metricExperimentDuration))
+}
+
+func TestGarbageCollectMetrics(t *testing.T) {
+	i := newTestInstaller()
+	defer i.Stop()
+	metrics := &testStatsd{}
+	i.statsd = metrics
+
+	available := uint64(1000)
+	defer func(f func() (uint64, error)) { packagesDiskAvailable = f }(packagesDiskAvailable)
+	packagesDiskAvailable = func() (uint64, error) { return available, nil }
+	i.pm.On("GarbageCollect", mock.Anything).Run(func(mock.Arguments) { available += 500 }).Return(nil).Once()
+
+	require.NoError(t, i.GarbageCollect(context.Background()))
+	reclaimed := metrics.get(metricGCReclaimedBytes)
+	require.Len(t, reclaimed, 1)
+	assert.Equal(t, float64(500), reclaimed[0].value)
+}
@@ -0,0 +1,304 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// defaultNotificationTimeout bounds each delivery of a notification if no timeout is set
+	defaultNotificationTimeout = 10 * time.Second
+	// notificationQueueSize is the number of notifications waiting to be delivered before new ones
+	// are dropped
+	notificationQueueSize = 64
+)
+
+// Types of the lifecycle events notified
+const (
+	eventInstallStarted     = "install_started"
+	eventInstallSucceeded   = "install_succeeded"
+	eventInstallFailed      = "install_failed"
+	eventExperimentStarted  = "experiment_started"
+	eventExperimentPromoted = "experiment_promoted"
+	eventExperimentStopped  = "experiment_stopped"
+	// eventExperimentFailed is notified when starting, promoting or stopping an experiment fails
+	eventExperimentFailed = "experiment_failed"
+)
+
+// LifecycleEvent is a change of the packages notified to the external orchestration.
+type LifecycleEvent struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// Package is empty for the operations on a package URL, as it isn't known until it's downloaded
+	Package   string `json:"package,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Requester string `json:"requester"`
+	RequestID string `json:"request_id,omitempty"`
+	Hostname  string `json:"hostname"`
+	Error     string `json:"error,omitempty"`
+}
+
+// notificationSink delivers the lifecycle events outside of the daemon.
+type notificationSink interface {
+	fmt.Stringer
+	send(ctx context.Context, event LifecycleEvent) error
+}
+
+// httpNotificationSink posts the events as JSON to a URL, which must answer with a 2xx status.
+type httpNotificationSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpNotificationSink) String() string {
+	return "http:" + s.url
+}
+
+func (s *httpNotificationSink) send(ctx context.Context, event LifecycleEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered with status %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// eventsNotificationSink sends the events as Datadog events through the dogstatsd server of the local agent
+type eventsNotificationSink struct {
+	statsd statsd.ClientInterface
+}
+
+func (s *eventsNotificationSink) String() string {
+	return "datadog_events"
+}
+
+func (s *eventsNotificationSink) send(_ context.Context, event LifecycleEvent) error {
+	subject := event.Package
+	if subject == "" {
+		subject = event.URL
+	}
+	e := statsd.NewEvent(fmt.Sprintf("Fleet %s: %s", event.Type, subject), fmt.Sprintf("%s of %s requested by %s", event.Operation, subject, event.Requester))
+	e.Timestamp = event.Time
+	e.Hostname = event.Hostname
+	e.SourceTypeName = "datadog-installer"
+	e.AggregationKey = event.Operation + ":" + subject
+	e.AlertType = statsd.Info
+	switch event.Type {
+	case eventInstallFailed, eventExperimentFailed:
+		e.AlertType = statsd.Error
+		e.Text += ": " + event.Error
+	case eventInstallSucceeded, eventExperimentPromoted:
+		e.AlertType = statsd.Success
+	}
+	e.Tags = []string{"event_type:" + event.Type, "operation:" + event.Operation, "requester:" + event.Requester}
+	if event.Package != "" {
+		e.Tags = append(e.Tags, "package:"+event.Package)
+	}
+	if event.RequestID != "" {
+		e.Tags = append(e.Tags, "request_id:"+event.RequestID)
+	}
+	return s.statsd.Event(e)
+}
+
+// notifier delivers the lifecycle events to the sinks in the background, so that a slow or
+// unreachable sink never delays the operations on the packages. Events are dropped if they can't
+// be delivered fast enough.
+type notifier struct {
+	webhook *httpNotificationSink
+	events  bool
+	timeout time.Duration
+	// retryBackoff are the delays before retrying a failed delivery
+	retryBackoff []time.Duration
+
+	m        sync.Mutex
+	queue    chan LifecycleEvent
+	done     chan struct{}
+	stopping chan struct{}
+	started  bool
+	closed   bool
+}
+
+// newNotifier returns a notifier posting the events to webhookURL, if set, and sending them as
+// Datadog events if events is set. Failed deliveries are retried after each of the retryBackoff delays.
+func newNotifier(webhookURL string, events bool, timeout time.Duration, retryBackoff []time.Duration) (*notifier, error) {
+	if timeout <= 0 {
+		timeout = defaultNotificationTimeout
+	}
+	n := &notifier{
+		events:       events,
+		timeout:      timeout,
+		retryBackoff: retryBackoff,
+		queue:        make(chan LifecycleEvent, notificationQueueSize),
+		done:         make(chan struct{}),
+		stopping:     make(chan struct{}),
+	}
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return n, fmt.Errorf("invalid notification URL %q", webhookURL)
+		}
+		n.webhook = &httpNotificationSink{url: webhookURL, client: &http.Client{}}
+	}
+	return n, nil
+}
+
+// enabled returns whether the events are delivered to at least one sink
+func (n *notifier) enabled() bool {
+	return n.webhook != nil || n.events
+}
+
+// start delivers the events queued and to come, the Datadog events are sent with statsdClient
+func (n *notifier) start(statsdClient statsd.ClientInterface) {
+	if !n.enabled() {
+		return
+	}
+	var sinks []notificationSink
+	if n.webhook != nil {
+		sinks = append(sinks, n.webhook)
+	}
+	if n.events {
+		sinks = append(sinks, &eventsNotificationSink{statsd: statsdClient})
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	if n.started || n.closed {
+		return
+	}
+	n.started = true
+	go func() {
+		defer close(n.done)
+		for event := range n.queue {
+			n.deliver(event, sinks)
+		}
+	}()
+}
+
+// stop delivers the events already queued and stops the notifier, the events notified after are dropped
+func (n *notifier) stop() {
+	n.m.Lock()
+	if n.closed {
+		n.m.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	// the events queued are delivered without retrying the failed deliveries
+	close(n.stopping)
+	started := n.started
+	n.m.Unlock()
+	if started {
+		<-n.done
+	}
+}
+
+// notify queues an event for delivery, it never blocks
+func (n *notifier) notify(event LifecycleEvent) {
+	if !n.enabled() {
+		return
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- event:
+	default:
+		log.Warnf("Daemon: dropping %s notification, the notification queue is full", event.Type)
+	}
+}
+
+func (n *notifier) deliver(event LifecycleEvent, sinks []notificationSink) {
+	for _, sink := range sinks {
+		err := n.send(sink, event)
+		for _, backoff := range n.retryBackoff {
+			if err == nil || n.isStopping() {
+				break
+			}
+			log.Debugf("Daemon: could not deliver %s notification to %s, retrying in %s: %v", event.Type, sink, backoff, err)
+			select {
+			case <-time.After(backoff):
+				err = n.send(sink, event)
+			case <-n.stopping:
+			}
+		}
+		if err != nil {
+			log.Warnf("Daemon: could not deliver %s notification to %s: %v", event.Type, sink, err)
+		}
+	}
+}
+
+// isStopping returns whether the notifier is stopping, the failed deliveries aren't retried anymore
+func (n *notifier) isStopping() bool {
+	select {
+	case <-n.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *notifier) send(sink notificationSink, event LifecycleEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	return sink.send(ctx, event)
+}
+
+// notify notifies a lifecycle event of a package, requested with ctx.
+func (d *daemonImpl) notify(ctx context.Context, eventType string, operation string, pkg string, url string, err error) {
+	requester, requestID := auditRequester(ctx)
+	event := LifecycleEvent{
+		Type:      eventType,
+		Time:      time.Now(),
+		Operation: operation,
+		Package:   pkg,
+		URL:       url,
+		Requester: requester,
+		RequestID: requestID,
+		Hostname:  d.rolloutHost.hostname,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	d.notifier.notify(event)
+}
+
+// notifyExperiment notifies the outcome of an operation on an experiment, succeededType being the
+// type of the event notified if it succeeded
+func (d *daemonImpl) notifyExperiment(ctx context.Context, succeededType string, operation string, pkg string, url string, err error) {
+	eventType := succeededType
+	if err != nil {
+		eventType = eventExperimentFailed
+	}
+	d.notify(ctx, eventType, operation, pkg, url, err)
+}
@@ -0,0 +1,206 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+// testWebhook records the events posted to it
+type testWebhook struct {
+	*httptest.Server
+	m      sync.Mutex
+	events []LifecycleEvent
+}
+
+func newTestWebhook(t *testing.T, status int) *testWebhook {
+	w := &testWebhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var event LifecycleEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		w.m.Lock()
+		w.events = append(w.events, event)
+		w.m.Unlock()
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *testWebhook) types() []string {
+	w.m.Lock()
+	defer w.m.Unlock()
+	var types []string
+	for _, event := range w.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// testEventsStatsd records the Datadog events sent by the daemon
+type testEventsStatsd struct {
+	statsd.NoOpClient
+	m      sync.Mutex
+	events []*statsd.Event
+}
+
+func (s *testEventsStatsd) Event(e *statsd.Event) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestNewNotifier(t *testing.T) {
+	n, err := newNotifier("http://127.0.0.1:8080/fleet", false, 0, nil)
+	require.NoError(t, err)
+	assert.True(t, n.enabled())
+	assert.Equal(t, defaultNotificationTimeout, n.timeout)
+
+	n, err = newNotifier("", true, time.Second, nil)
+	require.NoError(t, err)
+	assert.True(t, n.enabled())
+
+	n, err = newNotifier("", false, 0, nil)
+	require.NoError(t, err)
+	assert.False(t, n.enabled())
+
+	n, err = newNotifier("127.0.0.1:8080", true, 0, nil)
+	assert.Error(t, err)
+	assert.True(t, n.enabled(), "the Datadog events are sent whatever the URL")
+}
+
+func TestNotifierWebhook(t *testing.T) {
+	webhook := newTestWebhook(t, http.StatusOK)
+	n, err := newNotifier(webhook.URL, false, time.Second, nil)
+	require.NoError(t, err)
+
+	// events notified before the start are delivered once started
+	n.notify(LifecycleEvent{Type: eventInstallStarted, Operation: auditInstall})
+	n.start(&statsd.NoOpClient{})
+	n.notify(LifecycleEvent{Type: eventInstallSucceeded, Operation: auditInstall})
+	n.stop()
+	assert.Equal(t, []string{eventInstallStarted, eventInstallSucceeded}, webhook.types())
+
+	// events notified once stopped are dropped
+	n.notify(LifecycleEvent{Type: eventExperimentStarted})
+	n.stop()
+	assert.Len(t, webhook.types(), 2)
+}
+
+// failingSink fails the deliveries until it failed failures times
+type failingSink struct {
+	failures int
+	sent     int
+}
+
+func (s *failingSink) String() string {
+	return "failing sink"
+}
+
+func (s *failingSink) send(_ context.Context, _ LifecycleEvent) error {
+	s.sent++
+	if s.sent <= s.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestNotifierRetry(t *testing.T) {
+	n, err := newNotifier("", true, time.Second, []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond})
+	require.NoError(t, err)
+
+	// the failed deliveries are retried after each delay of the backoff
+	sink := &failingSink{failures: 2}
+	n.deliver(LifecycleEvent{Type: eventInstallStarted}, []notificationSink{sink})
+	assert.Equal(t, 3, sink.sent)
+
+	// and given up on once it's exhausted
+	sink = &failingSink{failures: 10}
+	n.deliver(LifecycleEvent{Type: eventInstallStarted}, []notificationSink{sink})
+	assert.Equal(t, 4, sink.sent)
+
+	// they aren't retried once the notifier is stopping
+	n.stop()
+	sink = &failingSink{failures: 10}
+	n.deliver(LifecycleEvent{Type: eventInstallStarted}, []notificationSink{sink})
+	assert.Equal(t, 1, sink.sent)
+}
+
+func TestNotifierWebhookError(t *testing.T) {
+	webhook := newTestWebhook(t, http.StatusInternalServerError)
+	sink := &httpNotificationSink{url: webhook.URL, client: &http.Client{}}
+
+	err := sink.send(context.Background(), LifecycleEvent{Type: eventInstallStarted})
+	assert.ErrorContains(t, err, "answered with status 500")
+}
+
+func TestEventsNotificationSink(t *testing.T) {
+	s := &testEventsStatsd{}
+	sink := &eventsNotificationSink{statsd: s}
+
+	err := sink.send(context.Background(), LifecycleEvent{
+		Type:      eventExperimentFailed,
+		Operation: auditPromoteExperiment,
+		Package:   "datadog-agent",
+		Requester: requesterRemoteConfig,
+		RequestID: "test-request",
+		Hostname:  "test-host",
+		Error:     "could not promote experiment",
+	})
+	require.NoError(t, err)
+	require.Len(t, s.events, 1)
+	e := s.events[0]
+	assert.Equal(t, "Fleet experiment_failed: datadog-agent", e.Title)
+	assert.Contains(t, e.Text, "could not promote experiment")
+	assert.Equal(t, statsd.Error, e.AlertType)
+	assert.Equal(t, "test-host", e.Hostname)
+	assert.ElementsMatch(t, []string{"event_type:experiment_failed", "operation:promote_experiment", "requester:remote_config", "package:datadog-agent", "request_id:test-request"}, e.Tags)
+}
+
+func TestNotifyLifecycleEvents(t *testing.T) {
+	webhook := newTestWebhook(t, http.StatusOK)
+	i := newTestInstallerWithEnv(&env.Env{Notifications: env.Notifications{URL: webhook.URL}})
+	defer i.Stop()
+
+	testURL := "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	i.pm.On("Install", mock.Anything, testURL, []string(nil)).Return(nil).Once()
+	i.pm.On("PromoteExperiment", mock.Anything, "test-package").Return(errors.New("promote failed")).Once()
+
+	require.NoError(t, i.Install(withAuditRequester(context.Background(), requesterLocalAPI), testURL, nil))
+	require.Error(t, i.PromoteExperiment(context.Background(), "test-package"))
+
+	require.Eventually(t, func() bool {
+		return len(webhook.types()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	webhook.m.Lock()
+	defer webhook.m.Unlock()
+	assert.Equal(t, eventInstallStarted, webhook.events[0].Type)
+	assert.Equal(t, eventInstallSucceeded, webhook.events[1].Type)
+	assert.Equal(t, testURL, webhook.events[1].URL)
+	assert.Equal(t, requesterLocalAPI, webhook.events[1].Requester)
+	assert.Equal(t, eventExperimentFailed, webhook.events[2].Type)
+	assert.Equal(t, auditPromoteExperiment, webhook.events[2].Operation)
+	assert.Equal(t, "test-package", webhook.events[2].Package)
+	assert.Contains(t, webhook.events[2].Error, "promote failed")
+}
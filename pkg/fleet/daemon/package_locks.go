@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"sync"
+)
+
+// packageLocks serializes the operations on each package while letting the operations on
+// different packages run concurrently. Operations that aren't bound to a single package, such
+// as the garbage collection or the rotation of the API key, exclude all the others.
+type packageLocks struct {
+	all sync.RWMutex
+
+	m        sync.Mutex
+	packages map[string]*sync.Mutex
+}
+
+func newPackageLocks() *packageLocks {
+	return &packageLocks{
+		packages: make(map[string]*sync.Mutex),
+	}
+}
+
+// lock locks the given package and returns the function unlocking it. All the packages are
+// locked if the package is empty.
+func (l *packageLocks) lock(pkg string) (unlock func()) {
+	if pkg == "" {
+		return l.lockAll()
+	}
+	l.all.RLock()
+	l.m.Lock()
+	m, ok := l.packages[pkg]
+	if !ok {
+		m = &sync.Mutex{}
+		l.packages[pkg] = m
+	}
+	l.m.Unlock()
+	m.Lock()
+	return func() {
+		m.Unlock()
+		l.all.RUnlock()
+	}
+}
+
+// lockAll locks all the packages and returns the function unlocking them.
+func (l *packageLocks) lockAll() (unlock func()) {
+	l.all.Lock()
+	return l.all.Unlock
+}
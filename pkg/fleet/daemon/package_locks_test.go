@@ -0,0 +1,77 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+// locked calls the lock function in the background, it returns a channel closed once the lock is
+// acquired and the channel of its unlock function
+func locked(lock func() func()) (<-chan struct{}, <-chan func()) {
+	done := make(chan struct{})
+	unlock := make(chan func(), 1)
+	go func() {
+		unlock <- lock()
+		close(done)
+	}()
+	return done, unlock
+}
+
+func assertLocked(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired")
+	}
+}
+
+func assertBlocked(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+		t.Fatal("lock acquired")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPackageLocks(t *testing.T) {
+	l := newPackageLocks()
+
+	unlockAgent := l.lock("datadog-agent")
+
+	// other packages can be locked concurrently
+	done, unlock := locked(func() func() { return l.lock("datadog-apm-inject") })
+	assertLocked(t, done)
+	(<-unlock)()
+
+	// the same package is serialized
+	done, unlock = locked(func() func() { return l.lock("datadog-agent") })
+	assertBlocked(t, done)
+	unlockAgent()
+	assertLocked(t, done)
+	unlockAgent = <-unlock
+
+	// all the packages wait for the locked ones
+	done, unlock = locked(l.lockAll)
+	assertBlocked(t, done)
+	unlockAgent()
+	assertLocked(t, done)
+	unlockAll := <-unlock
+
+	// an empty package locks them all
+	done, unlock = locked(func() func() { return l.lock("") })
+	assertBlocked(t, done)
+	unlockAll()
+	assertLocked(t, done)
+	unlockAll = <-unlock
+
+	done, unlock = locked(func() func() { return l.lock("datadog-apm-inject") })
+	assertBlocked(t, done)
+	unlockAll()
+	assertLocked(t, done)
+	(<-unlock)()
+}
@@ -0,0 +1,171 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// checkPackagesDiskSpace checks the disk space available for a package of the given size, it's overridden in tests
+var checkPackagesDiskSpace = installer.CheckAvailableDiskSpace
+
+// hostMemoryAvailable returns the memory available on the host, it's overridden in tests
+var hostMemoryAvailable = func() (uint64, error) {
+	meminfo, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	return parseMemAvailable(meminfo)
+}
+
+// hostGlibcVersion returns the version of the glibc of the host, it's overridden in tests
+var hostGlibcVersion = func(ctx context.Context) (string, error) {
+	output, err := osexec.CommandContext(ctx, "getconf", "GNU_LIBC_VERSION").Output()
+	if err != nil {
+		return "", err
+	}
+	// e.g. glibc 2.35
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] != "glibc" {
+		return "", fmt.Errorf("unexpected libc version %q", strings.TrimSpace(string(output)))
+	}
+	return fields[1], nil
+}
+
+// hostSystemdRunning returns whether systemd is running on the host, it's overridden in tests
+var hostSystemdRunning = func() bool {
+	// documented way of checking systemd is the init system, see sd_booted(3)
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+// hostOS is the operating system of the host, it's overridden in tests
+var hostOS = runtime.GOOS
+
+// parseMemAvailable returns the MemAvailable value of the content of /proc/meminfo, in bytes
+func parseMemAvailable(meminfo []byte) (uint64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse MemAvailable: %w", err)
+		}
+		return kb * 1024, nil
+	}
+	return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+}
+
+// PreflightError is returned when the host fails the preflight checks of an operation
+type PreflightError struct {
+	Failed []string
+}
+
+// Error returns the error message.
+func (e *PreflightError) Error() string {
+	return "preflight checks failed: " + strings.Join(e.Failed, ", ")
+}
+
+// preflight checks the host can take the install or experiment of the package of the given URL before
+// invoking the installer, so that the operation fails fast instead of leaving a partial install. The
+// checks that can't be run, e.g. the glibc version on musl based distributions, are skipped.
+func (d *daemonImpl) preflight(ctx context.Context, operation string, url string) (err error) {
+	if !d.env.Preflight.Enabled {
+		return nil
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "preflight")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("operation", operation)
+
+	var failed []string
+	preflight := d.env.Preflight
+	// the size of the packages outside of the catalogs is only known once they're downloaded, the
+	// installer checks the disk space then
+	if p, ok := d.catalogPackageOfURL(url); ok && p.Size > 0 {
+		if err := checkPackagesDiskSpace(uint64(p.Size)); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if preflight.MinMemoryBytes > 0 {
+		available, err := hostMemoryAvailable()
+		if err != nil {
+			log.Warnf("Daemon: could not check the memory available: %v", err)
+		} else if available < preflight.MinMemoryBytes {
+			failed = append(failed, fmt.Sprintf("%d bytes of memory available, %d required", available, preflight.MinMemoryBytes))
+		}
+	}
+	// the packages are only built against the glibc of linux
+	if hostOS == "linux" && preflight.MinGlibcVersion != "" {
+		if failure := checkGlibcVersion(ctx, preflight.MinGlibcVersion); failure != "" {
+			failed = append(failed, failure)
+		}
+	}
+	if preflight.RequireSystemd && !hostSystemdRunning() {
+		failed = append(failed, "systemd is not running")
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	span.SetTag("failed_checks", strings.Join(failed, ", "))
+	log.Warnf("Daemon: %s failed its preflight checks: %s", operation, strings.Join(failed, ", "))
+	return installerErrors.Wrap(installerErrors.ErrPreflightFailed, &PreflightError{Failed: failed})
+}
+
+// checkGlibcVersion returns why the glibc of the host isn't compatible with the packages, empty if it is
+func checkGlibcVersion(ctx context.Context, minVersion string) string {
+	required, err := semver.NewVersion(minVersion)
+	if err != nil {
+		log.Warnf("Daemon: ignoring invalid minimum glibc version %q: %v", minVersion, err)
+		return ""
+	}
+	rawVersion, err := hostGlibcVersion(ctx)
+	if err != nil {
+		// musl based distributions, or hosts without getconf, don't report a glibc version
+		log.Infof("Daemon: skipping the glibc version check as it can't be determined: %v", err)
+		return ""
+	}
+	version, err := semver.NewVersion(rawVersion)
+	if err != nil {
+		log.Warnf("Daemon: could not parse glibc version %q: %v", rawVersion, err)
+		return ""
+	}
+	if version.LessThan(required) {
+		return fmt.Sprintf("glibc %s found, %s required", rawVersion, minVersion)
+	}
+	return ""
+}
+
+// catalogPackageOfURL returns the package of the catalogs with the given URL
+func (d *daemonImpl) catalogPackageOfURL(url string) (Package, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for _, c := range []catalog{d.catalog, d.localCatalog} {
+		for _, p := range c.Packages {
+			if p.URL == url {
+				return p, true
+			}
+		}
+	}
+	return Package{}, false
+}
@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+// testPreflightHost overrides the checks of the host for the duration of a test
+type testPreflightHost struct {
+	disk    uint64
+	memory  uint64
+	glibc   string
+	systemd bool
+	os      string
+}
+
+func (h testPreflightHost) set(t *testing.T) {
+	disk, memory, glibc, systemd, os := checkPackagesDiskSpace, hostMemoryAvailable, hostGlibcVersion, hostSystemdRunning, hostOS
+	t.Cleanup(func() {
+		checkPackagesDiskSpace, hostMemoryAvailable, hostGlibcVersion, hostSystemdRunning, hostOS = disk, memory, glibc, systemd, os
+	})
+	checkPackagesDiskSpace = func(size uint64) error {
+		if h.disk < size {
+			return fmt.Errorf("not enough disk space: %d bytes available, %d bytes required", h.disk, size)
+		}
+		return nil
+	}
+	hostMemoryAvailable = func() (uint64, error) { return h.memory, nil }
+	hostGlibcVersion = func(context.Context) (string, error) {
+		if h.glibc == "" {
+			return "", errors.New("exit status 1")
+		}
+		return h.glibc, nil
+	}
+	hostSystemdRunning = func() bool { return h.systemd }
+	hostOS = h.os
+}
+
+func TestParseMemAvailable(t *testing.T) {
+	available, err := parseMemAvailable([]byte("MemTotal:        8048836 kB\nMemFree:          302544 kB\nMemAvailable:    4107516 kB\n"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4107516*1024), available)
+
+	_, err = parseMemAvailable([]byte("MemTotal:        8048836 kB\n"))
+	assert.Error(t, err)
+}
+
+func TestPreflight(t *testing.T) {
+	preflight := env.Preflight{Enabled: true, MinMemoryBytes: 100, MinGlibcVersion: "2.17", RequireSystemd: true}
+	testURL := "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	healthy := testPreflightHost{disk: 1000, memory: 100, glibc: "2.35", systemd: true, os: "linux"}
+
+	tests := []struct {
+		name      string
+		preflight env.Preflight
+		host      func(h testPreflightHost) testPreflightHost
+		url       string
+		failed    []string
+	}{
+		{
+			name: "healthy host",
+		},
+		{
+			name:   "low disk space",
+			host:   func(h testPreflightHost) testPreflightHost { h.disk = 999; return h },
+			failed: []string{"not enough disk space: 999 bytes available, 1000 bytes required"},
+		},
+		{
+			name: "low disk space for a package outside of the catalog",
+			host: func(h testPreflightHost) testPreflightHost { h.disk = 999; return h },
+			url:  "oci://example.com/other-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		},
+		{
+			name:   "low memory and old glibc",
+			host:   func(h testPreflightHost) testPreflightHost { h.memory = 99; h.glibc = "2.12"; return h },
+			failed: []string{"99 bytes of memory available, 100 required", "glibc 2.12 found, 2.17 required"},
+		},
+		{
+			name: "undeterminable glibc",
+			host: func(h testPreflightHost) testPreflightHost { h.glibc = ""; return h },
+		},
+		{
+			name: "no glibc outside of linux",
+			host: func(h testPreflightHost) testPreflightHost { h.glibc = ""; h.os = "darwin"; return h },
+		},
+		{
+			name:   "no systemd",
+			host:   func(h testPreflightHost) testPreflightHost { h.systemd = false; return h },
+			failed: []string{"systemd is not running"},
+		},
+		{
+			name:      "checks skipped",
+			preflight: env.Preflight{Enabled: true},
+			host:      func(testPreflightHost) testPreflightHost { return testPreflightHost{os: "linux"} },
+			url:       "oci://example.com/other-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		},
+		{
+			name:      "disabled",
+			preflight: env.Preflight{MinMemoryBytes: 100, RequireSystemd: true},
+			host:      func(testPreflightHost) testPreflightHost { return testPreflightHost{os: "linux"} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := healthy
+			if tt.host != nil {
+				host = tt.host(host)
+			}
+			host.set(t)
+			d := &daemonImpl{
+				env:     &env.Env{Preflight: preflight},
+				catalog: catalog{Packages: []Package{{Name: "test-package", URL: testURL, Size: 1000}}},
+			}
+			if tt.preflight != (env.Preflight{}) {
+				d.env.Preflight = tt.preflight
+			}
+			url := testURL
+			if tt.url != "" {
+				url = tt.url
+			}
+
+			err := d.preflight(context.Background(), auditInstall, url)
+			if len(tt.failed) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var preflightErr *PreflightError
+			require.ErrorAs(t, err, &preflightErr)
+			assert.Equal(t, tt.failed, preflightErr.Failed)
+			assert.Equal(t, installerErrors.ErrPreflightFailed, installerErrors.From(err).Code())
+		})
+	}
+}
+
+func TestRemoteRequestPreflightFailed(t *testing.T) {
+	testPreflightHost{disk: 10, os: "linux"}.set(t)
+	i := newTestInstallerWithEnv(&env.Env{RemoteUpdates: true, Preflight: env.Preflight{Enabled: true}})
+	defer i.Stop()
+
+	testStablePackage := Package{
+		Name:     "test-package",
+		Version:  "0.0.1",
+		URL:      "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	testExperimentPackage := Package{
+		Name:     "test-package",
+		Version:  "1.0.0",
+		URL:      "oci://example.com/test-package@sha256:5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Size:     1000,
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+	}
+	i.rcc.SubmitCatalog(catalog{Packages: []Package{testExperimentPackage}})
+	versionParamsJSON, _ := json.Marshal(taskWithVersionParams{Version: testExperimentPackage.Version})
+
+	i.pm.On("State", testStablePackage.Name).Return(repository.State{Stable: testStablePackage.Version}, nil).Once()
+	i.rcc.SubmitRequest(remoteAPIRequest{
+		ID:            "test-request-1",
+		Method:        methodStartExperiment,
+		Package:       testExperimentPackage.Name,
+		ExpectedState: expectedState{InstallerVersion: version.AgentVersion, Stable: testStablePackage.Version},
+		Params:        versionParamsJSON,
+	})
+	i.requestsWG.Wait()
+
+	require.Len(t, i.rcc.packagesState, 1)
+	task := i.rcc.packagesState[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, pbgo.TaskState_ERROR, task.State)
+	require.NotNil(t, task.Error)
+	assert.Equal(t, uint64(installerErrors.ErrPreflightFailed), task.Error.Code)
+	assert.Contains(t, task.Error.Message, "not enough disk space: 10 bytes available, 1000 bytes required")
+	// the installer isn't invoked
+	i.pm.AssertNotCalled(t, "InstallExperiment", mock.Anything, mock.Anything)
+}
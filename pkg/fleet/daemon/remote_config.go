@@ -9,11 +9,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 
 	"github.com/DataDog/datadog-agent/pkg/config/remote/client"
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
 	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
 	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -24,23 +27,43 @@ type remoteConfigClient interface {
 	Close()
 	Subscribe(product string, fn func(update map[string]state.RawConfig, applyStateCallback func(string, state.ApplyStatus)))
 	SetUpdaterPackagesState(packages []*pbgo.PackageState)
+	SetUpdaterTags(tags []string)
+	SetUpdaterHealth(health client.UpdaterHealth)
 }
 
 type remoteConfig struct {
 	client remoteConfigClient
+	// envTags are the updater tags reporting the environment of the daemon
+	envTags []string
+	// stateReport is the kind of the last report of the state of the packages, empty if it isn't flagged
+	stateReport string
 }
 
-func newRemoteConfig(rcFetcher client.ConfigFetcher) (*remoteConfig, error) {
+// envTagPrefix is the prefix of the updater tags reporting the environment of the daemon.
+const envTagPrefix = "installer_env:"
+
+// channelTagPrefix is the prefix of the updater tag reporting the release channel of the host.
+const channelTagPrefix = "installer_channel:"
+
+// stateReportTagPrefix is the prefix of the updater tag reporting whether the state of the packages is
+// a full or a delta report.
+const stateReportTagPrefix = "installer_state_report:"
+
+func newRemoteConfig(rcFetcher client.ConfigFetcher, env *env.Env) (*remoteConfig, error) {
+	var tags []string
+	for _, e := range env.ToRedactedEnv() {
+		tags = append(tags, envTagPrefix+e)
+	}
 	client, err := client.NewClient(
 		rcFetcher,
-		client.WithUpdater(),
+		client.WithUpdater(tags...),
 		client.WithProducts(state.ProductUpdaterCatalogDD, state.ProductUpdaterTask),
 		client.WithoutTufVerification(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create rc client: %w", err)
 	}
-	return &remoteConfig{client: client}, nil
+	return &remoteConfig{client: client, envTags: tags}, nil
 }
 
 // Start starts the remote config client.
@@ -58,9 +81,47 @@ func (rc *remoteConfig) Close() {
 	rc.client.Close()
 }
 
-// SetState sets the state of the given package.
-func (rc *remoteConfig) SetState(packages []*pbgo.PackageState) {
+// SetState sets the state of the packages, report being the kind of the report flagged in the updater
+// tags, if any. The packages and the tags are sent together on the next poll but set separately: a delta
+// report is flagged before being set and a full report set before being flagged, so that a poll in between
+// never sends a delta report flagged as full.
+func (rc *remoteConfig) SetState(packages []*pbgo.PackageState, report string) {
+	if report == stateReportDelta {
+		rc.setStateReport(report)
+		rc.client.SetUpdaterPackagesState(packages)
+		return
+	}
 	rc.client.SetUpdaterPackagesState(packages)
+	rc.setStateReport(report)
+}
+
+func (rc *remoteConfig) setStateReport(report string) {
+	if report == rc.stateReport {
+		return
+	}
+	rc.stateReport = report
+	rc.setTags()
+}
+
+// SetChannel sets the release channel of the host, reported in the updater tags. It replaces the
+// previous channel of the host.
+func (rc *remoteConfig) SetChannel(channel string) {
+	rc.envTags = slices.DeleteFunc(rc.envTags, func(tag string) bool { return strings.HasPrefix(tag, channelTagPrefix) })
+	rc.envTags = append(rc.envTags, channelTagPrefix+channel)
+	rc.setTags()
+}
+
+// SetHealth sets the health of the daemon, reported along with the state of the packages.
+func (rc *remoteConfig) SetHealth(health client.UpdaterHealth) {
+	rc.client.SetUpdaterHealth(health)
+}
+
+func (rc *remoteConfig) setTags() {
+	tags := slices.Clone(rc.envTags)
+	if rc.stateReport != "" {
+		tags = append(tags, stateReportTagPrefix+rc.stateReport)
+	}
+	rc.client.SetUpdaterTags(tags)
 }
 
 // Package represents a downloadable package.
@@ -72,6 +133,10 @@ type Package struct {
 	Size     int64  `json:"size"`
 	Platform string `json:"platform"`
 	Arch     string `json:"arch"`
+	// Rollout restricts the hosts the package is rolled out to, it's rolled out everywhere if unset
+	Rollout *Rollout `json:"rollout,omitempty"`
+	// Channel is the release channel of the package, stable if unset
+	Channel string `json:"channel,omitempty"`
 }
 
 type catalog struct {
@@ -134,6 +199,9 @@ func validatePackage(pkg Package) error {
 	if pkg.URL == "" {
 		return fmt.Errorf("package URL is empty")
 	}
+	if err := validateRollout(pkg.Rollout); err != nil {
+		return err
+	}
 	url, err := url.Parse(pkg.URL)
 	if err != nil {
 		return fmt.Errorf("could not parse package URL: %w", err)
@@ -154,6 +222,17 @@ const (
 	methodStartExperiment   = "start_experiment"
 	methodStopExperiment    = "stop_experiment"
 	methodPromoteExperiment = "promote_experiment"
+	methodRollback          = "rollback"
+	methodUninstall         = "uninstall"
+	methodRotateAPIKey      = "rotate_api_key"
+	methodReboot            = "reboot"
+	methodFlare             = "flare"
+	methodSetChannel        = "set_channel"
+	methodCheckPermissions  = "check_permissions"
+	methodDiagnose          = "diagnose"
+
+	methodSetIntegrationConfigs      = "set_integration_configs"
+	methodRollbackIntegrationConfigs = "rollback_integration_configs"
 )
 
 type remoteAPIRequest struct {
@@ -164,6 +243,8 @@ type remoteAPIRequest struct {
 	ExpectedState expectedState   `json:"expected_state"`
 	Method        string          `json:"method"`
 	Params        json.RawMessage `json:"params"`
+	// Priority orders the queued requests, the ones with a higher priority (e.g. security patches) are executed first
+	Priority int `json:"priority"`
 }
 
 type expectedState struct {
@@ -175,6 +256,47 @@ type expectedState struct {
 type taskWithVersionParams struct {
 	Version     string   `json:"version"`
 	InstallArgs []string `json:"install_args"`
+	// DryRun checks the operation and reports what it would change, without changing anything
+	DryRun bool `json:"dry_run,omitempty"`
+	// AllowDowngrade allows installing a version lower than the highest one installed on the host
+	AllowDowngrade bool `json:"allow_downgrade,omitempty"`
+	// TTL is the duration, e.g. 24h, after which the experiment is stopped if it's neither promoted nor stopped
+	TTL string `json:"ttl,omitempty"`
+}
+
+type rotateAPIKeyParams struct {
+	// EncryptedAPIKey is the new API key encrypted with the current one, see decryptAPIKey
+	EncryptedAPIKey string `json:"encrypted_api_key"`
+}
+
+type rebootParams struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+type setChannelParams struct {
+	Channel string `json:"channel"`
+}
+
+type checkPermissionsParams struct {
+	// Repair restores the expected owners and modes of the files, they are only checked otherwise
+	Repair bool `json:"repair"`
+}
+
+type diagnoseParams struct {
+	// Uninstrument reports the modification uninstrumenting docker would make, instrumenting it otherwise
+	Uninstrument bool `json:"uninstrument"`
+}
+
+type setIntegrationConfigsParams struct {
+	Version string `json:"version"`
+	// Files are the configuration files keyed by their path relative to the conf.d directory, e.g. nginx.d/conf.yaml
+	Files map[string]string `json:"files"`
+}
+
+type flareParams struct {
+	CaseID string `json:"case_id"`
+	Email  string `json:"user_handle"`
 }
 
 type handleRemoteAPIRequest func(request remoteAPIRequest) error
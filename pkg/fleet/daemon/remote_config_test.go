@@ -9,10 +9,12 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/DataDog/datadog-agent/pkg/config/remote/client"
 	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
 )
 
@@ -204,3 +206,16 @@ func TestRemoteAPIRequestIgnoresAlreadyExecutedRequests(t *testing.T) {
 
 	callback.AssertExpectations(t)
 }
+
+func TestRemoteConfigSetHealth(t *testing.T) {
+	rcc := newTestRemoteConfigClient()
+	rc := &remoteConfig{client: rcc, envTags: []string{envTagPrefix + "DD_SITE=datadoghq.com"}}
+	rc.SetChannel("stable")
+	tags := rcc.tags
+
+	// the health changes on every heartbeat so it isn't reported in the tags
+	health := client.UpdaterHealth{Heartbeat: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Uptime: time.Hour, QueueDepth: 2}
+	rc.SetHealth(health)
+	assert.Equal(t, health, rcc.health)
+	assert.Equal(t, tags, rcc.tags)
+}
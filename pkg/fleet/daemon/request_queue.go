@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// requestQueue is the queue of the remote requests waiting to be executed by the daemon. Requests
+// with a higher priority are executed first, requests of the same priority in the order they were
+// received.
+type requestQueue struct {
+	m        sync.Mutex
+	requests queuedRequests
+	received uint64
+
+	// ready is notified when a request is pushed, and again when one is popped while others are
+	// still queued so that an idle worker can pick them
+	ready chan struct{}
+}
+
+type queuedRequest struct {
+	request remoteAPIRequest
+	order   uint64
+}
+
+// queuedRequests implements heap.Interface
+type queuedRequests []queuedRequest
+
+func (q queuedRequests) Len() int { return len(q) }
+
+func (q queuedRequests) Less(i, j int) bool {
+	if q[i].request.Priority != q[j].request.Priority {
+		return q[i].request.Priority > q[j].request.Priority
+	}
+	return q[i].order < q[j].order
+}
+
+func (q queuedRequests) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *queuedRequests) Push(x any) { *q = append(*q, x.(queuedRequest)) }
+
+func (q *queuedRequests) Pop() any {
+	old := *q
+	n := len(old)
+	r := old[n-1]
+	*q = old[:n-1]
+	return r
+}
+
+func newRequestQueue() *requestQueue {
+	return &requestQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push queues a request and notifies the ready channel
+func (q *requestQueue) push(request remoteAPIRequest) {
+	q.m.Lock()
+	heap.Push(&q.requests, queuedRequest{request: request, order: q.received})
+	q.received++
+	q.m.Unlock()
+	q.notify()
+}
+
+func (q *requestQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the queued request with the highest priority, if any
+func (q *requestQueue) pop() (remoteAPIRequest, bool) {
+	return q.popFunc(func(remoteAPIRequest) bool { return true })
+}
+
+// popFunc returns the queued request with the highest priority accepted by the given function, if
+// any. The requests that aren't accepted are kept in the queue.
+func (q *requestQueue) popFunc(accept func(remoteAPIRequest) bool) (remoteAPIRequest, bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	var skipped []queuedRequest
+	defer func() {
+		for _, r := range skipped {
+			heap.Push(&q.requests, r)
+		}
+	}()
+	for len(q.requests) > 0 {
+		r := heap.Pop(&q.requests).(queuedRequest)
+		if !accept(r.request) {
+			skipped = append(skipped, r)
+			continue
+		}
+		if len(q.requests)+len(skipped) > 0 {
+			q.notify()
+		}
+		return r.request, true
+	}
+	return remoteAPIRequest{}, false
+}
+
+// len returns the number of queued requests
+func (q *requestQueue) len() int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return len(q.requests)
+}
+
+// requestClaims are the packages of the requests being handled by the workers of the daemon. A
+// worker only picks a request whose package isn't claimed, so that the workers don't all end up
+// waiting for the same package while requests for other packages are queued.
+type requestClaims struct {
+	m        sync.Mutex
+	packages map[string]struct{}
+}
+
+func newRequestClaims() *requestClaims {
+	return &requestClaims{
+		packages: make(map[string]struct{}),
+	}
+}
+
+// claim claims the package of the request and returns whether it was free. An empty package
+// stands for all of them.
+func (c *requestClaims) claim(pkg string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, all := c.packages[""]; all {
+		return false
+	}
+	if _, claimed := c.packages[pkg]; claimed || (pkg == "" && len(c.packages) > 0) {
+		return false
+	}
+	c.packages[pkg] = struct{}{}
+	return true
+}
+
+// release releases the package of a request
+func (c *requestClaims) release(pkg string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	delete(c.packages, pkg)
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestQueue(t *testing.T) {
+	q := newRequestQueue()
+	q.push(remoteAPIRequest{ID: "routine-1"})
+	q.push(remoteAPIRequest{ID: "security-1", Priority: 100})
+	q.push(remoteAPIRequest{ID: "routine-2"})
+	q.push(remoteAPIRequest{ID: "security-2", Priority: 100})
+	q.push(remoteAPIRequest{ID: "low", Priority: -1})
+
+	// a single notification is buffered for the pushes
+	assert.Len(t, q.ready, 1)
+
+	var ids []string
+	for request, ok := q.pop(); ok; request, ok = q.pop() {
+		ids = append(ids, request.ID)
+	}
+	assert.Equal(t, []string{"security-1", "security-2", "routine-1", "routine-2", "low"}, ids)
+}
+
+func TestRequestQueuePopFunc(t *testing.T) {
+	q := newRequestQueue()
+	q.push(remoteAPIRequest{ID: "agent-1", Package: "datadog-agent", Priority: 100})
+	q.push(remoteAPIRequest{ID: "agent-2", Package: "datadog-agent"})
+	q.push(remoteAPIRequest{ID: "injector-1", Package: "datadog-apm-inject"})
+	<-q.ready
+
+	notAgent := func(request remoteAPIRequest) bool { return request.Package != "datadog-agent" }
+	request, ok := q.popFunc(notAgent)
+	assert.True(t, ok)
+	assert.Equal(t, "injector-1", request.ID)
+	// the skipped requests are still queued, an idle worker is notified
+	assert.Len(t, q.ready, 1)
+	_, ok = q.popFunc(notAgent)
+	assert.False(t, ok)
+
+	var ids []string
+	for request, ok := q.pop(); ok; request, ok = q.pop() {
+		ids = append(ids, request.ID)
+	}
+	assert.Equal(t, []string{"agent-1", "agent-2"}, ids)
+}
+
+func TestRequestClaims(t *testing.T) {
+	c := newRequestClaims()
+	assert.True(t, c.claim("datadog-agent"))
+	assert.False(t, c.claim("datadog-agent"))
+	assert.True(t, c.claim("datadog-apm-inject"))
+	// all the packages can't be claimed while some are
+	assert.False(t, c.claim(""))
+
+	c.release("datadog-agent")
+	c.release("datadog-apm-inject")
+	assert.True(t, c.claim(""))
+	assert.False(t, c.claim("datadog-agent"))
+	c.release("")
+	assert.True(t, c.claim("datadog-agent"))
+}
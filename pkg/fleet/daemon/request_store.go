@@ -0,0 +1,203 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// requestStoreFile is the file, in the packages directory, the remote requests are persisted to
+const requestStoreFile = "requests.json"
+
+// requestStore persists the remote requests received by the daemon until they're handled, so that
+// a restarted daemon resumes the queued requests and reports the ones it was executing as aborted
+// instead of silently dropping them. Requests are only kept in memory if the store has no path.
+type requestStore struct {
+	m        sync.Mutex
+	path     string
+	requests []storedRequest
+}
+
+type storedRequest struct {
+	Request remoteAPIRequest `json:"request"`
+	// Running is set once the request is executed, it's aborted if the daemon restarts before it's done
+	Running bool `json:"running"`
+	// HandedOff is set once the request restarts the daemon itself, e.g. when starting an installer
+	// experiment, for the next daemon to report its outcome instead of aborting it
+	HandedOff bool `json:"handed_off,omitempty"`
+	// Redacted is set on the persisted requests whose secret params were removed, they can't be resumed
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// secretParams are the params holding secrets of the requests, by method. They're never written to
+// disk, the requests persisted without them are aborted by the next daemon instead of being resumed.
+var secretParams = map[string][]string{
+	methodRotateAPIKey:          {"encrypted_api_key"},
+	methodSetIntegrationConfigs: {"files"},
+}
+
+func newRequestStore(path string) *requestStore {
+	return &requestStore{path: path}
+}
+
+// load reads the requests persisted by the previous daemon, in the order they were received, and
+// returns the ones that were queued, the ones that were running or can't be resumed without their
+// secret params, and the ones that were handed off to this daemon.
+func (s *requestStore) load() (queued []remoteAPIRequest, aborted []remoteAPIRequest, handedOff []remoteAPIRequest, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.path == "" {
+		return nil, nil, nil, nil
+	}
+	rawRequests, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not read persisted requests: %w", err)
+	}
+	var requests []storedRequest
+	err = json.Unmarshal(rawRequests, &requests)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not unmarshal persisted requests: %w", err)
+	}
+	s.requests = requests
+	for _, r := range requests {
+		switch {
+		case r.HandedOff:
+			handedOff = append(handedOff, r.Request)
+		case r.Running || r.Redacted:
+			aborted = append(aborted, r.Request)
+		default:
+			queued = append(queued, r.Request)
+		}
+	}
+	return queued, aborted, handedOff, nil
+}
+
+// add persists a received request, it returns false if the request is already stored
+func (s *requestStore) add(request remoteAPIRequest) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.find(request.ID) >= 0 {
+		return false
+	}
+	s.requests = append(s.requests, storedRequest{Request: request})
+	s.persist()
+	return true
+}
+
+// setRunning marks a request as being executed
+func (s *requestStore) setRunning(id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return
+	}
+	s.requests[i].Running = true
+	s.persist()
+}
+
+// setHandedOff marks a request as handed off to the next daemon
+func (s *requestStore) setHandedOff(id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return
+	}
+	s.requests[i].HandedOff = true
+	s.persist()
+}
+
+// remove removes a handled request
+func (s *requestStore) remove(id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return
+	}
+	s.requests = append(s.requests[:i], s.requests[i+1:]...)
+	s.persist()
+}
+
+func (s *requestStore) find(id string) int {
+	for i, r := range s.requests {
+		if r.Request.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// persist writes the requests to disk. Failing to persist them doesn't prevent them from being
+// executed, they're only lost if the daemon restarts.
+func (s *requestStore) persist() {
+	if s.path == "" {
+		return
+	}
+	err := s.write()
+	if err != nil {
+		log.Warnf("Daemon: could not persist the remote requests: %v", err)
+	}
+}
+
+func (s *requestStore) write() error {
+	requests := make([]storedRequest, 0, len(s.requests))
+	for _, r := range s.requests {
+		requests = append(requests, redactRequest(r))
+	}
+	rawRequests, err := json.Marshal(requests)
+	if err != nil {
+		return fmt.Errorf("could not marshal requests: %w", err)
+	}
+	// the file is only readable by the daemon, the requests still hold the hosts and versions of the packages
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(rawRequests)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write requests: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), s.path)
+	if err != nil {
+		return fmt.Errorf("could not move requests: %w", err)
+	}
+	return nil
+}
+
+// redactRequest removes the secret params of a request before it's written to disk
+func redactRequest(r storedRequest) storedRequest {
+	keys, ok := secretParams[r.Request.Method]
+	if !ok || len(r.Request.Params) == 0 {
+		return r
+	}
+	rawParams := r.Request.Params
+	r.Request.Params = nil
+	var params map[string]json.RawMessage
+	// params that can't be parsed are dropped altogether rather than risking leaking them
+	if err := json.Unmarshal(rawParams, &params); err == nil {
+		for _, key := range keys {
+			delete(params, key)
+		}
+		r.Request.Params, _ = json.Marshal(params)
+	}
+	r.Redacted = true
+	return r
+}
@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), requestStoreFile)
+	s := newRequestStore(path)
+	assert.True(t, s.add(remoteAPIRequest{ID: "1", Package: "datadog-agent"}))
+	assert.True(t, s.add(remoteAPIRequest{ID: "2", Package: "datadog-apm-inject"}))
+	assert.True(t, s.add(remoteAPIRequest{ID: "3", Package: "datadog-agent"}))
+	assert.False(t, s.add(remoteAPIRequest{ID: "1", Package: "datadog-agent"}))
+	s.setRunning("1")
+	s.remove("2")
+
+	// the requests may hold secrets
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	restored := newRequestStore(path)
+	queued, aborted, handedOff, err := restored.load()
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, "3", queued[0].ID)
+	require.Len(t, aborted, 1)
+	assert.Equal(t, "1", aborted[0].ID)
+	assert.Empty(t, handedOff)
+	// the restored requests are known to the store
+	assert.False(t, restored.add(remoteAPIRequest{ID: "3", Package: "datadog-agent"}))
+}
+
+func TestRequestStoreMissingFile(t *testing.T) {
+	queued, aborted, handedOff, err := newRequestStore(filepath.Join(t.TempDir(), requestStoreFile)).load()
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+	assert.Empty(t, aborted)
+	assert.Empty(t, handedOff)
+}
+
+func TestRequestStoreInMemory(t *testing.T) {
+	s := newRequestStore("")
+	assert.True(t, s.add(remoteAPIRequest{ID: "1"}))
+	assert.False(t, s.add(remoteAPIRequest{ID: "1"}))
+	queued, aborted, handedOff, err := s.load()
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+	assert.Empty(t, aborted)
+	assert.Empty(t, handedOff)
+}
+
+func TestRequestStoreRedactsSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), requestStoreFile)
+	s := newRequestStore(path)
+	params, err := json.Marshal(rotateAPIKeyParams{EncryptedAPIKey: "secret-api-key"})
+	require.NoError(t, err)
+	s.add(remoteAPIRequest{ID: "1", Method: methodRotateAPIKey, Package: "datadog-agent", Params: params})
+	s.add(remoteAPIRequest{ID: "2", Method: methodStartExperiment, Package: "datadog-installer", Params: []byte(`{"version":"7.56.0-1"}`)})
+	s.setHandedOff("2")
+
+	// the secret params are kept in memory but never written to disk
+	assert.JSONEq(t, string(params), string(s.requests[0].Request.Params))
+	rawRequests, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(rawRequests), "secret-api-key")
+
+	// the redacted requests can't be resumed
+	queued, aborted, handedOff, err := newRequestStore(path).load()
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+	require.Len(t, aborted, 1)
+	assert.Equal(t, "1", aborted[0].ID)
+	require.Len(t, handedOff, 1)
+	assert.Equal(t, "2", handedOff[0].ID)
+}
@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+)
+
+// Rollout restricts a package of the catalog to a subset of the hosts, so that a new version can be
+// rolled out in stages by updating the catalog instead of sending requests to each host. A host is
+// targeted if it matches all the criteria that are set.
+type Rollout struct {
+	// Percentage is the percentage of the hosts targeted. Each host is assigned a stable bucket per
+	// package, so that raising the percentage only adds hosts to the rollout.
+	Percentage *int `json:"percentage,omitempty"`
+	// HostGroups are the groups targeted, the host must be in one of them
+	HostGroups []string `json:"host_groups,omitempty"`
+	// Rings are the rings targeted, the ring of the host must be one of them
+	Rings []string `json:"rings,omitempty"`
+}
+
+// rolloutHost is the identity of the host used to evaluate rollouts
+type rolloutHost struct {
+	hostname string
+	ring     string
+	groups   []string
+}
+
+func validateRollout(r *Rollout) error {
+	if r == nil {
+		return nil
+	}
+	if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+		return fmt.Errorf("rollout percentage %d is not between 0 and 100", *r.Percentage)
+	}
+	return nil
+}
+
+// includes returns whether the host is targeted by the rollout of the package
+func (r *Rollout) includes(pkg string, host rolloutHost) bool {
+	if r == nil {
+		return true
+	}
+	if len(r.Rings) > 0 && !slices.Contains(r.Rings, host.ring) {
+		return false
+	}
+	if len(r.HostGroups) > 0 && !slices.ContainsFunc(r.HostGroups, func(group string) bool {
+		return slices.Contains(host.groups, group)
+	}) {
+		return false
+	}
+	if r.Percentage != nil && rolloutBucket(pkg, host.hostname) >= *r.Percentage {
+		return false
+	}
+	return true
+}
+
+// rolloutBucket returns the bucket of the host for the rollouts of the package, between 0 and 99
+func rolloutBucket(pkg string, hostname string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(pkg))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(hostname))
+	return int(h.Sum32() % 100)
+}
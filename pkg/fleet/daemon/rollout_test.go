@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func percentage(p int) *int {
+	return &p
+}
+
+func TestRolloutIncludes(t *testing.T) {
+	host := rolloutHost{hostname: "host-1", ring: "canary", groups: []string{"web", "eu"}}
+
+	var unset *Rollout
+	assert.True(t, unset.includes("datadog-agent", host))
+	assert.True(t, (&Rollout{}).includes("datadog-agent", host))
+
+	assert.True(t, (&Rollout{Rings: []string{"canary", "early"}}).includes("datadog-agent", host))
+	assert.False(t, (&Rollout{Rings: []string{"stable"}}).includes("datadog-agent", host))
+
+	assert.True(t, (&Rollout{HostGroups: []string{"db", "eu"}}).includes("datadog-agent", host))
+	assert.False(t, (&Rollout{HostGroups: []string{"db"}}).includes("datadog-agent", host))
+
+	assert.True(t, (&Rollout{Percentage: percentage(100)}).includes("datadog-agent", host))
+	assert.False(t, (&Rollout{Percentage: percentage(0)}).includes("datadog-agent", host))
+
+	// all the criteria must match
+	assert.False(t, (&Rollout{Rings: []string{"canary"}, HostGroups: []string{"db"}}).includes("datadog-agent", host))
+}
+
+func TestRolloutPercentage(t *testing.T) {
+	rollout := &Rollout{Percentage: percentage(10)}
+	included := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		host := rolloutHost{hostname: fmt.Sprintf("host-%d", i)}
+		included[host.hostname] = rollout.includes("datadog-agent", host)
+	}
+	count := 0
+	for _, in := range included {
+		if in {
+			count++
+		}
+	}
+	assert.InDelta(t, 100, count, 40)
+
+	// raising the percentage keeps the hosts already targeted
+	rollout.Percentage = percentage(50)
+	for hostname, in := range included {
+		if in {
+			assert.True(t, rollout.includes("datadog-agent", rolloutHost{hostname: hostname}))
+		}
+	}
+}
+
+func TestValidateRollout(t *testing.T) {
+	assert.NoError(t, validateRollout(nil))
+	assert.NoError(t, validateRollout(&Rollout{Percentage: percentage(0)}))
+	assert.NoError(t, validateRollout(&Rollout{Percentage: percentage(100)}))
+	assert.Error(t, validateRollout(&Rollout{Percentage: percentage(-1)}))
+	assert.Error(t, validateRollout(&Rollout{Percentage: percentage(101)}))
+}
@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+)
+
+// defaultFullSyncInterval is the interval of the full reports of the state of the packages if none is set
+const defaultFullSyncInterval = 10 * time.Minute
+
+// Kinds of the reports of the state of the packages, flagged in the updater tags if the delta reports
+// are enabled
+const (
+	stateReportFull  = "full"
+	stateReportDelta = "delta"
+)
+
+// stateReporter reduces the state of the packages reported through remote config to the packages that
+// changed since the last full report, if the delta reports are enabled. As the remote config client sends
+// the last state set on every poll, the deltas are cumulative since the last full report: a report
+// replaced before being sent never loses a change.
+type stateReporter struct {
+	delta            bool
+	fullSyncInterval time.Duration
+
+	// baseline is the state of the packages of the last full report, by package
+	baseline     map[string]*pbgo.PackageState
+	lastFullSync time.Time
+}
+
+func newStateReporter(delta bool, fullSyncInterval time.Duration) *stateReporter {
+	if fullSyncInterval <= 0 {
+		fullSyncInterval = defaultFullSyncInterval
+	}
+	return &stateReporter{
+		delta:            delta,
+		fullSyncInterval: fullSyncInterval,
+	}
+}
+
+// report returns the packages to report out of the state of all the packages, and the kind of the
+// report. The kind is empty if the delta reports are disabled, as the state is always full.
+func (r *stateReporter) report(packages []*pbgo.PackageState, now time.Time) ([]*pbgo.PackageState, string) {
+	if !r.delta {
+		return packages, ""
+	}
+	// the wall clock going backwards also forces a full report
+	if r.baseline == nil || now.Sub(r.lastFullSync) >= r.fullSyncInterval || now.Before(r.lastFullSync) {
+		r.baseline = make(map[string]*pbgo.PackageState, len(packages))
+		for _, p := range packages {
+			r.baseline[p.Package] = p
+		}
+		r.lastFullSync = now
+		return packages, stateReportFull
+	}
+	current := make(map[string]struct{}, len(packages))
+	changed := []*pbgo.PackageState{}
+	for _, p := range packages {
+		current[p.Package] = struct{}{}
+		if reported, ok := r.baseline[p.Package]; !ok || !proto.Equal(reported, p) {
+			changed = append(changed, p)
+		}
+	}
+	// the packages removed since the last full report are reported without versions
+	var removed []string
+	for pkg := range r.baseline {
+		if _, ok := current[pkg]; !ok {
+			removed = append(removed, pkg)
+		}
+	}
+	sort.Strings(removed)
+	for _, pkg := range removed {
+		changed = append(changed, &pbgo.PackageState{Package: pkg})
+	}
+	return changed, stateReportDelta
+}
@@ -0,0 +1,163 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+)
+
+func reportedPackages(packages []*pbgo.PackageState) map[string]string {
+	reported := make(map[string]string)
+	for _, p := range packages {
+		reported[p.Package] = p.StableVersion + "/" + p.ExperimentVersion
+	}
+	return reported
+}
+
+func TestStateReporterFull(t *testing.T) {
+	r := newStateReporter(false, 0)
+	packages := []*pbgo.PackageState{{Package: "datadog-agent", StableVersion: "7.59.0"}}
+
+	for i := 0; i < 2; i++ {
+		reported, report := r.report(packages, time.Now())
+		assert.Equal(t, packages, reported)
+		assert.Empty(t, report)
+	}
+}
+
+func TestStateReporterDelta(t *testing.T) {
+	r := newStateReporter(true, time.Hour)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// the first report is full
+	reported, report := r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.59.0"},
+		{Package: "datadog-apm-inject", StableVersion: "0.20.0"},
+		{Package: "datadog-apm-library-java", StableVersion: "1.38.0"},
+	}, now)
+	assert.Equal(t, stateReportFull, report)
+	assert.Len(t, reported, 3)
+
+	// nothing changed
+	reported, report = r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.59.0"},
+		{Package: "datadog-apm-inject", StableVersion: "0.20.0"},
+		{Package: "datadog-apm-library-java", StableVersion: "1.38.0"},
+	}, now.Add(time.Minute))
+	assert.Equal(t, stateReportDelta, report)
+	assert.Empty(t, reported)
+
+	// an experiment started, a package was removed and another installed
+	reported, report = r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.59.0", ExperimentVersion: "7.60.0"},
+		{Package: "datadog-apm-inject", StableVersion: "0.20.0"},
+		{Package: "datadog-apm-library-python", StableVersion: "2.9.0"},
+	}, now.Add(2*time.Minute))
+	assert.Equal(t, stateReportDelta, report)
+	assert.Equal(t, map[string]string{
+		"datadog-agent":              "7.59.0/7.60.0",
+		"datadog-apm-library-python": "2.9.0/",
+		"datadog-apm-library-java":   "/",
+	}, reportedPackages(reported))
+
+	// the deltas are cumulative since the last full report
+	reported, report = r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.60.0"},
+		{Package: "datadog-apm-inject", StableVersion: "0.20.0"},
+		{Package: "datadog-apm-library-python", StableVersion: "2.9.0"},
+	}, now.Add(3*time.Minute))
+	assert.Equal(t, stateReportDelta, report)
+	assert.Equal(t, map[string]string{
+		"datadog-agent":              "7.60.0/",
+		"datadog-apm-library-python": "2.9.0/",
+		"datadog-apm-library-java":   "/",
+	}, reportedPackages(reported))
+
+	// the full state is reported again once the interval elapsed
+	reported, report = r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.60.0"},
+	}, now.Add(time.Hour))
+	assert.Equal(t, stateReportFull, report)
+	assert.Len(t, reported, 1)
+
+	// and if the wall clock goes backwards
+	_, report = r.report([]*pbgo.PackageState{
+		{Package: "datadog-agent", StableVersion: "7.60.0"},
+	}, now)
+	assert.Equal(t, stateReportFull, report)
+}
+
+// orderedRemoteConfigClient records the order the state and the tags are set in
+type orderedRemoteConfigClient struct {
+	*testRemoteConfigClient
+	calls []string
+}
+
+func (c *orderedRemoteConfigClient) SetUpdaterPackagesState(packages []*pbgo.PackageState) {
+	c.calls = append(c.calls, "state")
+	c.testRemoteConfigClient.SetUpdaterPackagesState(packages)
+}
+
+func (c *orderedRemoteConfigClient) SetUpdaterTags(tags []string) {
+	c.calls = append(c.calls, "tags")
+	c.testRemoteConfigClient.SetUpdaterTags(tags)
+}
+
+func TestRemoteConfigSetStateReport(t *testing.T) {
+	rcc := &orderedRemoteConfigClient{testRemoteConfigClient: newTestRemoteConfigClient()}
+	rc := &remoteConfig{client: rcc, envTags: []string{envTagPrefix + "DD_SITE=datadoghq.com"}}
+
+	// a full report is set before being flagged
+	rc.SetState([]*pbgo.PackageState{{Package: "datadog-agent"}}, stateReportFull)
+	assert.Equal(t, []string{"state", "tags"}, rcc.calls)
+	assert.Contains(t, rcc.tags, stateReportTagPrefix+stateReportFull)
+
+	// a delta report is flagged before being set
+	rcc.calls = nil
+	rc.SetState(nil, stateReportDelta)
+	assert.Equal(t, []string{"tags", "state"}, rcc.calls)
+	assert.Contains(t, rcc.tags, stateReportTagPrefix+stateReportDelta)
+	assert.NotContains(t, rcc.tags, stateReportTagPrefix+stateReportFull)
+
+	// the tags are only set if the kind of the report changes
+	rcc.calls = nil
+	rc.SetState(nil, stateReportDelta)
+	assert.Equal(t, []string{"state"}, rcc.calls)
+}
+
+func TestDaemonStateReportingDelta(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{StateReporting: env.StateReporting{Delta: true, FullSyncInterval: time.Hour}})
+	defer i.Stop()
+
+	i.m.Lock()
+	i.pm.ExpectedCalls = nil
+	i.pm.On("States").Return(map[string]repository.State{
+		"datadog-agent":      {Stable: "7.59.0", Experiment: "7.60.0"},
+		"datadog-apm-inject": {Stable: "0.20.0"},
+	}, nil)
+	i.m.Unlock()
+	i.refreshState(context.Background())
+
+	i.m.Lock()
+	defer i.m.Unlock()
+	require.Contains(t, i.rcc.tags, stateReportTagPrefix+stateReportDelta)
+	assert.Equal(t, map[string]string{
+		"datadog-agent":      "7.59.0/7.60.0",
+		"datadog-apm-inject": "0.20.0/",
+	}, reportedPackages(i.rcc.packagesState))
+}
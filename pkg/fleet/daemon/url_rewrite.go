@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// urlRewrite replaces the prefix of the URLs of the packages, e.g. to download the packages of the
+// catalog received from remote config from an internal mirror. The digest of the URLs is kept, so the
+// mirror must serve the same packages.
+type urlRewrite struct {
+	prefix      string
+	replacement string
+}
+
+// parseURLRewrite parses a rewrite rule formatted as `<prefix>=<replacement>`, e.g.
+// `oci://install.datadoghq.com/=oci://registry.internal/datadog/`. The prefix must have a scheme and a host.
+func parseURLRewrite(s string) (urlRewrite, error) {
+	prefix, replacement, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || prefix == "" || replacement == "" {
+		return urlRewrite{}, fmt.Errorf("invalid URL rewrite %q, expected <prefix>=<replacement>", s)
+	}
+	if scheme, host, _ := splitPackageURL(prefix); scheme == "" || host == "" {
+		return urlRewrite{}, fmt.Errorf("invalid URL rewrite %q, the prefix must have a scheme and a host", s)
+	}
+	return urlRewrite{prefix: prefix, replacement: replacement}, nil
+}
+
+// parseURLRewrites parses the URL rewrite rules of the configuration, the invalid ones are ignored
+func parseURLRewrites(rules []string) []urlRewrite {
+	var parsed []urlRewrite
+	for _, r := range rules {
+		rule, err := parseURLRewrite(r)
+		if err != nil {
+			log.Errorf("Daemon: ignoring URL rewrite: %v", err)
+			continue
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed
+}
+
+// splitPackageURL splits a package URL into its scheme, its host and its path, e.g. oci,
+// install.datadoghq.com and agent-package@sha256:... for oci://install.datadoghq.com/agent-package@sha256:...
+func splitPackageURL(url string) (scheme string, host string, path string) {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return "", "", url
+	}
+	host, path, _ = strings.Cut(rest, "/")
+	return scheme, host, path
+}
+
+// matches returns whether the rule applies to the URL: the URL must start with the prefix, followed by a
+// separator so that oci://install.datadoghq.com doesn't match oci://install.datadoghq.com.example.com/.
+func (r urlRewrite) matches(url string) bool {
+	if !strings.HasPrefix(url, r.prefix) {
+		return false
+	}
+	if len(url) == len(r.prefix) || strings.HasSuffix(r.prefix, "/") {
+		return true
+	}
+	next := url[len(r.prefix)]
+	if _, _, path := splitPackageURL(r.prefix); path == "" {
+		// the prefix ends with the host, followed by the path of the URL
+		return next == '/'
+	}
+	// the prefix ends with a repository, followed by its tag or digest
+	return next == '/' || next == ':' || next == '@'
+}
+
+// rewriteURL applies the first rewrite rule matching the URL of a package, before it's passed to the
+// installer. The URL is returned as is if no rule matches. The rewritten URL can't be downloaded from an
+// overridden registry, which would silently replace the registry the rule rewrote the URL to.
+func (d *daemonImpl) rewriteURL(ctx context.Context, url string) (string, error) {
+	for _, rule := range d.urlRewrites {
+		if !rule.matches(url) {
+			continue
+		}
+		rewritten := rule.replacement + strings.TrimPrefix(url, rule.prefix)
+		if override := oci.RegistryOverride(d.env, rewritten); override != "" {
+			return "", fmt.Errorf("package URL %s is rewritten to %s, which conflicts with the registry override %s", url, rewritten, override)
+		}
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("rewritten_url", rewritten)
+		}
+		log.Infof("Daemon: Rewrote package URL %s to %s", url, rewritten)
+		return rewritten, nil
+	}
+	return url, nil
+}
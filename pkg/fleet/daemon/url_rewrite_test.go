@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+func TestParseURLRewrite(t *testing.T) {
+	rule, err := parseURLRewrite(" oci://install.datadoghq.com/=oci://registry.internal/datadog/ ")
+	require.NoError(t, err)
+	assert.Equal(t, urlRewrite{prefix: "oci://install.datadoghq.com/", replacement: "oci://registry.internal/datadog/"}, rule)
+
+	for _, invalid := range []string{"", "oci://install.datadoghq.com/", "=oci://registry.internal/", "oci://install.datadoghq.com/=", "install.datadoghq.com/=oci://registry.internal/"} {
+		_, err := parseURLRewrite(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRewriteURL(t *testing.T) {
+	d := &daemonImpl{env: &env.Env{}, urlRewrites: parseURLRewrites([]string{
+		"oci://install.datadoghq.com/agent-package=oci://agent-mirror.internal/agent-package",
+		"oci://install.datadoghq.com=oci://registry.internal/datadog",
+		"invalid",
+	})}
+	rewrite := func(url string) string {
+		rewritten, err := d.rewriteURL(context.Background(), url)
+		require.NoError(t, err)
+		return rewritten
+	}
+
+	assert.Equal(t,
+		"oci://agent-mirror.internal/agent-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		rewrite("oci://install.datadoghq.com/agent-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"),
+	)
+	assert.Equal(t,
+		"oci://registry.internal/datadog/apm-inject-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		rewrite("oci://install.datadoghq.com/apm-inject-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"),
+	)
+	// the hosts and repositories are matched exactly
+	assert.Equal(t,
+		"oci://registry.internal/datadog/agent-package-dev@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		rewrite("oci://install.datadoghq.com/agent-package-dev@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"),
+	)
+	for _, url := range []string{
+		"oci://install.datadoghq.com.evil.example.com/agent-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		"oci://install.datadoghq.com:5000/agent-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85",
+		"https://install.datadoghq.com/agent-package",
+		"file:///tmp/agent-package",
+	} {
+		assert.Equal(t, url, rewrite(url))
+	}
+}
+
+func TestRewriteURLRegistryOverride(t *testing.T) {
+	d := &daemonImpl{
+		env:         &env.Env{RegistryOverrideByImage: map[string]string{"agent-package": "other-registry.internal"}},
+		urlRewrites: parseURLRewrites([]string{"oci://install.datadoghq.com/=oci://registry.internal/datadog/"}),
+	}
+
+	// the registry override would replace the host the URL is rewritten to
+	_, err := d.rewriteURL(context.Background(), "oci://install.datadoghq.com/agent-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85")
+	assert.ErrorContains(t, err, "conflicts with the registry override other-registry.internal")
+
+	rewritten, err := d.rewriteURL(context.Background(), "oci://install.datadoghq.com/apm-inject-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85")
+	require.NoError(t, err)
+	assert.Equal(t, "oci://registry.internal/datadog/apm-inject-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85", rewritten)
+}
+
+func TestInstallRewrittenURL(t *testing.T) {
+	i := newTestInstallerWithEnv(&env.Env{URLRewrites: []string{"oci://example.com/=oci://mirror.internal/example/"}})
+	defer i.Stop()
+
+	testURL := "oci://example.com/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	mirrorURL := "oci://mirror.internal/example/test-package@sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+	require.NoError(t, i.handleCatalogUpdate(catalog{Packages: []Package{{Name: "test-package", Version: "1.0.0", URL: testURL}}}))
+	i.pm.On("Install", mock.Anything, mirrorURL, []string(nil)).Return(nil).Once()
+	i.pm.On("InstallExperiment", mock.Anything, mirrorURL).Return(nil).Once()
+
+	require.NoError(t, i.Install(context.Background(), testURL, nil))
+	require.NoError(t, i.StartExperiment(context.Background(), testURL))
+	i.pm.AssertExpectations(t)
+}
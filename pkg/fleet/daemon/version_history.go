@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package daemon
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// versionHistoryFile is the file, in the packages directory, the highest installed versions are persisted to
+const versionHistoryFile = "versions.json"
+
+// versionHistory records the highest stable version ever installed for each package, so that remote
+// requests can't roll a host back to an older, possibly vulnerable, version without explicitly allowing
+// it. The versions are only kept in memory if the history has no path.
+type versionHistory struct {
+	m       sync.Mutex
+	path    string
+	highest map[string]string
+}
+
+func newVersionHistory(path string) *versionHistory {
+	return &versionHistory{
+		path:    path,
+		highest: make(map[string]string),
+	}
+}
+
+// load reads the versions persisted by the previous daemons
+func (h *versionHistory) load() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.path == "" {
+		return nil
+	}
+	rawVersions, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read version history: %w", err)
+	}
+	var highest map[string]string
+	err = json.Unmarshal(rawVersions, &highest)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal version history: %w", err)
+	}
+	for pkg, version := range highest {
+		h.recordLocked(pkg, version)
+	}
+	return nil
+}
+
+// record records an installed version of a package, it's kept if it's the highest one
+func (h *versionHistory) record(pkg string, version string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.recordLocked(pkg, version) {
+		h.persist()
+	}
+}
+
+func (h *versionHistory) recordLocked(pkg string, version string) bool {
+	if version == "" {
+		return false
+	}
+	highest, ok := h.highest[pkg]
+	if ok && compareVersions(version, highest) <= 0 {
+		return false
+	}
+	h.highest[pkg] = version
+	return true
+}
+
+// downgrade returns the highest version installed for the package if the version is lower than it
+func (h *versionHistory) downgrade(pkg string, version string) (string, bool) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	highest, ok := h.highest[pkg]
+	if !ok {
+		return "", false
+	}
+	return highest, compareVersions(version, highest) < 0
+}
+
+// persist writes the versions to disk. Failing to persist them only weakens the protection against
+// downgrades after a restart, the versions installed then are recorded again.
+func (h *versionHistory) persist() {
+	if h.path == "" {
+		return
+	}
+	err := h.write()
+	if err != nil {
+		log.Warnf("Daemon: could not persist the version history: %v", err)
+	}
+}
+
+func (h *versionHistory) write() error {
+	rawVersions, err := json.Marshal(h.highest)
+	if err != nil {
+		return fmt.Errorf("could not marshal versions: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(rawVersions)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write versions: %w", err)
+	}
+	err = os.Rename(tmpFile.Name(), h.path)
+	if err != nil {
+		return fmt.Errorf("could not move versions: %w", err)
+	}
+	return nil
+}
+
+// compareVersions compares two package versions, e.g. 7.55.1-1. The release suffix of the package isn't
+// a semver pre-release: the upstream versions are compared first, then the releases. Versions that can't
+// be parsed can't be ordered and are considered equal to the other ones.
+func compareVersions(a string, b string) int {
+	upstreamA, releaseA := splitPackageRelease(a)
+	upstreamB, releaseB := splitPackageRelease(b)
+	va, err := semver.NewVersion(upstreamA)
+	if err != nil {
+		return 0
+	}
+	vb, err := semver.NewVersion(upstreamB)
+	if err != nil {
+		return 0
+	}
+	if c := va.Compare(vb); c != 0 {
+		return c
+	}
+	return cmp.Compare(releaseA, releaseB)
+}
+
+// splitPackageRelease splits a package version into its upstream version and its numeric release
+// suffix, e.g. 7.55.1 and 1 for 7.55.1-1. Versions without a release suffix are release 0.
+func splitPackageRelease(version string) (string, int) {
+	i := strings.LastIndex(version, "-")
+	if i < 0 {
+		return version, 0
+	}
+	release, err := strconv.Atoi(version[i+1:])
+	if err != nil || release < 0 {
+		return version, 0
+	}
+	return version[:i], release
+}
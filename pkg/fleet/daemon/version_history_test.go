@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package daemon
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), versionHistoryFile)
+	h := newVersionHistory(path)
+	h.record("datadog-agent", "7.55.1-1")
+	h.record("datadog-agent", "7.56.0-1")
+	// a rollback to a lower version doesn't lower the highest version
+	h.record("datadog-agent", "7.55.1-1")
+	h.record("datadog-apm-inject", "0.10.0-1")
+
+	restored := newVersionHistory(path)
+	require.NoError(t, restored.load())
+	highest, downgrade := restored.downgrade("datadog-agent", "7.55.1-1")
+	assert.True(t, downgrade)
+	assert.Equal(t, "7.56.0-1", highest)
+	_, downgrade = restored.downgrade("datadog-agent", "7.56.0-1")
+	assert.False(t, downgrade)
+	_, downgrade = restored.downgrade("datadog-agent", "7.57.0-1")
+	assert.False(t, downgrade)
+	_, downgrade = restored.downgrade("datadog-apm-inject", "0.9.0-1")
+	assert.True(t, downgrade)
+	_, downgrade = restored.downgrade("datadog-apm-java", "1.0.0")
+	assert.False(t, downgrade)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, -1, compareVersions("7.55.1-1", "7.55.1-2"))
+	assert.Equal(t, -1, compareVersions("7.55.1-1", "7.56.0-1"))
+	assert.Equal(t, 1, compareVersions("7.100.0-1", "7.56.0-1"))
+	assert.Equal(t, 0, compareVersions("7.55.1-1", "7.55.1-1"))
+	// the release suffix isn't a pre-release and is compared as a number
+	assert.Equal(t, 1, compareVersions("7.55.1-10", "7.55.1-9"))
+	assert.Equal(t, 1, compareVersions("7.55.1-1", "7.55.1"))
+	assert.Equal(t, -1, compareVersions("7.56.0-rc.1-1", "7.56.0-1"))
+	assert.Equal(t, 1, compareVersions("7.56.0-1", "7.55.1-2"))
+	// versions that can't be parsed can't be ordered
+	assert.Equal(t, 0, compareVersions("latest", "7.55.1-1"))
+}
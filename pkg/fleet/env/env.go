@@ -8,12 +8,21 @@ package env
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
 	"github.com/DataDog/datadog-agent/pkg/config/utils"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/scrubber"
 )
 
 const (
@@ -22,9 +31,22 @@ const (
 	envRemoteUpdates         = "DD_REMOTE_UPDATES"
 	envRegistryURL           = "DD_INSTALLER_REGISTRY_URL"
 	envRegistryAuth          = "DD_INSTALLER_REGISTRY_AUTH"
+	envRegistryUsername      = "DD_INSTALLER_REGISTRY_USERNAME"
+	envRegistryPassword      = "DD_INSTALLER_REGISTRY_PASSWORD"
+	envRegistryCredHelper    = "DD_INSTALLER_REGISTRY_CREDENTIAL_HELPER"
 	envDefaultPackageVersion = "DD_INSTALLER_DEFAULT_PKG_VERSION"
 	envDefaultPackageInstall = "DD_INSTALLER_DEFAULT_PKG_INSTALL"
 	envApmLibraries          = "DD_APM_INSTRUMENTATION_LIBRARIES"
+	envRepairUnits           = "DD_INSTALLER_REPAIR_UNITS"
+	envRepairPermissions     = "DD_INSTALLER_REPAIR_PERMISSIONS"
+	envMaxDownloadBytes      = "DD_FLEET_MAX_DOWNLOAD_BYTES_PER_SEC"
+	envDeltaUpdates          = "DD_FLEET_DELTA_UPDATES"
+	envChannel               = "DD_FLEET_CHANNEL"
+	envArchiveSigningKey     = "DD_INSTALLER_ARCHIVE_SIGNING_KEY"
+	envArchiveTrustedKeys    = "DD_INSTALLER_ARCHIVE_TRUSTED_KEYS"
+	envProxyHTTP             = "DD_PROXY_HTTP"
+	envProxyHTTPS            = "DD_PROXY_HTTPS"
+	envProxyNoProxy          = "DD_PROXY_NO_PROXY"
 )
 
 var defaultEnv = Env{
@@ -71,12 +93,158 @@ type Env struct {
 	RegistryOverrideByImage     map[string]string
 	RegistryAuthOverrideByImage map[string]string
 
+	// RegistryUsername and RegistryPassword are the credentials of the password registry authentication,
+	// e.g. an Artifactory user and its token
+	RegistryUsername string
+	RegistryPassword string
+	// RegistryCredentialHelper is the docker credential helper of the helper registry authentication,
+	// the docker-credential-<helper> program being run to get the credentials of the registries
+	RegistryCredentialHelper string
+
 	DefaultPackagesInstallOverride map[string]bool
 	DefaultPackagesVersionOverride map[string]string
 
 	ApmLibraries map[ApmLibLanguage]ApmLibVersion
 
 	InstallScript InstallScriptEnv
+
+	// RemoteRequestJitter bounds the random delay applied before executing a remote request
+	RemoteRequestJitter time.Duration
+
+	// RemoteRequestWorkers is the number of remote requests executed concurrently, for different packages
+	RemoteRequestWorkers int
+
+	// RepairUnits enables the repair of the systemd units and symlinks of the packages found
+	// drifting from what the installer set up
+	RepairUnits bool
+	// RepairPermissions enables the repair of the owners and modes of the files of the packages
+	// directory found differing from what the installer set up
+	RepairPermissions bool
+
+	// SubprocessLimits are the resource limits of the installer subprocesses run by the daemon
+	SubprocessLimits SubprocessLimits
+
+	// MaintenanceWindows are the windows during which the daemon executes remote requests
+	MaintenanceWindows []string
+	// MaintenanceBlackout is the period during which the daemon doesn't execute remote requests
+	MaintenanceBlackout MaintenanceBlackout
+
+	// RolloutRing and RolloutGroups identify the host in the staged rollouts of the catalog
+	RolloutRing   string
+	RolloutGroups []string
+
+	// BlockedPackages are the packages the daemon refuses to manage on remote requests
+	BlockedPackages []string
+
+	// PinnedPackages are the versions the daemon keeps the packages on, by package name
+	PinnedPackages map[string]string
+
+	// HeartbeatInterval is the interval at which the daemon reports its state and health, 0 disables it
+	HeartbeatInterval time.Duration
+
+	// GCInterval is the interval at which the daemon garbage collects the unused packages, 0 disables it
+	GCInterval time.Duration
+
+	// ExperimentHealthProbes are the probes run during ExperimentHealthCheckDuration after starting an
+	// experiment, every ExperimentHealthCheckInterval, the experiment is stopped if one keeps failing
+	ExperimentHealthProbes        []string
+	ExperimentHealthCheckDuration time.Duration
+	ExperimentHealthCheckInterval time.Duration
+
+	// ExperimentHooks are run before and after the installs and experiments of the packages, e.g. to drain the node
+	ExperimentHooks ExperimentHooks
+
+	// URLRewrites redirect the URLs of the packages before they're installed, formatted as `<prefix>=<replacement>`
+	URLRewrites []string
+
+	// PrivilegedHelperSocket is the socket of the helper running the installer commands as root on behalf of
+	// the daemon, empty if the daemon runs them itself
+	PrivilegedHelperSocket string
+
+	// Notifications are the sinks the lifecycle events of the packages are sent to
+	Notifications Notifications
+
+	// StateReporting sets how the state of the packages is reported through remote config
+	StateReporting StateReporting
+
+	// Preflight are the checks of the host run before the installs and experiments
+	Preflight Preflight
+
+	// CatalogPath is the path of a catalog file, or directory of catalog files, mirrored on disk
+	CatalogPath string
+
+	// ArchiveSigningKey is the path of the ed25519 private key signing the exported package archives
+	ArchiveSigningKey string
+	// ArchiveTrustedKeys are the paths of the ed25519 public keys the imported package archives are verified with
+	ArchiveTrustedKeys []string
+
+	// MaxDownloadBytesPerSec limits the bandwidth of the package downloads, 0 leaves it unlimited
+	MaxDownloadBytesPerSec int64
+
+	// DeltaUpdates keeps the layers of the installed packages to reuse the ones shared by their next versions
+	DeltaUpdates bool
+
+	// Channel is the release channel the host is subscribed to, stable if empty
+	Channel string
+
+	// HTTPProxy, HTTPSProxy and NoProxy are the proxy settings of the agent, used to download packages
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    []string
+}
+
+// SubprocessLimits are resource limits of the installer subprocesses, zero values leave the
+// resource unlimited.
+type SubprocessLimits struct {
+	CPUWeight           int
+	IOWeight            int
+	IOReadBandwidthMax  string
+	IOWriteBandwidthMax string
+}
+
+// ExperimentHooks are the hooks the daemon runs around the installs and experiments of Packages, a command
+// run with `sh -c` and a URL notified with a POST, each bounded by Timeout. A failing hook fails the
+// operation if OnFailure is abort, the default, and is only logged if it is warn.
+type ExperimentHooks struct {
+	Packages  []string
+	Command   string
+	URL       string
+	Timeout   time.Duration
+	OnFailure string
+}
+
+// MaintenanceBlackout is a period during which the daemon doesn't execute remote requests, even in a
+// maintenance window. It starts immediately if Start is zero and there is none if End is zero.
+type MaintenanceBlackout struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Notifications are the sinks of the lifecycle events of the packages: a URL notified with a POST and
+// Datadog events sent through the dogstatsd server of the local agent, each delivery bounded by Timeout.
+// Failed deliveries are retried after each of the RetryBackoff delays.
+type Notifications struct {
+	URL          string
+	Events       bool
+	Timeout      time.Duration
+	RetryBackoff []time.Duration
+}
+
+// StateReporting sets how the state of the packages is reported: if Delta is set, only the packages that
+// changed since the last full report are reported, and the full state is reported every FullSyncInterval.
+type StateReporting struct {
+	Delta            bool
+	FullSyncInterval time.Duration
+}
+
+// Preflight are the checks of the host run before invoking the installer, if Enabled: the disk space
+// available for the package, the memory available, the glibc version and whether systemd is running.
+// Zero values skip the corresponding check.
+type Preflight struct {
+	Enabled         bool
+	MinMemoryBytes  uint64
+	MinGlibcVersion string
+	RequireSystemd  bool
 }
 
 // FromEnv returns an Env struct with values from the environment.
@@ -90,6 +258,9 @@ func FromEnv() *Env {
 		RegistryAuthOverride:        getEnvOrDefault(envRegistryAuth, defaultEnv.RegistryAuthOverride),
 		RegistryOverrideByImage:     overridesByNameFromEnv(envRegistryURL, func(s string) string { return s }),
 		RegistryAuthOverrideByImage: overridesByNameFromEnv(envRegistryAuth, func(s string) string { return s }),
+		RegistryUsername:            os.Getenv(envRegistryUsername),
+		RegistryPassword:            os.Getenv(envRegistryPassword),
+		RegistryCredentialHelper:    os.Getenv(envRegistryCredHelper),
 
 		DefaultPackagesInstallOverride: overridesByNameFromEnv(envDefaultPackageInstall, func(s string) bool { return s == "true" }),
 		DefaultPackagesVersionOverride: overridesByNameFromEnv(envDefaultPackageVersion, func(s string) string { return s }),
@@ -97,18 +268,133 @@ func FromEnv() *Env {
 		ApmLibraries: parseApmLibrariesEnv(),
 
 		InstallScript: installScriptEnvFromEnv(),
+
+		RepairUnits:       os.Getenv(envRepairUnits) == "true",
+		RepairPermissions: os.Getenv(envRepairPermissions) == "true",
+
+		MaxDownloadBytesPerSec: getEnvInt64(envMaxDownloadBytes),
+		DeltaUpdates:           os.Getenv(envDeltaUpdates) == "true",
+		Channel:                os.Getenv(envChannel),
+
+		ArchiveSigningKey:  os.Getenv(envArchiveSigningKey),
+		ArchiveTrustedKeys: strings.Fields(os.Getenv(envArchiveTrustedKeys)),
+
+		HTTPProxy:  os.Getenv(envProxyHTTP),
+		HTTPSProxy: os.Getenv(envProxyHTTPS),
+		NoProxy:    strings.Fields(os.Getenv(envProxyNoProxy)),
 	}
 }
 
 // FromConfig returns an Env struct with values from the configuration.
 func FromConfig(config config.Reader) *Env {
-	return &Env{
-		APIKey:               utils.SanitizeAPIKey(config.GetString("api_key")),
-		Site:                 config.GetString("site"),
-		RemoteUpdates:        config.GetBool("remote_updates"),
-		RegistryOverride:     config.GetString("installer.registry.url"),
-		RegistryAuthOverride: config.GetString("installer.registry.auth"),
+	env := &Env{
+		APIKey:                   utils.SanitizeAPIKey(config.GetString("api_key")),
+		Site:                     config.GetString("site"),
+		RemoteUpdates:            config.GetBool("remote_updates"),
+		RegistryOverride:         config.GetString("installer.registry.url"),
+		RegistryAuthOverride:     config.GetString("installer.registry.auth"),
+		RegistryUsername:         config.GetString("installer.registry.username"),
+		RegistryPassword:         config.GetString("installer.registry.password"),
+		RegistryCredentialHelper: config.GetString("installer.registry.credential_helper"),
+		RemoteRequestJitter:      config.GetDuration("installer.remote_request_jitter"),
+		RemoteRequestWorkers:     config.GetInt("installer.remote_request_workers"),
+		RepairUnits:              config.GetBool("installer.repair_units"),
+		RepairPermissions:        config.GetBool("installer.repair_permissions"),
+		SubprocessLimits: SubprocessLimits{
+			CPUWeight:           config.GetInt("installer.subprocess_limits.cpu_weight"),
+			IOWeight:            config.GetInt("installer.subprocess_limits.io_weight"),
+			IOReadBandwidthMax:  config.GetString("installer.subprocess_limits.io_read_bandwidth_max"),
+			IOWriteBandwidthMax: config.GetString("installer.subprocess_limits.io_write_bandwidth_max"),
+		},
+		MaintenanceWindows: config.GetStringSlice("fleet.maintenance_windows"),
+		RolloutRing:        config.GetString("installer.rollout.ring"),
+		RolloutGroups:      config.GetStringSlice("installer.rollout.groups"),
+		CatalogPath:        config.GetString("fleet.catalog_path"),
+		ArchiveSigningKey:  config.GetString("installer.archive.signing_key"),
+		ArchiveTrustedKeys: config.GetStringSlice("installer.archive.trusted_keys"),
+		BlockedPackages:    config.GetStringSlice("installer.blocked_packages"),
+		PinnedPackages:     config.GetStringMapString("fleet.pinned_packages"),
+		HeartbeatInterval:  config.GetDuration("installer.heartbeat_interval"),
+		GCInterval:         config.GetDuration("installer.gc_interval"),
+
+		ExperimentHealthProbes:        config.GetStringSlice("installer.experiment_health_check.probes"),
+		ExperimentHealthCheckDuration: config.GetDuration("installer.experiment_health_check.duration"),
+		ExperimentHealthCheckInterval: config.GetDuration("installer.experiment_health_check.interval"),
+		ExperimentHooks: ExperimentHooks{
+			Packages:  config.GetStringSlice("installer.experiment_hooks.packages"),
+			Command:   config.GetString("installer.experiment_hooks.command"),
+			URL:       config.GetString("installer.experiment_hooks.url"),
+			Timeout:   config.GetDuration("installer.experiment_hooks.timeout"),
+			OnFailure: config.GetString("installer.experiment_hooks.on_failure"),
+		},
+		URLRewrites:            config.GetStringSlice("installer.url_rewrites"),
+		PrivilegedHelperSocket: config.GetString("installer.privileged_helper.socket"),
+		Notifications: Notifications{
+			URL:     config.GetString("installer.notifications.url"),
+			Events:  config.GetBool("installer.notifications.events"),
+			Timeout: config.GetDuration("installer.notifications.timeout"),
+		},
+		StateReporting: StateReporting{
+			Delta:            config.GetBool("installer.state_reporting.delta"),
+			FullSyncInterval: config.GetDuration("installer.state_reporting.full_sync_interval"),
+		},
+		Preflight: Preflight{
+			Enabled:         config.GetBool("installer.preflight.enabled"),
+			MinMemoryBytes:  uint64(config.GetSizeInBytes("installer.preflight.min_memory")),
+			MinGlibcVersion: config.GetString("installer.preflight.min_glibc_version"),
+			RequireSystemd:  config.GetBool("installer.preflight.require_systemd"),
+		},
+
+		MaxDownloadBytesPerSec: config.GetInt64("fleet.max_download_bytes_per_sec"),
+		DeltaUpdates:           config.GetBool("fleet.delta_updates"),
+		Channel:                config.GetString("fleet.channel"),
+	}
+	env.MaintenanceBlackout = maintenanceBlackoutFromConfig(config)
+	retryBackoff, err := config.GetDurationSliceE("installer.notifications.retry_backoff")
+	if err != nil {
+		log.Warnf("ignoring the notifications retry backoff: %v", err)
 	}
+	env.Notifications.RetryBackoff = retryBackoff
+	if proxies := config.GetProxies(); proxies != nil {
+		env.HTTPProxy = proxies.HTTP
+		env.HTTPSProxy = proxies.HTTPS
+		env.NoProxy = proxies.NoProxy
+	}
+	return env
+}
+
+// maintenanceBlackoutFromConfig reads the maintenance blackout, an invalid one is ignored
+func maintenanceBlackoutFromConfig(config config.Reader) MaintenanceBlackout {
+	if config.GetString("fleet.maintenance_blackout.end") == "" {
+		return MaintenanceBlackout{}
+	}
+	location, err := time.LoadLocation(config.GetString("fleet.maintenance_blackout.timezone"))
+	if err != nil {
+		log.Warnf("ignoring the maintenance blackout: %v", err)
+		return MaintenanceBlackout{}
+	}
+	opts := []model.TimeOption{
+		model.WithTimeLayouts(time.RFC3339, "2006-01-02 15:04", "2006-01-02"),
+		model.WithTimeLocation(location),
+	}
+	var blackout MaintenanceBlackout
+	if config.GetString("fleet.maintenance_blackout.start") != "" {
+		blackout.Start, err = config.GetTimeE("fleet.maintenance_blackout.start", opts...)
+		if err != nil {
+			log.Warnf("ignoring the maintenance blackout: %v", err)
+			return MaintenanceBlackout{}
+		}
+	}
+	blackout.End, err = config.GetTimeE("fleet.maintenance_blackout.end", opts...)
+	if err != nil {
+		log.Warnf("ignoring the maintenance blackout: %v", err)
+		return MaintenanceBlackout{}
+	}
+	if !blackout.End.After(blackout.Start) {
+		log.Warnf("ignoring the maintenance blackout: it ends at %s before it starts at %s", blackout.End, blackout.Start)
+		return MaintenanceBlackout{}
+	}
+	return blackout
 }
 
 // ToEnv returns a slice of environment variables from the Env struct.
@@ -129,6 +415,45 @@ func (e *Env) ToEnv() []string {
 	if e.RegistryAuthOverride != "" {
 		env = append(env, envRegistryAuth+"="+e.RegistryAuthOverride)
 	}
+	if e.RegistryUsername != "" {
+		env = append(env, envRegistryUsername+"="+e.RegistryUsername)
+	}
+	if e.RegistryPassword != "" {
+		env = append(env, envRegistryPassword+"="+e.RegistryPassword)
+	}
+	if e.RegistryCredentialHelper != "" {
+		env = append(env, envRegistryCredHelper+"="+e.RegistryCredentialHelper)
+	}
+	if e.RepairUnits {
+		env = append(env, envRepairUnits+"=true")
+	}
+	if e.RepairPermissions {
+		env = append(env, envRepairPermissions+"=true")
+	}
+	if e.MaxDownloadBytesPerSec > 0 {
+		env = append(env, envMaxDownloadBytes+"="+strconv.FormatInt(e.MaxDownloadBytesPerSec, 10))
+	}
+	if e.DeltaUpdates {
+		env = append(env, envDeltaUpdates+"=true")
+	}
+	if e.Channel != "" {
+		env = append(env, envChannel+"="+e.Channel)
+	}
+	if e.ArchiveSigningKey != "" {
+		env = append(env, envArchiveSigningKey+"="+e.ArchiveSigningKey)
+	}
+	if len(e.ArchiveTrustedKeys) > 0 {
+		env = append(env, envArchiveTrustedKeys+"="+strings.Join(e.ArchiveTrustedKeys, " "))
+	}
+	if e.HTTPProxy != "" {
+		env = append(env, envProxyHTTP+"="+e.HTTPProxy)
+	}
+	if e.HTTPSProxy != "" {
+		env = append(env, envProxyHTTPS+"="+e.HTTPSProxy)
+	}
+	if len(e.NoProxy) > 0 {
+		env = append(env, envProxyNoProxy+"="+strings.Join(e.NoProxy, " "))
+	}
 	if len(e.ApmLibraries) > 0 {
 		libraries := []string{}
 		for l, v := range e.ApmLibraries {
@@ -148,6 +473,46 @@ func (e *Env) ToEnv() []string {
 	return env
 }
 
+// ToRedactedEnv returns the sorted environment variables of ToEnv with their secrets redacted,
+// so that they can be logged or reported remotely.
+func (e *Env) ToRedactedEnv() []string {
+	redacted := *e
+	if redacted.APIKey != "" {
+		redacted.APIKey = scrubber.HideKeyExceptLastFiveChars(redacted.APIKey)
+	}
+	if redacted.RegistryPassword != "" {
+		redacted.RegistryPassword = "********"
+	}
+	env := redacted.ToEnv()
+	for i, e := range env {
+		// registry URLs may contain credentials
+		key, value, _ := strings.Cut(e, "=")
+		env[i] = key + "=" + scrubber.ScrubLine(value)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// HTTPClient returns the HTTP client used to download packages. It goes through the proxies of
+// the agent when they are configured, and through the proxies of the standard environment
+// variables otherwise.
+func (e *Env) HTTPClient() *http.Client {
+	if e.HTTPProxy == "" && e.HTTPSProxy == "" && len(e.NoProxy) == 0 {
+		return http.DefaultClient
+	}
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  e.HTTPProxy,
+		HTTPSProxy: e.HTTPSProxy,
+		NoProxy:    strings.Join(e.NoProxy, ","),
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}
+	return &http.Client{Transport: transport}
+}
+
 func parseApmLibrariesEnv() map[ApmLibLanguage]ApmLibVersion {
 	apmLibraries := os.Getenv(envApmLibraries)
 	apmLibrariesVersion := map[ApmLibLanguage]ApmLibVersion{}
@@ -189,6 +554,14 @@ func overridesByNameToEnv[T any](envPrefix string, overridesByPackage map[string
 	return env
 }
 
+func getEnvInt64(env string) int64 {
+	value, err := strconv.ParseInt(os.Getenv(env), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
 func getEnvOrDefault(env string, defaultValue string) string {
 	value := os.Getenv(env)
 	if value == "" {
@@ -0,0 +1,435 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package helper provides the privileged helper running the installer commands on behalf of a daemon
+// that doesn't run as root, and its client.
+//
+// The helper listens on a unix socket only the allowed user can connect to, checked through the
+// credentials of the peer, and only runs the allowed commands of its own installer binary. The
+// requests are typed operations the helper builds the command arguments from: packages are only
+// installed from the registry the helper is configured with, pinned to their digest. The commands
+// run with the configuration of the helper, the daemon only passes their telemetry context.
+package helper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/limits"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+	"github.com/DataDog/datadog-agent/pkg/fleet/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// DefaultSocketPath is the default path of the socket of the helper.
+	DefaultSocketPath = "/opt/datadog-packages/installer-helper.sock"
+	// DefaultAllowedUser is the default user allowed to connect to the helper, root always is.
+	DefaultAllowedUser = "dd-agent"
+	// DefaultInstallerPath is the default installer run by the helper, the stable installer. The link
+	// is resolved on each request, so that the installer promoted since the helper started is run.
+	DefaultInstallerPath = "/opt/datadog-packages/datadog-installer/stable/bin/installer/installer"
+
+	runRoute = "/run"
+)
+
+// DefaultAllowedCommands are the installer commands run by the daemon the helper allows by default, the
+// ones managing the packages. The export and import commands, reading and writing arbitrary paths, can't
+// be run through the helper.
+var DefaultAllowedCommands = []string{
+	"install",
+	"remove",
+	"install-experiment",
+	"remove-experiment",
+	"promote-experiment",
+	"rollback",
+	"garbage-collect",
+	"check-permissions",
+	"is-installed",
+	"default-packages",
+	"apm instrument",
+	"apm uninstrument",
+}
+
+// OptInCommands are the installer commands the helper only allows once explicitly configured to, as they
+// change the credentials, the configuration or the availability of the host rather than its packages.
+var OptInCommands = []string{
+	"rotate-api-key",
+	"reboot",
+	"set-integration-configs",
+	"rollback-integration-configs",
+}
+
+// AllowedCommands returns the given allowed commands along with the opt-in commands enabled, which must
+// be part of OptInCommands.
+func AllowedCommands(allowedCommands []string, optInCommands []string) ([]string, error) {
+	allowed := slices.Clone(allowedCommands)
+	for _, command := range optInCommands {
+		if !slices.Contains(OptInCommands, command) {
+			return nil, fmt.Errorf("command %s can't be opted in, opt-in commands are %s", command, strings.Join(OptInCommands, ", "))
+		}
+		if !slices.Contains(allowed, command) {
+			allowed = append(allowed, command)
+		}
+	}
+	return allowed, nil
+}
+
+var (
+	imageNamePattern = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*$`)
+	digestPattern    = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	packagePattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// rootUID is always allowed to run commands, it's overridden in tests
+var rootUID uint32
+
+// interruptGracePeriod is the time left to the installer to exit once the request is canceled, before
+// it's killed
+var interruptGracePeriod = 30 * time.Second
+
+// Image is a package image of the registry the helper is configured with, pinned to its digest.
+type Image struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// RunRequest is a request to run an installer command. The helper builds the arguments of the command
+// from the fields of the request that apply to it.
+type RunRequest struct {
+	Command string `json:"command"`
+	// Image is the package installed by install and install-experiment, and DeltaBase the package
+	// it's patched from, if any
+	Image     *Image `json:"image,omitempty"`
+	DeltaBase *Image `json:"delta_base,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+	// Package is the package of remove, remove-experiment, promote-experiment, rollback and is-installed
+	Package string `json:"package,omitempty"`
+	// Method is the instrumentation method of apm instrument and apm uninstrument
+	Method string `json:"method,omitempty"`
+	// Repair makes check-permissions repair the issues it finds
+	Repair bool `json:"repair,omitempty"`
+	// Stdin is the standard input of the command, e.g. the API key of rotate-api-key
+	Stdin string `json:"stdin,omitempty"`
+	// Env is the telemetry context of the command, other variables are ignored
+	Env []string `json:"env,omitempty"`
+}
+
+// RunResponse is the result of an installer command.
+type RunResponse struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// errorResponse is the response of the helper to the requests it refuses or fails to run.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type connKey struct{}
+
+// Server is the privileged helper.
+type Server struct {
+	installerPath string
+	env           *env.Env
+	allowed       []string
+	allowedUID    uint32
+	listener      net.Listener
+	server        *http.Server
+}
+
+// NewServer returns a helper listening on the given socket, running the allowed commands of the given
+// installer binary with the given environment for root and the allowed user.
+func NewServer(socketPath string, installerPath string, env *env.Env, allowedCommands []string, allowedUser string) (*Server, error) {
+	u, err := user.Lookup(allowedUser)
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup allowed user: %w", err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse uid of the allowed user: %w", err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse gid of the allowed user: %w", err)
+	}
+	err = os.RemoveAll(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not remove socket: %w", err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	// only the group of the allowed user can connect, the peer is then checked on each request
+	if err := os.Chown(socketPath, os.Getuid(), gid); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("could not set socket owner: %w", err)
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("could not set socket permissions: %w", err)
+	}
+	s := &Server{
+		installerPath: installerPath,
+		env:           env,
+		allowed:       allowedCommands,
+		allowedUID:    uint32(uid),
+		listener:      listener,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(runRoute, s.run)
+	s.server = &http.Server{
+		Handler: mux,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+	return s, nil
+}
+
+// Start starts serving the requests.
+func (s *Server) Start() {
+	go func() {
+		err := s.server.Serve(s.listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Helper: server stopped: %v", err)
+		}
+	}()
+}
+
+// Stop stops the helper, the commands running are interrupted.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// run runs an installer command
+// example: curl -X POST --unix-socket /opt/datadog-packages/installer-helper.sock -H 'Content-Type: application/json' http://helper/run -d '{"command":"garbage-collect"}'
+func (s *Server) run(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	conn, _ := r.Context().Value(connKey{}).(net.Conn)
+	uid, err := peerUID(conn)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("could not authenticate peer: %w", err))
+		return
+	}
+	if uid != rootUID && uid != s.allowedUID {
+		log.Warnf("Helper: refused request from uid %d", uid)
+		writeError(w, http.StatusForbidden, fmt.Errorf("uid %d is not allowed", uid))
+		return
+	}
+	var request RunRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err))
+		return
+	}
+	if !slices.Contains(s.allowed, request.Command) {
+		log.Warnf("Helper: refused command %s from uid %d", request.Command, uid)
+		writeError(w, http.StatusForbidden, fmt.Errorf("command %s is not allowed", request.Command))
+		return
+	}
+
+	args, err := s.commandArgs(request)
+	if err != nil {
+		log.Warnf("Helper: refused invalid %s request from uid %d: %v", request.Command, uid, err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// the stable installer may have been promoted since the last request
+	installerPath, err := filepath.EvalSymlinks(s.installerPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("could not resolve installer path: %w", err))
+		return
+	}
+
+	log.Infof("Helper: running installer %s %s for uid %d", request.Command, strings.Join(args, " "), uid)
+	name, cmdArgs := installerPath, append([]string{request.Command}, args...)
+	if scopeArgs := limits.ScopeArgs(s.env.SubprocessLimits); len(scopeArgs) > 0 {
+		name, cmdArgs = "systemd-run", append(append(scopeArgs, "--", installerPath), cmdArgs...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(r.Context(), name, cmdArgs...)
+	cmd.Env = append(append(os.Environ(), s.env.ToEnv()...), filterEnv(request.Env)...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = interruptGracePeriod
+	cmd.Stdin = strings.NewReader(request.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("could not run installer %s: %w", request.Command, err))
+		return
+	}
+	response := RunResponse{
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}
+	log.Infof("Helper: installer %s exited with code %d", request.Command, response.ExitCode)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+}
+
+// commandArgs returns the arguments of the installer command of a request. The daemon can't make the
+// helper install a package from a URL of its choice, e.g. a local file or another registry.
+func (s *Server) commandArgs(request RunRequest) ([]string, error) {
+	switch request.Command {
+	case "install", "install-experiment":
+		url, err := s.imageURL(request.Image)
+		if err != nil {
+			return nil, err
+		}
+		var args []string
+		if request.DryRun {
+			args = append(args, "--dry-run")
+		}
+		if request.DeltaBase != nil {
+			baseURL, err := s.imageURL(request.DeltaBase)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "--delta-from", baseURL)
+		}
+		return append(args, url), nil
+	case "remove", "remove-experiment", "promote-experiment", "rollback", "is-installed":
+		if !packagePattern.MatchString(request.Package) {
+			return nil, fmt.Errorf("invalid package %q", request.Package)
+		}
+		return []string{request.Package}, nil
+	case "apm instrument", "apm uninstrument":
+		if request.DryRun {
+			// only the modification of the docker daemon configuration can be previewed
+			if request.Method != env.APMInstrumentationEnabledDocker {
+				return nil, fmt.Errorf("invalid dry run instrumentation method %q", request.Method)
+			}
+			return []string{"--dry-run", request.Method}, nil
+		}
+		switch request.Method {
+		case env.APMInstrumentationEnabledAll, env.APMInstrumentationEnabledDocker, env.APMInstrumentationEnabledHost:
+			return []string{request.Method}, nil
+		}
+		return nil, fmt.Errorf("invalid instrumentation method %q", request.Method)
+	case "check-permissions":
+		if request.Repair {
+			return []string{"--repair"}, nil
+		}
+		return nil, nil
+	case "garbage-collect", "default-packages", "rotate-api-key", "reboot", "set-integration-configs", "rollback-integration-configs":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("command %s can't be run through the helper", request.Command)
+	}
+}
+
+// imageURL returns the URL of an image of the registry of the helper
+func (s *Server) imageURL(image *Image) (string, error) {
+	if image == nil {
+		return "", fmt.Errorf("missing package image")
+	}
+	if !imageNamePattern.MatchString(image.Name) {
+		return "", fmt.Errorf("invalid image name %q", image.Name)
+	}
+	if !digestPattern.MatchString(image.Digest) {
+		return "", fmt.Errorf("invalid image digest %q", image.Digest)
+	}
+	return oci.PackageDigestURL(s.env, image.Name, image.Digest), nil
+}
+
+// filterEnv keeps the telemetry context of the command, the commands run with the configuration of
+// the helper and the caller can't change how they're run, e.g. through LD_PRELOAD or the registry.
+func filterEnv(env []string) []string {
+	var filtered []string
+	for _, variable := range env {
+		name, _, ok := strings.Cut(variable, "=")
+		if !ok {
+			continue
+		}
+		if name == telemetry.EnvTraceID || name == telemetry.EnvParentID {
+			filtered = append(filtered, variable)
+		}
+	}
+	return filtered
+}
+
+// Client runs the installer commands through the helper.
+type Client struct {
+	client *http.Client
+}
+
+// NewClient returns a client of the helper listening on the given socket.
+func NewClient(socketPath string) *Client {
+	return &Client{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Run runs an installer command through the helper. The command failing isn't an error, its exit code
+// is returned in the response.
+func (c *Client) Run(ctx context.Context, request RunRequest) (*RunResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	// the host has no meaning when using a unix socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://helper"+runRoute, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach privileged helper: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return nil, fmt.Errorf("privileged helper returned status %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("privileged helper refused command %s: %s", request.Command, errResp.Error)
+	}
+	var response RunResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode privileged helper response: %w", err)
+	}
+	return &response, nil
+}
@@ -0,0 +1,190 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// the peer credentials of the helper connections are only supported on linux
+//go:build linux
+
+package helper
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fakeinstaller"
+)
+
+const testDigest = "sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+
+func TestMain(m *testing.M) {
+	fakeinstaller.Main()
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T, allowedCommands ...string) (*Server, *Client) {
+	return newTestServerWithInstaller(t, fakeinstaller.Path(t), allowedCommands...)
+}
+
+func newTestServerWithInstaller(t *testing.T, installerPath string, allowedCommands ...string) (*Server, *Client) {
+	current, err := user.Current()
+	require.NoError(t, err)
+	// the temporary directories of the tests may exceed the length limit of the socket paths
+	dir, err := os.MkdirTemp("", "helper")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "helper.sock")
+
+	s, err := NewServer(socketPath, installerPath, &env.Env{Site: "datadoghq.com"}, allowedCommands, current.Username)
+	require.NoError(t, err)
+	s.Start()
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	return s, NewClient(socketPath)
+}
+
+func TestHelperRun(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls, Stdout: "report", Stderr: "failed", ExitCode: 3})
+	_, client := newTestServer(t, "install")
+
+	resp, err := client.Run(context.Background(), RunRequest{Command: "install", Image: &Image{Name: "agent-package", Digest: testDigest}})
+	require.NoError(t, err)
+	assert.Equal(t, &RunResponse{ExitCode: 3, Stdout: "report", Stderr: "failed"}, resp)
+	rawCalls, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	// the package is installed from the registry of the helper
+	assert.Equal(t, "install oci://gcr.io/datadoghq/agent-package@"+testDigest+"\n", string(rawCalls))
+}
+
+func TestHelperRefusesInvalidRequests(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	_, client := newTestServer(t, DefaultAllowedCommands...)
+
+	for _, request := range []RunRequest{
+		{Command: "install"},
+		{Command: "install", Image: &Image{Name: "agent-package", Digest: "latest"}},
+		{Command: "install", Image: &Image{Name: "../agent-package", Digest: testDigest}},
+		{Command: "install-experiment", Image: &Image{Name: "file:///tmp/evil", Digest: testDigest}},
+		{Command: "install-experiment", Image: &Image{Name: "agent-package", Digest: testDigest}, DeltaBase: &Image{Name: "agent-package"}},
+		{Command: "remove", Package: "--help"},
+		{Command: "apm instrument", Method: "everything"},
+		{Command: "apm instrument", Method: "host", DryRun: true},
+	} {
+		_, err := client.Run(context.Background(), request)
+		assert.ErrorContains(t, err, "privileged helper refused", request)
+	}
+	assert.NoFileExists(t, calls)
+}
+
+func TestHelperResolvesInstallerLink(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	link := filepath.Join(t.TempDir(), "installer")
+	require.NoError(t, os.Symlink(fakeinstaller.Path(t), link))
+	_, client := newTestServerWithInstaller(t, link, "garbage-collect")
+
+	_, err := client.Run(context.Background(), RunRequest{Command: "garbage-collect"})
+	require.NoError(t, err)
+
+	// the link is resolved again on the next request, e.g. once the installer is promoted
+	require.NoError(t, os.Remove(link))
+	require.NoError(t, os.Symlink(filepath.Join(t.TempDir(), "removed"), link))
+	_, err = client.Run(context.Background(), RunRequest{Command: "garbage-collect"})
+	assert.ErrorContains(t, err, "could not resolve installer path")
+	rawCalls, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, "garbage-collect\n", string(rawCalls))
+}
+
+func TestHelperRefusesCommand(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	_, client := newTestServer(t, DefaultAllowedCommands...)
+
+	_, err := client.Run(context.Background(), RunRequest{Command: "export", Package: "datadog-agent"})
+	assert.ErrorContains(t, err, "command export is not allowed")
+	assert.NoFileExists(t, calls)
+}
+
+func TestHelperRefusesOptInCommands(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	_, client := newTestServer(t, DefaultAllowedCommands...)
+
+	for _, command := range OptInCommands {
+		_, err := client.Run(context.Background(), RunRequest{Command: command})
+		assert.ErrorContains(t, err, "command "+command+" is not allowed")
+	}
+	assert.NoFileExists(t, calls)
+}
+
+func TestHelperRunsOptedInCommands(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	allowedCommands, err := AllowedCommands(DefaultAllowedCommands, []string{"reboot"})
+	require.NoError(t, err)
+	_, client := newTestServer(t, allowedCommands...)
+
+	_, err = client.Run(context.Background(), RunRequest{Command: "reboot"})
+	require.NoError(t, err)
+	_, err = client.Run(context.Background(), RunRequest{Command: "rotate-api-key", Stdin: "newkey"})
+	assert.ErrorContains(t, err, "command rotate-api-key is not allowed")
+	rawCalls, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, "reboot\n", string(rawCalls))
+}
+
+func TestAllowedCommands(t *testing.T) {
+	allowedCommands, err := AllowedCommands(DefaultAllowedCommands, nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAllowedCommands, allowedCommands)
+
+	allowedCommands, err = AllowedCommands([]string{"install"}, []string{"reboot", "rotate-api-key", "reboot"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"install", "reboot", "rotate-api-key"}, allowedCommands)
+
+	// only the commands not managing packages can be opted in, never e.g. export
+	_, err = AllowedCommands(DefaultAllowedCommands, []string{"export"})
+	assert.ErrorContains(t, err, "command export can't be opted in")
+}
+
+func TestHelperRefusesPeer(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	s, client := newTestServer(t, "install")
+	oldRootUID := rootUID
+	defer func() { rootUID = oldRootUID }()
+	rootUID = uint32(os.Getuid()) + 1
+	s.allowedUID = uint32(os.Getuid()) + 2
+
+	_, err := client.Run(context.Background(), RunRequest{Command: "install", Image: &Image{Name: "agent-package", Digest: testDigest}})
+	assert.ErrorContains(t, err, "is not allowed")
+	assert.NoFileExists(t, calls)
+}
+
+func TestFilterEnv(t *testing.T) {
+	assert.Equal(t, []string{
+		"DATADOG_TRACE_ID=1",
+		"DATADOG_PARENT_ID=2",
+	}, filterEnv([]string{
+		"DD_SITE=datadoghq.eu",
+		"DD_INSTALLER_REGISTRY_URL=attacker.example.com",
+		"LD_PRELOAD=/tmp/lib.so",
+		"PATH=/tmp",
+		"DATADOG_TRACE_ID=1",
+		"DATADOG_PARENT_ID=2",
+		"invalid",
+	}))
+}
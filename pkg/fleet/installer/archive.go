@@ -0,0 +1,720 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/db"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/version"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Package archives are gzipped tarballs of an installed package, used to transfer packages to hosts
+// that can't reach a registry. They start with a manifest describing every entry of the archive (its
+// type, mode, the SHA256 checksum of the regular files and the target of the symlinks) and its ed25519
+// signature. Archives are signed with the private key of the exporting host and only imported by hosts
+// trusting its public key, and entries that don't match the manifest fail the import.
+const (
+	archiveManifestName  = "manifest.json"
+	archiveSignatureName = "manifest.json.sig"
+	// archivePackageDir and archiveConfigDir hold the files of the package and of its configuration
+	archivePackageDir = "package"
+	archiveConfigDir  = "config"
+
+	archiveMaxManifestSize = 32 << 20 // 32MiB
+
+	archiveEntryDir     = "dir"
+	archiveEntryFile    = "file"
+	archiveEntrySymlink = "symlink"
+
+	// archiveModeMask keeps the permissions and the setuid, setgid and sticky bits of the entries
+	archiveModeMask = 07777
+)
+
+// archiveManifest describes the content of a package archive.
+type archiveManifest struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	// Entries describe the entries of the archive, by path in the archive
+	Entries map[string]archiveManifestEntry `json:"entries"`
+}
+
+// archiveManifestEntry describes an entry of a package archive.
+type archiveManifestEntry struct {
+	Type string `json:"type"`
+	// Mode holds the unix permissions of the entry, including the setuid, setgid and sticky bits
+	Mode int64 `json:"mode"`
+	// SHA256 is the checksum of the regular files
+	SHA256 string `json:"sha256,omitempty"`
+	// Link is the target of the symlinks
+	Link string `json:"link,omitempty"`
+}
+
+// archiveEntry is a file to add to an archive
+type archiveEntry struct {
+	name   string
+	path   string
+	header *tar.Header
+}
+
+// Export writes an archive of an installed version of a package, and of its configuration, to the given path.
+func (i *installerImpl) Export(ctx context.Context, pkg string, version string, archivePath string) (err error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	span, ok := tracer.SpanFromContext(ctx)
+	if ok {
+		span.SetTag(ext.ResourceName, pkg)
+		span.SetTag("package_version", version)
+	}
+	if i.archiveSigningKey == "" {
+		return fmt.Errorf("could not export package: a signing key is required to sign the archive")
+	}
+	signingKey, err := readArchiveSigningKey(i.archiveSigningKey)
+	if err != nil {
+		return fmt.Errorf("could not read archive signing key: %w", err)
+	}
+
+	repository := i.repositories.Get(pkg)
+	state, err := repository.GetState()
+	if err != nil {
+		return fmt.Errorf("could not get package state: %w", err)
+	}
+	var packagePath string
+	switch {
+	case version != "" && version == state.Stable:
+		packagePath = repository.StablePath()
+	case version != "" && version == state.Experiment:
+		packagePath = repository.ExperimentPath()
+	default:
+		return fmt.Errorf("could not export package: version %s of package %s is not installed", version, pkg)
+	}
+	packagePath, err = filepath.EvalSymlinks(packagePath)
+	if err != nil {
+		return fmt.Errorf("could not resolve package path: %w", err)
+	}
+
+	entries, err := listArchiveEntries(archivePackageDir, packagePath)
+	if err != nil {
+		return fmt.Errorf("could not list package files: %w", err)
+	}
+	configEntries, err := listArchiveEntries(archiveConfigDir, filepath.Join(i.configsDir, pkg))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not list package config files: %w", err)
+	}
+	entries = append(entries, configEntries...)
+
+	manifest := archiveManifest{
+		Package: pkg,
+		Version: version,
+		Entries: map[string]archiveManifestEntry{},
+	}
+	for _, entry := range entries {
+		manifestEntry := archiveManifestEntry{
+			Mode: entry.header.Mode & archiveModeMask,
+		}
+		switch entry.header.Typeflag {
+		case tar.TypeDir:
+			manifestEntry.Type = archiveEntryDir
+		case tar.TypeSymlink:
+			manifestEntry.Type = archiveEntrySymlink
+			manifestEntry.Link = entry.header.Linkname
+		case tar.TypeReg:
+			manifestEntry.Type = archiveEntryFile
+			manifestEntry.SHA256, err = fileChecksum(entry.path)
+			if err != nil {
+				return fmt.Errorf("could not compute checksum of %s: %w", entry.path, err)
+			}
+		default:
+			return fmt.Errorf("could not export package: unsupported file type for %s", entry.path)
+		}
+		manifest.Entries[entry.name] = manifestEntry
+	}
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("could not marshal archive manifest: %w", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, rawManifest))
+
+	f, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create archive: %w", err)
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("could not close archive: %w", closeErr)
+		}
+		if err != nil {
+			os.Remove(archivePath)
+		}
+	}()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	err = writeArchiveFile(tw, archiveManifestName, rawManifest)
+	if err != nil {
+		return err
+	}
+	err = writeArchiveFile(tw, archiveSignatureName, []byte(signature))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = writeArchiveEntry(tw, entry)
+		if err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("could not write archive: %w", err)
+	}
+	if err = gw.Close(); err != nil {
+		return fmt.Errorf("could not write archive: %w", err)
+	}
+	log.Infof("package %s version %s exported to %s", pkg, version, archivePath)
+	return nil
+}
+
+// ImportArchive installs the package of an archive written by Export as the stable version of the package.
+func (i *installerImpl) ImportArchive(ctx context.Context, archivePath string, args []string) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	start := time.Now()
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("could not open archive: %w", err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("could not read archive: %w", err)
+	}
+	tr := tar.NewReader(gr)
+	manifest, err := readArchiveManifest(tr, i.archiveTrustedKeys)
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	span, ok := tracer.SpanFromContext(ctx)
+	if ok {
+		span.SetTag(ext.ResourceName, manifest.Package)
+		span.SetTag("package_version", manifest.Version)
+	}
+
+	var dbPkg db.Package
+	err = i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+		dbPkg, err = packagesDB.GetPackage(manifest.Package)
+		return err
+	})
+	if err != nil && !errors.Is(err, db.ErrPackageNotFound) {
+		return fmt.Errorf("could not get package: %w", err)
+	}
+	if dbPkg.Name == manifest.Package && dbPkg.Version == manifest.Version {
+		log.Infof("package %s version %s is already installed", manifest.Package, manifest.Version)
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp(i.tmpDirPath, fmt.Sprintf("tmp-import-%s-*", manifest.Package)) // * is replaced by a random string
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = extractArchive(tr, manifest, tmpDir, i.packageStore(manifest.Package))
+	if err != nil {
+		return fmt.Errorf("could not extract archive: %w", err)
+	}
+	packageDir := filepath.Join(tmpDir, archivePackageDir)
+	bytesWritten, err := dirSize(packageDir)
+	if err != nil {
+		return fmt.Errorf("could not compute package size: %w", err)
+	}
+	err = copyDir(filepath.Join(tmpDir, archiveConfigDir), filepath.Join(i.configsDir, manifest.Package))
+	if err != nil {
+		return fmt.Errorf("could not copy package config: %w", err)
+	}
+	err = i.repositories.Create(ctx, manifest.Package, manifest.Version, packageDir)
+	if err != nil {
+		return fmt.Errorf("could not create repository: %w", err)
+	}
+	i.recordUsage(ctx, "import", &oci.DownloadedPackage{Name: manifest.Package, Version: manifest.Version}, bytesWritten, start)
+	i.checkPrerequisites(ctx, manifest.Package, manifest.Version)
+	err = i.setupPackage(ctx, manifest.Package, args)
+	if err != nil {
+		return fmt.Errorf("could not setup package: %w", err)
+	}
+	i.checkRebootRequired(manifest.Package)
+	err = i.withDB(func(packagesDB *db.PackagesDB) error {
+		return packagesDB.SetPackage(db.Package{
+			Name:             manifest.Package,
+			Version:          manifest.Version,
+			InstallerVersion: version.AgentVersion,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("could not store package installation in db: %w", err)
+	}
+	return nil
+}
+
+// listArchiveEntries lists the files under the given root, named after their path relative to it in
+// the given archive directory.
+func listArchiveEntries(archiveDir string, root string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	err := filepath.WalkDir(root, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		entry := archiveEntry{name: path.Join(archiveDir, filepath.ToSlash(rel)), path: p}
+		entry.header, err = archiveEntryHeader(entry)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func archiveEntryHeader(entry archiveEntry) (*tar.Header, error) {
+	info, err := os.Lstat(entry.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat %s: %w", entry.path, err)
+	}
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err = os.Readlink(entry.path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read link %s: %w", entry.path, err)
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, fmt.Errorf("could not create archive header for %s: %w", entry.path, err)
+	}
+	header.Name = entry.name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	return header, nil
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(content)),
+	})
+	if err != nil {
+		return fmt.Errorf("could not write %s to archive: %w", name, err)
+	}
+	_, err = tw.Write(content)
+	if err != nil {
+		return fmt.Errorf("could not write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+func writeArchiveEntry(tw *tar.Writer, entry archiveEntry) error {
+	err := tw.WriteHeader(entry.header)
+	if err != nil {
+		return fmt.Errorf("could not write %s to archive: %w", entry.path, err)
+	}
+	if entry.header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", entry.path, err)
+	}
+	defer f.Close()
+	// the file must not change between its checksum and its copy, the header holds its size
+	_, err = io.CopyN(tw, f, entry.header.Size)
+	if err != nil {
+		return fmt.Errorf("could not write %s to archive: %w", entry.path, err)
+	}
+	return nil
+}
+
+// readArchiveManifest reads the manifest at the start of an archive and checks its signature against
+// the trusted keys
+func readArchiveManifest(tr *tar.Reader, trustedKeyPaths []string) (archiveManifest, error) {
+	var manifest archiveManifest
+	if len(trustedKeyPaths) == 0 {
+		return manifest, fmt.Errorf("trusted keys are required to verify the archive signature")
+	}
+	trustedKeys, err := readArchiveTrustedKeys(trustedKeyPaths)
+	if err != nil {
+		return manifest, fmt.Errorf("could not read trusted keys: %w", err)
+	}
+	rawManifest, err := readArchiveFile(tr, archiveManifestName)
+	if err != nil {
+		return manifest, err
+	}
+	rawSignature, err := readArchiveFile(tr, archiveSignatureName)
+	if err != nil {
+		return manifest, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(rawSignature))
+	if err != nil {
+		return manifest, fmt.Errorf("could not decode archive signature: %w", err)
+	}
+	trusted := slices.ContainsFunc(trustedKeys, func(key ed25519.PublicKey) bool {
+		return ed25519.Verify(key, rawManifest, signature)
+	})
+	if !trusted {
+		return manifest, fmt.Errorf("archive signature doesn't match any trusted key")
+	}
+	err = json.Unmarshal(rawManifest, &manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("could not unmarshal archive manifest: %w", err)
+	}
+	if manifest.Package == "" || manifest.Version == "" {
+		return manifest, fmt.Errorf("archive manifest is missing the package name or version")
+	}
+	if strings.ContainsAny(manifest.Package+manifest.Version, `/\`) || strings.Contains(manifest.Package+manifest.Version, "..") {
+		return manifest, fmt.Errorf("invalid package name %s or version %s in archive manifest", manifest.Package, manifest.Version)
+	}
+	return manifest, nil
+}
+
+func readArchiveFile(tr *tar.Reader, name string) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("expected %s, got %s", name, header.Name)
+	}
+	content, err := io.ReadAll(io.LimitReader(tr, archiveMaxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return content, nil
+}
+
+// readArchiveSigningKey reads an ed25519 private key, PEM encoded in PKCS #8
+func readArchiveSigningKey(keyPath string) (ed25519.PrivateKey, error) {
+	block, err := readPEMBlock(keyPath, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", keyPath, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", keyPath)
+	}
+	return privateKey, nil
+}
+
+// readArchiveTrustedKeys reads ed25519 public keys, PEM encoded in PKIX
+func readArchiveTrustedKeys(keyPaths []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, keyPath := range keyPaths {
+		block, err := readPEMBlock(keyPath, "PUBLIC KEY")
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", keyPath, err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an ed25519 public key", keyPath)
+		}
+		keys = append(keys, publicKey)
+	}
+	return keys, nil
+}
+
+func readPEMBlock(p string, blockType string) (*pem.Block, error) {
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s doesn't hold a PEM encoded %s", p, strings.ToLower(blockType))
+	}
+	return block, nil
+}
+
+// extractArchive extracts the entries of an archive to the destination path, checking their type and
+// mode against the manifest, and the content of the regular files against their checksum before
+// writing them. The files of the package are linked to the store.
+func extractArchive(tr *tar.Reader, manifest archiveManifest, destinationPath string, store *cas.Store) error {
+	for _, dir := range []string{archivePackageDir, archiveConfigDir} {
+		err := os.MkdirAll(filepath.Join(destinationPath, dir), 0755)
+		if err != nil {
+			return fmt.Errorf("could not create directory: %w", err)
+		}
+	}
+	extracted := make(map[string]struct{}, len(manifest.Entries))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tar header: %w", err)
+		}
+		name := strings.TrimSuffix(header.Name, "/")
+		if !strings.HasPrefix(name, archivePackageDir+"/") && !strings.HasPrefix(name, archiveConfigDir+"/") &&
+			name != archivePackageDir && name != archiveConfigDir {
+			return fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+		manifestEntry, ok := manifest.Entries[name]
+		if !ok {
+			return fmt.Errorf("archive entry %s is missing from the manifest", name)
+		}
+		if _, ok := extracted[name]; ok {
+			return fmt.Errorf("archive entry %s is duplicated", name)
+		}
+		if err := checkArchiveHeader(header, manifestEntry); err != nil {
+			return fmt.Errorf("archive entry %s doesn't match the manifest: %w", name, err)
+		}
+		target := filepath.Join(destinationPath, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, filepath.Clean(destinationPath)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %s is trying to escape the destination directory", header.Name)
+		}
+		if err := checkNoSymlinkInPath(destinationPath, filepath.Dir(target)); err != nil {
+			return fmt.Errorf("could not extract %s: %w", name, err)
+		}
+		mode := header.FileInfo().Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = extractArchiveDir(target, mode)
+		case tar.TypeReg:
+			err = extractArchiveFile(tr, target, mode, manifestEntry.SHA256, destinationPath, store, strings.HasPrefix(name, archivePackageDir+"/"))
+		case tar.TypeSymlink:
+			err = os.Symlink(header.Linkname, target)
+		}
+		if err != nil {
+			return fmt.Errorf("could not extract %s: %w", name, err)
+		}
+		extracted[name] = struct{}{}
+	}
+	for name := range manifest.Entries {
+		if _, ok := extracted[name]; !ok {
+			return fmt.Errorf("archive entry %s of the manifest is missing", name)
+		}
+	}
+	return nil
+}
+
+// checkArchiveHeader checks the type and mode of an archive entry, and the target of the symlinks,
+// against the manifest
+func checkArchiveHeader(header *tar.Header, manifestEntry archiveManifestEntry) error {
+	var entryType string
+	switch header.Typeflag {
+	case tar.TypeDir:
+		entryType = archiveEntryDir
+	case tar.TypeReg:
+		entryType = archiveEntryFile
+	case tar.TypeSymlink:
+		entryType = archiveEntrySymlink
+	default:
+		return fmt.Errorf("unsupported entry type %d", header.Typeflag)
+	}
+	if entryType != manifestEntry.Type {
+		return fmt.Errorf("expected a %s, got a %s", manifestEntry.Type, entryType)
+	}
+	if header.Mode&^archiveModeMask != 0 || header.Mode != manifestEntry.Mode {
+		return fmt.Errorf("expected mode %#o, got %#o", manifestEntry.Mode, header.Mode)
+	}
+	if header.Typeflag == tar.TypeSymlink && header.Linkname != manifestEntry.Link {
+		return fmt.Errorf("expected a link to %s, got %s", manifestEntry.Link, header.Linkname)
+	}
+	return nil
+}
+
+func extractArchiveDir(target string, mode fs.FileMode) error {
+	info, err := os.Lstat(target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = os.Mkdir(target, mode)
+	case err == nil && !info.IsDir():
+		return fmt.Errorf("%s already exists and isn't a directory", target)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chmod(target, mode)
+}
+
+// extractArchiveFile stages a regular file of an archive next to the destination path and checks its
+// checksum, before moving it to its target or linking it to the store.
+func extractArchiveFile(reader io.Reader, target string, mode fs.FileMode, checksum string, destinationPath string, store *cas.Store, linkToStore bool) error {
+	staged, err := os.CreateTemp(destinationPath, ".staged-*")
+	if err != nil {
+		return fmt.Errorf("could not create staging file: %w", err)
+	}
+	defer func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}()
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(staged, h), reader)
+	if err != nil {
+		return fmt.Errorf("could not write staging file: %w", err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != checksum {
+		return fmt.Errorf("checksum doesn't match the manifest")
+	}
+	_, err = staged.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("could not read staging file: %w", err)
+	}
+	if linkToStore {
+		return store.Link(target, staged, mode)
+	}
+	return writeArchiveTarget(target, staged, mode)
+}
+
+// checkNoSymlinkInPath checks that none of the directories from root to the given path is a symlink,
+// so that files aren't written through symlinks out of root
+func checkNoSymlinkInPath(root string, p string) error {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("%s is outside of %s", p, root)
+	}
+	current := root
+	for _, component := range strings.Split(rel, string(os.PathSeparator)) {
+		if component == "." {
+			continue
+		}
+		current = filepath.Join(current, component)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write through symlink %s", current)
+		}
+	}
+	return nil
+}
+
+// writeArchiveTarget writes a file extracted from an archive, replacing the existing target without
+// following it if it's a symlink
+func writeArchiveTarget(target string, reader io.Reader, mode fs.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// O_EXCL fails on any existing target, including symlinks, instead of following them
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, reader)
+	if err != nil {
+		return err
+	}
+	// the mode is set explicitly as the umask may have cleared some of its bits
+	return f.Chmod(mode)
+}
+
+// copyDir copies the files of the source directory over the destination directory, without writing
+// through the symlinks of the destination directory
+func copyDir(source string, destination string) error {
+	return filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if d.IsDir() {
+			err := os.MkdirAll(target, mode)
+			if err != nil {
+				return err
+			}
+			return checkNoSymlinkInPath(destination, target)
+		}
+		if err := checkNoSymlinkInPath(destination, filepath.Dir(target)); err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeArchiveTarget(target, f, mode)
+		}
+	})
+}
+
+func fileChecksum(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,223 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
+)
+
+func TestExportImportArchive(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	exporter := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	exporter.archiveSigningKey, exporter.archiveTrustedKeys = newArchiveKeys(t)
+	err := exporter.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+
+	archivePath := filepath.Join(t.TempDir(), "package.tar.gz")
+	err = exporter.Export(testCtx, fixtures.FixtureSimpleV1.Package, fixtures.FixtureSimpleV2.Version, archivePath)
+	assert.Error(t, err, "the version isn't installed")
+	err = exporter.Export(testCtx, fixtures.FixtureSimpleV1.Package, fixtures.FixtureSimpleV1.Version, archivePath)
+	require.NoError(t, err)
+
+	importer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	importer.archiveTrustedKeys = exporter.archiveTrustedKeys
+	err = importer.ImportArchive(testCtx, archivePath, nil)
+	require.NoError(t, err)
+	r := importer.repositories.Get(fixtures.FixtureSimpleV1.Package)
+	state, err := r.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, state.Stable)
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), r.StableFS())
+	fixtures.AssertEqualFS(t, s.ConfigFS(fixtures.FixtureSimpleV1), importer.ConfigFS(fixtures.FixtureSimpleV1))
+	installed, err := importer.IsInstalled(testCtx, fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.True(t, installed)
+}
+
+func TestImportArchiveSignature(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	exporter := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	exporter.archiveSigningKey, exporter.archiveTrustedKeys = newArchiveKeys(t)
+	err := exporter.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	archivePath := filepath.Join(t.TempDir(), "package.tar.gz")
+	err = exporter.Export(testCtx, fixtures.FixtureSimpleV1.Package, fixtures.FixtureSimpleV1.Version, archivePath)
+	require.NoError(t, err)
+
+	importer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	_, importer.archiveTrustedKeys = newArchiveKeys(t)
+	err = importer.ImportArchive(testCtx, archivePath, nil)
+	assert.ErrorContains(t, err, "signature")
+	state, err := importer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.False(t, state.HasStable())
+}
+
+func TestImportArchiveChecksum(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	exporter := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	exporter.archiveSigningKey, exporter.archiveTrustedKeys = newArchiveKeys(t)
+	err := exporter.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	archivePath := filepath.Join(t.TempDir(), "package.tar.gz")
+	err = exporter.Export(testCtx, fixtures.FixtureSimpleV1.Package, fixtures.FixtureSimpleV1.Version, archivePath)
+	require.NoError(t, err)
+
+	// Rewrite the archive, keeping its signed manifest but altering the content of the package files
+	tamperedPath := filepath.Join(t.TempDir(), "tampered.tar.gz")
+	rewriteArchive(t, archivePath, tamperedPath, func(header *tar.Header, content []byte) []byte {
+		if header.Typeflag == tar.TypeReg && header.Name != archiveManifestName && header.Name != archiveSignatureName {
+			return append(content, []byte("tampered")...)
+		}
+		return content
+	})
+
+	importer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	importer.archiveTrustedKeys = exporter.archiveTrustedKeys
+	err = importer.ImportArchive(testCtx, tamperedPath, nil)
+	assert.ErrorContains(t, err, "checksum")
+	state, err := importer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.False(t, state.HasStable())
+}
+
+func TestImportArchiveMode(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	exporter := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	exporter.archiveSigningKey, exporter.archiveTrustedKeys = newArchiveKeys(t)
+	err := exporter.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	archivePath := filepath.Join(t.TempDir(), "package.tar.gz")
+	err = exporter.Export(testCtx, fixtures.FixtureSimpleV1.Package, fixtures.FixtureSimpleV1.Version, archivePath)
+	require.NoError(t, err)
+
+	// Rewrite the archive, keeping its signed manifest but making the package files setuid
+	tamperedPath := filepath.Join(t.TempDir(), "tampered.tar.gz")
+	rewriteArchive(t, archivePath, tamperedPath, func(header *tar.Header, content []byte) []byte {
+		if header.Typeflag == tar.TypeReg && strings.HasPrefix(header.Name, archivePackageDir+"/") {
+			header.Mode |= 04000
+		}
+		return content
+	})
+
+	importer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	importer.archiveTrustedKeys = exporter.archiveTrustedKeys
+	err = importer.ImportArchive(testCtx, tamperedPath, nil)
+	assert.ErrorContains(t, err, "mode")
+	state, err := importer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.False(t, state.HasStable())
+}
+
+func TestExtractArchiveRefusesSymlinkParents(t *testing.T) {
+	outside := t.TempDir()
+	content := []byte("content")
+	checksum := sha256.Sum256(content)
+	manifest := archiveManifest{
+		Package: "package",
+		Version: "1.0.0",
+		Entries: map[string]archiveManifestEntry{
+			"package/link":      {Type: archiveEntrySymlink, Mode: 0777, Link: outside},
+			"package/link/file": {Type: archiveEntryFile, Mode: 0644, SHA256: hex.EncodeToString(checksum[:])},
+		},
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "package/link", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "package/link/file", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	err = extractArchive(tar.NewReader(&buf), manifest, t.TempDir(), cas.NewStore(t.TempDir()))
+	assert.ErrorContains(t, err, "symlink")
+	assert.NoFileExists(t, filepath.Join(outside, "file"))
+}
+
+// newArchiveKeys writes an ed25519 key pair and returns the path of its private key and of its public key
+func newArchiveKeys(t *testing.T) (string, []string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	rawPrivateKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	rawPublicKey, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	privateKeyPath := filepath.Join(dir, "archive.key")
+	publicKeyPath := filepath.Join(dir, "archive.pub")
+	require.NoError(t, os.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawPrivateKey}), 0600))
+	require.NoError(t, os.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rawPublicKey}), 0644))
+	return privateKeyPath, []string{publicKeyPath}
+}
+
+func rewriteArchive(t *testing.T, sourcePath string, destinationPath string, rewrite func(header *tar.Header, content []byte) []byte) {
+	source, err := os.Open(sourcePath)
+	require.NoError(t, err)
+	defer source.Close()
+	gr, err := gzip.NewReader(source)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	destination, err := os.Create(destinationPath)
+	require.NoError(t, err)
+	defer destination.Close()
+	gw := gzip.NewWriter(destination)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		content = rewrite(header, content)
+		header.Size = int64(len(content))
+		require.NoError(t, tw.WriteHeader(header))
+		_, err = tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// layerCacheDir is the directory of the packages directory holding the compressed layers of the
+// installed packages, reused by delta updates
+const layerCacheDir = ".layers"
+
+type deltaBaseKey struct{}
+
+// WithDeltaBase returns a context updating a package as a delta from the package at the base URL,
+// the version installed on the host. The layers the new version shares with it aren't downloaded
+// again if they're in the layer cache.
+func WithDeltaBase(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, deltaBaseKey{}, baseURL)
+}
+
+// DeltaBase returns the URL of the package a context updates from, empty if none.
+func DeltaBase(ctx context.Context) string {
+	baseURL, _ := ctx.Value(deltaBaseKey{}).(string)
+	return baseURL
+}
+
+// download downloads a package, through the layer cache if delta updates are enabled.
+func (i *installerImpl) download(ctx context.Context, url string) (*oci.DownloadedPackage, error) {
+	pkg, err := i.downloader.Download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if i.layers == nil {
+		return pkg, nil
+	}
+	var base *oci.DownloadedPackage
+	if baseURL := DeltaBase(ctx); baseURL != "" {
+		base, err = i.downloader.Download(ctx, baseURL)
+		if err != nil {
+			// the update falls back to a full download
+			log.Warnf("could not get delta base package %s: %v", baseURL, err)
+		} else if base.Name != pkg.Name {
+			log.Warnf("ignoring delta base package %s for package %s", base.Name, pkg.Name)
+			base = nil
+		}
+	}
+	err = i.layers.Use(pkg, base)
+	if err != nil {
+		return nil, fmt.Errorf("could not use layer cache: %w", err)
+	}
+	delta := pkg.Delta()
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag("delta.reused_layers", delta.ReusedLayers)
+		span.SetTag("delta.reused_bytes", delta.ReusedBytes)
+		span.SetTag("delta.downloaded_bytes", delta.DownloadedBytes)
+	}
+	if base != nil {
+		log.Infof("updating package %s from version %s to %s reuses %d layers (%d bytes), downloading %d layers (%d bytes)", pkg.Name, base.Version, pkg.Version, delta.ReusedLayers, delta.ReusedBytes, delta.DownloadedLayers, delta.DownloadedBytes)
+	}
+	return pkg, nil
+}
+
+// retainLayers keeps the cached layers of an installed package for the next delta updates.
+func (i *installerImpl) retainLayers(pkg *oci.DownloadedPackage) {
+	if i.layers == nil {
+		return
+	}
+	err := i.layers.Retain(pkg)
+	if err != nil {
+		log.Warnf("could not retain the layers of package %s: %v", pkg.Name, err)
+	}
+}
+
+// pruneLayers removes the cached layers no installed version uses anymore.
+func (i *installerImpl) pruneLayers() error {
+	if i.layers == nil {
+		return nil
+	}
+	states, err := i.repositories.GetState()
+	if err != nil {
+		return fmt.Errorf("could not get package states: %w", err)
+	}
+	keep := make(map[string][]string)
+	for pkg, state := range states {
+		if state.HasStable() {
+			keep[pkg] = append(keep[pkg], state.Stable)
+		}
+		if state.HasExperiment() {
+			keep[pkg] = append(keep[pkg], state.Experiment)
+		}
+	}
+	err = i.layers.Prune(keep)
+	if err != nil {
+		return fmt.Errorf("could not prune layer cache: %w", err)
+	}
+	return nil
+}
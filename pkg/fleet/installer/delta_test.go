@@ -0,0 +1,61 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+)
+
+func TestInstallExperimentDelta(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	rootPath := t.TempDir()
+	installer := newTestPackageManager(t, s, rootPath, t.TempDir())
+	installer.layers = oci.NewLayerCache(filepath.Join(rootPath, layerCacheDir))
+
+	err := installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	refs, err := filepath.Glob(filepath.Join(rootPath, layerCacheDir, "refs", "simple", "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(rootPath, layerCacheDir, "refs", "simple", "v1")}, refs)
+
+	ctx := WithDeltaBase(testCtx, s.PackageURL(fixtures.FixtureSimpleV1))
+	err = installer.InstallExperiment(ctx, s.PackageURL(fixtures.FixtureSimpleV2))
+	require.NoError(t, err)
+	r := installer.repositories.Get(fixtures.FixtureSimpleV1.Package)
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV2), r.ExperimentFS())
+
+	// the layers of the versions no longer installed are pruned
+	err = installer.PromoteExperiment(testCtx, fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	err = installer.GarbageCollect(testCtx)
+	require.NoError(t, err)
+	refs, err = filepath.Glob(filepath.Join(rootPath, layerCacheDir, "refs", "simple", "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(rootPath, layerCacheDir, "refs", "simple", "v2")}, refs)
+
+	// the layer cache isn't reported as a package
+	states, err := installer.States()
+	require.NoError(t, err)
+	assert.Len(t, states, 1)
+	_, err = os.Stat(filepath.Join(rootPath, layerCacheDir))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/db"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+)
+
+// DryRunReport describes what an install or an experiment would change on the host. It's built from
+// the manifest of the package, the layers of the package aren't downloaded.
+type DryRunReport struct {
+	// Package and Version are the package and the version the URL resolves to
+	Package string `json:"package"`
+	Version string `json:"version"`
+	// Digest is the digest of the image of the package resolved for the platform of the host
+	Digest   string `json:"digest"`
+	Platform string `json:"platform"`
+	// Stable and Experiment are the versions of the package currently installed
+	Stable     string `json:"stable,omitempty"`
+	Experiment string `json:"experiment,omitempty"`
+	// Changes are the changes the operation would make, empty if there is nothing to do
+	Changes []string `json:"changes"`
+	// RequiredDiskSpace and AvailableDiskSpace are in bytes, on the disk of the packages
+	RequiredDiskSpace  uint64 `json:"required_disk_space"`
+	AvailableDiskSpace uint64 `json:"available_disk_space"`
+}
+
+// InstallDryRun checks that a package could be installed and reports what the install would change,
+// without changing anything.
+func (i *installerImpl) InstallDryRun(ctx context.Context, url string) (*DryRunReport, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	pkg, report, err := i.dryRun(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range packageDependencies[pkg.Name] {
+		installed, err := i.IsInstalled(ctx, dependency)
+		if err != nil {
+			return nil, fmt.Errorf("could not check if required package %s is installed: %w", dependency, err)
+		}
+		if !installed {
+			return nil, fmt.Errorf("required package %s is not installed", dependency)
+		}
+	}
+	var dbPkg db.Package
+	err = i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+		dbPkg, err = packagesDB.GetPackage(pkg.Name)
+		return err
+	})
+	if err != nil && !errors.Is(err, db.ErrPackageNotFound) {
+		return nil, fmt.Errorf("could not get package: %w", err)
+	}
+	if dbPkg.Name == pkg.Name && dbPkg.Version == pkg.Version {
+		return report, nil
+	}
+	err = checkAvailableDiskSpace(pkg, i.packagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("not enough disk space: %w", err)
+	}
+	if report.Stable == "" {
+		report.Changes = append(report.Changes, fmt.Sprintf("install %s %s", pkg.Name, pkg.Version))
+	} else {
+		report.Changes = append(report.Changes, fmt.Sprintf("replace stable %s %s with %s", pkg.Name, report.Stable, pkg.Version))
+	}
+	if report.Experiment != "" {
+		report.Changes = append(report.Changes, fmt.Sprintf("remove experiment %s %s", pkg.Name, report.Experiment))
+	}
+	return report, nil
+}
+
+// InstallExperimentDryRun checks that an experiment could be installed and reports what installing it
+// would change, without changing anything.
+func (i *installerImpl) InstallExperimentDryRun(ctx context.Context, url string) (*DryRunReport, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	pkg, report, err := i.dryRun(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if report.Stable == "" {
+		return nil, installerErrors.Wrap(
+			installerErrors.ErrInvalidState,
+			fmt.Errorf("package %s has no stable version to experiment on", pkg.Name),
+		)
+	}
+	err = checkAvailableDiskSpace(pkg, i.packagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("not enough disk space: %w", err)
+	}
+	if report.Experiment != "" {
+		report.Changes = append(report.Changes, fmt.Sprintf("replace experiment %s %s with %s", pkg.Name, report.Experiment, pkg.Version))
+	} else {
+		report.Changes = append(report.Changes, fmt.Sprintf("start experiment %s %s on top of stable %s", pkg.Name, pkg.Version, report.Stable))
+	}
+	// the experiments are started by systemctl commands on linux, they're recorded instead of run.
+	// The hooks of the other platforms aren't dry run.
+	if runtime.GOOS == "linux" {
+		dryRunCtx := service.WithSystemctlDryRun(ctx)
+		err = i.startExperiment(dryRunCtx, pkg.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not dry run the start of the experiment: %w", err)
+		}
+		for _, command := range service.SystemctlDryRunCommands(dryRunCtx) {
+			report.Changes = append(report.Changes, "run "+command)
+		}
+	}
+	return report, nil
+}
+
+// dryRun resolves the package of the URL for the platform of the host and reports its current state.
+// The digest of the package is checked against the one of the URL by the download of its manifest.
+func (i *installerImpl) dryRun(ctx context.Context, url string) (*oci.DownloadedPackage, *DryRunReport, error) {
+	pkg, err := i.downloader.Download(ctx, url)
+	if err != nil {
+		return nil, nil, installerErrors.Wrap(installerErrors.ErrDownloadFailed, fmt.Errorf("could not download package: %w", err))
+	}
+	digest, err := pkg.Image.Digest()
+	if err != nil {
+		return nil, nil, installerErrors.Wrap(installerErrors.ErrInvalidHash, fmt.Errorf("could not compute package digest: %w", err))
+	}
+	state, err := i.repositories.GetPackageState(pkg.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get package state: %w", err)
+	}
+	report := &DryRunReport{
+		Package:           pkg.Name,
+		Version:           pkg.Version,
+		Digest:            digest.String(),
+		Platform:          fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		Stable:            state.Stable,
+		Experiment:        state.Experiment,
+		Changes:           []string{},
+		RequiredDiskSpace: requiredDiskSpace(pkg),
+	}
+	if _, err := os.Stat(i.packagesDir); err == nil {
+		if usage, err := fsDisk.GetUsage(i.packagesDir); err == nil {
+			report.AvailableDiskSpace = usage.Available
+		}
+	}
+	return pkg, report, nil
+}
@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package installer
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
+)
+
+func TestInstallDryRun(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+
+	report, err := installer.InstallDryRun(testCtx, s.PackageURL(fixtures.FixtureSimpleV1))
+	require.NoError(t, err)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Package, report.Package)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, report.Version)
+	assert.NotEmpty(t, report.Digest)
+	assert.Empty(t, report.Stable)
+	assert.Equal(t, []string{"install simple v1"}, report.Changes)
+
+	// nothing was installed
+	state, err := installer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.False(t, state.HasStable())
+
+	err = installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	report, err = installer.InstallDryRun(testCtx, s.PackageURL(fixtures.FixtureSimpleV1))
+	require.NoError(t, err)
+	assert.Empty(t, report.Changes)
+	report, err = installer.InstallDryRun(testCtx, s.PackageURL(fixtures.FixtureSimpleV2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"replace stable simple v1 with v2"}, report.Changes)
+}
+
+func TestInstallExperimentDryRun(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+
+	_, err := installer.InstallExperimentDryRun(testCtx, s.PackageURL(fixtures.FixtureSimpleV2))
+	assert.Equal(t, installerErrors.ErrInvalidState, installerErrors.From(err).Code())
+
+	err = installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	report, err := installer.InstallExperimentDryRun(testCtx, s.PackageURL(fixtures.FixtureSimpleV2))
+	require.NoError(t, err)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, report.Stable)
+	assert.Equal(t, []string{"start experiment simple v2 on top of stable v1"}, report.Changes)
+
+	// the experiment wasn't started
+	state, err := installer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.False(t, state.HasExperiment())
+}
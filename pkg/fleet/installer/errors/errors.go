@@ -7,7 +7,9 @@
 package errors
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
 )
 
 // InstallerErrorCode is an error code used by the installer.
@@ -27,6 +29,28 @@ const (
 	ErrPackageNotFound
 	// ErrUpdateExperimentFailed is the code for an update experiment failure.
 	ErrUpdateExperimentFailed
+	// ErrExperimentUnhealthy is the code for an experiment failing its smoke tests.
+	ErrExperimentUnhealthy
+	// ErrPrerequisitesNotMet is the code for a package whose host prerequisites aren't satisfied.
+	ErrPrerequisitesNotMet
+	// ErrKeyringUnavailable is the code for signing keys that can't be loaded or don't match the pinned ones.
+	ErrKeyringUnavailable
+	// ErrInvalidSignature is the code for repository metadata or a package failing its signature checks.
+	ErrInvalidSignature
+	// ErrPackageBlocked is the code for a remote request targeting a package blocked on the host.
+	ErrPackageBlocked
+	// ErrPackagePinned is the code for a remote request that would move a package away from the version it's pinned to.
+	ErrPackagePinned
+	// ErrPackageDowngrade is the code for a remote request that would install a version lower than the highest one installed.
+	ErrPackageDowngrade
+	// ErrPackageChannel is the code for a remote request for a package of a channel the host isn't subscribed to.
+	ErrPackageChannel
+	// ErrExperimentExpired is the code for an experiment stopped as it was neither promoted nor stopped before its TTL.
+	ErrExperimentExpired
+	// ErrPreflightFailed is the code for a host failing the preflight checks run before invoking the installer.
+	ErrPreflightFailed
+	// ErrInvalidPermissions is the code for files of the packages whose owner or mode isn't the one set by the installer.
+	ErrInvalidPermissions
 )
 
 // InstallerError is an error type used by the installer.
@@ -70,17 +94,53 @@ func Wrap(errCode InstallerErrorCode, err error) error {
 }
 
 // From returns a new InstallerError from the given error.
+// If an InstallerError is wrapped in the given error, its code is kept.
 func From(err error) *InstallerError {
 	if err == nil {
 		return nil
 	}
 
-	e, ok := err.(*InstallerError)
-	if !ok {
-		return &InstallerError{
-			err:  err,
-			code: errUnknown,
-		}
+	if e, ok := err.(*InstallerError); ok {
+		return e
 	}
-	return e
+	code := errUnknown
+	var e *InstallerError
+	if errors.As(err, &e) {
+		code = e.code
+	}
+	return &InstallerError{
+		err:  err,
+		code: code,
+	}
+}
+
+// installerErrorJSON is the format of the errors reported by the installer subprocesses on stderr
+type installerErrorJSON struct {
+	Error string             `json:"error"`
+	Code  InstallerErrorCode `json:"code"`
+}
+
+// ToJSON returns the error as a JSON string, written by the installer subprocesses on their
+// last line of stderr so that the daemon gets its code back.
+func ToJSON(err error) string {
+	installerErr := From(err)
+	rawErr, _ := json.Marshal(installerErrorJSON{
+		Error: installerErr.Error(),
+		Code:  installerErr.Code(),
+	})
+	return string(rawErr)
+}
+
+// FromJSON returns the error reported by an installer subprocess in the given JSON string, and
+// whether one was found.
+func FromJSON(errStr string) (*InstallerError, bool) {
+	var jsonError installerErrorJSON
+	err := json.Unmarshal([]byte(strings.TrimSpace(errStr)), &jsonError)
+	if err != nil || jsonError.Error == "" {
+		return nil, false
+	}
+	return &InstallerError{
+		err:  errors.New(jsonError.Error),
+		code: jsonError.Code,
+	}, true
 }
@@ -24,6 +24,14 @@ func TestFrom(t *testing.T) {
 	})
 
 	assert.Nil(t, From(nil))
+
+	// The code of a wrapped InstallerError is kept along with the full message
+	wrapped := fmt.Errorf("could not setup package: %w", Wrap(ErrPrerequisitesNotMet, fmt.Errorf("test: test")))
+	taskErr = From(wrapped)
+	assert.Equal(t, ErrPrerequisitesNotMet, taskErr.Code())
+	assert.Equal(t, "could not setup package: test: test", taskErr.Error())
+
+	assert.Equal(t, errUnknown, From(fmt.Errorf("test: test")).Code())
 }
 
 func TestWrap(t *testing.T) {
@@ -42,3 +50,25 @@ func TestWrap(t *testing.T) {
 		code: ErrDownloadFailed,
 	})
 }
+
+func TestJSON(t *testing.T) {
+	err := fmt.Errorf("could not install: %w", Wrap(ErrDownloadFailed, fmt.Errorf("test: test")))
+	errStr := ToJSON(err)
+	assert.JSONEq(t, `{"error":"could not install: test: test","code":2}`, errStr)
+
+	taskErr, ok := FromJSON(errStr + "\n")
+	assert.True(t, ok)
+	assert.Equal(t, ErrDownloadFailed, taskErr.Code())
+	assert.Equal(t, "could not install: test: test", taskErr.Error())
+
+	// Unknown errors keep their message
+	taskErr, ok = FromJSON(ToJSON(fmt.Errorf("test: test")))
+	assert.True(t, ok)
+	assert.Equal(t, errUnknown, taskErr.Code())
+	assert.Equal(t, "test: test", taskErr.Error())
+
+	for _, notJSON := range []string{"", "Error: test", `{"code":2}`, `{"error":`} {
+		_, ok = FromJSON(notJSON)
+		assert.False(t, ok, notJSON)
+	}
+}
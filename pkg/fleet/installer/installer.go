@@ -10,7 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,8 +18,10 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/db"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
 	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
@@ -33,10 +35,31 @@ const (
 	packageDatadogAgent     = "datadog-agent"
 	packageAPMInjector      = "datadog-apm-inject"
 	packageDatadogInstaller = "datadog-installer"
+
+	// rebootRequiredMarker is the file a package ships at its root when its installation
+	// needs a reboot of the host to be fully applied, its content is the reason
+	rebootRequiredMarker = "reboot-required"
+
+	// storeDir is the directory of the packages directory holding the objects shared by the
+	// extracted packages, it must be on the same filesystem as the packages to be hard linked
+	storeDir = ".store"
+
+	// partialsDir is the directory of the packages directory holding the layers of the interrupted
+	// downloads, resumed by the next downloads of the packages
+	partialsDir = ".partial"
+	// partialsMaxAge is the time after which the partial layers that weren't resumed are removed
+	partialsMaxAge = 7 * 24 * time.Hour
+
+	// dbTimeout is the time to wait for the packages db to be released by another installer
+	dbTimeout = 10 * time.Second
 )
 
 var (
 	fsDisk = filesystem.NewDisk()
+	// restartAgent restarts the agent to load new integration configurations, it's overridden in tests
+	restartAgent = service.RestartAgent
+	// checkPrerequisites checks the host prerequisites of a package, it's overridden in tests
+	checkPrerequisites = service.CheckPrerequisites
 )
 
 // Installer is a package manager that installs and uninstalls packages.
@@ -46,29 +69,59 @@ type Installer interface {
 	States() (map[string]repository.State, error)
 
 	Install(ctx context.Context, url string, args []string) error
+	InstallDryRun(ctx context.Context, url string) (*DryRunReport, error)
 	Remove(ctx context.Context, pkg string) error
 	Purge(ctx context.Context)
 
 	InstallExperiment(ctx context.Context, url string) error
+	InstallExperimentDryRun(ctx context.Context, url string) (*DryRunReport, error)
 	RemoveExperiment(ctx context.Context, pkg string) error
 	PromoteExperiment(ctx context.Context, pkg string) error
+	Rollback(ctx context.Context, pkg string) error
+
+	Export(ctx context.Context, pkg string, version string, path string) error
+	ImportArchive(ctx context.Context, path string, args []string) error
 
 	GarbageCollect(ctx context.Context) error
+	CheckPermissions(ctx context.Context) ([]repository.PermissionIssue, error)
+	RepairPermissions(ctx context.Context) ([]repository.PermissionIssue, error)
 
 	InstrumentAPMInjector(ctx context.Context, method string) error
 	UninstrumentAPMInjector(ctx context.Context, method string) error
+	DiffAPMInjectorDocker(ctx context.Context, uninstrument bool) (service.FileDiff, error)
+
+	RotateAPIKey(ctx context.Context, apiKey string) error
+	Reboot(ctx context.Context) error
+
+	SetIntegrationConfigs(ctx context.Context, version string, files map[string][]byte) error
+	RollbackIntegrationConfigs(ctx context.Context) error
+}
+
+// IntegrationConfigs is a version of the integration configurations delivered remotely. The files are
+// keyed by their path relative to the conf.d directory, e.g. nginx.d/conf.yaml.
+type IntegrationConfigs struct {
+	Version string            `json:"version"`
+	Files   map[string][]byte `json:"files"`
 }
 
 // installerImpl is the implementation of the package manager.
 type installerImpl struct {
 	m sync.Mutex
 
-	db           *db.PackagesDB
+	dbPath       string
 	downloader   *oci.Downloader
 	repositories *repository.Repositories
+	store        *cas.Store
+	layers       *oci.LayerCache
+	confdOverlay *repository.ConfdOverlay
+	repairUnits  bool
+	repairPerms  bool
 	configsDir   string
 	packagesDir  string
 	tmpDirPath   string
+
+	archiveSigningKey  string
+	archiveTrustedKeys []string
 }
 
 // NewInstaller returns a new Package Manager.
@@ -77,20 +130,53 @@ func NewInstaller(env *env.Env) (Installer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not ensure packages directory exists: %w", err)
 	}
-	db, err := db.New(filepath.Join(PackagesPath, "packages.db"), db.WithTimeout(10*time.Second))
+	dbPath := filepath.Join(PackagesPath, "packages.db")
+	packagesDB, err := openPackagesDB(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("could not create packages db: %w", err)
 	}
+	err = packagesDB.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not close packages db: %w", err)
+	}
+	var layers *oci.LayerCache
+	if env.DeltaUpdates {
+		layers = oci.NewLayerCache(filepath.Join(PackagesPath, layerCacheDir))
+	}
 	return &installerImpl{
-		db:           db,
-		downloader:   oci.NewDownloader(env, http.DefaultClient),
+		dbPath:       dbPath,
+		downloader:   oci.NewResumableDownloader(env, env.HTTPClient(), filepath.Join(PackagesPath, partialsDir)),
 		repositories: repository.NewRepositories(PackagesPath, LocksPack),
+		store:        cas.NewStore(filepath.Join(PackagesPath, storeDir)),
+		layers:       layers,
+		confdOverlay: repository.NewConfdOverlay(ConfdOverlayPath),
+		repairUnits:  env.RepairUnits,
+		repairPerms:  env.RepairPermissions,
 		configsDir:   DefaultConfigsDir,
 		tmpDirPath:   TmpDirPath,
 		packagesDir:  PackagesPath,
+
+		archiveSigningKey:  env.ArchiveSigningKey,
+		archiveTrustedKeys: env.ArchiveTrustedKeys,
 	}, nil
 }
 
+// openPackagesDB opens the packages db. The db is locked while it is open.
+func openPackagesDB(dbPath string) (*db.PackagesDB, error) {
+	return db.New(dbPath, db.WithTimeout(dbTimeout))
+}
+
+// withDB opens the packages db for the duration of fn. The db isn't kept open for the whole
+// operation so that the installers running concurrently for other packages can access it.
+func (i *installerImpl) withDB(fn func(packagesDB *db.PackagesDB) error) error {
+	packagesDB, err := openPackagesDB(i.dbPath)
+	if err != nil {
+		return err
+	}
+	defer packagesDB.Close()
+	return fn(packagesDB)
+}
+
 // State returns the state of a package.
 func (i *installerImpl) State(pkg string) (repository.State, error) {
 	return i.repositories.GetPackageState(pkg)
@@ -115,7 +201,11 @@ func (i *installerImpl) IsInstalled(_ context.Context, pkg string) (bool, error)
 			hasMatch = true
 		}
 	}
-	hasPackage, err := i.db.HasPackage(pkg)
+	var hasPackage bool
+	err := i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+		hasPackage, err = packagesDB.HasPackage(pkg)
+		return err
+	})
 	if err != nil {
 		return false, fmt.Errorf("could not list packages: %w", err)
 	}
@@ -126,7 +216,8 @@ func (i *installerImpl) IsInstalled(_ context.Context, pkg string) (bool, error)
 func (i *installerImpl) Install(ctx context.Context, url string, args []string) error {
 	i.m.Lock()
 	defer i.m.Unlock()
-	pkg, err := i.downloader.Download(ctx, url)
+	start := time.Now()
+	pkg, err := i.download(ctx, url)
 	if err != nil {
 		return fmt.Errorf("could not download package: %w", err)
 	}
@@ -147,7 +238,11 @@ func (i *installerImpl) Install(ctx context.Context, url string, args []string)
 		}
 	}
 
-	dbPkg, err := i.db.GetPackage(pkg.Name)
+	var dbPkg db.Package
+	err = i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+		dbPkg, err = packagesDB.GetPackage(pkg.Name)
+		return err
+	})
 	if err != nil && !errors.Is(err, db.ErrPackageNotFound) {
 		return fmt.Errorf("could not get package: %w", err)
 	}
@@ -165,7 +260,11 @@ func (i *installerImpl) Install(ctx context.Context, url string, args []string)
 	}
 	defer os.RemoveAll(tmpDir)
 	configDir := filepath.Join(i.configsDir, pkg.Name)
-	err = pkg.ExtractLayers(oci.DatadogPackageLayerMediaType, tmpDir)
+	err = i.checkExtraction(pkg, tmpDir, configDir)
+	if err != nil {
+		return fmt.Errorf("package can't be extracted: %w", err)
+	}
+	err = pkg.ExtractLayersToStore(oci.DatadogPackageLayerMediaType, tmpDir, i.packageStore(pkg.Name))
 	if err != nil {
 		return fmt.Errorf("could not extract package layers: %w", err)
 	}
@@ -173,22 +272,32 @@ func (i *installerImpl) Install(ctx context.Context, url string, args []string)
 	if err != nil {
 		return fmt.Errorf("could not extract package config layer: %w", err)
 	}
+	bytesWritten, err := dirSize(tmpDir)
+	if err != nil {
+		return fmt.Errorf("could not compute package size: %w", err)
+	}
 	err = i.repositories.Create(ctx, pkg.Name, pkg.Version, tmpDir)
 	if err != nil {
 		return fmt.Errorf("could not create repository: %w", err)
 	}
+	i.recordUsage(ctx, "install", pkg, bytesWritten, start)
+	i.checkPrerequisites(ctx, pkg.Name, pkg.Version)
 	err = i.setupPackage(ctx, pkg.Name, args)
 	if err != nil {
 		return fmt.Errorf("could not setup package: %w", err)
 	}
-	err = i.db.SetPackage(db.Package{
-		Name:             pkg.Name,
-		Version:          pkg.Version,
-		InstallerVersion: version.AgentVersion,
+	i.checkRebootRequired(pkg.Name)
+	err = i.withDB(func(packagesDB *db.PackagesDB) error {
+		return packagesDB.SetPackage(db.Package{
+			Name:             pkg.Name,
+			Version:          pkg.Version,
+			InstallerVersion: version.AgentVersion,
+		})
 	})
 	if err != nil {
 		return fmt.Errorf("could not store package installation in db: %w", err)
 	}
+	i.retainLayers(pkg)
 	return nil
 }
 
@@ -196,7 +305,8 @@ func (i *installerImpl) Install(ctx context.Context, url string, args []string)
 func (i *installerImpl) InstallExperiment(ctx context.Context, url string) error {
 	i.m.Lock()
 	defer i.m.Unlock()
-	pkg, err := i.downloader.Download(ctx, url)
+	start := time.Now()
+	pkg, err := i.download(ctx, url)
 	if err != nil {
 		return fmt.Errorf("could not download package: %w", err)
 	}
@@ -210,7 +320,11 @@ func (i *installerImpl) InstallExperiment(ctx context.Context, url string) error
 	}
 	defer os.RemoveAll(tmpDir)
 	configDir := filepath.Join(i.configsDir, pkg.Name)
-	err = pkg.ExtractLayers(oci.DatadogPackageLayerMediaType, tmpDir)
+	err = i.checkExtraction(pkg, tmpDir, configDir)
+	if err != nil {
+		return fmt.Errorf("package can't be extracted: %w", err)
+	}
+	err = pkg.ExtractLayersToStore(oci.DatadogPackageLayerMediaType, tmpDir, i.packageStore(pkg.Name))
 	if err != nil {
 		return fmt.Errorf("could not extract package layers: %w", err)
 	}
@@ -218,12 +332,31 @@ func (i *installerImpl) InstallExperiment(ctx context.Context, url string) error
 	if err != nil {
 		return fmt.Errorf("could not extract package config layer: %w", err)
 	}
+	bytesWritten, err := dirSize(tmpDir)
+	if err != nil {
+		return fmt.Errorf("could not compute package size: %w", err)
+	}
 	repository := i.repositories.Get(pkg.Name)
 	err = repository.SetExperiment(ctx, pkg.Version, tmpDir)
 	if err != nil {
 		return fmt.Errorf("could not set experiment: %w", err)
 	}
-	return i.startExperiment(ctx, pkg.Name)
+	i.recordUsage(ctx, "install_experiment", pkg, bytesWritten, start)
+	i.retainLayers(pkg)
+	err = i.startExperiment(ctx, pkg.Name)
+	if err != nil {
+		return err
+	}
+	err = runSmokeTests(ctx, repository.ExperimentPath(), pkg.SmokeTests)
+	if err != nil {
+		// Stopping the experiment hands over to the stable version, the experiment is kept
+		// on disk so that it is reported as unhealthy until it is removed.
+		if stopErr := i.stopExperiment(ctx, pkg.Name); stopErr != nil {
+			log.Errorf("could not stop unhealthy experiment: %v", stopErr)
+		}
+		return installerErrors.Wrap(installerErrors.ErrExperimentUnhealthy, fmt.Errorf("experiment is unhealthy: %w", err))
+	}
+	return nil
 }
 
 // RemoveExperiment removes an experiment.
@@ -254,7 +387,34 @@ func (i *installerImpl) PromoteExperiment(ctx context.Context, pkg string) error
 	if err != nil {
 		return fmt.Errorf("could not promote experiment: %w", err)
 	}
-	return i.promoteExperiment(ctx, pkg)
+	err = i.promoteExperiment(ctx, pkg)
+	if err != nil {
+		return err
+	}
+	i.checkRebootRequired(pkg)
+	return nil
+}
+
+// Rollback sets the package that was stable before the last promotion as stable again.
+func (i *installerImpl) Rollback(ctx context.Context, pkg string) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if pkg == packageDatadogInstaller {
+		// The installer would be restarted while it's handling the rollback
+		return fmt.Errorf("could not rollback: rolling back the installer is not supported")
+	}
+	repository := i.repositories.Get(pkg)
+	err := repository.Rollback(ctx)
+	if err != nil {
+		return fmt.Errorf("could not rollback: %w", err)
+	}
+	err = i.rollback(ctx, pkg)
+	if err != nil {
+		return err
+	}
+	i.checkRebootRequired(pkg)
+	return nil
 }
 
 // Purge removes all packages.
@@ -262,7 +422,11 @@ func (i *installerImpl) Purge(ctx context.Context) {
 	i.m.Lock()
 	defer i.m.Unlock()
 
-	packages, err := i.db.ListPackages()
+	var packages []db.Package
+	err := i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+		packages, err = packagesDB.ListPackages()
+		return err
+	})
 	if err != nil {
 		// if we can't list packages we'll only remove the installer
 		packages = nil
@@ -289,6 +453,9 @@ func (i *installerImpl) Purge(ctx context.Context) {
 	if err != nil {
 		log.Warnf("could not remove path: %v", err)
 	}
+	if err := os.RemoveAll(ConfdOverlayPath); err != nil {
+		log.Warnf("could not remove conf.d overlay: %v", err)
+	}
 }
 
 // Remove uninstalls a package.
@@ -303,19 +470,115 @@ func (i *installerImpl) Remove(ctx context.Context, pkg string) error {
 	if err != nil {
 		return fmt.Errorf("could not delete repository: %w", err)
 	}
-	err = i.db.DeletePackage(pkg)
+	err = i.withDB(func(packagesDB *db.PackagesDB) error {
+		return packagesDB.DeletePackage(pkg)
+	})
 	if err != nil {
 		return fmt.Errorf("could not remove package installation in db: %w", err)
 	}
 	return nil
 }
 
-// GarbageCollect removes unused packages.
+// GarbageCollect removes unused packages, the shared objects they were the last to use and the stale
+// partial downloads, and verifies the integrity of the units and symlinks and the permissions of the installed packages.
 func (i *installerImpl) GarbageCollect(ctx context.Context) error {
 	i.m.Lock()
 	defer i.m.Unlock()
 
-	return i.repositories.Cleanup(ctx)
+	err := i.repositories.Cleanup(ctx)
+	if err != nil {
+		return err
+	}
+	err = i.store.GarbageCollect(ctx)
+	if err != nil {
+		return err
+	}
+	err = i.pruneLayers()
+	if err != nil {
+		return err
+	}
+	err = i.downloader.PrunePartials(partialsMaxAge)
+	if err != nil {
+		return fmt.Errorf("could not prune partial downloads: %w", err)
+	}
+	i.verifyUnits(ctx)
+	i.verifyPermissions(ctx)
+	return nil
+}
+
+// CheckPermissions checks the owners and modes of the files of the packages directory.
+func (i *installerImpl) CheckPermissions(ctx context.Context) ([]repository.PermissionIssue, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	return i.repositories.CheckPermissions(ctx)
+}
+
+// RepairPermissions restores the owners and modes of the files of the packages directory.
+func (i *installerImpl) RepairPermissions(ctx context.Context) ([]repository.PermissionIssue, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	return i.repositories.RepairPermissions(ctx)
+}
+
+// verifyPermissions checks the owners and modes of the files of the packages directory, and
+// repairs them if enabled. Like the drifts of the units, the issues don't fail the garbage collection.
+func (i *installerImpl) verifyPermissions(ctx context.Context) {
+	var issues []repository.PermissionIssue
+	var err error
+	if i.repairPerms {
+		issues, err = i.repositories.RepairPermissions(ctx)
+	} else {
+		issues, err = i.repositories.CheckPermissions(ctx)
+	}
+	if err != nil {
+		log.Warnf("could not verify permissions of the packages: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Repaired {
+			log.Infof("repaired permissions of %s: %s", issue.Path, issue.Reason)
+		} else {
+			log.Warnf("unexpected permissions of %s: %s", issue.Path, issue.Reason)
+		}
+	}
+}
+
+// verifyUnits checks that the units and symlinks of the installed packages match what their
+// setup wrote, and repairs them if enabled. Drifts are logged and reported on the spans of
+// the verification without failing the garbage collection.
+func (i *installerImpl) verifyUnits(ctx context.Context) {
+	for _, pkg := range []string{packageDatadogAgent, packageDatadogInstaller} {
+		var installed bool
+		err := i.withDB(func(packagesDB *db.PackagesDB) (err error) {
+			installed, err = packagesDB.HasPackage(pkg)
+			return err
+		})
+		if err != nil {
+			log.Warnf("could not check if package %s is installed: %v", pkg, err)
+			continue
+		}
+		if !installed {
+			continue
+		}
+		var drifts []service.Drift
+		switch pkg {
+		case packageDatadogAgent:
+			drifts, err = service.VerifyAgentUnits(ctx, i.repairUnits)
+		case packageDatadogInstaller:
+			drifts, err = service.VerifyInstallerUnits(ctx, i.repairUnits)
+		}
+		if err != nil {
+			log.Warnf("could not verify units of package %s: %v", pkg, err)
+		}
+		for _, drift := range drifts {
+			if drift.Repaired {
+				log.Infof("repaired %s file %s of package %s", drift.Reason, drift.Path, pkg)
+			} else {
+				log.Warnf("%s file %s of package %s doesn't match its setup", drift.Reason, drift.Path, pkg)
+			}
+		}
+	}
 }
 
 // InstrumentAPMInjector instruments the APM injector.
@@ -358,6 +621,83 @@ func (i *installerImpl) UninstrumentAPMInjector(ctx context.Context, method stri
 	return nil
 }
 
+// DiffAPMInjectorDocker returns the modification (un)instrumenting docker would make to the docker
+// daemon configuration, without applying it.
+func (i *installerImpl) DiffAPMInjectorDocker(ctx context.Context, uninstrument bool) (service.FileDiff, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	diff, err := service.DiffAPMInjectorDocker(ctx, uninstrument)
+	if err != nil {
+		return service.FileDiff{}, fmt.Errorf("could not diff the docker daemon configuration: %w", err)
+	}
+	return diff, nil
+}
+
+// RotateAPIKey replaces the API key in the agent configuration and restarts the agent.
+func (i *installerImpl) RotateAPIKey(ctx context.Context, apiKey string) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	err := service.RotateAPIKey(ctx, apiKey)
+	if err != nil {
+		return fmt.Errorf("could not rotate API key: %w", err)
+	}
+	return nil
+}
+
+// SetIntegrationConfigs writes the integration configurations delivered remotely as a new version of
+// the conf.d overlay and restarts the agent to load them. The previous version is restored if the agent
+// can't be restarted.
+func (i *installerImpl) SetIntegrationConfigs(ctx context.Context, version string, files map[string][]byte) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	err := i.confdOverlay.Set(version, files)
+	if err != nil {
+		return fmt.Errorf("could not set integration configurations: %w", err)
+	}
+	err = restartAgent(ctx)
+	if err != nil {
+		log.Errorf("Failed to restart the agent with the integration configurations %s, restoring the previous ones: %s", version, err)
+		err = fmt.Errorf("could not restart the agent: %w", err)
+		if rollbackErr := i.confdOverlay.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("could not restore the previous integration configurations: %w", rollbackErr))
+		}
+		return errors.Join(err, restartAgent(ctx))
+	}
+	return nil
+}
+
+// RollbackIntegrationConfigs restores the previous version of the integration configurations delivered
+// remotely and restarts the agent to load them.
+func (i *installerImpl) RollbackIntegrationConfigs(ctx context.Context) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	err := i.confdOverlay.Rollback()
+	if err != nil {
+		return fmt.Errorf("could not rollback integration configurations: %w", err)
+	}
+	err = restartAgent(ctx)
+	if err != nil {
+		return fmt.Errorf("could not restart the agent: %w", err)
+	}
+	return nil
+}
+
+// Reboot reboots the host so that the packages requiring it are fully applied.
+func (i *installerImpl) Reboot(ctx context.Context) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	err := service.Reboot(ctx)
+	if err != nil {
+		return fmt.Errorf("could not reboot: %w", err)
+	}
+	return nil
+}
+
 func (i *installerImpl) startExperiment(ctx context.Context, pkg string) error {
 	switch pkg {
 	case packageDatadogAgent:
@@ -391,6 +731,15 @@ func (i *installerImpl) promoteExperiment(ctx context.Context, pkg string) error
 	}
 }
 
+func (i *installerImpl) rollback(ctx context.Context, pkg string) error {
+	switch pkg {
+	case packageDatadogAgent:
+		return service.RollbackAgent(ctx)
+	default:
+		return nil
+	}
+}
+
 func (i *installerImpl) setupPackage(ctx context.Context, pkg string, args []string) error {
 	switch pkg {
 	case packageDatadogInstaller:
@@ -420,8 +769,108 @@ func (i *installerImpl) removePackage(ctx context.Context, pkg string) error {
 const (
 	packageUnknownSize = 2 << 30  // 2GiB
 	installerOverhead  = 10 << 20 // 10MiB
+	// installerInodesOverhead accounts for the inodes used by the installer itself, e.g. its database and locks
+	installerInodesOverhead = 1000
 )
 
+// recordUsage accumulates the bandwidth and disk usage of an operation in the state of the package
+// and reports it on the span of the operation. Failing to record usage doesn't fail the operation.
+func (i *installerImpl) recordUsage(ctx context.Context, operation string, pkg *oci.DownloadedPackage, bytesWritten int64, start time.Time) {
+	usage := repository.OperationUsage{
+		Operation:       operation,
+		Version:         pkg.Version,
+		BytesDownloaded: pkg.BytesDownloaded(),
+		BytesWritten:    bytesWritten,
+		Duration:        time.Since(start),
+		Time:            start,
+	}
+	span, ok := tracer.SpanFromContext(ctx)
+	if ok {
+		span.SetTag("usage.bytes_downloaded", usage.BytesDownloaded)
+		span.SetTag("usage.bytes_written", usage.BytesWritten)
+		span.SetTag("usage.duration_ms", usage.Duration.Milliseconds())
+	}
+	err := i.repositories.RecordUsage(pkg.Name, usage)
+	if err != nil {
+		log.Warnf("could not record usage of package %s: %v", pkg.Name, err)
+	}
+}
+
+// checkPrerequisites records the host prerequisites of the package that aren't satisfied in its
+// state, so that fleet automation can act on them. The package is still set up as its features
+// not relying on them work, failing to check doesn't fail the operation either.
+func (i *installerImpl) checkPrerequisites(ctx context.Context, pkg string, version string) {
+	err := checkPrerequisites(ctx, pkg)
+	var prerequisitesErr *service.PrerequisitesError
+	if err != nil && !errors.As(err, &prerequisitesErr) {
+		log.Warnf("could not check prerequisites of package %s: %v", pkg, err)
+		return
+	}
+	if prerequisitesErr == nil {
+		err = i.repositories.DeletePrerequisitesNotMet(pkg)
+		if err != nil {
+			log.Warnf("could not clear unsatisfied prerequisites of package %s: %v", pkg, err)
+		}
+		return
+	}
+	log.Warnf("package %s version %s is set up with unsatisfied prerequisites: %v", pkg, version, err)
+	err = i.repositories.SetPrerequisitesNotMet(pkg, version, prerequisitesErr.Unsatisfied)
+	if err != nil {
+		log.Warnf("could not record unsatisfied prerequisites of package %s: %v", pkg, err)
+	}
+}
+
+// checkRebootRequired flags the package as requiring a reboot if its stable version ships
+// the reboot-required marker. Only stable versions are checked: rebooting during an experiment
+// would hand over to the stable version. Failing to check doesn't fail the operation.
+func (i *installerImpl) checkRebootRequired(pkg string) {
+	repository := i.repositories.Get(pkg)
+	reason, err := fs.ReadFile(repository.StableFS(), rebootRequiredMarker)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Warnf("could not check if package %s requires a reboot: %v", pkg, err)
+		return
+	}
+	state, err := repository.GetState()
+	if err != nil {
+		log.Warnf("could not get state of package %s: %v", pkg, err)
+		return
+	}
+	log.Infof("package %s version %s requires a reboot of the host to be fully applied", pkg, state.Stable)
+	err = i.repositories.SetRebootRequired(pkg, state.Stable, strings.TrimSpace(string(reason)))
+	if err != nil {
+		log.Warnf("could not flag package %s as requiring a reboot: %v", pkg, err)
+	}
+}
+
+// dirSize returns the total size of the regular files under the given directory.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// CheckAvailableDiskSpace checks the disk space available for the packages is enough to install a
+// package of the given size, 0 if unknown, as checked by the installer once the package is downloaded.
+func CheckAvailableDiskSpace(size uint64) error {
+	return checkAvailableDiskSpace(&oci.DownloadedPackage{Size: size}, PackagesPath)
+}
+
 // checkAvailableDiskSpace checks if there is enough disk space to install a package at the given path.
 // This will check the underlying partition of the given path. Note that the path must be an existing dir.
 //
@@ -430,11 +879,7 @@ const (
 // On Windows, it is computed using `GetDiskFreeSpaceExW` and is the number of bytes available
 // See https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getdiskfreespaceexw for more details
 func checkAvailableDiskSpace(pkg *oci.DownloadedPackage, path string) error {
-	requiredDiskSpace := pkg.Size
-	if requiredDiskSpace == 0 {
-		requiredDiskSpace = packageUnknownSize
-	}
-	requiredDiskSpace += installerOverhead
+	requiredDiskSpace := requiredDiskSpace(pkg)
 
 	_, err := os.Stat(path)
 	if err != nil {
@@ -444,12 +889,82 @@ func checkAvailableDiskSpace(pkg *oci.DownloadedPackage, path string) error {
 	if err != nil {
 		return err
 	}
-	if s.Available < uint64(requiredDiskSpace) {
+	if s.Available < requiredDiskSpace {
 		return fmt.Errorf("not enough disk space at %s: %d bytes available, %d bytes required", path, s.Available, requiredDiskSpace)
 	}
 	return nil
 }
 
+// requiredDiskSpace returns the disk space needed to install a package, the size of packages
+// not annotated with one is assumed to be the largest expected
+func requiredDiskSpace(pkg *oci.DownloadedPackage) uint64 {
+	if pkg.Size == 0 {
+		return packageUnknownSize + installerOverhead
+	}
+	return pkg.Size + installerOverhead
+}
+
+// NotEnoughInodesError is returned when the filesystem doesn't have enough free inodes to extract a package.
+type NotEnoughInodesError struct {
+	Path      string
+	Available uint64
+	Required  uint64
+}
+
+// Error returns the error message.
+func (e *NotEnoughInodesError) Error() string {
+	return fmt.Sprintf("not enough free inodes at %s: %d available, %d required", e.Path, e.Available, e.Required)
+}
+
+// packageStore returns the store the files of a package are linked to. The agent package is chowned
+// to dd-agent once installed, its files never share objects with the files of the other packages.
+func (i *installerImpl) packageStore(pkg string) *cas.Store {
+	if pkg == packageDatadogAgent {
+		return i.store.Partition("dd-agent")
+	}
+	return i.store
+}
+
+// checkExtraction checks that the layers of a package can be extracted before extracting them, as
+// running out of inodes or exceeding the path length limits midway through the extraction
+// only surfaces as an opaque tar error.
+//
+// The package layers are checked against the final path of the package rather than the temporary
+// directory they are extracted to, and the config layers against the config directory.
+// It returns a *NotEnoughInodesError or a *tar.PathTooLongError if the package can't be extracted.
+func (i *installerImpl) checkExtraction(pkg *oci.DownloadedPackage, tmpDir string, configDir string) error {
+	packageEntries, err := pkg.CheckLayers(oci.DatadogPackageLayerMediaType, filepath.Join(i.packagesDir, pkg.Name, pkg.Version))
+	if err != nil {
+		return fmt.Errorf("could not check package layers: %w", err)
+	}
+	err = checkAvailableInodes(tmpDir, packageEntries)
+	if err != nil {
+		return err
+	}
+	_, err = pkg.CheckLayers(oci.DatadogPackageConfigLayerMediaType, configDir)
+	if err != nil {
+		return fmt.Errorf("could not check package config layers: %w", err)
+	}
+	return nil
+}
+
+// checkAvailableInodes checks if there are enough free inodes to create the given number of entries at the given path.
+// The check is skipped on filesystems allocating inodes dynamically, which report no inodes.
+func checkAvailableInodes(path string, entries uint64) error {
+	s, err := fsDisk.GetUsage(path)
+	if err != nil {
+		return err
+	}
+	if s.InodesTotal == 0 {
+		return nil
+	}
+	requiredInodes := entries + installerInodesOverhead
+	if s.InodesAvailable < requiredInodes {
+		return &NotEnoughInodesError{Path: path, Available: s.InodesAvailable, Required: requiredInodes}
+	}
+	return nil
+}
+
 func ensurePackageDirExists() error {
 	err := os.MkdirAll(PackagesPath, 0755)
 	if err != nil {
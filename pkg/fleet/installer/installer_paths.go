@@ -16,4 +16,6 @@ const (
 	LocksPack = "/var/run/datadog-installer/locks"
 	// DefaultConfigsDir is the default Agent configuration directory
 	DefaultConfigsDir = "/etc"
+	// ConfdOverlayPath is the path to the conf.d overlay containing the integration configurations delivered remotely.
+	ConfdOverlayPath = "/etc/datadog-agent/managed"
 )
@@ -25,6 +25,9 @@ var (
 
 	// DefaultConfigsDir is the default Agent configuration directory
 	DefaultConfigsDir string
+
+	// ConfdOverlayPath is the path to the conf.d overlay containing the integration configurations delivered remotely.
+	ConfdOverlayPath string
 )
 
 func init() {
@@ -32,4 +35,5 @@ func init() {
 	TmpDirPath = PackagesPath
 	LocksPack = filepath.Join(PackagesPath, "locks")
 	DefaultConfigsDir, _ = windows.KnownFolderPath(windows.FOLDERID_ProgramData, 0)
+	ConfdOverlayPath = filepath.Join(DefaultConfigsDir, "Datadog", "managed")
 }
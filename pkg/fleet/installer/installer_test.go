@@ -10,16 +10,22 @@ package installer
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/db"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
@@ -33,13 +39,14 @@ type testPackageManager struct {
 
 func newTestPackageManager(t *testing.T, s *fixtures.Server, rootPath string, locksPath string) *testPackageManager {
 	repositories := repository.NewRepositories(rootPath, locksPath)
-	db, err := db.New(filepath.Join(rootPath, "packages.db"))
-	assert.NoError(t, err)
+	dbPath := filepath.Join(rootPath, "packages.db")
 	return &testPackageManager{
 		installerImpl{
-			db:           db,
+			dbPath:       dbPath,
 			downloader:   oci.NewDownloader(&env.Env{}, s.Client()),
 			repositories: repositories,
+			store:        cas.NewStore(filepath.Join(rootPath, storeDir)),
+			confdOverlay: repository.NewConfdOverlay(filepath.Join(rootPath, "managed")),
 			configsDir:   t.TempDir(),
 			tmpDirPath:   rootPath,
 			packagesDir:  rootPath,
@@ -70,6 +77,26 @@ func TestInstallStable(t *testing.T) {
 	fixtures.AssertEqualFS(t, s.ConfigFS(fixtures.FixtureSimpleV1), installer.ConfigFS(fixtures.FixtureSimpleV1))
 }
 
+func TestInstallReleasesDB(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+
+	err := installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	assert.NoError(t, err)
+
+	// another installer can open the db while this one is still running
+	packagesDB, err := db.New(installer.dbPath, db.WithTimeout(time.Second))
+	assert.NoError(t, err)
+	defer packagesDB.Close()
+	installed, err := packagesDB.HasPackage(fixtures.FixtureSimpleV1.Package)
+	assert.NoError(t, err)
+	assert.True(t, installed)
+}
+
 func TestInstallExperiment(t *testing.T) {
 	if runtime.GOOS == "darwin" {
 		t.Skip("FIXME: Failing test on macOS - #incident-26965")
@@ -92,6 +119,81 @@ func TestInstallExperiment(t *testing.T) {
 	fixtures.AssertEqualFS(t, s.ConfigFS(fixtures.FixtureSimpleV2), installer.ConfigFS(fixtures.FixtureSimpleV2))
 }
 
+func TestInstallRecordsUsage(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+
+	err := installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	assert.NoError(t, err)
+	err = installer.InstallExperiment(testCtx, s.PackageURL(fixtures.FixtureSimpleV2))
+	assert.NoError(t, err)
+
+	state, err := installer.State(fixtures.FixtureSimpleV1.Package)
+	assert.NoError(t, err)
+	require.NotNil(t, state.Usage)
+	assert.Equal(t, int64(2), state.Usage.Operations)
+	require.Len(t, state.Usage.History, 2)
+	install, experiment := state.Usage.History[0], state.Usage.History[1]
+	assert.Equal(t, "install", install.Operation)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, install.Version)
+	assert.NotZero(t, install.BytesDownloaded)
+	assert.NotZero(t, install.BytesWritten)
+	assert.Equal(t, "install_experiment", experiment.Operation)
+	assert.Equal(t, fixtures.FixtureSimpleV2.Version, experiment.Version)
+	assert.Equal(t, install.BytesDownloaded+experiment.BytesDownloaded, state.Usage.BytesDownloaded)
+	assert.Equal(t, install.BytesWritten+experiment.BytesWritten, state.Usage.BytesWritten)
+
+	err = installer.Remove(testCtx, fixtures.FixtureSimpleV1.Package)
+	assert.NoError(t, err)
+	state, err = installer.State(fixtures.FixtureSimpleV1.Package)
+	assert.NoError(t, err)
+	assert.Nil(t, state.Usage)
+}
+
+func TestInstallPrerequisitesNotMet(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: Failing test on macOS - #incident-26965")
+	}
+
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	var prerequisitesErr error = &service.PrerequisitesError{
+		Package:     fixtures.FixtureSimpleV1.Package,
+		Unsatisfied: []string{"kernel BTF or headers"},
+	}
+	previousCheckPrerequisites := checkPrerequisites
+	checkPrerequisites = func(context.Context, string) error {
+		if prerequisitesErr == nil {
+			return nil
+		}
+		return installerErrors.Wrap(installerErrors.ErrPrerequisitesNotMet, prerequisitesErr)
+	}
+	t.Cleanup(func() { checkPrerequisites = previousCheckPrerequisites })
+
+	// the package is set up despite its unsatisfied prerequisites, which are reported in its state
+	err := installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV1), nil)
+	require.NoError(t, err)
+	state, err := installer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, state.Stable)
+	require.NotNil(t, state.PrerequisitesNotMet)
+	assert.Equal(t, fixtures.FixtureSimpleV1.Version, state.PrerequisitesNotMet.Version)
+	assert.Equal(t, installerErrors.ErrPrerequisitesNotMet, state.PrerequisitesNotMet.Code)
+	assert.Equal(t, []string{"kernel BTF or headers"}, state.PrerequisitesNotMet.Unsatisfied)
+
+	// they are cleared once satisfied
+	prerequisitesErr = nil
+	err = installer.Install(testCtx, s.PackageURL(fixtures.FixtureSimpleV2), nil)
+	require.NoError(t, err)
+	state, err = installer.State(fixtures.FixtureSimpleV1.Package)
+	require.NoError(t, err)
+	assert.Nil(t, state.PrerequisitesNotMet)
+}
+
 func TestInstallPromoteExperiment(t *testing.T) {
 	if runtime.GOOS == "darwin" {
 		t.Skip("FIXME: Failing test on macOS - #incident-26965")
@@ -138,3 +240,54 @@ func TestUninstallExperiment(t *testing.T) {
 	// we do not rollback configuration examples to their previous versions currently
 	fixtures.AssertEqualFS(t, s.ConfigFS(fixtures.FixtureSimpleV2), installer.ConfigFS(fixtures.FixtureSimpleV2))
 }
+
+func TestCheckAvailableInodes(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, checkAvailableInodes(dir, 1))
+
+	usage, err := fsDisk.GetUsage(dir)
+	assert.NoError(t, err)
+	if usage.InodesTotal == 0 {
+		t.Skip("filesystem allocates inodes dynamically")
+	}
+	err = checkAvailableInodes(dir, usage.InodesTotal)
+	var inodesErr *NotEnoughInodesError
+	assert.ErrorAs(t, err, &inodesErr)
+	assert.Equal(t, dir, inodesErr.Path)
+}
+
+func TestSetIntegrationConfigs(t *testing.T) {
+	s := fixtures.NewServer(t)
+	installer := newTestPackageManager(t, s, t.TempDir(), t.TempDir())
+	restarts := 0
+	var restartErr error
+	previousRestartAgent := restartAgent
+	restartAgent = func(context.Context) error {
+		restarts++
+		return restartErr
+	}
+	t.Cleanup(func() { restartAgent = previousRestartAgent })
+
+	err := installer.SetIntegrationConfigs(testCtx, "v1", map[string][]byte{"nginx.d/conf.yaml": []byte("v1")})
+	require.NoError(t, err)
+	err = installer.SetIntegrationConfigs(testCtx, "v2", map[string][]byte{"nginx.d/conf.yaml": []byte("v2")})
+	require.NoError(t, err)
+	assert.Equal(t, 2, restarts)
+	content, err := os.ReadFile(filepath.Join(installer.confdOverlay.Path(), "nginx.d", "conf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+
+	require.NoError(t, installer.RollbackIntegrationConfigs(testCtx))
+	assert.Equal(t, 3, restarts)
+	content, err = os.ReadFile(filepath.Join(installer.confdOverlay.Path(), "nginx.d", "conf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	// the previous configurations are restored if the agent can't be restarted
+	restartErr = errors.New("restart failed")
+	err = installer.SetIntegrationConfigs(testCtx, "v3", map[string][]byte{"nginx.d/conf.yaml": []byte("v3")})
+	assert.ErrorContains(t, err, "restart failed")
+	state, err := installer.confdOverlay.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, "v1", state.Current)
+}
@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	confdOverlayLink      = "conf.d"
+	confdOverlayTmpPrefix = ".tmp-"
+)
+
+// ConfdOverlay contains the integration configurations delivered remotely to the agent.
+//
+// The agent reads the conf.d link ahead of the user conf.d directory. On disk the overlay
+// is structured as follows:
+// .
+// ├── v1
+// │   └── nginx.d
+// │       └── conf.yaml
+// ├── v2
+// │   └── nginx.d
+// │       └── conf.yaml
+// ├── conf.d -> v2 (symlink)
+// └── previous -> v1 (symlink)
+//
+// A new version is written to a temporary directory and only exposed to the agent
+// once complete by swapping the conf.d link, so the agent never reads a partial set of
+// configurations. The previous version is kept to allow a rollback.
+type ConfdOverlay struct {
+	rootPath string
+}
+
+// ConfdOverlayState is the state of the conf.d overlay.
+type ConfdOverlayState struct {
+	Current  string
+	Previous string
+}
+
+// NewConfdOverlay returns a new conf.d overlay rooted at the given path.
+func NewConfdOverlay(rootPath string) *ConfdOverlay {
+	return &ConfdOverlay{
+		rootPath: rootPath,
+	}
+}
+
+// Path returns the path of the directory read by the agent.
+func (o *ConfdOverlay) Path() string {
+	return filepath.Join(o.rootPath, confdOverlayLink)
+}
+
+// GetState returns the state of the overlay.
+func (o *ConfdOverlay) GetState() (ConfdOverlayState, error) {
+	overlay, err := readConfdOverlay(o.rootPath)
+	if err != nil {
+		return ConfdOverlayState{}, err
+	}
+	return ConfdOverlayState{
+		Current:  overlay.current.Target(),
+		Previous: overlay.previous.Target(),
+	}, nil
+}
+
+// Set writes the given configuration files as a new version of the overlay and makes it current.
+// The files are keyed by their path relative to the conf.d directory, e.g. nginx.d/conf.yaml.
+//
+// 1. Write the files to a temporary directory.
+// 2. Move the temporary directory to the version directory.
+// 3. Set the previous link to the current version.
+// 4. Set the current link to the new version.
+// 5. Cleanup the versions that are not linked anymore.
+func (o *ConfdOverlay) Set(version string, files map[string][]byte) error {
+	if version == "" || version == confdOverlayLink || version == previousVersionLink || strings.HasPrefix(version, ".") || !filepath.IsLocal(version) || filepath.Base(version) != version {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	err := os.MkdirAll(o.rootPath, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create overlay root directory: %w", err)
+	}
+	overlay, err := readConfdOverlay(o.rootPath)
+	if err != nil {
+		return err
+	}
+	if overlay.current.Target() == version {
+		return fmt.Errorf("version %s is already current", version)
+	}
+	versionPath := filepath.Join(o.rootPath, version)
+	if overlay.previous.Target() == version {
+		// The version directory is written again from scratch
+		err = overlay.previous.Delete()
+		if err != nil {
+			return fmt.Errorf("could not delete previous link: %w", err)
+		}
+	}
+	err = overlay.cleanup()
+	if err != nil {
+		return fmt.Errorf("could not cleanup overlay: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(o.rootPath, confdOverlayTmpPrefix)
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for name, content := range files {
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid configuration file path %q", name)
+		}
+		path := filepath.Join(tmpDir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return fmt.Errorf("could not create configuration directory: %w", err)
+		}
+		err = os.WriteFile(path, content, 0644)
+		if err != nil {
+			return fmt.Errorf("could not write configuration file: %w", err)
+		}
+	}
+	err = os.Chmod(tmpDir, 0755)
+	if err != nil {
+		return fmt.Errorf("could not set permissions on version directory: %w", err)
+	}
+	err = os.Rename(tmpDir, versionPath)
+	if err != nil {
+		return fmt.Errorf("could not move version directory: %w", err)
+	}
+
+	if overlay.current.Exists() {
+		err = overlay.previous.Set(*overlay.current.packagePath)
+		if err != nil {
+			return fmt.Errorf("could not set previous version: %w", err)
+		}
+	}
+	err = overlay.current.Set(versionPath)
+	if err != nil {
+		return fmt.Errorf("could not set current version: %w", err)
+	}
+	return overlay.cleanup()
+}
+
+// Rollback makes the previous version current again.
+//
+// 1. Set the current link to the previous version.
+// 2. Delete the previous link.
+// 3. Cleanup the version that was current.
+func (o *ConfdOverlay) Rollback() error {
+	overlay, err := readConfdOverlay(o.rootPath)
+	if err != nil {
+		return err
+	}
+	if !overlay.previous.Exists() {
+		return fmt.Errorf("no previous version to rollback to")
+	}
+	err = overlay.current.Set(*overlay.previous.packagePath)
+	if err != nil {
+		return fmt.Errorf("could not set current version: %w", err)
+	}
+	err = overlay.previous.Delete()
+	if err != nil {
+		return fmt.Errorf("could not delete previous link: %w", err)
+	}
+	return overlay.cleanup()
+}
+
+type confdOverlayFiles struct {
+	rootPath string
+
+	current  *link
+	previous *link
+}
+
+func readConfdOverlay(rootPath string) (*confdOverlayFiles, error) {
+	currentLink, err := newLink(filepath.Join(rootPath, confdOverlayLink))
+	if err != nil {
+		return nil, fmt.Errorf("could not load current link: %w", err)
+	}
+	previousLink, err := newLink(filepath.Join(rootPath, previousVersionLink))
+	if err != nil {
+		return nil, fmt.Errorf("could not load previous link: %w", err)
+	}
+	return &confdOverlayFiles{
+		rootPath: rootPath,
+		current:  currentLink,
+		previous: previousLink,
+	}, nil
+}
+
+// cleanup removes the versions that are neither current nor previous, as well as
+// temporary directories left over by an interrupted Set.
+func (o *confdOverlayFiles) cleanup() error {
+	files, err := os.ReadDir(o.rootPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read root directory: %w", err)
+	}
+	for _, file := range files {
+		isLink := file.Name() == confdOverlayLink || file.Name() == previousVersionLink
+		isCurrent := o.current.Target() == file.Name()
+		isPrevious := o.previous.Target() == file.Name()
+		if isLink || isCurrent || isPrevious {
+			continue
+		}
+		versionPath := filepath.Join(o.rootPath, file.Name())
+		log.Debugf("configuration version %s isn't used anymore, removing it", versionPath)
+		if err := os.RemoveAll(versionPath); err != nil {
+			log.Errorf("could not remove configuration version %s, will retry: %v", versionPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package repository
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfdOverlaySet(t *testing.T) {
+	overlay := NewConfdOverlay(path.Join(t.TempDir(), "managed"))
+
+	state, err := overlay.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, ConfdOverlayState{}, state)
+
+	err = overlay.Set("v1", map[string][]byte{"nginx.d/conf.yaml": []byte("instances: [{}]")})
+	require.NoError(t, err)
+	content, err := os.ReadFile(path.Join(overlay.Path(), "nginx.d", "conf.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "instances: [{}]", string(content))
+
+	err = overlay.Set("v2", map[string][]byte{"redisdb.d/conf.yaml": []byte("instances: [{}]")})
+	require.NoError(t, err)
+	assert.FileExists(t, path.Join(overlay.Path(), "redisdb.d", "conf.yaml"))
+	assert.NoFileExists(t, path.Join(overlay.Path(), "nginx.d", "conf.yaml"))
+	state, err = overlay.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, ConfdOverlayState{Current: "v2", Previous: "v1"}, state)
+
+	err = overlay.Set("v3", nil)
+	require.NoError(t, err)
+	assert.NoDirExists(t, path.Join(overlay.rootPath, "v1"))
+	assert.DirExists(t, path.Join(overlay.rootPath, "v2"))
+
+	err = overlay.Set("v3", nil)
+	assert.Error(t, err)
+}
+
+func TestConfdOverlayRollback(t *testing.T) {
+	overlay := NewConfdOverlay(path.Join(t.TempDir(), "managed"))
+
+	assert.Error(t, overlay.Rollback())
+
+	require.NoError(t, overlay.Set("v1", map[string][]byte{"nginx.d/conf.yaml": []byte("v1")}))
+	require.NoError(t, overlay.Set("v2", map[string][]byte{"nginx.d/conf.yaml": []byte("v2")}))
+	require.NoError(t, overlay.Rollback())
+
+	content, err := os.ReadFile(path.Join(overlay.Path(), "nginx.d", "conf.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+	state, err := overlay.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, ConfdOverlayState{Current: "v1"}, state)
+	assert.NoDirExists(t, path.Join(overlay.rootPath, "v2"))
+
+	// Only one rollback is possible
+	assert.Error(t, overlay.Rollback())
+}
+
+func TestConfdOverlaySetPrevious(t *testing.T) {
+	overlay := NewConfdOverlay(path.Join(t.TempDir(), "managed"))
+
+	require.NoError(t, overlay.Set("v1", map[string][]byte{"nginx.d/conf.yaml": []byte("v1")}))
+	require.NoError(t, overlay.Set("v2", map[string][]byte{"nginx.d/conf.yaml": []byte("v2")}))
+	require.NoError(t, overlay.Set("v1", map[string][]byte{"nginx.d/conf.yaml": []byte("v1")}))
+
+	state, err := overlay.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, ConfdOverlayState{Current: "v1", Previous: "v2"}, state)
+}
+
+func TestConfdOverlayInvalid(t *testing.T) {
+	overlay := NewConfdOverlay(path.Join(t.TempDir(), "managed"))
+
+	for _, version := range []string{"", "conf.d", "previous", ".tmp-1", "../v1", "a/b"} {
+		assert.Error(t, overlay.Set(version, nil), version)
+	}
+	assert.Error(t, overlay.Set("v1", map[string][]byte{"../datadog.yaml": nil}))
+	assert.Error(t, overlay.Set("v1", map[string][]byte{"/etc/datadog-agent/datadog.yaml": nil}))
+
+	// Failed sets don't leave anything behind
+	entries, err := os.ReadDir(overlay.rootPath)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,25 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package repository
+
+import (
+	"io/fs"
+)
+
+// fileOwner returns false on Windows as the files are owned through their ACLs
+func fileOwner(_ fs.FileInfo) (uid int, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileID identifies the file a hard link points to
+type fileID struct{}
+
+// linkedFileID returns false on Windows as the owners of the files aren't checked
+func linkedFileID(_ fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
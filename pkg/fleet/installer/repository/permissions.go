@@ -0,0 +1,303 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// dirMode is the mode of the packages directory, of the repositories and of their versions
+	dirMode fs.FileMode = 0755
+	// forbiddenMode are the permission bits no file of a package may have
+	forbiddenMode fs.FileMode = 0002
+)
+
+// PermissionIssue is a file of the packages directory whose owner or mode doesn't match the ones
+// set by the installer, usually after a restore from a backup or a recursive chown of the host.
+type PermissionIssue struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	Repaired bool   `json:"repaired"`
+}
+
+// owner is the expected owner of a file, unknown owners aren't checked
+type owner struct {
+	uid, gid int
+	known    bool
+}
+
+// linkedFile is the first path found linking to a file shared by several paths, and its expected owner
+type linkedFile struct {
+	path  string
+	owner owner
+}
+
+// rootOwner returns the expected owner of the packages directory and of the repositories,
+// it's overridden in tests
+var rootOwner = func() owner {
+	return owner{uid: 0, gid: 0, known: true}
+}
+
+// packageOwner returns the expected owner of the files of the versions of a package, it's
+// overridden in tests
+var packageOwner = func(pkg string) owner {
+	// The agent runs as the dd-agent user on Linux only, see movePackageFromSource
+	if pkg != "datadog-agent" || runtime.GOOS == "darwin" {
+		return rootOwner()
+	}
+	ddAgentUser, err := user.Lookup("dd-agent")
+	if err != nil {
+		log.Warnf("could not look up the dd-agent user, not checking the owner of the agent files: %v", err)
+		return owner{}
+	}
+	ddAgentGroup, err := user.LookupGroup("dd-agent")
+	if err != nil {
+		log.Warnf("could not look up the dd-agent group, not checking the owner of the agent files: %v", err)
+		return owner{}
+	}
+	uid, err := strconv.Atoi(ddAgentUser.Uid)
+	if err != nil {
+		return owner{}
+	}
+	gid, err := strconv.Atoi(ddAgentGroup.Gid)
+	if err != nil {
+		return owner{}
+	}
+	return owner{uid: uid, gid: gid, known: true}
+}
+
+// CheckPermissions checks the owners and modes of the packages directory, of the repositories and
+// of the files of their versions. It returns the issues found.
+func (r *Repositories) CheckPermissions(ctx context.Context) ([]PermissionIssue, error) {
+	return r.verifyPermissions(ctx, false)
+}
+
+// RepairPermissions checks the owners and modes of the packages directory, of the repositories
+// and of the files of their versions, and restores the expected ones. It returns the issues found.
+func (r *Repositories) RepairPermissions(ctx context.Context) ([]PermissionIssue, error) {
+	return r.verifyPermissions(ctx, true)
+}
+
+// verifyPermissions walks the packages directory: the directory, the repositories and their
+// versions must be 0755, the versions of the agent being owned by dd-agent and everything else by
+// root, and no file of a version may be writable by others. Symlinks are skipped, their mode
+// isn't used and the links of the repositories are checked by the state of the packages.
+func (r *Repositories) verifyPermissions(ctx context.Context, repair bool) (issues []PermissionIssue, err error) {
+	if runtime.GOOS == "windows" {
+		// the packages directory is secured by its ACLs
+		return nil, nil
+	}
+	span, _ := tracer.StartSpanFromContext(ctx, "verify_permissions")
+	defer func() {
+		repaired := 0
+		for _, issue := range issues {
+			if issue.Repaired {
+				repaired++
+			}
+		}
+		span.SetTag("repair", repair)
+		span.SetTag("issues", len(issues))
+		span.SetTag("repaired", repaired)
+		span.Finish(tracer.WithError(err))
+	}()
+
+	issues, err = verifyPath(r.rootPath, rootOwner(), dirMode, repair)
+	if err != nil {
+		return issues, err
+	}
+	repositories, err := r.loadRepositories()
+	if err != nil {
+		return issues, err
+	}
+	packages := make([]string, 0, len(repositories))
+	for pkg := range repositories {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	// the files hard linked by the packages of different owners are tracked across the repositories,
+	// changing the owner of one of their paths would change the owner of the others
+	links := make(map[fileID]linkedFile)
+	for _, pkg := range packages {
+		repositoryIssues, err := repositories[pkg].verifyPermissions(rootOwner(), packageOwner(pkg), links, repair)
+		issues = append(issues, repositoryIssues...)
+		if err != nil {
+			return issues, fmt.Errorf("could not verify permissions of package %s: %w", pkg, err)
+		}
+	}
+	return issues, nil
+}
+
+// verifyPermissions verifies the repository directory and the files of its versions. A file sharing
+// its inode with a file of another owner is replaced by a copy when repaired, so that the owners
+// of both can be restored.
+func (r *Repository) verifyPermissions(repositoryOwner owner, versionOwner owner, links map[fileID]linkedFile, repair bool) ([]PermissionIssue, error) {
+	issues, err := verifyPath(r.rootPath, repositoryOwner, dirMode, repair)
+	if err != nil {
+		return issues, err
+	}
+	entries, err := os.ReadDir(r.rootPath)
+	if err != nil {
+		return issues, fmt.Errorf("could not read repository directory: %w", err)
+	}
+	for _, entry := range entries {
+		// the stable and experiment links and the temporary files of the repository
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		versionPath := filepath.Join(r.rootPath, entry.Name())
+		err := filepath.WalkDir(versionPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			if d.Type().IsRegular() {
+				sharedIssue, err := verifyLinks(path, versionOwner, links, repair)
+				if err != nil {
+					return err
+				}
+				if sharedIssue != nil {
+					issues = append(issues, *sharedIssue)
+					if !sharedIssue.Repaired {
+						return nil
+					}
+				}
+			}
+			var mode fs.FileMode
+			if path == versionPath {
+				mode = dirMode
+			}
+			pathIssues, err := verifyPath(path, versionOwner, mode, repair)
+			issues = append(issues, pathIssues...)
+			return err
+		})
+		if err != nil {
+			return issues, fmt.Errorf("could not verify version %s: %w", entry.Name(), err)
+		}
+	}
+	return issues, nil
+}
+
+// verifyLinks checks that a file doesn't share its inode with a file expected to have another owner,
+// and gives it its own inode if repair is set.
+func verifyLinks(path string, expected owner, links map[fileID]linkedFile, repair bool) (*PermissionIssue, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat %s: %w", path, err)
+	}
+	id, ok := linkedFileID(info)
+	if !ok {
+		return nil, nil
+	}
+	first, ok := links[id]
+	if !ok {
+		links[id] = linkedFile{path: path, owner: expected}
+		return nil, nil
+	}
+	if first.owner == expected {
+		return nil, nil
+	}
+	issue := &PermissionIssue{
+		Path:   path,
+		Reason: fmt.Sprintf("shares its inode with %s of another owner", first.path),
+	}
+	if repair {
+		if err := unshareFile(path, info); err != nil {
+			return issue, fmt.Errorf("could not unshare %s: %w", path, err)
+		}
+		issue.Repaired = true
+	}
+	return issue, nil
+}
+
+// unshareFile replaces a hard link by a copy of the file
+func unshareFile(path string, info fs.FileInfo) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky))
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// verifyPath checks the owner of a file and its mode, mode being the expected permissions or 0 if
+// only the forbidden ones are checked, and restores them if repair is set.
+func verifyPath(path string, expected owner, mode fs.FileMode, repair bool) ([]PermissionIssue, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat %s: %w", path, err)
+	}
+	var issues []PermissionIssue
+	special := info.Mode() & (fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if uid, gid, ok := fileOwner(info); ok && expected.known && (uid != expected.uid || gid != expected.gid) {
+		issue := PermissionIssue{
+			Path:   path,
+			Reason: fmt.Sprintf("owned by %d:%d instead of %d:%d", uid, gid, expected.uid, expected.gid),
+		}
+		if repair {
+			if err := os.Lchown(path, expected.uid, expected.gid); err != nil {
+				return append(issues, issue), fmt.Errorf("could not change owner of %s: %w", path, err)
+			}
+			issue.Repaired = true
+			// changing the owner of a file clears its setuid and setgid bits, they are restored
+			if special != 0 {
+				if err := os.Chmod(path, info.Mode().Perm()|special); err != nil {
+					return append(issues, issue), fmt.Errorf("could not restore the mode of %s: %w", path, err)
+				}
+			}
+		}
+		issues = append(issues, issue)
+	}
+	perm := info.Mode().Perm()
+	var issue PermissionIssue
+	switch {
+	case mode != 0 && perm != mode:
+		issue = PermissionIssue{Path: path, Reason: fmt.Sprintf("mode %04o instead of %04o", perm, mode)}
+	case perm&forbiddenMode != 0:
+		issue = PermissionIssue{Path: path, Reason: fmt.Sprintf("mode %04o is writable by others", perm)}
+		mode = perm &^ forbiddenMode
+	default:
+		return issues, nil
+	}
+	if repair {
+		if err := os.Chmod(path, mode|special); err != nil {
+			return append(issues, issue), fmt.Errorf("could not change mode of %s: %w", path, err)
+		}
+		issue.Repaired = true
+	}
+	return append(issues, issue), nil
+}
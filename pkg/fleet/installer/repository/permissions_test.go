@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setTestOwners makes the files of the tests expected to be owned by the current user, and the
+// files of pkg by another one
+func setTestOwners(t *testing.T, otherOwnerPkg string) {
+	root, pkgOwner := rootOwner, packageOwner
+	t.Cleanup(func() { rootOwner, packageOwner = root, pkgOwner })
+	current := owner{uid: os.Getuid(), gid: os.Getgid(), known: true}
+	rootOwner = func() owner { return current }
+	packageOwner = func(pkg string) owner {
+		if pkg == otherOwnerPkg {
+			return owner{uid: current.uid + 1, gid: current.gid + 1, known: true}
+		}
+		return current
+	}
+}
+
+func newTestPermissionsRepositories(t *testing.T) *Repositories {
+	repositories := newTestRepositories(t)
+	require.NoError(t, os.Chmod(repositories.rootPath, 0755))
+	source := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "bin", "agent"), []byte("agent"), 0755))
+	require.NoError(t, repositories.Create(testCtx, "repo1", "v1", source))
+	return repositories
+}
+
+func TestCheckPermissionsNoIssue(t *testing.T) {
+	setTestOwners(t, "")
+	repositories := newTestPermissionsRepositories(t)
+
+	issues, err := repositories.CheckPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestRepairPermissions(t *testing.T) {
+	setTestOwners(t, "")
+	repositories := newTestPermissionsRepositories(t)
+	versionPath := filepath.Join(repositories.rootPath, "repo1", "v1")
+	binaryPath := filepath.Join(versionPath, "bin", "agent")
+	require.NoError(t, os.Chmod(versionPath, 0700))
+	require.NoError(t, os.Chmod(binaryPath, 0777))
+
+	issues, err := repositories.CheckPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Equal(t, []PermissionIssue{
+		{Path: versionPath, Reason: "mode 0700 instead of 0755"},
+		{Path: binaryPath, Reason: "mode 0777 is writable by others"},
+	}, issues)
+
+	issues, err = repositories.RepairPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Len(t, issues, 2)
+	for _, issue := range issues {
+		assert.True(t, issue.Repaired, issue.Path)
+	}
+	info, err := os.Stat(versionPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	info, err = os.Stat(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0775), info.Mode().Perm())
+
+	issues, err = repositories.CheckPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestCheckPermissionsOwner(t *testing.T) {
+	setTestOwners(t, "repo1")
+	repositories := newTestPermissionsRepositories(t)
+
+	issues, err := repositories.CheckPermissions(testCtx)
+	require.NoError(t, err)
+	// the repository is owned by root, its versions by the owner of the package
+	paths := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		assert.Contains(t, issue.Reason, "owned by")
+		paths = append(paths, issue.Path)
+	}
+	versionPath := filepath.Join(repositories.rootPath, "repo1", "v1")
+	assert.Equal(t, []string{versionPath, filepath.Join(versionPath, "bin"), filepath.Join(versionPath, "bin", "agent")}, paths)
+}
+
+func TestRepairPermissionsOwnerKeepsSetgid(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of the files requires root")
+	}
+	setTestOwners(t, "repo1")
+	repositories := newTestPermissionsRepositories(t)
+	binaryPath := filepath.Join(repositories.rootPath, "repo1", "v1", "bin", "agent")
+	require.NoError(t, os.Chmod(binaryPath, 0755|os.ModeSetgid))
+
+	issues, err := repositories.RepairPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Contains(t, issues, PermissionIssue{Path: binaryPath, Reason: fmt.Sprintf("owned by %d:%d instead of %d:%d", os.Getuid(), os.Getgid(), os.Getuid()+1, os.Getgid()+1), Repaired: true})
+	info, err := os.Stat(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, 0755|os.ModeSetgid, info.Mode()&(os.ModePerm|os.ModeSetgid))
+}
+
+func TestRepairPermissionsSharedFile(t *testing.T) {
+	setTestOwners(t, "repo2")
+	repositories := newTestPermissionsRepositories(t)
+	source := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "bin", "agent"), []byte("agent"), 0755))
+	require.NoError(t, repositories.Create(testCtx, "repo2", "v1", source))
+	// the file is hard linked by both packages, e.g. through the store
+	sharedPath := filepath.Join(repositories.rootPath, "repo1", "v1", "bin", "agent")
+	linkPath := filepath.Join(repositories.rootPath, "repo2", "v1", "bin", "agent")
+	require.NoError(t, os.Remove(linkPath))
+	require.NoError(t, os.Link(sharedPath, linkPath))
+
+	issues, err := repositories.CheckPermissions(testCtx)
+	require.NoError(t, err)
+	assert.Contains(t, issues, PermissionIssue{Path: linkPath, Reason: "shares its inode with " + sharedPath + " of another owner"})
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner of the files requires root")
+	}
+
+	_, err = repositories.RepairPermissions(testCtx)
+	require.NoError(t, err)
+	shared, err := os.Stat(sharedPath)
+	require.NoError(t, err)
+	link, err := os.Stat(linkPath)
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(shared, link))
+	content, err := os.ReadFile(linkPath)
+	require.NoError(t, err)
+	assert.Equal(t, "agent", string(content))
+
+	// the owners of both files are stable across repairs
+	for i := 0; i < 2; i++ {
+		issues, err = repositories.RepairPermissions(testCtx)
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	}
+}
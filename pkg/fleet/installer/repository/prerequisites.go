@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+const prerequisitesNotMetFile = "prerequisites_not_met.json"
+
+// PrerequisitesNotMet describes the prerequisites of a package that weren't satisfied on the host
+// when it was set up. The package is installed but some of its features are degraded.
+type PrerequisitesNotMet struct {
+	Version     string                             `json:"version"`
+	Code        installerErrors.InstallerErrorCode `json:"code"`
+	Unsatisfied []string                           `json:"unsatisfied"`
+	Since       time.Time                          `json:"since"`
+}
+
+// SetPrerequisitesNotMet records the prerequisites of the given package that aren't satisfied on the host.
+func (r *Repositories) SetPrerequisitesNotMet(pkg string, version string, unsatisfied []string) error {
+	prerequisitesNotMet, err := r.readPrerequisitesNotMet()
+	if err != nil {
+		return err
+	}
+	prerequisitesNotMet[pkg] = PrerequisitesNotMet{
+		Version:     version,
+		Code:        installerErrors.ErrPrerequisitesNotMet,
+		Unsatisfied: unsatisfied,
+		Since:       time.Now().UTC(),
+	}
+	return r.writePrerequisitesNotMet(prerequisitesNotMet)
+}
+
+// GetPrerequisitesNotMet returns the unsatisfied prerequisites of the given package, nil if there are none.
+func (r *Repositories) GetPrerequisitesNotMet(pkg string) (*PrerequisitesNotMet, error) {
+	prerequisitesNotMet, err := r.readPrerequisitesNotMet()
+	if err != nil {
+		return nil, err
+	}
+	pkgPrerequisitesNotMet, ok := prerequisitesNotMet[pkg]
+	if !ok {
+		return nil, nil
+	}
+	return &pkgPrerequisitesNotMet, nil
+}
+
+// DeletePrerequisitesNotMet clears the unsatisfied prerequisites of the given package, e.g. once
+// they are satisfied.
+func (r *Repositories) DeletePrerequisitesNotMet(pkg string) error {
+	prerequisitesNotMet, err := r.readPrerequisitesNotMet()
+	if err != nil {
+		return err
+	}
+	if _, ok := prerequisitesNotMet[pkg]; !ok {
+		return nil
+	}
+	delete(prerequisitesNotMet, pkg)
+	return r.writePrerequisitesNotMet(prerequisitesNotMet)
+}
+
+func (r *Repositories) readPrerequisitesNotMet() (map[string]PrerequisitesNotMet, error) {
+	prerequisitesNotMet := make(map[string]PrerequisitesNotMet)
+	err := r.readRootFile(prerequisitesNotMetFile, &prerequisitesNotMet)
+	if err != nil {
+		return nil, fmt.Errorf("could not read unsatisfied prerequisites: %w", err)
+	}
+	return prerequisitesNotMet, nil
+}
+
+func (r *Repositories) writePrerequisitesNotMet(prerequisitesNotMet map[string]PrerequisitesNotMet) error {
+	err := r.writeRootFile(prerequisitesNotMetFile, prerequisitesNotMet)
+	if err != nil {
+		return fmt.Errorf("could not write unsatisfied prerequisites: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+func TestRepositoriesPrerequisitesNotMet(t *testing.T) {
+	repositories := newTestRepositories(t)
+	err := repositories.Create(testCtx, "repo1", "v1", t.TempDir())
+	require.NoError(t, err)
+	err = repositories.Create(testCtx, "repo2", "v1", t.TempDir())
+	require.NoError(t, err)
+
+	err = repositories.SetPrerequisitesNotMet("repo1", "v1", []string{"kernel BTF or headers"})
+	require.NoError(t, err)
+
+	// Unsatisfied prerequisites are read from disk
+	repositories = NewRepositories(repositories.rootPath, repositories.locksPath)
+	state, err := repositories.GetState()
+	assert.NoError(t, err)
+	require.NotNil(t, state["repo1"].PrerequisitesNotMet)
+	assert.Equal(t, "v1", state["repo1"].PrerequisitesNotMet.Version)
+	assert.Equal(t, installerErrors.ErrPrerequisitesNotMet, state["repo1"].PrerequisitesNotMet.Code)
+	assert.Equal(t, []string{"kernel BTF or headers"}, state["repo1"].PrerequisitesNotMet.Unsatisfied)
+	assert.False(t, state["repo1"].PrerequisitesNotMet.Since.IsZero())
+	assert.Nil(t, state["repo2"].PrerequisitesNotMet)
+
+	err = repositories.DeletePrerequisitesNotMet("repo1")
+	assert.NoError(t, err)
+	packageState, err := repositories.GetPackageState("repo1")
+	assert.NoError(t, err)
+	assert.Nil(t, packageState.PrerequisitesNotMet)
+
+	err = repositories.SetPrerequisitesNotMet("repo1", "v1", []string{"kernel BTF or headers"})
+	require.NoError(t, err)
+	err = repositories.Delete(testCtx, "repo1")
+	assert.NoError(t, err)
+	prerequisitesNotMet, err := repositories.GetPrerequisitesNotMet("repo1")
+	assert.NoError(t, err)
+	assert.Nil(t, prerequisitesNotMet)
+}
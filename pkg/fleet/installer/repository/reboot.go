@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	rebootRequiredFile = "reboot_required.json"
+
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+)
+
+// bootID returns an identifier of the current boot of the host, empty if the platform doesn't have one
+var bootID = func() (string, error) {
+	id, err := os.ReadFile(bootIDPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read boot ID: %w", err)
+	}
+	return strings.TrimSpace(string(id)), nil
+}
+
+// RebootRequired describes why a package needs the host to reboot to be fully applied.
+type RebootRequired struct {
+	Version string    `json:"version"`
+	Reason  string    `json:"reason"`
+	Since   time.Time `json:"since"`
+	// BootID is the boot of the host when the reboot became required, the reboot is
+	// considered done once it changes
+	BootID string `json:"boot_id"`
+}
+
+// SetRebootRequired flags the given package as requiring a reboot of the host.
+func (r *Repositories) SetRebootRequired(pkg string, version string, reason string) error {
+	id, err := bootID()
+	if err != nil {
+		return err
+	}
+	rebootRequired, err := r.readRebootRequired()
+	if err != nil {
+		return err
+	}
+	rebootRequired[pkg] = RebootRequired{
+		Version: version,
+		Reason:  reason,
+		Since:   time.Now().UTC(),
+		BootID:  id,
+	}
+	return r.writeRebootRequired(rebootRequired)
+}
+
+// GetRebootRequired returns why the given package requires a reboot, nil if it doesn't.
+func (r *Repositories) GetRebootRequired(pkg string) (*RebootRequired, error) {
+	rebootRequired, err := r.readRebootRequired()
+	if err != nil {
+		return nil, err
+	}
+	pkgRebootRequired, ok := rebootRequired[pkg]
+	if !ok {
+		return nil, nil
+	}
+	return &pkgRebootRequired, nil
+}
+
+func (r *Repositories) deleteRebootRequired(pkg string) error {
+	rebootRequired, err := r.readRebootRequired()
+	if err != nil {
+		return err
+	}
+	if _, ok := rebootRequired[pkg]; !ok {
+		return nil
+	}
+	delete(rebootRequired, pkg)
+	return r.writeRebootRequired(rebootRequired)
+}
+
+// readRebootRequired returns the packages requiring a reboot. Packages flagged during a
+// previous boot of the host are left out, the file is cleaned up on the next write.
+func (r *Repositories) readRebootRequired() (map[string]RebootRequired, error) {
+	rebootRequired := make(map[string]RebootRequired)
+	err := r.readRootFile(rebootRequiredFile, &rebootRequired)
+	if err != nil {
+		return nil, fmt.Errorf("could not read reboot requirements: %w", err)
+	}
+	id, err := bootID()
+	if err != nil {
+		return nil, err
+	}
+	for pkg, pkgRebootRequired := range rebootRequired {
+		if id != "" && pkgRebootRequired.BootID != id {
+			delete(rebootRequired, pkg)
+		}
+	}
+	return rebootRequired, nil
+}
+
+func (r *Repositories) writeRebootRequired(rebootRequired map[string]RebootRequired) error {
+	err := r.writeRootFile(rebootRequiredFile, rebootRequired)
+	if err != nil {
+		return fmt.Errorf("could not write reboot requirements: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setTestBootID(t *testing.T, id string) {
+	previous := bootID
+	bootID = func() (string, error) { return id, nil }
+	t.Cleanup(func() { bootID = previous })
+}
+
+func TestRepositoriesRebootRequired(t *testing.T) {
+	setTestBootID(t, "boot-1")
+	repositories := newTestRepositories(t)
+	err := repositories.Create(testCtx, "repo1", "v1", t.TempDir())
+	require.NoError(t, err)
+	err = repositories.Create(testCtx, "repo2", "v1", t.TempDir())
+	require.NoError(t, err)
+
+	err = repositories.SetRebootRequired("repo1", "v1", "kernel module update")
+	require.NoError(t, err)
+
+	// Requirements are read from disk
+	repositories = NewRepositories(repositories.rootPath, repositories.locksPath)
+	state, err := repositories.GetState()
+	assert.NoError(t, err)
+	require.NotNil(t, state["repo1"].RebootRequired)
+	assert.Equal(t, "v1", state["repo1"].RebootRequired.Version)
+	assert.Equal(t, "kernel module update", state["repo1"].RebootRequired.Reason)
+	assert.Equal(t, "boot-1", state["repo1"].RebootRequired.BootID)
+	assert.False(t, state["repo1"].RebootRequired.Since.IsZero())
+	assert.Nil(t, state["repo2"].RebootRequired)
+
+	err = repositories.Delete(testCtx, "repo1")
+	assert.NoError(t, err)
+	rebootRequired, err := repositories.GetRebootRequired("repo1")
+	assert.NoError(t, err)
+	assert.Nil(t, rebootRequired)
+}
+
+func TestRepositoriesRebootRequiredAfterReboot(t *testing.T) {
+	setTestBootID(t, "boot-1")
+	repositories := newTestRepositories(t)
+
+	err := repositories.SetRebootRequired("repo1", "v1", "kernel module update")
+	require.NoError(t, err)
+	rebootRequired, err := repositories.GetRebootRequired("repo1")
+	assert.NoError(t, err)
+	assert.NotNil(t, rebootRequired)
+
+	setTestBootID(t, "boot-2")
+	rebootRequired, err = repositories.GetRebootRequired("repo1")
+	assert.NoError(t, err)
+	assert.Nil(t, rebootRequired)
+}
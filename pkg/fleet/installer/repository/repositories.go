@@ -7,12 +7,17 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// errCorruptRootFile is returned when a file stored at the root of the repositories can't be parsed
+var errCorruptRootFile = errors.New("corrupt file")
+
 // Repositories manages multiple repositories.
 type Repositories struct {
 	rootPath  string
@@ -48,6 +53,10 @@ func (r *Repositories) loadRepositories() (map[string]*Repository, error) {
 			// Temporary extraction dir, ignore
 			continue
 		}
+		if strings.HasPrefix(d.Name(), ".") {
+			// Hidden dir such as the shared object store, ignore
+			continue
+		}
 		repo := r.newRepository(d.Name())
 		repositories[d.Name()] = repo
 	}
@@ -77,6 +86,18 @@ func (r *Repositories) Delete(_ context.Context, pkg string) error {
 	if err != nil {
 		return fmt.Errorf("could not delete repository for package %s: %w", pkg, err)
 	}
+	err = r.deleteUsage(pkg)
+	if err != nil {
+		return fmt.Errorf("could not delete usage for package %s: %w", pkg, err)
+	}
+	err = r.deleteRebootRequired(pkg)
+	if err != nil {
+		return fmt.Errorf("could not delete reboot requirement for package %s: %w", pkg, err)
+	}
+	err = r.DeletePrerequisitesNotMet(pkg)
+	if err != nil {
+		return fmt.Errorf("could not delete unsatisfied prerequisites for package %s: %w", pkg, err)
+	}
 	return nil
 }
 
@@ -87,11 +108,33 @@ func (r *Repositories) GetState() (map[string]State, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not load repositories: %w", err)
 	}
+	usage, err := r.readUsage()
+	if err != nil {
+		return nil, fmt.Errorf("could not load usage: %w", err)
+	}
+	rebootRequired, err := r.readRebootRequired()
+	if err != nil {
+		return nil, fmt.Errorf("could not load reboot requirements: %w", err)
+	}
+	prerequisitesNotMet, err := r.readPrerequisitesNotMet()
+	if err != nil {
+		return nil, fmt.Errorf("could not load unsatisfied prerequisites: %w", err)
+	}
 	for name, repo := range repositories {
-		state[name], err = repo.GetState()
+		repoState, err := repo.GetState()
 		if err != nil {
 			return nil, fmt.Errorf("could not get state for repository %s: %w", name, err)
 		}
+		if pkgUsage, ok := usage[name]; ok {
+			repoState.Usage = &pkgUsage
+		}
+		if pkgRebootRequired, ok := rebootRequired[name]; ok {
+			repoState.RebootRequired = &pkgRebootRequired
+		}
+		if pkgPrerequisitesNotMet, ok := prerequisitesNotMet[name]; ok {
+			repoState.PrerequisitesNotMet = &pkgPrerequisitesNotMet
+		}
+		state[name] = repoState
 	}
 	return state, nil
 }
@@ -99,7 +142,26 @@ func (r *Repositories) GetState() (map[string]State, error) {
 // GetPackageState returns the state of the given package.
 func (r *Repositories) GetPackageState(pkg string) (State, error) {
 	repo := r.newRepository(pkg)
-	return repo.GetState()
+	state, err := repo.GetState()
+	if err != nil {
+		return State{}, err
+	}
+	usage, err := r.readUsage()
+	if err != nil {
+		return State{}, fmt.Errorf("could not load usage: %w", err)
+	}
+	if pkgUsage, ok := usage[pkg]; ok {
+		state.Usage = &pkgUsage
+	}
+	state.RebootRequired, err = r.GetRebootRequired(pkg)
+	if err != nil {
+		return State{}, fmt.Errorf("could not load reboot requirement: %w", err)
+	}
+	state.PrerequisitesNotMet, err = r.GetPrerequisitesNotMet(pkg)
+	if err != nil {
+		return State{}, fmt.Errorf("could not load unsatisfied prerequisites: %w", err)
+	}
+	return state, nil
 }
 
 // Cleanup cleans up the repositories.
@@ -116,3 +178,54 @@ func (r *Repositories) Cleanup(ctx context.Context) error {
 	}
 	return nil
 }
+
+// readRootFile parses a JSON file stored at the root of the repositories. v is left
+// untouched if the file doesn't exist.
+func (r *Repositories) readRootFile(name string, v any) error {
+	content, err := os.ReadFile(filepath.Join(r.rootPath, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+	err = json.Unmarshal(content, v)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w: %w", name, errCorruptRootFile, err)
+	}
+	return nil
+}
+
+// writeRootFile replaces a JSON file stored at the root of the repositories atomically so
+// readers never see a partial file.
+func (r *Repositories) writeRootFile(name string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not serialize %s: %w", name, err)
+	}
+	err = os.MkdirAll(r.rootPath, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create root directory: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(r.rootPath, name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", name, err)
+	}
+	err = os.Chmod(tmpFile.Name(), 0644)
+	if err != nil {
+		return fmt.Errorf("could not set permissions on %s: %w", name, err)
+	}
+	err = os.Rename(tmpFile.Name(), filepath.Join(r.rootPath, name))
+	if err != nil {
+		return fmt.Errorf("could not move %s: %w", name, err)
+	}
+	return nil
+}
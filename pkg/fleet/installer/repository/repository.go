@@ -38,8 +38,13 @@ var (
 // .
 // ├── 7.50.0
 // ├── 7.51.0
+// ├── 7.49.0
 // ├── stable -> 7.50.0 (symlink)
-// └── experiment -> 7.51.0 (symlink)
+// ├── experiment -> 7.51.0 (symlink)
+// └── previous -> 7.49.0 (symlink)
+//
+// The previous link is set to the former stable package when an experiment is promoted, the
+// package is kept on disk to allow rolling back to it.
 //
 // and the locks directory (if any) is structured as follows:
 // .
@@ -66,6 +71,15 @@ type Repository struct {
 type State struct {
 	Stable     string
 	Experiment string
+	// Previous is the version that was stable before the last promotion, if it can be rolled back to
+	Previous string `json:",omitempty"`
+
+	// Usage is the bandwidth and disk usage accumulated by the package, if any was recorded
+	Usage *PackageUsage `json:",omitempty"`
+	// RebootRequired is set if the package needs the host to reboot to be fully applied
+	RebootRequired *RebootRequired `json:",omitempty"`
+	// PrerequisitesNotMet is set if some prerequisites of the package weren't satisfied on the host when it was set up
+	PrerequisitesNotMet *PrerequisitesNotMet `json:",omitempty"`
 }
 
 // HasStable returns true if the repository has a stable package.
@@ -88,6 +102,16 @@ func (r *Repository) ExperimentFS() fs.FS {
 	return os.DirFS(filepath.Join(r.rootPath, experimentVersionLink))
 }
 
+// StablePath returns the path of the stable package.
+func (r *Repository) StablePath() string {
+	return filepath.Join(r.rootPath, stableVersionLink)
+}
+
+// ExperimentPath returns the path of the experiment package.
+func (r *Repository) ExperimentPath() string {
+	return filepath.Join(r.rootPath, experimentVersionLink)
+}
+
 // GetState returns the state of the repository.
 func (r *Repository) GetState() (State, error) {
 	repository, err := readRepository(r.rootPath, r.locksPath)
@@ -100,6 +124,7 @@ func (r *Repository) GetState() (State, error) {
 	return State{
 		Stable:     repository.stable.Target(),
 		Experiment: repository.experiment.Target(),
+		Previous:   repository.previous.Target(),
 	}, nil
 }
 
@@ -123,6 +148,12 @@ func (r *Repository) Create(ctx context.Context, name string, stableSourcePath s
 	}
 
 	// Remove symlinks as we are bootstrapping
+	if repository.previous.Exists() {
+		err = repository.previous.Delete()
+		if err != nil {
+			return fmt.Errorf("could not delete previous link: %w", err)
+		}
+	}
 	if repository.experiment.Exists() {
 		err = repository.experiment.Delete()
 		if err != nil {
@@ -166,14 +197,21 @@ func (r *Repository) Create(ctx context.Context, name string, stableSourcePath s
 
 // SetExperiment moves package files from the given source path to the repository and sets it as the experiment.
 //
-// 1. Cleanup the repository.
-// 2. Move the experiment source to the repository.
-// 3. Set the experiment link to the experiment package.
+// 1. Delete the previous link if it points to the experiment package, which is installed again.
+// 2. Cleanup the repository.
+// 3. Move the experiment source to the repository.
+// 4. Set the experiment link to the experiment package.
 func (r *Repository) SetExperiment(ctx context.Context, name string, sourcePath string) error {
 	repository, err := readRepository(r.rootPath, r.locksPath)
 	if err != nil {
 		return err
 	}
+	if repository.previous.Exists() && repository.previous.Target() == name {
+		err = repository.previous.Delete()
+		if err != nil {
+			return fmt.Errorf("could not delete previous link: %w", err)
+		}
+	}
 	err = repository.cleanup(ctx)
 	if err != nil {
 		return fmt.Errorf("could not cleanup repository: %w", err)
@@ -191,9 +229,10 @@ func (r *Repository) SetExperiment(ctx context.Context, name string, sourcePath
 // PromoteExperiment promotes the experiment to stable.
 //
 // 1. Cleanup the repository.
-// 2. Set the stable link to the experiment package.
-// 3. Delete the experiment link.
-// 4. Cleanup the repository to remove the previous stable package.
+// 2. Set the previous link to the stable package.
+// 3. Set the stable link to the experiment package.
+// 4. Delete the experiment link.
+// 5. Cleanup the repository to remove the package that was previous.
 func (r *Repository) PromoteExperiment(ctx context.Context) error {
 	repository, err := readRepository(r.rootPath, r.locksPath)
 	if err != nil {
@@ -209,6 +248,10 @@ func (r *Repository) PromoteExperiment(ctx context.Context) error {
 	if !repository.experiment.Exists() {
 		return fmt.Errorf("experiment package does not exist, invalid state")
 	}
+	err = repository.previous.Set(*repository.stable.packagePath)
+	if err != nil {
+		return fmt.Errorf("could not set previous: %w", err)
+	}
 	err = repository.stable.Set(*repository.experiment.packagePath)
 	if err != nil {
 		return fmt.Errorf("could not set stable: %w", err)
@@ -267,6 +310,51 @@ func (r *Repository) DeleteExperiment(ctx context.Context) error {
 	return nil
 }
 
+// Rollback sets the previous stable package as stable again.
+//
+// 1. Cleanup the repository.
+// 2. Set the stable link to the previous package.
+// 3. Delete the previous link.
+// 4. Cleanup the repository to remove the package that was stable.
+func (r *Repository) Rollback(ctx context.Context) error {
+	repository, err := readRepository(r.rootPath, r.locksPath)
+	if err != nil {
+		return err
+	}
+	err = repository.cleanup(ctx)
+	if err != nil {
+		return fmt.Errorf("could not cleanup repository: %w", err)
+	}
+	if !repository.stable.Exists() {
+		return fmt.Errorf("stable package does not exist, invalid state")
+	}
+	if repository.experiment.Exists() {
+		return fmt.Errorf("an experiment is running, it must be stopped or promoted before rolling back")
+	}
+	if !repository.previous.Exists() {
+		return fmt.Errorf("no previous package to rollback to")
+	}
+	err = repository.stable.Set(*repository.previous.packagePath)
+	if err != nil {
+		return fmt.Errorf("could not set stable: %w", err)
+	}
+	err = repository.previous.Delete()
+	if err != nil {
+		return fmt.Errorf("could not delete previous link: %w", err)
+	}
+
+	// Read repository again to re-load the list of locked packages
+	repository, err = readRepository(r.rootPath, r.locksPath)
+	if err != nil {
+		return err
+	}
+	err = repository.cleanup(ctx)
+	if err != nil {
+		return fmt.Errorf("could not cleanup repository: %w", err)
+	}
+	return nil
+}
+
 // Cleanup calls the cleanup function of the repository
 func (r *Repository) Cleanup(ctx context.Context) error {
 	repository, err := readRepository(r.rootPath, r.locksPath)
@@ -283,6 +371,7 @@ type repositoryFiles struct {
 
 	stable     *link
 	experiment *link
+	previous   *link
 }
 
 func readRepository(rootPath string, locksPath string) (*repositoryFiles, error) {
@@ -294,6 +383,10 @@ func readRepository(rootPath string, locksPath string) (*repositoryFiles, error)
 	if err != nil {
 		return nil, fmt.Errorf("could not load experiment link: %w", err)
 	}
+	previousLink, err := newLink(filepath.Join(rootPath, previousVersionLink))
+	if err != nil {
+		return nil, fmt.Errorf("could not load previous link: %w", err)
+	}
 
 	// List locked packages
 	packages, err := os.ReadDir(rootPath)
@@ -320,6 +413,7 @@ func readRepository(rootPath string, locksPath string) (*repositoryFiles, error)
 		lockedPackages: lockedPackages,
 		stable:         stableLink,
 		experiment:     experimentLink,
+		previous:       previousLink,
 	}, nil
 }
 
@@ -342,7 +436,7 @@ func (r *repositoryFiles) setStable(ctx context.Context, name string, sourcePath
 }
 
 func movePackageFromSource(ctx context.Context, packageName string, rootPath string, lockedPackages map[string]bool, sourcePath string) (string, error) {
-	if packageName == "" || packageName == stableVersionLink || packageName == experimentVersionLink {
+	if packageName == "" || packageName == stableVersionLink || packageName == experimentVersionLink || packageName == previousVersionLink {
 		return "", fmt.Errorf("invalid package name")
 	}
 	targetPath := filepath.Join(rootPath, packageName)
@@ -367,7 +461,8 @@ func movePackageFromSource(ctx context.Context, packageName string, rootPath str
 	if err := os.Chmod(targetPath, 0755); err != nil {
 		return "", fmt.Errorf("could not set permissions on package: %w", err)
 	}
-	if filepath.Base(rootPath) == "datadog-agent" && runtime.GOOS != "windows" {
+	// The agent runs as the dd-agent user on Linux only, there is no such user on Windows and macOS
+	if filepath.Base(rootPath) == "datadog-agent" && runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
 		if err := exec.CommandContext(ctx, "chown", "-R", "dd-agent:dd-agent", targetPath).Run(); err != nil {
 			return "", err
 		}
@@ -386,10 +481,11 @@ func (r *repositoryFiles) cleanup(_ context.Context) error {
 	// The injector is ran directly with the service as it's a LD_PRELOAD, and has access
 	// to the PIDs.
 	for _, file := range files {
-		isLink := file.Name() == stableVersionLink || file.Name() == experimentVersionLink
+		isLink := file.Name() == stableVersionLink || file.Name() == experimentVersionLink || file.Name() == previousVersionLink
 		isStable := r.stable.Exists() && r.stable.Target() == file.Name()
 		isExperiment := r.experiment.Exists() && r.experiment.Target() == file.Name()
-		if isLink || isStable || isExperiment {
+		isPrevious := r.previous.Exists() && r.previous.Target() == file.Name()
+		if isLink || isStable || isExperiment || isPrevious {
 			continue
 		}
 
@@ -134,6 +134,18 @@ func TestPromoteExperiment(t *testing.T) {
 	assert.NoError(t, err)
 	err = repository.PromoteExperiment(testCtx)
 	assert.NoError(t, err)
+	assert.DirExists(t, path.Join(repository.rootPath, "v1"))
+	assert.DirExists(t, path.Join(repository.rootPath, "v2"))
+	state, err := repository.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, State{Stable: "v2", Previous: "v1"}, state)
+
+	// only the last stable package is kept
+	experimentDownloadPackagePath = createTestDownloadedPackage(t, dir, "v3")
+	err = repository.SetExperiment(testCtx, "v3", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+	err = repository.PromoteExperiment(testCtx)
+	assert.NoError(t, err)
 	assert.NoDirExists(t, path.Join(repository.rootPath, "v1"))
 	assert.DirExists(t, path.Join(repository.rootPath, "v2"))
 }
@@ -146,6 +158,65 @@ func TestPromoteExperimentWithoutExperiment(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRollback(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("FIXME: broken on darwin")
+	}
+	dir := t.TempDir()
+	repository := createTestRepository(t, dir, "v1")
+	experimentDownloadPackagePath := createTestDownloadedPackage(t, dir, "v2")
+
+	err := repository.SetExperiment(testCtx, "v2", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+	err = repository.PromoteExperiment(testCtx)
+	assert.NoError(t, err)
+	err = repository.Rollback(testCtx)
+	assert.NoError(t, err)
+	assert.DirExists(t, path.Join(repository.rootPath, "v1"))
+	assert.NoDirExists(t, path.Join(repository.rootPath, "v2"))
+	state, err := repository.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, State{Stable: "v1"}, state)
+
+	// there is nothing left to rollback to
+	err = repository.Rollback(testCtx)
+	assert.Error(t, err)
+}
+
+func TestRollbackWithExperiment(t *testing.T) {
+	dir := t.TempDir()
+	repository := createTestRepository(t, dir, "v1")
+	experimentDownloadPackagePath := createTestDownloadedPackage(t, dir, "v2")
+	err := repository.SetExperiment(testCtx, "v2", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+	err = repository.PromoteExperiment(testCtx)
+	assert.NoError(t, err)
+	experimentDownloadPackagePath = createTestDownloadedPackage(t, dir, "v3")
+	err = repository.SetExperiment(testCtx, "v3", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+
+	err = repository.Rollback(testCtx)
+	assert.Error(t, err)
+}
+
+func TestSetExperimentPrevious(t *testing.T) {
+	dir := t.TempDir()
+	repository := createTestRepository(t, dir, "v1")
+	experimentDownloadPackagePath := createTestDownloadedPackage(t, dir, "v2")
+	err := repository.SetExperiment(testCtx, "v2", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+	err = repository.PromoteExperiment(testCtx)
+	assert.NoError(t, err)
+
+	// the previous package is installed again as an experiment
+	experimentDownloadPackagePath = createTestDownloadedPackage(t, dir, "v1")
+	err = repository.SetExperiment(testCtx, "v1", experimentDownloadPackagePath)
+	assert.NoError(t, err)
+	state, err := repository.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, State{Stable: "v2", Experiment: "v1"}, state)
+}
+
 func TestDeleteExperiment(t *testing.T) {
 	if runtime.GOOS == "darwin" {
 		t.Skip("FIXME: broken on darwin")
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	usageFile = "usage.json"
+
+	// maxUsageOperations is the number of operations kept in the history of a package
+	maxUsageOperations = 10
+)
+
+// OperationUsage is the bandwidth and disk usage of a single operation on a package.
+type OperationUsage struct {
+	Operation       string        `json:"operation"`
+	Version         string        `json:"version"`
+	BytesDownloaded int64         `json:"bytes_downloaded"`
+	BytesWritten    int64         `json:"bytes_written"`
+	Duration        time.Duration `json:"duration"`
+	Time            time.Time     `json:"time"`
+}
+
+// PackageUsage is the bandwidth and disk usage accumulated by the operations on a package.
+type PackageUsage struct {
+	Operations      int64         `json:"operations"`
+	BytesDownloaded int64         `json:"bytes_downloaded"`
+	BytesWritten    int64         `json:"bytes_written"`
+	Duration        time.Duration `json:"duration"`
+
+	// History holds the most recent operations, oldest first
+	History []OperationUsage `json:"history"`
+}
+
+func (u *PackageUsage) add(op OperationUsage) {
+	u.Operations++
+	u.BytesDownloaded += op.BytesDownloaded
+	u.BytesWritten += op.BytesWritten
+	u.Duration += op.Duration
+	u.History = append(u.History, op)
+	if len(u.History) > maxUsageOperations {
+		u.History = u.History[len(u.History)-maxUsageOperations:]
+	}
+}
+
+// RecordUsage adds the usage of an operation to the usage accumulated by the given package.
+//
+// Usage is stored in a file at the root of the repositories as operations may be run by
+// different processes than the one reading the state.
+func (r *Repositories) RecordUsage(pkg string, op OperationUsage) error {
+	usage, err := r.readUsage()
+	if err != nil {
+		return err
+	}
+	pkgUsage := usage[pkg]
+	pkgUsage.add(op)
+	usage[pkg] = pkgUsage
+	return r.writeUsage(usage)
+}
+
+// GetUsage returns the usage accumulated by the given package.
+func (r *Repositories) GetUsage(pkg string) (PackageUsage, error) {
+	usage, err := r.readUsage()
+	if err != nil {
+		return PackageUsage{}, err
+	}
+	return usage[pkg], nil
+}
+
+func (r *Repositories) deleteUsage(pkg string) error {
+	usage, err := r.readUsage()
+	if err != nil {
+		return err
+	}
+	if _, ok := usage[pkg]; !ok {
+		return nil
+	}
+	delete(usage, pkg)
+	return r.writeUsage(usage)
+}
+
+// readUsage reads the usage accumulated by the packages. Usage is informational: a corrupt usage
+// file, e.g. after the host ran out of disk space, is reset instead of failing the state of the packages.
+func (r *Repositories) readUsage() (map[string]PackageUsage, error) {
+	usage := make(map[string]PackageUsage)
+	err := r.readRootFile(usageFile, &usage)
+	if errors.Is(err, errCorruptRootFile) {
+		log.Warnf("resetting the usage of the packages: %v", err)
+		err = os.Remove(filepath.Join(r.rootPath, usageFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("could not remove corrupt usage file: %v", err)
+		}
+		return make(map[string]PackageUsage), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read usage: %w", err)
+	}
+	return usage, nil
+}
+
+func (r *Repositories) writeUsage(usage map[string]PackageUsage) error {
+	err := r.writeRootFile(usageFile, usage)
+	if err != nil {
+		return fmt.Errorf("could not write usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoriesUsage(t *testing.T) {
+	repositories := newTestRepositories(t)
+	err := repositories.Create(testCtx, "repo1", "v1", t.TempDir())
+	require.NoError(t, err)
+	err = repositories.Create(testCtx, "repo2", "v1", t.TempDir())
+	require.NoError(t, err)
+
+	err = repositories.RecordUsage("repo1", OperationUsage{Operation: "install", Version: "v1", BytesDownloaded: 100, BytesWritten: 300, Duration: time.Second})
+	require.NoError(t, err)
+	err = repositories.RecordUsage("repo1", OperationUsage{Operation: "install_experiment", Version: "v2", BytesDownloaded: 50, BytesWritten: 200, Duration: time.Second})
+	require.NoError(t, err)
+
+	// Usage is read from disk
+	repositories = NewRepositories(repositories.rootPath, repositories.locksPath)
+	state, err := repositories.GetState()
+	assert.NoError(t, err)
+	assert.Len(t, state, 2)
+	require.NotNil(t, state["repo1"].Usage)
+	assert.Equal(t, int64(2), state["repo1"].Usage.Operations)
+	assert.Equal(t, int64(150), state["repo1"].Usage.BytesDownloaded)
+	assert.Equal(t, int64(500), state["repo1"].Usage.BytesWritten)
+	assert.Equal(t, 2*time.Second, state["repo1"].Usage.Duration)
+	assert.Len(t, state["repo1"].Usage.History, 2)
+	assert.Nil(t, state["repo2"].Usage)
+
+	err = repositories.Delete(testCtx, "repo1")
+	assert.NoError(t, err)
+	usage, err := repositories.GetUsage("repo1")
+	assert.NoError(t, err)
+	assert.Equal(t, PackageUsage{}, usage)
+}
+
+func TestRepositoriesUsageHistory(t *testing.T) {
+	repositories := newTestRepositories(t)
+
+	for i := 0; i < maxUsageOperations+5; i++ {
+		err := repositories.RecordUsage("repo1", OperationUsage{Version: fmt.Sprintf("v%d", i), BytesDownloaded: 1})
+		require.NoError(t, err)
+	}
+
+	usage, err := repositories.GetUsage("repo1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(maxUsageOperations+5), usage.Operations)
+	assert.Equal(t, int64(maxUsageOperations+5), usage.BytesDownloaded)
+	require.Len(t, usage.History, maxUsageOperations)
+	assert.Equal(t, "v5", usage.History[0].Version)
+	assert.Equal(t, fmt.Sprintf("v%d", maxUsageOperations+4), usage.History[maxUsageOperations-1].Version)
+}
+
+func TestRepositoriesUsageCorrupt(t *testing.T) {
+	repositories := newTestRepositories(t)
+	err := repositories.Create(testCtx, "repo1", "v1", t.TempDir())
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(repositories.rootPath, usageFile), []byte(`{"repo1":`), 0644)
+	require.NoError(t, err)
+
+	// the corrupt usage doesn't fail the state and is reset
+	state, err := repositories.GetState()
+	require.NoError(t, err)
+	assert.Nil(t, state["repo1"].Usage)
+	assert.NoFileExists(t, filepath.Join(repositories.rootPath, usageFile))
+
+	err = repositories.RecordUsage("repo1", OperationUsage{Operation: "install", Version: "v1", BytesDownloaded: 100})
+	require.NoError(t, err)
+	usage, err := repositories.GetUsage("repo1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Operations)
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+// Package service provides a way to interact with os services
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const agentEnvironmentPath = "/etc/datadog-agent/environment"
+
+var (
+	apiKeyPattern         = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	apiKeyConfigPattern   = regexp.MustCompile(`(?m)^api_key:.*$`)
+	apiKeyEnvironmentLine = regexp.MustCompile(`(?m)^DD_API_KEY=.*$`)
+)
+
+// RotateAPIKey replaces the API key in the agent configuration files and restarts the running agent units.
+//
+// The configuration files are only modified once all of them have been prepared, and restored
+// along with the units if the units fail to restart with the new key.
+func RotateAPIKey(ctx context.Context, apiKey string) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "rotate_api_key")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	if !apiKeyPattern.MatchString(apiKey) {
+		return fmt.Errorf("invalid API key")
+	}
+
+	mutators := []*fileMutator{
+		newFileMutator(agentConfigPath, func(_ context.Context, existing []byte) ([]byte, error) {
+			return setAPIKeyConfig(existing, apiKey)
+		}, nil, nil),
+		newFileMutator(agentEnvironmentPath, func(_ context.Context, existing []byte) ([]byte, error) {
+			return setAPIKeyEnvironment(existing, apiKey), nil
+		}, nil, nil),
+	}
+	var rollbacks []func() error
+	defer func() {
+		for _, mutator := range mutators {
+			mutator.cleanup()
+		}
+	}()
+	rollback := func() error {
+		var rollbackErr error
+		for i := len(rollbacks) - 1; i >= 0; i-- {
+			rollbackErr = errors.Join(rollbackErr, rollbacks[i]())
+		}
+		return rollbackErr
+	}
+
+	for _, mutator := range mutators {
+		rollbackFile, err := mutator.mutate(ctx)
+		if err != nil {
+			return errors.Join(fmt.Errorf("could not set API key in %s: %w", mutator.path, err), rollback())
+		}
+		rollbacks = append(rollbacks, rollbackFile)
+	}
+
+	err = restartAgentUnits(ctx)
+	if err != nil {
+		log.Errorf("Failed to restart the agent with the new API key, restoring the previous one: %s", err)
+		err = errors.Join(fmt.Errorf("could not restart the agent: %w", err), rollback())
+		return errors.Join(err, restartAgentUnits(ctx))
+	}
+	return nil
+}
+
+// RestartAgent restarts the agent units that are running, e.g. for them to load the integration
+// configurations delivered remotely
+func RestartAgent(ctx context.Context) error {
+	return restartAgentUnits(ctx)
+}
+
+// restartAgentUnits restarts the agent units that are running, stable or experiment
+func restartAgentUnits(ctx context.Context) error {
+	for _, units := range [][]string{stableUnits, experimentalUnits} {
+		for _, unit := range units {
+			if err := tryRestartUnit(ctx, unit); err != nil {
+				return fmt.Errorf("failed to restart %s: %w", unit, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setAPIKeyConfig sets the api_key of a datadog.yaml configuration file, preserving the rest of its content
+func setAPIKeyConfig(config []byte, apiKey string) ([]byte, error) {
+	if len(config) == 0 {
+		return nil, fmt.Errorf("agent configuration is missing")
+	}
+	line := []byte("api_key: " + apiKey)
+	if apiKeyConfigPattern.Match(config) {
+		return apiKeyConfigPattern.ReplaceAllLiteral(config, line), nil
+	}
+	res := append([]byte{}, config...)
+	if res[len(res)-1] != '\n' {
+		res = append(res, '\n')
+	}
+	return append(append(res, line...), '\n'), nil
+}
+
+// setAPIKeyEnvironment replaces DD_API_KEY in an environment file if it is set, as it takes
+// precedence over the configuration file
+func setAPIKeyEnvironment(envFile []byte, apiKey string) []byte {
+	if !apiKeyEnvironmentLine.Match(envFile) {
+		return envFile
+	}
+	return apiKeyEnvironmentLine.ReplaceAllLiteral(envFile, []byte("DD_API_KEY="+apiKey))
+}
@@ -0,0 +1,24 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows || darwin
+
+// Package service provides a way to interact with os services
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// RotateAPIKey is not supported on this platform
+func RotateAPIKey(_ context.Context, _ string) error {
+	return fmt.Errorf("API key rotation is not supported on this platform")
+}
+
+// RestartAgent is not supported on this platform
+func RestartAgent(_ context.Context) error {
+	return fmt.Errorf("restarting the agent is not supported on this platform")
+}
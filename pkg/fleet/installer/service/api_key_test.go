@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+// Package service provides a way to interact with os services
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAPIKeyConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "replace existing key",
+			input:    "# comment\napi_key: oldkey\nsite: datadoghq.com\n",
+			expected: "# comment\napi_key: newkey\nsite: datadoghq.com\n",
+		},
+		{
+			name:     "ignore nested and commented keys",
+			input:    "#api_key: commented\nproxy:\n  api_key: nested\napi_key: \"oldkey\" # quoted\n",
+			expected: "#api_key: commented\nproxy:\n  api_key: nested\napi_key: newkey\n",
+		},
+		{
+			name:     "missing key",
+			input:    "site: datadoghq.com",
+			expected: "site: datadoghq.com\napi_key: newkey\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := setAPIKeyConfig([]byte(tt.input), "newkey")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(res))
+		})
+	}
+
+	_, err := setAPIKeyConfig(nil, "newkey")
+	assert.Error(t, err)
+}
+
+func TestSetAPIKeyEnvironment(t *testing.T) {
+	assert.Equal(t, "DD_SITE=datadoghq.com\nDD_API_KEY=newkey\n", string(setAPIKeyEnvironment([]byte("DD_SITE=datadoghq.com\nDD_API_KEY=oldkey\n"), "newkey")))
+	assert.Equal(t, "DD_SITE=datadoghq.com\n", string(setAPIKeyEnvironment([]byte("DD_SITE=datadoghq.com\n"), "newkey")))
+	assert.Empty(t, setAPIKeyEnvironment(nil, "newkey"))
+}
@@ -0,0 +1,194 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultTraceAgentPort  = 8126
+	traceAgentCheckTimeout = 5 * time.Second
+)
+
+// traceAgentConfig is the subset of the agent configuration setting the trace agent receivers
+type traceAgentConfig struct {
+	ApmConfig struct {
+		ReceiverPort *int `yaml:"receiver_port"`
+	} `yaml:"apm_config"`
+}
+
+// traceAgentEndpoints are the endpoints of the trace agent the injected processes send their traces to
+type traceAgentEndpoints struct {
+	socket string
+	// port is 0 if the TCP receiver of the trace agent is disabled
+	port int
+}
+
+// traceAgentConnectivity is the result of the connectivity check of the trace agent endpoints
+type traceAgentConnectivity struct {
+	endpoints traceAgentEndpoints
+	socketErr error
+	portErr   error
+}
+
+// reachable returns whether the injected processes can send their traces to the trace agent
+func (c traceAgentConnectivity) reachable() bool {
+	return c.socketErr == nil || (c.endpoints.port != 0 && c.portErr == nil)
+}
+
+// verifyTraceAgentConnectivity checks that the trace agent is reachable from the injected processes,
+// as "instrumented but no traces" is usually a connectivity issue. It doesn't fail the instrumentation
+// as the agent may be started after the injector, the result is reported with the instrumentation.
+func (a *apmInjectorInstaller) verifyTraceAgentConnectivity(ctx context.Context) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "verify_trace_agent_connectivity")
+	defer span.Finish()
+
+	envFile, err := os.ReadFile(envFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to read %s, skipping trace agent connectivity verification: %v", envFilePath, err)
+		span.SetTag("error", err)
+		return
+	}
+	endpoints, err := resolveTraceAgentEndpoints(envFile)
+	if err != nil {
+		log.Warnf("failed to resolve the trace agent endpoints, skipping trace agent connectivity verification: %v", err)
+		span.SetTag("error", err)
+		return
+	}
+	c := checkTraceAgentConnectivity(ctx, endpoints)
+
+	span.SetTag("socket_path.apm", endpoints.socket)
+	span.SetTag("port.apm", endpoints.port)
+	span.SetTag("reachable", c.reachable())
+	if c.socketErr != nil {
+		span.SetTag("socket_error", c.socketErr.Error())
+	}
+	if c.portErr != nil {
+		span.SetTag("port_error", c.portErr.Error())
+	}
+	if !c.reachable() {
+		log.Warnf("the trace agent isn't reachable by the instrumented processes, their traces will be dropped until it is: socket %s: %v, port %d: %v", endpoints.socket, c.socketErr, endpoints.port, c.portErr)
+	}
+}
+
+// resolveTraceAgentEndpoints resolves the trace agent endpoints the way the injected processes do,
+// from the environment file shared with the injector and then from the agent configuration
+func resolveTraceAgentEndpoints(envFile []byte) (traceAgentEndpoints, error) {
+	envs := parseEnvFile(envFile)
+	endpoints := traceAgentEndpoints{
+		socket: envs["DD_APM_RECEIVER_SOCKET"],
+		port:   defaultTraceAgentPort,
+	}
+	if endpoints.socket == "" {
+		apmSocket, _, err := getSocketsPath()
+		if err != nil {
+			return endpoints, err
+		}
+		endpoints.socket = apmSocket
+	}
+
+	if rawPort, ok := envs["DD_TRACE_AGENT_PORT"]; ok {
+		port, err := strconv.Atoi(rawPort)
+		if err != nil {
+			return endpoints, fmt.Errorf("invalid DD_TRACE_AGENT_PORT %q: %w", rawPort, err)
+		}
+		endpoints.port = port
+		return endpoints, nil
+	}
+	rawCfg, err := os.ReadFile(agentConfigPath)
+	if err != nil && os.IsNotExist(err) {
+		return endpoints, nil
+	} else if err != nil {
+		return endpoints, fmt.Errorf("error reading agent configuration file: %w", err)
+	}
+	var cfg traceAgentConfig
+	if err := yaml.Unmarshal(rawCfg, &cfg); err != nil {
+		log.Warn("Failed to unmarshal agent configuration, using default trace agent port")
+		return endpoints, nil
+	}
+	if cfg.ApmConfig.ReceiverPort != nil {
+		endpoints.port = *cfg.ApmConfig.ReceiverPort
+	}
+	return endpoints, nil
+}
+
+// parseEnvFile returns the environment variables set by an environment file
+func parseEnvFile(envFile []byte) map[string]string {
+	envs := map[string]string{}
+	for _, line := range strings.Split(string(envFile), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		envs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return envs
+}
+
+// checkTraceAgentConnectivity checks that the trace agent answers on its socket and on its port
+func checkTraceAgentConnectivity(ctx context.Context, endpoints traceAgentEndpoints) traceAgentConnectivity {
+	c := traceAgentConnectivity{endpoints: endpoints}
+
+	if _, err := os.Stat(endpoints.socket); err != nil {
+		c.socketErr = fmt.Errorf("socket not found: %w", err)
+	} else {
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", endpoints.socket)
+				},
+				DisableKeepAlives: true,
+			},
+		}
+		c.socketErr = checkTraceAgentInfo(ctx, client, "http://unix/info")
+	}
+
+	if endpoints.port == 0 {
+		c.portErr = fmt.Errorf("trace agent TCP receiver is disabled")
+	} else {
+		client := &http.Client{
+			Transport: &http.Transport{DisableKeepAlives: true},
+		}
+		c.portErr = checkTraceAgentInfo(ctx, client, fmt.Sprintf("http://localhost:%d/info", endpoints.port))
+	}
+	return c
+}
+
+// checkTraceAgentInfo checks that the info endpoint of the trace agent answers
+func checkTraceAgentInfo(ctx context.Context, client *http.Client, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, traceAgentCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("trace agent answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTraceAgentEndpoints(t *testing.T) {
+	tempDir := t.TempDir()
+	agentConfigPath = filepath.Join(tempDir, "datadog.yaml")
+
+	testCases := []struct {
+		name              string
+		envFile           string
+		agentConfig       string
+		expectedEndpoints traceAgentEndpoints
+	}{
+		{
+			name:              "defaults",
+			expectedEndpoints: traceAgentEndpoints{socket: apmInstallerSocket, port: defaultTraceAgentPort},
+		},
+		{
+			name:              "environment file",
+			envFile:           "DD_APM_RECEIVER_SOCKET=/banana/apm.socket\nDD_TRACE_AGENT_PORT=8127\n",
+			agentConfig:       "apm_config:\n  receiver_socket: /apple/apm.socket\n  receiver_port: 8128\n",
+			expectedEndpoints: traceAgentEndpoints{socket: "/banana/apm.socket", port: 8127},
+		},
+		{
+			name:              "agent config",
+			agentConfig:       "apm_config:\n  receiver_socket: /apple/apm.socket\n  receiver_port: 8128\n",
+			expectedEndpoints: traceAgentEndpoints{socket: "/apple/apm.socket", port: 8128},
+		},
+		{
+			name:              "TCP receiver disabled",
+			envFile:           "DD_APM_RECEIVER_SOCKET=/banana/apm.socket\n",
+			agentConfig:       "apm_config:\n  receiver_port: 0\n",
+			expectedEndpoints: traceAgentEndpoints{socket: "/banana/apm.socket", port: 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(agentConfigPath)
+			if tc.agentConfig != "" {
+				os.WriteFile(agentConfigPath, []byte(tc.agentConfig), 0644)
+			}
+
+			endpoints, err := resolveTraceAgentEndpoints([]byte(tc.envFile))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedEndpoints, endpoints)
+		})
+	}
+
+	_, err := resolveTraceAgentEndpoints([]byte("DD_TRACE_AGENT_PORT=banana\n"))
+	assert.Error(t, err)
+}
+
+func newTestTraceAgent(t *testing.T, listener net.Listener) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	s.Listener.Close()
+	s.Listener = listener
+	s.Start()
+	t.Cleanup(s.Close)
+}
+
+func TestCheckTraceAgentConnectivity(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "apm.socket")
+	socketListener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	newTestTraceAgent(t, socketListener)
+	portListener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	newTestTraceAgent(t, portListener)
+	port := portListener.Addr().(*net.TCPAddr).Port
+
+	c := checkTraceAgentConnectivity(context.Background(), traceAgentEndpoints{socket: socket, port: port})
+	assert.NoError(t, c.socketErr)
+	assert.NoError(t, c.portErr)
+	assert.True(t, c.reachable())
+
+	// only the TCP receiver is reachable
+	c = checkTraceAgentConnectivity(context.Background(), traceAgentEndpoints{socket: socket + ".missing", port: port})
+	assert.ErrorContains(t, c.socketErr, "socket not found")
+	assert.NoError(t, c.portErr)
+	assert.True(t, c.reachable())
+
+	// only the socket is reachable, the TCP receiver is disabled
+	c = checkTraceAgentConnectivity(context.Background(), traceAgentEndpoints{socket: socket})
+	assert.NoError(t, c.socketErr)
+	assert.Error(t, c.portErr)
+	assert.True(t, c.reachable())
+
+	// the trace agent isn't running
+	require.NoError(t, portListener.Close())
+	c = checkTraceAgentConnectivity(context.Background(), traceAgentEndpoints{socket: socket + ".missing", port: port})
+	assert.Error(t, c.socketErr)
+	assert.Error(t, c.portErr)
+	assert.False(t, c.reachable())
+}
@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build !windows
+//go:build !windows && !darwin
 
 // Package service provides a way to interact with os services
 package service
@@ -69,6 +69,18 @@ func UninstrumentAPMInjector(ctx context.Context, method string) (err error) {
 	return installer.Uninstrument(ctx)
 }
 
+// DiffAPMInjectorDocker returns the modification (un)instrumenting docker would make to the
+// docker daemon configuration, without writing it
+func DiffAPMInjectorDocker(ctx context.Context, uninstrument bool) (diff FileDiff, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "diff_injector_docker")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	installer := newAPMInjectorInstaller(injectorPath)
+	if uninstrument {
+		return installer.dockerConfigUninstrument.diff(ctx)
+	}
+	return installer.dockerConfigInstrument.diff(ctx)
+}
+
 func newAPMInjectorInstaller(path string) *apmInjectorInstaller {
 	a := &apmInjectorInstaller{
 		installPath: path,
@@ -210,6 +222,7 @@ func (a *apmInjectorInstaller) Instrument(ctx context.Context) (retErr error) {
 		}
 	}
 
+	a.verifyTraceAgentConnectivity(ctx)
 	return nil
 }
 
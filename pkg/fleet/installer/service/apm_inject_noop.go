@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build windows
+//go:build windows || darwin
 
 // Package service provides a way to interact with os services
 package service
@@ -23,3 +23,6 @@ func InstrumentAPMInjector(_ context.Context, _ string) error { return nil }
 
 // UninstrumentAPMInjector noop
 func UninstrumentAPMInjector(_ context.Context, _ string) error { return nil }
+
+// DiffAPMInjectorDocker noop
+func DiffAPMInjectorDocker(_ context.Context, _ bool) (FileDiff, error) { return FileDiff{}, nil }
@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build !windows
+//go:build !windows && !darwin
 
 // Package service provides a way to interact with os services
 package service
@@ -202,3 +202,13 @@ func StopAgentExperiment(ctx context.Context) error {
 func PromoteAgentExperiment(ctx context.Context) error {
 	return StopAgentExperiment(ctx)
 }
+
+// RollbackAgent restarts the stable agent units once the stable link points to the previous package
+func RollbackAgent(ctx context.Context) error {
+	for _, unit := range stableUnits {
+		if err := tryRestartUnit(ctx, unit); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", unit, err)
+		}
+	}
+	return nil
+}
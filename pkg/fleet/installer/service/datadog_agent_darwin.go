@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build darwin
+
+// Package service provides a way to interact with os services
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	agentLabel    = "com.datadoghq.agent"
+	agentExpLabel = "com.datadoghq.agent-exp"
+	agentSymlink  = "/usr/local/bin/datadog-agent"
+)
+
+// SetupAgent installs and starts the agent
+func SetupAgent(ctx context.Context, _ []string) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "setup_agent")
+	defer func() {
+		if err != nil {
+			log.Errorf("Failed to setup agent: %s, reverting", err)
+			err = errors.Join(err, RemoveAgent(ctx))
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+
+	for _, label := range []string{agentLabel, agentExpLabel} {
+		if err = loadDaemon(ctx, label); err != nil {
+			return fmt.Errorf("failed to load %s: %v", label, err)
+		}
+	}
+	if err = os.MkdirAll("/etc/datadog-agent", 0755); err != nil {
+		return fmt.Errorf("failed to create /etc/datadog-agent: %v", err)
+	}
+	if err = os.MkdirAll("/var/log/datadog", 0755); err != nil {
+		return fmt.Errorf("failed to create /var/log/datadog: %v", err)
+	}
+	err = os.Symlink("/opt/datadog-packages/datadog-agent/stable/bin/agent/agent", agentSymlink)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("failed to create symlink: %v", err)
+	}
+
+	_, err = os.Stat("/etc/datadog-agent/datadog.yaml")
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check if /etc/datadog-agent/datadog.yaml exists: %v", err)
+	}
+	// this is expected during a fresh install with the install script / MDM profile / etc...
+	// the config is populated afterwards by the install method and the agent is restarted
+	if !os.IsNotExist(err) {
+		if err = restartDaemon(ctx, agentLabel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveAgent stops and removes the agent
+func RemoveAgent(ctx context.Context) error {
+	span, ctx := tracer.StartSpanFromContext(ctx, "remove_agent_units")
+	defer span.Finish()
+	// stop the experiment first, it could otherwise restart the stable agent
+	for _, label := range []string{agentExpLabel, agentLabel} {
+		if err := stopDaemon(ctx, label); err != nil {
+			log.Warnf("Failed to stop %s: %s", label, err)
+		}
+		if err := removeDaemon(ctx, label); err != nil {
+			log.Warnf("Failed to remove %s: %s", label, err)
+		}
+	}
+	if err := os.Remove(agentSymlink); err != nil {
+		log.Warnf("Failed to remove agent symlink: %s", err)
+	}
+	return nil
+}
+
+// StartAgentExperiment starts the agent experiment
+//
+// launchd has no equivalent of the systemd Conflicts= directive so the
+// stable agent is stopped explicitly before the experiment is started. The
+// experiment daemon starts the stable agent again once it exits.
+func StartAgentExperiment(ctx context.Context) error {
+	if err := stopDaemon(ctx, agentLabel); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", agentLabel, err)
+	}
+	return restartDaemon(ctx, agentExpLabel)
+}
+
+// StopAgentExperiment stops the agent experiment
+func StopAgentExperiment(ctx context.Context) error {
+	if err := stopDaemon(ctx, agentExpLabel); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", agentExpLabel, err)
+	}
+	return restartDaemon(ctx, agentLabel)
+}
+
+// PromoteAgentExperiment promotes the agent experiment
+func PromoteAgentExperiment(ctx context.Context) error {
+	return StopAgentExperiment(ctx)
+}
+
+// RollbackAgent restarts the stable agent once the stable link points to the previous package
+func RollbackAgent(ctx context.Context) error {
+	return restartDaemon(ctx, agentLabel)
+}
@@ -93,6 +93,19 @@ func PromoteAgentExperiment(_ context.Context) error {
 	return nil
 }
 
+// RollbackAgent installs the previous agent package once the stable link points to it
+func RollbackAgent(ctx context.Context) (err error) {
+	span, _ := tracer.StartSpanFromContext(ctx, "rollback_agent")
+	defer func() {
+		if err != nil {
+			log.Errorf("Failed to rollback agent: %s", err)
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+	// TODO: Need args here to restore DDAGENTUSER
+	return msiexec("stable", "/i", nil)
+}
+
 // RemoveAgent stops and removes the agent
 func RemoveAgent(ctx context.Context) (err error) {
 	span, _ := tracer.StartSpanFromContext(ctx, "remove_agent")
@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build !windows
+//go:build !windows && !darwin
 
 package service
 
@@ -22,6 +22,7 @@ import (
 const (
 	installerUnit    = "datadog-installer.service"
 	installerUnitExp = "datadog-installer-exp.service"
+	installerSymlink = "/usr/bin/datadog-installer"
 )
 
 var installerUnits = []string{installerUnit, installerUnitExp}
@@ -108,7 +109,7 @@ func SetupInstaller(ctx context.Context) (err error) {
 	}
 
 	// Create installer path symlink
-	err = os.Symlink("/opt/datadog-packages/datadog-installer/stable/bin/installer/installer", "/usr/bin/datadog-installer")
+	err = os.Symlink("/opt/datadog-packages/datadog-installer/stable/bin/installer/installer", installerSymlink)
 	if err != nil && errors.Is(err, os.ErrExist) {
 		log.Info("Installer symlink already exists, skipping")
 	} else if err != nil {
@@ -208,7 +209,7 @@ func RemoveInstaller(ctx context.Context) error {
 	}
 
 	// Remove symlink
-	if err := os.Remove("/usr/bin/datadog-installer"); err != nil {
+	if err := os.Remove(installerSymlink); err != nil {
 		log.Warnf("Failed to remove /usr/bin/datadog-installer: %s", err)
 	}
 
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build darwin
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	installerLabel    = "com.datadoghq.installer"
+	installerExpLabel = "com.datadoghq.installer-exp"
+	installerSymlink  = "/usr/local/bin/datadog-installer"
+)
+
+// SetupInstaller installs and starts the installer launchd daemons
+func SetupInstaller(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			log.Errorf("Failed to setup installer: %s, reverting", err)
+			err = RemoveInstaller(ctx)
+		}
+	}()
+
+	for _, dir := range []string{"/etc/datadog-agent", "/var/log/datadog", "/var/run/datadog-installer", "/opt/datadog-installer/tmp"} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating %s: %w", dir, err)
+		}
+	}
+	err = os.MkdirAll("/var/run/datadog-installer/locks", 0777)
+	if err != nil {
+		return fmt.Errorf("error creating /var/run/datadog-installer/locks: %w", err)
+	}
+	// Locks directory can already be created by a package install
+	err = os.Chmod("/var/run/datadog-installer/locks", 0777)
+	if err != nil {
+		return fmt.Errorf("error changing permissions of /var/run/datadog-installer/locks: %w", err)
+	}
+
+	err = os.Symlink("/opt/datadog-packages/datadog-installer/stable/bin/installer/installer", installerSymlink)
+	if err != nil && errors.Is(err, os.ErrExist) {
+		log.Info("Installer symlink already exists, skipping")
+	} else if err != nil {
+		return fmt.Errorf("error creating symlink to %s: %w", installerSymlink, err)
+	}
+
+	if os.Getenv("DD_REMOTE_UPDATES") != "true" {
+		return nil
+	}
+	for _, label := range []string{installerLabel, installerExpLabel} {
+		if err = loadDaemon(ctx, label); err != nil {
+			return err
+		}
+	}
+	_, err = os.Stat("/etc/datadog-agent/datadog.yaml")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// this is expected during a fresh install, the config is populated afterwards
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return restartDaemon(ctx, installerLabel)
+}
+
+// RemoveInstaller removes the installer launchd daemons
+func RemoveInstaller(ctx context.Context) error {
+	for _, label := range []string{installerExpLabel, installerLabel} {
+		if err := stopDaemon(ctx, label); err != nil {
+			log.Warnf("Failed to stop %s: %s", label, err)
+		}
+		if err := removeDaemon(ctx, label); err != nil {
+			log.Warnf("Failed to remove %s: %s", label, err)
+		}
+	}
+	if err := os.Remove(installerSymlink); err != nil {
+		log.Warnf("Failed to remove %s: %s", installerSymlink, err)
+	}
+	return nil
+}
+
+// StartInstallerExperiment starts the experiment installer launchd daemon
+//
+// This is called from a process spawned by the stable installer daemon: stopping the
+// stable daemon kills its whole process tree, so it must be the last operation.
+func StartInstallerExperiment(ctx context.Context) error {
+	if err := restartDaemon(ctx, installerExpLabel); err != nil {
+		return fmt.Errorf("failed to start %s: %w", installerExpLabel, err)
+	}
+	return stopDaemon(ctx, installerLabel)
+}
+
+// StopInstallerExperiment restarts the stable installer launchd daemon
+//
+// This is called from a process spawned by the experiment installer daemon, see
+// StartInstallerExperiment for the ordering constraints.
+func StopInstallerExperiment(ctx context.Context) error {
+	if err := restartDaemon(ctx, installerLabel); err != nil {
+		return fmt.Errorf("failed to start %s: %w", installerLabel, err)
+	}
+	return stopDaemon(ctx, installerExpLabel)
+}
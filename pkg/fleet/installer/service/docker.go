@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build !windows
+//go:build !windows && !darwin
 
 // Package service provides a way to interact with os services
 package service
@@ -176,12 +176,9 @@ func reloadDockerConfig(ctx context.Context) (err error) {
 		log.Warn("docker is inactive, skipping docker reload")
 		return nil
 	}
-	cmd := exec.Command("systemctl", "reload", "docker")
-	bufErr := new(bytes.Buffer)
-	cmd.Stderr = bufErr
-	err = cmd.Run()
+	_, err = systemctl(ctx, "reload", "docker")
 	if err != nil {
-		return fmt.Errorf("failed to reload docker (%s): %s", err.Error(), bufErr.String())
+		return fmt.Errorf("failed to reload docker: %w", err)
 	}
 	return nil
 }
@@ -208,16 +205,10 @@ func isDockerInstalled(ctx context.Context) bool {
 
 // isDockerActive checks if docker is active on the system
 func isDockerActive(ctx context.Context) bool {
-	cmd := exec.CommandContext(ctx, "systemctl", "is-active", "docker")
-	var outb bytes.Buffer
-	cmd.Stdout = &outb
-	err := cmd.Run()
+	state, err := unitActiveState(ctx, "docker")
 	if err != nil {
 		log.Warn("installer: failed to check if docker is active, assuming it isn't: ", err)
 		return false
 	}
-	if strings.TrimSpace(outb.String()) == "active" {
-		return true
-	}
-	return false
+	return state == "active"
 }
@@ -121,6 +121,24 @@ func (ft *fileMutator) mutate(ctx context.Context) (rollback func() error, err e
 	return rollback, nil
 }
 
+// diff returns the current content of the file and the content it would have once
+// mutated, without modifying anything on disk
+func (ft *fileMutator) diff(ctx context.Context) (FileDiff, error) {
+	data, err := os.ReadFile(ft.path)
+	if err != nil && !os.IsNotExist(err) {
+		return FileDiff{}, fmt.Errorf("could not read file %s: %s", ft.path, err)
+	}
+	res, err := ft.transformContent(ctx, data)
+	if err != nil {
+		return FileDiff{}, fmt.Errorf("could not transform file %s: %s", ft.path, err)
+	}
+	return FileDiff{
+		Path:   ft.path,
+		Before: string(data),
+		After:  string(res),
+	}, nil
+}
+
 func writeFile(path string, data []byte) error {
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
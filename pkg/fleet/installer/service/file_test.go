@@ -144,3 +144,32 @@ func TestCleanup(t *testing.T) {
 	assert.NoFileExists(t, mutator.pathTmp)
 	assert.NoFileExists(t, mutator.pathBackup)
 }
+
+func TestDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalPath := tmpDir + "/original.txt"
+	mode := fs.FileMode(0744)
+	require.Nil(t, os.WriteFile(originalPath, []byte(originalContent), mode))
+	mutator := newFileMutator(originalPath, transformFunc, nil, nil)
+
+	diff, err := mutator.diff(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, FileDiff{Path: originalPath, Before: originalContent, After: transformedContent}, diff)
+	assert.True(t, diff.HasChanges())
+
+	// The file is left untouched
+	assertFile(t, originalPath, originalContent, mode)
+	assert.NoFileExists(t, mutator.pathTmp)
+	assert.NoFileExists(t, mutator.pathBackup)
+}
+
+func TestDiff_No_original(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalPath := tmpDir + "/original.txt"
+	mutator := newFileMutator(originalPath, transformFunc, nil, nil)
+
+	diff, err := mutator.diff(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, FileDiff{Path: originalPath, Before: "", After: transformedContent}, diff)
+	assert.NoFileExists(t, originalPath)
+}
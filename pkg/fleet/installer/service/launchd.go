@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build darwin
+
+// Package service provides a way to interact with os services
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service/embedded"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const launchdDomain = "system"
+
+var (
+	// launchdPath is the directory of the daemons loaded by launchd at boot, it's overridden in tests
+	launchdPath = "/Library/LaunchDaemons"
+	// launchctl runs launchctl with the given arguments, it's overridden in tests
+	launchctl = func(ctx context.Context, args ...string) error {
+		return exec.CommandContext(ctx, "launchctl", args...).Run()
+	}
+)
+
+// launchdServiceNotFoundExitCode is the exit code returned by launchctl
+// when the targeted service is not loaded in the domain.
+const launchdServiceNotFoundExitCode = 113
+
+func plistPath(label string) string {
+	return filepath.Join(launchdPath, label+".plist")
+}
+
+// loadDaemon writes the embedded plist of the given label to the LaunchDaemons directory.
+func loadDaemon(ctx context.Context, label string) error {
+	span, _ := tracer.StartSpanFromContext(ctx, "load_daemon")
+	defer span.Finish()
+	span.SetTag("label", label)
+	content, err := embedded.FS.ReadFile(label + ".plist")
+	if err != nil {
+		return fmt.Errorf("error reading embedded plist %s: %w", label, err)
+	}
+	return os.WriteFile(plistPath(label), content, 0644)
+}
+
+// removeDaemon removes the plist of the given label from the LaunchDaemons directory.
+func removeDaemon(ctx context.Context, label string) error {
+	span, _ := tracer.StartSpanFromContext(ctx, "remove_daemon")
+	defer span.Finish()
+	span.SetTag("label", label)
+	err := os.Remove(plistPath(label))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// startDaemon bootstraps the daemon in the system domain and starts it, the experiments
+// don't set RunAtLoad so that they aren't started at boot.
+func startDaemon(ctx context.Context, label string) error {
+	span, _ := tracer.StartSpanFromContext(ctx, "start_daemon")
+	defer span.Finish()
+	span.SetTag("label", label)
+	if err := launchctl(ctx, "bootstrap", launchdDomain, plistPath(label)); err != nil {
+		return fmt.Errorf("failed to bootstrap %s: %w", label, err)
+	}
+	return launchctl(ctx, "kickstart", launchdDomain+"/"+label)
+}
+
+// stopDaemon removes the daemon from the system domain, stopping it if it is running.
+// Stopping a daemon that is not loaded is not an error.
+func stopDaemon(ctx context.Context, label string) error {
+	span, _ := tracer.StartSpanFromContext(ctx, "stop_daemon")
+	defer span.Finish()
+	span.SetTag("label", label)
+	err := launchctl(ctx, "bootout", launchdDomain+"/"+label)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == launchdServiceNotFoundExitCode {
+		return nil
+	}
+	return err
+}
+
+// restartDaemon stops the daemon if it is running and bootstraps it again.
+func restartDaemon(ctx context.Context, label string) error {
+	if err := stopDaemon(ctx, label); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", label, err)
+	}
+	return startDaemon(ctx, label)
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service/embedded"
+)
+
+var runAtLoadRegexp = regexp.MustCompile(`<key>RunAtLoad</key>\s*<(true|false)/>`)
+
+func TestLaunchdPlists(t *testing.T) {
+	tests := []struct {
+		label     string
+		runAtLoad string
+		// onExit is the stable daemon started again once the experiment exits
+		onExit string
+	}{
+		{label: "com.datadoghq.agent", runAtLoad: "true"},
+		{label: "com.datadoghq.installer", runAtLoad: "true"},
+		{label: "com.datadoghq.agent-exp", runAtLoad: "false", onExit: "com.datadoghq.agent"},
+		{label: "com.datadoghq.installer-exp", runAtLoad: "false", onExit: "com.datadoghq.installer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			content, err := embedded.FS.ReadFile(tt.label + ".plist")
+			require.NoError(t, err)
+			assert.Contains(t, string(content), "<string>"+tt.label+"</string>")
+
+			// experiments must not be started at boot, the stable daemons are
+			runAtLoad := runAtLoadRegexp.FindStringSubmatch(string(content))
+			require.NotNil(t, runAtLoad)
+			assert.Equal(t, tt.runAtLoad, runAtLoad[1])
+
+			if tt.onExit != "" {
+				assert.Contains(t, string(content), "; /bin/launchctl bootstrap system /Library/LaunchDaemons/"+tt.onExit+".plist</string>")
+			}
+		})
+	}
+}
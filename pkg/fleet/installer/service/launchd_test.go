@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build darwin
+
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service/embedded"
+)
+
+// setTestLaunchd records the launchctl invocations instead of running them
+func setTestLaunchd(t *testing.T) *[]string {
+	previousLaunchdPath, previousLaunchctl := launchdPath, launchctl
+	var calls []string
+	launchdPath = t.TempDir()
+	launchctl = func(_ context.Context, args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}
+	t.Cleanup(func() { launchdPath, launchctl = previousLaunchdPath, previousLaunchctl })
+	return &calls
+}
+
+func TestLoadDaemon(t *testing.T) {
+	setTestLaunchd(t)
+
+	require.NoError(t, loadDaemon(context.Background(), agentExpLabel))
+	content, err := os.ReadFile(filepath.Join(launchdPath, agentExpLabel+".plist"))
+	require.NoError(t, err)
+	expected, err := embedded.FS.ReadFile(agentExpLabel + ".plist")
+	require.NoError(t, err)
+	assert.Equal(t, expected, content)
+
+	require.NoError(t, removeDaemon(context.Background(), agentExpLabel))
+	assert.NoFileExists(t, filepath.Join(launchdPath, agentExpLabel+".plist"))
+	// removing a daemon that isn't loaded is not an error
+	assert.NoError(t, removeDaemon(context.Background(), agentExpLabel))
+}
+
+func TestStartAgentExperiment(t *testing.T) {
+	calls := setTestLaunchd(t)
+
+	require.NoError(t, StartAgentExperiment(context.Background()))
+	assert.Equal(t, []string{
+		"bootout system/" + agentLabel,
+		"bootout system/" + agentExpLabel,
+		"bootstrap system " + filepath.Join(launchdPath, agentExpLabel+".plist"),
+		"kickstart system/" + agentExpLabel,
+	}, *calls)
+}
+
+func TestStopAgentExperiment(t *testing.T) {
+	calls := setTestLaunchd(t)
+
+	require.NoError(t, StopAgentExperiment(context.Background()))
+	assert.Equal(t, []string{
+		"bootout system/" + agentExpLabel,
+		"bootout system/" + agentLabel,
+		"bootstrap system " + filepath.Join(launchdPath, agentLabel+".plist"),
+		"kickstart system/" + agentLabel,
+	}, *calls)
+}
+
+func TestStartInstallerExperiment(t *testing.T) {
+	calls := setTestLaunchd(t)
+
+	require.NoError(t, StartInstallerExperiment(context.Background()))
+	// the stable installer is stopped last as it is the parent of the current process
+	assert.Equal(t, []string{
+		"bootout system/" + installerExpLabel,
+		"bootstrap system " + filepath.Join(launchdPath, installerExpLabel+".plist"),
+		"kickstart system/" + installerExpLabel,
+		"bootout system/" + installerLabel,
+	}, *calls)
+}
+
+func TestStopDaemonNotLoaded(t *testing.T) {
+	setTestLaunchd(t)
+	exitCode := launchdServiceNotFoundExitCode
+	launchctl = func(ctx context.Context, _ ...string) error {
+		return exec.CommandContext(ctx, "sh", "-c", "exit "+strconv.Itoa(exitCode)).Run()
+	}
+
+	assert.NoError(t, stopDaemon(context.Background(), agentExpLabel))
+	exitCode = 1
+	assert.Error(t, stopDaemon(context.Background(), agentExpLabel))
+}
@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// envInstallKernelHeaders allows the installer to install the missing kernel headers with the
+// package manager of the host
+const envInstallKernelHeaders = "DD_INSTALLER_INSTALL_KERNEL_HEADERS"
+
+// kernelPrerequisite is a kernel artifact a package needs on the host.
+// Paths and package names are formatted with the kernel release.
+type kernelPrerequisite struct {
+	name string
+	// requiredIf is a path that must exist for the prerequisite to be required, e.g. the
+	// configuration of the feature relying on it
+	requiredIf string
+	// paths are alternatives, the prerequisite is satisfied if any of them exists
+	paths []string
+	// packages are the packages providing the prerequisite, by distribution family. They are only
+	// installed when opted in with DD_INSTALLER_INSTALL_KERNEL_HEADERS, suggested to the user otherwise.
+	packages map[string]string
+}
+
+// systemProbePrerequisites are the kernel artifacts needed by the eBPF based features of
+// system-probe (NPM, USM, CWS...): either BTF or the headers to compile against.
+// system-probe is only started when its configuration exists.
+var systemProbePrerequisites = []kernelPrerequisite{
+	{
+		name:       "kernel BTF or headers",
+		requiredIf: "/etc/datadog-agent/system-probe.yaml",
+		paths:      []string{"/sys/kernel/btf/vmlinux", "/lib/modules/%s/build"},
+		packages: map[string]string{
+			"debian": "linux-headers-%s",
+			"rhel":   "kernel-devel-%s",
+			"suse":   "kernel-default-devel",
+		},
+	},
+}
+
+// packagesPrerequisites are the kernel prerequisites of the packages having some
+var packagesPrerequisites = map[string][]kernelPrerequisite{
+	"datadog-agent": systemProbePrerequisites,
+}
+
+// prerequisitesChecker checks prerequisites against the host it runs on
+type prerequisitesChecker struct {
+	// root is prepended to every path checked, it is only overridden in tests
+	root          string
+	kernelRelease string
+	distribution  string
+	install       bool
+	installFunc   func(ctx context.Context, distribution string, pkgs []string) error
+}
+
+func newPrerequisitesChecker() (*prerequisitesChecker, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return nil, fmt.Errorf("could not get kernel release: %w", err)
+	}
+	osRelease, err := os.ReadFile("/etc/os-release")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read os-release: %w", err)
+	}
+	return &prerequisitesChecker{
+		root:          "/",
+		kernelRelease: unix.ByteSliceToString(uname.Release[:]),
+		distribution:  distributionFamily(osRelease),
+		install:       os.Getenv(envInstallKernelHeaders) == "true",
+		installFunc:   installSystemPackages,
+	}, nil
+}
+
+// CheckPrerequisites checks the kernel prerequisites of a package, installing the missing ones if
+// allowed. The prerequisites that are still unsatisfied are reported as an installer error wrapping
+// a PrerequisitesError, along with the packages of the distribution providing them.
+func CheckPrerequisites(ctx context.Context, pkg string) (err error) {
+	prerequisites, ok := packagesPrerequisites[pkg]
+	if !ok {
+		return nil
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "check_prerequisites")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("package", pkg)
+
+	c, err := newPrerequisitesChecker()
+	if err != nil {
+		return err
+	}
+	span.SetTag("kernel_release", c.kernelRelease)
+	span.SetTag("distribution", c.distribution)
+	return c.check(ctx, pkg, prerequisites)
+}
+
+func (c *prerequisitesChecker) check(ctx context.Context, pkg string, prerequisites []kernelPrerequisite) error {
+	var missing []kernelPrerequisite
+	for _, p := range prerequisites {
+		if c.required(p) && !c.satisfied(p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var pkgs []string
+	for _, p := range missing {
+		if name, ok := p.packages[c.distribution]; ok {
+			pkgs = append(pkgs, c.format(name))
+		}
+	}
+	if c.install && len(pkgs) > 0 {
+		log.Infof("Installing missing prerequisites of %s: %s", pkg, strings.Join(pkgs, ", "))
+		if err := c.installFunc(ctx, c.distribution, pkgs); err != nil {
+			log.Warnf("Failed to install prerequisites of %s: %v", pkg, err)
+		}
+		missing = slices.DeleteFunc(missing, c.satisfied)
+		if len(missing) == 0 {
+			return nil
+		}
+	}
+
+	prerequisitesErr := &PrerequisitesError{Package: pkg}
+	for _, p := range missing {
+		unsatisfied := p.name
+		if name, ok := p.packages[c.distribution]; ok {
+			unsatisfied = fmt.Sprintf("%s (provided by %s)", p.name, c.format(name))
+		}
+		prerequisitesErr.Unsatisfied = append(prerequisitesErr.Unsatisfied, unsatisfied)
+	}
+	return installerErrors.Wrap(installerErrors.ErrPrerequisitesNotMet, prerequisitesErr)
+}
+
+func (c *prerequisitesChecker) required(p kernelPrerequisite) bool {
+	if p.requiredIf == "" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(c.root, p.requiredIf))
+	return err == nil
+}
+
+func (c *prerequisitesChecker) satisfied(p kernelPrerequisite) bool {
+	for _, path := range p.paths {
+		if _, err := os.Stat(filepath.Join(c.root, c.format(path))); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *prerequisitesChecker) format(s string) string {
+	if !strings.Contains(s, "%s") {
+		return s
+	}
+	return fmt.Sprintf(s, c.kernelRelease)
+}
+
+// distributionFamily returns the family of the distribution described by an os-release file:
+// debian, rhel or suse. It returns an empty string for other distributions.
+func distributionFamily(osRelease []byte) string {
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(osRelease))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || (key != "ID" && key != "ID_LIKE") {
+			continue
+		}
+		ids = append(ids, strings.Fields(strings.Trim(value, `"'`))...)
+	}
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return "debian"
+		case "rhel", "centos", "fedora", "amzn":
+			return "rhel"
+		case "suse", "sles", "opensuse":
+			return "suse"
+		}
+	}
+	return ""
+}
+
+func installSystemPackages(ctx context.Context, distribution string, pkgs []string) error {
+	var cmd *exec.Cmd
+	switch distribution {
+	case "debian":
+		cmd = exec.CommandContext(ctx, "apt-get", append([]string{"install", "-y", "--no-install-recommends"}, pkgs...)...)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	case "rhel":
+		packageManager := "yum"
+		if _, err := exec.LookPath("dnf"); err == nil {
+			packageManager = "dnf"
+		}
+		cmd = exec.CommandContext(ctx, packageManager, append([]string{"install", "-y"}, pkgs...)...)
+	case "suse":
+		cmd = exec.CommandContext(ctx, "zypper", append([]string{"--non-interactive", "install"}, pkgs...)...)
+	default:
+		return fmt.Errorf("unsupported distribution")
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Path, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrerequisitesError is returned when some prerequisites of a package aren't satisfied on the host
+type PrerequisitesError struct {
+	Package     string
+	Unsatisfied []string
+}
+
+// Error returns the error message.
+func (e *PrerequisitesError) Error() string {
+	return fmt.Sprintf("unsatisfied prerequisites for %s: %s", e.Package, strings.Join(e.Unsatisfied, ", "))
+}
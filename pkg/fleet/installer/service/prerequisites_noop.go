@@ -0,0 +1,15 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows || darwin
+
+package service
+
+import "context"
+
+// CheckPrerequisites is a noop, packages have no kernel prerequisites on this platform
+func CheckPrerequisites(_ context.Context, _ string) error {
+	return nil
+}
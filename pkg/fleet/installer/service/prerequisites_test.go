@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+const testKernelRelease = "6.1.0-18-amd64"
+
+func newTestPrerequisitesChecker(t *testing.T, install bool) (*prerequisitesChecker, *[]string) {
+	var installed []string
+	c := &prerequisitesChecker{
+		root:          t.TempDir(),
+		kernelRelease: testKernelRelease,
+		distribution:  "debian",
+		install:       install,
+	}
+	c.installFunc = func(_ context.Context, _ string, pkgs []string) error {
+		installed = append(installed, pkgs...)
+		// the headers package creates the build directory of the running kernel
+		return os.MkdirAll(filepath.Join(c.root, "lib/modules", testKernelRelease, "build"), 0755)
+	}
+	return c, &installed
+}
+
+func createTestFile(t *testing.T, root string, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, path), nil, 0644))
+}
+
+func TestPrerequisitesNotRequired(t *testing.T) {
+	c, installed := newTestPrerequisitesChecker(t, true)
+
+	assert.NoError(t, c.check(context.Background(), "datadog-agent", systemProbePrerequisites))
+	assert.Empty(t, *installed)
+}
+
+func TestPrerequisitesSatisfied(t *testing.T) {
+	c, installed := newTestPrerequisitesChecker(t, true)
+	createTestFile(t, c.root, "etc/datadog-agent/system-probe.yaml")
+	createTestFile(t, c.root, "sys/kernel/btf/vmlinux")
+
+	assert.NoError(t, c.check(context.Background(), "datadog-agent", systemProbePrerequisites))
+	assert.Empty(t, *installed)
+}
+
+func TestPrerequisitesUnsatisfied(t *testing.T) {
+	c, installed := newTestPrerequisitesChecker(t, false)
+	createTestFile(t, c.root, "etc/datadog-agent/system-probe.yaml")
+
+	err := c.check(context.Background(), "datadog-agent", systemProbePrerequisites)
+	assert.Empty(t, *installed)
+
+	var prerequisitesErr *PrerequisitesError
+	require.True(t, errors.As(err, &prerequisitesErr))
+	assert.Equal(t, "datadog-agent", prerequisitesErr.Package)
+	assert.Equal(t, []string{"kernel BTF or headers (provided by linux-headers-" + testKernelRelease + ")"}, prerequisitesErr.Unsatisfied)
+	assert.Equal(t, installerErrors.ErrPrerequisitesNotMet, installerErrors.From(err).Code())
+}
+
+func TestPrerequisitesInstall(t *testing.T) {
+	c, installed := newTestPrerequisitesChecker(t, true)
+	createTestFile(t, c.root, "etc/datadog-agent/system-probe.yaml")
+
+	assert.NoError(t, c.check(context.Background(), "datadog-agent", systemProbePrerequisites))
+	assert.Equal(t, []string{"linux-headers-" + testKernelRelease}, *installed)
+}
+
+func TestPrerequisitesInstallUnsupportedDistribution(t *testing.T) {
+	c, installed := newTestPrerequisitesChecker(t, true)
+	c.distribution = ""
+	createTestFile(t, c.root, "etc/datadog-agent/system-probe.yaml")
+
+	err := c.check(context.Background(), "datadog-agent", systemProbePrerequisites)
+	assert.Empty(t, *installed)
+
+	var prerequisitesErr *PrerequisitesError
+	require.True(t, errors.As(err, &prerequisitesErr))
+	assert.Equal(t, []string{"kernel BTF or headers"}, prerequisitesErr.Unsatisfied)
+	assert.Equal(t, installerErrors.ErrPrerequisitesNotMet, installerErrors.From(err).Code())
+}
+
+func TestDistributionFamily(t *testing.T) {
+	testCases := []struct {
+		osRelease string
+		expected  string
+	}{
+		{osRelease: "ID=debian\nVERSION_ID=\"12\"\n", expected: "debian"},
+		{osRelease: "ID=ubuntu\nID_LIKE=debian\n", expected: "debian"},
+		{osRelease: "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n", expected: "rhel"},
+		{osRelease: "ID=\"amzn\"\nID_LIKE=\"centos rhel fedora\"\n", expected: "rhel"},
+		{osRelease: "ID=\"sles\"\nID_LIKE=\"suse\"\n", expected: "suse"},
+		{osRelease: "ID=alpine\n", expected: ""},
+		{osRelease: "", expected: ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, distributionFamily([]byte(tc.osRelease)), tc.osRelease)
+	}
+}
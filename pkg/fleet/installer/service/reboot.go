@@ -0,0 +1,39 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Reboot reboots the host, through systemd when it is running
+func Reboot(ctx context.Context) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "reboot")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	systemdRunning, err := isSystemdRunning()
+	if err != nil {
+		return fmt.Errorf("error checking if systemd is running: %w", err)
+	}
+	if systemdRunning {
+		_, err = systemctl(ctx, "reboot", "")
+		if err != nil {
+			return fmt.Errorf("could not reboot: %w", err)
+		}
+		return nil
+	}
+	output, err := exec.CommandContext(ctx, "shutdown", "-r", "now").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("could not reboot: %w: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows || darwin
+
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// Reboot is not supported on this platform
+func Reboot(_ context.Context) error {
+	return fmt.Errorf("reboot is not supported on this platform")
+}
@@ -0,0 +1,202 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/util/scrubber"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	// systemctlMaxAttempts is the number of times a systemctl command failing on a transient dbus error is run
+	systemctlMaxAttempts = 3
+	// journalExcerptLines is the number of lines of the journal of a unit reported when a command on it fails
+	journalExcerptLines = 20
+	// journalExcerptMaxSize is the size of the end of the journal excerpt reported, the lines can be long
+	journalExcerptMaxSize = 2048
+)
+
+// transientSystemctlErrors are the errors of systemctl when it can't reach systemd over dbus, e.g. while
+// systemd is reexecuted by a package upgrade. The commands failing on them are retried.
+var transientSystemctlErrors = []string{
+	"Failed to connect to bus",
+	"Transport endpoint is not connected",
+	"Connection timed out",
+	"Connection reset by peer",
+	"org.freedesktop.DBus.Error.NoReply",
+	"org.freedesktop.DBus.Error.Timeout",
+}
+
+var (
+	// systemctlRetryDelay is the time waited before retrying a command failing on a transient error
+	systemctlRetryDelay = time.Second
+	// runSystemctl and runJournalctl run the commands, they're overridden in tests
+	runSystemctl = func(ctx context.Context, args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, "systemctl", args...).CombinedOutput()
+	}
+	runJournalctl = func(ctx context.Context, unit string) ([]byte, error) {
+		return exec.CommandContext(ctx, "journalctl", "--unit", unit, "--lines", fmt.Sprint(journalExcerptLines), "--no-pager", "--output", "short-iso").Output()
+	}
+)
+
+// SystemctlResult is the result of a systemctl command.
+type SystemctlResult struct {
+	Args []string `json:"args"`
+	Unit string   `json:"unit,omitempty"`
+	// DryRun is set if the command was only recorded
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Attempts int           `json:"attempts"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// ActiveState is the state of the unit once started, for the commands starting units
+	ActiveState string `json:"active_state,omitempty"`
+	// Journal is an excerpt of the journal of the unit if the command failed
+	Journal string `json:"journal,omitempty"`
+}
+
+// SystemctlError is the error of a failed systemctl command. Its message carries the journal excerpt
+// of the unit so that it's reported with the task error.
+type SystemctlError struct {
+	Result SystemctlResult
+	Err    error
+}
+
+// Error returns the error message.
+func (e *SystemctlError) Error() string {
+	msg := fmt.Sprintf("systemctl %s failed: %v", strings.Join(e.Result.Args, " "), e.Err)
+	if output := strings.TrimSpace(e.Result.Output); output != "" {
+		msg += ": " + output
+	}
+	if e.Result.Journal != "" {
+		msg += fmt.Sprintf("\njournal of %s:\n%s", e.Result.Unit, strings.TrimRight(e.Result.Journal, "\n"))
+	}
+	return msg
+}
+
+// Unwrap returns the wrapped error.
+func (e *SystemctlError) Unwrap() error {
+	return e.Err
+}
+
+// systemctl runs a systemctl command on a unit, the unit is empty for the commands on systemd itself.
+// The commands failing on transient dbus errors are retried, the journal of the unit is captured if it
+// still fails.
+func systemctl(ctx context.Context, command string, unit string, args ...string) (result SystemctlResult, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "systemctl")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	result.Args = []string{command}
+	if unit != "" {
+		result.Args = append(result.Args, unit)
+	}
+	result.Args = append(result.Args, args...)
+	result.Unit = unit
+	span.SetTag("command", command)
+	span.SetTag("unit", unit)
+
+	if recordSystemctlDryRun(ctx, result.Args) {
+		log.Infof("Installer: Dry run, not running systemctl %s", strings.Join(result.Args, " "))
+		result.DryRun = true
+		return result, nil
+	}
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		span.SetTag("attempts", result.Attempts)
+	}()
+	for {
+		var output []byte
+		result.Attempts++
+		output, err = runSystemctl(ctx, result.Args...)
+		result.Output = string(output)
+		if err == nil || result.Attempts >= systemctlMaxAttempts || !transientSystemctlError(result.Output) {
+			break
+		}
+		log.Warnf("Installer: systemctl %s failed on a transient error, retrying: %s", strings.Join(result.Args, " "), strings.TrimSpace(result.Output))
+		select {
+		case <-time.After(systemctlRetryDelay):
+		case <-ctx.Done():
+			return result, &SystemctlError{Result: result, Err: ctx.Err()}
+		}
+	}
+	if err != nil {
+		if unit != "" {
+			result.Journal = journalExcerpt(ctx, unit)
+		}
+		return result, &SystemctlError{Result: result, Err: err}
+	}
+	return result, nil
+}
+
+// startUnitChecked starts a unit and checks it didn't fail right away, e.g. on an invalid
+// configuration. The units started without blocking aren't checked.
+func startUnitChecked(ctx context.Context, unit string, args ...string) error {
+	result, err := systemctl(ctx, "start", unit, args...)
+	if err != nil || result.DryRun || slices.Contains(args, "--no-block") {
+		return err
+	}
+	result.ActiveState, err = unitActiveState(ctx, unit)
+	if err != nil {
+		return err
+	}
+	if result.ActiveState == "failed" {
+		result.Journal = journalExcerpt(ctx, unit)
+		return &SystemctlError{Result: result, Err: fmt.Errorf("unit %s failed once started", unit)}
+	}
+	return nil
+}
+
+// unitActiveState returns the active state of a unit: active, inactive, failed, activating...
+func unitActiveState(ctx context.Context, unit string) (string, error) {
+	result, err := systemctl(ctx, "show", unit, "--property", "ActiveState", "--value")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Output), nil
+}
+
+// journalExcerpt returns the last lines of the journal of a unit, empty if it can't be read. The excerpt
+// is reported with the task errors so it's bounded and scrubbed.
+func journalExcerpt(ctx context.Context, unit string) string {
+	journal, err := runJournalctl(ctx, unit)
+	if err != nil {
+		log.Debugf("Installer: could not read the journal of %s: %v", unit, err)
+		return ""
+	}
+	if len(journal) > journalExcerptMaxSize {
+		journal = journal[len(journal)-journalExcerptMaxSize:]
+		// drop the truncated line
+		if i := bytes.IndexByte(journal, '\n'); i >= 0 && i < len(journal)-1 {
+			journal = journal[i+1:]
+		}
+	}
+	scrubbed, err := scrubber.ScrubBytes(journal)
+	if err != nil {
+		log.Debugf("Installer: could not scrub the journal of %s: %v", unit, err)
+		return ""
+	}
+	return string(scrubbed)
+}
+
+func transientSystemctlError(output string) bool {
+	for _, transientErr := range transientSystemctlErrors {
+		if strings.Contains(output, transientErr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type systemctlKey int
+
+var systemctlDryRunKey systemctlKey
+
+// systemctlDryRun records the systemctl commands skipped by a dry run
+type systemctlDryRun struct {
+	m        sync.Mutex
+	commands []string
+}
+
+// WithSystemctlDryRun returns a context in which the systemctl commands are recorded instead of run.
+func WithSystemctlDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemctlDryRunKey, &systemctlDryRun{})
+}
+
+// SystemctlDryRunCommands returns the systemctl commands recorded in a dry run context, in order.
+func SystemctlDryRunCommands(ctx context.Context) []string {
+	dryRun, ok := ctx.Value(systemctlDryRunKey).(*systemctlDryRun)
+	if !ok {
+		return nil
+	}
+	dryRun.m.Lock()
+	defer dryRun.m.Unlock()
+	return append([]string(nil), dryRun.commands...)
+}
+
+// recordSystemctlDryRun records a command if the context is a dry run one, it returns false otherwise
+func recordSystemctlDryRun(ctx context.Context, args []string) bool {
+	dryRun, ok := ctx.Value(systemctlDryRunKey).(*systemctlDryRun)
+	if !ok {
+		return false
+	}
+	dryRun.m.Lock()
+	defer dryRun.m.Unlock()
+	dryRun.commands = append(dryRun.commands, "systemctl "+strings.Join(args, " "))
+	return true
+}
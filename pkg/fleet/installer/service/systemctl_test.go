@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSystemctl struct {
+	calls   [][]string
+	outputs []fakeSystemctlOutput
+	journal string
+}
+
+type fakeSystemctlOutput struct {
+	output string
+	err    error
+}
+
+func newFakeSystemctl(t *testing.T, outputs ...fakeSystemctlOutput) *fakeSystemctl {
+	f := &fakeSystemctl{outputs: outputs, journal: "-- Journal begins --\ndatadog-agent.service: Main process exited, code=exited, status=1/FAILURE\n"}
+	previousSystemctl, previousJournalctl, previousDelay := runSystemctl, runJournalctl, systemctlRetryDelay
+	runSystemctl = func(_ context.Context, args ...string) ([]byte, error) {
+		f.calls = append(f.calls, args)
+		if len(f.outputs) == 0 {
+			return nil, nil
+		}
+		o := f.outputs[0]
+		f.outputs = f.outputs[1:]
+		return []byte(o.output), o.err
+	}
+	runJournalctl = func(_ context.Context, _ string) ([]byte, error) {
+		return []byte(f.journal), nil
+	}
+	systemctlRetryDelay = 0
+	t.Cleanup(func() {
+		runSystemctl, runJournalctl, systemctlRetryDelay = previousSystemctl, previousJournalctl, previousDelay
+	})
+	return f
+}
+
+func TestSystemctlRetriesTransientErrors(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	f := newFakeSystemctl(t,
+		fakeSystemctlOutput{output: "Failed to connect to bus: Connection refused", err: exitErr},
+		fakeSystemctlOutput{output: "Failed to enable unit: Transport endpoint is not connected", err: exitErr},
+	)
+
+	result, err := systemctl(context.Background(), "enable", "datadog-agent.service")
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Attempts)
+	assert.Len(t, f.calls, 3)
+	assert.Equal(t, []string{"enable", "datadog-agent.service"}, f.calls[2])
+}
+
+func TestSystemctlFailureCapturesJournal(t *testing.T) {
+	f := newFakeSystemctl(t, fakeSystemctlOutput{output: "Job for datadog-agent.service failed.", err: errors.New("exit status 1")})
+
+	_, err := systemctl(context.Background(), "stop", "datadog-agent.service")
+	var systemctlErr *SystemctlError
+	require.ErrorAs(t, err, &systemctlErr)
+	// permanent errors aren't retried
+	assert.Len(t, f.calls, 1)
+	assert.Equal(t, 1, systemctlErr.Result.Attempts)
+	assert.Equal(t, strings.TrimRight(f.journal, "\n"), strings.TrimRight(systemctlErr.Result.Journal, "\n"))
+	assert.True(t, strings.HasPrefix(err.Error(), "systemctl stop datadog-agent.service failed: exit status 1: Job for datadog-agent.service failed.\njournal of datadog-agent.service:\n"))
+	assert.Contains(t, err.Error(), "status=1/FAILURE")
+}
+
+func TestSystemctlDryRun(t *testing.T) {
+	f := newFakeSystemctl(t)
+
+	result, err := systemctl(WithSystemctlDryRun(context.Background()), "daemon-reload", "")
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"daemon-reload"}, result.Args)
+	assert.Empty(t, f.calls)
+}
+
+func TestSystemctlDryRunRecordsCommands(t *testing.T) {
+	f := newFakeSystemctl(t)
+
+	ctx := WithSystemctlDryRun(context.Background())
+	require.NoError(t, StartAgentExperiment(ctx))
+	require.NoError(t, StartInstallerExperiment(ctx))
+	assert.Equal(t, []string{
+		"systemctl start datadog-agent-exp.service --no-block",
+		"systemctl start datadog-installer-exp.service --no-block",
+	}, SystemctlDryRunCommands(ctx))
+	assert.Empty(t, f.calls)
+	assert.Empty(t, SystemctlDryRunCommands(context.Background()))
+}
+
+func TestJournalExcerptBoundedAndScrubbed(t *testing.T) {
+	f := newFakeSystemctl(t)
+	f.journal = strings.Repeat("datadog-agent.service: starting the agent\n", 100) +
+		"datadog-agent.service: invalid api_key: 0123456789abcdef0123456789abcdef\n"
+
+	journal := journalExcerpt(context.Background(), "datadog-agent.service")
+	assert.LessOrEqual(t, len(journal), journalExcerptMaxSize)
+	// the truncated line is dropped
+	assert.True(t, strings.HasPrefix(journal, "datadog-agent.service: starting the agent\n"))
+	assert.NotContains(t, journal, "0123456789abcdef0123456789abcdef")
+	assert.Contains(t, journal, "bcdef")
+}
+
+func TestStartUnitChecksActiveState(t *testing.T) {
+	f := newFakeSystemctl(t, fakeSystemctlOutput{}, fakeSystemctlOutput{output: "active\n"})
+	require.NoError(t, startUnit(context.Background(), "datadog-agent.service"))
+	assert.Equal(t, [][]string{
+		{"start", "datadog-agent.service"},
+		{"show", "datadog-agent.service", "--property", "ActiveState", "--value"},
+	}, f.calls)
+
+	// the unit failed right after being started
+	newFakeSystemctl(t, fakeSystemctlOutput{}, fakeSystemctlOutput{output: "failed\n"})
+	err := startUnit(context.Background(), "datadog-agent.service")
+	var systemctlErr *SystemctlError
+	require.ErrorAs(t, err, &systemctlErr)
+	assert.Equal(t, "failed", systemctlErr.Result.ActiveState)
+	assert.NotEmpty(t, systemctlErr.Result.Journal)
+
+	// the units started without blocking aren't checked
+	f = newFakeSystemctl(t)
+	require.NoError(t, startUnit(context.Background(), "datadog-agent-exp.service", "--no-block"))
+	assert.Len(t, f.calls, 1)
+}
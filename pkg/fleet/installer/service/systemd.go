@@ -3,7 +3,7 @@
 // This product includes software developed at Datadog (https://www.datadoghq.com/).
 // Copyright 2016-present Datadog, Inc.
 
-//go:build !windows
+//go:build !windows && !darwin
 
 // Package service provides a way to interact with os services
 package service
@@ -12,7 +12,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 
@@ -23,34 +22,34 @@ import (
 
 const systemdPath = "/etc/systemd/system"
 
+// installedUnitsPath keeps a copy of the units written at setup, the units of the installed packages
+// their verification compares against
+const installedUnitsPath = "/opt/datadog-packages/units"
+
 func stopUnit(ctx context.Context, unit string, args ...string) error {
-	span, _ := tracer.StartSpanFromContext(ctx, "stop_unit")
-	defer span.Finish()
-	span.SetTag("unit", unit)
-	args = append([]string{"stop", unit}, args...)
-	return exec.CommandContext(ctx, "systemctl", args...).Run()
+	_, err := systemctl(ctx, "stop", unit, args...)
+	return err
 }
 
 func startUnit(ctx context.Context, unit string, args ...string) error {
-	span, _ := tracer.StartSpanFromContext(ctx, "start_unit")
-	defer span.Finish()
-	span.SetTag("unit", unit)
-	args = append([]string{"start", unit}, args...)
-	return exec.CommandContext(ctx, "systemctl", args...).Run()
+	return startUnitChecked(ctx, unit, args...)
+}
+
+// tryRestartUnit restarts the unit if it is running. It isn't checked as the units failed
+// before aren't restarted.
+func tryRestartUnit(ctx context.Context, unit string) error {
+	_, err := systemctl(ctx, "try-restart", unit)
+	return err
 }
 
 func enableUnit(ctx context.Context, unit string) error {
-	span, _ := tracer.StartSpanFromContext(ctx, "enable_unit")
-	defer span.Finish()
-	span.SetTag("unit", unit)
-	return exec.CommandContext(ctx, "systemctl", "enable", unit).Run()
+	_, err := systemctl(ctx, "enable", unit)
+	return err
 }
 
 func disableUnit(ctx context.Context, unit string) error {
-	span, _ := tracer.StartSpanFromContext(ctx, "disable_unit")
-	defer span.Finish()
-	span.SetTag("unit", unit)
-	return exec.CommandContext(ctx, "systemctl", "disable", unit).Run()
+	_, err := systemctl(ctx, "disable", unit)
+	return err
 }
 
 func loadUnit(ctx context.Context, unit string) error {
@@ -62,20 +61,34 @@ func loadUnit(ctx context.Context, unit string) error {
 		return fmt.Errorf("error reading embedded unit %s: %w", unit, err)
 	}
 	unitPath := filepath.Join(systemdPath, unit)
-	return os.WriteFile(unitPath, content, 0644)
+	err = os.WriteFile(unitPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	// failing to keep a copy only makes the verification fall back to the units of the running installer
+	if err := os.MkdirAll(installedUnitsPath, 0755); err != nil {
+		log.Warnf("Failed to keep a copy of unit %s: %v", unit, err)
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(installedUnitsPath, unit), content, 0644); err != nil {
+		log.Warnf("Failed to keep a copy of unit %s: %v", unit, err)
+	}
+	return nil
 }
 
 func removeUnit(ctx context.Context, unit string) error {
 	span, _ := tracer.StartSpanFromContext(ctx, "remove_unit")
 	defer span.Finish()
 	span.SetTag("unit", unit)
+	if err := os.Remove(path.Join(installedUnitsPath, unit)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove the copy of unit %s: %v", unit, err)
+	}
 	return os.Remove(path.Join(systemdPath, unit))
 }
 
 func systemdReload(ctx context.Context) error {
-	span, _ := tracer.StartSpanFromContext(ctx, "systemd_reload")
-	defer span.Finish()
-	return exec.CommandContext(ctx, "systemctl", "daemon-reload").Run()
+	_, err := systemctl(ctx, "daemon-reload", "")
+	return err
 }
 
 // isSystemdRunning checks if systemd is running using the documented way
@@ -0,0 +1,237 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service/embedded"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Drift reasons
+const (
+	DriftMissing  = "missing"
+	DriftModified = "modified"
+)
+
+// Drift is a file managed by the installer for a package that doesn't match what it installed,
+// usually because it was changed by a configuration management tool
+type Drift struct {
+	Path     string
+	Reason   string
+	Repaired bool
+}
+
+// managedFiles are the files the installer manages on the host for a package
+type managedFiles struct {
+	// units are written from the embedded units
+	units []string
+	// enabled are the units enabled, linked from the wants directories of their WantedBy targets
+	enabled []string
+	// symlinks are the symlinks to their target
+	symlinks map[string]string
+}
+
+// VerifyAgentUnits checks that the systemd units and symlinks of the agent match the ones written
+// at setup, repairing them if asked to. It returns the drifts found.
+func VerifyAgentUnits(ctx context.Context, repair bool) ([]Drift, error) {
+	return verifyManagedFiles(ctx, "verify_agent_units", managedFiles{
+		units:    append(append([]string{}, stableUnits...), experimentalUnits...),
+		enabled:  []string{agentUnit},
+		symlinks: map[string]string{agentSymlink: "/opt/datadog-packages/datadog-agent/stable/bin/agent/agent"},
+	}, repair)
+}
+
+// VerifyInstallerUnits checks that the systemd units and symlinks of the installer match the ones
+// written at setup, repairing them if asked to. It returns the drifts found.
+func VerifyInstallerUnits(ctx context.Context, repair bool) ([]Drift, error) {
+	files := managedFiles{
+		symlinks: map[string]string{installerSymlink: "/opt/datadog-packages/datadog-installer/stable/bin/installer/installer"},
+	}
+	// units are only set up with remote updates
+	if os.Getenv("DD_REMOTE_UPDATES") == "true" {
+		files.units = installerUnits
+		files.enabled = []string{installerUnit}
+	}
+	return verifyManagedFiles(ctx, "verify_installer_units", files, repair)
+}
+
+func verifyManagedFiles(ctx context.Context, operation string, files managedFiles, repair bool) (drifts []Drift, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, operation)
+	defer func() {
+		repaired := 0
+		for _, drift := range drifts {
+			if drift.Repaired {
+				repaired++
+			}
+		}
+		span.SetTag("drifts", len(drifts))
+		span.SetTag("repaired", repaired)
+		span.Finish(tracer.WithError(err))
+	}()
+
+	systemdRunning, err := isSystemdRunning()
+	if err != nil {
+		return nil, fmt.Errorf("error checking if systemd is running: %w", err)
+	}
+	if !systemdRunning {
+		files.units = nil
+		files.enabled = nil
+	}
+	drifts, err = files.verify(systemdPath, installedUnitsPath, repair)
+	if err != nil {
+		return drifts, err
+	}
+	reload := false
+	for _, drift := range drifts {
+		span.SetTag("drift."+drift.Path, drift.Reason)
+		reload = reload || (drift.Repaired && strings.HasPrefix(drift.Path, systemdPath))
+	}
+	if reload {
+		return drifts, systemdReload(ctx)
+	}
+	return drifts, nil
+}
+
+// verify compares the managed files to the expected ones, the units being looked up in systemdDir and
+// compared to the copies kept in installedDir when they were written. The units the user masked and the
+// installed units the user disabled are left as they are.
+func (m managedFiles) verify(systemdDir string, installedDir string, repair bool) ([]Drift, error) {
+	var drifts []Drift
+	missing := make(map[string]bool)
+	masked := make(map[string]bool)
+	for _, unit := range m.units {
+		unitPath := filepath.Join(systemdDir, unit)
+		if target, err := os.Readlink(unitPath); err == nil && target == "/dev/null" {
+			masked[unit] = true
+			continue
+		}
+		expected, err := installedUnit(installedDir, unit)
+		if err != nil {
+			return drifts, err
+		}
+		content, err := os.ReadFile(unitPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return drifts, fmt.Errorf("error reading unit %s: %w", unitPath, err)
+		}
+		if err == nil && bytes.Equal(content, expected) {
+			continue
+		}
+		drift := Drift{Path: unitPath, Reason: DriftModified}
+		if err != nil {
+			drift.Reason = DriftMissing
+			missing[unit] = true
+		}
+		if repair {
+			drift.Repaired = repairDrift(drift, os.WriteFile(unitPath, expected, 0644))
+		}
+		drifts = append(drifts, drift)
+	}
+
+	for _, unit := range m.enabled {
+		if masked[unit] {
+			continue
+		}
+		content, err := installedUnit(installedDir, unit)
+		if err != nil {
+			return drifts, err
+		}
+		for _, target := range wantedBy(content) {
+			linkPath := filepath.Join(systemdDir, target+".wants", unit)
+			// an installed unit without its link was disabled by the user, it's only enabled back
+			// along with a unit that went missing
+			if _, err := os.Lstat(linkPath); errors.Is(err, os.ErrNotExist) && !missing[unit] {
+				continue
+			}
+			drifts = append(drifts, verifySymlink(linkPath, filepath.Join(systemdDir, unit), repair)...)
+		}
+	}
+
+	for linkPath, target := range m.symlinks {
+		drifts = append(drifts, verifySymlink(linkPath, target, repair)...)
+	}
+	return drifts, nil
+}
+
+// installedUnit returns the unit as written at setup, or as embedded in the running installer if no
+// copy was kept, e.g. for packages set up by an older installer
+func installedUnit(installedDir string, unit string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(installedDir, unit))
+	if err == nil {
+		return content, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading installed unit %s: %w", unit, err)
+	}
+	content, err = embedded.FS.ReadFile(unit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading embedded unit %s: %w", unit, err)
+	}
+	return content, nil
+}
+
+func verifySymlink(linkPath string, target string, repair bool) []Drift {
+	current, err := os.Readlink(linkPath)
+	if err == nil && current == target {
+		return nil
+	}
+	drift := Drift{Path: linkPath, Reason: DriftModified}
+	if errors.Is(err, os.ErrNotExist) {
+		drift.Reason = DriftMissing
+	}
+	if repair {
+		drift.Repaired = repairDrift(drift, replaceSymlink(linkPath, target))
+	}
+	return []Drift{drift}
+}
+
+func replaceSymlink(linkPath string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(linkPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Symlink(target, linkPath)
+}
+
+func repairDrift(drift Drift, err error) bool {
+	if err != nil {
+		log.Warnf("Failed to repair %s %s: %v", drift.Reason, drift.Path, err)
+		return false
+	}
+	return true
+}
+
+// wantedBy returns the targets of the WantedBy directives of the [Install] section of a unit
+func wantedBy(unit []byte) []string {
+	var targets []string
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(unit))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if section == "[Install]" && found && strings.TrimSpace(key) == "WantedBy" {
+			targets = append(targets, strings.Fields(value)...)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows || darwin
+
+package service
+
+import (
+	"context"
+)
+
+// Drift is a file managed by the installer for a package that doesn't match what it installed
+type Drift struct {
+	Path     string
+	Reason   string
+	Repaired bool
+}
+
+// VerifyAgentUnits noop
+func VerifyAgentUnits(_ context.Context, _ bool) ([]Drift, error) {
+	return nil, nil
+}
+
+// VerifyInstallerUnits noop
+func VerifyInstallerUnits(_ context.Context, _ bool) ([]Drift, error) {
+	return nil, nil
+}
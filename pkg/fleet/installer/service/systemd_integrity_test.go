@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service/embedded"
+)
+
+func TestWantedBy(t *testing.T) {
+	unit := []byte("[Unit]\nWantedBy=ignored.target\n\n[Install]\nWantedBy=multi-user.target graphical.target\nAlias=foo.service\n")
+	assert.Equal(t, []string{"multi-user.target", "graphical.target"}, wantedBy(unit))
+	assert.Empty(t, wantedBy([]byte("[Service]\nExecStart=/bin/true\n")))
+}
+
+func TestVerifyManagedFiles(t *testing.T) {
+	systemdDir := t.TempDir()
+	installedDir := t.TempDir()
+	binDir := t.TempDir()
+	files := managedFiles{
+		units:    []string{agentUnit, traceAgentUnit},
+		enabled:  []string{agentUnit},
+		symlinks: map[string]string{filepath.Join(binDir, "datadog-agent"): "/opt/agent"},
+	}
+
+	// nothing set up
+	drifts, err := files.verify(systemdDir, installedDir, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Drift{
+		{Path: filepath.Join(systemdDir, agentUnit), Reason: DriftMissing},
+		{Path: filepath.Join(systemdDir, traceAgentUnit), Reason: DriftMissing},
+		{Path: filepath.Join(systemdDir, "multi-user.target.wants", agentUnit), Reason: DriftMissing},
+		{Path: filepath.Join(binDir, "datadog-agent"), Reason: DriftMissing},
+	}, drifts)
+
+	// repaired
+	drifts, err = files.verify(systemdDir, installedDir, true)
+	require.NoError(t, err)
+	assert.Len(t, drifts, 4)
+	for _, drift := range drifts {
+		assert.True(t, drift.Repaired, drift.Path)
+	}
+	drifts, err = files.verify(systemdDir, installedDir, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// drift from a config management tool
+	require.NoError(t, os.WriteFile(filepath.Join(systemdDir, traceAgentUnit), []byte("[Service]\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(binDir, "datadog-agent")))
+	require.NoError(t, os.Symlink("/usr/local/agent", filepath.Join(binDir, "datadog-agent")))
+	drifts, err = files.verify(systemdDir, installedDir, true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Drift{
+		{Path: filepath.Join(systemdDir, traceAgentUnit), Reason: DriftModified, Repaired: true},
+		{Path: filepath.Join(binDir, "datadog-agent"), Reason: DriftModified, Repaired: true},
+	}, drifts)
+
+	content, err := os.ReadFile(filepath.Join(systemdDir, traceAgentUnit))
+	require.NoError(t, err)
+	expected, err := embedded.FS.ReadFile(traceAgentUnit)
+	require.NoError(t, err)
+	assert.Equal(t, expected, content)
+	target, err := os.Readlink(filepath.Join(binDir, "datadog-agent"))
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/agent", target)
+}
+
+func TestVerifyManagedFilesInstalledUnits(t *testing.T) {
+	systemdDir := t.TempDir()
+	installedDir := t.TempDir()
+	files := managedFiles{units: []string{agentUnit}, enabled: []string{agentUnit}}
+
+	// the unit written by the installer that set the package up differs from the embedded one
+	installed := []byte("[Service]\nExecStart=/opt/agent\n\n[Install]\nWantedBy=multi-user.target\n")
+	require.NoError(t, os.WriteFile(filepath.Join(installedDir, agentUnit), installed, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(systemdDir, agentUnit), installed, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(systemdDir, "multi-user.target.wants"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(systemdDir, agentUnit), filepath.Join(systemdDir, "multi-user.target.wants", agentUnit)))
+	drifts, err := files.verify(systemdDir, installedDir, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// and is the one restored
+	require.NoError(t, os.WriteFile(filepath.Join(systemdDir, agentUnit), []byte("[Service]\n"), 0644))
+	drifts, err = files.verify(systemdDir, installedDir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{{Path: filepath.Join(systemdDir, agentUnit), Reason: DriftModified, Repaired: true}}, drifts)
+	content, err := os.ReadFile(filepath.Join(systemdDir, agentUnit))
+	require.NoError(t, err)
+	assert.Equal(t, installed, content)
+}
+
+func TestVerifyManagedFilesUserChoices(t *testing.T) {
+	systemdDir := t.TempDir()
+	installedDir := t.TempDir()
+	files := managedFiles{units: []string{agentUnit, traceAgentUnit}, enabled: []string{agentUnit, traceAgentUnit}}
+	for _, unit := range files.units {
+		content, err := embedded.FS.ReadFile(unit)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(systemdDir, unit), content, 0644))
+	}
+
+	// the agent unit is disabled and the trace agent unit masked
+	require.NoError(t, os.Remove(filepath.Join(systemdDir, traceAgentUnit)))
+	require.NoError(t, os.Symlink("/dev/null", filepath.Join(systemdDir, traceAgentUnit)))
+	drifts, err := files.verify(systemdDir, installedDir, true)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+	assert.NoFileExists(t, filepath.Join(systemdDir, "multi-user.target.wants", agentUnit))
+	target, err := os.Readlink(filepath.Join(systemdDir, traceAgentUnit))
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/null", target)
+}
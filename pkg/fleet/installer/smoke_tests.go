@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	// smokeTestTimeout is the time given to a smoke test to succeed, the experiment may
+	// still be starting when the first attempts run.
+	smokeTestTimeout = 2 * time.Minute
+	// smokeTestInterval is the interval between two attempts of a failing smoke test.
+	smokeTestInterval = 5 * time.Second
+	// smokeTestAttemptTimeout is the timeout of a single attempt of a smoke test.
+	smokeTestAttemptTimeout = 30 * time.Second
+)
+
+// runSmokeTests runs the smoke tests declared by a package against its experiment.
+// The executable of each test is resolved relative to the package root so a package
+// can only run its own binaries.
+func runSmokeTests(ctx context.Context, packagePath string, tests [][]string) (err error) {
+	if len(tests) == 0 {
+		return nil
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "smoke_tests")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	span.SetTag("smoke_tests.count", len(tests))
+
+	for _, test := range tests {
+		if len(test) == 0 || !filepath.IsLocal(test[0]) {
+			return fmt.Errorf("invalid smoke test %q", test)
+		}
+		err = runSmokeTest(ctx, filepath.Join(packagePath, test[0]), test[1:])
+		if err != nil {
+			return fmt.Errorf("smoke test %q failed: %w", strings.Join(test, " "), err)
+		}
+	}
+	return nil
+}
+
+// runSmokeTest runs a smoke test until it succeeds or smokeTestTimeout is reached.
+func runSmokeTest(ctx context.Context, path string, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+	defer cancel()
+	for {
+		err := runSmokeTestAttempt(ctx, path, args)
+		if err == nil {
+			return nil
+		}
+		log.Debugf("smoke test %s failed, retrying: %v", path, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(smokeTestInterval):
+		}
+	}
+}
+
+func runSmokeTestAttempt(ctx context.Context, path string, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, smokeTestAttemptTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSmokeTestScript(t *testing.T, packagePath string, name string, content string) {
+	path := filepath.Join(packagePath, "bin", name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755))
+}
+
+func TestRunSmokeTests(t *testing.T) {
+	smokeTestTimeout = 2 * time.Second
+	smokeTestInterval = 10 * time.Millisecond
+	defer func() {
+		smokeTestTimeout = 2 * time.Minute
+		smokeTestInterval = 5 * time.Second
+	}()
+
+	packagePath := t.TempDir()
+	readyPath := filepath.Join(t.TempDir(), "ready")
+	writeSmokeTestScript(t, packagePath, "healthy", "exit 0\n")
+	writeSmokeTestScript(t, packagePath, "unhealthy", "echo not ready\nexit 1\n")
+	// Becomes healthy on its third attempt
+	writeSmokeTestScript(t, packagePath, "starting", "echo >> "+readyPath+"\n[ $(wc -l < "+readyPath+") -ge 3 ]\n")
+
+	assert.NoError(t, runSmokeTests(context.Background(), packagePath, nil))
+	assert.NoError(t, runSmokeTests(context.Background(), packagePath, [][]string{{"bin/healthy", "--version"}, {"bin/starting"}}))
+
+	err := runSmokeTests(context.Background(), packagePath, [][]string{{"bin/healthy"}, {"bin/unhealthy", "health"}})
+	assert.ErrorContains(t, err, `smoke test "bin/unhealthy health" failed`)
+	assert.ErrorContains(t, err, "not ready")
+
+	assert.Error(t, runSmokeTests(context.Background(), packagePath, [][]string{{}}))
+	assert.Error(t, runSmokeTests(context.Background(), packagePath, [][]string{{"../healthy"}}))
+	assert.Error(t, runSmokeTests(context.Background(), packagePath, [][]string{{"/bin/true"}}))
+}
@@ -9,18 +9,20 @@ package bootstrap
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/exec"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/oci"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/ospackages"
 )
 
 const (
 	installerPackage = "datadog-installer"
 	installerBinPath = "bin/installer/installer"
+	// osInstallerBinPath is the path to the installer binary of the OS package.
+	osInstallerBinPath = "/opt/datadog-installer/bin/installer/installer"
 
 	rootTmpDir = "/opt/datadog-installer/tmp"
 )
@@ -35,6 +37,17 @@ func InstallExperiment(ctx context.Context, env *env.Env, url string) error {
 	return install(ctx, env, url, true)
 }
 
+// InstallOSPackage installs the installer from the OS package of the Datadog repositories, for hosts
+// it can't be installed on from the registry, and returns an installer executor. The latest version is
+// installed if the version is empty.
+func InstallOSPackage(ctx context.Context, env *env.Env, version string) (*exec.InstallerExec, error) {
+	err := ospackages.Install(ctx, env.HTTPClient(), installerPackage, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install the installer OS package: %w", err)
+	}
+	return exec.NewInstallerExec(env, osInstallerBinPath), nil
+}
+
 func install(ctx context.Context, env *env.Env, url string, experiment bool) error {
 	err := os.MkdirAll(rootTmpDir, 0755)
 	if err != nil {
@@ -64,7 +77,7 @@ func install(ctx context.Context, env *env.Env, url string, experiment bool) err
 // 4. Create an installer executor from the extract layer.
 func downloadInstaller(ctx context.Context, env *env.Env, url string, tmpDir string) (*exec.InstallerExec, error) {
 	// 1. Download the installer package from the registry.
-	downloader := oci.NewDownloader(env, http.DefaultClient)
+	downloader := oci.NewDownloader(env, env.HTTPClient())
 	downloadedPackage, err := downloader.Download(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download installer package: %w", err)
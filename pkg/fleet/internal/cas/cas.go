@@ -0,0 +1,210 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package cas provides a content-addressed store of files, used to deduplicate the files
+// shared by the packages on disk.
+package cas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	tmpDir = "tmp"
+	// tmpMaxAge is the age after which a temporary file is considered abandoned by an
+	// interrupted extraction
+	tmpMaxAge = 24 * time.Hour
+)
+
+// Store is a content-addressed store of files. Each file written through the store is a hard
+// link to the object holding its content, so identical files are stored only once on disk.
+//
+// Objects are shared by every file with the same content and mode, the files linked to the
+// store must never be modified in place. Files whose owner is changed once linked must be
+// linked to the partition of the store of their owner.
+type Store struct {
+	rootPath string
+}
+
+// NewStore returns a new Store rooted at the given path. The directory is created on the
+// first write.
+func NewStore(rootPath string) *Store {
+	return &Store{
+		rootPath: rootPath,
+	}
+}
+
+// Partition returns the partition of the store holding the objects of the files of the given
+// owner. An owner is shared by all the links of an object, so the files of different owners
+// never share objects. The partitions are garbage collected with the store.
+func (s *Store) Partition(owner string) *Store {
+	return &Store{
+		rootPath: filepath.Join(s.rootPath, owner),
+	}
+}
+
+// Link writes the content read from the reader to the target path, as a hard link to the
+// object with the same content and mode. The object is added to the store if it doesn't exist.
+func (s *Store) Link(targetPath string, reader io.Reader, mode fs.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(targetPath), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create directory: %w", err)
+	}
+	if !hardLinksSupported {
+		return writeFile(targetPath, reader, mode)
+	}
+	tmpPath, hash, err := s.writeTmp(reader, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	// tar archives can overwrite a file extracted from a previous layer
+	if err := os.Remove(targetPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove existing file: %w", err)
+	}
+
+	objectPath := s.objectPath(hash, mode)
+	err = os.Link(objectPath, targetPath)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		// hard links aren't supported by every filesystem, keep the file unshared
+		log.Debugf("could not link %s to %s, keeping a copy: %v", targetPath, objectPath, err)
+		return os.Rename(tmpPath, targetPath)
+	}
+
+	// The file is moved to its target before being added to the store so that the object is
+	// never seen without link by a concurrent garbage collection
+	err = os.Rename(tmpPath, targetPath)
+	if err != nil {
+		return fmt.Errorf("could not move file: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(objectPath), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create object directory: %w", err)
+	}
+	err = os.Link(targetPath, objectPath)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		log.Debugf("could not add %s to the store, keeping it unshared: %v", targetPath, err)
+	}
+	return nil
+}
+
+// writeTmp writes the content of the reader to a temporary file of the store and returns its
+// path and the hash of the content.
+func (s *Store) writeTmp(reader io.Reader, mode fs.FileMode) (string, string, error) {
+	err := os.MkdirAll(filepath.Join(s.rootPath, tmpDir), 0755)
+	if err != nil {
+		return "", "", fmt.Errorf("could not create store directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Join(s.rootPath, tmpDir), "object-*")
+	if err != nil {
+		return "", "", fmt.Errorf("could not create file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), reader)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("could not write file: %w", err)
+	}
+	err = f.Chmod(mode)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("could not set file mode: %w", err)
+	}
+	return f.Name(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// objectPath returns the path of the object with the given hash and mode. The mode is part of
+// the address as it is shared by all the links of the object.
+func (s *Store) objectPath(hash string, mode fs.FileMode) string {
+	return filepath.Join(s.rootPath, hash[:2], fmt.Sprintf("%s-%o", hash, mode.Perm()))
+}
+
+// GarbageCollect removes the objects that aren't linked by any file anymore, and the temporary
+// files abandoned by interrupted extractions.
+func (s *Store) GarbageCollect(ctx context.Context) (err error) {
+	span, _ := tracer.StartSpanFromContext(ctx, "store_garbage_collect")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+	if !hardLinksSupported {
+		return nil
+	}
+
+	var removedObjects, removedBytes int64
+	err = filepath.WalkDir(s.rootPath, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == s.rootPath {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if filepath.Base(filepath.Dir(path)) == tmpDir {
+			if time.Since(info.ModTime()) > tmpMaxAge {
+				return removeIgnoreNotExist(path)
+			}
+			return nil
+		}
+		if linkCount(info) > 1 {
+			return nil
+		}
+		removedObjects++
+		removedBytes += info.Size()
+		return removeIgnoreNotExist(path)
+	})
+	span.SetTag("removed_objects", removedObjects)
+	span.SetTag("removed_bytes", removedBytes)
+	if err != nil {
+		return fmt.Errorf("could not clean up store: %w", err)
+	}
+	log.Debugf("Removed %d unused objects (%d bytes) from the store", removedObjects, removedBytes)
+	return nil
+}
+
+func removeIgnoreNotExist(path string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func writeFile(targetPath string, reader io.Reader, mode fs.FileMode) error {
+	f, err := os.OpenFile(targetPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("could not create file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, reader)
+	if err != nil {
+		return fmt.Errorf("could not write file: %w", err)
+	}
+	return nil
+}
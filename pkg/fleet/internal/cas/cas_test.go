@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package cas
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countObjects(t *testing.T, rootPath string) int {
+	var count int
+	err := filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Base(filepath.Dir(path)) != tmpDir {
+			count++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return count
+}
+
+func TestLinkDeduplicates(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "store")
+	pkgsPath := t.TempDir()
+	s := NewStore(storePath)
+
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "a", "LICENSE"), strings.NewReader("license"), 0644))
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "b", "LICENSE"), strings.NewReader("license"), 0644))
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "b", "run.sh"), strings.NewReader("license"), 0755))
+	assert.Equal(t, 2, countObjects(t, storePath))
+
+	a, err := os.Stat(filepath.Join(pkgsPath, "a", "LICENSE"))
+	require.NoError(t, err)
+	b, err := os.Stat(filepath.Join(pkgsPath, "b", "LICENSE"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(a, b))
+	assert.Equal(t, uint64(3), linkCount(a))
+
+	content, err := os.ReadFile(filepath.Join(pkgsPath, "b", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "license", string(content))
+	info, err := os.Stat(filepath.Join(pkgsPath, "b", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+func TestPartition(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "store")
+	pkgsPath := t.TempDir()
+	s := NewStore(storePath)
+
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "a", "LICENSE"), strings.NewReader("license"), 0644))
+	require.NoError(t, s.Partition("dd-agent").Link(filepath.Join(pkgsPath, "b", "LICENSE"), strings.NewReader("license"), 0644))
+	assert.Equal(t, 2, countObjects(t, storePath))
+
+	a, err := os.Stat(filepath.Join(pkgsPath, "a", "LICENSE"))
+	require.NoError(t, err)
+	b, err := os.Stat(filepath.Join(pkgsPath, "b", "LICENSE"))
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(a, b))
+
+	// the objects of the partitions are garbage collected with the store
+	require.NoError(t, os.RemoveAll(filepath.Join(pkgsPath, "b")))
+	require.NoError(t, s.GarbageCollect(context.Background()))
+	assert.Equal(t, 1, countObjects(t, storePath))
+}
+
+func TestLinkOverwrite(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "store"))
+	target := filepath.Join(t.TempDir(), "file")
+
+	require.NoError(t, s.Link(target, strings.NewReader("v1"), 0644))
+	require.NoError(t, s.Link(target, strings.NewReader("v2"), 0644))
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+}
+
+func TestGarbageCollect(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "store")
+	pkgsPath := t.TempDir()
+	s := NewStore(storePath)
+
+	// GC of a store never written to is a no-op
+	require.NoError(t, s.GarbageCollect(context.Background()))
+
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "a", "shared"), strings.NewReader("shared"), 0644))
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "b", "shared"), strings.NewReader("shared"), 0644))
+	require.NoError(t, s.Link(filepath.Join(pkgsPath, "a", "own"), strings.NewReader("own"), 0644))
+	assert.Equal(t, 2, countObjects(t, storePath))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(pkgsPath, "a")))
+	require.NoError(t, s.GarbageCollect(context.Background()))
+	assert.Equal(t, 1, countObjects(t, storePath))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(pkgsPath, "b")))
+	require.NoError(t, s.GarbageCollect(context.Background()))
+	assert.Equal(t, 0, countObjects(t, storePath))
+}
@@ -0,0 +1,25 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package cas
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+const hardLinksSupported = true
+
+// linkCount returns the number of hard links of a file
+func linkCount(info fs.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		// can't tell, keep the object
+		return 2
+	}
+	return uint64(stat.Nlink)
+}
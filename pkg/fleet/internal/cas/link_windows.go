@@ -0,0 +1,20 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package cas
+
+import (
+	"io/fs"
+)
+
+// hardLinksSupported is false on Windows as the link count of a file isn't available from its
+// info, files are written without being shared so that no object is left behind
+const hardLinksSupported = false
+
+func linkCount(_ fs.FileInfo) uint64 {
+	return 2
+}
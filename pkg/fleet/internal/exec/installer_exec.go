@@ -9,14 +9,22 @@ package exec
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/helper"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
 	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/service"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/limits"
 	"github.com/DataDog/datadog-agent/pkg/fleet/telemetry"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
@@ -26,46 +34,277 @@ const (
 	StableInstallerPath = "/opt/datadog-packages/datadog-installer/stable/bin/installer/installer"
 	// ExperimentInstallerPath is the path to the experiment installer binary.
 	ExperimentInstallerPath = "/opt/datadog-packages/datadog-installer/experiment/bin/installer/installer"
+
+	// stderrTailSize is the size of the end of stderr kept to report the failures of the installer
+	stderrTailSize = 4096
 )
 
+// interruptGracePeriod is the time left to the installer to exit once interrupted, when its context
+// is done, before it's killed
+var interruptGracePeriod = 30 * time.Second
+
 // InstallerExec is an implementation of the Installer interface that uses the installer binary.
 type InstallerExec struct {
 	env              *env.Env
 	installerBinPath string
+	// helper runs the commands as root when the daemon doesn't, nil if they're run directly
+	helper *helper.Client
 }
 
 // NewInstallerExec returns a new InstallerExec.
 func NewInstallerExec(env *env.Env, installerBinPath string) *InstallerExec {
-	return &InstallerExec{
+	i := &InstallerExec{
 		env:              env,
 		installerBinPath: installerBinPath,
 	}
+	if env.PrivilegedHelperSocket != "" {
+		i.helper = helper.NewClient(env.PrivilegedHelperSocket)
+	}
+	return i
 }
 
 type installerCmd struct {
 	*exec.Cmd
-	span tracer.Span
-	ctx  context.Context
+	command string
+	span    tracer.Span
+	ctx     context.Context
+
+	// helper runs the command through the privileged helper if set, helperRequest is built from the
+	// arguments of the command and helperExitCode is the exit code the helper returns
+	helper         *helper.Client
+	helperRequest  helper.RunRequest
+	helperErr      error
+	helperExitCode int
 }
 
 func (i *InstallerExec) newInstallerCmd(ctx context.Context, command string, args ...string) *installerCmd {
 	env := i.env.ToEnv()
 	span, ctx := tracer.StartSpanFromContext(ctx, fmt.Sprintf("installer.%s", command))
 	span.SetTag("args", args)
-	cmd := exec.CommandContext(ctx, i.installerBinPath, append([]string{command}, args...)...)
+	name, cmdArgs := i.installerBinPath, append([]string{command}, args...)
+	if scopeArgs := limits.ScopeArgs(i.env.SubprocessLimits); len(scopeArgs) > 0 {
+		name, cmdArgs = "systemd-run", append(append(scopeArgs, "--", i.installerBinPath), cmdArgs...)
+	}
+	cmd := exec.CommandContext(ctx, name, cmdArgs...)
 	env = append(os.Environ(), env...)
 	cmd.Cancel = func() error {
 		return cmd.Process.Signal(os.Interrupt)
 	}
+	cmd.WaitDelay = interruptGracePeriod
 	env = append(env, telemetry.EnvFromSpanContext(span.Context())...)
 	cmd.Env = env
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return &installerCmd{
-		Cmd:  cmd,
-		span: span,
-		ctx:  ctx,
+	installerCmd := &installerCmd{
+		Cmd:     cmd,
+		command: command,
+		span:    span,
+		ctx:     ctx,
+	}
+	if i.helper != nil {
+		// the helper runs its own installer binary, with its own configuration and resource limits
+		installerCmd.helper = i.helper
+		installerCmd.helperRequest, installerCmd.helperErr = helperRequest(command, args)
+		installerCmd.helperRequest.Env = telemetry.EnvFromSpanContext(span.Context())
+	}
+	return installerCmd
+}
+
+// Run runs the installer command. The installer writes the error it fails with on stderr, it's
+// returned with its code. Other failures, e.g. the installer crashing or being interrupted, are
+// returned with the end of stderr.
+func (c *installerCmd) Run() error {
+	stderr := &tailBuffer{size: stderrTailSize}
+	c.Cmd.Stderr = io.MultiWriter(c.Cmd.Stderr, stderr)
+	var err error
+	if c.helper != nil {
+		c.helperExitCode, err = c.runThroughHelper()
+		if err == nil && c.helperExitCode != 0 {
+			err = fmt.Errorf("exit status %d", c.helperExitCode)
+		}
+	} else {
+		err = c.Cmd.Run()
+	}
+	if err == nil {
+		return nil
+	}
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("installer %s was interrupted: %w (%w)", c.command, ctxErr, err)
+	}
+	if installerErr, ok := lastInstallerError(stderr.String()); ok {
+		return installerErr
+	}
+	return fmt.Errorf("installer %s failed: %w\n%s", c.command, err, stderr.String())
+}
+
+// runThroughHelper runs the installer command through the privileged helper, writing its output to the
+// outputs of the command, and returns its exit code.
+func (c *installerCmd) runThroughHelper() (int, error) {
+	c.span.SetTag("privileged_helper", true)
+	if c.helperErr != nil {
+		return -1, c.helperErr
+	}
+	request := c.helperRequest
+	if c.Cmd.Stdin != nil {
+		stdin, err := io.ReadAll(c.Cmd.Stdin)
+		if err != nil {
+			return -1, fmt.Errorf("could not read installer %s input: %w", c.command, err)
+		}
+		request.Stdin = string(stdin)
+	}
+	resp, err := c.helper.Run(c.ctx, request)
+	if err != nil {
+		return -1, err
+	}
+	_, _ = io.WriteString(c.Cmd.Stdout, resp.Stdout)
+	_, _ = io.WriteString(c.Cmd.Stderr, resp.Stderr)
+	return resp.ExitCode, nil
+}
+
+// exitCode returns the exit code of the command once run, or -1 if it didn't exit
+func (c *installerCmd) exitCode() int {
+	if c.helper != nil {
+		return c.helperExitCode
+	}
+	return c.ProcessState.ExitCode()
+}
+
+// helperRequest returns the request running an installer command through the privileged helper
+func helperRequest(command string, args []string) (helper.RunRequest, error) {
+	request := helper.RunRequest{Command: command}
+	switch command {
+	case "install", "install-experiment":
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "--dry-run":
+				request.DryRun = true
+			case "--delta-from":
+				i++
+				if i == len(args) {
+					return request, fmt.Errorf("missing delta base for installer %s", command)
+				}
+				image, err := helperImage(args[i])
+				if err != nil {
+					return request, err
+				}
+				request.DeltaBase = image
+			default:
+				image, err := helperImage(args[i])
+				if err != nil {
+					return request, err
+				}
+				request.Image = image
+			}
+		}
+	case "remove", "remove-experiment", "promote-experiment", "rollback", "is-installed":
+		if len(args) != 1 {
+			return request, fmt.Errorf("installer %s expects a package", command)
+		}
+		request.Package = args[0]
+	case "apm instrument", "apm uninstrument":
+		if len(args) > 0 && args[0] == "--dry-run" {
+			request.DryRun = true
+			args = args[1:]
+		}
+		if len(args) != 1 {
+			return request, fmt.Errorf("installer %s expects a method", command)
+		}
+		request.Method = args[0]
+	case "check-permissions":
+		request.Repair = slices.Contains(args, "--repair")
+	case "garbage-collect", "default-packages", "rotate-api-key", "reboot", "set-integration-configs", "rollback-integration-configs":
+	default:
+		return request, fmt.Errorf("installer %s can't be run through the privileged helper", command)
+	}
+	return request, nil
+}
+
+// helperImage returns the image of a package URL. The helper installs the image from its own registry,
+// so only registry packages pinned to their digest are supported.
+func helperImage(url string) (*helper.Image, error) {
+	ref, ok := strings.CutPrefix(url, "oci://")
+	name, digest, found := strings.Cut(ref[strings.LastIndex(ref, "/")+1:], "@")
+	if !ok || !found {
+		return nil, fmt.Errorf("the privileged helper only installs registry packages pinned to their digest, got %s", url)
+	}
+	return &helper.Image{Name: name, Digest: digest}, nil
+}
+
+// runDryRun runs a dry-run installer command, the installer writes its report on stdout
+func (c *installerCmd) runDryRun() (*installer.DryRunReport, error) {
+	var stdout bytes.Buffer
+	c.Cmd.Stdout = &stdout
+	err := c.Run()
+	if err != nil {
+		return nil, err
+	}
+	var report installer.DryRunReport
+	err = json.Unmarshal(stdout.Bytes(), &report)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode dry run report: %w", err)
+	}
+	return &report, nil
+}
+
+// runFileDiff runs a dry-run apm installer command, the installer writes the file modification on stdout
+func (c *installerCmd) runFileDiff() (service.FileDiff, error) {
+	var stdout bytes.Buffer
+	c.Cmd.Stdout = &stdout
+	err := c.Run()
+	if err != nil {
+		return service.FileDiff{}, err
+	}
+	var diff service.FileDiff
+	err = json.Unmarshal(stdout.Bytes(), &diff)
+	if err != nil {
+		return service.FileDiff{}, fmt.Errorf("could not decode file diff: %w", err)
+	}
+	return diff, nil
+}
+
+// runPermissions runs a check-permissions installer command, the installer writes the issues on stdout
+func (c *installerCmd) runPermissions() ([]repository.PermissionIssue, error) {
+	var stdout bytes.Buffer
+	c.Cmd.Stdout = &stdout
+	err := c.Run()
+	if err != nil {
+		return nil, err
+	}
+	var issues []repository.PermissionIssue
+	err = json.Unmarshal(stdout.Bytes(), &issues)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode permission issues: %w", err)
+	}
+	return issues, nil
+}
+
+// lastInstallerError returns the last error written by the installer on stderr, if any
+func lastInstallerError(stderr string) (*installerErrors.InstallerError, bool) {
+	lines := strings.Split(stderr, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if installerErr, ok := installerErrors.FromJSON(lines[i]); ok {
+			return installerErr, true
+		}
+	}
+	return nil, false
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	size int
+	buf  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.size:]...)
 	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
 }
 
 // Install installs a package.
@@ -75,6 +314,13 @@ func (i *InstallerExec) Install(ctx context.Context, url string, _ []string) (er
 	return cmd.Run()
 }
 
+// InstallDryRun reports what installing a package would change, without changing anything.
+func (i *InstallerExec) InstallDryRun(ctx context.Context, url string) (_ *installer.DryRunReport, err error) {
+	cmd := i.newInstallerCmd(ctx, "install", "--dry-run", url)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.runDryRun()
+}
+
 // Remove removes a package.
 func (i *InstallerExec) Remove(ctx context.Context, pkg string) (err error) {
 	cmd := i.newInstallerCmd(ctx, "remove", pkg)
@@ -89,11 +335,22 @@ func (i *InstallerExec) Purge(_ context.Context) {
 
 // InstallExperiment installs an experiment.
 func (i *InstallerExec) InstallExperiment(ctx context.Context, url string) (err error) {
-	cmd := i.newInstallerCmd(ctx, "install-experiment", url)
+	args := []string{url}
+	if baseURL := installer.DeltaBase(ctx); baseURL != "" {
+		args = append([]string{"--delta-from", baseURL}, args...)
+	}
+	cmd := i.newInstallerCmd(ctx, "install-experiment", args...)
 	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
 	return cmd.Run()
 }
 
+// InstallExperimentDryRun reports what installing an experiment would change, without changing anything.
+func (i *InstallerExec) InstallExperimentDryRun(ctx context.Context, url string) (_ *installer.DryRunReport, err error) {
+	cmd := i.newInstallerCmd(ctx, "install-experiment", "--dry-run", url)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.runDryRun()
+}
+
 // RemoveExperiment removes an experiment.
 func (i *InstallerExec) RemoveExperiment(ctx context.Context, pkg string) (err error) {
 	cmd := i.newInstallerCmd(ctx, "remove-experiment", pkg)
@@ -108,6 +365,27 @@ func (i *InstallerExec) PromoteExperiment(ctx context.Context, pkg string) (err
 	return cmd.Run()
 }
 
+// Rollback sets the previous stable version of a package as stable again.
+func (i *InstallerExec) Rollback(ctx context.Context, pkg string) (err error) {
+	cmd := i.newInstallerCmd(ctx, "rollback", pkg)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.Run()
+}
+
+// Export writes an archive of an installed package to the given path.
+func (i *InstallerExec) Export(ctx context.Context, pkg string, version string, path string) (err error) {
+	cmd := i.newInstallerCmd(ctx, "export", pkg, version, path)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.Run()
+}
+
+// ImportArchive installs the package of an archive.
+func (i *InstallerExec) ImportArchive(ctx context.Context, path string, _ []string) (err error) {
+	cmd := i.newInstallerCmd(ctx, "import", path)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.Run()
+}
+
 // GarbageCollect runs the garbage collector.
 func (i *InstallerExec) GarbageCollect(ctx context.Context) (err error) {
 	cmd := i.newInstallerCmd(ctx, "garbage-collect")
@@ -129,12 +407,74 @@ func (i *InstallerExec) UninstrumentAPMInjector(ctx context.Context, method stri
 	return cmd.Run()
 }
 
+// DiffAPMInjectorDocker returns the modification (un)instrumenting docker would make to the docker
+// daemon configuration, without applying it.
+func (i *InstallerExec) DiffAPMInjectorDocker(ctx context.Context, uninstrument bool) (_ service.FileDiff, err error) {
+	command := "apm instrument"
+	if uninstrument {
+		command = "apm uninstrument"
+	}
+	cmd := i.newInstallerCmd(ctx, command, "--dry-run", env.APMInstrumentationEnabledDocker)
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.runFileDiff()
+}
+
+// RotateAPIKey rotates the API key of the agent. The key is passed through stdin
+// to keep it out of the process arguments.
+func (i *InstallerExec) RotateAPIKey(ctx context.Context, apiKey string) (err error) {
+	cmd := i.newInstallerCmd(ctx, "rotate-api-key")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	cmd.Stdin = strings.NewReader(apiKey)
+	return cmd.Run()
+}
+
+// SetIntegrationConfigs sets the integration configurations delivered remotely. The configurations are
+// passed through stdin as they can be large and hold secrets.
+func (i *InstallerExec) SetIntegrationConfigs(ctx context.Context, version string, files map[string][]byte) (err error) {
+	cmd := i.newInstallerCmd(ctx, "set-integration-configs")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	configs, err := json.Marshal(installer.IntegrationConfigs{Version: version, Files: files})
+	if err != nil {
+		return fmt.Errorf("could not marshal integration configurations: %w", err)
+	}
+	cmd.Stdin = bytes.NewReader(configs)
+	return cmd.Run()
+}
+
+// RollbackIntegrationConfigs restores the previous integration configurations delivered remotely.
+func (i *InstallerExec) RollbackIntegrationConfigs(ctx context.Context) (err error) {
+	cmd := i.newInstallerCmd(ctx, "rollback-integration-configs")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.Run()
+}
+
+// Reboot reboots the host.
+func (i *InstallerExec) Reboot(ctx context.Context) (err error) {
+	cmd := i.newInstallerCmd(ctx, "reboot")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.Run()
+}
+
+// CheckPermissions checks the owners and modes of the files of the packages directory.
+func (i *InstallerExec) CheckPermissions(ctx context.Context) (_ []repository.PermissionIssue, err error) {
+	cmd := i.newInstallerCmd(ctx, "check-permissions")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.runPermissions()
+}
+
+// RepairPermissions restores the owners and modes of the files of the packages directory.
+func (i *InstallerExec) RepairPermissions(ctx context.Context) (_ []repository.PermissionIssue, err error) {
+	cmd := i.newInstallerCmd(ctx, "check-permissions", "--repair")
+	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
+	return cmd.runPermissions()
+}
+
 // IsInstalled checks if a package is installed.
 func (i *InstallerExec) IsInstalled(ctx context.Context, pkg string) (_ bool, err error) {
 	cmd := i.newInstallerCmd(ctx, "is-installed", pkg)
 	defer func() { cmd.span.Finish(tracer.WithError(err)) }()
 	err = cmd.Run()
-	if err != nil && cmd.ProcessState.ExitCode() == 10 {
+	if err != nil && cmd.exitCode() == 10 {
 		return false, nil
 	}
 	if err != nil {
@@ -168,7 +508,7 @@ func (i *InstallerExec) DefaultPackages(ctx context.Context) (_ []string, err er
 // State returns the state of a package.
 func (i *InstallerExec) State(pkg string) (repository.State, error) {
 	repositories := repository.NewRepositories(installer.PackagesPath, installer.LocksPack)
-	return repositories.Get(pkg).GetState()
+	return repositories.GetPackageState(pkg)
 }
 
 // States returns the states of all packages.
@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// the peer credentials of the helper connections are only supported on linux
+//go:build linux
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/helper"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fakeinstaller"
+)
+
+const testDigest = "sha256:2fa082d512a120a814e32ddb80454efce56595b5c84a37cc1a9f90cf9cc7ba85"
+
+func newTestInstallerExecWithHelper(t *testing.T) *InstallerExec {
+	current, err := user.Current()
+	require.NoError(t, err)
+	dir, err := os.MkdirTemp("", "helper")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "helper.sock")
+	// rotate-api-key is opted in to test the commands taking a standard input
+	allowedCommands, err := helper.AllowedCommands(helper.DefaultAllowedCommands, []string{"rotate-api-key"})
+	require.NoError(t, err)
+	s, err := helper.NewServer(socketPath, fakeinstaller.Path(t), &env.Env{Site: "datadoghq.com"}, allowedCommands, current.Username)
+	require.NoError(t, err)
+	s.Start()
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	// the daemon binary isn't run, the helper runs its own
+	return NewInstallerExec(&env.Env{PrivilegedHelperSocket: socketPath}, "/nonexistent/installer")
+}
+
+func TestInstallerExecThroughHelper(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	i := newTestInstallerExecWithHelper(t)
+
+	require.NoError(t, i.Install(context.Background(), "oci://example.com/test-package@"+testDigest, nil))
+	require.NoError(t, i.PromoteExperiment(context.Background(), "test-package"))
+	rawCalls, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	// the helper installs the package from its own registry
+	assert.Equal(t, "install oci://gcr.io/datadoghq/test-package@"+testDigest+"\npromote-experiment test-package\n", string(rawCalls))
+
+	// the packages that aren't pinned to their digest and the commands the helper doesn't support are refused
+	err = i.Install(context.Background(), "oci://example.com/test-package:1.0.0", nil)
+	assert.ErrorContains(t, err, "pinned to their digest")
+	err = i.Install(context.Background(), "file:///tmp/test-package", nil)
+	assert.ErrorContains(t, err, "pinned to their digest")
+	err = i.Export(context.Background(), "test-package", "1.0.0", t.TempDir())
+	assert.ErrorContains(t, err, "can't be run through the privileged helper")
+}
+
+func TestInstallerExecThroughHelperStdin(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Input: input})
+	i := newTestInstallerExecWithHelper(t)
+
+	require.NoError(t, i.RotateAPIKey(context.Background(), "newkey"))
+	rawInput, err := os.ReadFile(input)
+	require.NoError(t, err)
+	assert.Equal(t, "newkey", string(rawInput))
+}
+
+func TestInstallerExecThroughHelperExitCode(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{ExitCode: 10})
+	i := newTestInstallerExecWithHelper(t)
+
+	installed, err := i.IsInstalled(context.Background(), "test-package")
+	require.NoError(t, err)
+	assert.False(t, installed)
+
+	fakeinstaller.Set(t, fakeinstaller.Behavior{})
+	installed, err = i.IsInstalled(context.Background(), "test-package")
+	require.NoError(t, err)
+	assert.True(t, installed)
+}
+
+func TestInstallerExecThroughHelperErrorCode(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{
+		Error:    &fakeinstaller.Error{Message: "could not download package", Code: installerErrors.ErrDownloadFailed},
+		ExitCode: 255,
+	})
+	i := newTestInstallerExecWithHelper(t)
+
+	err := i.Install(context.Background(), "oci://example.com/test-package@"+testDigest, nil)
+	require.Error(t, err)
+	assert.Equal(t, installerErrors.ErrDownloadFailed, installerErrors.From(err).Code())
+	assert.Equal(t, "could not download package", installerErrors.From(err).Error())
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fakeinstaller"
+)
+
+func TestMain(m *testing.M) {
+	fakeinstaller.Main()
+	os.Exit(m.Run())
+}
+
+func newTestInstallerExec(t *testing.T) *InstallerExec {
+	return NewInstallerExec(&env.Env{}, fakeinstaller.Path(t))
+}
+
+func TestInstallerExecSuccess(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Calls: calls})
+	i := newTestInstallerExec(t)
+
+	err := i.Install(context.Background(), "oci://example.com/test-package:1.0.0", nil)
+	assert.NoError(t, err)
+	err = i.PromoteExperiment(context.Background(), "test-package")
+	assert.NoError(t, err)
+
+	rawCalls, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, "install oci://example.com/test-package:1.0.0\npromote-experiment test-package\n", string(rawCalls))
+}
+
+func TestInstallerExecErrorCode(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{
+		Stderr:   "downloading package\n",
+		Error:    &fakeinstaller.Error{Message: "could not download package", Code: installerErrors.ErrDownloadFailed},
+		ExitCode: 255,
+	})
+	i := newTestInstallerExec(t)
+
+	err := i.Install(context.Background(), "oci://example.com/test-package:1.0.0", nil)
+	require.Error(t, err)
+	installerErr := installerErrors.From(err)
+	assert.Equal(t, installerErrors.ErrDownloadFailed, installerErr.Code())
+	assert.Equal(t, "could not download package", installerErr.Error())
+}
+
+func TestInstallerExecPartialOutput(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{
+		Stderr:   strings.Repeat("x", 2*stderrTailSize) + "\nextracting layer 2/3",
+		ExitCode: 3,
+	})
+	i := newTestInstallerExec(t)
+
+	err := i.InstallExperiment(context.Background(), "oci://example.com/test-package:1.0.0")
+	require.Error(t, err)
+	assert.Equal(t, installerErrors.InstallerErrorCode(0), installerErrors.From(err).Code())
+	assert.Contains(t, err.Error(), "installer install-experiment failed: exit status 3")
+	assert.Contains(t, err.Error(), "extracting layer 2/3")
+	// only the end of stderr is reported
+	assert.Less(t, len(err.Error()), stderrTailSize+100)
+}
+
+func TestInstallerExecCrash(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Crash: true})
+	i := newTestInstallerExec(t)
+
+	err := i.Remove(context.Background(), "test-package")
+	require.Error(t, err)
+	assert.Equal(t, installerErrors.InstallerErrorCode(0), installerErrors.From(err).Code())
+	assert.Contains(t, err.Error(), "installer remove failed: exit status 2")
+	assert.Contains(t, err.Error(), "panic: fake installer crash")
+}
+
+func TestInstallerExecTimeout(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Hang: true})
+	i := newTestInstallerExec(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := i.Install(ctx, "oci://example.com/test-package:1.0.0", nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "installer install was interrupted")
+	assert.Less(t, time.Since(start), interruptGracePeriod)
+}
+
+func TestInstallerExecTimeoutIgnoringInterrupt(t *testing.T) {
+	oldInterruptGracePeriod := interruptGracePeriod
+	defer func() { interruptGracePeriod = oldInterruptGracePeriod }()
+	interruptGracePeriod = 200 * time.Millisecond
+
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Hang: true, IgnoreInterrupt: true})
+	i := newTestInstallerExec(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := i.Install(ctx, "oci://example.com/test-package:1.0.0", nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// the installer is killed once the grace period is over
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestInstallerExecIsInstalled(t *testing.T) {
+	fakeinstaller.Set(t, fakeinstaller.Behavior{})
+	i := newTestInstallerExec(t)
+	installed, err := i.IsInstalled(context.Background(), "test-package")
+	assert.NoError(t, err)
+	assert.True(t, installed)
+
+	fakeinstaller.Set(t, fakeinstaller.Behavior{ExitCode: 10})
+	installed, err = i.IsInstalled(context.Background(), "test-package")
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	fakeinstaller.Set(t, fakeinstaller.Behavior{Error: &fakeinstaller.Error{Message: "could not open database"}, ExitCode: 255})
+	_, err = i.IsInstalled(context.Background(), "test-package")
+	assert.EqualError(t, err, "could not open database")
+}
+
+func TestLastInstallerError(t *testing.T) {
+	// the installer reports the error before the generic error message of the command
+	stderr := `downloading package
+{"error":"could not download package","code":2}
+Error: could not download package
+`
+	installerErr, ok := lastInstallerError(stderr)
+	require.True(t, ok)
+	assert.Equal(t, installerErrors.ErrDownloadFailed, installerErr.Code())
+
+	_, ok = lastInstallerError("Error: could not download package\n")
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package fakeinstaller provides a fake installer binary that can be scripted to fail the ways the
+// real one can, to test how the installer subprocesses are handled.
+//
+// The fake installer is the test binary itself: tests call Main from their TestMain so that the
+// binary behaves as the fake installer when it's started as one, and pass Path as the installer
+// binary path.
+package fakeinstaller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"testing"
+	"time"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+// envBehavior holds the behavior of the fake installer, it's inherited by the installer subprocesses
+const envBehavior = "DD_TEST_FAKE_INSTALLER_BEHAVIOR"
+
+// Behavior is the behavior of the fake installer
+type Behavior struct {
+	// Stdout and Stderr are written before the fake installer exits, e.g. partial output
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// Error is reported the way the installer reports its errors, before exiting with ExitCode
+	Error *Error `json:"error,omitempty"`
+	// ExitCode is the exit code of the fake installer
+	ExitCode int `json:"exit_code,omitempty"`
+	// Crash makes the fake installer panic after writing its output
+	Crash bool `json:"crash,omitempty"`
+	// Hang makes the fake installer hang after writing its output, until it's interrupted or killed
+	Hang bool `json:"hang,omitempty"`
+	// IgnoreInterrupt makes a hanging fake installer ignore interrupts, it hangs until it's killed
+	IgnoreInterrupt bool `json:"ignore_interrupt,omitempty"`
+	// Calls is a file to which the fake installer appends its arguments, one call per line
+	Calls string `json:"calls,omitempty"`
+	// Input is a file to which the fake installer copies its standard input
+	Input string `json:"input,omitempty"`
+}
+
+// Error is an error reported by the fake installer
+type Error struct {
+	Message string                             `json:"message"`
+	Code    installerErrors.InstallerErrorCode `json:"code"`
+}
+
+// Path returns the path of the fake installer binary
+func Path(t *testing.T) string {
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("could not get the fake installer path: %v", err)
+	}
+	return path
+}
+
+// Set sets the behavior of the fake installers started by the test
+func Set(t *testing.T, behavior Behavior) {
+	rawBehavior, err := json.Marshal(behavior)
+	if err != nil {
+		t.Fatalf("could not marshal the fake installer behavior: %v", err)
+	}
+	t.Setenv(envBehavior, string(rawBehavior))
+}
+
+// Main runs the fake installer and exits if the binary was started as one. It must be called at
+// the beginning of TestMain.
+func Main() {
+	rawBehavior, ok := os.LookupEnv(envBehavior)
+	if !ok {
+		return
+	}
+	var behavior Behavior
+	err := json.Unmarshal([]byte(rawBehavior), &behavior)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid fake installer behavior: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(run(behavior, os.Args[1:]))
+}
+
+func run(behavior Behavior, args []string) int {
+	if behavior.Calls != "" {
+		f, err := os.OpenFile(behavior.Calls, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not record fake installer call: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(f, strings.Join(args, " "))
+		f.Close()
+	}
+
+	if behavior.Input != "" {
+		input, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = os.WriteFile(behavior.Input, input, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not record fake installer input: %v\n", err)
+			return 1
+		}
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	if behavior.IgnoreInterrupt {
+		signal.Ignore(os.Interrupt)
+	}
+
+	fmt.Fprint(os.Stdout, behavior.Stdout)
+	fmt.Fprint(os.Stderr, behavior.Stderr)
+	if behavior.Crash {
+		panic("fake installer crash")
+	}
+	if behavior.Hang {
+		select {
+		case <-interrupted:
+			fmt.Fprintln(os.Stderr, "fake installer interrupted")
+			return 1
+		case <-time.After(time.Hour):
+		}
+	}
+	if behavior.Error != nil {
+		fmt.Fprintln(os.Stderr, installerErrors.ToJSON(installerErrors.Wrap(behavior.Error.Code, fmt.Errorf("%s", behavior.Error.Message))))
+	}
+	return behavior.ExitCode
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+// Package limits provides the resource limits of the installer subprocesses.
+package limits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var bandwidthPattern = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+
+// systemdAvailable returns true if the host runs systemd and commands can be run in a transient scope
+var systemdAvailable = func() bool {
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("systemd-run")
+	return err == nil
+}
+
+// ScopeArgs returns the systemd-run arguments running a command in a transient scope with
+// the given resource limits, or nil if no limit is set or systemd isn't available.
+//
+// systemd-run executes the command in place once the scope is created, so the command keeps the PID,
+// the environment and the standard streams of the systemd-run process.
+func ScopeArgs(limits env.SubprocessLimits) []string {
+	var properties []string
+	for name, weight := range map[string]int{"CPUWeight": limits.CPUWeight, "IOWeight": limits.IOWeight} {
+		if weight == 0 {
+			continue
+		}
+		if weight < 1 || weight > 10000 {
+			log.Warnf("Ignoring invalid installer subprocess %s %d, it must range from 1 to 10000", name, weight)
+			continue
+		}
+		properties = append(properties, fmt.Sprintf("%s=%d", name, weight))
+	}
+	// IO bandwidths are limited on the device of the packages directory, where the packages are extracted
+	for name, bandwidth := range map[string]string{"IOReadBandwidthMax": limits.IOReadBandwidthMax, "IOWriteBandwidthMax": limits.IOWriteBandwidthMax} {
+		if bandwidth == "" {
+			continue
+		}
+		if !bandwidthPattern.MatchString(bandwidth) {
+			log.Warnf("Ignoring invalid installer subprocess %s %q", name, bandwidth)
+			continue
+		}
+		properties = append(properties, fmt.Sprintf("%s=%s %s", name, installer.PackagesPath, bandwidth))
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	if !systemdAvailable() {
+		log.Warnf("Installer subprocess limits are set but systemd is not available, they are not applied")
+		return nil
+	}
+	sort.Strings(properties)
+
+	args := []string{"--scope", "--quiet", "--collect"}
+	for _, property := range properties {
+		args = append(args, "--property", property)
+	}
+	return args
+}
@@ -0,0 +1,18 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows || darwin
+
+// Package limits provides the resource limits of the installer subprocesses.
+package limits
+
+import (
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+// ScopeArgs returns nil, the subprocess limits rely on systemd
+func ScopeArgs(_ env.SubprocessLimits) []string {
+	return nil
+}
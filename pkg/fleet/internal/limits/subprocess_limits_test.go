@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows && !darwin
+
+package limits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+)
+
+func TestSubprocessScopeArgs(t *testing.T) {
+	oldSystemdAvailable := systemdAvailable
+	defer func() { systemdAvailable = oldSystemdAvailable }()
+	systemdAvailable = func() bool { return true }
+
+	assert.Nil(t, ScopeArgs(env.SubprocessLimits{}))
+
+	args := ScopeArgs(env.SubprocessLimits{
+		CPUWeight:           20,
+		IOWeight:            50,
+		IOWriteBandwidthMax: "50M",
+	})
+	assert.Equal(t, []string{
+		"--scope", "--quiet", "--collect",
+		"--property", "CPUWeight=20",
+		"--property", "IOWeight=50",
+		"--property", "IOWriteBandwidthMax=/opt/datadog-packages 50M",
+	}, args)
+
+	// invalid limits are ignored
+	args = ScopeArgs(env.SubprocessLimits{
+		CPUWeight:          20000,
+		IOReadBandwidthMax: "fast",
+	})
+	assert.Nil(t, args)
+
+	// limits can't be applied without systemd
+	systemdAvailable = func() bool { return false }
+	assert.Nil(t, ScopeArgs(env.SubprocessLimits{CPUWeight: 20}))
+}
@@ -8,14 +8,19 @@ package oci
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	"github.com/docker/docker-credential-helpers/client"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	oci "github.com/google/go-containerregistry/pkg/v1"
@@ -24,9 +29,11 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/time/rate"
 	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/tar"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -38,8 +45,17 @@ const (
 	RegistryAuthGCR string = "gcr"
 	// RegistryAuthECR is the Amazon Elastic Container Registry authentication method.
 	RegistryAuthECR string = "ecr"
+	// RegistryAuthACR is the Azure Container Registry authentication method, through the docker-credential-acr-env helper.
+	RegistryAuthACR string = "acr"
+	// RegistryAuthPassword is the authentication method using the registry username and password, e.g. an Artifactory token.
+	RegistryAuthPassword string = "password"
+	// RegistryAuthHelper is the authentication method using the docker credential helper of the registry credential helper setting.
+	RegistryAuthHelper string = "helper"
 )
 
+// acrCredentialHelper is the docker credential helper used by the ACR authentication method
+const acrCredentialHelper = "acr-env"
+
 const (
 	// AnnotationPackage is the annotiation used to identify the package name.
 	AnnotationPackage = "com.datadoghq.package.name"
@@ -47,6 +63,10 @@ const (
 	AnnotationVersion = "com.datadoghq.package.version"
 	// AnnotationSize is the annotiation used to identify the package size.
 	AnnotationSize = "com.datadoghq.package.size"
+	// AnnotationSmokeTests is the annotation used to declare the smoke tests of the package.
+	// It holds a JSON list of commands, each command being a list of arguments whose first
+	// element is the path of the executable relative to the package root.
+	AnnotationSmokeTests = "com.datadoghq.package.smoke-tests"
 
 	// DatadogPackageLayerMediaType is the media type for the main Datadog Package layer.
 	DatadogPackageLayerMediaType types.MediaType = "application/vnd.datadog.package.layer.v1.tar+zstd"
@@ -64,20 +84,122 @@ type DownloadedPackage struct {
 	Name    string
 	Version string
 	Size    uint64
+
+	// SmokeTests are the commands to run to check the health of the package once started
+	SmokeTests [][]string
+
+	// downloaded counts the bytes received from the registry for this package,
+	// layers are fetched lazily so it keeps growing as they are read
+	downloaded *atomic.Int64
+
+	// delta are the layers served from the layer cache and downloaded, once the package uses it
+	delta DeltaStats
+}
+
+// BytesDownloaded returns the number of bytes received from the registry so far for the package.
+// It is always 0 for packages read from the local filesystem.
+func (d *DownloadedPackage) BytesDownloaded() int64 {
+	if d.downloaded == nil {
+		return 0
+	}
+	return d.downloaded.Load()
+}
+
+// Delta returns the layers of the package served from the layer cache and downloaded. It's empty
+// if the package doesn't go through a layer cache.
+func (d *DownloadedPackage) Delta() DeltaStats {
+	return d.delta
+}
+
+// countingTransport counts the bytes of the response bodies read through it.
+type countingTransport struct {
+	transport http.RoundTripper
+	count     *atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingReader{ReadCloser: resp.Body, count: t.count}
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// throttledTransport limits the bandwidth of the response bodies read through it.
+type throttledTransport struct {
+	transport http.RoundTripper
+	limiter   *rate.Limiter
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &throttledReader{ReadCloser: resp.Body, limiter: t.limiter, ctx: req.Context()}
+	return resp, nil
+}
+
+type throttledReader struct {
+	io.ReadCloser
+	limiter *rate.Limiter
+	ctx     context.Context
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// reads can't be larger than the burst of the limiter
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
 }
 
 // Downloader is the Downloader used by the installer to download packages.
 type Downloader struct {
 	env    *env.Env
 	client *http.Client
+	// limiter limits the bandwidth of the downloads, it's shared by all the downloads of the Downloader
+	limiter *rate.Limiter
+	// partialsDir holds the partially downloaded layers, empty if interrupted downloads aren't resumed
+	partialsDir string
 }
 
 // NewDownloader returns a new Downloader.
 func NewDownloader(env *env.Env, client *http.Client) *Downloader {
-	return &Downloader{
-		env:    env,
-		client: client,
+	return NewResumableDownloader(env, client, "")
+}
+
+// NewResumableDownloader returns a new Downloader keeping the layers it downloads from registries in
+// the given directory until they're complete, so that the downloads interrupted by a flaky link are
+// resumed with range requests instead of restarted.
+func NewResumableDownloader(env *env.Env, client *http.Client, partialsDir string) *Downloader {
+	d := &Downloader{
+		env:         env,
+		client:      client,
+		partialsDir: partialsDir,
 	}
+	if env.MaxDownloadBytesPerSec > 0 {
+		d.limiter = rate.NewLimiter(rate.Limit(env.MaxDownloadBytesPerSec), int(env.MaxDownloadBytesPerSec))
+	}
+	return d
 }
 
 // Download downloads the Datadog Package referenced in the given Package struct.
@@ -88,9 +210,10 @@ func (d *Downloader) Download(ctx context.Context, packageURL string) (*Download
 		return nil, fmt.Errorf("could not parse package URL: %w", err)
 	}
 	var image oci.Image
+	downloaded := &atomic.Int64{}
 	switch url.Scheme {
 	case "oci":
-		image, err = d.downloadRegistry(ctx, strings.TrimPrefix(packageURL, "oci://"))
+		image, err = d.downloadRegistry(ctx, strings.TrimPrefix(packageURL, "oci://"), downloaded)
 	case "file":
 		image, err = d.downloadFile(url.Path)
 	default:
@@ -119,21 +242,86 @@ func (d *Downloader) Download(ctx context.Context, packageURL string) (*Download
 			return nil, fmt.Errorf("could not parse package size: %w", err)
 		}
 	}
+	var smokeTests [][]string
+	rawSmokeTests, ok := manifest.Annotations[AnnotationSmokeTests]
+	if ok {
+		err = json.Unmarshal([]byte(rawSmokeTests), &smokeTests)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse package smoke tests: %w", err)
+		}
+	}
 	log.Debugf("Successfully downloaded package from %s", packageURL)
 	return &DownloadedPackage{
-		Image:   image,
-		Name:    name,
-		Version: version,
-		Size:    size,
+		Image:      image,
+		Name:       name,
+		Version:    version,
+		Size:       size,
+		SmokeTests: smokeTests,
+		downloaded: downloaded,
 	}, nil
 }
 
-func getKeychain(auth string) authn.Keychain {
+// passwordHelper returns the registry username and password of the environment for the registry
+// they're set for, the one of installer.registry.url, so that they're never sent to other registries
+type passwordHelper struct {
+	registry string
+	username string
+	password string
+}
+
+func (h passwordHelper) Get(serverURL string) (string, string, error) {
+	if h.registry == "" || registryHost(serverURL) != h.registry {
+		return "", "", fmt.Errorf("no credentials for registry %s", serverURL)
+	}
+	return h.username, h.password, nil
+}
+
+// registryHost returns the host of a registry URL, e.g. registry.example.com for
+// https://registry.example.com/datadog
+func registryHost(registryURL string) string {
+	if _, rest, ok := strings.Cut(registryURL, "://"); ok {
+		registryURL = rest
+	}
+	host, _, _ := strings.Cut(registryURL, "/")
+	return strings.ToLower(host)
+}
+
+// programHelper gets the credentials of the registries from a docker credential helper, the
+// docker-credential-<name> program found in the PATH
+type programHelper struct {
+	name string
+}
+
+func (h programHelper) Get(serverURL string) (string, string, error) {
+	creds, err := client.Get(client.NewShellProgramFunc("docker-credential-"+h.name), serverURL)
+	if err != nil {
+		// the keychain falls back to anonymous pulls on errors, which would hide them
+		log.Warnf("could not get the credentials of %s from the docker-credential-%s helper: %v", serverURL, h.name, err)
+		return "", "", err
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+func getKeychain(env *env.Env, auth string) authn.Keychain {
 	switch auth {
 	case RegistryAuthGCR:
 		return google.Keychain
 	case RegistryAuthECR:
 		return authn.NewKeychainFromHelper(ecr.NewECRHelper())
+	case RegistryAuthACR:
+		return authn.NewKeychainFromHelper(programHelper{name: acrCredentialHelper})
+	case RegistryAuthPassword:
+		return authn.NewKeychainFromHelper(passwordHelper{
+			registry: registryHost(env.RegistryOverride),
+			username: env.RegistryUsername,
+			password: env.RegistryPassword,
+		})
+	case RegistryAuthHelper:
+		if env.RegistryCredentialHelper == "" {
+			log.Warnf("no registry credential helper set for the %s registry authentication method, defaulting to docker", auth)
+			return authn.DefaultKeychain
+		}
+		return authn.NewKeychainFromHelper(programHelper{name: env.RegistryCredentialHelper})
 	case RegistryAuthDefault, "":
 		return authn.DefaultKeychain
 	default:
@@ -147,17 +335,30 @@ type urlWithKeychain struct {
 	keychain authn.Keychain
 }
 
-// getRefAndKeychain returns the reference and keychain for the given URL.
-// This function applies potential registry and authentication overrides set either globally or per image.
-func getRefAndKeychain(env *env.Env, url string) urlWithKeychain {
-	imageWithIdentifier := url[strings.LastIndex(url, "/")+1:]
-	registryOverride := env.RegistryOverride
+// RegistryOverride returns the registry the package at the given oci:// URL is downloaded from instead
+// of the registry of the URL, empty if it isn't overridden.
+func RegistryOverride(env *env.Env, packageURL string) string {
+	if !strings.HasPrefix(packageURL, "oci://") {
+		return ""
+	}
+	return registryOverrideOf(env, packageURL[strings.LastIndex(packageURL, "/")+1:])
+}
+
+// registryOverrideOf returns the registry override of an image, e.g. agent-package@sha256:...
+func registryOverrideOf(env *env.Env, imageWithIdentifier string) string {
 	for image, override := range env.RegistryOverrideByImage {
 		if strings.HasPrefix(imageWithIdentifier, image+":") || strings.HasPrefix(imageWithIdentifier, image+"@") {
-			registryOverride = override
-			break
+			return override
 		}
 	}
+	return env.RegistryOverride
+}
+
+// getRefAndKeychain returns the reference and keychain for the given URL.
+// This function applies potential registry and authentication overrides set either globally or per image.
+func getRefAndKeychain(env *env.Env, url string) urlWithKeychain {
+	imageWithIdentifier := url[strings.LastIndex(url, "/")+1:]
+	registryOverride := registryOverrideOf(env, imageWithIdentifier)
 	ref := url
 	if registryOverride != "" {
 		if !strings.HasSuffix(registryOverride, "/") {
@@ -165,10 +366,10 @@ func getRefAndKeychain(env *env.Env, url string) urlWithKeychain {
 		}
 		ref = registryOverride + imageWithIdentifier
 	}
-	keychain := getKeychain(env.RegistryAuthOverride)
+	keychain := getKeychain(env, env.RegistryAuthOverride)
 	for image, override := range env.RegistryAuthOverrideByImage {
 		if strings.HasPrefix(imageWithIdentifier, image+":") || strings.HasPrefix(imageWithIdentifier, image+"@") {
-			keychain = getKeychain(override)
+			keychain = getKeychain(env, override)
 			break
 		}
 	}
@@ -178,17 +379,50 @@ func getRefAndKeychain(env *env.Env, url string) urlWithKeychain {
 	}
 }
 
-func (d *Downloader) downloadRegistry(ctx context.Context, url string) (oci.Image, error) {
+func (d *Downloader) downloadRegistry(ctx context.Context, url string, downloaded *atomic.Int64) (oci.Image, error) {
 	refAndKeychain := getRefAndKeychain(d.env, url)
 	ref, err := name.ParseReference(refAndKeychain.ref)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse reference: %w", err)
 	}
-	index, err := remote.Index(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(refAndKeychain.keychain), remote.WithTransport(httptrace.WrapRoundTripper(d.client.Transport)))
+	transport := d.transport(downloaded)
+	index, err := remote.Index(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(refAndKeychain.keychain), remote.WithTransport(transport))
 	if err != nil {
 		return nil, fmt.Errorf("could not download image: %w", err)
 	}
-	return d.downloadIndex(index)
+	image, err := d.downloadIndex(index)
+	if err != nil || d.partialsDir == "" {
+		return image, err
+	}
+	fetcher := &blobFetcher{
+		ctx:       ctx,
+		repo:      ref.Context(),
+		keychain:  refAndKeychain.keychain,
+		transport: transport,
+	}
+	return resumableLayers(image, fetcher, d.partialsDir)
+}
+
+// PrunePartials removes the partially downloaded layers that weren't resumed for longer than the given
+// duration, e.g. the layers of versions that won't be installed anymore.
+func (d *Downloader) PrunePartials(maxAge time.Duration) error {
+	if d.partialsDir == "" {
+		return nil
+	}
+	return prunePartials(d.partialsDir, maxAge)
+}
+
+// transport returns the transport used to reach the registry, counting the bytes received and
+// limiting the bandwidth if configured.
+func (d *Downloader) transport(downloaded *atomic.Int64) http.RoundTripper {
+	transport := d.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if d.limiter != nil {
+		transport = &throttledTransport{transport: transport, limiter: d.limiter}
+	}
+	return httptrace.WrapRoundTripper(&countingTransport{transport: transport, count: downloaded})
 }
 
 func (d *Downloader) downloadFile(path string) (oci.Image, error) {
@@ -227,6 +461,16 @@ func (d *Downloader) downloadIndex(index oci.ImageIndex) (oci.Image, error) {
 
 // ExtractLayers extracts the layers of the downloaded package with the given media type to the given directory.
 func (d *DownloadedPackage) ExtractLayers(mediaType types.MediaType, dir string) error {
+	return d.extractLayers(mediaType, dir, nil)
+}
+
+// ExtractLayersToStore extracts the layers of the downloaded package with the given media type to the given
+// directory, deduplicating their files through the given store.
+func (d *DownloadedPackage) ExtractLayersToStore(mediaType types.MediaType, dir string, store *cas.Store) error {
+	return d.extractLayers(mediaType, dir, store)
+}
+
+func (d *DownloadedPackage) extractLayers(mediaType types.MediaType, dir string, store *cas.Store) error {
 	layers, err := d.Image.Layers()
 	if err != nil {
 		return fmt.Errorf("could not get image layers: %w", err)
@@ -241,7 +485,12 @@ func (d *DownloadedPackage) ExtractLayers(mediaType types.MediaType, dir string)
 			if err != nil {
 				return fmt.Errorf("could not uncompress layer: %w", err)
 			}
-			err = tar.Extract(uncompressedLayer, dir, layerMaxSize)
+			if store != nil {
+				err = tar.ExtractToStore(uncompressedLayer, dir, layerMaxSize, store)
+			} else {
+				err = tar.Extract(uncompressedLayer, dir, layerMaxSize)
+			}
+			uncompressedLayer.Close()
 			if err != nil {
 				return fmt.Errorf("could not extract layer: %w", err)
 			}
@@ -250,6 +499,36 @@ func (d *DownloadedPackage) ExtractLayers(mediaType types.MediaType, dir string)
 	return nil
 }
 
+// CheckLayers reads the layers of the downloaded package with the given media type without extracting them
+// and returns the number of entries they would create in the given directory.
+// It returns a *tar.PathTooLongError if an entry would exceed the path length limits of the platform.
+func (d *DownloadedPackage) CheckLayers(mediaType types.MediaType, dir string) (uint64, error) {
+	layers, err := d.Image.Layers()
+	if err != nil {
+		return 0, fmt.Errorf("could not get image layers: %w", err)
+	}
+	var entries uint64
+	for _, layer := range layers {
+		layerMediaType, err := layer.MediaType()
+		if err != nil {
+			return 0, fmt.Errorf("could not get layer media type: %w", err)
+		}
+		if layerMediaType == mediaType {
+			uncompressedLayer, err := layer.Uncompressed()
+			if err != nil {
+				return 0, fmt.Errorf("could not uncompress layer: %w", err)
+			}
+			layerEntries, err := tar.Check(uncompressedLayer, dir, layerMaxSize)
+			uncompressedLayer.Close()
+			if err != nil {
+				return 0, fmt.Errorf("could not check layer: %w", err)
+			}
+			entries += layerEntries
+		}
+	}
+	return entries, nil
+}
+
 // WriteOCILayout writes the image as an OCI layout to the given directory.
 func (d *DownloadedPackage) WriteOCILayout(dir string) error {
 	layoutPath, err := layout.Write(dir, empty.Index)
@@ -272,3 +551,14 @@ func PackageURL(env *env.Env, pkg string, version string) string {
 		return fmt.Sprintf("oci://gcr.io/datadoghq/%s-package:%s", strings.TrimPrefix(pkg, "datadog-"), version)
 	}
 }
+
+// PackageDigestURL returns the URL of a package image of the default registry of the site, pinned to
+// its digest. The registry overrides of the environment still apply when it's downloaded.
+func PackageDigestURL(env *env.Env, image string, digest string) string {
+	switch env.Site {
+	case "datad0g.com":
+		return fmt.Sprintf("oci://docker.io/datadog/%s@%s", image, digest)
+	default:
+		return fmt.Sprintf("oci://gcr.io/datadoghq/%s@%s", image, digest)
+	}
+}
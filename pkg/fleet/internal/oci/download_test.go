@@ -9,16 +9,23 @@
 package oci
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 
 	"github.com/DataDog/datadog-agent/pkg/fleet/env"
 	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	oci "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/google"
 )
@@ -58,10 +65,56 @@ func TestDownload(t *testing.T) {
 	assert.Equal(t, fixtures.FixtureSimpleV1.Package, downloadedPackage.Name)
 	assert.Equal(t, fixtures.FixtureSimpleV1.Version, downloadedPackage.Version)
 	assert.NotZero(t, downloadedPackage.Size)
+	manifestBytes := downloadedPackage.BytesDownloaded()
+	assert.NotZero(t, manifestBytes)
 	tmpDir := t.TempDir()
 	err = downloadedPackage.ExtractLayers(DatadogPackageLayerMediaType, tmpDir)
 	assert.NoError(t, err)
 	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+	// Layers are fetched when extracted
+	assert.Greater(t, downloadedPackage.BytesDownloaded(), manifestBytes)
+}
+
+func TestDownloadThrottled(t *testing.T) {
+	s := newTestDownloadServer(t)
+	d := s.DownloaderWithEnv(&env.Env{MaxDownloadBytesPerSec: 1 << 20})
+	assert.NotNil(t, d.limiter)
+
+	downloadedPackage, err := d.Download(context.Background(), s.PackageURL(fixtures.FixtureSimpleV1))
+	assert.NoError(t, err)
+	tmpDir := t.TempDir()
+	err = downloadedPackage.ExtractLayers(DatadogPackageLayerMediaType, tmpDir)
+	assert.NoError(t, err)
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+}
+
+func TestThrottledReader(t *testing.T) {
+	limiter := rate.NewLimiter(1000, 1000)
+	r := &throttledReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(make([]byte, 1500))),
+		limiter:    limiter,
+		ctx:        context.Background(),
+	}
+
+	start := time.Now()
+	n, err := r.Read(make([]byte, 4096))
+	assert.NoError(t, err)
+	// reads are capped to the burst of the limiter
+	assert.Equal(t, 1000, n)
+	content, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, content, 500)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = &throttledReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(make([]byte, 1500))),
+		limiter:    rate.NewLimiter(1000, 1000),
+		ctx:        ctx,
+	}
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, context.Canceled)
 }
 
 func TestDownloadLayout(t *testing.T) {
@@ -77,6 +130,7 @@ func TestDownloadLayout(t *testing.T) {
 	err = downloadedPackage.ExtractLayers(DatadogPackageLayerMediaType, tmpDir)
 	assert.NoError(t, err)
 	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+	assert.Zero(t, downloadedPackage.BytesDownloaded())
 }
 
 func TestDownloadInvalidHash(t *testing.T) {
@@ -175,6 +229,26 @@ func TestGetRefAndKeychain(t *testing.T) {
 			expectedRef:            "gcr.io/datadoghq/agent-package-dev@sha256:1234",
 			expectedKeychain:       google.Keychain,
 		},
+		{
+			url:                  "gcr.io/datadoghq/agent-package-dev@sha256:1234",
+			registryOverride:     "mirror.azurecr.io",
+			registryAuthOverride: "acr",
+			expectedRef:          "mirror.azurecr.io/agent-package-dev@sha256:1234",
+			expectedKeychain:     authn.NewKeychainFromHelper(programHelper{name: "acr-env"}),
+		},
+		{
+			url:                  "gcr.io/datadoghq/agent-package-dev@sha256:1234",
+			registryOverride:     "artifactory.example.com/datadog",
+			registryAuthOverride: "password",
+			expectedRef:          "artifactory.example.com/datadog/agent-package-dev@sha256:1234",
+			expectedKeychain:     authn.NewKeychainFromHelper(passwordHelper{registry: "artifactory.example.com", username: "user", password: "token"}),
+		},
+		{
+			url:                    "gcr.io/datadoghq/agent-package-dev@sha256:1234",
+			regAuthOverrideByImage: map[string]string{"agent-package-dev": "helper"},
+			expectedRef:            "gcr.io/datadoghq/agent-package-dev@sha256:1234",
+			expectedKeychain:       authn.NewKeychainFromHelper(programHelper{name: "artifactory"}),
+		},
 	}
 
 	for _, tt := range tests {
@@ -183,6 +257,9 @@ func TestGetRefAndKeychain(t *testing.T) {
 			RegistryOverrideByImage:     tt.regOverrideByImage,
 			RegistryAuthOverride:        tt.registryAuthOverride,
 			RegistryAuthOverrideByImage: tt.regAuthOverrideByImage,
+			RegistryUsername:            "user",
+			RegistryPassword:            "token",
+			RegistryCredentialHelper:    "artifactory",
 		}
 		actual := getRefAndKeychain(env, tt.url)
 		assert.Equal(t, tt.expectedRef, actual.ref)
@@ -190,6 +267,54 @@ func TestGetRefAndKeychain(t *testing.T) {
 	}
 }
 
+func TestPasswordHelper(t *testing.T) {
+	helper := passwordHelper{registry: "registry.example.com", username: "user", password: "token"}
+	username, password, err := helper.Get("registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "token", password)
+
+	// the credentials are only sent to the registry of installer.registry.url
+	for _, server := range []string{"gcr.io", "registry.example.com.evil.com", "evil.com/registry.example.com"} {
+		_, _, err = helper.Get(server)
+		assert.Error(t, err, server)
+	}
+	_, _, err = passwordHelper{username: "user", password: "token"}.Get("registry.example.com")
+	assert.Error(t, err)
+}
+
+func TestProgramHelper(t *testing.T) {
+	dir := t.TempDir()
+	helper := `#!/bin/sh
+read server
+if [ "$1" = "get" ] && [ "$server" = "registry.example.com" ]; then
+	echo '{"ServerURL":"registry.example.com","Username":"user","Secret":"token"}'
+else
+	echo "credentials not found in native keychain"
+	exit 1
+fi
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-credential-test"), []byte(helper), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	keychain := getKeychain(&env.Env{RegistryCredentialHelper: "test"}, RegistryAuthHelper)
+	ref, err := name.ParseReference("registry.example.com/agent-package:latest")
+	require.NoError(t, err)
+	authenticator, err := keychain.Resolve(ref.Context())
+	require.NoError(t, err)
+	auth, err := authenticator.Authorization()
+	require.NoError(t, err)
+	assert.Equal(t, "user", auth.Username)
+	assert.Equal(t, "token", auth.Password)
+
+	// registries unknown to the helper are pulled anonymously
+	ref, err = name.ParseReference("other.example.com/agent-package:latest")
+	require.NoError(t, err)
+	authenticator, err = keychain.Resolve(ref.Context())
+	require.NoError(t, err)
+	assert.Equal(t, authn.Anonymous, authenticator)
+}
+
 func TestPackageURL(t *testing.T) {
 	type test struct {
 		site     string
@@ -0,0 +1,364 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	oci "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	layerCacheBlobsDir = "blobs"
+	layerCacheRefsDir  = "refs"
+)
+
+// LayerCache keeps the compressed layers of the installed packages on disk, so that the layers a new
+// version shares with the installed one are reused instead of downloaded again. Layers are stored by
+// digest and referenced by the package versions using them, the layers no version references anymore
+// are removed by Prune.
+type LayerCache struct {
+	rootPath string
+}
+
+// NewLayerCache returns a new LayerCache rooted at the given path. The directory is created on the
+// first write.
+func NewLayerCache(rootPath string) *LayerCache {
+	return &LayerCache{
+		rootPath: rootPath,
+	}
+}
+
+func (c *LayerCache) blobPath(digest oci.Hash) string {
+	return filepath.Join(c.rootPath, layerCacheBlobsDir, digest.Algorithm, digest.Hex)
+}
+
+func (c *LayerCache) refPath(pkg string, version string) string {
+	return filepath.Join(c.rootPath, layerCacheRefsDir, pkg, version)
+}
+
+func (c *LayerCache) has(digest oci.Hash) bool {
+	_, err := os.Stat(c.blobPath(digest))
+	return err == nil
+}
+
+// DeltaStats are the layers of a package served from the cache and downloaded.
+type DeltaStats struct {
+	ReusedLayers     int
+	ReusedBytes      int64
+	DownloadedLayers int
+	DownloadedBytes  int64
+}
+
+// Use makes the layers of the package go through the cache: they are written to the cache as they
+// are downloaded, and the layers shared with the base package are read from the cache if it holds
+// them. The base is the package installed on the host the new version replaces, nil if unknown.
+func (c *LayerCache) Use(pkg *DownloadedPackage, base *DownloadedPackage) error {
+	reusable := make(map[oci.Hash]struct{})
+	if base != nil {
+		baseLayers, err := base.Image.Layers()
+		if err != nil {
+			return fmt.Errorf("could not get base image layers: %w", err)
+		}
+		for _, layer := range baseLayers {
+			digest, err := layer.Digest()
+			if err != nil {
+				return fmt.Errorf("could not get base layer digest: %w", err)
+			}
+			reusable[digest] = struct{}{}
+		}
+	}
+	layers, err := pkg.Image.Layers()
+	if err != nil {
+		return fmt.Errorf("could not get image layers: %w", err)
+	}
+	image := &wrappedImage{Image: pkg.Image}
+	for _, layer := range layers {
+		cached := &cachedLayer{layer: layer, cache: c}
+		if cached.digest, err = layer.Digest(); err != nil {
+			return fmt.Errorf("could not get layer digest: %w", err)
+		}
+		if cached.size, err = layer.Size(); err != nil {
+			return fmt.Errorf("could not get layer size: %w", err)
+		}
+		if cached.mediaType, err = layer.MediaType(); err != nil {
+			return fmt.Errorf("could not get layer media type: %w", err)
+		}
+		_, cached.reusable = reusable[cached.digest]
+		if cached.reusable && c.has(cached.digest) {
+			pkg.delta.ReusedLayers++
+			pkg.delta.ReusedBytes += cached.size
+		} else {
+			pkg.delta.DownloadedLayers++
+			pkg.delta.DownloadedBytes += cached.size
+		}
+		wrapped, err := partial.CompressedToLayer(cached)
+		if err != nil {
+			return fmt.Errorf("could not wrap layer: %w", err)
+		}
+		image.layers = append(image.layers, wrapped)
+	}
+	pkg.Image = image
+	return nil
+}
+
+// Retain references the layers of an installed package version so that they're kept by Prune.
+func (c *LayerCache) Retain(pkg *DownloadedPackage) error {
+	layers, err := pkg.Image.Layers()
+	if err != nil {
+		return fmt.Errorf("could not get image layers: %w", err)
+	}
+	var digests []string
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return fmt.Errorf("could not get layer digest: %w", err)
+		}
+		digests = append(digests, digest.String())
+	}
+	rawDigests, err := json.Marshal(digests)
+	if err != nil {
+		return fmt.Errorf("could not marshal layer digests: %w", err)
+	}
+	refPath := c.refPath(pkg.Name, pkg.Version)
+	err = os.MkdirAll(filepath.Dir(refPath), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create refs directory: %w", err)
+	}
+	return os.WriteFile(refPath, rawDigests, 0644)
+}
+
+// Prune removes the references of the package versions that aren't kept, given by package, and
+// the layers no version references anymore.
+func (c *LayerCache) Prune(keep map[string][]string) error {
+	referenced := make(map[string]struct{})
+	refsPath := filepath.Join(c.rootPath, layerCacheRefsDir)
+	pkgs, err := os.ReadDir(refsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read refs directory: %w", err)
+	}
+	for _, pkg := range pkgs {
+		versions, err := os.ReadDir(filepath.Join(refsPath, pkg.Name()))
+		if err != nil {
+			return fmt.Errorf("could not read refs of package %s: %w", pkg.Name(), err)
+		}
+		for _, version := range versions {
+			refPath := c.refPath(pkg.Name(), version.Name())
+			if !slices.Contains(keep[pkg.Name()], version.Name()) {
+				if err := os.Remove(refPath); err != nil {
+					return fmt.Errorf("could not remove ref: %w", err)
+				}
+				continue
+			}
+			rawDigests, err := os.ReadFile(refPath)
+			if err != nil {
+				return fmt.Errorf("could not read ref: %w", err)
+			}
+			var digests []string
+			if err := json.Unmarshal(rawDigests, &digests); err != nil {
+				return fmt.Errorf("could not unmarshal ref: %w", err)
+			}
+			for _, digest := range digests {
+				referenced[digest] = struct{}{}
+			}
+		}
+	}
+	blobsPath := filepath.Join(c.rootPath, layerCacheBlobsDir)
+	algorithms, err := os.ReadDir(blobsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read blobs directory: %w", err)
+	}
+	for _, algorithm := range algorithms {
+		blobs, err := os.ReadDir(filepath.Join(blobsPath, algorithm.Name()))
+		if err != nil {
+			return fmt.Errorf("could not read blobs: %w", err)
+		}
+		for _, blob := range blobs {
+			if _, ok := referenced[algorithm.Name()+":"+blob.Name()]; ok {
+				continue
+			}
+			log.Debugf("removing unused cached layer %s:%s", algorithm.Name(), blob.Name())
+			if err := os.Remove(filepath.Join(blobsPath, algorithm.Name(), blob.Name())); err != nil {
+				return fmt.Errorf("could not remove cached layer: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// wrappedImage is an image whose layers are wrapped, to go through the layer cache or resume their
+// interrupted downloads.
+type wrappedImage struct {
+	oci.Image
+	layers []oci.Layer
+}
+
+func (i *wrappedImage) Layers() ([]oci.Layer, error) {
+	return i.layers, nil
+}
+
+func (i *wrappedImage) LayerByDigest(digest oci.Hash) (oci.Layer, error) {
+	for _, layer := range i.layers {
+		if layerDigest, err := layer.Digest(); err == nil && layerDigest == digest {
+			return layer, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not found", digest)
+}
+
+// cachedLayer reads a layer from the cache when it's reusable or was already downloaded, and writes it
+// to the cache while downloading it otherwise.
+type cachedLayer struct {
+	layer     oci.Layer
+	cache     *LayerCache
+	digest    oci.Hash
+	size      int64
+	mediaType types.MediaType
+
+	m        sync.Mutex
+	reusable bool
+}
+
+func (l *cachedLayer) Digest() (oci.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *cachedLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+func (l *cachedLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+func (l *cachedLayer) Compressed() (io.ReadCloser, error) {
+	l.m.Lock()
+	reusable := l.reusable
+	l.m.Unlock()
+	if reusable {
+		f, err := os.Open(l.cache.blobPath(l.digest))
+		if err == nil {
+			return newVerifyingReader(f, l.digest), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("could not open cached layer: %w", err)
+		}
+	}
+	rc, err := l.layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	blobPath := l.cache.blobPath(l.digest)
+	err = os.MkdirAll(filepath.Dir(blobPath), 0755)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("could not create blobs directory: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(blobPath), l.digest.Hex+".tmp")
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("could not create temporary file: %w", err)
+	}
+	return &cachingReader{
+		ReadCloser: rc,
+		tmpFile:    tmpFile,
+		blobPath:   blobPath,
+		size:       l.size,
+		onCached: func() {
+			l.m.Lock()
+			defer l.m.Unlock()
+			// the layer was verified by the registry client, it can be read from the cache from now on
+			l.reusable = true
+		},
+	}, nil
+}
+
+// cachingReader writes a downloaded layer to a temporary file, moved to the cache once the layer is
+// fully read. Failing to cache a layer doesn't fail its download.
+type cachingReader struct {
+	io.ReadCloser
+	tmpFile  *os.File
+	blobPath string
+	size     int64
+	written  int64
+	failed   bool
+	onCached func()
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.failed {
+		if _, writeErr := r.tmpFile.Write(p[:n]); writeErr != nil {
+			log.Warnf("could not cache layer: %v", writeErr)
+			r.failed = true
+		}
+		r.written += int64(n)
+	}
+	// the registry client fails the read of a layer not matching its digest
+	if err != nil && err != io.EOF {
+		r.failed = true
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	// the end of the compressed stream may be left unread by the decompression
+	if !r.failed && r.written < r.size {
+		_, _ = io.Copy(io.Discard, r)
+	}
+	err := r.ReadCloser.Close()
+	closeErr := r.tmpFile.Close()
+	// the remote layer is verified once fully read, partially read layers aren't cached
+	if err == nil && closeErr == nil && !r.failed && r.written == r.size {
+		if renameErr := os.Rename(r.tmpFile.Name(), r.blobPath); renameErr == nil {
+			r.onCached()
+			return nil
+		}
+	}
+	os.Remove(r.tmpFile.Name())
+	return err
+}
+
+// verifyingReader checks the digest of a cached layer once fully read, so that a corrupted cache
+// fails the extraction instead of installing altered files.
+type verifyingReader struct {
+	f      *os.File
+	digest oci.Hash
+	hasher hash.Hash
+}
+
+func newVerifyingReader(f *os.File, digest oci.Hash) *verifyingReader {
+	return &verifyingReader{f: f, digest: digest, hasher: sha256.New()}
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	r.hasher.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(r.hasher.Sum(nil)); r.digest.Algorithm != "sha256" || got != r.digest.Hex {
+			return n, fmt.Errorf("cached layer %s is corrupted: got sha256:%s", r.digest, got)
+		}
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
+)
+
+func (s *testDownloadServer) downloadThroughCache(t *testing.T, cache *LayerCache, f fixtures.Fixture, base *fixtures.Fixture) *DownloadedPackage {
+	pkg, err := s.Downloader().Download(context.Background(), s.PackageURL(f))
+	require.NoError(t, err)
+	var basePkg *DownloadedPackage
+	if base != nil {
+		basePkg, err = s.Downloader().Download(context.Background(), s.PackageURL(*base))
+		require.NoError(t, err)
+	}
+	require.NoError(t, cache.Use(pkg, basePkg))
+	return pkg
+}
+
+func TestLayerCacheReusesBaseLayers(t *testing.T) {
+	s := newTestDownloadServer(t)
+	cache := NewLayerCache(t.TempDir())
+
+	// the layers are cached as they're downloaded
+	pkg := s.downloadThroughCache(t, cache, fixtures.FixtureSimpleV1, nil)
+	assert.Zero(t, pkg.Delta().ReusedLayers)
+	assert.NotZero(t, pkg.Delta().DownloadedLayers)
+	tmpDir := t.TempDir()
+	require.NoError(t, pkg.ExtractLayers(DatadogPackageLayerMediaType, tmpDir))
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+	require.NoError(t, pkg.ExtractLayers(DatadogPackageConfigLayerMediaType, t.TempDir()))
+	require.NoError(t, cache.Retain(pkg))
+
+	// the layers shared with the base are read from the cache
+	pkg = s.downloadThroughCache(t, cache, fixtures.FixtureSimpleV1, &fixtures.FixtureSimpleV1)
+	assert.Zero(t, pkg.Delta().DownloadedLayers)
+	assert.NotZero(t, pkg.Delta().ReusedBytes)
+	manifestBytes := pkg.BytesDownloaded()
+	tmpDir = t.TempDir()
+	require.NoError(t, pkg.ExtractLayers(DatadogPackageLayerMediaType, tmpDir))
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+	assert.Equal(t, manifestBytes, pkg.BytesDownloaded())
+}
+
+func TestLayerCacheCorruptedLayer(t *testing.T) {
+	s := newTestDownloadServer(t)
+	cache := NewLayerCache(t.TempDir())
+	pkg := s.downloadThroughCache(t, cache, fixtures.FixtureSimpleV1, nil)
+	require.NoError(t, pkg.ExtractLayers(DatadogPackageLayerMediaType, t.TempDir()))
+
+	layers, err := pkg.Image.Layers()
+	require.NoError(t, err)
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		require.NoError(t, err)
+		if cache.has(digest) {
+			require.NoError(t, os.WriteFile(cache.blobPath(digest), []byte("corrupted"), 0644))
+		}
+	}
+	pkg = s.downloadThroughCache(t, cache, fixtures.FixtureSimpleV1, &fixtures.FixtureSimpleV1)
+	err = pkg.ExtractLayers(DatadogPackageLayerMediaType, t.TempDir())
+	assert.ErrorContains(t, err, "is corrupted")
+}
+
+func TestLayerCachePrune(t *testing.T) {
+	s := newTestDownloadServer(t)
+	root := t.TempDir()
+	cache := NewLayerCache(root)
+	for _, f := range []fixtures.Fixture{fixtures.FixtureSimpleV1, fixtures.FixtureSimpleV2} {
+		pkg := s.downloadThroughCache(t, cache, f, nil)
+		require.NoError(t, pkg.ExtractLayers(DatadogPackageLayerMediaType, t.TempDir()))
+		require.NoError(t, pkg.ExtractLayers(DatadogPackageConfigLayerMediaType, t.TempDir()))
+		require.NoError(t, cache.Retain(pkg))
+	}
+	blobs := func() []string {
+		matches, err := filepath.Glob(filepath.Join(root, layerCacheBlobsDir, "*", "*"))
+		require.NoError(t, err)
+		return matches
+	}
+	all := blobs()
+	require.NotEmpty(t, all)
+
+	require.NoError(t, cache.Prune(map[string][]string{"simple": {"v2"}}))
+	kept := blobs()
+	assert.NotEmpty(t, kept)
+	assert.Less(t, len(kept), len(all))
+	assert.NoFileExists(t, cache.refPath("simple", "v1"))
+	assert.FileExists(t, cache.refPath("simple", "v2"))
+
+	require.NoError(t, cache.Prune(nil))
+	assert.Empty(t, blobs())
+}
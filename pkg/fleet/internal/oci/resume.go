@@ -0,0 +1,411 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	oci "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// errRangeNotSatisfiable is returned when the registry can't serve a blob from the requested offset
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// closeDrainMaxSize is the most a layer closed before it's fully read is drained of, to verify it: the
+// decompression may leave the end of the compressed stream unread. The layers closed earlier were
+// abandoned by their reader, their download is kept to be resumed.
+const closeDrainMaxSize = 64 << 10
+
+// blobFetcher fetches the blobs of a repository, from an offset if the registry supports range requests.
+type blobFetcher struct {
+	ctx        context.Context
+	repo       name.Repository
+	keychain   authn.Keychain
+	transport  http.RoundTripper
+	clientOnce sync.Once
+	client     *http.Client
+	clientErr  error
+}
+
+// httpClient returns the client authenticated to the registry, it's created on the first fetch as
+// the layers may all be served from the layer cache.
+func (f *blobFetcher) httpClient() (*http.Client, error) {
+	f.clientOnce.Do(func() {
+		auth, err := f.keychain.Resolve(f.repo)
+		if err != nil {
+			f.clientErr = fmt.Errorf("could not resolve registry credentials: %w", err)
+			return
+		}
+		rt, err := transport.NewWithContext(f.ctx, f.repo.Registry, auth, f.transport, []string{f.repo.Scope(transport.PullScope)})
+		if err != nil {
+			f.clientErr = fmt.Errorf("could not create registry transport: %w", err)
+			return
+		}
+		f.client = &http.Client{Transport: rt}
+	})
+	return f.client, f.clientErr
+}
+
+// fetch returns the blob from the given offset and the offset its body actually starts at, 0 if the
+// registry ignored the range.
+func (f *blobFetcher) fetch(digest oci.Hash, offset int64) (io.ReadCloser, int64, error) {
+	client, err := f.httpClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	u := url.URL{
+		Scheme: f.repo.Registry.Scheme(),
+		Host:   f.repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/blobs/%s", f.repo.RepositoryStr(), digest),
+	}
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not fetch blob %s: %w", digest, err)
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		return nil, 0, errRangeNotSatisfiable
+	}
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("could not fetch blob %s: %w", digest, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, 0, nil
+	}
+	start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+	if err != nil || start != offset {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unexpected content range %q for blob %s from offset %d", resp.Header.Get("Content-Range"), digest, offset)
+	}
+	return resp.Body, start, nil
+}
+
+// contentRangeStart returns the first byte of a Content-Range header, e.g. 100 for "bytes 100-999/1000".
+func contentRangeStart(contentRange string) (int64, error) {
+	rangeSpec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("unsupported content range unit")
+	}
+	start, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid content range")
+	}
+	return strconv.ParseInt(start, 10, 64)
+}
+
+// resumableLayers wraps the layers of a remote image so that their downloads are written to partial
+// files, resumed from where they stopped by the next downloads if interrupted.
+func resumableLayers(image oci.Image, fetcher *blobFetcher, partialsDir string) (oci.Image, error) {
+	manifest, err := image.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("could not get image manifest: %w", err)
+	}
+	wrapped := &wrappedImage{Image: image}
+	for _, descriptor := range manifest.Layers {
+		layer, err := partial.CompressedToLayer(&resumableLayer{
+			fetcher:     fetcher,
+			partialPath: filepath.Join(partialsDir, descriptor.Digest.Algorithm, descriptor.Digest.Hex),
+			digest:      descriptor.Digest,
+			size:        descriptor.Size,
+			mediaType:   descriptor.MediaType,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not wrap layer: %w", err)
+		}
+		wrapped.layers = append(wrapped.layers, layer)
+	}
+	return wrapped, nil
+}
+
+// resumableLayer is a remote layer whose download resumes from its partial file.
+type resumableLayer struct {
+	fetcher     *blobFetcher
+	partialPath string
+	digest      oci.Hash
+	size        int64
+	mediaType   types.MediaType
+}
+
+func (l *resumableLayer) Digest() (oci.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *resumableLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+func (l *resumableLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+func (l *resumableLayer) Compressed() (io.ReadCloser, error) {
+	err := os.MkdirAll(filepath.Dir(l.partialPath), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create partial downloads directory: %w", err)
+	}
+	f, err := os.OpenFile(l.partialPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open partial layer: %w", err)
+	}
+	locked, err := tryLockPartial(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock partial layer: %w", err)
+	}
+	if !locked {
+		f.Close()
+		log.Infof("layer %s is being downloaded by another process, downloading it without resuming", l.digest)
+		return l.compressedWithoutPartial()
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not stat partial layer: %w", err)
+	}
+	offset := info.Size()
+	if offset > l.size {
+		offset = 0
+	}
+	var body io.ReadCloser
+	if offset < l.size {
+		var start int64
+		body, start, err = l.fetcher.fetch(l.digest, offset)
+		if errors.Is(err, errRangeNotSatisfiable) {
+			body, start, err = l.fetcher.fetch(l.digest, 0)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if offset > 0 && start == offset {
+			log.Infof("resuming download of layer %s from byte %d of %d", l.digest, offset, l.size)
+		}
+		offset = start
+	}
+	// the partial file is truncated to the offset the download resumes from, dropping what the
+	// registry serves again
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		if body != nil {
+			body.Close()
+		}
+		return nil, fmt.Errorf("could not truncate partial layer: %w", err)
+	}
+	return &resumingReader{
+		f:      f,
+		body:   body,
+		onDisk: offset,
+		digest: l.digest,
+		size:   l.size,
+		hasher: sha256.New(),
+	}, nil
+}
+
+// compressedWithoutPartial downloads the layer from scratch to a temporary file removed once the layer
+// is read, for when the partial file is in use by another download.
+func (l *resumableLayer) compressedWithoutPartial() (io.ReadCloser, error) {
+	f, err := os.CreateTemp(filepath.Dir(l.partialPath), filepath.Base(l.partialPath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary layer: %w", err)
+	}
+	body, _, err := l.fetcher.fetch(l.digest, 0)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &resumingReader{
+		f:       f,
+		body:    body,
+		digest:  l.digest,
+		size:    l.size,
+		hasher:  sha256.New(),
+		discard: true,
+	}, nil
+}
+
+// resumingReader reads the partial file of a layer then the rest of the layer from the registry,
+// appending it to the partial file. The whole layer is verified against its digest once read: the
+// partial file is removed once the layer is verified or found corrupted, and kept for the next
+// download otherwise.
+type resumingReader struct {
+	f      *os.File
+	body   io.ReadCloser
+	onDisk int64
+	digest oci.Hash
+	size   int64
+	hasher hash.Hash
+	read   int64
+
+	// discard is set once the partial file can't be resumed from
+	discard bool
+	done    bool
+	err     error
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.read < r.onDisk {
+		if remaining := r.onDisk - r.read; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err := r.f.Read(p)
+		r.hasher.Write(p[:n])
+		r.read += int64(n)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			r.err = fmt.Errorf("could not read partial layer: %w", err)
+			return n, r.err
+		}
+		return n, nil
+	}
+	var n int
+	var err error
+	if r.body != nil {
+		n, err = r.body.Read(p)
+	} else {
+		err = io.EOF
+	}
+	if n > 0 {
+		r.hasher.Write(p[:n])
+		r.read += int64(n)
+		if !r.discard {
+			if _, writeErr := r.f.Write(p[:n]); writeErr != nil {
+				// failing to keep the partial layer doesn't fail its download
+				log.Warnf("could not write partial layer: %v", writeErr)
+				r.discard = true
+			}
+		}
+	}
+	if r.read > r.size {
+		r.err = r.corrupted()
+		return n, r.err
+	}
+	if err == io.EOF {
+		if r.read < r.size {
+			r.err = io.ErrUnexpectedEOF
+			return n, r.err
+		}
+		if got := hex.EncodeToString(r.hasher.Sum(nil)); r.digest.Algorithm != "sha256" || got != r.digest.Hex {
+			r.err = r.corrupted()
+			return n, r.err
+		}
+		r.done = true
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// corrupted discards the partial layer not matching the digest of the layer, so that the next
+// download starts from scratch.
+func (r *resumingReader) corrupted() error {
+	r.discard = true
+	if err := r.f.Truncate(0); err != nil {
+		log.Warnf("could not discard partial layer: %v", err)
+	}
+	return fmt.Errorf("downloaded layer %s is corrupted: got sha256:%s", r.digest, hex.EncodeToString(r.hasher.Sum(nil)))
+}
+
+func (r *resumingReader) Close() error {
+	// the end of the compressed stream may be left unread by the decompression, the layers abandoned
+	// earlier by their reader aren't downloaded to the end
+	if !r.done && r.err == nil && r.size-r.read <= closeDrainMaxSize {
+		_, _ = io.Copy(io.Discard, r)
+	}
+	var err error
+	if r.body != nil {
+		err = r.body.Close()
+	}
+	// the partial layer is removed while it's still locked, so that no other download resumes from it
+	if r.done || r.discard {
+		os.Remove(r.f.Name())
+	}
+	closeErr := r.f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// prunePartials removes the partial layers that weren't written to for longer than the given duration.
+func prunePartials(partialsDir string, maxAge time.Duration) error {
+	algorithms, err := os.ReadDir(partialsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read partial downloads directory: %w", err)
+	}
+	for _, algorithm := range algorithms {
+		partials, err := os.ReadDir(filepath.Join(partialsDir, algorithm.Name()))
+		if err != nil {
+			return fmt.Errorf("could not read partial downloads: %w", err)
+		}
+		for _, entry := range partials {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < maxAge {
+				continue
+			}
+			if err := removeStalePartial(filepath.Join(partialsDir, algorithm.Name(), entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeStalePartial removes a stale partial layer, unless it's being resumed by a download.
+func removeStalePartial(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("could not open partial layer: %w", err)
+	}
+	defer f.Close()
+	locked, err := tryLockPartial(f)
+	if err != nil || !locked {
+		return nil
+	}
+	log.Debugf("removing stale partial layer %s", path)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("could not remove partial layer: %w", err)
+	}
+	return nil
+}
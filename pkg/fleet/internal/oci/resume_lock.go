@@ -0,0 +1,25 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package oci
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockPartial takes an exclusive lock on a partial layer so that concurrent downloads of the same
+// layer, e.g. by the daemon and the installer command, don't write to it at the same time. It returns
+// false if the partial layer is locked by another download. The lock is released when the file is closed.
+func tryLockPartial(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,18 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package oci
+
+import (
+	"os"
+)
+
+// tryLockPartial doesn't lock the partial layers on Windows, where the installer doesn't download
+// packages concurrently
+func tryLockPartial(_ *os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,282 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// for now the installer is not supported on windows
+//go:build !windows
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/env"
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/fixtures"
+)
+
+// flakyTransport interrupts the downloads of the blobs after a number of bytes, and serves the
+// range requests the test registry doesn't support if enabled.
+type flakyTransport struct {
+	transport      http.RoundTripper
+	supportsRange  bool
+	interruptAfter int64
+
+	m             sync.Mutex
+	interruptions int
+	ranges        []string
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !strings.Contains(req.URL.Path, "/blobs/") {
+		return t.transport.RoundTrip(req)
+	}
+	rangeHeader := req.Header.Get("Range")
+	if rangeHeader != "" {
+		req = req.Clone(req.Context())
+		req.Header.Del("Range")
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	if rangeHeader != "" && t.supportsRange {
+		t.ranges = append(t.ranges, rangeHeader)
+		var start int64
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			return nil, err
+		}
+		resp.StatusCode = http.StatusPartialContent
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, resp.ContentLength-1, resp.ContentLength))
+		resp.ContentLength -= start
+	}
+	if t.interruptions > 0 {
+		t.interruptions--
+		resp.Body = &interruptedReader{ReadCloser: resp.Body, remaining: t.interruptAfter}
+	}
+	return resp, nil
+}
+
+type interruptedReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (s *testDownloadServer) flakyDownloader(partialsDir string, transport *flakyTransport) *Downloader {
+	transport.transport = s.Client().Transport
+	return NewResumableDownloader(&env.Env{}, &http.Client{Transport: transport}, partialsDir)
+}
+
+func (s *testDownloadServer) downloadAndExtract(d *Downloader, f fixtures.Fixture, dir string) error {
+	pkg, err := d.Download(context.Background(), s.PackageURL(f))
+	if err != nil {
+		return err
+	}
+	return pkg.ExtractLayers(DatadogPackageLayerMediaType, dir)
+}
+
+func TestDownloadResumesInterruptedLayers(t *testing.T) {
+	s := newTestDownloadServer(t)
+	partialsDir := t.TempDir()
+	transport := &flakyTransport{supportsRange: true, interruptAfter: 100, interruptions: 1000}
+	d := s.flakyDownloader(partialsDir, transport)
+
+	// each attempt downloads the next bytes of the layer until it's complete
+	var err error
+	var tmpDir string
+	attempts := 0
+	for attempts < 100 {
+		attempts++
+		tmpDir = t.TempDir()
+		err = s.downloadAndExtract(d, fixtures.FixtureSimpleV1, tmpDir)
+		if err == nil {
+			break
+		}
+		assert.ErrorContains(t, err, "connection reset by peer")
+	}
+	require.NoError(t, err)
+	assert.Greater(t, attempts, 1)
+	assert.Len(t, transport.ranges, attempts-1)
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+
+	// the complete layers are removed from the partial downloads
+	partials, err := filepath.Glob(filepath.Join(partialsDir, "*", "*"))
+	require.NoError(t, err)
+	assert.Empty(t, partials)
+}
+
+func TestDownloadRestartsWithoutRangeSupport(t *testing.T) {
+	s := newTestDownloadServer(t)
+	transport := &flakyTransport{interruptAfter: 100, interruptions: 1}
+	d := s.flakyDownloader(t.TempDir(), transport)
+
+	err := s.downloadAndExtract(d, fixtures.FixtureSimpleV1, t.TempDir())
+	assert.ErrorContains(t, err, "connection reset by peer")
+
+	// the registry serves the whole layer again, replacing the partial layer
+	tmpDir := t.TempDir()
+	require.NoError(t, s.downloadAndExtract(d, fixtures.FixtureSimpleV1, tmpDir))
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+}
+
+func TestDownloadCorruptedPartialLayer(t *testing.T) {
+	s := newTestDownloadServer(t)
+	partialsDir := t.TempDir()
+	transport := &flakyTransport{supportsRange: true}
+	d := s.flakyDownloader(partialsDir, transport)
+
+	pkg, err := d.Download(context.Background(), s.PackageURL(fixtures.FixtureSimpleV1))
+	require.NoError(t, err)
+	layers, err := pkg.Image.Layers()
+	require.NoError(t, err)
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(partialsDir, digest.Algorithm), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(partialsDir, digest.Algorithm, digest.Hex), []byte("corrupted"), 0644))
+	}
+
+	// the corrupted partial layer is detected once the layer is resumed and discarded
+	err = pkg.ExtractLayers(DatadogPackageLayerMediaType, t.TempDir())
+	assert.ErrorContains(t, err, "is corrupted")
+	assert.NotEmpty(t, transport.ranges)
+
+	tmpDir := t.TempDir()
+	require.NoError(t, s.downloadAndExtract(d, fixtures.FixtureSimpleV1, tmpDir))
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+}
+
+func TestDownloadLockedPartialLayer(t *testing.T) {
+	s := newTestDownloadServer(t)
+	partialsDir := t.TempDir()
+	transport := &flakyTransport{supportsRange: true}
+	d := s.flakyDownloader(partialsDir, transport)
+
+	pkg, err := d.Download(context.Background(), s.PackageURL(fixtures.FixtureSimpleV1))
+	require.NoError(t, err)
+	layers, err := pkg.Image.Layers()
+	require.NoError(t, err)
+	var locks []*os.File
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		require.NoError(t, err)
+		partialPath := filepath.Join(partialsDir, digest.Algorithm, digest.Hex)
+		require.NoError(t, os.MkdirAll(filepath.Dir(partialPath), 0755))
+		require.NoError(t, os.WriteFile(partialPath, []byte("in progress"), 0644))
+		f, err := os.Open(partialPath)
+		require.NoError(t, err)
+		defer f.Close()
+		locked, err := tryLockPartial(f)
+		require.NoError(t, err)
+		require.True(t, locked)
+		locks = append(locks, f)
+	}
+
+	// the partial layers locked by another download are left untouched
+	tmpDir := t.TempDir()
+	require.NoError(t, pkg.ExtractLayers(DatadogPackageLayerMediaType, tmpDir))
+	fixtures.AssertEqualFS(t, s.PackageFS(fixtures.FixtureSimpleV1), os.DirFS(tmpDir))
+	assert.Empty(t, transport.ranges)
+	for _, f := range locks {
+		content, err := os.ReadFile(f.Name())
+		require.NoError(t, err)
+		assert.Equal(t, "in progress", string(content))
+	}
+	temporary, err := filepath.Glob(filepath.Join(partialsDir, "*", "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, temporary)
+}
+
+func TestCloseAbandonedLayer(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "partial"))
+	require.NoError(t, err)
+	body := &countingReadCloser{Reader: strings.NewReader(strings.Repeat("a", 4*closeDrainMaxSize))}
+	r := &resumingReader{f: f, body: body, size: 4 * closeDrainMaxSize, hasher: sha256.New()}
+
+	// the layer abandoned by its reader isn't downloaded to the end, its partial file is kept
+	_, err = io.ReadFull(r, make([]byte, 100))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, 100, body.read)
+	assert.True(t, body.closed)
+	info, err := os.Stat(f.Name())
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, info.Size())
+}
+
+type countingReadCloser struct {
+	*strings.Reader
+	read   int
+	closed bool
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += n
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestPrunePartials(t *testing.T) {
+	partialsDir := t.TempDir()
+	d := NewResumableDownloader(&env.Env{}, http.DefaultClient, partialsDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(partialsDir, "sha256"), 0755))
+	stale := filepath.Join(partialsDir, "sha256", "stale")
+	recent := filepath.Join(partialsDir, "sha256", "recent")
+	require.NoError(t, os.WriteFile(stale, []byte("stale"), 0644))
+	require.NoError(t, os.WriteFile(recent, []byte("recent"), 0644))
+	staleTime := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(stale, staleTime, staleTime))
+
+	require.NoError(t, d.PrunePartials(24*time.Hour))
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, recent)
+
+	// partial downloads are disabled by default
+	assert.NoError(t, NewDownloader(&env.Env{}, http.DefaultClient).PrunePartials(0))
+}
+
+func TestContentRangeStart(t *testing.T) {
+	start, err := contentRangeStart("bytes 100-999/1000")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), start)
+	for _, invalid := range []string{"", "items 0-9/10", "bytes */1000"} {
+		_, err := contentRangeStart(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
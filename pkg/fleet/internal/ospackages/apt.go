@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ospackages
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AptRepository is a Datadog apt repository, for instance https://apt.datadoghq.com/ stable 7
+type AptRepository struct {
+	URL          string
+	Distribution string
+	Component    string
+	Architecture string
+}
+
+// Download downloads a package from the repository to the destination directory and returns its path.
+// The version may omit the epoch, for instance 7.55.0-1, and the latest version is downloaded if it's empty.
+//
+// apt packages aren't signed individually, the trust chain is the one apt follows:
+// 1. The InRelease file is verified against the pinned keys.
+// 2. The Packages index is verified against its checksum in the InRelease file.
+// 3. The package is verified against its checksum in the Packages index.
+func (r AptRepository) Download(ctx context.Context, client *http.Client, keyring *Keyring, name string, version string, destDir string) (string, error) {
+	baseURL := strings.TrimSuffix(r.URL, "/")
+	distURL := fmt.Sprintf("%s/dists/%s", baseURL, r.Distribution)
+
+	// 1. Verify the InRelease file
+	inRelease, err := fetch(ctx, client, distURL+"/InRelease")
+	if err != nil {
+		return "", err
+	}
+	release, err := keyring.VerifyClearsigned("InRelease", inRelease)
+	if err != nil {
+		return "", err
+	}
+
+	// 2. Verify the Packages index, the compressed one being preferred
+	checksums := parseReleaseChecksums(release)
+	indexPath := fmt.Sprintf("%s/binary-%s/Packages", r.Component, r.Architecture)
+	if _, ok := checksums[indexPath+".gz"]; ok {
+		indexPath += ".gz"
+	}
+	indexChecksum, ok := checksums[indexPath]
+	if !ok {
+		return "", signatureError(fmt.Errorf("%s is not listed in the InRelease file", indexPath))
+	}
+	index, err := fetchVerified(ctx, client, distURL+"/"+indexPath, indexChecksum)
+	if err != nil {
+		return "", err
+	}
+
+	// 3. Download and verify the package, the latest one if no version is given
+	var match map[string]string
+	for _, stanza := range parseStanzas(index) {
+		if stanza["Package"] != name || !matchesVersion(version, stanza["Version"], stripEpoch(stanza["Version"])) {
+			continue
+		}
+		if match == nil || compareVersions(stanza["Version"], match["Version"]) > 0 {
+			match = stanza
+		}
+	}
+	if match == nil {
+		return "", fmt.Errorf("package %s %s not found in %s", name, version, indexPath)
+	}
+	if match["Filename"] == "" || match["SHA256"] == "" {
+		return "", signatureError(fmt.Errorf("package %s %s has no checksum in %s", name, match["Version"], indexPath))
+	}
+	return downloadVerified(ctx, client, baseURL+"/"+match["Filename"], match["SHA256"], destDir)
+}
+
+// parseReleaseChecksums returns the SHA256 checksums of the files listed in a Release file
+func parseReleaseChecksums(release []byte) map[string]string {
+	checksums := make(map[string]string)
+	inSHA256 := false
+	scanner := bufio.NewScanner(bytes.NewReader(release))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			inSHA256 = strings.TrimSpace(line) == "SHA256:"
+			continue
+		}
+		fields := strings.Fields(line)
+		if inSHA256 && len(fields) == 3 {
+			checksums[fields[2]] = fields[0]
+		}
+	}
+	return checksums
+}
+
+// parseStanzas parses the stanzas of a Packages index, continuation lines are ignored
+func parseStanzas(index []byte) []map[string]string {
+	var stanzas []map[string]string
+	stanza := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(index))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(stanza) > 0 {
+				stanzas = append(stanzas, stanza)
+				stanza = make(map[string]string)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if found {
+			stanza[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(stanza) > 0 {
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas
+}
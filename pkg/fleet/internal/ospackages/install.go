@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ospackages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// URLs of the Datadog repositories, they're overridden in tests
+var (
+	aptRepositoryURL = "https://apt.datadoghq.com"
+	yumRepositoryURL = "https://yum.datadoghq.com/stable/7"
+)
+
+// repository is a Datadog repository of a package type
+type repository interface {
+	Download(ctx context.Context, client *http.Client, keyring *Keyring, name string, version string, destDir string) (string, error)
+}
+
+// Install downloads a package from the Datadog repository matching the package manager of the host and
+// installs it. The latest version is installed if the version is empty.
+//
+// The package is verified against the pinned keys before being installed, so the package manager isn't
+// asked to check it against the keyring of the host.
+func Install(ctx context.Context, client *http.Client, name string, version string) error {
+	packageType, repo, err := hostRepository()
+	if err != nil {
+		return err
+	}
+	keyring, err := LoadKeyring(ctx, client, packageType)
+	if err != nil {
+		return fmt.Errorf("could not load the keys of the %s repository: %w", packageType, err)
+	}
+	tmpDir, err := os.MkdirTemp("", "ospackages")
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path, err := repo.Download(ctx, client, keyring, name, version, tmpDir)
+	if err != nil {
+		return fmt.Errorf("could not download %s: %w", name, err)
+	}
+	var cmd *exec.Cmd
+	switch packageType {
+	case PackageTypeDeb:
+		cmd = exec.CommandContext(ctx, "dpkg", "-i", path)
+	case PackageTypeRPM:
+		cmd = exec.CommandContext(ctx, "rpm", "-U", "--nosignature", path)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not install %s: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hostRepository returns the type of the packages of the host and the Datadog repository they're
+// downloaded from
+func hostRepository() (PackageType, repository, error) {
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		return PackageTypeDeb, AptRepository{
+			URL:          aptRepositoryURL,
+			Distribution: "stable",
+			Component:    "7",
+			Architecture: runtime.GOARCH,
+		}, nil
+	}
+	if _, err := exec.LookPath("rpm"); err == nil {
+		arch := "x86_64"
+		if runtime.GOARCH == "arm64" {
+			arch = "aarch64"
+		}
+		return PackageTypeRPM, YumRepository{URL: yumRepositoryURL + "/" + arch}, nil
+	}
+	return "", nil, fmt.Errorf("no supported package manager found")
+}
@@ -0,0 +1,162 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ospackages
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgpErrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// PackageType is the type of the OS packages of a repository
+type PackageType string
+
+const (
+	// PackageTypeDeb is the type of the packages of apt repositories
+	PackageTypeDeb PackageType = "deb"
+	// PackageTypeRPM is the type of the packages of yum and zypper repositories
+	PackageTypeRPM PackageType = "rpm"
+)
+
+// keysURL is where the public keys of the Datadog repositories are published
+var keysURL = "https://keys.datadoghq.com"
+
+// pinnedKeys are the fingerprints of the primary keys trusted to sign the metadata of the Datadog
+// repositories, and the files they are published in. Keys found in these files but not pinned here
+// are ignored.
+var pinnedKeys = map[PackageType]struct {
+	files        []string
+	fingerprints []string
+}{
+	PackageTypeDeb: {
+		files: []string{
+			"DATADOG_APT_KEY_CURRENT.public",
+			"DATADOG_APT_KEY_C0962C7D.public",
+			"DATADOG_APT_KEY_F14F620E.public",
+			"DATADOG_APT_KEY_382E94DE.public",
+		},
+		fingerprints: []string{
+			"5F1E256061D813B125E156E8E6266D4AC0962C7D",
+			"D75CEA17048B9ACBF186794B32637D44F14F620E",
+			"A2923DFF56EDA6E76E55E492D3A80E30382E94DE",
+		},
+	},
+	PackageTypeRPM: {
+		files: []string{
+			"DATADOG_RPM_KEY_CURRENT.public",
+			"DATADOG_RPM_KEY_B01082D3.public",
+			"DATADOG_RPM_KEY_E09422B3.public",
+		},
+		fingerprints: []string{
+			"C6559B690CA882F023BDF3F63F4D1729FD4BF915",
+			"7408BFD56BC5BF0C361AAAE85D88EEA3B01082D3",
+			"A4C0B90D7443CF6E4E8AA341F1068E14E09422B3",
+		},
+	},
+}
+
+// Keyring holds the pinned keys used to verify the metadata of the Datadog repositories
+type Keyring struct {
+	entities openpgp.EntityList
+}
+
+// LoadKeyring fetches the public keys of the Datadog repositories of the given package type and
+// keeps the ones matching the pinned fingerprints. The keyring of the host is never used.
+func LoadKeyring(ctx context.Context, client *http.Client, packageType PackageType) (*Keyring, error) {
+	pinned, ok := pinnedKeys[packageType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported package type %s", packageType)
+	}
+	keyring := &Keyring{}
+	var fetchErr error
+	fetched := false
+	for _, file := range pinned.files {
+		content, err := fetch(ctx, client, keysURL+"/"+file)
+		if err != nil {
+			log.Warnf("Could not fetch key %s: %v", file, err)
+			fetchErr = err
+			continue
+		}
+		fetched = true
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+		if err != nil {
+			log.Warnf("Could not read key %s: %v", file, err)
+			continue
+		}
+		for _, entity := range entities {
+			fingerprint := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+			if !slices.Contains(pinned.fingerprints, fingerprint) {
+				log.Warnf("Ignoring key %s from %s, it isn't pinned", fingerprint, file)
+				continue
+			}
+			if !keyring.has(fingerprint) {
+				keyring.entities = append(keyring.entities, entity)
+			}
+		}
+	}
+	if len(keyring.entities) == 0 {
+		// keys that can't be fetched at all are a network problem, not a keyring one
+		if !fetched {
+			return nil, fmt.Errorf("no pinned key could be loaded: %w", fetchErr)
+		}
+		return nil, keyringError(fmt.Errorf("no pinned key found for %s packages", packageType))
+	}
+	return keyring, nil
+}
+
+func (k *Keyring) has(fingerprint string) bool {
+	for _, entity := range k.entities {
+		if fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyClearsigned verifies a clearsigned message, such as an apt InRelease file, and returns its
+// signed content
+func (k *Keyring) VerifyClearsigned(name string, content []byte) ([]byte, error) {
+	block, _ := clearsign.Decode(content)
+	if block == nil {
+		return nil, signatureError(fmt.Errorf("%s is not clearsigned", name))
+	}
+	if _, err := block.VerifySignature(k.entities, nil); err != nil {
+		return nil, verificationError(name, err)
+	}
+	return block.Plaintext, nil
+}
+
+// VerifyDetached verifies the detached signature of a file, such as the repomd.xml.asc signature of
+// a yum repomd.xml file. The signature may be armored or not.
+func (k *Keyring) VerifyDetached(name string, content []byte, signature []byte) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(k.entities, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(k.entities, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return verificationError(name, err)
+	}
+	return nil
+}
+
+// verificationError tells apart signatures made by keys that aren't trusted from invalid signatures
+func verificationError(name string, err error) error {
+	if errors.Is(err, pgpErrors.ErrUnknownIssuer) || errors.Is(err, pgpErrors.ErrKeyExpired) || errors.Is(err, pgpErrors.ErrKeyRevoked) {
+		return keyringError(fmt.Errorf("%s is not signed by a valid pinned key: %w", name, err))
+	}
+	return signatureError(fmt.Errorf("invalid signature for %s: %w", name, err))
+}
@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package ospackages downloads deb and rpm packages from the Datadog repositories when the installer
+// falls back to OS packages. The repository metadata and the packages are verified against pinned
+// Datadog keys instead of the keyring of the host, which may be missing, outdated or tampered with.
+package ospackages
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+var (
+	// ErrKeyring is returned when the pinned signing keys can't be loaded, or when the metadata is
+	// signed by a key that isn't pinned, is expired or is revoked.
+	ErrKeyring = errors.New("keyring error")
+	// ErrNetwork is returned when the keys, the metadata or a package can't be fetched.
+	ErrNetwork = errors.New("network error")
+	// ErrInvalidSignature is returned when the signature of the metadata or the checksum of a
+	// file listed in it doesn't match.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// keyringError wraps an error as a keyring problem
+func keyringError(err error) error {
+	return installerErrors.Wrap(installerErrors.ErrKeyringUnavailable, fmt.Errorf("%w: %w", ErrKeyring, err))
+}
+
+// networkError wraps an error as a network problem
+func networkError(err error) error {
+	return installerErrors.Wrap(installerErrors.ErrDownloadFailed, fmt.Errorf("%w: %w", ErrNetwork, err))
+}
+
+// signatureError wraps an error as a signature problem
+func signatureError(err error) error {
+	return installerErrors.Wrap(installerErrors.ErrInvalidSignature, fmt.Errorf("%w: %w", ErrInvalidSignature, err))
+}
+
+// fetch returns the content of the given URL
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	body, err := open(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, networkError(fmt.Errorf("could not read %s: %w", url, err))
+	}
+	return content, nil
+}
+
+// open returns the body of the given URL, which must be closed by the caller
+func open(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request for %s: %w", url, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, networkError(fmt.Errorf("could not fetch %s: %w", url, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, networkError(fmt.Errorf("could not fetch %s: unexpected status code %d", url, resp.StatusCode))
+	}
+	return resp.Body, nil
+}
+
+// fetchVerified returns the content of the given URL after checking its checksum, decompressing it
+// if it ends with .gz
+func fetchVerified(ctx context.Context, client *http.Client, url string, sha256sum string) ([]byte, error) {
+	content, err := fetch(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSHA256(url, content, sha256sum); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(url, ".gz") {
+		return content, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress %s: %w", url, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// downloadVerified downloads the given URL to the destination directory after checking its checksum,
+// returning the path of the downloaded file
+func downloadVerified(ctx context.Context, client *http.Client, url string, sha256sum string, destDir string) (string, error) {
+	body, err := open(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	path := filepath.Join(destDir, filepath.Base(url))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("could not create %s: %w", path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		os.Remove(path)
+		return "", networkError(fmt.Errorf("could not download %s: %w", url, err))
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, sha256sum) {
+		os.Remove(path)
+		return "", signatureError(fmt.Errorf("checksum of %s is %s, expected %s", url, sum, sha256sum))
+	}
+	return path, nil
+}
+
+func checkSHA256(url string, content []byte, sha256sum string) error {
+	hash := sha256.Sum256(content)
+	if sum := hex.EncodeToString(hash[:]); !strings.EqualFold(sum, sha256sum) {
+		return signatureError(fmt.Errorf("checksum of %s is %s, expected %s", url, sum, sha256sum))
+	}
+	return nil
+}
+
+// matchesVersion returns true if the requested version is empty or is one of the given forms of the
+// version of a package
+func matchesVersion(requested string, versions ...string) bool {
+	return requested == "" || slices.Contains(versions, requested)
+}
+
+// stripEpoch removes the epoch of a package version, e.g. 7.55.0-1 for 1:7.55.0-1
+func stripEpoch(version string) string {
+	if i := strings.Index(version, ":"); i >= 0 {
+		return version[i+1:]
+	}
+	return version
+}
+
+// compareVersions orders two package versions in the [epoch:]version[-release] form. The upstream
+// versions are compared as semver, versions that can't be parsed are considered equal to the other ones.
+func compareVersions(a string, b string) int {
+	if c := cmp.Compare(epochOf(a), epochOf(b)); c != 0 {
+		return c
+	}
+	upstreamA, releaseA := splitRelease(stripEpoch(a))
+	upstreamB, releaseB := splitRelease(stripEpoch(b))
+	va, err := semver.NewVersion(upstreamA)
+	if err != nil {
+		return 0
+	}
+	vb, err := semver.NewVersion(upstreamB)
+	if err != nil {
+		return 0
+	}
+	if c := va.Compare(vb); c != 0 {
+		return c
+	}
+	return cmp.Compare(releaseA, releaseB)
+}
+
+func epochOf(version string) int {
+	i := strings.Index(version, ":")
+	if i < 0 {
+		return 0
+	}
+	epoch, _ := strconv.Atoi(version[:i])
+	return epoch
+}
+
+func splitRelease(version string) (string, int) {
+	i := strings.LastIndex(version, "-")
+	if i < 0 {
+		return version, 0
+	}
+	release, err := strconv.Atoi(version[i+1:])
+	if err != nil {
+		return version, 0
+	}
+	return version[:i], release
+}
@@ -0,0 +1,279 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ospackages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	installerErrors "github.com/DataDog/datadog-agent/pkg/fleet/installer/errors"
+)
+
+type testRepository struct {
+	*httptest.Server
+	files map[string][]byte
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	repo := &testRepository{files: make(map[string][]byte)}
+	repo.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := repo.files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(repo.Close)
+	return repo
+}
+
+func newTestKey(t *testing.T) (*openpgp.Entity, []byte) {
+	entity, err := openpgp.NewEntity("Datadog test", "", "test@datadoghq.com", nil)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return entity, buf.Bytes()
+}
+
+// pinTestKey pins the given key for the package type and serves it from the repository
+func pinTestKey(t *testing.T, repo *testRepository, packageType PackageType, entity *openpgp.Entity, armored []byte) {
+	oldKeysURL, oldPinnedKeys := keysURL, pinnedKeys
+	t.Cleanup(func() { keysURL, pinnedKeys = oldKeysURL, oldPinnedKeys })
+	keysURL = repo.URL + "/keys"
+	pinnedKeys = map[PackageType]struct {
+		files        []string
+		fingerprints []string
+	}{
+		packageType: {
+			files:        []string{"TEST_KEY.public", "MISSING_KEY.public"},
+			fingerprints: []string{fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)},
+		},
+	}
+	repo.files["/keys/TEST_KEY.public"] = armored
+}
+
+func sha256sum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func clearsigned(t *testing.T, entity *openpgp.Entity, content []byte) []byte {
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, entity.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func detachedSignature(t *testing.T, entity *openpgp.Entity, content []byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&buf, entity, bytes.NewReader(content), nil))
+	return buf.Bytes()
+}
+
+func setupAptRepository(t *testing.T, repo *testRepository, signer *openpgp.Entity, deb []byte) {
+	repo.files["/pool/d/da/datadog-agent_7.55.0-1_amd64.deb"] = deb
+	packages := gzipped(t, []byte(fmt.Sprintf("Package: datadog-agent\nVersion: 1:7.54.0-1\nFilename: pool/d/da/datadog-agent_7.54.0-1_amd64.deb\nSHA256: %s\n\nPackage: datadog-agent\nVersion: 1:7.55.0-1\nDescription: Datadog Agent\n multiline description\nFilename: pool/d/da/datadog-agent_7.55.0-1_amd64.deb\nSHA256: %s\n", sha256sum([]byte("old")), sha256sum(deb))))
+	repo.files["/dists/stable/7/binary-amd64/Packages.gz"] = packages
+	release := fmt.Sprintf("Origin: Datadog, Inc.\nSuite: stable\nMD5Sum:\n 00000000000000000000000000000000 %d 7/binary-amd64/Packages.gz\nSHA256:\n %s %d 7/binary-amd64/Packages.gz\n", len(packages), sha256sum(packages), len(packages))
+	repo.files["/dists/stable/InRelease"] = clearsigned(t, signer, []byte(release))
+}
+
+func setupYumRepository(t *testing.T, repo *testRepository, signer *openpgp.Entity, rpm []byte) {
+	repo.files["/7/x86_64/datadog-agent-7.55.0-1.x86_64.rpm"] = rpm
+	primary := gzipped(t, []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" packages="1">
+<package type="rpm"><name>datadog-agent</name><arch>x86_64</arch><version epoch="1" ver="7.55.0" rel="1"/><checksum type="sha256" pkgid="YES">%s</checksum><location href="datadog-agent-7.55.0-1.x86_64.rpm"/></package>
+</metadata>`, sha256sum(rpm))))
+	repo.files["/7/x86_64/repodata/primary.xml.gz"] = primary
+	repomdContent := []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+<data type="primary"><checksum type="sha256">%s</checksum><location href="repodata/primary.xml.gz"/></data>
+</repomd>`, sha256sum(primary)))
+	repo.files["/7/x86_64/repodata/repomd.xml"] = repomdContent
+	repo.files["/7/x86_64/repodata/repomd.xml.asc"] = detachedSignature(t, signer, repomdContent)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	repo := newTestRepository(t)
+	pinned, armored := newTestKey(t)
+	pinTestKey(t, repo, PackageTypeDeb, pinned, armored)
+
+	keyring, err := LoadKeyring(context.Background(), repo.Client(), PackageTypeDeb)
+	require.NoError(t, err)
+	assert.Len(t, keyring.entities, 1)
+
+	// a key that isn't pinned is ignored
+	_, other := newTestKey(t)
+	repo.files["/keys/TEST_KEY.public"] = other
+	_, err = LoadKeyring(context.Background(), repo.Client(), PackageTypeDeb)
+	assert.ErrorIs(t, err, ErrKeyring)
+	assert.Equal(t, installerErrors.ErrKeyringUnavailable, installerErrors.From(err).Code())
+
+	// the keys can't be fetched
+	delete(repo.files, "/keys/TEST_KEY.public")
+	_, err = LoadKeyring(context.Background(), repo.Client(), PackageTypeDeb)
+	assert.ErrorIs(t, err, ErrNetwork)
+	assert.NotErrorIs(t, err, ErrKeyring)
+	assert.Equal(t, installerErrors.ErrDownloadFailed, installerErrors.From(err).Code())
+}
+
+func TestAptDownload(t *testing.T) {
+	repo := newTestRepository(t)
+	pinned, armored := newTestKey(t)
+	pinTestKey(t, repo, PackageTypeDeb, pinned, armored)
+	keyring, err := LoadKeyring(context.Background(), repo.Client(), PackageTypeDeb)
+	require.NoError(t, err)
+	aptRepo := AptRepository{URL: repo.URL + "/", Distribution: "stable", Component: "7", Architecture: "amd64"}
+
+	setupAptRepository(t, repo, pinned, []byte("deb content"))
+	path, err := aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.55.0-1", t.TempDir())
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "deb content", string(content))
+	assert.Equal(t, "datadog-agent_7.55.0-1_amd64.deb", filepath.Base(path))
+
+	// without the epoch, or the latest version
+	for _, version := range []string{"7.55.0-1", ""} {
+		path, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", version, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "datadog-agent_7.55.0-1_amd64.deb", filepath.Base(path))
+	}
+
+	_, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.56.0-1", t.TempDir())
+	assert.Error(t, err)
+
+	// tampered package
+	repo.files["/pool/d/da/datadog-agent_7.55.0-1_amd64.deb"] = []byte("tampered")
+	destDir := t.TempDir()
+	_, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.55.0-1", destDir)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.Equal(t, installerErrors.ErrInvalidSignature, installerErrors.From(err).Code())
+	entries, err := os.ReadDir(destDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// tampered InRelease
+	setupAptRepository(t, repo, pinned, []byte("deb content"))
+	repo.files["/dists/stable/InRelease"] = bytes.Replace(repo.files["/dists/stable/InRelease"], []byte("Suite: stable"), []byte("Suite: unstable"), 1)
+	_, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.55.0-1", t.TempDir())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// InRelease signed by a key that isn't pinned
+	other, _ := newTestKey(t)
+	setupAptRepository(t, repo, other, []byte("deb content"))
+	_, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.55.0-1", t.TempDir())
+	assert.ErrorIs(t, err, ErrKeyring)
+	assert.NotErrorIs(t, err, ErrInvalidSignature)
+
+	// repository unreachable
+	repo.Close()
+	_, err = aptRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "1:7.55.0-1", t.TempDir())
+	assert.ErrorIs(t, err, ErrNetwork)
+}
+
+func TestYumDownload(t *testing.T) {
+	repo := newTestRepository(t)
+	pinned, armored := newTestKey(t)
+	pinTestKey(t, repo, PackageTypeRPM, pinned, armored)
+	keyring, err := LoadKeyring(context.Background(), repo.Client(), PackageTypeRPM)
+	require.NoError(t, err)
+	yumRepo := YumRepository{URL: repo.URL + "/7/x86_64"}
+
+	setupYumRepository(t, repo, pinned, []byte("rpm content"))
+	for _, version := range []string{"7.55.0", "7.55.0-1", ""} {
+		path, err := yumRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", version, t.TempDir())
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "rpm content", string(content))
+	}
+
+	// tampered primary metadata
+	repo.files["/7/x86_64/repodata/primary.xml.gz"] = gzipped(t, []byte("<metadata/>"))
+	_, err = yumRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "7.55.0", t.TempDir())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// repomd.xml signed by a key that isn't pinned
+	other, _ := newTestKey(t)
+	setupYumRepository(t, repo, other, []byte("rpm content"))
+	_, err = yumRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "7.55.0", t.TempDir())
+	assert.ErrorIs(t, err, ErrKeyring)
+
+	// missing signature
+	setupYumRepository(t, repo, pinned, []byte("rpm content"))
+	delete(repo.files, "/7/x86_64/repodata/repomd.xml.asc")
+	_, err = yumRepo.Download(context.Background(), repo.Client(), keyring, "datadog-agent", "7.55.0", t.TempDir())
+	assert.ErrorIs(t, err, ErrNetwork)
+}
+
+func TestInstall(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOARCH != "amd64" {
+		t.Skip("the test repository only has amd64 packages, installed with a fake dpkg")
+	}
+	repo := newTestRepository(t)
+	pinned, armored := newTestKey(t)
+	pinTestKey(t, repo, PackageTypeDeb, pinned, armored)
+	setupAptRepository(t, repo, pinned, []byte("deb content"))
+	oldAptRepositoryURL := aptRepositoryURL
+	t.Cleanup(func() { aptRepositoryURL = oldAptRepositoryURL })
+	aptRepositoryURL = repo.URL
+
+	// a fake dpkg copying the package it installs
+	binDir := t.TempDir()
+	installed := filepath.Join(t.TempDir(), "installed")
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "dpkg"), []byte("#!/bin/sh\ncp \"$2\" "+installed+"\n"), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	require.NoError(t, Install(context.Background(), repo.Client(), "datadog-agent", ""))
+	content, err := os.ReadFile(installed)
+	require.NoError(t, err)
+	assert.Equal(t, "deb content", string(content))
+
+	// tampered package
+	repo.files["/pool/d/da/datadog-agent_7.55.0-1_amd64.deb"] = []byte("tampered")
+	err = Install(context.Background(), repo.Client(), "datadog-agent", "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 1, compareVersions("1:7.55.0-1", "7.56.0-1"))
+	assert.Equal(t, 1, compareVersions("7.55.0-2", "7.55.0-1"))
+	assert.Equal(t, -1, compareVersions("7.54.1-1", "7.55.0-1"))
+	assert.Equal(t, 0, compareVersions("1:7.55.0-1", "1:7.55.0-1"))
+}
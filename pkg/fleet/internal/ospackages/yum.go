@@ -0,0 +1,131 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ospackages
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// YumRepository is a Datadog yum repository, for instance https://yum.datadoghq.com/stable/7/x86_64/
+type YumRepository struct {
+	URL string
+}
+
+type repomd struct {
+	Data []struct {
+		Type     string      `xml:"type,attr"`
+		Checksum yumChecksum `xml:"checksum"`
+		Location yumLocation `xml:"location"`
+	} `xml:"data"`
+}
+
+type primaryMetadata struct {
+	Packages []yumPackage `xml:"package"`
+}
+
+type yumPackage struct {
+	Name    string `xml:"name"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum yumChecksum `xml:"checksum"`
+	Location yumLocation `xml:"location"`
+}
+
+// fullVersion returns the version of the package in the epoch:version-release form
+func (p yumPackage) fullVersion() string {
+	version := p.Version.Ver + "-" + p.Version.Rel
+	if p.Version.Epoch != "" && p.Version.Epoch != "0" {
+		version = p.Version.Epoch + ":" + version
+	}
+	return version
+}
+
+type yumChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type yumLocation struct {
+	Href string `xml:"href,attr"`
+}
+
+// Download downloads a package from the repository to the destination directory and returns its path.
+// The version may include the release, for instance 7.55.0-1, and the latest version is downloaded if
+// it's empty.
+//
+// The trust chain is the one yum follows with repo_gpgcheck:
+// 1. The repomd.xml file is verified against its detached signature and the pinned keys.
+// 2. The primary metadata is verified against its checksum in the repomd.xml file.
+// 3. The package is verified against its checksum in the primary metadata.
+func (r YumRepository) Download(ctx context.Context, client *http.Client, keyring *Keyring, name string, version string, destDir string) (string, error) {
+	baseURL := strings.TrimSuffix(r.URL, "/")
+
+	// 1. Verify the repomd.xml file
+	repomdContent, err := fetch(ctx, client, baseURL+"/repodata/repomd.xml")
+	if err != nil {
+		return "", err
+	}
+	signature, err := fetch(ctx, client, baseURL+"/repodata/repomd.xml.asc")
+	if err != nil {
+		return "", err
+	}
+	if err := keyring.VerifyDetached("repomd.xml", repomdContent, signature); err != nil {
+		return "", err
+	}
+	var md repomd
+	if err := xml.Unmarshal(repomdContent, &md); err != nil {
+		return "", fmt.Errorf("could not parse repomd.xml: %w", err)
+	}
+
+	// 2. Verify the primary metadata
+	var primaryContent []byte
+	for _, data := range md.Data {
+		if data.Type != "primary" {
+			continue
+		}
+		if data.Checksum.Type != "sha256" {
+			return "", signatureError(fmt.Errorf("unsupported checksum type %s for the primary metadata", data.Checksum.Type))
+		}
+		primaryContent, err = fetchVerified(ctx, client, baseURL+"/"+data.Location.Href, strings.TrimSpace(data.Checksum.Value))
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+	if primaryContent == nil {
+		return "", signatureError(fmt.Errorf("no primary metadata listed in repomd.xml"))
+	}
+	var primary primaryMetadata
+	if err := xml.Unmarshal(primaryContent, &primary); err != nil {
+		return "", fmt.Errorf("could not parse the primary metadata: %w", err)
+	}
+
+	// 3. Download and verify the package, the latest one if no version is given
+	match := -1
+	for i, pkg := range primary.Packages {
+		if pkg.Name != name || !matchesVersion(version, pkg.Version.Ver, pkg.Version.Ver+"-"+pkg.Version.Rel) {
+			continue
+		}
+		if match == -1 || compareVersions(pkg.fullVersion(), primary.Packages[match].fullVersion()) > 0 {
+			match = i
+		}
+	}
+	if match == -1 {
+		return "", fmt.Errorf("package %s %s not found in the primary metadata", name, version)
+	}
+	pkg := primary.Packages[match]
+	if pkg.Checksum.Type != "sha256" {
+		return "", signatureError(fmt.Errorf("unsupported checksum type %s for package %s %s", pkg.Checksum.Type, name, pkg.fullVersion()))
+	}
+	return downloadVerified(ctx, client, baseURL+"/"+pkg.Location.Href, strings.TrimSpace(pkg.Checksum.Value), destDir)
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package tar
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathTooLongError is returned when an entry of an archive would be extracted to a path
+// exceeding the limits of the filesystem.
+type PathTooLongError struct {
+	Path  string
+	Limit int
+	// Component is true if a single element of the path exceeds the limit
+	Component bool
+}
+
+// Error returns the error message.
+func (e *PathTooLongError) Error() string {
+	if e.Component {
+		return fmt.Sprintf("path %s has an element longer than %d characters", e.Path, e.Limit)
+	}
+	return fmt.Sprintf("path %s is longer than %d characters", e.Path, e.Limit)
+}
+
+// Check reads the headers of a tar archive without extracting it and returns the number of
+// entries it would create in the given destination path. It returns a *PathTooLongError if
+// any entry would exceed the path length limits of the platform.
+func Check(reader io.Reader, destinationPath string, maxSize int64) (entries uint64, err error) {
+	tr := tar.NewReader(io.LimitReader(reader, maxSize))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read tar header: %w", err)
+		}
+		if header.Name == "./" {
+			continue
+		}
+		err = checkPathLength(filepath.Join(destinationPath, header.Name))
+		if err != nil {
+			return 0, err
+		}
+		entries++
+	}
+	return entries, nil
+}
+
+func checkPathLength(path string) error {
+	if len(path) > maxPathLen {
+		return &PathTooLongError{Path: path, Limit: maxPathLen}
+	}
+	for _, element := range strings.Split(path, string(os.PathSeparator)) {
+		if len(element) > maxNameLen {
+			return &PathTooLongError{Path: path, Limit: maxNameLen, Component: true}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildArchive(t *testing.T, names ...string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0755
+		}
+		require.NoError(t, tw.WriteHeader(header))
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestCheck(t *testing.T) {
+	archive := buildArchive(t, "./", "bin/", "bin/agent", "embedded/lib/python3.11/site.py")
+	entries, err := Check(archive, t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), entries)
+}
+
+func TestCheckPathTooLong(t *testing.T) {
+	var pathErr *PathTooLongError
+
+	archive := buildArchive(t, "bin/"+strings.Repeat("a", maxNameLen+1))
+	_, err := Check(archive, t.TempDir(), 1<<20)
+	require.True(t, errors.As(err, &pathErr))
+	assert.True(t, pathErr.Component)
+
+	deep := strings.Repeat(strings.Repeat("d", 100)+"/", maxPathLen/100+1) + "file"
+	archive = buildArchive(t, deep)
+	_, err = Check(archive, t.TempDir(), 1<<20)
+	require.True(t, errors.As(err, &pathErr))
+	assert.False(t, pathErr.Component)
+	assert.Equal(t, maxPathLen, pathErr.Limit)
+}
@@ -0,0 +1,15 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build !windows
+
+package tar
+
+const (
+	// maxPathLen is PATH_MAX, including the terminating null byte
+	maxPathLen = 4096 - 1
+	// maxNameLen is NAME_MAX
+	maxNameLen = 255
+)
@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build windows
+
+package tar
+
+const (
+	// maxPathLen is MAX_PATH, including the terminating null character, as long paths
+	// aren't enabled by default
+	maxPathLen = 260 - 1
+	// maxNameLen is the maximum length of a path element on NTFS
+	maxNameLen = 255
+)
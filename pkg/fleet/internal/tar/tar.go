@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/DataDog/datadog-agent/pkg/fleet/internal/cas"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
@@ -25,6 +26,17 @@ import (
 // against its reference in the package catalog. This catalog is itself sent over Remote Config
 // which guarantees its integrity.
 func Extract(reader io.Reader, destinationPath string, maxSize int64) error {
+	return extract(reader, destinationPath, maxSize, nil)
+}
+
+// ExtractToStore extracts a tar archive to the given destination path, writing its regular files
+// as hard links to the objects of the given store so that files shared with other packages are
+// stored only once. The destination must be on the same filesystem as the store.
+func ExtractToStore(reader io.Reader, destinationPath string, maxSize int64, store *cas.Store) error {
+	return extract(reader, destinationPath, maxSize, store)
+}
+
+func extract(reader io.Reader, destinationPath string, maxSize int64, store *cas.Store) error {
 	log.Debugf("Extracting archive to %s", destinationPath)
 	tr := tar.NewReader(io.LimitReader(reader, maxSize))
 	for {
@@ -54,7 +66,11 @@ func Extract(reader io.Reader, destinationPath string, maxSize int64) error {
 				return fmt.Errorf("could not create directory: %w", err)
 			}
 		case tar.TypeReg:
-			err = extractFile(target, tr, os.FileMode(header.Mode))
+			if store != nil {
+				err = store.Link(target, tr, os.FileMode(header.Mode))
+			} else {
+				err = extractFile(target, tr, os.FileMode(header.Mode))
+			}
 			if err != nil {
 				return err // already wrapped
 			}
@@ -9,10 +9,8 @@
 package status
 
 import (
-	"bufio"
 	"expvar"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +20,7 @@ import (
 
 	ddconfig "github.com/DataDog/datadog-agent/pkg/config"
 	apicfg "github.com/DataDog/datadog-agent/pkg/process/util/api/config"
+	"github.com/DataDog/datadog-agent/pkg/security/common/containerutils"
 	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
 	"github.com/DataDog/datadog-agent/pkg/version"
 )
@@ -186,26 +185,16 @@ func publishContainerID() interface{} {
 		return nil
 	}
 	defer func() { _ = f.Close() }()
-	scanner := bufio.NewScanner(f)
-	// the content of the file should have "docker/" on each line, the last
-	// bit of each line after the "/" should be the container id, e.g.
+	// the container ID is part of the cgroup paths of the process, e.g.
 	//
 	// 	11:name=systemd:/docker/49de419da182a44f29659b9761a963543cdbf1dee8b51313b9104edec4461c58
 	//
-	// we could just extract that and treat it as current container id
-	containerID := ""
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "docker/") {
-			slices := strings.Split(line, "/")
-			// it's not totally safe to assume the format, but it's the only thing we can do for now
-			if len(slices) == 3 {
-				containerID = slices[len(slices)-1]
-				break
-			}
-		}
+	// all the hierarchies of a container share the same ID, the first one found is used
+	containerIDs, err := containerutils.FindAllContainerIDs(f)
+	if err != nil || len(containerIDs) == 0 {
+		return ""
 	}
-	return containerID
+	return containerIDs[0]
 }
 
 func publishEndpoints(eps []apicfg.Endpoint) func() interface{} {
@@ -991,9 +991,9 @@ func (z *ClientTracer) Msgsize() (s int) {
 // MarshalMsg implements msgp.Marshaler
 func (z *ClientUpdater) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 2
+	// map header, size 6
 	// string "Tags"
-	o = append(o, 0x82, 0xa4, 0x54, 0x61, 0x67, 0x73)
+	o = append(o, 0x86, 0xa4, 0x54, 0x61, 0x67, 0x73)
 	o = msgp.AppendArrayHeader(o, uint32(len(z.Tags)))
 	for za0001 := range z.Tags {
 		o = msgp.AppendString(o, z.Tags[za0001])
@@ -1012,6 +1012,18 @@ func (z *ClientUpdater) MarshalMsg(b []byte) (o []byte, err error) {
 			}
 		}
 	}
+	// string "HeartbeatTimestamp"
+	o = append(o, 0xb2, 0x48, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	o = msgp.AppendInt64(o, z.HeartbeatTimestamp)
+	// string "UptimeSeconds"
+	o = append(o, 0xad, 0x55, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73)
+	o = msgp.AppendUint64(o, z.UptimeSeconds)
+	// string "LastGcTimestamp"
+	o = append(o, 0xaf, 0x4c, 0x61, 0x73, 0x74, 0x47, 0x63, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	o = msgp.AppendInt64(o, z.LastGcTimestamp)
+	// string "QueueDepth"
+	o = append(o, 0xaa, 0x51, 0x75, 0x65, 0x75, 0x65, 0x44, 0x65, 0x70, 0x74, 0x68)
+	o = msgp.AppendUint64(o, z.QueueDepth)
 	return
 }
 
@@ -1082,6 +1094,30 @@ func (z *ClientUpdater) UnmarshalMsg(bts []byte) (o []byte, err error) {
 					}
 				}
 			}
+		case "HeartbeatTimestamp":
+			z.HeartbeatTimestamp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "HeartbeatTimestamp")
+				return
+			}
+		case "UptimeSeconds":
+			z.UptimeSeconds, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UptimeSeconds")
+				return
+			}
+		case "LastGcTimestamp":
+			z.LastGcTimestamp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "LastGcTimestamp")
+				return
+			}
+		case "QueueDepth":
+			z.QueueDepth, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "QueueDepth")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -1108,6 +1144,7 @@ func (z *ClientUpdater) Msgsize() (s int) {
 			s += z.Packages[za0002].Msgsize()
 		}
 	}
+	s += 19 + msgp.Int64Size + 14 + msgp.Uint64Size + 16 + msgp.Int64Size + 11 + msgp.Uint64Size
 	return
 }
 
@@ -3152,9 +3189,9 @@ func (z *PackageState) Msgsize() (s int) {
 // MarshalMsg implements msgp.Marshaler
 func (z *PackageStateTask) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 3
+	// map header, size 4
 	// string "Id"
-	o = append(o, 0x83, 0xa2, 0x49, 0x64)
+	o = append(o, 0x84, 0xa2, 0x49, 0x64)
 	o = msgp.AppendString(o, z.Id)
 	// string "State"
 	o = append(o, 0xa5, 0x53, 0x74, 0x61, 0x74, 0x65)
@@ -3172,6 +3209,9 @@ func (z *PackageStateTask) MarshalMsg(b []byte) (o []byte, err error) {
 		o = append(o, 0xa7, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65)
 		o = msgp.AppendString(o, z.Error.Message)
 	}
+	// string "DryRunReport"
+	o = append(o, 0xac, 0x44, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74)
+	o = msgp.AppendString(o, z.DryRunReport)
 	return
 }
 
@@ -3255,6 +3295,12 @@ func (z *PackageStateTask) UnmarshalMsg(bts []byte) (o []byte, err error) {
 					}
 				}
 			}
+		case "DryRunReport":
+			z.DryRunReport, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "DryRunReport")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -3275,6 +3321,7 @@ func (z *PackageStateTask) Msgsize() (s int) {
 	} else {
 		s += 1 + 5 + msgp.Uint64Size + 8 + msgp.StringPrefixSize + len(z.Error.Message)
 	}
+	s += 13 + msgp.StringPrefixSize + len(z.DryRunReport)
 	return
 }
 
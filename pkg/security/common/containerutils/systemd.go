@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package containerutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	systemdSliceSuffix = ".slice"
+	systemdUserPrefix  = "user-"
+)
+
+// systemdUnitSuffixes are the suffixes of the systemd units that can hold processes
+var systemdUnitSuffixes = []string{".service", ".scope"}
+
+// SystemdCGroup is the systemd hierarchy of a cgroup managed by systemd
+type SystemdCGroup struct {
+	// Slice is the innermost slice of the cgroup, e.g. system.slice or user-1000.slice
+	Slice string
+	// Unit is the innermost unit of the cgroup, e.g. sshd.service or session-3.scope
+	Unit string
+	// User is the UID of the user owning the cgroup, set for the cgroups of user-<uid>.slice
+	User string
+}
+
+// ParseSystemdCGroup parses the systemd slices and units of a cgroup path, such as
+// /system.slice/sshd.service or /user.slice/user-1000.slice/session-3.scope.
+// It returns false if the cgroup isn't managed by systemd.
+func ParseSystemdCGroup(cgroup string) (SystemdCGroup, bool) {
+	var systemdCGroup SystemdCGroup
+	managed := false
+	for _, element := range strings.Split(strings.Trim(cgroup, "/"), "/") {
+		switch {
+		case strings.HasSuffix(element, systemdSliceSuffix):
+			managed = true
+			systemdCGroup.Slice = element
+			// The slices nested below a unit belong to it, keep the innermost unit only
+			systemdCGroup.Unit = ""
+			if user, ok := parseSystemdUserSlice(element); ok {
+				systemdCGroup.User = user
+			}
+		case isSystemdUnit(element):
+			managed = true
+			systemdCGroup.Unit = element
+		}
+	}
+	return systemdCGroup, managed
+}
+
+// parseSystemdUserSlice returns the UID of a user-<uid>.slice slice
+func parseSystemdUserSlice(slice string) (string, bool) {
+	uid, ok := strings.CutPrefix(strings.TrimSuffix(slice, systemdSliceSuffix), systemdUserPrefix)
+	if !ok {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+		return "", false
+	}
+	return uid, true
+}
+
+func isSystemdUnit(element string) bool {
+	for _, suffix := range systemdUnitSuffixes {
+		if len(element) > len(suffix) && strings.HasSuffix(element, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package containerutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSystemdCGroup(t *testing.T) {
+	testCases := []struct {
+		input   string
+		output  SystemdCGroup
+		managed bool
+	}{
+		{ // system service
+			input:   "/system.slice/sshd.service",
+			output:  SystemdCGroup{Slice: "system.slice", Unit: "sshd.service"},
+			managed: true,
+		},
+		{ // user session
+			input:   "/user.slice/user-1000.slice/session-3.scope",
+			output:  SystemdCGroup{Slice: "user-1000.slice", Unit: "session-3.scope", User: "1000"},
+			managed: true,
+		},
+		{ // user manager
+			input:   "/user.slice/user-1000.slice/user@1000.service/app.slice/app-org.gnome.Terminal.slice/vte-spawn-f9176c6a.scope",
+			output:  SystemdCGroup{Slice: "app-org.gnome.Terminal.slice", Unit: "vte-spawn-f9176c6a.scope", User: "1000"},
+			managed: true,
+		},
+		{ // slice without unit
+			input:   "/user.slice/user-1000.slice/",
+			output:  SystemdCGroup{Slice: "user-1000.slice", User: "1000"},
+			managed: true,
+		},
+		{ // container
+			input:   "/system.slice/docker-cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0.scope",
+			output:  SystemdCGroup{Slice: "system.slice", Unit: "docker-cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0.scope"},
+			managed: true,
+		},
+		{ // not a user slice
+			input:   "/user.slice/user-runtime.slice",
+			output:  SystemdCGroup{Slice: "user-runtime.slice"},
+			managed: true,
+		},
+		{ // cgroupfs
+			input: "/docker/cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0",
+		},
+		{ // root cgroup
+			input: "/",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.input, func(t *testing.T) {
+			systemdCGroup, managed := ParseSystemdCGroup(test.input)
+			assert.Equal(t, test.managed, managed)
+			assert.Equal(t, test.output, systemdCGroup)
+		})
+	}
+}
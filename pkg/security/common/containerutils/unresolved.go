@@ -0,0 +1,131 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package containerutils
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	// maxUnresolvedPrefixLen is the maximum length of the prefix used to bucket an unresolved cgroup
+	maxUnresolvedPrefixLen = 32
+	// maxUnresolvedBuckets is the maximum number of buckets tracked between two swaps
+	maxUnresolvedBuckets = 64
+	// minHexRunLen is the length of the hexadecimal runs considered to be identifiers
+	minHexRunLen = 8
+
+	// UnresolvedUnknownBucket is the bucket of the cgroups whose sanitized prefix is empty
+	UnresolvedUnknownBucket = "unknown"
+	// UnresolvedOtherBucket is the bucket used once maxUnresolvedBuckets buckets are tracked
+	UnresolvedOtherBucket = "other"
+)
+
+// UnresolvedPrefix returns the sanitized prefix of a cgroup path that didn't match any container ID
+// pattern. Runtimes encode the container ID in the last element of the path, e.g.
+// cri-containerd-<id>.scope, so the prefix is the beginning of the last element, up to the first
+// digit or hexadecimal identifier. It returns an empty string for the root cgroup.
+func UnresolvedPrefix(cgroup string) string {
+	leaf := strings.TrimRight(cgroup, "/")
+	if i := strings.LastIndexByte(leaf, '/'); i >= 0 {
+		leaf = leaf[i+1:]
+	}
+	if leaf == "" {
+		return ""
+	}
+
+	var prefix strings.Builder
+	for i := 0; i < len(leaf) && prefix.Len() < maxUnresolvedPrefixLen; i++ {
+		c := leaf[i]
+		if (c >= '0' && c <= '9') || isHexRun(leaf[i:]) {
+			break
+		}
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '.', c == '-':
+			prefix.WriteByte(c)
+		default:
+			prefix.WriteByte('_')
+		}
+	}
+	if prefix.Len() == 0 {
+		return UnresolvedUnknownBucket
+	}
+	return prefix.String()
+}
+
+func isHexRun(s string) bool {
+	if len(s) < minHexRunLen {
+		return false
+	}
+	return strings.Trim(s[:minHexRunLen], containerIDCoreChars) == ""
+}
+
+// UnresolvedCounters counts the cgroups that didn't match any container ID pattern, bucketed by
+// their sanitized prefix, see UnresolvedPrefix. The number of buckets is bounded so that unexpected
+// paths can't blow up the cardinality of the metrics.
+type UnresolvedCounters struct {
+	sync.Mutex
+	counts map[string]int64
+}
+
+// NewUnresolvedCounters returns a new set of unresolved cgroup counters
+func NewUnresolvedCounters() *UnresolvedCounters {
+	return &UnresolvedCounters{
+		counts: make(map[string]int64),
+	}
+}
+
+// IsHostCGroup returns whether a cgroup belongs to the processes of the host rather than to a
+// container: the systemd services, the user sessions and slices, and the root and init cgroups.
+// The scopes of the other slices aren't host cgroups, that's where the runtimes place the containers.
+func IsHostCGroup(cgroup string) bool {
+	if strings.Trim(cgroup, "/") == "" {
+		return true
+	}
+	systemdCGroup, ok := ParseSystemdCGroup(cgroup)
+	if !ok {
+		return false
+	}
+	switch {
+	case systemdCGroup.Unit == "", systemdCGroup.User != "":
+		return true
+	case strings.HasSuffix(systemdCGroup.Unit, ".service"):
+		return true
+	case systemdCGroup.Unit == "init.scope", strings.HasPrefix(systemdCGroup.Unit, "session-"):
+		return true
+	}
+	return false
+}
+
+// Add counts a cgroup path that didn't match any container ID pattern. The host cgroups aren't
+// counted, see IsHostCGroup.
+func (c *UnresolvedCounters) Add(cgroup string) {
+	if IsHostCGroup(cgroup) {
+		return
+	}
+	prefix := UnresolvedPrefix(cgroup)
+	if prefix == "" {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, found := c.counts[prefix]; !found && len(c.counts) >= maxUnresolvedBuckets {
+		prefix = UnresolvedOtherBucket
+	}
+	c.counts[prefix]++
+}
+
+// Swap returns the counts per bucket and resets them
+func (c *UnresolvedCounters) Swap() map[string]int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	counts := c.counts
+	c.counts = make(map[string]int64)
+	return counts
+}
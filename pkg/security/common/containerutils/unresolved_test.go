@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package containerutils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnresolvedPrefix(t *testing.T) {
+	testCases := []testCase{
+		{ // root cgroup
+			input:  "/",
+			output: "",
+		},
+		{ // systemd service
+			input:  "/system.slice/sshd.service",
+			output: "sshd.service",
+		},
+		{ // systemd session
+			input:  "/user.slice/user-1000.slice/session-3.scope",
+			output: "session-",
+		},
+		{ // hexadecimal identifier
+			input:  "/kubepods.slice/unknown-runtime-c40dff48f1d53c3f07a50aa12bb9ae0e.scope",
+			output: "unknown-runtime-",
+		},
+		{ // uuid
+			input:  "/user.slice/user-1000.slice/user@1000.service/apps.slice/apps-org.gnome.Terminal.slice/vte-spawn-f9176c6a-2a34-4ce2-86af-60d16888ed8e.scope",
+			output: "vte-spawn-",
+		},
+		{ // special characters and trailing slash
+			input:  "/runtime:pod+ctr/",
+			output: "runtime_pod_ctr",
+		},
+		{ // numeric only
+			input:  "/1234",
+			output: UnresolvedUnknownBucket,
+		},
+		{ // truncated
+			input:  "/averyveryveryveryveryverylongcgroupname",
+			output: "averyveryveryveryveryverylongcgr",
+		},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.output, UnresolvedPrefix(test.input), test.input)
+	}
+}
+
+func TestIsHostCGroup(t *testing.T) {
+	for cgroup, host := range map[string]bool{
+		"/":                          true,
+		"/init.scope":                true,
+		"/system.slice":              true,
+		"/system.slice/sshd.service": true,
+		"/user.slice/user-1000.slice/session-3.scope":                                      true,
+		"/user.slice/user-1000.slice/user@1000.service/app.slice/vte-spawn-f9176c6a.scope": true,
+		"/system.slice/unknown-runtime-c40dff48f1d53c3f07a50aa12bb9ae0e.scope":             false,
+		"/kubepods/besteffort/pod1234/unknown":                                             false,
+	} {
+		assert.Equal(t, host, IsHostCGroup(cgroup), cgroup)
+	}
+}
+
+func TestUnresolvedCounters(t *testing.T) {
+	counters := NewUnresolvedCounters()
+	counters.Add("/")
+	// host cgroups
+	counters.Add("/user.slice/user-1000.slice/session-3.scope")
+	counters.Add("/system.slice/sshd.service")
+	counters.Add("/init.scope")
+	// unknown runtimes
+	counters.Add("/kubepods/unknown-runtime-c40dff48f1d53c3f07a50aa12bb9ae0e")
+	counters.Add("/kubepods/unknown-runtime-d40dff48f1d53c3f07a50aa12bb9ae0e")
+	counters.Add("/machine.slice/vm-runtime-c40dff48f1d53c3f07a50aa12bb9ae0e.scope")
+	assert.Equal(t, map[string]int64{"unknown-runtime-": 2, "vm-runtime-": 1}, counters.Swap())
+	assert.Empty(t, counters.Swap())
+
+	for i := 0; i < maxUnresolvedBuckets+10; i++ {
+		counters.Add(fmt.Sprintf("/kubepods/runtime-%c%c", 'a'+rune(i/26), 'a'+rune(i%26)))
+	}
+	counts := counters.Swap()
+	assert.Len(t, counts, maxUnresolvedBuckets+1)
+	assert.Equal(t, int64(10), counts[UnresolvedOtherBucket])
+}
@@ -7,6 +7,8 @@
 package containerutils
 
 import (
+	"bufio"
+	"io"
 	"regexp"
 	"strings"
 )
@@ -17,9 +19,17 @@ import (
 // ([0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){4}) is container id used by Garden, length: 28
 var ContainerIDPatternStr = "([0-9a-fA-F]{64})|([0-9a-fA-F]{32}-\\d+)|([0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){4})"
 var containerIDPattern = regexp.MustCompile(ContainerIDPatternStr)
+var fullContainerIDPattern = regexp.MustCompile("^(?:" + ContainerIDPatternStr + ")$")
 
 var containerIDCoreChars = "0123456789abcdefABCDEF"
 
+// maxContainerIDRunLength bounds the sequences of container ID characters buffered by FindAllContainerIDs
+const maxContainerIDRunLength = 4096
+
+// containerRuntimePrefixes are the prefixes added by the runtimes to the container ID in the name
+// of the cgroup of a container, e.g. cri-containerd-<id>.scope when the cgroups are managed by systemd
+var containerRuntimePrefixes = []string{"cri-containerd-", "containerd-", "docker-", "crio-", "libpod-", "nerdctl-"}
+
 // FindContainerID extracts the first sub string that matches the pattern of a container ID
 func FindContainerID(s string) string {
 	match := containerIDPattern.FindIndex([]byte(s))
@@ -46,3 +56,119 @@ func FindContainerID(s string) string {
 	}
 	return s[match[0]:match[1]]
 }
+
+// FindContainerIDInCgroup extracts the container ID of a cgroup path. The elements of the path are
+// checked from the innermost to the outermost, once stripped of their runtime prefix and systemd
+// unit suffix, so that the ID is found whatever the slices the container is nested in, and isn't
+// shadowed by an identifier of a parent cgroup such as a pod UID. It falls back to FindContainerID
+// for the paths not holding the ID in an element of its own.
+func FindContainerIDInCgroup(cgroup string) string {
+	elements := strings.Split(strings.Trim(cgroup, "/"), "/")
+	for i := len(elements) - 1; i >= 0; i-- {
+		if containerID := containerIDFromCgroupElement(elements[i]); containerID != "" {
+			return containerID
+		}
+	}
+	return FindContainerID(cgroup)
+}
+
+func containerIDFromCgroupElement(element string) string {
+	for _, suffix := range systemdUnitSuffixes {
+		element = strings.TrimSuffix(element, suffix)
+	}
+	for _, prefix := range containerRuntimePrefixes {
+		if containerID, ok := strings.CutPrefix(element, prefix); ok {
+			element = containerID
+			break
+		}
+	}
+	if !fullContainerIDPattern.MatchString(element) {
+		return ""
+	}
+	return element
+}
+
+// FindAllContainerIDs extracts the distinct sub strings of the content of the reader that match the
+// pattern of a container ID, in their order of appearance, with the same delimitation rules as
+// FindContainerID. The content is read in chunks, so that large inputs such as mountinfo files can
+// be parsed without loading them in memory. Sequences of container ID characters longer than
+// maxContainerIDRunLength are skipped.
+func FindAllContainerIDs(r io.Reader) ([]string, error) {
+	var containerIDs []string
+	seen := make(map[string]struct{})
+
+	// container IDs can't span characters outside of the pattern, the content is split in runs
+	// of pattern characters that are matched one by one
+	splitter := &containerIDRunSplitter{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, maxContainerIDRunLength), maxContainerIDRunLength)
+	scanner.Split(splitter.split)
+	for scanner.Scan() {
+		run := scanner.Bytes()
+		for _, match := range containerIDPattern.FindAllIndex(run, -1) {
+			if match[0] != 0 && isContainerIDCoreChar(run[match[0]-1]) {
+				continue
+			}
+			if match[1] < len(run) && isContainerIDCoreChar(run[match[1]]) {
+				continue
+			}
+			containerID := string(run[match[0]:match[1]])
+			if _, found := seen[containerID]; found {
+				continue
+			}
+			seen[containerID] = struct{}{}
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return containerIDs, nil
+}
+
+func isContainerIDCoreChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isContainerIDChar(c byte) bool {
+	return c == '-' || isContainerIDCoreChar(c)
+}
+
+// containerIDRunSplitter is a bufio.SplitFunc returning the runs of container ID characters
+type containerIDRunSplitter struct {
+	// skipping is set while skipping the remainder of a run longer than maxContainerIDRunLength
+	skipping bool
+}
+
+func (s *containerIDRunSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	if s.skipping {
+		for start < len(data) && isContainerIDChar(data[start]) {
+			start++
+		}
+		if start == len(data) {
+			return start, nil, nil
+		}
+		s.skipping = false
+	}
+	for start < len(data) && !isContainerIDChar(data[start]) {
+		start++
+	}
+	end := start
+	for end < len(data) && isContainerIDChar(data[end]) {
+		end++
+	}
+
+	switch {
+	case end < len(data) || (atEOF && end > start):
+		return end, data[start:end], nil
+	case end-start >= maxContainerIDRunLength:
+		s.skipping = true
+		return end, nil, nil
+	case atEOF:
+		return end, nil, nil
+	default:
+		// the run may continue in the data not read yet
+		return start, nil, nil
+	}
+}
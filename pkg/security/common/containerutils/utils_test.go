@@ -9,7 +9,11 @@
 package containerutils
 
 import (
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -91,3 +95,146 @@ func TestFindContainerID(t *testing.T) {
 		assert.Equal(t, test.output, FindContainerID(test.input))
 	}
 }
+
+func TestFindContainerIDInCgroup(t *testing.T) {
+	testCases := []testCase{
+		{ // kubernetes with the systemd cgroup driver
+			input:  "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod48d25824_cbe2_4fdc_9928_5bb49e05473d.slice/cri-containerd-c40dff48f1d53c3f07a50aa12bb9ae0e58c0927dc6b1d77e3f166784722642ad.scope",
+			output: "c40dff48f1d53c3f07a50aa12bb9ae0e58c0927dc6b1d77e3f166784722642ad",
+		},
+		{ // GKE Autopilot, the pod UID matches the Garden pattern
+			input:  "/kubepods/burstable/pod0d26b6a2-6a1c-4b8f-a7b3-1a3c2d7e8f90/5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f",
+			output: "5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f",
+		},
+		{ // GKE Autopilot, pods nested in a custom slice
+			input:  "/system.slice/gke-autopilot.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0d26b6a2_6a1c_4b8f_a7b3_1a3c2d7e8f90.slice/cri-containerd-5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f.scope",
+			output: "5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f",
+		},
+		{ // rke2 with the systemd cgroup driver and a custom slice
+			input:  "/rke2.slice/rke2-kubepods.slice/rke2-kubepods-pod3f1e2d4c_5b6a_4978_8a9b_0c1d2e3f4a5b.slice/containerd-9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39281706f5e4d3c2b1a0.scope",
+			output: "9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39281706f5e4d3c2b1a0",
+		},
+		{ // rke2 with the cgroupfs driver
+			input:  "/kubepods/besteffort/pod3f1e2d4c-5b6a-4978-8a9b-0c1d2e3f4a5b/9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39281706f5e4d3c2b1a0",
+			output: "9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39281706f5e4d3c2b1a0",
+		},
+		{ // docker
+			input:  "/system.slice/docker-cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0.scope",
+			output: "cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0",
+		},
+		{ // podman
+			input:  "/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0.scope/container",
+			output: "cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0",
+		},
+		{ // ECS, the ID isn't in an element of its own
+			input:  "/ecs/0123456789aAbBcCdDeEfF0123456789/0123456789aAbBcCdDeEfF0123456789-012345678",
+			output: "0123456789aAbBcCdDeEfF0123456789-012345678",
+		},
+		{ // systemd scope of a user session
+			input:  "/user.slice/user-1000.slice/user@1000.service/apps.slice/apps-org.gnome.Terminal.slice/vte-spawn-f9176c6a-2a34-4ce2-86af-60d16888ed8e.scope",
+			output: "",
+		},
+		{ // root cgroup
+			input:  "/",
+			output: "",
+		},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.output, FindContainerIDInCgroup(test.input), test.input)
+	}
+}
+
+func TestFindAllContainerIDs(t *testing.T) {
+	testCases := []struct {
+		input  string
+		output []string
+	}{
+		{ // mountinfo of a docker container
+			input: `1085 1047 0:96 / / rw,relatime master:480 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/GDZ3:/var/lib/docker/overlay2/l/HEXQ,upperdir=/var/lib/docker/overlay2/8a4d/diff
+1092 1085 259:1 /var/lib/docker/containers/cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/nvme0n1p1 rw
+1093 1085 259:1 /var/lib/docker/containers/cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0/hostname /etc/hostname rw,relatime - ext4 /dev/nvme0n1p1 rw
+1094 1085 259:1 /var/lib/docker/containers/cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0/hosts /etc/hosts rw,relatime - ext4 /dev/nvme0n1p1 rw
+`,
+			output: []string{"cc1f5c8e0ba2e0e1be6e4d8a7a8f44ab48e12c8a4e55e1d7c24e7d5b56e9a8e0"},
+		},
+		{ // cgroup v1 of a kubernetes container, the pod UID isn't a container ID
+			input: `12:memory:/kubepods/burstable/pod0d26b6a2-6a1c-4b8f-a7b3-1a3c2d7e8f90/5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f
+11:cpu,cpuacct:/kubepods/burstable/pod0d26b6a2-6a1c-4b8f-a7b3-1a3c2d7e8f90/5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f
+`,
+			output: []string{"5b1c7e6e8d0b4a2f9e3d1c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f"},
+		},
+		{ // multiple
+			input: "prefixaAbBcCdDeEfF2345678901234567890123456789012345678901234567890123-0123456789012345678901234567890123456789012345678901234567890123-9999999999999999999999999999999999999999999999999999999999999999suffix",
+			output: []string{
+				"aAbBcCdDeEfF2345678901234567890123456789012345678901234567890123",
+				"0123456789012345678901234567890123456789012345678901234567890123",
+				"9999999999999999999999999999999999999999999999999999999999999999",
+			},
+		},
+		{ // ECS and Garden
+			input:  "/ecs/0123456789aAbBcCdDeEfF0123456789/0123456789aAbBcCdDeEfF0123456789-012345678 /docker/01234567-0123-4567-890a-bcde",
+			output: []string{"0123456789aAbBcCdDeEfF0123456789-012345678", "01234567-0123-4567-890a-bcde"},
+		},
+		{ // too long to be delimited
+			input: "0123456789012345678901234567890123456789012345678901234567890123456789",
+		},
+		{ // overlong runs are skipped
+			input:  strings.Repeat("0", maxContainerIDRunLength+10) + "/aAbBcCdDeEfF2345678901234567890123456789012345678901234567890123",
+			output: []string{"aAbBcCdDeEfF2345678901234567890123456789012345678901234567890123"},
+		},
+		{
+			input: "",
+		},
+	}
+
+	for _, test := range testCases {
+		containerIDs, err := FindAllContainerIDs(strings.NewReader(test.input))
+		assert.NoError(t, err)
+		assert.Equal(t, test.output, containerIDs)
+
+		// the runs split across reads are reassembled
+		containerIDs, err = FindAllContainerIDs(iotest.OneByteReader(strings.NewReader(test.input)))
+		assert.NoError(t, err)
+		assert.Equal(t, test.output, containerIDs)
+	}
+
+	_, err := FindAllContainerIDs(iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// benchmarkMountinfo returns the content of a mountinfo file listing the mounts of the given number
+// of containers
+func benchmarkMountinfo(containers int) string {
+	var b strings.Builder
+	for i := 0; i < containers; i++ {
+		containerID := fmt.Sprintf("%064x", i)
+		fmt.Fprintf(&b, "%d 1047 0:96 / /run/containerd/io.containerd.runtime.v2.task/k8s.io/%s/rootfs rw,relatime - overlay overlay rw,lowerdir=/var/lib/containerd/snapshots/%d/fs\n", 2000+i, containerID, i)
+		fmt.Fprintf(&b, "%d 1085 259:1 /var/lib/docker/containers/%s/hostname /etc/hostname rw,relatime - ext4 /dev/nvme0n1p1 rw\n", 3000+i, containerID)
+	}
+	return b.String()
+}
+
+func BenchmarkFindAllContainerIDs(b *testing.B) {
+	mountinfo := benchmarkMountinfo(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := FindAllContainerIDs(strings.NewReader(mountinfo)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindAllContainerIDsRegexp(b *testing.B) {
+	mountinfo := benchmarkMountinfo(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		content, err := io.ReadAll(strings.NewReader(mountinfo))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = containerIDPattern.FindAllString(string(content), -1)
+	}
+}
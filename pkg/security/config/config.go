@@ -59,6 +59,17 @@ type RuntimeSecurityConfig struct {
 	EventServerRate int
 	// EventServerRetention defines an event retention period so that some fields can be resolved
 	EventServerRetention time.Duration
+	// ContainerRateLimiterRate defines the number of events per second each container is allowed to generate,
+	// on top of the per rule limits. Leave this parameter to 0 to disable the per container rate limiting.
+	ContainerRateLimiterRate int
+	// ContainerRateLimiterBurst defines the maximum burst of events each container is allowed to generate
+	ContainerRateLimiterBurst int
+	// ContainerRateLimiterPerContainerMetrics tags the per container rate limiter metrics with the container ID,
+	// they are aggregated over all the containers otherwise
+	ContainerRateLimiterPerContainerMetrics bool
+	// IgnoreSandboxContainers defines whether the events of the sandbox (pause) containers of the Kubernetes pods
+	// are ignored by the rules that don't reference container.is_sandbox
+	IgnoreSandboxContainers bool
 	// FIMEnabled determines whether fim rules will be loaded
 	FIMEnabled bool
 	// SelfTestEnabled defines if the self tests should be executed at startup or not
@@ -312,6 +323,11 @@ func NewRuntimeSecurityConfig() (*RuntimeSecurityConfig, error) {
 		EventServerRate:      coreconfig.SystemProbe.GetInt("runtime_security_config.event_server.rate"),
 		EventServerRetention: coreconfig.SystemProbe.GetDuration("runtime_security_config.event_server.retention"),
 
+		ContainerRateLimiterRate:                coreconfig.SystemProbe.GetInt("runtime_security_config.container_rate_limiter.rate"),
+		ContainerRateLimiterBurst:               coreconfig.SystemProbe.GetInt("runtime_security_config.container_rate_limiter.burst"),
+		ContainerRateLimiterPerContainerMetrics: coreconfig.SystemProbe.GetBool("runtime_security_config.container_rate_limiter.per_container_metrics"),
+		IgnoreSandboxContainers:                 coreconfig.SystemProbe.GetBool("runtime_security_config.ignore_sandbox_containers"),
+
 		SelfTestEnabled:                 coreconfig.SystemProbe.GetBool("runtime_security_config.self_test.enabled"),
 		SelfTestSendReport:              coreconfig.SystemProbe.GetBool("runtime_security_config.self_test.send_report"),
 		RemoteConfigurationEnabled:      isRemoteConfigEnabled(),
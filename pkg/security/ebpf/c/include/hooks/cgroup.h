@@ -90,6 +90,9 @@ static __attribute__((always_inline)) int trace__cgroup_write(ctx_t *ctx) {
     if (prefix[0] == 'c' && prefix[1] == 'r' && prefix[2] == 'i' && prefix[3] == '-' && prefix[4] == 'c' && prefix[5] == 'o' && prefix[6] == 'n' && prefix[7] == 't' && prefix[8] == 'a' && prefix[9] == 'i' && prefix[10] == 'n' && prefix[11] == 'e' && prefix[12] == 'r' && prefix[13] == 'd' && prefix[14] == '-') {
         container_id += 15; // skip "cri-containerd-"
     }
+    if (prefix[0] == 'c' && prefix[1] == 'o' && prefix[2] == 'n' && prefix[3] == 't' && prefix[4] == 'a' && prefix[5] == 'i' && prefix[6] == 'n' && prefix[7] == 'e' && prefix[8] == 'r' && prefix[9] == 'd' && prefix[10] == '-') {
+        container_id += 11; // skip "containerd-"
+    }
 
     bpf_probe_read(&new_entry.container.container_id, sizeof(new_entry.container.container_id), container_id);
     if (!is_container_id_valid(new_entry.container.container_id)) {
@@ -117,12 +117,27 @@ func NewAnomalyDetectionLimiter(numWorkloads int, numEventsAllowedPerPeriod int,
 	}, nil
 }
 
+// ContainerEvent is implemented by the events that can be generated from a container
+type ContainerEvent interface {
+	GetContainerID() string
+}
+
+// ContainerEventRate defines the rate limiter of the events generated by a container
+type ContainerEventRate interface {
+	Allow(limit rate.Limit, burst int) bool
+}
+
+// ContainerEventRateResolver returns the event rate of a container, nil if it is unknown
+type ContainerEventRateResolver func(containerID string) ContainerEventRate
+
 // RateLimiter describes a set of rule rate limiters
 type RateLimiter struct {
 	sync.RWMutex
 	limiters     map[rules.RuleID]Limiter
 	statsdClient statsd.ClientInterface
 	config       *config.RuntimeSecurityConfig
+
+	containerEventRateResolver ContainerEventRateResolver
 }
 
 // NewRateLimiter initializes an empty rate limiter
@@ -175,6 +190,41 @@ func (rl *RateLimiter) Apply(ruleSet *rules.RuleSet, customRuleIDs []eval.RuleID
 	rl.limiters = newLimiters
 }
 
+// SetContainerEventRateResolver sets the resolver of the container event rates, used to apply the per
+// container limit
+func (rl *RateLimiter) SetContainerEventRateResolver(resolver ContainerEventRateResolver) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	rl.containerEventRateResolver = resolver
+}
+
+// allowContainer returns whether the container of the event is allowed to send a new event. Only the
+// events of the rules without a specific limiter count towards the limit of their container.
+func (rl *RateLimiter) allowContainer(ruleID string, event Event) bool {
+	if rl.containerEventRateResolver == nil || rl.config.ContainerRateLimiterRate <= 0 {
+		return true
+	}
+	if _, found := defaultPerRuleLimiters[ruleID]; found || ruleID == AnomalyDetectionRuleID {
+		return true
+	}
+
+	containerEvent, ok := event.(ContainerEvent)
+	if !ok {
+		return true
+	}
+	containerID := containerEvent.GetContainerID()
+	if containerID == "" {
+		return true
+	}
+
+	eventRate := rl.containerEventRateResolver(containerID)
+	if eventRate == nil {
+		return true
+	}
+	return eventRate.Allow(rate.Limit(rl.config.ContainerRateLimiterRate), rl.config.ContainerRateLimiterBurst)
+}
+
 // Allow returns true if a specific rule shall be allowed to sent a new event
 func (rl *RateLimiter) Allow(ruleID string, event Event) bool {
 	rl.RLock()
@@ -184,7 +234,11 @@ func (rl *RateLimiter) Allow(ruleID string, event Event) bool {
 	if !ok {
 		return false
 	}
-	return limiter.Allow(event)
+	// the events dropped by the rule limiter aren't charged to their container
+	if !limiter.Allow(event) {
+		return false
+	}
+	return rl.allowContainer(ruleID, event)
 }
 
 // GetStats returns a map indexed by ids that describes the amount of events
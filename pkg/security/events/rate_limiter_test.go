@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+
+	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/model"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/rules"
+)
+
+type testContainerEvent struct {
+	containerID string
+}
+
+func (e *testContainerEvent) GetWorkloadID() string                  { return "" }
+func (e *testContainerEvent) GetTags() []string                      { return nil }
+func (e *testContainerEvent) GetType() string                        { return "exec" }
+func (e *testContainerEvent) GetActionReports() []model.ActionReport { return nil }
+func (e *testContainerEvent) GetContainerID() string                 { return e.containerID }
+
+type testContainerEventRate struct {
+	limiter *rate.Limiter
+}
+
+func (r *testContainerEventRate) Allow(limit rate.Limit, burst int) bool {
+	if r.limiter == nil {
+		r.limiter = rate.NewLimiter(limit, burst)
+	}
+	return r.limiter.Allow()
+}
+
+func TestContainerRateLimiter(t *testing.T) {
+	cfg := &config.RuntimeSecurityConfig{
+		ContainerRateLimiterRate:  1,
+		ContainerRateLimiterBurst: 2,
+	}
+	rl := NewRateLimiter(cfg, nil)
+	rl.Apply(&rules.RuleSet{}, []string{"rule_a", "rule_b"})
+
+	eventRates := map[string]*testContainerEventRate{
+		"noisy": {},
+		"quiet": {},
+	}
+	rl.SetContainerEventRateResolver(func(containerID string) ContainerEventRate {
+		if eventRate, found := eventRates[containerID]; found {
+			return eventRate
+		}
+		return nil
+	})
+
+	noisy := &testContainerEvent{containerID: "noisy"}
+	assert.True(t, rl.Allow("rule_a", noisy))
+	assert.True(t, rl.Allow("rule_b", noisy))
+	assert.False(t, rl.Allow("rule_a", noisy))
+
+	// the other containers and the hosts events aren't affected
+	assert.True(t, rl.Allow("rule_a", &testContainerEvent{containerID: "quiet"}))
+	assert.True(t, rl.Allow("rule_a", &testContainerEvent{}))
+	assert.True(t, rl.Allow("rule_a", &testContainerEvent{containerID: "unknown"}))
+
+	// nor the rules with a specific limiter
+	assert.True(t, rl.Allow(HeartbeatRuleID, noisy))
+
+	// disabled
+	cfg.ContainerRateLimiterRate = 0
+	assert.True(t, rl.Allow("rule_b", noisy))
+}
+
+func TestContainerRateLimiterRuleDrops(t *testing.T) {
+	cfg := &config.RuntimeSecurityConfig{
+		ContainerRateLimiterRate:  1,
+		ContainerRateLimiterBurst: 2,
+	}
+	rl := NewRateLimiter(cfg, nil)
+	rl.Apply(&rules.RuleSet{}, []string{"rule_a", "rule_b"})
+	// rule_b only allows a single event
+	rl.limiters["rule_b"] = NewStdLimiter(0, 1)
+
+	eventRate := &testContainerEventRate{}
+	rl.SetContainerEventRateResolver(func(string) ContainerEventRate { return eventRate })
+
+	noisy := &testContainerEvent{containerID: "noisy"}
+	assert.True(t, rl.Allow("rule_b", noisy))
+	assert.False(t, rl.Allow("rule_b", noisy))
+	assert.False(t, rl.Allow("rule_b", noisy))
+
+	// the events dropped by the rule limiter didn't use the budget of the container
+	assert.True(t, rl.Allow("rule_a", noisy))
+	assert.False(t, rl.Allow("rule_a", noisy))
+}
@@ -32,6 +32,14 @@ var (
 	// MetricRateLimiterAllow is the name of the metric used to count the amount of events allowed by the rate limiter
 	// Tags: rule_id
 	MetricRateLimiterAllow = newRuntimeMetric(".rules.rate_limiter.allow")
+	// MetricContainerRateLimiterDrop is the name of the metric used to count the amount of events of a container dropped by
+	// the per container rate limiter
+	// Tags: container_id, if runtime_security_config.container_rate_limiter.per_container_metrics is enabled
+	MetricContainerRateLimiterDrop = newRuntimeMetric(".rules.container_rate_limiter.drop")
+	// MetricContainerRateLimiterAllow is the name of the metric used to count the amount of events of a container allowed
+	// by the per container rate limiter
+	// Tags: container_id, if runtime_security_config.container_rate_limiter.per_container_metrics is enabled
+	MetricContainerRateLimiterAllow = newRuntimeMetric(".rules.container_rate_limiter.allow")
 
 	// Rule Suppression metrics
 
@@ -172,6 +180,13 @@ var (
 	// Tags: -
 	MetricProcessInodeError = newRuntimeMetric(".process_resolver.inode_error")
 
+	// Container resolver metrics
+
+	// MetricContainerResolverUnresolved is the name of the metric used to report the cgroups that didn't match
+	// any container ID pattern, bucketed by a sanitized prefix of the cgroup
+	// Tags: cgroup_prefix
+	MetricContainerResolverUnresolved = newRuntimeMetric(".container_resolver.unresolved")
+
 	// Mount resolver metrics
 
 	// MetricMountResolverCacheSize is the name of the metric used to report the size of the user space
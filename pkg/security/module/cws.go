@@ -84,6 +84,8 @@ func NewCWSConsumer(evm *eventmonitor.EventMonitor, cfg *config.RuntimeSecurityC
 		reloader:      NewReloader(),
 	}
 
+	c.rateLimiter.SetContainerEventRateResolver(c.probe.GetContainerEventRate)
+
 	// set sender
 	if opts.EventSender != nil {
 		c.eventSender = opts.EventSender
@@ -496,6 +496,27 @@ func (fh *EBPFFieldHandlers) ResolveContainerCreatedAt(ev *model.Event, e *model
 	return int(e.CreatedAt)
 }
 
+// ResolveContainerCGroupCreatedAt resolves the creation time of the cgroup of the container of the event
+func (fh *EBPFFieldHandlers) ResolveContainerCGroupCreatedAt(ev *model.Event, e *model.ContainerContext) int {
+	if e.CGroupCreatedAt == 0 {
+		if containerContext, _ := fh.ResolveContainerContext(ev); containerContext != nil {
+			e.CGroupCreatedAt = containerContext.CGroupCreatedAt
+		}
+	}
+	return int(e.CGroupCreatedAt)
+}
+
+// ResolveContainerIsSandbox resolves whether the container of the event is the sandbox container of a pod
+func (fh *EBPFFieldHandlers) ResolveContainerIsSandbox(_ *model.Event, e *model.ContainerContext) bool {
+	if e.ID != "" {
+		// the container may be flagged as a sandbox after its first event, once its executable or image is known
+		if workload, found := fh.resolvers.CGroupResolver.GetWorkload(e.ID); found {
+			return workload.IsSandboxContainer()
+		}
+	}
+	return e.IsSandbox
+}
+
 // ResolveContainerTags resolves the container tags of the event
 func (fh *EBPFFieldHandlers) ResolveContainerTags(_ *model.Event, e *model.ContainerContext) []string {
 	if len(e.Tags) == 0 && e.ID != "" {
@@ -171,6 +171,21 @@ func (fh *EBPFLessFieldHandlers) ResolveContainerCreatedAt(ev *model.Event, e *m
 	return int(e.CreatedAt)
 }
 
+// ResolveContainerCGroupCreatedAt resolves the creation time of the cgroup of the container of the event
+func (fh *EBPFLessFieldHandlers) ResolveContainerCGroupCreatedAt(ev *model.Event, e *model.ContainerContext) int {
+	if e.CGroupCreatedAt == 0 {
+		if containerContext, _ := fh.ResolveContainerContext(ev); containerContext != nil {
+			e.CGroupCreatedAt = containerContext.CGroupCreatedAt
+		}
+	}
+	return int(e.CGroupCreatedAt)
+}
+
+// ResolveContainerIsSandbox resolves whether the container of the event is the sandbox container of a pod
+func (fh *EBPFLessFieldHandlers) ResolveContainerIsSandbox(_ *model.Event, e *model.ContainerContext) bool {
+	return e.IsSandbox
+}
+
 // ResolveContainerTags resolves the container tags of the event
 func (fh *EBPFLessFieldHandlers) ResolveContainerTags(_ *model.Event, e *model.ContainerContext) []string {
 	if len(e.Tags) == 0 && e.ID != "" {
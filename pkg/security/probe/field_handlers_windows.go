@@ -121,6 +121,16 @@ func (fh *FieldHandlers) ResolveContainerID(_ *model.Event, e *model.ContainerCo
 	return e.ID
 }
 
+// ResolveContainerCGroupCreatedAt resolves the creation time of the cgroup of the container of the event
+func (fh *FieldHandlers) ResolveContainerCGroupCreatedAt(_ *model.Event, e *model.ContainerContext) int {
+	return int(e.CGroupCreatedAt)
+}
+
+// ResolveContainerIsSandbox resolves whether the container of the event is the sandbox container of a pod
+func (fh *FieldHandlers) ResolveContainerIsSandbox(_ *model.Event, e *model.ContainerContext) bool {
+	return e.IsSandbox
+}
+
 // ResolveContainerTags resolves the container tags of the event
 func (fh *FieldHandlers) ResolveContainerTags(_ *model.Event, e *model.ContainerContext) []string {
 	return e.Tags
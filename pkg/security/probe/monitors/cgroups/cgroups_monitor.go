@@ -19,19 +19,48 @@ import (
 type Monitor struct {
 	statsdClient    statsd.ClientInterface
 	cgroupsResolver *cgroup.Resolver
+	// perContainerMetrics tags the rate limiter metrics with the container ID, which has a high cardinality
+	perContainerMetrics bool
 }
 
 // SendStats send stats
 func (cm *Monitor) SendStats() error {
 	count := cm.cgroupsResolver.Len()
 	_ = cm.statsdClient.Gauge(metrics.MetricRuntimeCgroupsRunning, float64(count), []string{}, 1.0)
+
+	var totalAllowed, totalDropped uint64
+	for _, workload := range cm.cgroupsResolver.GetWorkloads() {
+		if workload.EventRate == nil || workload.ID == "" {
+			continue
+		}
+		allowed, dropped := workload.EventRate.SwapStats()
+		if !cm.perContainerMetrics {
+			totalAllowed += allowed
+			totalDropped += dropped
+			continue
+		}
+		cm.sendRateLimiterStats(allowed, dropped, []string{"container_id:" + workload.ID})
+	}
+	if !cm.perContainerMetrics {
+		cm.sendRateLimiterStats(totalAllowed, totalDropped, []string{})
+	}
 	return nil
 }
 
+func (cm *Monitor) sendRateLimiterStats(allowed, dropped uint64, tags []string) {
+	if dropped > 0 {
+		_ = cm.statsdClient.Count(metrics.MetricContainerRateLimiterDrop, int64(dropped), tags, 1.0)
+	}
+	if allowed > 0 {
+		_ = cm.statsdClient.Count(metrics.MetricContainerRateLimiterAllow, int64(allowed), tags, 1.0)
+	}
+}
+
 // NewCgroupsMonitor returns a new cgroups monitor
-func NewCgroupsMonitor(statsdClient statsd.ClientInterface, cgrouspResolver *cgroup.Resolver) *Monitor {
+func NewCgroupsMonitor(statsdClient statsd.ClientInterface, cgrouspResolver *cgroup.Resolver, perContainerMetrics bool) *Monitor {
 	return &Monitor{
-		statsdClient:    statsdClient,
-		cgroupsResolver: cgrouspResolver,
+		statsdClient:        statsdClient,
+		cgroupsResolver:     cgrouspResolver,
+		perContainerMetrics: perContainerMetrics,
 	}
 }
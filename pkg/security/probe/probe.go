@@ -54,6 +54,7 @@ type PlatformProbe interface {
 	DumpProcessCache(_ bool) (string, error)
 	AddDiscarderPushedCallback(_ DiscarderPushedCallback)
 	GetEventTags(_ string) []string
+	GetContainerEventRate(_ string) events.ContainerEventRate
 }
 
 // EventHandler represents a handler for events sent by the probe that needs access to all the fields in the SECL model
@@ -354,6 +355,11 @@ func (p *Probe) GetEventTags(containerID string) []string {
 	return p.PlatformProbe.GetEventTags(containerID)
 }
 
+// GetContainerEventRate returns the event rate of a container
+func (p *Probe) GetContainerEventRate(containerID string) events.ContainerEventRate {
+	return p.PlatformProbe.GetContainerEventRate(containerID)
+}
+
 // GetService returns the service name from the process tree
 func (p *Probe) GetService(ev *model.Event) string {
 	if service := ev.FieldHandlers.ResolveService(ev, &ev.BaseEvent); service != "" {
@@ -984,6 +984,15 @@ func (p *EBPFProbe) GetEventTags(containerID string) []string {
 	return p.Resolvers.TagsResolver.Resolve(containerID)
 }
 
+// GetContainerEventRate returns the event rate of a container
+func (p *EBPFProbe) GetContainerEventRate(containerID string) events.ContainerEventRate {
+	workload, found := p.Resolvers.CGroupResolver.GetWorkload(containerID)
+	if !found || workload == nil || workload.EventRate == nil {
+		return nil
+	}
+	return workload.EventRate
+}
+
 // OnNewDiscarder handles new discarders
 func (p *EBPFProbe) OnNewDiscarder(rs *rules.RuleSet, ev *model.Event, field eval.Field, eventType eval.EventType) {
 	// discarders disabled
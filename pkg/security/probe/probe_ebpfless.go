@@ -25,6 +25,7 @@ import (
 	"github.com/DataDog/datadog-go/v5/statsd"
 
 	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/events"
 	"github.com/DataDog/datadog-agent/pkg/security/probe/kfilters"
 	"github.com/DataDog/datadog-agent/pkg/security/proto/ebpfless"
 	"github.com/DataDog/datadog-agent/pkg/security/resolvers"
@@ -587,6 +588,11 @@ func (p *EBPFLessProbe) GetEventTags(containerID string) []string {
 	return p.Resolvers.TagsResolver.Resolve(containerID)
 }
 
+// GetContainerEventRate returns the event rate of a container
+func (p *EBPFLessProbe) GetContainerEventRate(_ string) events.ContainerEventRate {
+	return nil
+}
+
 func (p *EBPFLessProbe) zeroEvent() *model.Event {
 	p.event.Zero()
 	p.event.FieldHandlers = p.fieldHandlers
@@ -73,7 +73,7 @@ func (m *EBPFMonitors) Init() error {
 		}
 	}
 
-	m.cgroupsMonitor = cgroups.NewCgroupsMonitor(p.statsdClient, p.Resolvers.CGroupResolver)
+	m.cgroupsMonitor = cgroups.NewCgroupsMonitor(p.statsdClient, p.Resolvers.CGroupResolver, p.config.RuntimeSecurity.ContainerRateLimiterPerContainerMetrics)
 
 	return nil
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/events"
 	"github.com/DataDog/datadog-agent/pkg/security/probe/kfilters"
 	"github.com/DataDog/datadog-agent/pkg/security/secl/compiler/eval"
 	"github.com/DataDog/datadog-agent/pkg/security/secl/model"
@@ -80,6 +81,11 @@ func (p *Probe) GetEventTags(_ string) []string {
 	return nil
 }
 
+// GetContainerEventRate returns the event rate of a container
+func (p *Probe) GetContainerEventRate(_ string) events.ContainerEventRate {
+	return nil
+}
+
 // IsNetworkEnabled returns whether network is enabled
 func (p *Probe) IsNetworkEnabled() bool {
 	return p.Config.Probe.NetworkEnabled
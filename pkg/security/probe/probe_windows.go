@@ -23,6 +23,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/etw"
 	etwimpl "github.com/DataDog/datadog-agent/comp/etw/impl"
 	"github.com/DataDog/datadog-agent/pkg/security/config"
+	"github.com/DataDog/datadog-agent/pkg/security/events"
 	"github.com/DataDog/datadog-agent/pkg/security/metrics"
 	"github.com/DataDog/datadog-agent/pkg/security/probe/kfilters"
 	"github.com/DataDog/datadog-agent/pkg/security/resolvers"
@@ -1355,6 +1356,11 @@ func (p *WindowsProbe) GetEventTags(_ string) []string {
 	return nil
 }
 
+// GetContainerEventRate returns the event rate of a container
+func (p *WindowsProbe) GetContainerEventRate(_ string) events.ContainerEventRate {
+	return nil
+}
+
 func (p *WindowsProbe) zeroEvent() *model.Event {
 	p.event.Zero()
 	p.event.FieldHandlers = p.fieldHandlers
@@ -81,7 +81,7 @@ func getContainerIDFromProcFS(cgroupPath string) (string, error) {
 	}
 
 	for _, cgroup := range cgroups {
-		cid := containerutils.FindContainerID(cgroup.path)
+		cid := containerutils.FindContainerIDInCgroup(cgroup.path)
 		if cid != "" {
 			return cid, nil
 		}
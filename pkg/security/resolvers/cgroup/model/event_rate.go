@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package model
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+)
+
+// EventRate holds the rate limiter of the events generated by a workload, so that a noisy container
+// doesn't consume the events budget of the others
+type EventRate struct {
+	sync.Mutex
+	limiter *rate.Limiter
+
+	// stats
+	Allowed *atomic.Uint64
+	Dropped *atomic.Uint64
+}
+
+// NewEventRate returns a new event rate
+func NewEventRate() *EventRate {
+	return &EventRate{
+		Allowed: atomic.NewUint64(0),
+		Dropped: atomic.NewUint64(0),
+	}
+}
+
+// Allow returns whether a new event of the workload is allowed by the provided limit and burst
+func (er *EventRate) Allow(limit rate.Limit, burst int) bool {
+	er.Lock()
+	// (re)create the limiter with a full bucket when the limit changes
+	if er.limiter == nil || er.limiter.Limit() != limit || er.limiter.Burst() != burst {
+		er.limiter = rate.NewLimiter(limit, burst)
+	}
+	allowed := er.limiter.Allow()
+	er.Unlock()
+
+	if allowed {
+		er.Allowed.Inc()
+		return true
+	}
+	er.Dropped.Inc()
+	return false
+}
+
+// SwapStats returns the allowed and dropped events counts, and zeros them
+func (er *EventRate) SwapStats() (uint64, uint64) {
+	return er.Allowed.Swap(0), er.Dropped.Swap(0)
+}
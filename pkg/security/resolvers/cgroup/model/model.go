@@ -11,6 +11,8 @@ package model
 import (
 	"errors"
 	"fmt"
+	"path"
+	"slices"
 	"sync"
 
 	"go.uber.org/atomic"
@@ -23,6 +25,24 @@ var (
 	ErrNoImageProvided = errors.New("no image name provided") // ErrNoImageProvided is returned when no image name is provided
 )
 
+var (
+	// sandboxImages are the names of the images of the sandbox containers holding the namespaces of the
+	// Kubernetes pods, whatever their registry
+	sandboxImages = []string{"pause", "pause-amd64", "pause-arm64"}
+	// sandboxExecutables are the executables run by the sandbox containers
+	sandboxExecutables = []string{"/pause"}
+)
+
+// IsSandboxImage returns true if the image is the image of the sandbox (pause) containers
+func IsSandboxImage(image string) bool {
+	return image != "" && slices.Contains(sandboxImages, path.Base(image))
+}
+
+// IsSandboxExecutable returns true if the executable is the one run by the sandbox (pause) containers
+func IsSandboxExecutable(executable string) bool {
+	return slices.Contains(sandboxExecutables, executable)
+}
+
 // WorkloadSelector is a selector used to uniquely indentify the image of a workload
 type WorkloadSelector struct {
 	Image string
@@ -75,6 +95,10 @@ type CacheEntry struct {
 	Deleted          *atomic.Bool
 	WorkloadSelector WorkloadSelector
 	PIDs             map[uint32]int8
+	EventRate        *EventRate
+
+	// sandboxInit is set while the init process of the workload runs the executable of the sandbox containers
+	sandboxInit bool
 }
 
 // NewCacheEntry returns a new instance of a CacheEntry
@@ -84,7 +108,8 @@ func NewCacheEntry(id string, pids ...uint32) (*CacheEntry, error) {
 		ContainerContext: model.ContainerContext{
 			ID: id,
 		},
-		PIDs: make(map[uint32]int8, 10),
+		PIDs:      make(map[uint32]int8, 10),
+		EventRate: NewEventRate(),
 	}
 
 	for _, pid := range pids {
@@ -135,6 +160,34 @@ func (cgce *CacheEntry) SetTags(tags []string) {
 	if len(cgce.WorkloadSelector.Image) != 0 && len(cgce.WorkloadSelector.Tag) == 0 {
 		cgce.WorkloadSelector.Tag = "latest"
 	}
+	cgce.updateSandbox()
+}
+
+// SetSandboxInit sets whether the init process of the workload runs the executable of the sandbox containers
+func (cgce *CacheEntry) SetSandboxInit(sandboxInit bool) {
+	cgce.Lock()
+	defer cgce.Unlock()
+
+	cgce.sandboxInit = sandboxInit
+	cgce.updateSandbox()
+}
+
+// updateSandbox flags the workload as a sandbox container from the image reported by the runtime once
+// known, and from the executable of its init process until then
+func (cgce *CacheEntry) updateSandbox() {
+	if cgce.WorkloadSelector.IsReady() {
+		cgce.IsSandbox = IsSandboxImage(cgce.WorkloadSelector.Image)
+	} else {
+		cgce.IsSandbox = cgce.sandboxInit
+	}
+}
+
+// IsSandboxContainer returns true if the workload is the sandbox container of a pod
+func (cgce *CacheEntry) IsSandboxContainer() bool {
+	cgce.RLock()
+	defer cgce.RUnlock()
+
+	return cgce.IsSandbox
 }
 
 // GetWorkloadSelectorCopy returns a copy of the workload selector of this cgroup
@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSandboxImage(t *testing.T) {
+	for _, image := range []string{"pause", "registry.k8s.io/pause", "k8s.gcr.io/pause-amd64", "602401143452.dkr.ecr.us-west-2.amazonaws.com/eks/pause"} {
+		assert.True(t, IsSandboxImage(image), image)
+	}
+	for _, image := range []string{"", "nginx", "registry.k8s.io/kube-proxy", "example.com/pause-service"} {
+		assert.False(t, IsSandboxImage(image), image)
+	}
+}
+
+func TestCacheEntrySandbox(t *testing.T) {
+	entry, err := NewCacheEntry("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", 1)
+	require.NoError(t, err)
+	assert.False(t, entry.IsSandboxContainer())
+
+	// the init process is only trusted until the image is known
+	entry.SetSandboxInit(true)
+	assert.True(t, entry.IsSandboxContainer())
+	entry.SetSandboxInit(false)
+	assert.False(t, entry.IsSandboxContainer())
+
+	entry.SetSandboxInit(true)
+	entry.SetTags([]string{"image_name:nginx", "image_tag:1.27"})
+	assert.False(t, entry.IsSandboxContainer())
+
+	entry.SetTags([]string{"image_name:registry.k8s.io/pause", "image_tag:3.9"})
+	assert.True(t, entry.IsSandboxContainer())
+	assert.Equal(t, "registry.k8s.io/pause", entry.WorkloadSelector.Image)
+}
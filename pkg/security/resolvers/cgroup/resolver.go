@@ -18,8 +18,10 @@ import (
 
 	cgroupModel "github.com/DataDog/datadog-agent/pkg/security/resolvers/cgroup/model"
 	"github.com/DataDog/datadog-agent/pkg/security/resolvers/tags"
+	"github.com/DataDog/datadog-agent/pkg/security/secl/compiler/eval"
 	"github.com/DataDog/datadog-agent/pkg/security/secl/model"
 	"github.com/DataDog/datadog-agent/pkg/security/seclog"
+	"github.com/DataDog/datadog-agent/pkg/security/utils"
 )
 
 // Event defines the cgroup event type
@@ -119,9 +121,17 @@ func (cr *Resolver) AddPID(process *model.ProcessCacheEntry) {
 	cr.Lock()
 	defer cr.Unlock()
 
+	// only the executable of the init process of a container, started by the runtime, tells a sandbox
+	// container apart: any other process of a container could run the same executable
+	isInit := isContainerInit(process)
+	sandboxInit := isInit && cgroupModel.IsSandboxExecutable(process.FileEvent.PathnameStr)
+
 	entry, exists := cr.workloads.Get(process.ContainerID)
 	if exists {
 		entry.AddPID(process.Pid)
+		if isInit {
+			entry.SetSandboxInit(sandboxInit)
+		}
 		return
 	}
 
@@ -133,6 +143,30 @@ func (cr *Resolver) AddPID(process *model.ProcessCacheEntry) {
 		return
 	}
 	newCGroup.CreatedAt = uint64(process.ProcessContext.ExecTime.UnixNano())
+	if isInit {
+		newCGroup.SetSandboxInit(sandboxInit)
+	}
+	// the first process we see may have been started long after the container, when the agent
+	// starts or restarts for instance, prefer the creation time of the container cgroup. Its
+	// change time can only move forward so the earliest of both is kept.
+	createdAt, err := utils.GetProcContainerCreationTime(process.Pid, process.Pid, utils.ContainerID(process.ContainerID))
+	if err != nil {
+		seclog.Debugf("couldn't get creation time of container %s: %v", process.ContainerID, err)
+	} else {
+		newCGroup.CGroupCreatedAt = uint64(createdAt.UnixNano())
+		if newCGroup.CreatedAt == 0 || newCGroup.CGroupCreatedAt < newCGroup.CreatedAt {
+			newCGroup.CreatedAt = newCGroup.CGroupCreatedAt
+		}
+	}
+
+	// rules can reference containers by their short ID, warn when it doesn't identify a single container
+	if shortID := shortContainerID(process.ContainerID); shortID != "" {
+		for _, id := range cr.workloads.Keys() {
+			if shortContainerID(id) == shortID {
+				seclog.Warnf("containers %s and %s share the short ID %s, rules using it match both", id, process.ContainerID, shortID)
+			}
+		}
+	}
 
 	// add the new CGroup to the cache
 	cr.workloads.Add(process.ContainerID, newCGroup)
@@ -141,6 +175,16 @@ func (cr *Resolver) AddPID(process *model.ProcessCacheEntry) {
 	cr.checkTags(newCGroup)
 }
 
+// isContainerInit returns true if the process is the init process of its container, started by the
+// runtime from outside of the container, whatever it executed since
+func isContainerInit(process *model.ProcessCacheEntry) bool {
+	ancestor := process.Ancestor
+	for ancestor != nil && ancestor.Pid == process.Pid {
+		ancestor = ancestor.Ancestor
+	}
+	return ancestor != nil && ancestor.ContainerID != process.ContainerID
+}
+
 // checkTags checks if the tags of a workload were properly set
 func (cr *Resolver) checkTags(workload *cgroupModel.CacheEntry) {
 	// check if the workload tags were found
@@ -156,6 +200,12 @@ func (cr *Resolver) checkTags(workload *cgroupModel.CacheEntry) {
 		}
 	}
 
+	// sandbox containers are neither profiled nor scanned
+	if workload.IsSandboxContainer() {
+		seclog.Debugf("ignoring the sandbox container %s", workload.ID)
+		return
+	}
+
 	// notify listeners
 	cr.listenersLock.Lock()
 	defer cr.listenersLock.Unlock()
@@ -182,6 +232,14 @@ func (cr *Resolver) GetWorkload(id string) (*cgroupModel.CacheEntry, bool) {
 	return cr.workloads.Get(id)
 }
 
+// GetWorkloads returns the workloads currently tracked by the resolver
+func (cr *Resolver) GetWorkloads() []*cgroupModel.CacheEntry {
+	cr.RLock()
+	defer cr.RUnlock()
+
+	return cr.workloads.Values()
+}
+
 // DelPID removes a PID from the cgroup resolver
 func (cr *Resolver) DelPID(pid uint32) {
 	cr.Lock()
@@ -226,3 +284,11 @@ func (cr *Resolver) Len() int {
 
 	return cr.workloads.Len()
 }
+
+// shortContainerID returns the short ID of a container, as displayed by container runtimes
+func shortContainerID(id string) string {
+	if len(id) <= eval.ShortContainerIDLength {
+		return ""
+	}
+	return id[:eval.ShortContainerIDLength]
+}
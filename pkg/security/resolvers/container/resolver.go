@@ -9,14 +9,67 @@
 package container
 
 import (
+	"fmt"
+	"slices"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"github.com/DataDog/datadog-agent/pkg/security/common/containerutils"
+	"github.com/DataDog/datadog-agent/pkg/security/metrics"
 	"github.com/DataDog/datadog-agent/pkg/security/utils"
 )
 
 // Resolver is used to resolve the container context of the events
-type Resolver struct{}
+type Resolver struct {
+	statsdClient statsd.ClientInterface
+	unresolved   *containerutils.UnresolvedCounters
+}
+
+// NewResolver returns a new container resolver
+func NewResolver(statsdClient statsd.ClientInterface) *Resolver {
+	return &Resolver{
+		statsdClient: statsdClient,
+		unresolved:   containerutils.NewUnresolvedCounters(),
+	}
+}
 
 // GetContainerID returns the container id of the given pid
 func (cr *Resolver) GetContainerID(pid uint32) (utils.ContainerID, error) {
 	// Parse /proc/[pid]/task/[pid]/cgroup
-	return utils.GetProcContainerID(pid, pid)
+	cgroups, err := utils.GetProcControlGroups(pid, pid)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cgroup := range cgroups {
+		if containerID := cgroup.GetContainerID(); containerID != "" {
+			return containerID, nil
+		}
+	}
+
+	if cr.unresolved == nil {
+		return "", nil
+	}
+	// cgroup v1 hierarchies usually share the same path, count each path once
+	var paths []string
+	for _, cgroup := range cgroups {
+		if !slices.Contains(paths, cgroup.Path) {
+			paths = append(paths, cgroup.Path)
+			cr.unresolved.Add(cgroup.Path)
+		}
+	}
+	return "", nil
+}
+
+// SendStats sends the container resolver metrics
+func (cr *Resolver) SendStats() error {
+	if cr.unresolved == nil || cr.statsdClient == nil {
+		return nil
+	}
+	for prefix, count := range cr.unresolved.Swap() {
+		if err := cr.statsdClient.Count(metrics.MetricContainerResolverUnresolved, count, []string{"cgroup_prefix:" + prefix}, 1.0); err != nil {
+			return fmt.Errorf("failed to send container_resolver unresolved metric: %w", err)
+		}
+	}
+	return nil
 }
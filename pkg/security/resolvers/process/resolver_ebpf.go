@@ -240,6 +240,9 @@ func (p *EBPFResolver) SendStats() error {
 		}
 	}
 
+	if p.containerResolver != nil {
+		return p.containerResolver.SendStats()
+	}
 	return nil
 }
 
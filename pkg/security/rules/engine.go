@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 
@@ -529,6 +530,15 @@ func (e *RuleEngine) HandleEvent(event *model.Event) {
 		return
 	}
 
+	// sandbox containers only hold the namespaces of the pods, their events are only evaluated against
+	// the rules targeting them
+	if e.config.IgnoreSandboxContainers && event.ContainerContext.ID != "" && event.FieldHandlers.ResolveContainerIsSandbox(event, event.ContainerContext) {
+		if ruleSet := e.GetRuleSet(); ruleSet != nil {
+			ruleSet.EvaluateRules(event, targetsSandboxContainers)
+		}
+		return
+	}
+
 	if threatScoreRuleSet := e.GetThreatScoreRuleSet(); threatScoreRuleSet != nil {
 		threatScoreRuleSet.Evaluate(event)
 	}
@@ -545,6 +555,11 @@ func (e *RuleEngine) HandleEvent(event *model.Event) {
 	}
 }
 
+// targetsSandboxContainers returns true if the rule references the sandbox containers
+func targetsSandboxContainers(rule *rules.Rule) bool {
+	return slices.Contains(rule.GetFields(), "container.is_sandbox")
+}
+
 // StopEventCollector stops the event collector
 func (e *RuleEngine) StopEventCollector() []rules.CollectedEvent {
 	return e.GetRuleSet().StopEventCollector()
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package eval
+
+const (
+	// ShortContainerIDLength is the length of the short container IDs displayed by container runtimes
+	ShortContainerIDLength = 12
+	// ContainerIDLength is the length of a full container ID
+	ContainerIDLength = 64
+)
+
+// IsShortContainerID returns whether the value is a truncated hexadecimal container ID, at least
+// ShortContainerIDLength characters long
+func IsShortContainerID(value string) bool {
+	if len(value) < ShortContainerIDLength || len(value) >= ContainerIDLength {
+		return false
+	}
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// containerIDPrefix turns a short container ID scalar value into a pattern matching the full IDs
+// starting with it
+func containerIDPrefix(value FieldValue) FieldValue {
+	if id, ok := value.Value.(string); ok && value.Type == ScalarValueType && IsShortContainerID(id) {
+		return FieldValue{Value: id + "*", Type: PatternValueType}
+	}
+	return value
+}
+
+func containerIDPrefixEvaluator(e *StringEvaluator) {
+	if e.Field != "" || e.EvalFnc != nil {
+		return
+	}
+	value := containerIDPrefix(FieldValue{Value: e.Value, Type: e.ValueType})
+	e.Value, e.ValueType = value.Value.(string), value.Type
+}
+
+func containerIDPrefixValues(e *StringValuesEvaluator) *StringValuesEvaluator {
+	if e.EvalFnc != nil {
+		return e
+	}
+	var values StringValues
+	for _, v := range e.Values.GetFieldValues() {
+		values.AppendFieldValue(containerIDPrefix(v))
+	}
+	return &StringValuesEvaluator{
+		Values: values,
+	}
+}
+
+var (
+	// ContainerIDCmp lets the *container.id fields be compared to short container IDs, like the ones displayed by
+	// `docker ps`, which then match the full IDs starting with them. A short ID shared by several containers
+	// matches all of them. Important : short IDs are not used as approvers
+	ContainerIDCmp = &OpOverrides{
+		StringEquals: func(a *StringEvaluator, b *StringEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				containerIDPrefixEvaluator(b)
+			} else if b.Field != "" {
+				containerIDPrefixEvaluator(a)
+			}
+
+			return StringEquals(a, b, state)
+		},
+		StringValuesContains: func(a *StringEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				b = containerIDPrefixValues(b)
+			}
+
+			return StringValuesContains(a, b, state)
+		},
+		StringArrayContains: func(a *StringEvaluator, b *StringArrayEvaluator, state *State) (*BoolEvaluator, error) {
+			if b.Field != "" {
+				containerIDPrefixEvaluator(a)
+			}
+
+			return StringArrayContains(a, b, state)
+		},
+		StringArrayMatches: func(a *StringArrayEvaluator, b *StringValuesEvaluator, state *State) (*BoolEvaluator, error) {
+			if a.Field != "" {
+				b = containerIDPrefixValues(b)
+			}
+
+			return StringArrayMatches(a, b, state)
+		},
+	}
+)
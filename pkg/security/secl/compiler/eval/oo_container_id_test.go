@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package eval holds eval related files
+package eval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testContainerID = "3d0b9a5c2e71f4a8b6c9d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5"
+
+func TestIsShortContainerID(t *testing.T) {
+	assert.True(t, IsShortContainerID("3d0b9a5c2e71"))
+	assert.True(t, IsShortContainerID("3d0b9a5c2e71f4a8"))
+	assert.False(t, IsShortContainerID("3d0b9a5c2e7"))
+	assert.False(t, IsShortContainerID(testContainerID))
+	assert.False(t, IsShortContainerID("3D0B9A5C2E71"))
+	assert.False(t, IsShortContainerID("my-container"))
+}
+
+func TestContainerIDEquals(t *testing.T) {
+	field := func() *StringEvaluator {
+		return &StringEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) string {
+				return testContainerID
+			},
+		}
+	}
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "3d0b9a5c2e71", expected: true},
+		{value: "3d0b9a5c2e71f4a8", expected: true},
+		{value: testContainerID, expected: true},
+		{value: "3d0b9a5c2e72", expected: false},
+		// too short to be considered as a container ID
+		{value: "3d0b9a5c2e7", expected: false},
+		{value: "", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			var ctx Context
+			state := NewState(&testModel{}, "", nil)
+
+			e, err := ContainerIDCmp.StringEquals(&StringEvaluator{Value: test.value, ValueType: ScalarValueType}, field(), state)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, e.Eval(&ctx).(bool))
+
+			e, err = ContainerIDCmp.StringEquals(field(), &StringEvaluator{Value: test.value, ValueType: ScalarValueType}, state)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, e.Eval(&ctx).(bool))
+		})
+	}
+}
+
+func TestContainerIDValuesContains(t *testing.T) {
+	a := &StringEvaluator{
+		Field: "field",
+		EvalFnc: func(ctx *Context) string {
+			return testContainerID
+		},
+	}
+
+	var values StringValues
+	values.AppendScalarValue("aaaaaaaaaaaa")
+	values.AppendScalarValue("3d0b9a5c2e71")
+
+	var ctx Context
+	state := NewState(&testModel{}, "", nil)
+
+	e, err := ContainerIDCmp.StringValuesContains(a, &StringValuesEvaluator{Values: values}, state)
+	assert.NoError(t, err)
+	assert.True(t, e.Eval(&ctx).(bool))
+
+	// the values of the rule aren't changed
+	assert.Equal(t, []string{"aaaaaaaaaaaa", "3d0b9a5c2e71"}, values.GetScalarValues())
+}
+
+func TestContainerIDArray(t *testing.T) {
+	ancestors := func() *StringArrayEvaluator {
+		return &StringArrayEvaluator{
+			Field: "field",
+			EvalFnc: func(ctx *Context) []string {
+				return []string{"", testContainerID}
+			},
+		}
+	}
+
+	var ctx Context
+	state := NewState(&testModel{}, "", nil)
+
+	e, err := ContainerIDCmp.StringArrayContains(&StringEvaluator{Value: "3d0b9a5c2e71", ValueType: ScalarValueType}, ancestors(), state)
+	assert.NoError(t, err)
+	assert.True(t, e.Eval(&ctx).(bool))
+
+	e, err = ContainerIDCmp.StringArrayContains(&StringEvaluator{Value: "ffffffffffff", ValueType: ScalarValueType}, ancestors(), state)
+	assert.NoError(t, err)
+	assert.False(t, e.Eval(&ctx).(bool))
+
+	var values StringValues
+	values.AppendScalarValue("3d0b9a5c2e71")
+	e, err = ContainerIDCmp.StringArrayMatches(ancestors(), &StringValuesEvaluator{Values: values}, state)
+	assert.NoError(t, err)
+	assert.True(t, e.Eval(&ctx).(bool))
+}
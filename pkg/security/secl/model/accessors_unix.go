@@ -860,6 +860,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.FunctionWeight,
 		}, nil
+	case "container.cgroup_created_at":
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				ev := ctx.Event.(*Event)
+				return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext))
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.created_at":
 		return &eval.IntEvaluator{
 			EvalFnc: func(ctx *eval.Context) int {
@@ -871,6 +880,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext)
@@ -878,6 +888,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.HandlerWeight,
 		}, nil
+	case "container.is_sandbox":
+		return &eval.BoolEvaluator{
+			EvalFnc: func(ctx *eval.Context) bool {
+				ev := ctx.Event.(*Event)
+				return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext)
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.tags":
 		return &eval.StringArrayEvaluator{
 			EvalFnc: func(ctx *eval.Context) []string {
@@ -1089,6 +1108,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "exec.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.Exec.Process.ContainerID
@@ -1907,6 +1927,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "exit.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.Exit.Process.ContainerID
@@ -4303,6 +4324,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.ancestors.container.id":
 		return &eval.StringArrayEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) []string {
 				if result, ok := ctx.StringCache[field]; ok {
 					return result
@@ -5925,6 +5947,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.BaseEvent.ProcessContext.Process.ContainerID
@@ -6662,6 +6685,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.parent.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				if !ev.BaseEvent.ProcessContext.HasParent() {
@@ -7843,6 +7867,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "ptrace.tracee.ancestors.container.id":
 		return &eval.StringArrayEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) []string {
 				if result, ok := ctx.StringCache[field]; ok {
 					return result
@@ -9465,6 +9490,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "ptrace.tracee.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.PTrace.Tracee.Process.ContainerID
@@ -10202,6 +10228,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "ptrace.tracee.parent.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				if !ev.PTrace.Tracee.HasParent() {
@@ -12519,6 +12546,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "signal.target.ancestors.container.id":
 		return &eval.StringArrayEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) []string {
 				if result, ok := ctx.StringCache[field]; ok {
 					return result
@@ -14141,6 +14169,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "signal.target.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.Signal.Target.Process.ContainerID
@@ -14878,6 +14907,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "signal.target.parent.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				if !ev.Signal.Target.HasParent() {
@@ -16578,8 +16608,10 @@ func (ev *Event) GetFields() []eval.Field {
 		"chown.file.uid",
 		"chown.file.user",
 		"chown.retval",
+		"container.cgroup_created_at",
 		"container.created_at",
 		"container.id",
+		"container.is_sandbox",
 		"container.tags",
 		"dns.id",
 		"dns.question.class",
@@ -17965,10 +17997,14 @@ func (ev *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 		return ev.FieldHandlers.ResolveFileFieldsUser(ev, &ev.Chown.File.FileFields), nil
 	case "chown.retval":
 		return int(ev.Chown.SyscallEvent.Retval), nil
+	case "container.cgroup_created_at":
+		return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.created_at":
 		return int(ev.FieldHandlers.ResolveContainerCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.id":
 		return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext), nil
+	case "container.is_sandbox":
+		return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext), nil
 	case "container.tags":
 		return ev.FieldHandlers.ResolveContainerTags(ev, ev.BaseEvent.ContainerContext), nil
 	case "dns.id":
@@ -24307,10 +24343,14 @@ func (ev *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 		return "chown", nil
 	case "chown.retval":
 		return "chown", nil
+	case "container.cgroup_created_at":
+		return "*", nil
 	case "container.created_at":
 		return "*", nil
 	case "container.id":
 		return "*", nil
+	case "container.is_sandbox":
+		return "*", nil
 	case "container.tags":
 		return "*", nil
 	case "dns.id":
@@ -26896,10 +26936,14 @@ func (ev *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 		return reflect.String, nil
 	case "chown.retval":
 		return reflect.Int, nil
+	case "container.cgroup_created_at":
+		return reflect.Int, nil
 	case "container.created_at":
 		return reflect.Int, nil
 	case "container.id":
 		return reflect.String, nil
+	case "container.is_sandbox":
+		return reflect.Bool, nil
 	case "container.tags":
 		return reflect.String, nil
 	case "dns.id":
@@ -29904,6 +29948,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.Chown.SyscallEvent.Retval = int64(rv)
 		return nil
+	case "container.cgroup_created_at":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.CGroupCreatedAt"}
+		}
+		ev.BaseEvent.ContainerContext.CGroupCreatedAt = uint64(rv)
+		return nil
 	case "container.created_at":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
@@ -29924,6 +29978,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.BaseEvent.ContainerContext.ID = rv
 		return nil
+	case "container.is_sandbox":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(bool)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.IsSandbox"}
+		}
+		ev.BaseEvent.ContainerContext.IsSandbox = rv
+		return nil
 	case "container.tags":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
@@ -91,6 +91,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.FunctionWeight,
 		}, nil
+	case "container.cgroup_created_at":
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				ev := ctx.Event.(*Event)
+				return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext))
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.created_at":
 		return &eval.IntEvaluator{
 			EvalFnc: func(ctx *eval.Context) int {
@@ -102,6 +111,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext)
@@ -109,6 +119,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.HandlerWeight,
 		}, nil
+	case "container.is_sandbox":
+		return &eval.BoolEvaluator{
+			EvalFnc: func(ctx *eval.Context) bool {
+				ev := ctx.Event.(*Event)
+				return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext)
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.tags":
 		return &eval.StringArrayEvaluator{
 			EvalFnc: func(ctx *eval.Context) []string {
@@ -447,6 +466,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "exec.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.Exec.Process.ContainerID
@@ -587,6 +607,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "exit.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.Exit.Process.ContainerID
@@ -797,6 +818,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.ancestors.container.id":
 		return &eval.StringArrayEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) []string {
 				if result, ok := ctx.StringCache[field]; ok {
 					return result
@@ -1059,6 +1081,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				return ev.BaseEvent.ProcessContext.Process.ContainerID
@@ -1148,6 +1171,7 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 		}, nil
 	case "process.parent.container.id":
 		return &eval.StringEvaluator{
+			OpOverrides: eval.ContainerIDCmp,
 			EvalFnc: func(ctx *eval.Context) string {
 				ev := ctx.Event.(*Event)
 				if !ev.BaseEvent.ProcessContext.HasParent() {
@@ -1645,8 +1669,10 @@ func (ev *Event) GetFields() []eval.Field {
 		"change_permission.type",
 		"change_permission.user_domain",
 		"change_permission.username",
+		"container.cgroup_created_at",
 		"container.created_at",
 		"container.id",
+		"container.is_sandbox",
 		"container.tags",
 		"create.file.device_path",
 		"create.file.device_path.length",
@@ -1804,10 +1830,14 @@ func (ev *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 		return ev.ChangePermission.UserDomain, nil
 	case "change_permission.username":
 		return ev.ChangePermission.UserName, nil
+	case "container.cgroup_created_at":
+		return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.created_at":
 		return int(ev.FieldHandlers.ResolveContainerCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.id":
 		return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext), nil
+	case "container.is_sandbox":
+		return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext), nil
 	case "container.tags":
 		return ev.FieldHandlers.ResolveContainerTags(ev, ev.BaseEvent.ContainerContext), nil
 	case "create.file.device_path":
@@ -2270,10 +2300,14 @@ func (ev *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 		return "change_permission", nil
 	case "change_permission.username":
 		return "change_permission", nil
+	case "container.cgroup_created_at":
+		return "*", nil
 	case "container.created_at":
 		return "*", nil
 	case "container.id":
 		return "*", nil
+	case "container.is_sandbox":
+		return "*", nil
 	case "container.tags":
 		return "*", nil
 	case "create.file.device_path":
@@ -2573,10 +2607,14 @@ func (ev *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 		return reflect.String, nil
 	case "change_permission.username":
 		return reflect.String, nil
+	case "container.cgroup_created_at":
+		return reflect.Int, nil
 	case "container.created_at":
 		return reflect.Int, nil
 	case "container.id":
 		return reflect.String, nil
+	case "container.is_sandbox":
+		return reflect.Bool, nil
 	case "container.tags":
 		return reflect.String, nil
 	case "create.file.device_path":
@@ -2906,6 +2944,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.ChangePermission.UserName = rv
 		return nil
+	case "container.cgroup_created_at":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.CGroupCreatedAt"}
+		}
+		ev.BaseEvent.ContainerContext.CGroupCreatedAt = uint64(rv)
+		return nil
 	case "container.created_at":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
@@ -2926,6 +2974,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.BaseEvent.ContainerContext.ID = rv
 		return nil
+	case "container.is_sandbox":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(bool)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.IsSandbox"}
+		}
+		ev.BaseEvent.ContainerContext.IsSandbox = rv
+		return nil
 	case "container.tags":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
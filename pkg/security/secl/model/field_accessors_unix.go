@@ -806,6 +806,14 @@ func (ev *Event) GetChownRetval() int64 {
 	return ev.Chown.SyscallEvent.Retval
 }
 
+// GetContainerCgroupCreatedAt returns the value of the field, resolving if necessary
+func (ev *Event) GetContainerCgroupCreatedAt() int {
+	if ev.BaseEvent.ContainerContext == nil {
+		return 0
+	}
+	return ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext)
+}
+
 // GetContainerCreatedAt returns the value of the field, resolving if necessary
 func (ev *Event) GetContainerCreatedAt() int {
 	if ev.BaseEvent.ContainerContext == nil {
@@ -822,6 +830,14 @@ func (ev *Event) GetContainerId() string {
 	return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext)
 }
 
+// GetContainerIsSandbox returns the value of the field, resolving if necessary
+func (ev *Event) GetContainerIsSandbox() bool {
+	if ev.BaseEvent.ContainerContext == nil {
+		return false
+	}
+	return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext)
+}
+
 // GetContainerTags returns the value of the field, resolving if necessary
 func (ev *Event) GetContainerTags() []string {
 	if ev.BaseEvent.ContainerContext == nil {
@@ -70,10 +70,12 @@ func (r *Releasable) AppendReleaseCallback(callback func()) {
 // ContainerContext holds the container context of an event
 type ContainerContext struct {
 	Releasable
-	ID        string   `field:"id,handler:ResolveContainerID"`                              // SECLDoc[id] Definition:`ID of the container`
-	CreatedAt uint64   `field:"created_at,handler:ResolveContainerCreatedAt"`               // SECLDoc[created_at] Definition:`Timestamp of the creation of the container``
-	Tags      []string `field:"tags,handler:ResolveContainerTags,opts:skip_ad,weight:9999"` // SECLDoc[tags] Definition:`Tags of the container`
-	Resolved  bool     `field:"-"`
+	ID              string   `field:"id,handler:ResolveContainerID" op_override:"eval.ContainerIDCmp"` // SECLDoc[id] Definition:`ID of the container` Example:`container.id == "3d0b9a5c2e71"` Description:`Matches the container whose ID starts with the short ID 3d0b9a5c2e71, as displayed by container runtimes.`
+	CreatedAt       uint64   `field:"created_at,handler:ResolveContainerCreatedAt"`                    // SECLDoc[created_at] Definition:`Timestamp of the creation of the container` Example:`exec.file.name == "sh" && container.created_at < 5s` Description:`Matches shells executed in a container during its first 5 seconds.`
+	CGroupCreatedAt uint64   `field:"cgroup_created_at,handler:ResolveContainerCGroupCreatedAt"`       // SECLDoc[cgroup_created_at] Definition:`Timestamp of the creation of the cgroup of the container, approximated by the change time of its directory in the cgroup filesystem` Example:`exec.file.name == "sh" && container.cgroup_created_at < 5s` Description:`Matches shells executed in a container during the first 5 seconds of its cgroup, even if the agent started after the container.`
+	Tags            []string `field:"tags,handler:ResolveContainerTags,opts:skip_ad,weight:9999"`      // SECLDoc[tags] Definition:`Tags of the container`
+	IsSandbox       bool     `field:"is_sandbox,handler:ResolveContainerIsSandbox"`                    // SECLDoc[is_sandbox] Definition:`Indicates whether the container is the sandbox (pause) container of a Kubernetes pod` Example:`container.is_sandbox` Description:`Matches the events of the sandbox containers, the rules using this field are the only ones evaluated against them when they are ignored.`
+	Resolved        bool     `field:"-"`
 }
 
 // SecurityProfileContext holds the security context of the profile
@@ -256,6 +258,14 @@ func (e *Event) GetWorkloadID() string {
 	return e.SecurityProfileContext.Name
 }
 
+// GetContainerID returns the ID of the container of the event, if any
+func (e *Event) GetContainerID() string {
+	if e.ContainerContext == nil {
+		return ""
+	}
+	return e.ContainerContext.ID
+}
+
 // Retain the event
 func (e *Event) Retain() Event {
 	if e.ProcessCacheEntry != nil {
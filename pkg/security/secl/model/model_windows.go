@@ -74,7 +74,7 @@ type Process struct {
 
 	FileEvent FileEvent `field:"file"`
 
-	ContainerID string `field:"container.id"` // SECLDoc[container.id] Definition:`Container ID`
+	ContainerID string `field:"container.id" op_override:"eval.ContainerIDCmp"` // SECLDoc[container.id] Definition:`Container ID`
 
 	ExitTime time.Time `field:"exit_time,opts:getters_only"`
 	ExecTime time.Time `field:"exec_time,opts:getters_only"`
@@ -679,6 +679,15 @@ func (rs *RuleSet) runRuleActions(_ eval.Event, ctx *eval.Context, rule *Rule) e
 
 // Evaluate the specified event against the set of rules
 func (rs *RuleSet) Evaluate(event eval.Event) bool {
+	return rs.evaluate(event, nil)
+}
+
+// EvaluateRules evaluates the specified event against the rules of the set selected by the filter
+func (rs *RuleSet) EvaluateRules(event eval.Event, filter func(rule *Rule) bool) bool {
+	return rs.evaluate(event, filter)
+}
+
+func (rs *RuleSet) evaluate(event eval.Event, filter func(rule *Rule) bool) bool {
 	ctx := rs.pool.Get(event)
 	defer rs.pool.Put(ctx)
 
@@ -698,6 +707,9 @@ func (rs *RuleSet) Evaluate(event eval.Event) bool {
 	result := false
 
 	for _, rule := range bucket.rules {
+		if filter != nil && !filter(rule) {
+			continue
+		}
 		utils.PprofDoWithoutContext(rule.GetPprofLabels(), func() {
 			if rule.GetEvaluator().Eval(ctx) {
 
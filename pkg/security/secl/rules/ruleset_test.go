@@ -10,6 +10,7 @@ package rules
 
 import (
 	"reflect"
+	"slices"
 	"strings"
 	"syscall"
 	"testing"
@@ -167,6 +168,27 @@ func TestRuleSetDiscarders(t *testing.T) {
 	}
 }
 
+func TestRuleSetEvaluateRules(t *testing.T) {
+	rs := newRuleSet()
+	AddTestRuleExpr(t, rs, `open.file.path == "/etc/passwd"`, `open.file.path == "/etc/passwd" && process.uid == 0`)
+
+	ev := model.NewFakeEvent()
+	ev.Type = uint32(model.FileOpenEventType)
+	ev.SetFieldValue("open.file.path", "/etc/passwd")
+	ev.SetFieldValue("process.uid", 1)
+
+	usesUID := func(rule *Rule) bool {
+		return slices.Contains(rule.GetFields(), "process.uid")
+	}
+	if rs.EvaluateRules(ev, usesUID) {
+		t.Fatal("the rules not selected by the filter shouldn't be evaluated")
+	}
+	ev.SetFieldValue("process.uid", 0)
+	if !rs.EvaluateRules(ev, usesUID) {
+		t.Fatal("the rules selected by the filter should be evaluated")
+	}
+}
+
 func TestRuleSetApprovers1(t *testing.T) {
 	rs := newRuleSet()
 	AddTestRuleExpr(t, rs, `open.file.path in ["/etc/passwd", "/etc/shadow"] && (process.uid == 0 && process.gid == 0)`)
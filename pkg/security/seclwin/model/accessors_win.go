@@ -89,6 +89,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.FunctionWeight,
 		}, nil
+	case "container.cgroup_created_at":
+		return &eval.IntEvaluator{
+			EvalFnc: func(ctx *eval.Context) int {
+				ev := ctx.Event.(*Event)
+				return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext))
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.created_at":
 		return &eval.IntEvaluator{
 			EvalFnc: func(ctx *eval.Context) int {
@@ -107,6 +116,15 @@ func (m *Model) GetEvaluator(field eval.Field, regID eval.RegisterID) (eval.Eval
 			Field:  field,
 			Weight: eval.HandlerWeight,
 		}, nil
+	case "container.is_sandbox":
+		return &eval.BoolEvaluator{
+			EvalFnc: func(ctx *eval.Context) bool {
+				ev := ctx.Event.(*Event)
+				return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext)
+			},
+			Field:  field,
+			Weight: eval.HandlerWeight,
+		}, nil
 	case "container.tags":
 		return &eval.StringArrayEvaluator{
 			EvalFnc: func(ctx *eval.Context) []string {
@@ -1634,8 +1652,10 @@ func (ev *Event) GetFields() []eval.Field {
 		"change_permission.type",
 		"change_permission.user_domain",
 		"change_permission.username",
+		"container.cgroup_created_at",
 		"container.created_at",
 		"container.id",
+		"container.is_sandbox",
 		"container.tags",
 		"create.file.device_path",
 		"create.file.device_path.length",
@@ -1792,10 +1812,14 @@ func (ev *Event) GetFieldValue(field eval.Field) (interface{}, error) {
 		return ev.ChangePermission.UserDomain, nil
 	case "change_permission.username":
 		return ev.ChangePermission.UserName, nil
+	case "container.cgroup_created_at":
+		return int(ev.FieldHandlers.ResolveContainerCGroupCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.created_at":
 		return int(ev.FieldHandlers.ResolveContainerCreatedAt(ev, ev.BaseEvent.ContainerContext)), nil
 	case "container.id":
 		return ev.FieldHandlers.ResolveContainerID(ev, ev.BaseEvent.ContainerContext), nil
+	case "container.is_sandbox":
+		return ev.FieldHandlers.ResolveContainerIsSandbox(ev, ev.BaseEvent.ContainerContext), nil
 	case "container.tags":
 		return ev.FieldHandlers.ResolveContainerTags(ev, ev.BaseEvent.ContainerContext), nil
 	case "create.file.device_path":
@@ -2256,10 +2280,14 @@ func (ev *Event) GetFieldEventType(field eval.Field) (eval.EventType, error) {
 		return "change_permission", nil
 	case "change_permission.username":
 		return "change_permission", nil
+	case "container.cgroup_created_at":
+		return "*", nil
 	case "container.created_at":
 		return "*", nil
 	case "container.id":
 		return "*", nil
+	case "container.is_sandbox":
+		return "*", nil
 	case "container.tags":
 		return "*", nil
 	case "create.file.device_path":
@@ -2557,10 +2585,14 @@ func (ev *Event) GetFieldType(field eval.Field) (reflect.Kind, error) {
 		return reflect.String, nil
 	case "change_permission.username":
 		return reflect.String, nil
+	case "container.cgroup_created_at":
+		return reflect.Int, nil
 	case "container.created_at":
 		return reflect.Int, nil
 	case "container.id":
 		return reflect.String, nil
+	case "container.is_sandbox":
+		return reflect.Bool, nil
 	case "container.tags":
 		return reflect.String, nil
 	case "create.file.device_path":
@@ -2888,6 +2920,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.ChangePermission.UserName = rv
 		return nil
+	case "container.cgroup_created_at":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(int)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.CGroupCreatedAt"}
+		}
+		ev.BaseEvent.ContainerContext.CGroupCreatedAt = uint64(rv)
+		return nil
 	case "container.created_at":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
@@ -2908,6 +2950,16 @@ func (ev *Event) SetFieldValue(field eval.Field, value interface{}) error {
 		}
 		ev.BaseEvent.ContainerContext.ID = rv
 		return nil
+	case "container.is_sandbox":
+		if ev.BaseEvent.ContainerContext == nil {
+			ev.BaseEvent.ContainerContext = &ContainerContext{}
+		}
+		rv, ok := value.(bool)
+		if !ok {
+			return &eval.ErrValueTypeMismatch{Field: "BaseEvent.ContainerContext.IsSandbox"}
+		}
+		ev.BaseEvent.ContainerContext.IsSandbox = rv
+		return nil
 	case "container.tags":
 		if ev.BaseEvent.ContainerContext == nil {
 			ev.BaseEvent.ContainerContext = &ContainerContext{}
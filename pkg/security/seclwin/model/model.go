@@ -79,10 +79,12 @@ func (r *Releasable) OnRelease() {
 // ContainerContext holds the container context of an event
 type ContainerContext struct {
 	Releasable
-	ID        string   `field:"id,handler:ResolveContainerID"`                              // SECLDoc[id] Definition:`ID of the container`
-	CreatedAt uint64   `field:"created_at,handler:ResolveContainerCreatedAt"`               // SECLDoc[created_at] Definition:`Timestamp of the creation of the container``
-	Tags      []string `field:"tags,handler:ResolveContainerTags,opts:skip_ad,weight:9999"` // SECLDoc[tags] Definition:`Tags of the container`
-	Resolved  bool     `field:"-"`
+	ID              string   `field:"id,handler:ResolveContainerID"`                              // SECLDoc[id] Definition:`ID of the container`
+	CreatedAt       uint64   `field:"created_at,handler:ResolveContainerCreatedAt"`               // SECLDoc[created_at] Definition:`Timestamp of the creation of the container``
+	CGroupCreatedAt uint64   `field:"cgroup_created_at,handler:ResolveContainerCGroupCreatedAt"`  // SECLDoc[cgroup_created_at] Definition:`Timestamp of the creation of the cgroup of the container, approximated by the change time of its directory in the cgroup filesystem`
+	Tags            []string `field:"tags,handler:ResolveContainerTags,opts:skip_ad,weight:9999"` // SECLDoc[tags] Definition:`Tags of the container`
+	IsSandbox       bool     `field:"is_sandbox,handler:ResolveContainerIsSandbox"`               // SECLDoc[is_sandbox] Definition:`Indicates whether the container is the sandbox (pause) container of a Kubernetes pod`
+	Resolved        bool     `field:"-"`
 }
 
 // SecurityProfileContext holds the security context of the profile
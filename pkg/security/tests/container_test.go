@@ -88,6 +88,10 @@ func TestContainerCreatedAt(t *testing.T) {
 			createdAtNano, _ := event.GetFieldValue("container.created_at")
 			createdAt := time.Unix(0, int64(createdAtNano.(int)))
 			assert.True(t, time.Since(createdAt) > 3*time.Second)
+			// the creation time of the container is never later than the creation time of its cgroup
+			cgroupCreatedAtNano, _ := event.GetFieldValue("container.cgroup_created_at")
+			assert.NotZero(t, cgroupCreatedAtNano)
+			assert.LessOrEqual(t, createdAtNano.(int), cgroupCreatedAtNano.(int))
 
 			test.validateOpenSchema(t, event)
 		})
@@ -12,9 +12,12 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/sha256"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/security/common/containerutils"
 )
@@ -49,7 +52,29 @@ type ControlGroup struct {
 
 // GetContainerID returns the container id extracted from the path of the control group
 func (cg ControlGroup) GetContainerID() ContainerID {
-	return ContainerID(containerutils.FindContainerID(cg.Path))
+	return ContainerID(containerutils.FindContainerIDInCgroup(cg.Path))
+}
+
+// SysPath returns the path of the control group in the cgroup filesystem
+func (cg ControlGroup) SysPath() string {
+	// the unified hierarchy (cgroup v2) has no controller in /proc/[pid]/cgroup and is mounted at the root
+	// of the cgroup filesystem, v1 hierarchies are mounted in a directory named after their controllers
+	controllers := strings.TrimPrefix(strings.Join(cg.Controllers, ","), "name=")
+	return CgroupSysPath(controllers, cg.Path, "")
+}
+
+// CreationTime returns the creation time of the control group, approximated by the change time
+// of its directory in the cgroup filesystem.
+func (cg ControlGroup) CreationTime() (time.Time, error) {
+	fi, err := os.Stat(cg.SysPath())
+	if err != nil {
+		return time.Time{}, err
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, fmt.Errorf("couldn't get change time of %s", cg.SysPath())
+	}
+	return time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec), nil
 }
 
 // GetProcControlGroups returns the cgroup membership of the specified task.
@@ -93,3 +118,19 @@ func GetProcContainerID(tgid, pid uint32) (ContainerID, error) {
 	}
 	return "", nil
 }
+
+// GetProcContainerCreationTime returns the creation time of the control group of the given container
+// the process belongs to.
+func GetProcContainerCreationTime(tgid, pid uint32, containerID ContainerID) (time.Time, error) {
+	cgroups, err := GetProcControlGroups(tgid, pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, cgroup := range cgroups {
+		if cgroup.GetContainerID() == containerID {
+			return cgroup.CreationTime()
+		}
+	}
+	return time.Time{}, fmt.Errorf("process %d doesn't belong to container %s", pid, containerID)
+}
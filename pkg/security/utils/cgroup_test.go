@@ -0,0 +1,41 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build linux
+
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/util/kernel"
+)
+
+func TestControlGroupSysPath(t *testing.T) {
+	tests := []struct {
+		ControlGroup ControlGroup
+		Expected     string
+	}{
+		{
+			ControlGroup: ControlGroup{ID: 0, Controllers: []string{""}, Path: "/system.slice/docker-abc.scope"},
+			Expected:     "fs/cgroup/system.slice/docker-abc.scope",
+		},
+		{
+			ControlGroup: ControlGroup{ID: 4, Controllers: []string{"cpu", "cpuacct"}, Path: "/docker/abc"},
+			Expected:     "fs/cgroup/cpu,cpuacct/docker/abc",
+		},
+		{
+			ControlGroup: ControlGroup{ID: 1, Controllers: []string{"name=systemd"}, Path: "/docker/abc"},
+			Expected:     "fs/cgroup/systemd/docker/abc",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, filepath.Join(kernel.SysFSRoot(), test.Expected), test.ControlGroup.SysPath())
+	}
+}
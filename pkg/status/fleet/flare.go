@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fleet
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	flaretypes "github.com/DataDog/datadog-agent/comp/core/flare/types"
+	"github.com/DataDog/datadog-agent/pkg/fleet/daemon"
+)
+
+// FlareProvider attaches the fleet flares of the daemon to the agent flares
+type FlareProvider struct {
+	client daemon.StatusClient
+}
+
+// GetFlareProvider returns a flare provider if remote updates are enabled, an empty one otherwise
+func GetFlareProvider(conf config.Component) flaretypes.Provider {
+	if conf.GetBool("remote_updates") {
+		p := FlareProvider{
+			client: daemon.NewStatusClient(conf.GetString("run_path")),
+		}
+		return flaretypes.NewProvider(p.fillFlare)
+	}
+
+	return flaretypes.Provider{}
+}
+
+// fillFlare adds the files of the fleet flare to the agent flare, under the fleet directory
+func (p FlareProvider) fillFlare(fb flaretypes.FlareBuilder) error {
+	archive, err := p.client.FlareArchive()
+	if err != nil {
+		return fb.AddFile(filepath.Join("fleet", "error.log"), []byte(fmt.Sprintf("could not collect the fleet flare: %v\n", err)))
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("could not read the fleet flare: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read the fleet flare: %w", err)
+		}
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || path.IsAbs(name) || strings.HasPrefix(name, "..") {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("could not read %s from the fleet flare: %w", header.Name, err)
+		}
+		// the content is scrubbed again by the flare builder
+		err = fb.AddFile(filepath.FromSlash(name), content)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+
+package fleet
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/flare/helpers"
+)
+
+func fleetFlareArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestFlareProvider(t *testing.T) {
+	provider := FlareProvider{client: &testLocalAPIClient{
+		archive: fleetFlareArchive(t, map[string]string{
+			"fleet/status.json":      `{"version":"7.60.0"}`,
+			"fleet/logs/updater.log": "daemon logs\napi_key: 0123456789abcdef0123456789abcdef\n",
+			"../outside.log":         "outside",
+		}),
+	}}
+	fb := helpers.NewFlareBuilderMock(t, false)
+	require.NoError(t, provider.fillFlare(fb.Fb))
+	fb.AssertFileContent(`{"version":"7.60.0"}`, "fleet", "status.json")
+	fb.AssertFileContent("daemon logs\napi_key: \"***************************bcdef\"", "fleet", "logs", "updater.log")
+	fb.AssertNoFileExists("..", "outside.log")
+}
+
+func TestFlareProviderUnreachableDaemon(t *testing.T) {
+	provider := FlareProvider{client: &testLocalAPIClient{err: errors.New("connection refused")}}
+	fb := helpers.NewFlareBuilderMock(t, false)
+	require.NoError(t, provider.fillFlare(fb.Fb))
+	fb.AssertFileContent("could not collect the fleet flare: connection refused", "fleet", "error.log")
+}
@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package fleet implements the status and flare providers of the fleet automation daemon
+package fleet
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/status"
+	"github.com/DataDog/datadog-agent/pkg/fleet/daemon"
+)
+
+//go:embed status_templates
+var templatesFS embed.FS
+
+// Provider provides the functionality to populate the status output
+type Provider struct {
+	client daemon.StatusClient
+}
+
+// GetProvider if remote updates are enabled returns status.Provider otherwise returns nil
+func GetProvider(conf config.Component) status.Provider {
+	if conf.GetBool("remote_updates") {
+		return Provider{
+			client: daemon.NewStatusClient(conf.GetString("run_path")),
+		}
+	}
+
+	return nil
+}
+
+func (p Provider) getStatusInfo() map[string]interface{} {
+	stats := make(map[string]interface{})
+
+	p.populateStatus(stats)
+
+	return stats
+}
+
+func (p Provider) populateStatus(stats map[string]interface{}) {
+	response, err := p.client.Status()
+	if err != nil {
+		stats["fleetAutomationStatus"] = map[string]interface{}{
+			"Error": fmt.Sprintf("could not reach the installer daemon: %v", err),
+		}
+		return
+	}
+
+	packages := make([]map[string]string, 0, len(response.Packages))
+	for name, state := range response.Packages {
+		packages = append(packages, map[string]string{
+			"Name":       name,
+			"Stable":     state.Stable,
+			"Experiment": state.Experiment,
+		})
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i]["Name"] < packages[j]["Name"]
+	})
+
+	fleetStatus := map[string]interface{}{
+		"Version":          response.Version,
+		"Channel":          response.Channel,
+		"Packages":         packages,
+		"StartTime":        formatTime(response.Daemon.StartTime),
+		"LastGC":           formatTime(response.Daemon.LastGC),
+		"CatalogUpdatedAt": formatTime(response.Daemon.CatalogUpdatedAt),
+		"CatalogPackages":  response.Daemon.CatalogPackages,
+	}
+	if request := response.Daemon.LastRemoteRequest; request != nil {
+		fleetStatus["LastRemoteRequest"] = map[string]string{
+			"ID":      request.ID,
+			"Package": request.Package,
+			"State":   request.State,
+			"Error":   request.Error,
+			"Time":    formatTime(request.Time),
+		}
+	}
+	stats["fleetAutomationStatus"] = fleetStatus
+}
+
+// formatTime formats a time of the daemon along with how long ago it was, the zero time is never
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.UTC().Format(time.RFC3339), time.Since(t).Truncate(time.Second))
+}
+
+// Name returns the name
+func (p Provider) Name() string {
+	return "Fleet Automation"
+}
+
+// Section return the section
+func (p Provider) Section() string {
+	return "Fleet Automation"
+}
+
+// JSON populates the status map
+func (p Provider) JSON(_ bool, stats map[string]interface{}) error {
+	p.populateStatus(stats)
+
+	return nil
+}
+
+// Text renders the text output
+func (p Provider) Text(_ bool, buffer io.Writer) error {
+	return status.RenderText(templatesFS, "fleet.tmpl", buffer, p.getStatusInfo())
+}
+
+// HTML renders the html output
+func (p Provider) HTML(_ bool, buffer io.Writer) error {
+	return status.RenderHTML(templatesFS, "fleetHTML.tmpl", buffer, p.getStatusInfo())
+}
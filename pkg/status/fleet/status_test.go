@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fleet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/fleet/daemon"
+	"github.com/DataDog/datadog-agent/pkg/fleet/installer/repository"
+)
+
+type testLocalAPIClient struct {
+	response daemon.StatusResponse
+	archive  []byte
+	err      error
+}
+
+func (c *testLocalAPIClient) Status() (daemon.StatusResponse, error) {
+	return c.response, c.err
+}
+
+func (c *testLocalAPIClient) FlareArchive() ([]byte, error) {
+	return c.archive, c.err
+}
+
+func TestStatusOutput(t *testing.T) {
+	provider := Provider{client: &testLocalAPIClient{
+		response: daemon.StatusResponse{
+			Version: "7.60.0",
+			Channel: "beta",
+			Packages: map[string]repository.State{
+				"datadog-agent":      {Stable: "7.59.0", Experiment: "7.60.0"},
+				"datadog-apm-inject": {Stable: "0.20.0"},
+			},
+			Daemon: daemon.DaemonStatus{
+				StartTime:        time.Now().Add(-time.Hour),
+				CatalogUpdatedAt: time.Now().Add(-time.Minute),
+				CatalogPackages:  4,
+				LastRemoteRequest: &daemon.TaskRecord{
+					TaskState: daemon.TaskState{ID: "request-1", Package: "datadog-agent", State: "ERROR", Error: "could not start experiment"},
+					Time:      time.Now(),
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		assertFunc func(t *testing.T)
+	}{
+		{"JSON", func(t *testing.T) {
+			stats := make(map[string]interface{})
+			provider.JSON(false, stats)
+
+			fleetStatus := stats["fleetAutomationStatus"].(map[string]interface{})
+			assert.Equal(t, "beta", fleetStatus["Channel"])
+			assert.Equal(t, "never", fleetStatus["LastGC"])
+			assert.Equal(t, []map[string]string{
+				{"Name": "datadog-agent", "Stable": "7.59.0", "Experiment": "7.60.0"},
+				{"Name": "datadog-apm-inject", "Stable": "0.20.0", "Experiment": ""},
+			}, fleetStatus["Packages"])
+		}},
+		{"Text", func(t *testing.T) {
+			b := new(bytes.Buffer)
+			err := provider.Text(false, b)
+
+			assert.NoError(t, err)
+			assert.Contains(t, b.String(), "Status: Running")
+			assert.Contains(t, b.String(), "Experiment: 7.60.0")
+			assert.Contains(t, b.String(), "Last Garbage Collection: never")
+			assert.Contains(t, b.String(), "Error: could not start experiment")
+		}},
+		{"HTML", func(t *testing.T) {
+			b := new(bytes.Buffer)
+			err := provider.HTML(false, b)
+
+			assert.NoError(t, err)
+			assert.Contains(t, b.String(), "datadog-apm-inject: stable 0.20.0, experiment none")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.assertFunc(t)
+		})
+	}
+}
+
+func TestStatusOutputUnreachable(t *testing.T) {
+	provider := Provider{client: &testLocalAPIClient{err: errors.New("connection refused")}}
+
+	b := new(bytes.Buffer)
+	err := provider.Text(false, b)
+
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), "Status: Not running or unreachable")
+	assert.Contains(t, b.String(), "connection refused")
+}
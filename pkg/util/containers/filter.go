@@ -173,7 +173,7 @@ func GetSharedMetricFilter() (*Filter, error) {
 // GetPauseContainerFilter returns a filter only excluding pause containers
 func GetPauseContainerFilter() (*Filter, error) {
 	var excludeList []string
-	if config.Datadog().GetBool("exclude_pause_container") {
+	if config.Datadog().Features().Enabled(config.FeatureExcludePauseContainer) {
 		excludeList = append(excludeList,
 			pauseContainerGCR,
 			pauseContainerOpenshift,
@@ -270,7 +270,7 @@ func newMetricFilterFromConfig() (*Filter, error) {
 		excludeList = config.Datadog().GetStringSlice("ac_exclude")
 	}
 
-	if config.Datadog().GetBool("exclude_pause_container") {
+	if config.Datadog().Features().Enabled(config.FeatureExcludePauseContainer) {
 		excludeList = append(excludeList,
 			pauseContainerGCR,
 			pauseContainerOpenshift,
@@ -24,7 +24,9 @@ func (Disk) GetUsage(path string) (*DiskUsage, error) {
 	}
 
 	return &DiskUsage{
-		Total:     usage.Total,
-		Available: usage.Free,
+		Total:           usage.Total,
+		Available:       usage.Free,
+		InodesTotal:     usage.InodesTotal,
+		InodesAvailable: usage.InodesFree,
 	}, nil
 }
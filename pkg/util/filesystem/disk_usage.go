@@ -10,4 +10,7 @@ package filesystem
 type DiskUsage struct {
 	Total     uint64
 	Available uint64
+	// InodesTotal and InodesAvailable are 0 on filesystems allocating inodes dynamically
+	InodesTotal     uint64
+	InodesAvailable uint64
 }
@@ -0,0 +1,322 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package main implements a fake remote config backend for the fleet daemon soak test.
+//
+// Every poll is answered with a newly signed repository holding a catalog (UPDATER_CATALOG_DD)
+// listing new versions and a few remote requests (UPDATER_TASK) expecting the state the daemon
+// reported, so the daemon keeps verifying, storing and executing new configurations. The roots
+// the daemon must trust are written to an environment file loaded by the daemon unit.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/go-tuf/data"
+	"github.com/DataDog/go-tuf/pkg/keys"
+	"github.com/DataDog/go-tuf/sign"
+	"google.golang.org/protobuf/proto"
+
+	pbgo "github.com/DataDog/datadog-agent/pkg/proto/pbgo/core"
+)
+
+const (
+	orgID   = 2
+	orgUUID = "fake-org-uuid"
+	// packageDigest is the digest of the packages listed in the catalog, they are never downloaded
+	// as the remote requests only target versions missing from the catalog.
+	packageDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	// metaExpiration is the validity of the signed metadata, the roots outlive any soak test.
+	metaExpiration = 24 * time.Hour * 365
+)
+
+var (
+	addr         = flag.String("addr", "127.0.0.1:7878", "address the backend listens on")
+	envFile      = flag.String("env-file", "", "file the environment of the daemon pointing to the backend is written to")
+	tasksPerPoll = flag.Int("tasks-per-poll", 4, "number of remote requests sent on each poll")
+	catalogSize  = flag.Int("catalog-size", 20, "number of packages listed in each catalog")
+)
+
+// repository is a signed TUF repository, the config and director repositories only differ by their keys.
+type repository struct {
+	rootKey      keys.Signer
+	timestampKey keys.Signer
+	snapshotKey  keys.Signer
+	targetsKey   keys.Signer
+	root         []byte
+}
+
+// stats are the counters exposed to the soak test to check the daemon handles the traffic.
+type stats struct {
+	Polls int `json:"polls"`
+	// Tasks is the number of remote requests sent to the daemon
+	Tasks int `json:"tasks"`
+	// TasksApplied is the number of remote requests the daemon reported as acknowledged or failed
+	TasksApplied int `json:"tasks_applied"`
+	// CatalogsApplied is the number of catalogs the daemon reported as acknowledged
+	CatalogsApplied int `json:"catalogs_applied"`
+}
+
+type backend struct {
+	sync.Mutex
+	config   *repository
+	director *repository
+	version  int64
+	stats    stats
+	// applied are the configurations reported as applied by the daemon, keyed by their ID and version
+	applied map[string]struct{}
+}
+
+func main() {
+	flag.Parse()
+	b := &backend{
+		config:   newRepository(),
+		director: newRepository(),
+		applied:  make(map[string]struct{}),
+	}
+	if *envFile != "" {
+		if err := b.writeEnvFile(*envFile); err != nil {
+			log.Fatalf("could not write environment file: %v", err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0.1/configurations", b.configurations)
+	mux.HandleFunc("/api/v0.1/org", func(w http.ResponseWriter, _ *http.Request) {
+		writeProto(w, &pbgo.OrgDataResponse{Uuid: orgUUID})
+	})
+	mux.HandleFunc("/api/v0.1/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeProto(w, &pbgo.OrgStatusResponse{Enabled: true, Authorized: true})
+	})
+	mux.HandleFunc("/stats", b.getStats)
+	log.Printf("fake remote config backend listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func newRepository() *repository {
+	r := &repository{
+		rootKey:      generateKey(),
+		timestampKey: generateKey(),
+		snapshotKey:  generateKey(),
+		targetsKey:   generateKey(),
+	}
+	root := data.NewRoot()
+	root.Version = 1
+	root.Expires = time.Now().Add(metaExpiration)
+	for role, key := range map[string]keys.Signer{"root": r.rootKey, "timestamp": r.timestampKey, "snapshot": r.snapshotKey, "targets": r.targetsKey} {
+		root.AddKey(key.PublicData())
+		root.Roles[role] = &data.Role{KeyIDs: key.PublicData().IDs(), Threshold: 1}
+	}
+	r.root = mustSign(root, r.rootKey)
+	return r
+}
+
+func generateKey() keys.Signer {
+	key, err := keys.GenerateEd25519Key()
+	if err != nil {
+		log.Fatalf("could not generate key: %v", err)
+	}
+	return key
+}
+
+func mustSign(meta interface{}, key keys.Signer) []byte {
+	signed, err := sign.Marshal(meta, key)
+	if err != nil {
+		log.Fatalf("could not sign metadata: %v", err)
+	}
+	raw, err := json.Marshal(signed)
+	if err != nil {
+		log.Fatalf("could not marshal metadata: %v", err)
+	}
+	return raw
+}
+
+// writeEnvFile writes the environment pointing the daemon to the backend, in the systemd EnvironmentFile format.
+func (b *backend) writeEnvFile(path string) error {
+	env := fmt.Sprintf(`DD_REMOTE_CONFIGURATION_RC_DD_URL=http://%s
+DD_REMOTE_CONFIGURATION_NO_TLS=true
+DD_REMOTE_CONFIGURATION_REFRESH_INTERVAL=5s
+DD_REMOTE_CONFIGURATION_CONFIG_ROOT='%s'
+DD_REMOTE_CONFIGURATION_DIRECTOR_ROOT='%s'
+`, *addr, b.config.root, b.director.root)
+	return os.WriteFile(path, []byte(env), 0644)
+}
+
+// metas signs the targets, snapshot and timestamp of the repository for the given version.
+func (r *repository) metas(version int64, targets data.TargetFiles) (timestamp, snapshot, topTargets []byte) {
+	expires := time.Now().Add(metaExpiration)
+	targetsMeta := data.NewTargets()
+	targetsMeta.Version = version
+	targetsMeta.Expires = expires
+	targetsMeta.Targets = targets
+	topTargets = mustSign(targetsMeta, r.targetsKey)
+
+	snapshotMeta := data.NewSnapshot()
+	snapshotMeta.Version = version
+	snapshotMeta.Expires = expires
+	snapshotMeta.Meta["targets.json"] = data.SnapshotFileMeta{Version: version}
+	custom := json.RawMessage(fmt.Sprintf(`{"org_uuid":%q}`, orgUUID))
+	snapshotMeta.Custom = &custom
+	snapshot = mustSign(snapshotMeta, r.snapshotKey)
+
+	timestampMeta := data.NewTimestamp()
+	timestampMeta.Version = version
+	timestampMeta.Expires = expires
+	timestampMeta.Meta["snapshot.json"] = data.TimestampFileMeta{Version: version, Length: int64(len(snapshot)), Hashes: data.Hashes{"sha256": hash(snapshot)}}
+	timestamp = mustSign(timestampMeta, r.timestampKey)
+	return timestamp, snapshot, topTargets
+}
+
+func hash(content []byte) []byte {
+	h := sha256.Sum256(content)
+	return h[:]
+}
+
+func (b *backend) configurations(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request pbgo.LatestConfigsRequest
+	if err := proto.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.stats.Polls++
+	b.version++
+	stable := b.record(&request)
+
+	files := []*pbgo.File{b.catalog()}
+	for i := 0; i < *tasksPerPoll; i++ {
+		files = append(files, b.task(i, stable))
+	}
+	b.stats.Tasks += *tasksPerPoll
+	targets := make(data.TargetFiles)
+	for _, file := range files {
+		custom := json.RawMessage(fmt.Sprintf(`{"v":%d}`, b.version))
+		targets[file.Path] = data.TargetFileMeta{
+			FileMeta: data.FileMeta{Length: int64(len(file.Raw)), Hashes: data.Hashes{"sha256": hash(file.Raw)}},
+			Custom:   &custom,
+		}
+	}
+
+	version := uint64(b.version)
+	configTimestamp, configSnapshot, configTargets := b.config.metas(b.version, targets)
+	directorTimestamp, directorSnapshot, directorTargets := b.director.metas(b.version, targets)
+	writeProto(w, &pbgo.LatestConfigsResponse{
+		ConfigMetas: &pbgo.ConfigMetas{
+			Roots:      []*pbgo.TopMeta{{Version: 1, Raw: b.config.root}},
+			Timestamp:  &pbgo.TopMeta{Version: version, Raw: configTimestamp},
+			Snapshot:   &pbgo.TopMeta{Version: version, Raw: configSnapshot},
+			TopTargets: &pbgo.TopMeta{Version: version, Raw: configTargets},
+		},
+		DirectorMetas: &pbgo.DirectorMetas{
+			Roots:     []*pbgo.TopMeta{{Version: 1, Raw: b.director.root}},
+			Timestamp: &pbgo.TopMeta{Version: version, Raw: directorTimestamp},
+			Snapshot:  &pbgo.TopMeta{Version: version, Raw: directorSnapshot},
+			Targets:   &pbgo.TopMeta{Version: version, Raw: directorTargets},
+		},
+		TargetFiles: files,
+	})
+}
+
+// record counts the configurations the daemon reported as applied since the last poll and returns
+// the stable version of the agent it reported.
+func (b *backend) record(request *pbgo.LatestConfigsRequest) string {
+	var stable string
+	for _, client := range request.ActiveClients {
+		if !client.IsUpdater {
+			continue
+		}
+		for _, config := range client.GetState().GetConfigStates() {
+			// 2 and 3 are the acknowledged and error apply states
+			if config.ApplyState != 2 && config.ApplyState != 3 {
+				continue
+			}
+			key := fmt.Sprintf("%s/%d", config.Id, config.Version)
+			if _, ok := b.applied[key]; ok {
+				continue
+			}
+			b.applied[key] = struct{}{}
+			switch config.Product {
+			case "UPDATER_TASK":
+				b.stats.TasksApplied++
+			case "UPDATER_CATALOG_DD":
+				if config.ApplyState == 2 {
+					b.stats.CatalogsApplied++
+				}
+			}
+		}
+		for _, pkg := range client.GetClientUpdater().GetPackages() {
+			if pkg.Package == "datadog-agent" {
+				stable = pkg.StableVersion
+			}
+		}
+	}
+	return stable
+}
+
+// catalog lists catalogSize new versions of the agent, none of them is ever requested.
+func (b *backend) catalog() *pbgo.File {
+	var packages []map[string]string
+	for i := 0; i < *catalogSize; i++ {
+		packages = append(packages, map[string]string{
+			"package":  "datadog-agent",
+			"version":  fmt.Sprintf("7.99.%d-%d", b.version, i),
+			"url":      "oci://install.datadoghq.com/agent-package@" + packageDigest,
+			"platform": "linux",
+			"arch":     "amd64",
+		})
+	}
+	raw, _ := json.Marshal(map[string]interface{}{"packages": packages})
+	return &pbgo.File{Path: fmt.Sprintf("datadog/%d/UPDATER_CATALOG_DD/catalog/config", orgID), Raw: raw}
+}
+
+// task returns a remote request expecting the reported state, alternating experiment starts for
+// versions missing from the catalog and experiment stops.
+func (b *backend) task(i int, stable string) *pbgo.File {
+	id := fmt.Sprintf("soak-%d-%d", b.version, i)
+	method, params := "stop_experiment", "{}"
+	if i%2 == 0 {
+		method, params = "start_experiment", fmt.Sprintf(`{"version":"7.0.%d-%d"}`, b.version, i)
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id":             id,
+		"package_name":   "datadog-agent",
+		"method":         method,
+		"params":         json.RawMessage(params),
+		"expected_state": map[string]string{"stable": stable},
+	})
+	return &pbgo.File{Path: fmt.Sprintf("datadog/%d/UPDATER_TASK/%s/config", orgID, id), Raw: raw}
+}
+
+func (b *backend) getStats(w http.ResponseWriter, _ *http.Request) {
+	b.Lock()
+	defer b.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(b.stats)
+}
+
+func writeProto(w http.ResponseWriter, m proto.Message) {
+	raw, err := proto.Marshal(m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(raw)
+}
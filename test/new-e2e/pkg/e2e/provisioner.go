@@ -15,6 +15,13 @@ type Diagnosable interface {
 	Diagnose(ctx context.Context, stackName string) (string, error)
 }
 
+// Snapshotable defines the interface for a provisioner able to snapshot the resources it provisioned
+// and to restore them later, without provisioning them again.
+type Snapshotable interface {
+	Snapshot(ctx context.Context, stackName string, logger io.Writer) error
+	Restore(ctx context.Context, stackName string, logger io.Writer) error
+}
+
 // Provisioner defines the interface for a provisioner.
 type Provisioner interface {
 	ID() string
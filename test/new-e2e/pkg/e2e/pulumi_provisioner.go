@@ -24,17 +24,26 @@ const (
 // PulumiEnvRunFunc is a function that runs a Pulumi program with a given environment.
 type PulumiEnvRunFunc[Env any] func(ctx *pulumi.Context, env *Env) error
 
+// Snapshotter takes, restores and deletes snapshots of the resources of a Pulumi stack.
+type Snapshotter interface {
+	Snapshot(ctx context.Context, pulumiStackName string, logger io.Writer) error
+	Restore(ctx context.Context, pulumiStackName string, logger io.Writer) error
+	Delete(ctx context.Context, pulumiStackName string, logger io.Writer) error
+}
+
 // PulumiProvisioner is a provisioner based on Pulumi with binding to an environment.
 type PulumiProvisioner[Env any] struct {
 	id           string
 	runFunc      PulumiEnvRunFunc[Env]
 	configMap    runner.ConfigMap
 	diagnoseFunc func(ctx context.Context, stackName string) (string, error)
+	snapshotter  Snapshotter
 }
 
 var (
 	_ TypedProvisioner[any] = &PulumiProvisioner[any]{}
 	_ UntypedProvisioner    = &PulumiProvisioner[any]{}
+	_ Snapshotable          = &PulumiProvisioner[any]{}
 )
 
 // NewTypedPulumiProvisioner returns a new PulumiProvisioner.
@@ -115,7 +124,47 @@ func (pp *PulumiProvisioner[Env]) SetDiagnoseFunc(diagnoseFunc func(ctx context.
 	pp.diagnoseFunc = diagnoseFunc
 }
 
-// Destroy deletes the Pulumi stack.
+// SetSnapshotter sets the snapshotter used to snapshot and restore the resources of the stack.
+func (pp *PulumiProvisioner[Env]) SetSnapshotter(snapshotter Snapshotter) {
+	pp.snapshotter = snapshotter
+}
+
+// Snapshot takes a snapshot of the resources of the Pulumi stack, replacing the previous one.
+// It returns ErrSnapshotNotSupported if no snapshotter is set.
+func (pp *PulumiProvisioner[Env]) Snapshot(ctx context.Context, stackName string, logger io.Writer) error {
+	if pp.snapshotter == nil {
+		return ErrSnapshotNotSupported
+	}
+	pulumiStackName, err := infra.GetStackManager().GetPulumiStackName(stackName)
+	if err != nil {
+		return err
+	}
+	return pp.snapshotter.Snapshot(ctx, pulumiStackName, logger)
+}
+
+// Restore restores the resources of the Pulumi stack to their last snapshot.
+// It returns ErrSnapshotNotSupported if no snapshotter is set.
+func (pp *PulumiProvisioner[Env]) Restore(ctx context.Context, stackName string, logger io.Writer) error {
+	if pp.snapshotter == nil {
+		return ErrSnapshotNotSupported
+	}
+	pulumiStackName, err := infra.GetStackManager().GetPulumiStackName(stackName)
+	if err != nil {
+		return err
+	}
+	return pp.snapshotter.Restore(ctx, pulumiStackName, logger)
+}
+
+// Destroy deletes the Pulumi stack and the snapshots of its resources.
 func (pp *PulumiProvisioner[Env]) Destroy(ctx context.Context, stackName string, logger io.Writer) error {
+	if pp.snapshotter != nil {
+		pulumiStackName, err := infra.GetStackManager().GetPulumiStackName(stackName)
+		if err == nil {
+			err = pp.snapshotter.Delete(ctx, pulumiStackName, logger)
+		}
+		if err != nil {
+			fmt.Fprintf(logger, "WARNING: unable to delete snapshots of stack %s, err: %v\n", stackName, err)
+		}
+	}
 	return infra.GetStackManager().DeleteStack(ctx, stackName, logger)
 }
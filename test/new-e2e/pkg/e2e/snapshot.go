@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package e2e
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/runner"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/utils/infra"
+)
+
+const envSnapshotFileName = "e2e-snapshot.json"
+
+// ErrSnapshotNotSupported is returned by provisioners unable to snapshot their resources
+var ErrSnapshotNotSupported = errors.New("snapshots are not supported by this provisioner")
+
+// envSnapshot is the local part of an environment snapshot, holding the resources needed to
+// build the environment without provisioning it. The provisioners snapshot the resources themselves.
+type envSnapshot struct {
+	Provisioners []string     `json:"provisioners"`
+	Resources    RawResources `json:"resources"`
+}
+
+func (bs *BaseSuite[Env]) takeSnapshot() error {
+	ctx, cancel := bs.providerContext(createTimeout)
+	defer cancel()
+
+	// Any previous snapshot is invalidated first so that a partial snapshot is never restored
+	if err := removeEnvSnapshot(bs.params.stackName); err != nil {
+		return err
+	}
+
+	logger := newTestLogger(bs.T())
+	for id, provisioner := range bs.currentProvisioners {
+		snapshotable, ok := provisioner.(Snapshotable)
+		if !ok {
+			return fmt.Errorf("provisioner %s: %w", id, ErrSnapshotNotSupported)
+		}
+		if err := snapshotable.Snapshot(ctx, bs.params.stackName, logger); err != nil {
+			return fmt.Errorf("unable to snapshot stack: %s, provisioner %s, err: %w", bs.params.stackName, id, err)
+		}
+	}
+
+	return writeEnvSnapshot(bs.params.stackName, envSnapshot{
+		Provisioners: provisionerIDs(bs.currentProvisioners),
+		Resources:    bs.currentResources,
+	})
+}
+
+func (bs *BaseSuite[Env]) restoreSnapshot() error {
+	snapshot, err := readEnvSnapshot(bs.params.stackName)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(snapshot.Provisioners, provisionerIDs(bs.originalProvisioners)) {
+		return fmt.Errorf("snapshot was taken with provisioners %v", snapshot.Provisioners)
+	}
+
+	ctx, cancel := bs.providerContext(createTimeout)
+	defer cancel()
+
+	logger := newTestLogger(bs.T())
+	for id, provisioner := range bs.originalProvisioners {
+		snapshotable, ok := provisioner.(Snapshotable)
+		if !ok {
+			return fmt.Errorf("provisioner %s: %w", id, ErrSnapshotNotSupported)
+		}
+		if err := snapshotable.Restore(ctx, bs.params.stackName, logger); err != nil {
+			return fmt.Errorf("unable to restore stack: %s, provisioner %s, err: %w", bs.params.stackName, id, err)
+		}
+	}
+
+	newEnv, newEnvFields, newEnvValues, err := bs.createEnv()
+	if err != nil {
+		return fmt.Errorf("unable to create new env: %T for stack: %s, err: %v", newEnv, bs.params.stackName, err)
+	}
+	return bs.setEnv(newEnv, newEnvFields, newEnvValues, snapshot.Resources, bs.originalProvisioners)
+}
+
+// envSnapshotPath returns the path of the environment snapshot, stored in the Pulumi workspace of the stack
+func envSnapshotPath(stackName string) (string, error) {
+	pulumiStackName, err := infra.GetStackManager().GetPulumiStackName(stackName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runner.GetProfile().GetWorkspacePath(pulumiStackName), envSnapshotFileName), nil
+}
+
+func readEnvSnapshot(stackName string) (envSnapshot, error) {
+	var snapshot envSnapshot
+	path, err := envSnapshotPath(stackName)
+	if err != nil {
+		return snapshot, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(content, &snapshot)
+	if err != nil {
+		return snapshot, fmt.Errorf("unable to parse snapshot %s, err: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func writeEnvSnapshot(stackName string, snapshot envSnapshot) error {
+	path, err := envSnapshotPath(stackName)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+func removeEnvSnapshot(stackName string) error {
+	path, err := envSnapshotPath(stackName)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func provisionerIDs(provisioners ProvisionerMap) []string {
+	ids := make([]string, 0, len(provisioners))
+	for id := range provisioners {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
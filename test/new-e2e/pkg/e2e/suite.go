@@ -43,6 +43,9 @@
 // During development, it's highly recommended to use the [params.WithDevMode] option to prevent the environment from being deleted.
 // [params.WithDevMode] is automatically enabled when the `E2E_DEV_MODE` environment variable is set to `true`.
 //
+// In dev mode, the [params.WithSnapshot] option, automatically enabled when the `E2E_SNAPSHOT` environment variable is set to `true`,
+// snapshots the environment once provisioned and restores it on the next runs instead of provisioning it again.
+//
 // # Organizing your tests
 //
 // The execution order for tests in [testify Suite] is IMPLEMENTATION SPECIFIC
@@ -187,6 +190,7 @@ type BaseSuite[Env any] struct {
 
 	originalProvisioners ProvisionerMap
 	currentProvisioners  ProvisionerMap
+	currentResources     RawResources
 
 	firstFailTest string
 }
@@ -233,6 +237,10 @@ func (bs *BaseSuite[Env]) init(options []SuiteOption, self Suite[Env]) {
 		bs.params.devMode = false
 	}
 
+	if !bs.params.devMode {
+		bs.params.snapshot = false
+	}
+
 	if !bs.params.skipDeleteOnFailure {
 		bs.params.skipDeleteOnFailure, _ = runner.GetProfile().ParamStore().GetBoolWithDefault(parameters.SkipDeleteOnFailure, false)
 	}
@@ -306,8 +314,12 @@ func (bs *BaseSuite[Env]) reconcileEnv(targetProvisioners ProvisionerMap) error
 		resources.Merge(provisionerResources)
 	}
 
+	return bs.setEnv(newEnv, newEnvFields, newEnvValues, resources, targetProvisioners)
+}
+
+func (bs *BaseSuite[Env]) setEnv(newEnv *Env, newEnvFields []reflect.StructField, newEnvValues []reflect.Value, resources RawResources, provisioners ProvisionerMap) error {
 	// Env is taken as parameter as some fields may have keys set by Env pulumi program.
-	err = bs.buildEnvFromResources(resources, newEnvFields, newEnvValues)
+	err := bs.buildEnvFromResources(resources, newEnvFields, newEnvValues)
 	if err != nil {
 		return fmt.Errorf("unable to build env: %T from resources for stack: %s, err: %v", newEnv, bs.params.stackName, err)
 	}
@@ -321,7 +333,8 @@ func (bs *BaseSuite[Env]) reconcileEnv(targetProvisioners ProvisionerMap) error
 
 	// On success we update the current environment
 	// We need top copy provisioners to protect against external modifications
-	bs.currentProvisioners = copyProvisioners(targetProvisioners)
+	bs.currentProvisioners = copyProvisioners(provisioners)
+	bs.currentResources = resources
 	bs.env = newEnv
 	return nil
 }
@@ -463,10 +476,25 @@ func (bs *BaseSuite[Env]) SetupSuite() {
 		panic(fmt.Errorf("Forward panic in SetupSuite after TearDownSuite, err was: %v", err))
 	}()
 
+	if bs.params.snapshot {
+		err := bs.restoreSnapshot()
+		if err == nil {
+			bs.T().Logf("Restored environment of stack %s from its snapshot", bs.params.stackName)
+			return
+		}
+		bs.T().Logf("Unable to restore environment from snapshot, provisioning it, err: %v", err)
+	}
+
 	if err := bs.reconcileEnv(bs.originalProvisioners); err != nil {
 		// `panic()` is required to stop the execution of the test suite. Otherwise `testify.Suite` will keep on running suite tests.
 		panic(err)
 	}
+
+	if bs.params.snapshot {
+		if err := bs.takeSnapshot(); err != nil {
+			bs.T().Logf("WARNING: unable to snapshot environment, err: %v", err)
+		}
+	}
 }
 
 // BeforeTest is executed right before the test starts and receives the suite and test names as input.
@@ -527,6 +555,11 @@ func (bs *BaseSuite[Env]) TearDownSuite() {
 			bs.T().Errorf("unable to delete stack: %s, provisioner %s, err: %v", bs.params.stackName, id, err)
 		}
 	}
+
+	// A snapshot left by a previous run in dev mode is stale once the stack is destroyed
+	if err := removeEnvSnapshot(bs.params.stackName); err != nil {
+		bs.T().Logf("unable to remove snapshot of stack: %s, err: %v", bs.params.stackName, err)
+	}
 }
 
 // Run is a helper function to run a test suite.
@@ -540,6 +573,13 @@ func Run[Env any, T Suite[Env]](t *testing.T, s T, options ...SuiteOption) {
 		options = append(options, WithDevMode())
 	}
 
+	snapshot, err := runner.GetProfile().ParamStore().GetBoolWithDefault(parameters.Snapshot, false)
+	if err != nil {
+		t.Logf("Unable to get Snapshot value, Snapshot will be disabled, error: %v", err)
+	} else if snapshot {
+		options = append(options, WithSnapshot())
+	}
+
 	s.init(options, s)
 	suite.Run(t, s)
 }
@@ -20,6 +20,10 @@ type suiteParams struct {
 	// Unavailable in CI.
 	devMode bool
 
+	// Setting snapshot allows to restore the environment from a snapshot taken after its first provisioning
+	// Only available in dev mode.
+	snapshot bool
+
 	skipDeleteOnFailure bool
 
 	provisioners ProvisionerMap
@@ -45,6 +49,16 @@ func WithDevMode() SuiteOption {
 	}
 }
 
+// WithSnapshot enables environment snapshots, it has no effect outside of dev mode.
+// The environment is snapshotted after being provisioned and the next runs of the suite restore it
+// instead of provisioning it again, which can be useful when iterating on tests that modify the environment.
+// All the provisioners of the suite must implement [Snapshotable].
+func WithSnapshot() SuiteOption {
+	return func(options *suiteParams) {
+		options.snapshot = true
+	}
+}
+
 // WithSkipDeleteOnFailure doesn't destroy the environment when a test fails.
 func WithSkipDeleteOnFailure() SuiteOption {
 	return func(options *suiteParams) {
@@ -68,6 +68,10 @@ func EKSProvisioner(opts ...ProvisionerOption) e2e.TypedProvisioner[environments
 
 // EKSRunFunc deploys a EKS environment given a pulumi.Context
 func EKSRunFunc(ctx *pulumi.Context, env *environments.AwsKubernetes, params *ProvisionerParams) error {
+	if params.ipv6 {
+		return fmt.Errorf("IPv6 clusters are not supported by the EKS provisioner")
+	}
+
 	var awsEnv aws.Environment
 	var err error
 	if env.AwsEnvironment != nil {
@@ -12,6 +12,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
 	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments"
+	awssnapshot "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/snapshot"
 	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/utils/optional"
 	"github.com/DataDog/test-infra-definitions/common/utils"
 
@@ -57,6 +58,7 @@ func KindProvisioner(opts ...ProvisionerOption) e2e.TypedProvisioner[environment
 	}, params.extraConfigParams)
 
 	provisioner.SetDiagnoseFunc(kindDiagnoseFunc)
+	provisioner.SetSnapshotter(awssnapshot.NewVMSnapshotter())
 
 	return provisioner
 }
@@ -78,7 +80,12 @@ func KindRunFunc(ctx *pulumi.Context, env *environments.Kubernetes, params *Prov
 		return err
 	}
 
-	kindCluster, err := kubeComp.NewKindCluster(&awsEnv, host, awsEnv.CommonNamer().ResourceName("kind"), params.name, awsEnv.KubernetesVersion(), utils.PulumiDependsOn(installEcrCredsHelperCmd))
+	var kindCluster *kubeComp.Cluster
+	if params.ipv6 {
+		kindCluster, err = newKindIPv6Cluster(&awsEnv, host, awsEnv.CommonNamer().ResourceName("kind"), params.name, awsEnv.KubernetesVersion(), utils.PulumiDependsOn(installEcrCredsHelperCmd))
+	} else {
+		kindCluster, err = kubeComp.NewKindCluster(&awsEnv, host, awsEnv.CommonNamer().ResourceName("kind"), params.name, awsEnv.KubernetesVersion(), utils.PulumiDependsOn(installEcrCredsHelperCmd))
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package awskubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/test-infra-definitions/common/config"
+	"github.com/DataDog/test-infra-definitions/common/utils"
+	"github.com/DataDog/test-infra-definitions/components"
+	"github.com/DataDog/test-infra-definitions/components/command"
+	"github.com/DataDog/test-infra-definitions/components/docker"
+	kubeComp "github.com/DataDog/test-infra-definitions/components/kubernetes"
+	"github.com/DataDog/test-infra-definitions/components/os"
+	"github.com/DataDog/test-infra-definitions/components/remote"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	kindIPv6Version       = "v0.22.0"
+	kindIPv6ReadinessWait = "60s"
+)
+
+// kindIPv6ClusterConfig is the configuration of the kind cluster created by test-infra-definitions
+// with a single-stack IPv6 networking. The API server keeps listening on all the IPv4 addresses of
+// the VM so that it's reachable from the test runner.
+const kindIPv6ClusterConfig = `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+- role: control-plane
+  extraMounts:
+  - hostPath: /proc
+    containerPath: /host/proc
+containerdConfigPatches:
+  - |-
+    [plugins."io.containerd.grpc.v1.cri".registry.mirrors."docker.io"]
+      endpoint = ["https://mirror.gcr.io", "https://registry-1.docker.io"]
+networking:
+  ipFamily: ipv6
+  apiServerAddress: "0.0.0.0"
+  apiServerPort: 8443
+`
+
+// Source: https://github.com/kubernetes-sigs/kind/releases/tag/v0.22.0
+var kindIPv6NodeImages = map[string]string{
+	"1.29": "v1.29.2@sha256:51a1434a5397193442f0be2a297b488b6c919ce8a3931be0ce822606ea5ca245",
+	"1.28": "v1.28.7@sha256:9bc6c451a289cf96ad0bbaf33d416901de6fd632415b076ab05f5fa7e4f65c58",
+	"1.27": "v1.27.11@sha256:681253009e68069b8e01aad36a1e0fa8cf18bb0ab3e5c4069b2e65cafdd70843",
+	"1.26": "v1.26.14@sha256:5d548739ddef37b9318c70cb977f57bf3e5015e4552be4e27e57280a8cbb8e4f",
+	"1.25": "v1.25.16@sha256:e8b50f8e06b44bb65a93678a65a26248fae585b3d3c2a669e5ca6c90c69dc519",
+	"1.24": "v1.24.17@sha256:bad10f9b98d54586cba05a7eaa1b61c6b90bfc4ee174fdc43a7b75ca75c95e51",
+}
+
+// kindIPv6NodeImage returns the kind node image to use for the given Kubernetes version
+func kindIPv6NodeImage(kubeVersion string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(kubeVersion, "v"), ".", 3)
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid kubernetes version %q", kubeVersion)
+	}
+	nodeImage, found := kindIPv6NodeImages[parts[0]+"."+parts[1]]
+	if !found {
+		return "", fmt.Errorf("unsupported kubernetes version %s for an IPv6 kind cluster", kubeVersion)
+	}
+	return nodeImage, nil
+}
+
+// newKindIPv6Cluster installs a single-stack IPv6 kind cluster on a Linux virtual machine. It mirrors
+// kubeComp.NewKindCluster, which only creates IPv4 clusters.
+func newKindIPv6Cluster(env config.Env, vm *remote.Host, resourceName, kindClusterName string, kubeVersion string, opts ...pulumi.ResourceOption) (*kubeComp.Cluster, error) {
+	return components.NewComponent(env, resourceName, func(clusterComp *kubeComp.Cluster) error {
+		opts = utils.MergeOptions[pulumi.ResourceOption](opts, pulumi.Parent(clusterComp))
+
+		runner := vm.OS.Runner()
+		curlCommand, err := vm.OS.PackageManager().Ensure("curl", nil, "", opts...)
+		if err != nil {
+			return err
+		}
+
+		dockerManager, err := docker.NewManager(env, vm, opts...)
+		if err != nil {
+			return err
+		}
+		opts = utils.MergeOptions(opts, utils.PulumiDependsOn(dockerManager, curlCommand))
+
+		nodeImageVersion, err := kindIPv6NodeImage(kubeVersion)
+		if err != nil {
+			return err
+		}
+
+		kindArch := vm.OS.Descriptor().Architecture
+		if kindArch == os.AMD64Arch {
+			kindArch = "amd64"
+		}
+		kindInstall, err := runner.Command(
+			env.CommonNamer().ResourceName("kind-install"),
+			&command.Args{
+				Create: pulumi.Sprintf(`curl --retry 10 -fsSLo ./kind "https://kind.sigs.k8s.io/dl/%s/kind-linux-%s" && sudo install kind /usr/local/bin/kind`, kindIPv6Version, kindArch),
+			},
+			opts...,
+		)
+		if err != nil {
+			return err
+		}
+
+		clusterConfigFilePath := fmt.Sprintf("/tmp/kind-cluster-%s.yaml", kindClusterName)
+		clusterConfig, err := vm.OS.FileManager().CopyInlineFile(
+			pulumi.String(kindIPv6ClusterConfig),
+			clusterConfigFilePath, opts...)
+		if err != nil {
+			return err
+		}
+
+		nodeImage := fmt.Sprintf("%s/kindest/node:%s", env.InternalDockerhubMirror(), nodeImageVersion)
+		createCluster, err := runner.Command(
+			env.CommonNamer().ResourceName("kind-create-cluster", resourceName),
+			&command.Args{
+				Create:   pulumi.Sprintf("kind create cluster --name %s --config %s --image %s --wait %s", kindClusterName, clusterConfigFilePath, nodeImage, kindIPv6ReadinessWait),
+				Delete:   pulumi.Sprintf("kind delete cluster --name %s", kindClusterName),
+				Triggers: pulumi.Array{pulumi.String(kindIPv6ClusterConfig)},
+			},
+			utils.MergeOptions(opts, utils.PulumiDependsOn(clusterConfig, kindInstall), pulumi.DeleteBeforeReplace(true))...,
+		)
+		if err != nil {
+			return err
+		}
+
+		kubeConfigCmd, err := runner.Command(
+			env.CommonNamer().ResourceName("kind-kubeconfig", resourceName),
+			&command.Args{
+				Create: pulumi.Sprintf("kind get kubeconfig --name %s", kindClusterName),
+			},
+			utils.MergeOptions(opts, utils.PulumiDependsOn(createCluster))...,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Point the kubeconfig to the private IP of the VM and skip the TLS verification
+		clusterComp.KubeConfig = pulumi.All(kubeConfigCmd.Stdout, vm.Address).ApplyT(func(args []interface{}) string {
+			allowInsecure := regexp.MustCompile("certificate-authority-data:.+").ReplaceAllString(args[0].(string), "insecure-skip-tls-verify: true")
+			return strings.ReplaceAll(allowInsecure, "0.0.0.0", args[1].(string))
+		}).(pulumi.StringOutput)
+		clusterComp.ClusterName = pulumi.String(kindClusterName).ToStringOutput()
+
+		return nil
+	}, opts...)
+}
@@ -35,6 +35,7 @@ type ProvisionerParams struct {
 	eksBottlerocketNodeGroup bool
 	eksWindowsNodeGroup      bool
 	deployDogstatsd          bool
+	ipv6                     bool
 }
 
 func newProvisionerParams() *ProvisionerParams {
@@ -51,6 +52,7 @@ func newProvisionerParams() *ProvisionerParams {
 		eksBottlerocketNodeGroup: false,
 		eksWindowsNodeGroup:      false,
 		deployDogstatsd:          false,
+		ipv6:                     false,
 	}
 }
 
@@ -139,6 +141,14 @@ func WithDeployDogstatsd() ProvisionerOption {
 	}
 }
 
+// WithIPv6 creates a single-stack IPv6 cluster, it is only supported by the kind provisioner
+func WithIPv6() ProvisionerOption {
+	return func(params *ProvisionerParams) error {
+		params.ipv6 = true
+		return nil
+	}
+}
+
 // WithoutFakeIntake removes the fake intake
 func WithoutFakeIntake() ProvisionerOption {
 	return func(params *ProvisionerParams) error {
@@ -0,0 +1,277 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package awssnapshot contains a snapshotter of the EC2 instances of a stack, restoring them by
+// replacing their root volume with a snapshot taken after provisioning.
+package awssnapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/pointer"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	awsec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const (
+	stackTagKey    = "e2e-snapshot-stack"
+	instanceTagKey = "e2e-snapshot-instance"
+
+	snapshotTimeout     = 30 * time.Minute
+	restoreTimeout      = 15 * time.Minute
+	restorePollInterval = 10 * time.Second
+)
+
+// VMSnapshotter snapshots the root volume of the EC2 instances of a stack
+type VMSnapshotter struct{}
+
+var _ e2e.Snapshotter = &VMSnapshotter{}
+
+// NewVMSnapshotter returns a new VMSnapshotter
+func NewVMSnapshotter() *VMSnapshotter {
+	return &VMSnapshotter{}
+}
+
+// Snapshot takes a snapshot of the root volume of every instance of the stack, replacing the previous ones
+func (s *VMSnapshotter) Snapshot(ctx context.Context, pulumiStackName string, logger io.Writer) error {
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	err = deleteSnapshots(ctx, client, pulumiStackName, logger)
+	if err != nil {
+		return err
+	}
+	instances, err := stackInstances(ctx, client, pulumiStackName)
+	if err != nil {
+		return err
+	}
+
+	snapshotIDs := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		volumeID, err := rootVolumeID(instance)
+		if err != nil {
+			return err
+		}
+		output, err := client.CreateSnapshot(ctx, &awsec2.CreateSnapshotInput{
+			VolumeId:    volumeID,
+			Description: pointer.Ptr(fmt.Sprintf("E2E snapshot of %s for stack %s", *instance.InstanceId, pulumiStackName)),
+			TagSpecifications: []awsec2types.TagSpecification{
+				{
+					ResourceType: awsec2types.ResourceTypeSnapshot,
+					Tags: []awsec2types.Tag{
+						{Key: pointer.Ptr(stackTagKey), Value: pointer.Ptr(pulumiStackName)},
+						{Key: pointer.Ptr(instanceTagKey), Value: instance.InstanceId},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to snapshot volume %s of instance %s: %v", *volumeID, *instance.InstanceId, err)
+		}
+		fmt.Fprintf(logger, "Taking snapshot %s of instance %s\n", *output.SnapshotId, *instance.InstanceId)
+		snapshotIDs = append(snapshotIDs, *output.SnapshotId)
+	}
+
+	err = awsec2.NewSnapshotCompletedWaiter(client).Wait(ctx, &awsec2.DescribeSnapshotsInput{
+		SnapshotIds: snapshotIDs,
+	}, snapshotTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to wait for snapshots of stack %s: %v", pulumiStackName, err)
+	}
+	return nil
+}
+
+// Restore replaces the root volume of every instance of the stack with its snapshot
+func (s *VMSnapshotter) Restore(ctx context.Context, pulumiStackName string, logger io.Writer) error {
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	snapshots, err := stackSnapshots(ctx, client, pulumiStackName)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshot found for stack %s", pulumiStackName)
+	}
+	instances, err := stackInstances(ctx, client, pulumiStackName)
+	if err != nil {
+		return err
+	}
+	if len(instances) != len(snapshots) {
+		return fmt.Errorf("found %d snapshots for %d instances in stack %s", len(snapshots), len(instances), pulumiStackName)
+	}
+
+	taskIDs := make([]string, 0, len(snapshots))
+	instanceIDs := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		instanceID := tagValue(snapshot.Tags, instanceTagKey)
+		if instanceID == "" {
+			return fmt.Errorf("snapshot %s has no %s tag", *snapshot.SnapshotId, instanceTagKey)
+		}
+		output, err := client.CreateReplaceRootVolumeTask(ctx, &awsec2.CreateReplaceRootVolumeTaskInput{
+			InstanceId:               pointer.Ptr(instanceID),
+			SnapshotId:               snapshot.SnapshotId,
+			DeleteReplacedRootVolume: pointer.Ptr(true),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore instance %s from snapshot %s: %v", instanceID, *snapshot.SnapshotId, err)
+		}
+		fmt.Fprintf(logger, "Restoring instance %s from snapshot %s\n", instanceID, *snapshot.SnapshotId)
+		taskIDs = append(taskIDs, *output.ReplaceRootVolumeTask.ReplaceRootVolumeTaskId)
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+
+	err = waitReplaceRootVolumeTasks(ctx, client, taskIDs)
+	if err != nil {
+		return err
+	}
+	err = awsec2.NewInstanceRunningWaiter(client).Wait(ctx, &awsec2.DescribeInstancesInput{
+		InstanceIds: instanceIDs,
+	}, restoreTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to wait for instances of stack %s: %v", pulumiStackName, err)
+	}
+	return nil
+}
+
+// Delete deletes the snapshots of the stack
+func (s *VMSnapshotter) Delete(ctx context.Context, pulumiStackName string, logger io.Writer) error {
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	return deleteSnapshots(ctx, client, pulumiStackName, logger)
+}
+
+func newClient(ctx context.Context) (*awsec2.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	return awsec2.NewFromConfig(cfg), nil
+}
+
+// stackInstances returns the instances of the stack, named after the stack by Pulumi
+func stackInstances(ctx context.Context, client *awsec2.Client, pulumiStackName string) ([]awsec2types.Instance, error) {
+	output, err := client.DescribeInstances(ctx, &awsec2.DescribeInstancesInput{
+		Filters: []awsec2types.Filter{
+			{
+				Name:   pointer.Ptr("tag:managed-by"),
+				Values: []string{"pulumi"},
+			},
+			{
+				Name:   pointer.Ptr("tag:Name"),
+				Values: []string{pulumiStackName + "-*"},
+			},
+			{
+				Name:   pointer.Ptr("instance-state-name"),
+				Values: []string{"pending", "running"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instances: %v", err)
+	}
+	var instances []awsec2types.Instance
+	for _, reservation := range output.Reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instance found for stack %s", pulumiStackName)
+	}
+	return instances, nil
+}
+
+func stackSnapshots(ctx context.Context, client *awsec2.Client, pulumiStackName string) ([]awsec2types.Snapshot, error) {
+	output, err := client.DescribeSnapshots(ctx, &awsec2.DescribeSnapshotsInput{
+		OwnerIds: []string{"self"},
+		Filters: []awsec2types.Filter{
+			{
+				Name:   pointer.Ptr("tag:" + stackTagKey),
+				Values: []string{pulumiStackName},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe snapshots: %v", err)
+	}
+	return output.Snapshots, nil
+}
+
+func deleteSnapshots(ctx context.Context, client *awsec2.Client, pulumiStackName string, logger io.Writer) error {
+	snapshots, err := stackSnapshots(ctx, client, pulumiStackName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, snapshot := range snapshots {
+		_, err := client.DeleteSnapshot(ctx, &awsec2.DeleteSnapshotInput{
+			SnapshotId: snapshot.SnapshotId,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete snapshot %s: %v", *snapshot.SnapshotId, err))
+			continue
+		}
+		fmt.Fprintf(logger, "Deleted snapshot %s\n", *snapshot.SnapshotId)
+	}
+	return errors.Join(errs...)
+}
+
+func waitReplaceRootVolumeTasks(ctx context.Context, client *awsec2.Client, taskIDs []string) error {
+	ctx, cancel := context.WithTimeout(ctx, restoreTimeout)
+	defer cancel()
+	for {
+		output, err := client.DescribeReplaceRootVolumeTasks(ctx, &awsec2.DescribeReplaceRootVolumeTasksInput{
+			ReplaceRootVolumeTaskIds: taskIDs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe root volume replacements: %v", err)
+		}
+		done := true
+		for _, task := range output.ReplaceRootVolumeTasks {
+			switch task.TaskState {
+			case awsec2types.ReplaceRootVolumeTaskStateSucceeded:
+			case awsec2types.ReplaceRootVolumeTaskStateFailed, awsec2types.ReplaceRootVolumeTaskStateFailedDetached:
+				return fmt.Errorf("root volume replacement of instance %s failed", *task.InstanceId)
+			default:
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for root volume replacements: %w", ctx.Err())
+		case <-time.After(restorePollInterval):
+		}
+	}
+}
+
+func rootVolumeID(instance awsec2types.Instance) (*string, error) {
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.DeviceName != nil && instance.RootDeviceName != nil && *mapping.DeviceName == *instance.RootDeviceName && mapping.Ebs != nil {
+			return mapping.Ebs.VolumeId, nil
+		}
+	}
+	return nil, fmt.Errorf("no root EBS volume found for instance %s", *instance.InstanceId)
+}
+
+func tagValue(tags []awsec2types.Tag, key string) string {
+	for _, tag := range tags {
+		if tag.Key != nil && *tag.Key == key && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
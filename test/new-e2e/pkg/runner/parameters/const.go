@@ -49,4 +49,6 @@ const (
 	PulumiVerboseProgressStreams StoreKey = "pulumi_verbose_progress_streams"
 	// DevMode config flag parameter name
 	DevMode StoreKey = "dev_mode"
+	// Snapshot config flag parameter name
+	Snapshot StoreKey = "snapshot"
 )
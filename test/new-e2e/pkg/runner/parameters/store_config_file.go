@@ -39,6 +39,7 @@ type ConfigParams struct {
 	OutputDir string `yaml:"outputDir"`
 	Pulumi    Pulumi `yaml:"pulumi"`
 	DevMode   string `yaml:"devMode"`
+	Snapshot  string `yaml:"snapshot"`
 }
 
 // AWS instance contains AWS related parameters
@@ -152,6 +153,8 @@ func (s configFileValueStore) get(key StoreKey) (string, error) {
 		value = s.config.ConfigParams.Pulumi.VerboseProgressStreams
 	case DevMode:
 		value = s.config.ConfigParams.DevMode
+	case Snapshot:
+		value = s.config.ConfigParams.Snapshot
 	}
 
 	if value == "" {
@@ -568,7 +568,9 @@ func sendEventToDatadog(sender datadogEventSender, title string, message string,
 // GetPulumiStackName returns the Pulumi stack name
 // The internal Pulumi stack name should normally remain hidden as all the Pulumi interactions
 // should be done via the StackManager.
-// The only use case for getting the internal Pulumi stack name is to interact directly with Pulumi for debug purposes.
+// The only use case for getting the internal Pulumi stack name is to interact directly with Pulumi for debug purposes,
+// or with the resources of the stack, for instance to snapshot them.
+// The name of a stack that hasn't been loaded yet is derived from the profile.
 func (sm *StackManager) GetPulumiStackName(name string) (_ string, err error) {
 	defer func() {
 		if err != nil {
@@ -578,7 +580,7 @@ func (sm *StackManager) GetPulumiStackName(name string) (_ string, err error) {
 
 	stack, ok := sm.stacks.Get(name)
 	if !ok {
-		return "", fmt.Errorf("stack %s not present", name)
+		return buildStackName(runner.GetProfile().NamePrefix(), name), nil
 	}
 
 	return stack.Name(), nil
@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package containers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DataDog/test-infra-definitions/common/config"
+	"github.com/DataDog/test-infra-definitions/components/datadog/apps/mutatedbyadmissioncontroller"
+	"github.com/DataDog/test-infra-definitions/components/datadog/kubernetesagentparams"
+	kubeComp "github.com/DataDog/test-infra-definitions/components/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments"
+	awskubernetes "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/kubernetes"
+)
+
+// The agent host is injected from the IP of the node, which is an IPv6 address on an IPv6 cluster
+const kindIPv6HelmValues = `
+clusterAgent:
+  admissionController:
+    enabled: true
+    mutateUnlabelled: false
+    configMode: hostip
+`
+
+// kindIPv6Suite checks the admission controller on a single-stack IPv6 cluster, where the webhook
+// is reached through an IPv6 service and the agent URLs injected in the pods are IPv6 addresses
+type kindIPv6Suite struct {
+	e2e.BaseSuite[environments.Kubernetes]
+}
+
+func TestKindIPv6Suite(t *testing.T) {
+	e2e.Run(t, &kindIPv6Suite{}, e2e.WithProvisioner(
+		awskubernetes.KindProvisioner(
+			awskubernetes.WithName("kind-ipv6"),
+			awskubernetes.WithIPv6(),
+			awskubernetes.WithAgentOptions(kubernetesagentparams.WithHelmValues(kindIPv6HelmValues)),
+			awskubernetes.WithWorkloadApp(func(e config.Env, kubeProvider *kubernetes.Provider) (*kubeComp.Workload, error) {
+				return mutatedbyadmissioncontroller.K8sAppDefinition(e, kubeProvider, "workload-mutated", "workload-mutated-lib-injection")
+			}),
+		),
+	))
+}
+
+func isIPv6(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() == nil
+}
+
+func (suite *kindIPv6Suite) TestClusterIsIPv6() {
+	ctx := context.Background()
+	client := suite.Env().KubernetesCluster.Client()
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(nodes.Items)
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				suite.True(isIPv6(address.Address), "node %s has a non IPv6 internal IP %s", node.Name, address.Address)
+			}
+		}
+	}
+
+	apiService, err := client.CoreV1().Services("default").Get(ctx, "kubernetes", metav1.GetOptions{})
+	suite.Require().NoError(err)
+	suite.True(isIPv6(apiService.Spec.ClusterIP), "unexpected cluster IP %s for the kubernetes service", apiService.Spec.ClusterIP)
+}
+
+// TestAdmissionWebhookService checks the webhook is addressed through a service with an IPv6 cluster
+// IP, backed by the cluster agent pods
+func (suite *kindIPv6Suite) TestAdmissionWebhookService() {
+	ctx := context.Background()
+	client := suite.Env().KubernetesCluster.Client()
+
+	webhookConfig, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "datadog-webhook", metav1.GetOptions{})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(webhookConfig.Webhooks)
+
+	for _, webhook := range webhookConfig.Webhooks {
+		suite.Nil(webhook.ClientConfig.URL, "webhook %s should be addressed through a service", webhook.Name)
+		if !suite.NotNil(webhook.ClientConfig.Service, "webhook %s has no service", webhook.Name) {
+			continue
+		}
+		ref := webhook.ClientConfig.Service
+
+		service, err := client.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		suite.Require().NoError(err)
+		suite.Equal([]corev1.IPFamily{corev1.IPv6Protocol}, service.Spec.IPFamilies)
+		suite.True(isIPv6(service.Spec.ClusterIP), "unexpected cluster IP %s for service %s/%s", service.Spec.ClusterIP, ref.Namespace, ref.Name)
+
+		suite.EventuallyWithTf(func(c *assert.CollectT) {
+			endpoints, err := client.CoreV1().Endpoints(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if !assert.NoError(c, err) {
+				return
+			}
+			var addresses []string
+			for _, subset := range endpoints.Subsets {
+				for _, address := range subset.Addresses {
+					addresses = append(addresses, address.IP)
+				}
+			}
+			if !assert.NotEmpty(c, addresses) {
+				return
+			}
+			for _, address := range addresses {
+				assert.True(c, isIPv6(address), "unexpected endpoint address %s", address)
+			}
+		}, 5*time.Minute, 10*time.Second, "The service %s/%s of the webhook has no ready IPv6 endpoints", ref.Namespace, ref.Name)
+	}
+}
+
+// TestAdmissionControllerAgentHost checks the pods created on the cluster are mutated, which requires
+// the webhook to be reachable over IPv6, and are given the IPv6 address of their node as agent host
+func (suite *kindIPv6Suite) TestAdmissionControllerAgentHost() {
+	ctx := context.Background()
+	client := suite.Env().KubernetesCluster.Client()
+	namespace, name := "workload-mutated", "mutated"
+
+	// Delete the pod to ensure it is recreated after the admission controller is deployed
+	err := client.CoreV1().Pods(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: fields.OneTermEqualSelector("app", name).String(),
+	})
+	suite.Require().NoError(err)
+
+	var pod corev1.Pod
+	suite.Require().EventuallyWithTf(func(c *assert.CollectT) {
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fields.OneTermEqualSelector("app", name).String(),
+		})
+		if !assert.NoError(c, err) {
+			return
+		}
+		if !assert.Len(c, pods.Items, 1) {
+			return
+		}
+		pod = pods.Items[0]
+		assert.NotEmpty(c, pod.Status.HostIP)
+	}, 2*time.Minute, 10*time.Second, "Failed to witness the scheduling of pod with name %s in namespace %s", name, namespace)
+
+	suite.Require().Len(pod.Spec.Containers, 1)
+	env := make(map[string]corev1.EnvVar)
+	for _, envVar := range pod.Spec.Containers[0].Env {
+		env[envVar.Name] = envVar
+	}
+
+	if suite.Contains(env, "DD_AGENT_HOST") {
+		agentHost := env["DD_AGENT_HOST"]
+		if suite.NotNil(agentHost.ValueFrom) && suite.NotNil(agentHost.ValueFrom.FieldRef) {
+			suite.Equal("status.hostIP", agentHost.ValueFrom.FieldRef.FieldPath)
+		}
+	}
+	suite.Contains(env, "DD_ENTITY_ID")
+	suite.True(isIPv6(pod.Status.HostIP), "unexpected host IP %s for pod %s/%s", pod.Status.HostIP, namespace, pod.Name)
+	for _, podIP := range pod.Status.PodIPs {
+		suite.True(isIPv6(podIP.IP), "unexpected IP %s for pod %s/%s", podIP.IP, namespace, pod.Name)
+	}
+}
@@ -34,10 +34,11 @@ type Evt struct {
 
 // RuleEvent represents a rule event
 type RuleEvent struct {
-	Event   `mapstructure:",squash"`
-	Evt     `json:"evt" mapstructure:"evt"`
-	Process Process `json:"process" mapstructure:"process"`
-	File    File    `json:"file" mapstructure:"file"`
+	Event     `mapstructure:",squash"`
+	Evt       `json:"evt" mapstructure:"evt"`
+	Process   Process   `json:"process" mapstructure:"process"`
+	File      File      `json:"file" mapstructure:"file"`
+	Container Container `json:"container" mapstructure:"container"`
 }
 
 // Get implements the GetterFromPointer interface
@@ -50,6 +51,11 @@ type File struct {
 	Path string `json:"path" mapstructure:"path"`
 }
 
+// Container represents the container context of an event
+type Container struct {
+	ID string `json:"id" mapstructure:"id"`
+}
+
 // Process represents a process
 type Process struct {
 	Executable File `json:"executable" mapstructure:"executable"`
@@ -0,0 +1,275 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package cws
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	agentmodel "github.com/DataDog/agent-payload/v5/process"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments"
+	awshost "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/host"
+	"github.com/DataDog/datadog-agent/test/new-e2e/tests/cws/api"
+	"github.com/DataDog/datadog-agent/test/new-e2e/tests/cws/config"
+
+	"github.com/DataDog/test-infra-definitions/components/datadog/agentparams"
+	"github.com/DataDog/test-infra-definitions/components/os"
+	"github.com/DataDog/test-infra-definitions/scenarios/aws/ec2"
+)
+
+const (
+	// cgroupHostnamePrefix is the prefix of the hostname of the agent
+	cgroupHostnamePrefix = "cws-e2e-cgroup-host"
+
+	// cgroupPolicyPath is the path of the policy catching the opens of the containers
+	cgroupPolicyPath = "/etc/datadog-agent/runtime-security.d/cgroup.policy"
+
+	// cgroupRuleID is the ID of the rule catching the opens of the containers
+	cgroupRuleID = "cgroup_e2e_open"
+
+	// cgroupPolicy catches the opens of the files touched in the containers
+	cgroupPolicy = `---
+version: 1.2.0
+rules:
+  - id: cgroup_e2e_open
+    expression: open.file.path =~ "/tmp/cgroup_e2e_*"
+`
+
+	// cgroupProcessConfig enables the process collection to check the containers of the process payloads
+	cgroupProcessConfig = `process_config:
+  process_collection:
+    enabled: true
+`
+
+	// cgroupContainerImage is the image run by the container runtimes
+	cgroupContainerImage = "docker.io/library/busybox:latest"
+)
+
+// cgroupMode is the cgroup hierarchy the host is booted with
+type cgroupMode string
+
+const (
+	cgroupV1     cgroupMode = "v1"
+	cgroupHybrid cgroupMode = "hybrid"
+	cgroupV2     cgroupMode = "v2"
+)
+
+// kernelArgs returns the kernel command line arguments pinning systemd to the cgroup mode
+func (m cgroupMode) kernelArgs() string {
+	switch m {
+	case cgroupV1:
+		return "systemd.unified_cgroup_hierarchy=0 systemd.legacy_systemd_cgroup_controller=1"
+	case cgroupHybrid:
+		return "systemd.unified_cgroup_hierarchy=0"
+	default:
+		return "systemd.unified_cgroup_hierarchy=1"
+	}
+}
+
+// containerRuntime runs a long running container and executes commands in it
+type containerRuntime struct {
+	name string
+	// run starts the container named name and prints its ID
+	run func(name string) string
+	// exec executes a command in the container
+	exec func(id string, command string) string
+}
+
+var containerRuntimes = []containerRuntime{
+	{
+		name: "docker",
+		run: func(name string) string {
+			return fmt.Sprintf("sudo docker run -d --name %s %s sleep 86400", name, cgroupContainerImage)
+		},
+		exec: func(id string, command string) string {
+			return fmt.Sprintf("sudo docker exec %s %s", id, command)
+		},
+	},
+	{
+		name: "containerd",
+		run: func(name string) string {
+			// the container ID is used as-is in the cgroup path, use one that looks like the IDs of the other runtimes
+			id := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+			return fmt.Sprintf("sudo ctr image pull %s >/dev/null && sudo ctr run -d %s %s sleep 86400 >/dev/null && echo %s", cgroupContainerImage, cgroupContainerImage, id, id)
+		},
+		exec: func(id string, command string) string {
+			return fmt.Sprintf("sudo ctr task exec --exec-id %s %s %s", uuid.NewString()[:8], id, command)
+		},
+	},
+	{
+		name: "podman",
+		run: func(name string) string {
+			return fmt.Sprintf("sudo podman run -d --name %s %s sleep 86400", name, cgroupContainerImage)
+		},
+		exec: func(id string, command string) string {
+			return fmt.Sprintf("sudo podman exec %s %s", id, command)
+		},
+	},
+}
+
+type cgroupSuite struct {
+	e2e.BaseSuite[environments.Host]
+	apiClient  *api.Client
+	testID     string
+	mode       cgroupMode
+	containers map[string]string
+}
+
+// TestCgroupSuite checks the container context of the CWS events and of the process payloads on
+// hosts booted with each cgroup hierarchy, for the workloads of each container runtime
+func TestCgroupSuite(t *testing.T) {
+	for _, mode := range []cgroupMode{cgroupV1, cgroupHybrid, cgroupV2} {
+		mode := mode // capture range variable for parallel tests closure
+		t.Run(string(mode), func(t *testing.T) {
+			t.Parallel()
+			testID := uuid.NewString()[:4]
+			ddHostname := fmt.Sprintf("%s-%s-%s", cgroupHostnamePrefix, mode, testID)
+			agentConfig := config.GenDatadogAgentConfig(ddHostname, "tag1", "tag2") + cgroupProcessConfig
+			e2e.Run[environments.Host](t, &cgroupSuite{testID: testID, mode: mode},
+				e2e.WithProvisioner(
+					awshost.ProvisionerNoFakeIntake(
+						awshost.WithEC2InstanceOptions(ec2.WithOS(os.Ubuntu2204)),
+						awshost.WithAgentOptions(
+							agentparams.WithAgentConfig(agentConfig),
+							agentparams.WithSecurityAgentConfig(securityAgentConfig),
+							agentparams.WithSystemProbeConfig(systemProbeConfig),
+						),
+					),
+				),
+				e2e.WithStackName(fmt.Sprintf("cws-cgroup-%s", mode)),
+			)
+			t.Logf("Running testsuite with DD_HOSTNAME=%s", ddHostname)
+		})
+	}
+}
+
+func (s *cgroupSuite) SetupSuite() {
+	s.BaseSuite.SetupSuite()
+	s.apiClient = api.NewClient()
+
+	host := s.Env().RemoteHost
+	host.MustExecute("sudo apt-get update && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y docker.io podman")
+
+	// Pin the cgroup hierarchy on the kernel command line and reboot on it
+	host.MustExecute(fmt.Sprintf(`echo 'GRUB_CMDLINE_LINUX_DEFAULT="$GRUB_CMDLINE_LINUX_DEFAULT %s"' | sudo tee /etc/default/grub.d/99-cgroup.cfg && sudo update-grub`, s.mode.kernelArgs()))
+	bootID := host.MustExecute("cat /proc/sys/kernel/random/boot_id")
+	// the connection is closed by the reboot, the error is expected
+	_, _ = host.Execute("sudo systemctl reboot")
+	require.EventuallyWithT(s.T(), func(c *assert.CollectT) {
+		if !assert.NoError(c, host.Reconnect()) {
+			return
+		}
+		newBootID, err := host.Execute("cat /proc/sys/kernel/random/boot_id")
+		if !assert.NoError(c, err) {
+			return
+		}
+		assert.NotEqual(c, bootID, newBootID, "host did not reboot")
+	}, 10*time.Minute, 10*time.Second)
+	s.requireCgroupMode()
+
+	s.containers = make(map[string]string)
+	for _, runtime := range containerRuntimes {
+		name := fmt.Sprintf("cgroup-e2e-%s-%s", runtime.name, s.testID)
+		id := strings.TrimSpace(host.MustExecute(runtime.run(name)))
+		require.NotEmptyf(s.T(), id, "could not start the %s container", runtime.name)
+		s.containers[runtime.name] = id
+	}
+
+	host.MustExecute(fmt.Sprintf("echo '%s' | sudo tee %s", cgroupPolicy, cgroupPolicyPath))
+	require.EventuallyWithT(s.T(), func(c *assert.CollectT) {
+		_, err := host.Execute(fmt.Sprintf("sudo %s runtime policy reload", securityAgentPath))
+		if !assert.NoError(c, err) {
+			return
+		}
+		testRulesetLoaded(c, s, "file", "cgroup.policy")
+	}, 4*time.Minute, 10*time.Second)
+}
+
+// requireCgroupMode checks that the host runs with the expected cgroup hierarchy
+func (s *cgroupSuite) requireCgroupMode() {
+	host := s.Env().RemoteHost
+	rootFS := strings.TrimSpace(host.MustExecute("stat -fc %T /sys/fs/cgroup/"))
+	_, unifiedErr := host.Execute("test -d /sys/fs/cgroup/unified")
+	switch s.mode {
+	case cgroupV1:
+		require.Equal(s.T(), "tmpfs", rootFS, "cgroup v1 should be mounted")
+		require.Error(s.T(), unifiedErr, "cgroup v2 should not be mounted")
+	case cgroupHybrid:
+		require.Equal(s.T(), "tmpfs", rootFS, "cgroup v1 should be mounted")
+		require.NoError(s.T(), unifiedErr, "cgroup v2 should be mounted")
+	case cgroupV2:
+		require.Equal(s.T(), "cgroup2fs", rootFS, "cgroup v2 should be mounted")
+	}
+}
+
+func (s *cgroupSuite) Hostname() string {
+	return s.Env().Agent.Client.Hostname()
+}
+
+func (s *cgroupSuite) Client() *api.Client {
+	return s.apiClient
+}
+
+func (s *cgroupSuite) TestCWSEventContainerContext() {
+	for _, runtime := range containerRuntimes {
+		s.Run(runtime.name, func() {
+			id := s.containers[runtime.name]
+			filePath := fmt.Sprintf("/tmp/cgroup_e2e_%s", runtime.name)
+			assert.EventuallyWithT(s.T(), func(c *assert.CollectT) {
+				s.Env().RemoteHost.MustExecute(runtime.exec(id, "touch "+filePath))
+				query := fmt.Sprintf("rule_id:%s host:%s @file.path:%s", cgroupRuleID, s.Hostname(), filePath)
+				event, err := api.GetAppEvent[api.RuleEvent](s.apiClient, query)
+				if !assert.NoErrorf(c, err, "could not get %s event for host %s", cgroupRuleID, s.Hostname()) {
+					return
+				}
+				if !assert.NotNil(c, event, "rule event should not be nil") {
+					return
+				}
+				assert.Equal(c, id, event.Container.ID, "event should be attributed to the %s container", runtime.name)
+			}, 10*time.Minute, 30*time.Second)
+		})
+	}
+}
+
+func (s *cgroupSuite) TestProcessContainerContext() {
+	var checkOutput struct {
+		Processes []*agentmodel.Process `json:"processes"`
+	}
+	assert.EventuallyWithT(s.T(), func(c *assert.CollectT) {
+		check, err := s.Env().RemoteHost.Execute("sudo /opt/datadog-agent/embedded/bin/process-agent check process --json")
+		if !assert.NoError(c, err) {
+			return
+		}
+		if !assert.NoError(c, json.Unmarshal([]byte(check), &checkOutput), "failed to unmarshal process check output") {
+			return
+		}
+		for _, runtime := range containerRuntimes {
+			assert.Truef(c, findContainerProcess(checkOutput.Processes, s.containers[runtime.name], "sleep"), "sleep process of the %s container not found", runtime.name)
+		}
+	}, 2*time.Minute, 10*time.Second)
+}
+
+// findContainerProcess returns whether a process with the given command is attributed to the container
+func findContainerProcess(processes []*agentmodel.Process, containerID string, command string) bool {
+	for _, process := range processes {
+		if process.ContainerId != containerID || process.Command == nil || len(process.Command.Args) == 0 {
+			continue
+		}
+		if process.Command.Args[0] == command {
+			return true
+		}
+	}
+	return false
+}
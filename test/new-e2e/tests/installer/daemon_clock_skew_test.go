@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	awshost "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/host"
+	e2eos "github.com/DataDog/test-infra-definitions/components/os"
+	"github.com/DataDog/test-infra-definitions/scenarios/aws/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// clockSkewOverride lowers the GC and heartbeat intervals of the daemon so that several
+	// of their ticks happen around each clock jump
+	clockSkewOverride = `[Service]
+Environment=DD_INSTALLER_GC_INTERVAL=30s
+Environment=DD_INSTALLER_HEARTBEAT_INTERVAL=10s
+`
+	clockSkewOverridePath = "/etc/systemd/system/datadog-installer.service.d/clock-skew.conf"
+)
+
+type daemonClockSkewSuite struct {
+	packageBaseSuite
+}
+
+func TestDaemonClockSkew(t *testing.T) {
+	flavor := e2eos.Ubuntu2204
+	flavor.Architecture = e2eos.AMD64Arch
+	s := &daemonClockSkewSuite{
+		packageBaseSuite: newPackageSuite("daemon-clock-skew", flavor, flavor.Architecture, awshost.WithoutFakeIntake()),
+	}
+	opts := []awshost.ProvisionerOption{
+		awshost.WithEC2InstanceOptions(ec2.WithOSArch(flavor, flavor.Architecture)),
+		awshost.WithoutAgent(),
+	}
+	opts = append(opts, s.ProvisionerOptions()...)
+	e2e.Run(t, s,
+		e2e.WithProvisioner(awshost.Provisioner(opts...)),
+		e2e.WithStackName(s.Name()),
+	)
+}
+
+// TestClockJumps shifts the wall clock of the host forwards and backwards, as NTP or the restore
+// of a VM snapshot would, and checks that the daemon keeps running its periodic garbage
+// collection and serving its API. The timers of the daemon rely on the monotonic clock so
+// neither jump should stall or burst them.
+func (s *daemonClockSkewSuite) TestClockJumps() {
+	s.RunInstallScript("DD_REMOTE_UPDATES=true")
+	defer s.Purge()
+	s.host.WaitForUnitActive("datadog-installer.service")
+
+	require.NoError(s.T(), s.host.WriteFile("/tmp/clock-skew.conf", []byte(clockSkewOverride)))
+	s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo mkdir -p $(dirname %[1]s) && sudo mv /tmp/clock-skew.conf %[1]s", clockSkewOverridePath))
+	defer s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo rm -f %s && sudo systemctl daemon-reload", clockSkewOverridePath))
+	s.Env().RemoteHost.MustExecute("sudo systemctl daemon-reload && sudo systemctl restart datadog-installer.service")
+	s.host.WaitForUnitActive("datadog-installer.service")
+
+	s.Env().RemoteHost.MustExecute("sudo timedatectl set-ntp false")
+	defer s.Env().RemoteHost.MustExecute("sudo timedatectl set-ntp true")
+	pid := s.daemonPID()
+	s.waitForGarbageCollections(1)
+
+	for _, shift := range []string{"+2 hours", "-3 hours", "+1 hour"} {
+		s.T().Logf("shifting the clock by %s", shift)
+		s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo date -s '%s'", shift))
+		s.waitForGarbageCollections(s.garbageCollections() + 1)
+		require.Equal(s.T(), pid, s.daemonPID(), "daemon restarted after the clock shift by %s", shift)
+		out := s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo curl -sf --unix-socket %s -H 'Content-Type: application/json' http://daemon/status", daemonSocket))
+		assert.NotEmpty(s.T(), out)
+	}
+}
+
+// garbageCollections returns the number of garbage collections the daemon ran since it started
+func (s *daemonClockSkewSuite) garbageCollections() int {
+	out := s.Env().RemoteHost.MustExecute("sudo journalctl -u datadog-installer.service -o cat --no-pager | grep -c 'Successfully ran garbage collection' || true")
+	var count int
+	_, err := fmt.Sscan(strings.TrimSpace(out), &count)
+	require.NoError(s.T(), err)
+	return count
+}
+
+// waitForGarbageCollections waits for the daemon to have run at least count garbage collections,
+// a stalled timer makes it time out
+func (s *daemonClockSkewSuite) waitForGarbageCollections(count int) {
+	require.Eventually(s.T(), func() bool {
+		return s.garbageCollections() >= count
+	}, 2*time.Minute, 5*time.Second, "daemon didn't run %d garbage collections", count)
+}
@@ -0,0 +1,225 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package installer
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	awshost "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/host"
+	e2eos "github.com/DataDog/test-infra-definitions/components/os"
+	"github.com/DataDog/test-infra-definitions/scenarios/aws/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	soakDuration  = flag.Duration("soak-duration", 0, "duration of the fleet daemon soak test, the test is skipped if not set")
+	soakBatchSize = flag.Int("soak-batch-size", 500, "number of requests sent to the daemon between two samples")
+)
+
+const (
+	daemonSocket = "/opt/datadog-agent/run/installer.sock"
+	// soakWarmupSamples are the first samples, ignored as the daemon is still allocating its caches.
+	soakWarmupSamples = 3
+	// soakMaxGrowth is the growth over the whole run tolerated by the linear fit of a series,
+	// relative to its mean.
+	soakMaxGrowth = 0.2
+
+	// fakeRemoteConfigAddr is the address of the fake remote config backend on the host.
+	fakeRemoteConfigAddr = "127.0.0.1:7878"
+	// fakeRemoteConfigEnvPath is the environment pointing the daemon to the fake remote config backend,
+	// it's written by the backend as it holds the roots the daemon must trust.
+	fakeRemoteConfigEnvPath  = "/etc/datadog-agent/fake-remote-config.env"
+	fakeRemoteConfigOverride = `[Service]
+EnvironmentFile=/etc/datadog-agent/fake-remote-config.env
+`
+	fakeRemoteConfigOverridePath = "/etc/systemd/system/datadog-installer.service.d/fake-remote-config.conf"
+	// remoteConfigDBPath is the cache of the remote config service of the daemon, it's removed so the
+	// daemon trusts the roots of the fake backend.
+	remoteConfigDBPath = "/opt/datadog-agent/run/remote-config-installer.db"
+)
+
+type daemonSoakSuite struct {
+	packageBaseSuite
+	duration  time.Duration
+	batchSize int
+}
+
+// daemonSample is a snapshot of the resources used by the daemon.
+type daemonSample struct {
+	rss        int
+	fds        int
+	goroutines int
+	rc         fakeRemoteConfigStats
+}
+
+// fakeRemoteConfigStats are the counters of the fake remote config backend, see test/fakeremoteconfig.
+type fakeRemoteConfigStats struct {
+	Polls           int `json:"polls"`
+	Tasks           int `json:"tasks"`
+	TasksApplied    int `json:"tasks_applied"`
+	CatalogsApplied int `json:"catalogs_applied"`
+}
+
+func TestDaemonSoak(t *testing.T) {
+	if *soakDuration == 0 {
+		t.Skip("soak test disabled, set -soak-duration to run it")
+	}
+	flavor := e2eos.Ubuntu2204
+	flavor.Architecture = e2eos.AMD64Arch
+	s := &daemonSoakSuite{
+		packageBaseSuite: newPackageSuite("daemon-soak", flavor, flavor.Architecture, awshost.WithoutFakeIntake()),
+		duration:         *soakDuration,
+		batchSize:        *soakBatchSize,
+	}
+	opts := []awshost.ProvisionerOption{
+		awshost.WithEC2InstanceOptions(ec2.WithOSArch(flavor, flavor.Architecture)),
+		awshost.WithoutAgent(),
+	}
+	opts = append(opts, s.ProvisionerOptions()...)
+	e2e.Run(t, s,
+		e2e.WithProvisioner(awshost.Provisioner(opts...)),
+		e2e.WithStackName(s.Name()),
+	)
+}
+
+// TestSoak drives the daemon with local requests and catalog lookups, and with the catalogs and
+// remote requests of a fake remote config backend, while sampling its memory, file descriptors
+// and goroutines, and fails if any of them trends upwards.
+func (s *daemonSoakSuite) TestSoak() {
+	s.RunInstallScript("DD_REMOTE_UPDATES=true")
+	defer s.Purge()
+	s.host.WaitForUnitActive("datadog-installer.service")
+
+	s.startFakeRemoteConfig()
+	defer s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo systemctl stop fake-remote-config.service; sudo rm -f %s %s && sudo systemctl daemon-reload", fakeRemoteConfigOverridePath, fakeRemoteConfigEnvPath))
+	require.NoError(s.T(), s.host.WriteFile("/tmp/fake-remote-config.conf", []byte(fakeRemoteConfigOverride)))
+	s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo mkdir -p $(dirname %[1]s) && sudo mv /tmp/fake-remote-config.conf %[1]s", fakeRemoteConfigOverridePath))
+	s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo systemctl stop datadog-installer.service && sudo rm -f %s && sudo systemctl daemon-reload && sudo systemctl start datadog-installer.service", remoteConfigDBPath))
+	s.host.WaitForUnitActive("datadog-installer.service")
+	require.Eventually(s.T(), func() bool {
+		return s.fakeRemoteConfigStats().TasksApplied > 0
+	}, 2*time.Minute, 5*time.Second, "daemon didn't apply the remote requests of the fake remote config backend")
+
+	pid := s.daemonPID()
+	require.NoError(s.T(), s.host.WriteFile("/tmp/daemon-soak.sh", []byte(soakScript(s.batchSize))))
+
+	var samples []daemonSample
+	deadline := time.Now().Add(s.duration)
+	for batch := 0; time.Now().Before(deadline); batch++ {
+		s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo bash /tmp/daemon-soak.sh %d", batch))
+		require.Equal(s.T(), pid, s.daemonPID(), "daemon restarted during the soak test")
+		samples = append(samples, s.sample(pid))
+		s.T().Logf("batch %d: %+v", batch, samples[len(samples)-1])
+	}
+	require.Greater(s.T(), len(samples), soakWarmupSamples+1, "soak duration too short to collect samples")
+
+	samples = samples[soakWarmupSamples:]
+	first, last := samples[0].rc, samples[len(samples)-1].rc
+	require.Greater(s.T(), last.TasksApplied, first.TasksApplied, "daemon stopped applying remote requests")
+	require.Greater(s.T(), last.CatalogsApplied, first.CatalogsApplied, "daemon stopped applying catalogs")
+	var rss, fds, goroutines []int
+	for _, sample := range samples {
+		rss = append(rss, sample.rss)
+		fds = append(fds, sample.fds)
+		goroutines = append(goroutines, sample.goroutines)
+	}
+	assertNoGrowthTrend(s.T(), "rss", rss)
+	assertNoGrowthTrend(s.T(), "fds", fds)
+	assertNoGrowthTrend(s.T(), "goroutines", goroutines)
+}
+
+// startFakeRemoteConfig builds the fake remote config backend for the host and runs it there, it
+// writes the environment pointing the daemon to it once it's listening.
+func (s *daemonSoakSuite) startFakeRemoteConfig() {
+	bin := filepath.Join(s.T().TempDir(), "fakeremoteconfig")
+	// the tests run from test/new-e2e/tests/installer, the backend belongs to the main module
+	cmd := exec.Command("go", "build", "-o", bin, "./test/fakeremoteconfig")
+	cmd.Dir = filepath.Join("..", "..", "..", "..")
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	out, err := cmd.CombinedOutput()
+	require.NoError(s.T(), err, "could not build the fake remote config backend: %s", out)
+
+	s.Env().RemoteHost.CopyFile(bin, "/tmp/fakeremoteconfig")
+	s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo systemd-run --unit fake-remote-config /bin/bash -c 'chmod +x /tmp/fakeremoteconfig && exec /tmp/fakeremoteconfig -addr %s -env-file %s'", fakeRemoteConfigAddr, fakeRemoteConfigEnvPath))
+	require.Eventually(s.T(), func() bool {
+		_, err := s.Env().RemoteHost.Execute(fmt.Sprintf("curl -sf http://%s/stats", fakeRemoteConfigAddr))
+		return err == nil
+	}, time.Minute, time.Second, "fake remote config backend didn't start")
+}
+
+func (s *daemonSoakSuite) fakeRemoteConfigStats() fakeRemoteConfigStats {
+	var stats fakeRemoteConfigStats
+	out := s.Env().RemoteHost.MustExecute(fmt.Sprintf("curl -sf http://%s/stats", fakeRemoteConfigAddr))
+	require.NoError(s.T(), json.Unmarshal([]byte(out), &stats))
+	return stats
+}
+
+func (s *packageBaseSuite) daemonPID() int {
+	out := s.Env().RemoteHost.MustExecute("systemctl show -p MainPID --value datadog-installer.service")
+	pid, err := strconv.Atoi(strings.TrimSpace(out))
+	require.NoError(s.T(), err)
+	require.NotZero(s.T(), pid, "daemon is not running")
+	return pid
+}
+
+func (s *daemonSoakSuite) sample(pid int) daemonSample {
+	rss, err := strconv.Atoi(strings.TrimSpace(s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo awk '/VmRSS/ {print $2}' /proc/%d/status", pid))))
+	require.NoError(s.T(), err)
+	fds, err := strconv.Atoi(strings.TrimSpace(s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo ls /proc/%d/fd | wc -l", pid))))
+	require.NoError(s.T(), err)
+
+	var status struct {
+		Goroutines int `json:"goroutines"`
+	}
+	out := s.Env().RemoteHost.MustExecute(fmt.Sprintf("sudo curl -s --unix-socket %s -H 'Content-Type: application/json' http://daemon/status", daemonSocket))
+	require.NoError(s.T(), json.Unmarshal([]byte(out), &status))
+	return daemonSample{rss: rss, fds: fds, goroutines: status.Goroutines, rc: s.fakeRemoteConfigStats()}
+}
+
+// soakScript returns a script sending batchSize requests going through the experiment machinery,
+// each followed by a catalog lookup. The versions requested aren't in the catalog and the experiment
+// is never started so the requests don't touch the installed packages.
+func soakScript(batchSize int) string {
+	return fmt.Sprintf(`#!/bin/bash
+set -e
+for i in $(seq 1 %[1]d); do
+  version="7.0.$1-$i"
+  curl -s --unix-socket %[2]s -H 'Content-Type: application/json' -X POST http://daemon/datadog-agent/experiment/start -d "{\"version\":\"$version\"}" > /dev/null
+  curl -s --unix-socket %[2]s -H 'Content-Type: application/json' -X POST http://daemon/datadog-agent/experiment/stop -d '{}' > /dev/null
+  curl -sf --unix-socket %[2]s -H 'Content-Type: application/json' http://daemon/catalog > /dev/null
+done
+`, batchSize, daemonSocket)
+}
+
+// assertNoGrowthTrend fits a line to the series with least squares and fails if it grows by more
+// than soakMaxGrowth of the mean of the series over the run. Allocations made by the runtime make
+// single samples noisy, a leak shows up as a steady slope whatever the dips in between.
+func assertNoGrowthTrend(t *testing.T, name string, series []int) {
+	n := float64(len(series))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range series {
+		x, y := float64(i), float64(v)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	mean := sumY / n
+	growth := slope * (n - 1)
+	assert.LessOrEqualf(t, growth, mean*soakMaxGrowth, "%s trends upwards, growing by %.1f over the run for a mean of %.1f: %v", name, growth, mean, series)
+}
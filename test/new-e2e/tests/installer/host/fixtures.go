@@ -105,6 +105,12 @@ func (h *Host) SetBrokenDockerConfigAdditionalFields() {
 	h.remote.MustExecute(`echo '{"tomato": "potato"}' | sudo tee /etc/docker/daemon.json`)
 }
 
+// SetDockerConfig writes the given JSON to the Docker daemon configuration and reloads the daemon
+func (h *Host) SetDockerConfig(content string) {
+	h.remote.MustExecute(fmt.Sprintf("echo '%s' | sudo tee /etc/docker/daemon.json", content))
+	h.remote.MustExecute("sudo systemctl reload docker")
+}
+
 // RemoveBrokenDockerConfig removes the broken configuration from the Docker daemon
 func (h *Host) RemoveBrokenDockerConfig() {
 	h.remote.MustExecute("sudo rm /etc/docker/daemon.json")
@@ -6,6 +6,7 @@
 package installer
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/testutil/flake"
 	e2eos "github.com/DataDog/test-infra-definitions/components/os"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -292,6 +294,63 @@ func (s *packageApmInjectSuite) TestVersionBump() {
 	s.assertTraceReceived(traceIDDocker)
 }
 
+func (s *packageApmInjectSuite) TestInstrumentDockerIdempotent() {
+	s.host.InstallDocker()
+	s.host.SetDockerConfig(`{"log-driver": "json-file", "runtimes": {"custom": {"path": "/usr/bin/runc"}}}`)
+	s.RunInstallScript(
+		"DD_APM_INSTRUMENTATION_ENABLED=docker",
+		"DD_APM_INSTRUMENTATION_LIBRARIES=python",
+		envForceInstall("datadog-agent"),
+		envForceInstall("datadog-apm-inject"),
+		envForceVersion("datadog-apm-inject", "0.13.2-beta1-dev.b0d6e40.glci530444874.g6d8b7576-1"),
+		envForceInstall("datadog-apm-library-python"),
+	)
+	defer s.Purge()
+	s.assertDockerdConfigConverged()
+
+	s.host.StartExamplePythonAppInDocker()
+	defer s.host.StopExamplePythonAppInDocker()
+
+	// Instrumenting repeatedly reloads docker but must not alter the configuration
+	for i := 0; i < 3; i++ {
+		s.Env().RemoteHost.MustExecute("sudo datadog-installer apm instrument docker")
+		s.assertDockerdConfigConverged()
+		s.assertDockerWorkloadRunning()
+	}
+
+	// Upgrading the injector points the runtime to the new stable version
+	s.RunInstallScript(
+		"DD_APM_INSTRUMENTATION_ENABLED=docker",
+		"DD_APM_INSTRUMENTATION_LIBRARIES=python",
+		envForceInstall("datadog-agent"),
+		envForceInstall("datadog-apm-inject"),
+		envForceVersion("datadog-apm-inject", "0.14.0-beta1-dev.b0d6e40.glci528580195.g068abe2b-1"),
+		envForceInstall("datadog-apm-library-python"),
+	)
+	state := s.host.State()
+	state.AssertSymlinkExists("/opt/datadog-packages/datadog-apm-inject/stable", "/opt/datadog-packages/datadog-apm-inject/0.14.0-beta1-dev.b0d6e40.glci528580195.g068abe2b-1", "root", "root")
+	s.assertDockerdConfigConverged()
+	s.assertDockerWorkloadRunning()
+
+	s.Env().RemoteHost.MustExecute("sudo datadog-installer apm instrument docker")
+	s.assertDockerdConfigConverged()
+	s.assertDockerWorkloadRunning()
+
+	traceID := rand.Uint64()
+	s.host.CallExamplePythonAppInDocker(fmt.Sprint(traceID))
+	s.assertTraceReceived(traceID)
+
+	// Uninstrumenting restores the default runtime and keeps the user configuration
+	s.Env().RemoteHost.MustExecute("sudo datadog-installer apm uninstrument docker")
+	s.assertDockerdNotInstrumented()
+	config := s.readDockerdConfig()
+	assert.Equal(s.T(), "runc", config.DefaultRuntime)
+	assert.Equal(s.T(), "json-file", config.LogDriver)
+	assert.Equal(s.T(), map[string]dockerRuntime{"custom": {Path: "/usr/bin/runc"}}, config.Runtimes)
+	assert.Equal(s.T(), "runc", strings.TrimSpace(s.Env().RemoteHost.MustExecute("sudo docker system info --format '{{ .DefaultRuntime }}'")))
+	s.assertDockerWorkloadRunning()
+}
+
 func (s *packageApmInjectSuite) TestInstrument() {
 	s.RunInstallScript(
 		"DD_APM_INSTRUMENTATION_ENABLED=host",
@@ -506,6 +565,44 @@ func (s *packageApmInjectSuite) assertDockerdNotInstrumented() {
 	assert.Equal(s.T(), runtimeConfig, "")
 }
 
+type dockerRuntime struct {
+	Path string `json:"path"`
+}
+
+type dockerDaemonConfig struct {
+	DefaultRuntime string                   `json:"default-runtime"`
+	LogDriver      string                   `json:"log-driver"`
+	Runtimes       map[string]dockerRuntime `json:"runtimes"`
+}
+
+func (s *packageApmInjectSuite) readDockerdConfig() dockerDaemonConfig {
+	content, err := s.host.ReadFile("/etc/docker/daemon.json")
+	require.NoError(s.T(), err)
+	var config dockerDaemonConfig
+	require.NoError(s.T(), json.Unmarshal(content, &config))
+	return config
+}
+
+// assertDockerdConfigConverged checks that the docker daemon configuration holds a single
+// injector runtime, set as default, alongside the runtimes configured by the user
+func (s *packageApmInjectSuite) assertDockerdConfigConverged() {
+	config := s.readDockerdConfig()
+	assert.Equal(s.T(), "dd-shim", config.DefaultRuntime)
+	assert.Equal(s.T(), "json-file", config.LogDriver)
+	assert.Equal(s.T(), map[string]dockerRuntime{
+		"custom":  {Path: "/usr/bin/runc"},
+		"dd-shim": {Path: filepath.Join(injectOCIPath, "stable", "inject", "auto_inject_runc")},
+	}, config.Runtimes)
+	s.assertDockerdInstrumented(injectOCIPath)
+	assert.Equal(s.T(), "dd-shim", strings.TrimSpace(s.Env().RemoteHost.MustExecute("sudo docker system info --format '{{ .DefaultRuntime }}'")))
+}
+
+// assertDockerWorkloadRunning checks that the example app container survived the docker reloads
+func (s *packageApmInjectSuite) assertDockerWorkloadRunning() {
+	running := strings.TrimSpace(s.Env().RemoteHost.MustExecute("sudo docker inspect --format '{{ .State.Running }}' python-app"))
+	assert.Equal(s.T(), "true", running)
+}
+
 func (s *packageApmInjectSuite) purgeInjectorDebInstall() {
 	s.Env().RemoteHost.MustExecute("sudo rm -f /var/run/datadog-installer/environment")
 	s.Env().RemoteHost.MustExecute("sudo rm -f /etc/datadog-agent/datadog.yaml")
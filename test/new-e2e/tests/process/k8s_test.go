@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DataDog/test-infra-definitions/common/config"
+	"github.com/DataDog/test-infra-definitions/common/utils"
+	"github.com/DataDog/test-infra-definitions/components/datadog/kubernetesagentparams"
+	kubeComp "github.com/DataDog/test-infra-definitions/components/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/DataDog/datadog-agent/test/fakeintake/aggregator"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/e2e"
+	"github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments"
+	awskubernetes "github.com/DataDog/datadog-agent/test/new-e2e/pkg/environments/aws/kubernetes"
+)
+
+const polyglotNamespace = "polyglot"
+
+// Language detection runs in the process agent on top of process collection, the detected
+// languages are reported by the node agent to the cluster agent which annotates the deployments.
+const languageDetectionHelmValues = `
+datadog:
+  processAgent:
+    enabled: true
+    processCollection: true
+  env:
+    - name: DD_LANGUAGE_DETECTION_ENABLED
+      value: "true"
+clusterAgent:
+  enabled: true
+  env:
+    - name: DD_LANGUAGE_DETECTION_ENABLED
+      value: "true"
+`
+
+type polyglotContainer struct {
+	name     string
+	image    string
+	command  []string
+	language string
+}
+
+type polyglotDeployment struct {
+	name       string
+	containers []polyglotContainer
+}
+
+var (
+	pythonContainer = polyglotContainer{
+		name:     "python",
+		image:    "python:3.12-alpine",
+		command:  []string{"python3", "-m", "http.server", "8080"},
+		language: "python",
+	}
+	nodeContainer = polyglotContainer{
+		name:     "node",
+		image:    "node:20-alpine",
+		command:  []string{"node", "-e", "require('http').createServer((req, res) => res.end()).listen(8081)"},
+		language: "node",
+	}
+	rubyContainer = polyglotContainer{
+		name:     "ruby",
+		image:    "ruby:3.3-alpine",
+		command:  []string{"ruby", "-run", "-e", "httpd", ".", "-p", "8082"},
+		language: "ruby",
+	}
+
+	polyglotDeployments = []polyglotDeployment{
+		{name: "python-app", containers: []polyglotContainer{pythonContainer}},
+		{name: "node-app", containers: []polyglotContainer{nodeContainer}},
+		// Languages are detected and reported per container
+		{name: "polyglot-app", containers: []polyglotContainer{pythonContainer, nodeContainer, rubyContainer}},
+	}
+)
+
+type k8sTestSuite struct {
+	e2e.BaseSuite[environments.Kubernetes]
+}
+
+func TestK8sTestSuite(t *testing.T) {
+	t.Parallel()
+	e2e.Run(t, &k8sTestSuite{}, e2e.WithProvisioner(
+		awskubernetes.KindProvisioner(
+			awskubernetes.WithAgentOptions(kubernetesagentparams.WithHelmValues(languageDetectionHelmValues)),
+			awskubernetes.WithWorkloadApp(polyglotWorkload),
+		),
+	))
+}
+
+// TestK8sIPv6TestSuite runs the same tests on a single-stack IPv6 cluster, where the agents reach the
+// kubelet, the cluster agent and the intake through IPv6 addresses
+func TestK8sIPv6TestSuite(t *testing.T) {
+	t.Parallel()
+	e2e.Run(t, &k8sTestSuite{}, e2e.WithProvisioner(
+		awskubernetes.KindProvisioner(
+			awskubernetes.WithName("kind-ipv6"),
+			awskubernetes.WithIPv6(),
+			awskubernetes.WithAgentOptions(kubernetesagentparams.WithHelmValues(languageDetectionHelmValues)),
+			awskubernetes.WithWorkloadApp(polyglotWorkload),
+		),
+	))
+}
+
+// Test00ProcessCollection checks the processes of the workloads are collected, it must run first
+// as the languages are only detected once the processes are seen by the process agent
+func (s *k8sTestSuite) Test00ProcessCollection() {
+	t := s.T()
+
+	var payloads []*aggregator.ProcessPayload
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		var err error
+		payloads, err = s.Env().FakeIntake.Client().GetProcesses()
+		assert.NoError(c, err, "failed to get process payloads from fakeintake")
+
+		for _, process := range []string{"python3", "node", "ruby"} {
+			var found bool
+			for _, payload := range payloads {
+				if found, _ = findProcess(process, payload.Processes, false); found {
+					break
+				}
+			}
+			assert.True(c, found, "%s process not found", process)
+		}
+	}, 5*time.Minute, 10*time.Second)
+
+	assertContainersCollected(t, payloads, []string{pythonContainer.name, nodeContainer.name, rubyContainer.name})
+}
+
+// TestLanguageDetectionAnnotations checks the languages detected on the node flow to the cluster
+// agent, which sets them as annotations of the deployments for the admission controller to
+// inject the matching libraries
+func (s *k8sTestSuite) TestLanguageDetectionAnnotations() {
+	for _, deployment := range polyglotDeployments {
+		s.Run(deployment.name, func() {
+			assert.EventuallyWithT(s.T(), func(c *assert.CollectT) {
+				d, err := s.Env().KubernetesCluster.Client().AppsV1().Deployments(polyglotNamespace).Get(context.Background(), deployment.name, k8smetav1.GetOptions{})
+				if !assert.NoError(c, err) {
+					return
+				}
+				for _, container := range deployment.containers {
+					annotation := fmt.Sprintf("internal.dd.datadoghq.com/%s.detected_langs", container.name)
+					assert.Equal(c, container.language, d.Annotations[annotation], "unexpected languages for container %s", container.name)
+				}
+			}, 5*time.Minute, 10*time.Second)
+		})
+	}
+}
+
+// polyglotWorkload deploys applications written in different languages, alone in their pod or together
+func polyglotWorkload(e config.Env, kubeProvider *kubernetes.Provider) (*kubeComp.Workload, error) {
+	opts := []pulumi.ResourceOption{pulumi.Provider(kubeProvider), pulumi.Parent(kubeProvider), pulumi.DeletedWith(kubeProvider)}
+
+	k8sComponent := &kubeComp.Workload{}
+	if err := e.Ctx().RegisterComponentResource("dd:apps", polyglotNamespace+"/polyglot", k8sComponent, opts...); err != nil {
+		return nil, err
+	}
+	opts = append(opts, pulumi.Parent(k8sComponent))
+
+	ns, err := corev1.NewNamespace(e.Ctx(), polyglotNamespace, &corev1.NamespaceArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name: pulumi.String(polyglotNamespace),
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, utils.PulumiDependsOn(ns))
+
+	for _, deployment := range polyglotDeployments {
+		var containers corev1.ContainerArray
+		for _, container := range deployment.containers {
+			containers = append(containers, &corev1.ContainerArgs{
+				Name:    pulumi.String(container.name),
+				Image:   pulumi.String(container.image),
+				Command: pulumi.ToStringArray(container.command),
+			})
+		}
+		labels := pulumi.StringMap{
+			"app": pulumi.String(deployment.name),
+		}
+		if _, err := appsv1.NewDeployment(e.Ctx(), polyglotNamespace+"/"+deployment.name, &appsv1.DeploymentArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(deployment.name),
+				Namespace: pulumi.String(polyglotNamespace),
+				Labels:    labels,
+			},
+			Spec: &appsv1.DeploymentSpecArgs{
+				Selector: &metav1.LabelSelectorArgs{
+					MatchLabels: labels,
+				},
+				Template: &corev1.PodTemplateSpecArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Labels: labels,
+					},
+					Spec: &corev1.PodSpecArgs{
+						Containers: containers,
+					},
+				},
+			},
+		}, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	return k8sComponent, nil
+}